@@ -0,0 +1,3 @@
+module github.com/rocjay1/rm-analyzer
+
+go 1.23
@@ -6,6 +6,12 @@ resource "azurerm_service_plan" "plan" {
   sku_name            = "FC1" # Flex Consumption
 }
 
+# Signs the acknowledgement links in reminder emails
+resource "random_password" "reminder_signing_key" {
+  length  = 48
+  special = false
+}
+
 resource "azurerm_function_app_flex_consumption" "app" {
   name                = "${var.project_name}-func-${local.resource_suffix}"
   resource_group_name = azurerm_resource_group.rg.name
@@ -55,10 +61,38 @@ resource "azurerm_function_app_flex_consumption" "app" {
     "SENDER_EMAIL"                    = "DoNotReply@${azurerm_email_communication_service_domain.domain.from_sender_domain}"
     "BUILD_FLAGS"                     = "UseElf"
     "BLOB_CONTAINER_NAME"             = "csv-uploads"
+    "RECEIPTS_CONTAINER_NAME"         = "receipts"
+    "BACKUPS_CONTAINER_NAME"          = "backups"
+    "REPORTS_CONTAINER_NAME"          = "reports"
+    "MESSAGES_CONTAINER_NAME"         = "messages"
     "QUEUE_NAME"                      = "csv-processing"
+    "BACKFILL_QUEUE_NAME"             = "csv-backfill"
     "TRANSACTIONS_TABLE"              = "transactions"
     "SAVINGS_TABLE"                   = "savings"
     "PEOPLE_TABLE"                    = "people"
+    "SETTINGS_TABLE"                  = "settings"
+    "API_KEYS_TABLE"                  = "apikeys"
+    "DEBTS_TABLE"                     = "debts"
+    "HEALTH_SCORES_TABLE"             = "healthscores"
+    "ACCOUNTS_TABLE"                  = "accounts"
+    "SUBSCRIPTIONS_TABLE"             = "subscriptions"
+    "RULES_TABLE"                     = "rules"
+    "BUDGETS_TABLE"                   = "budgets"
+    "INVITES_TABLE"                   = "invites"
+    "ACTIVITY_TABLE"                  = "activity"
+    "FAILURES_TABLE"                  = "failures"
+    "JOBS_TABLE"                      = "jobs"
+    "UPLOADS_TABLE"                   = "uploads"
+    "PROCESSING_LOG_TABLE"            = "processinglog"
+    "APP_URL"                         = "https://${azurerm_static_web_app_custom_domain.custom_domain.domain_name}"
+    "ADMIN_EMAILS"                    = join(",", var.admin_emails)
+    "REMINDER_SIGNING_KEY"            = random_password.reminder_signing_key.result
+    "LOG_LEVEL"                       = "INFO"
+    "LOG_FORMAT"                      = "text"
+    "LOG_DEBUG_SAMPLE_EVERY"          = "10"
+    "RETENTION_UPLOADS_DAYS"          = "90"
+    "RETENTION_TRANSACTIONS_DAYS"     = "0"
+    "RETENTION_SAVINGS_DAYS"          = "0"
   }
 }
 
@@ -25,11 +25,40 @@ resource "azurerm_storage_container" "csv" {
   container_access_type = "private"
 }
 
+resource "azurerm_storage_container" "receipts" {
+  name                  = "receipts"
+  storage_account_id    = azurerm_storage_account.sa.id
+  container_access_type = "private"
+}
+
+resource "azurerm_storage_container" "backups" {
+  name                  = "backups"
+  storage_account_id    = azurerm_storage_account.sa.id
+  container_access_type = "private"
+}
+
+resource "azurerm_storage_container" "reports" {
+  name                  = "reports"
+  storage_account_id    = azurerm_storage_account.sa.id
+  container_access_type = "private"
+}
+
+resource "azurerm_storage_container" "messages" {
+  name                  = "messages"
+  storage_account_id    = azurerm_storage_account.sa.id
+  container_access_type = "private"
+}
+
 resource "azurerm_storage_queue" "csv" {
   name               = "csv-processing"
   storage_account_id = azurerm_storage_account.sa.id
 }
 
+resource "azurerm_storage_queue" "backfill" {
+  name               = "csv-backfill"
+  storage_account_id = azurerm_storage_account.sa.id
+}
+
 # Storage Roles (Required for Keyless AzureWebJobsStorage)
 # Blob Data Owner is required for the Functions Host to manage leases and artifacts
 resource "azurerm_role_assignment" "storage_blob_owner" {
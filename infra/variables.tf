@@ -52,4 +52,9 @@ variable "github_repo" {
   type        = string
   default     = "rocjay1/rm-analyzer"
   description = "The GitHub repository in 'owner/repo' format for OIDC trust."
-}
\ No newline at end of file
+}
+variable "admin_emails" {
+  type        = list(string)
+  default     = []
+  description = "Emails allowed to call administrative endpoints (e.g. data purge)."
+}
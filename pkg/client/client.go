@@ -0,0 +1,287 @@
+// Package client is a client for the RMAnalyzer HTTP API, for the CLI, tests
+// and third-party automation.
+//
+// Requests authenticate with an API key (x-api-key) or any headers set with
+// WithHeader, such as the Static Web Apps auth cookie. Throttled and transient
+// failures are retried with exponential backoff, but only for idempotent
+// methods (GET, HEAD, PUT, DELETE and OPTIONS): a POST or PATCH that failed
+// part-way may already have taken effect, so it is never sent twice.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// APIKeyHeader carries an API key issued by the bootstrap endpoint.
+	APIKeyHeader = "x-api-key"
+	// HouseholdHeader names the household a request works on: live or sandbox.
+	HouseholdHeader = "x-household"
+	// ShareTokenHeader carries the token of a shared report link.
+	ShareTokenHeader = "x-share-token"
+	// UndoHeader names the undo operation that reverts a delete.
+	UndoHeader = "x-undo-id"
+)
+
+// Statuses worth retrying: throttling and transient server errors.
+var retryableStatuses = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// Methods that can be sent again without changing the outcome.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// Client calls the RMAnalyzer API. Create one with New.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	headers    http.Header
+	retries    int
+	backoff    time.Duration
+	sleep      func(context.Context, time.Duration) error
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithAPIKey authenticates every request with an API key.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.headers.Set(APIKeyHeader, key) }
+}
+
+// WithHeader sends a header with every request, e.g. the Static Web Apps
+// auth cookie.
+func WithHeader(name, value string) Option {
+	return func(c *Client) { c.headers.Add(name, value) }
+}
+
+// WithHousehold works on the named household (live or sandbox).
+func WithHousehold(name string) Option {
+	return func(c *Client) { c.headers.Set(HouseholdHeader, name) }
+}
+
+// WithHTTPClient sends requests with the given http.Client.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetries sets the attempts after the first for throttled or transient
+// failures of idempotent requests, and the delay before the first retry,
+// which doubles on each attempt.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// New creates a Client for the API at baseURL, e.g. "https://example.com/api".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		headers:    http.Header{},
+		retries:    3,
+		backoff:    500 * time.Millisecond,
+		sleep:      sleepContext,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// APIError is returned when the API answers with a non-success status.
+type APIError struct {
+	Status int
+	Body   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.Status, e.Body)
+}
+
+// FieldError is a field-level validation error from a 400 response.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors returns the field-level validation errors of a 400 response,
+// if any.
+func (e *APIError) FieldErrors() []FieldError {
+	var body struct {
+		Fields []FieldError `json:"fields"`
+	}
+	if json.Unmarshal([]byte(e.Body), &body) != nil {
+		return nil
+	}
+	return body.Fields
+}
+
+// IsNotFound reports whether err is an APIError with status 404.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// request describes one API call.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+	headers     http.Header
+}
+
+// do sends a request and returns the response body and headers. Idempotent
+// requests are retried on throttling, transient server errors and network
+// errors; others are sent once.
+func (c *Client) do(ctx context.Context, r request) ([]byte, http.Header, error) {
+	target := c.baseURL + "/" + strings.TrimLeft(r.path, "/")
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+	retries := 0
+	if idempotentMethods[r.method] {
+		retries = c.retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		body, header, err := c.send(ctx, target, r)
+		var apiErr *APIError
+		retryable := err != nil && ctx.Err() == nil &&
+			(!errors.As(err, &apiErr) || retryableStatuses[apiErr.Status])
+		if !retryable || attempt >= retries {
+			return body, header, err
+		}
+		if err := c.sleep(ctx, c.backoff<<attempt); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, target string, r request) ([]byte, http.Header, error) {
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, target, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, values := range c.headers {
+		req.Header[name] = values
+	}
+	for name, values := range r.headers {
+		req.Header[name] = values
+	}
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, resp.Header, &APIError{Status: resp.StatusCode, Body: string(payload)}
+	}
+	return payload, resp.Header, nil
+}
+
+// doJSON sends payload (if any) as JSON and decodes a JSON response into out
+// (if non-nil).
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, payload, out any) (http.Header, error) {
+	r := request{method: method, path: path, query: query}
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		r.body = encoded
+		r.contentType = "application/json"
+	}
+	body, header, err := c.do(ctx, r)
+	if err != nil {
+		return header, err
+	}
+	if out == nil {
+		return header, nil
+	}
+	if err := decode(body, out); err != nil {
+		return header, fmt.Errorf("decoding %s %s response: %w", method, path, err)
+	}
+	return header, nil
+}
+
+// decode decodes a JSON response body into out, leaving out unchanged if the
+// body is empty.
+func decode(body []byte, out any) error {
+	if len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, out any) error {
+	_, err := c.doJSON(ctx, http.MethodGet, path, query, nil, out)
+	return err
+}
+
+// getBytes fetches a file such as a CSV export.
+func (c *Client) getBytes(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	body, _, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query})
+	return body, err
+}
+
+// query builds query parameters from name/value pairs, leaving out empty values.
+func query(pairs ...string) url.Values {
+	values := url.Values{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			values.Set(pairs[i], pairs[i+1])
+		}
+	}
+	return values
+}
+
+// escape escapes a path segment.
+func escape(segment string) string {
+	return url.PathEscape(segment)
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return New(server.URL+"/api", WithAPIKey("secret"), WithHousehold("sandbox"), WithRetries(2, 0))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestSendsAuthAndHouseholdHeaders(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(APIKeyHeader) != "secret" || r.Header.Get(HouseholdHeader) != "sandbox" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		if r.URL.Path != "/api/savings" || r.URL.Query().Get("month") != "2025-03" {
+			t.Errorf("url = %s", r.URL)
+		}
+		writeJSON(w, Savings{StartingBalance: 100, Items: []SavingsItem{{Name: "Rent", Cost: 50}}})
+	})
+
+	savings, err := c.GetSavings(context.Background(), "2025-03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if savings.StartingBalance != 100 || savings.Items[0].Name != "Rent" {
+		t.Errorf("savings = %+v", savings)
+	}
+}
+
+func TestRetriesIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, Cards{Cards: []Card{{AccountID: "a1"}}})
+	})
+
+	cards, err := c.GetCards(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 || cards.Cards[0].AccountID != "a1" {
+		t.Errorf("calls = %d, cards = %+v", calls.Load(), cards)
+	}
+}
+
+func TestDoesNotRetryPosts(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	err := c.SaveSavings(context.Background(), "2025-03", Savings{StartingBalance: 1})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadGateway {
+		t.Fatalf("err = %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]any{"fields": []FieldError{{Field: "month", Message: "is invalid"}}})
+	})
+
+	_, err := c.ListTransactions(context.Background(), TransactionQuery{Month: "2025-13"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || calls.Load() != 1 {
+		t.Fatalf("err = %v, calls = %d", err, calls.Load())
+	}
+	if fields := apiErr.FieldErrors(); len(fields) != 1 || fields[0].Field != "month" {
+		t.Errorf("fields = %+v", fields)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	_, err := c.GetUpload(context.Background(), "missing.csv")
+	if !IsNotFound(err) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestUploadSendsMultipartFile(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(file)
+		if header.Filename != "may.csv" || string(content) != "Date,Name\n" {
+			t.Errorf("file = %s %q", header.Filename, content)
+		}
+		if r.URL.Query().Get("priority") != "backfill" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		writeJSON(w, UploadResult{BlobName: "b.csv", Status: "queued"})
+	})
+
+	result, err := c.Upload(context.Background(), "may.csv", []byte("Date,Name\n"), "backfill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BlobName != "b.csv" || result.Status != "queued" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestAllActivityFollowsCursor(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			next := "p2"
+			writeJSON(w, ActivityPage{Events: []ActivityEvent{{ID: "1"}, {ID: "2"}}, Cursor: &next})
+		case "p2":
+			writeJSON(w, ActivityPage{Events: []ActivityEvent{{ID: "3"}}})
+		default:
+			t.Errorf("cursor = %s", r.URL.Query().Get("cursor"))
+		}
+	})
+
+	var ids []string
+	for event, err := range c.AllActivity(context.Background(), "", 2) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, event.ID)
+	}
+	if len(ids) != 3 || ids[2] != "3" {
+		t.Errorf("ids = %v", ids)
+	}
+}
+
+func TestAllTransactionsPagesByMonth(t *testing.T) {
+	var months []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		month := r.URL.Query().Get("month")
+		months = append(months, month)
+		writeJSON(w, TransactionPage{Month: month, Transactions: []Transaction{{ID: month}}})
+	})
+
+	var ids []string
+	for tx, err := range c.AllTransactions(context.Background(), "2024-11", "2025-02", "", "") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, tx.ID)
+	}
+	want := []string{"2024-11", "2024-12", "2025-01", "2025-02"}
+	if len(ids) != len(want) || ids[3] != want[3] || months[1] != want[1] {
+		t.Errorf("ids = %v, months = %v", ids, months)
+	}
+
+	for _, err := range c.AllTransactions(context.Background(), "2025-02", "2024-11", "", "") {
+		if err == nil {
+			t.Error("expected an error for a backwards range")
+		}
+	}
+}
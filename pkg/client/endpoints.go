@@ -0,0 +1,798 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Uploads
+
+// Upload sends a statement file for asynchronous processing at the given
+// priority (interactive or backfill; empty for interactive). Check on it with
+// GetUpload.
+func (c *Client) Upload(ctx context.Context, fileName string, content []byte, priority string) (*UploadResult, error) {
+	body, contentType, err := multipartFile(fileName, content)
+	if err != nil {
+		return nil, err
+	}
+	raw, _, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "upload",
+		query:       query("priority", priority),
+		body:        body,
+		contentType: contentType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result UploadResult
+	return &result, decode(raw, &result)
+}
+
+// ListUploads lists up to limit recent uploads, newest first (0 for the
+// server's default).
+func (c *Client) ListUploads(ctx context.Context, limit int) ([]Upload, error) {
+	var out struct {
+		Uploads []Upload `json:"uploads"`
+	}
+	err := c.get(ctx, "uploads", query("limit", itoa(limit)), &out)
+	return out.Uploads, err
+}
+
+// GetUpload returns an upload's processing status. An untracked upload is
+// an APIError with status 404; see IsNotFound.
+func (c *Client) GetUpload(ctx context.Context, blobName string) (*Upload, error) {
+	var out Upload
+	if err := c.get(ctx, "uploads/"+escape(blobName), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReprocessUpload re-categorizes an upload's stored transactions from its
+// original file.
+func (c *Client) ReprocessUpload(ctx context.Context, blobName string) (Object, error) {
+	return c.object(ctx, http.MethodPost, "uploads/"+escape(blobName)+"/reprocess", nil, nil)
+}
+
+// ListFailures lists uploads that failed processing.
+func (c *Client) ListFailures(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "failures", nil, nil)
+}
+
+// RetryFailure re-enqueues a failed upload for processing.
+func (c *Client) RetryFailure(ctx context.Context, id string) (Object, error) {
+	return c.object(ctx, http.MethodPost, "failures/"+escape(id)+"/retry", nil, nil)
+}
+
+// Documents
+
+// ListDocuments lists a month's archived documents, for one account if
+// accountNumber isn't nil.
+func (c *Client) ListDocuments(ctx context.Context, month string, accountNumber *int) (Object, error) {
+	q := query("month", month)
+	if accountNumber != nil {
+		q.Set("accountNumber", strconv.Itoa(*accountNumber))
+	}
+	return c.object(ctx, http.MethodGet, "documents", q, nil)
+}
+
+// UploadDocument files a document against a month, and an account if
+// accountNumber isn't nil.
+func (c *Client) UploadDocument(ctx context.Context, month string, accountNumber *int, description, fileName string, content []byte) (Object, error) {
+	body, contentType, err := multipartFile(fileName, content)
+	if err != nil {
+		return nil, err
+	}
+	q := query("month", month, "description", description)
+	if accountNumber != nil {
+		q.Set("accountNumber", strconv.Itoa(*accountNumber))
+	}
+	raw, _, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "documents",
+		query:       q,
+		body:        body,
+		contentType: contentType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out Object
+	return out, decode(raw, &out)
+}
+
+// GetDocument returns an archived document with a fresh download link.
+func (c *Client) GetDocument(ctx context.Context, id string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "documents/"+escape(id), nil, nil)
+}
+
+// DeleteDocument deletes an archived document.
+func (c *Client) DeleteDocument(ctx context.Context, id string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, "documents/"+escape(id), nil, nil, nil)
+	return err
+}
+
+// Alerts
+
+// ListAlerts lists the alerts sent to the caller.
+func (c *Client) ListAlerts(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "alerts", nil, nil)
+}
+
+// UpdateAlert snoozes (until snoozedUntil, YYYY-MM-DD), dismisses or reopens
+// an alert.
+func (c *Client) UpdateAlert(ctx context.Context, id, status, snoozedUntil string) (Object, error) {
+	payload := Object{"status": status}
+	if snoozedUntil != "" {
+		payload["snoozedUntil"] = snoozedUntil
+	}
+	return c.object(ctx, http.MethodPatch, "alerts/"+escape(id), nil, payload)
+}
+
+// Savings
+
+// GetSavings returns the caller's savings for a month (YYYY-MM). A month
+// with nothing saved is an APIError with status 404; see IsNotFound.
+func (c *Client) GetSavings(ctx context.Context, month string) (*Savings, error) {
+	var out Savings
+	if err := c.get(ctx, "savings", query("month", month), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SaveSavings saves the caller's savings for a month.
+func (c *Client) SaveSavings(ctx context.Context, month string, savings Savings) error {
+	payload := Object{
+		"month":           month,
+		"startingBalance": savings.StartingBalance,
+		"items":           savings.Items,
+	}
+	_, err := c.doJSON(ctx, http.MethodPost, "savings", nil, payload, nil)
+	return err
+}
+
+// CopySavings starts a month's savings from an earlier month's items and
+// ending balance, and returns what was saved.
+func (c *Client) CopySavings(ctx context.Context, fromMonth, toMonth string, excludeOneOff bool) (Object, error) {
+	q := query("from", fromMonth, "to", toMonth, "excludeOneOff", strconv.FormatBool(excludeOneOff))
+	return c.object(ctx, http.MethodPost, "savings/copy", q, nil)
+}
+
+// RoundUps suggests a savings contribution from the round-ups of the
+// caller's purchases in a month.
+func (c *Client) RoundUps(ctx context.Context, month string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "savings/roundups", query("month", month), nil)
+}
+
+// RecordRoundUps records a month's round-ups as its round-up savings item.
+func (c *Client) RecordRoundUps(ctx context.Context, month string) (Object, error) {
+	return c.object(ctx, http.MethodPost, "savings/roundups", query("month", month), nil)
+}
+
+// ExportSavings exports a savings month as CSV.
+func (c *Client) ExportSavings(ctx context.Context, month string) ([]byte, error) {
+	return c.getBytes(ctx, "savings/export", query("month", month))
+}
+
+// ExportBudget exports a year's monthly budget status as CSV.
+func (c *Client) ExportBudget(ctx context.Context, year int) ([]byte, error) {
+	return c.getBytes(ctx, "budget/export", query("year", strconv.Itoa(year)))
+}
+
+// Transactions
+
+// ListTransactions lists a month's transactions. See AllTransactions to list
+// a range of months.
+func (c *Client) ListTransactions(ctx context.Context, q TransactionQuery) (*TransactionPage, error) {
+	var out TransactionPage
+	params := query("month", q.Month, "filter", q.Filter, "sort", q.Sort)
+	if err := c.get(ctx, "transactions", params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SearchTransactions finds stored transactions by words in their
+// description. params holds the optional months, minAmount, maxAmount,
+// from, to and limit.
+func (c *Client) SearchTransactions(ctx context.Context, text string, params url.Values) (Object, error) {
+	q := url.Values{}
+	for name, values := range params {
+		q[name] = values
+	}
+	q.Set("q", text)
+	return c.object(ctx, http.MethodGet, "transactions/search", q, nil)
+}
+
+// ExportTransactions exports the transactions from one month to another
+// (YYYY-MM) as csv, ndjson or xlsx, narrowed by a filter if one is given.
+func (c *Client) ExportTransactions(ctx context.Context, fromMonth, toMonth, format, filter string) ([]byte, error) {
+	q := query("from", fromMonth, "to", toMonth, "format", format, "filter", filter)
+	return c.getBytes(ctx, "transactions/export", q)
+}
+
+// Export exports a month's transactions as csv or xlsx.
+func (c *Client) Export(ctx context.Context, month, format string) ([]byte, error) {
+	return c.getBytes(ctx, "export", query("month", month, "format", format))
+}
+
+// UpdateTransaction corrects a stored transaction.
+func (c *Client) UpdateTransaction(ctx context.Context, id string, changes TransactionChanges) (Object, error) {
+	return c.object(ctx, http.MethodPut, "transactions/"+escape(id), nil, changes)
+}
+
+// DeleteTransaction deletes a stored transaction and returns the ID of the
+// undo operation that restores it.
+func (c *Client) DeleteTransaction(ctx context.Context, id string) (string, error) {
+	header, err := c.doJSON(ctx, http.MethodDelete, "transactions/"+escape(id), nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	return header.Get(UndoHeader), nil
+}
+
+// SetTransactionOwner reassigns a transaction to another member, or back to
+// its account's owner if owner is empty.
+func (c *Client) SetTransactionOwner(ctx context.Context, id, owner string) (Object, error) {
+	payload := Object{"owner": nil}
+	if owner != "" {
+		payload["owner"] = owner
+	}
+	return c.object(ctx, http.MethodPatch, "transactions/"+escape(id)+"/owner", nil, payload)
+}
+
+// BulkEditTransactions changes the category, ignore flag or owner of the
+// transactions a filter or list of IDs selects in the given months. With
+// dryRun, it only reports what would change.
+func (c *Client) BulkEditTransactions(ctx context.Context, months []string, filter string, ids []string, changes map[string]string, dryRun bool) (Object, error) {
+	payload := Object{"months": months, "changes": changes, "dryRun": dryRun}
+	if filter != "" {
+		payload["filter"] = filter
+	}
+	if ids != nil {
+		payload["ids"] = ids
+	}
+	return c.object(ctx, http.MethodPost, "transactions/bulk-edit", nil, payload)
+}
+
+// Undo reverts a recent bulk edit or delete.
+func (c *Client) Undo(ctx context.Context, operationID string) (Object, error) {
+	return c.object(ctx, http.MethodPost, "undo/"+escape(operationID), nil, nil)
+}
+
+// Rules
+
+// ListRules lists the category rules applied to uploaded transactions.
+func (c *Client) ListRules(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "rules", nil, nil)
+}
+
+// AddRule adds a category rule; rule holds its category and conditions.
+func (c *Client) AddRule(ctx context.Context, rule Object) (Object, error) {
+	return c.object(ctx, http.MethodPost, "rules", nil, rule)
+}
+
+// ReplaceRule replaces a category rule.
+func (c *Client) ReplaceRule(ctx context.Context, id string, rule Object) (Object, error) {
+	return c.object(ctx, http.MethodPut, "rules/"+escape(id), nil, rule)
+}
+
+// DeleteRule deletes a category rule.
+func (c *Client) DeleteRule(ctx context.Context, id string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, "rules/"+escape(id), nil, nil, nil)
+	return err
+}
+
+// ApplyRules re-applies the category rules to the stored transactions of the
+// given months, in the background if async is set.
+func (c *Client) ApplyRules(ctx context.Context, months []string, async bool) (Object, error) {
+	payload := Object{"months": months, "async": async}
+	return c.object(ctx, http.MethodPost, "rules/apply", nil, payload)
+}
+
+// Views
+
+// ListViews lists the caller's saved transaction views.
+func (c *Client) ListViews(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "views", nil, nil)
+}
+
+// SaveView saves a transaction view: a name with a filter and sort.
+func (c *Client) SaveView(ctx context.Context, name, filter, sort string) (Object, error) {
+	return c.object(ctx, http.MethodPost, "views", nil, viewPayload(name, filter, sort))
+}
+
+// ReplaceView replaces one of the caller's transaction views.
+func (c *Client) ReplaceView(ctx context.Context, id, name, filter, sort string) (Object, error) {
+	return c.object(ctx, http.MethodPut, "views/"+escape(id), nil, viewPayload(name, filter, sort))
+}
+
+// DeleteView deletes one of the caller's transaction views.
+func (c *Client) DeleteView(ctx context.Context, id string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, "views/"+escape(id), nil, nil, nil)
+	return err
+}
+
+func viewPayload(name, filter, sort string) Object {
+	payload := Object{"name": name}
+	if filter != "" {
+		payload["filter"] = filter
+	}
+	if sort != "" {
+		payload["sort"] = sort
+	}
+	return payload
+}
+
+// Reports
+
+// Summary returns a month's spend per category, account and person.
+func (c *Client) Summary(ctx context.Context, month string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "summary", query("month", month), nil)
+}
+
+// CompareReport compares two members' spending by category for a month.
+func (c *Client) CompareReport(ctx context.Context, p1, p2, month string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "reports/compare", query("p1", p1, "p2", p2, "month", month), nil)
+}
+
+// TrendReport returns monthly spend over a range, in real terms if real is set.
+func (c *Client) TrendReport(ctx context.Context, fromMonth, toMonth string, real bool) (Object, error) {
+	q := query("from", fromMonth, "to", toMonth, "real", strconv.FormatBool(real))
+	return c.object(ctx, http.MethodGet, "reports/trend", q, nil)
+}
+
+// DiffReport explains what changed in spending between two months.
+func (c *Client) DiffReport(ctx context.Context, a, b string, limit int) (Object, error) {
+	return c.object(ctx, http.MethodGet, "reports/diff", query("a", a, "b", b, "limit", itoa(limit)), nil)
+}
+
+// ReviewPacket returns a month's review packet.
+func (c *Client) ReviewPacket(ctx context.Context, month string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "reports/review", query("month", month, "format", "json"), nil)
+}
+
+// ReviewPacketPDF returns a month's review packet as a printable PDF.
+func (c *Client) ReviewPacketPDF(ctx context.Context, month string) ([]byte, error) {
+	return c.getBytes(ctx, "reports/review", query("month", month, "format", "pdf"))
+}
+
+// ListActivity returns a page of the household's activity, newest first. See
+// AllActivity to follow the cursor through every page.
+func (c *Client) ListActivity(ctx context.Context, q ActivityQuery) (*ActivityPage, error) {
+	var out ActivityPage
+	params := query("kind", q.Kind, "limit", itoa(q.Limit), "cursor", q.Cursor)
+	if err := c.get(ctx, "activity", params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// HealthScore returns the caller's financial health score for a month.
+func (c *Client) HealthScore(ctx context.Context, month string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "health-score", query("month", month), nil)
+}
+
+// EmergencyFund returns the months of essential spending the emergency fund
+// covers.
+func (c *Client) EmergencyFund(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "emergency-fund", nil, nil)
+}
+
+// Subscriptions lists detected recurring charges with their price history.
+func (c *Client) Subscriptions(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "subscriptions", nil, nil)
+}
+
+// Debts
+
+// Debt returns who owes whom for a month, with a per-category breakdown.
+func (c *Client) Debt(ctx context.Context, month string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "debt", query("month", month), nil)
+}
+
+// DebtHistory returns the debt ledger with the running balance.
+func (c *Client) DebtHistory(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "debts/history", nil, nil)
+}
+
+// SettleDebt records a settlement paid by whoever owes. With a month
+// (YYYY-MM) it pays that month's debt; with "" it pays down the outstanding
+// ledger balance. It may not exceed what is owed.
+func (c *Client) SettleDebt(ctx context.Context, amount float64, month string) (Object, error) {
+	payload := Object{"amount": amount}
+	if month != "" {
+		payload["month"] = month
+	}
+	return c.object(ctx, http.MethodPost, "debts/settle", nil, payload)
+}
+
+// Settings, categories and budgets
+
+// GetSettings returns the household settings.
+func (c *Client) GetSettings(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "settings", nil, nil)
+}
+
+// UpdateSettings updates the household settings given.
+func (c *Client) UpdateSettings(ctx context.Context, settings Object) (Object, error) {
+	return c.object(ctx, http.MethodPut, "settings", nil, settings)
+}
+
+// ListCategories lists the categories with their flags and colors.
+func (c *Client) ListCategories(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "categories", nil, nil)
+}
+
+// AddCategory adds a category (admin only); category holds its name and
+// optional essential, color and budgetEligible.
+func (c *Client) AddCategory(ctx context.Context, category Object) (Object, error) {
+	return c.object(ctx, http.MethodPost, "categories", nil, category)
+}
+
+// UpdateCategory changes a category's flags and color (admin only).
+func (c *Client) UpdateCategory(ctx context.Context, category Object) (Object, error) {
+	return c.object(ctx, http.MethodPut, "categories", nil, category)
+}
+
+// DeleteCategory deletes an added category (admin only).
+func (c *Client) DeleteCategory(ctx context.Context, name string) (Object, error) {
+	return c.object(ctx, http.MethodDelete, "categories", query("name", name), nil)
+}
+
+// ListBudgets lists the monthly category budgets.
+func (c *Client) ListBudgets(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "budgets", nil, nil)
+}
+
+// SetBudget sets a category's monthly budget.
+func (c *Client) SetBudget(ctx context.Context, category string, limit float64) (Object, error) {
+	return c.object(ctx, http.MethodPut, "budgets", nil, Object{"category": category, "limit": limit})
+}
+
+// DeleteBudget removes a category's monthly budget.
+func (c *Client) DeleteBudget(ctx context.Context, category string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, "budgets", query("category", category), nil, nil)
+	return err
+}
+
+// BudgetStatus compares the category budgets with a month's spend.
+func (c *Client) BudgetStatus(ctx context.Context, month string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "budgets/status", query("month", month), nil)
+}
+
+// Accounts and cards
+
+// UnassignedAccounts lists the account numbers in a month's transactions
+// that belong to no member.
+func (c *Client) UnassignedAccounts(ctx context.Context, month string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "accounts/unassigned", query("month", month), nil)
+}
+
+// AssignAccount maps an account number to a member for future imports.
+func (c *Client) AssignAccount(ctx context.Context, accountNumber int, email string) (Object, error) {
+	payload := Object{"accountNumber": accountNumber, "email": email}
+	return c.object(ctx, http.MethodPost, "accounts/assign", nil, payload)
+}
+
+// SyncAccounts stores the caller's accounts from an account-sync payload.
+func (c *Client) SyncAccounts(ctx context.Context, accounts []Object, override bool) (Object, error) {
+	payload := Object{"accounts": accounts, "override": override}
+	return c.object(ctx, http.MethodPost, "accounts/sync", nil, payload)
+}
+
+// ReconcileAccount reconciles one of the caller's synced accounts against a
+// statement balance on date (YYYY-MM-DD, empty for today), accepting the
+// difference if accept is set.
+func (c *Client) ReconcileAccount(ctx context.Context, accountID string, balance float64, date string, accept bool) (Object, error) {
+	return c.object(ctx, http.MethodPost, "accounts/"+escape(accountID)+"/reconcile", nil, reconcilePayload(balance, date, accept))
+}
+
+// ReconcileCard reconciles one of the caller's cards against a statement
+// balance.
+func (c *Client) ReconcileCard(ctx context.Context, accountID string, balance float64, date string, accept bool) (Object, error) {
+	return c.object(ctx, http.MethodPost, "cards/"+escape(accountID)+"/reconcile", nil, reconcilePayload(balance, date, accept))
+}
+
+func reconcilePayload(balance float64, date string, accept bool) Object {
+	payload := Object{"balance": balance, "accept": accept}
+	if date != "" {
+		payload["date"] = date
+	}
+	return payload
+}
+
+// CardReconciliations lists a card's reconcile attempts, newest first.
+func (c *Client) CardReconciliations(ctx context.Context, accountID string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "cards/"+escape(accountID)+"/reconciliations", nil, nil)
+}
+
+// GetCards lists the caller's cards with per-card and aggregate utilization.
+func (c *Client) GetCards(ctx context.Context) (*Cards, error) {
+	var out Cards
+	if err := c.get(ctx, "cards", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CardStatements lists a card's closed statements, newest first.
+func (c *Client) CardStatements(ctx context.Context, accountID string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "cards/"+escape(accountID)+"/statements", nil, nil)
+}
+
+// InterestProjection projects a card's interest over months if only payment
+// is made each month (0 for the server's defaults).
+func (c *Client) InterestProjection(ctx context.Context, accountID string, payment float64, months int) (Object, error) {
+	q := query("months", itoa(months))
+	if payment > 0 {
+		q.Set("payment", strconv.FormatFloat(payment, 'f', -1, 64))
+	}
+	return c.object(ctx, http.MethodGet, "cards/"+escape(accountID)+"/interest-projection", q, nil)
+}
+
+// CardFees compares each card's annual fee with a year of spend on it.
+func (c *Client) CardFees(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "cards/fees", nil, nil)
+}
+
+// CardRewards estimates the rewards each card earned in a month.
+func (c *Client) CardRewards(ctx context.Context, month string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "cards/rewards", query("month", month), nil)
+}
+
+// BestCard suggests the card with the best reward rate for a category, or
+// for each category if category is empty.
+func (c *Client) BestCard(ctx context.Context, category string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "cards/best", query("category", category), nil)
+}
+
+// People, invites and shares
+
+// ListPeople lists the household members.
+func (c *Client) ListPeople(ctx context.Context) ([]Person, error) {
+	var out struct {
+		People []Person `json:"people"`
+	}
+	err := c.get(ctx, "people", nil, &out)
+	return out.People, err
+}
+
+// SavePerson adds or updates a household member.
+func (c *Client) SavePerson(ctx context.Context, person Person) (*Person, error) {
+	var out Person
+	if _, err := c.doJSON(ctx, http.MethodPost, "people", nil, person, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeletePerson removes a household member.
+func (c *Client) DeletePerson(ctx context.Context, email string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, "people/"+escape(email), nil, nil, nil)
+	return err
+}
+
+// PersonAccounts lists a member's accounts.
+func (c *Client) PersonAccounts(ctx context.Context, email string) (Object, error) {
+	return c.object(ctx, http.MethodGet, "people/"+escape(email)+"/accounts", nil, nil)
+}
+
+// AddPersonAccount associates an account with a member, with a weight for
+// shared accounts (0 for the whole account).
+func (c *Client) AddPersonAccount(ctx context.Context, email string, accountNumber int, weight float64) (Object, error) {
+	payload := Object{"accountNumber": accountNumber}
+	if weight > 0 {
+		payload["weight"] = weight
+	}
+	return c.object(ctx, http.MethodPost, "people/"+escape(email)+"/accounts", nil, payload)
+}
+
+// RemovePersonAccount removes an account from a member.
+func (c *Client) RemovePersonAccount(ctx context.Context, email string, accountNumber int) (Object, error) {
+	path := fmt.Sprintf("people/%s/accounts/%d", escape(email), accountNumber)
+	return c.object(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// Invite emails a new member an invite link (admin only).
+func (c *Client) Invite(ctx context.Context, name, email string) (Object, error) {
+	return c.object(ctx, http.MethodPost, "invites", nil, Object{"name": name, "email": email})
+}
+
+// AcceptInvite adds the signed-in caller to the household from an invite token.
+func (c *Client) AcceptInvite(ctx context.Context, token string) (*Person, error) {
+	var out Person
+	if _, err := c.doJSON(ctx, http.MethodPost, "invites/accept", nil, Object{"token": token}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListShares lists the caller's report share links.
+func (c *Client) ListShares(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "shares", nil, nil)
+}
+
+// CreateShare creates a report share link; share holds the report and its
+// month or range, real and expiresInDays.
+func (c *Client) CreateShare(ctx context.Context, share Object) (Object, error) {
+	return c.object(ctx, http.MethodPost, "shares", nil, share)
+}
+
+// RevokeShare revokes one of the caller's report share links.
+func (c *Client) RevokeShare(ctx context.Context, id string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, "shares/"+escape(id), nil, nil, nil)
+	return err
+}
+
+// SharedReport opens a shared report from its link's token, without signing in.
+func (c *Client) SharedReport(ctx context.Context, token string) (Object, error) {
+	raw, _, err := c.do(ctx, request{
+		method:  http.MethodGet,
+		path:    "shared",
+		headers: http.Header{http.CanonicalHeaderKey(ShareTokenHeader): {token}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out Object
+	return out, decode(raw, &out)
+}
+
+// Administration (admin only)
+
+// Purge previews (dryRun) or deletes the household's data. Deleting takes the
+// confirmation token a preview returns.
+func (c *Client) Purge(ctx context.Context, dryRun bool, confirmationToken string) (Object, error) {
+	payload := Object{"dryRun": dryRun}
+	if confirmationToken != "" {
+		payload["confirmationToken"] = confirmationToken
+	}
+	return c.object(ctx, http.MethodPost, "manage/purge", nil, payload)
+}
+
+// RetentionPreview shows what the next retention run will delete.
+func (c *Client) RetentionPreview(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "manage/retention", nil, nil)
+}
+
+// Bootstrap provisions storage, default settings and the first API key.
+func (c *Client) Bootstrap(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodPost, "manage/bootstrap", nil, nil)
+}
+
+// SeedSandbox resets the sandbox household to fresh demo data.
+func (c *Client) SeedSandbox(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodPost, "manage/sandbox/seed", nil, nil)
+}
+
+// SeedDemo fills an empty live household with demo data. Like SeedSandbox, it
+// picks its household itself, so a client made WithHousehold("sandbox") is
+// turned away.
+func (c *Client) SeedDemo(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodPost, "manage/seed-demo", nil, nil)
+}
+
+// VerifyBackup verifies the latest backup's manifest and tables.
+func (c *Client) VerifyBackup(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodPost, "manage/backup/verify", nil, nil)
+}
+
+// RehearseRestore restores the latest backup into scratch tables and
+// compares them with the live ones.
+func (c *Client) RehearseRestore(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodPost, "manage/backup/rehearse", nil, nil)
+}
+
+// TableRoutes lists the table each table name is switched to.
+func (c *Client) TableRoutes(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "manage/tables", nil, nil)
+}
+
+// SwitchTables switches table names to other tables together.
+func (c *Client) SwitchTables(ctx context.Context, tables map[string]string) (Object, error) {
+	return c.object(ctx, http.MethodPost, "manage/tables/switch", nil, Object{"tables": tables})
+}
+
+// RollbackTables undoes the last table switch.
+func (c *Client) RollbackTables(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodPost, "manage/tables/rollback", nil, nil)
+}
+
+// StorageOps shows the table requests per endpoint and their projected cost.
+func (c *Client) StorageOps(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "manage/storage-ops", nil, nil)
+}
+
+// ListConnectors lists the pull connectors with their last pull.
+func (c *Client) ListConnectors(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "manage/connectors", nil, nil)
+}
+
+// SaveConnector creates or replaces a pull connector.
+func (c *Client) SaveConnector(ctx context.Context, name string, connector Object) (Object, error) {
+	return c.object(ctx, http.MethodPut, "manage/connectors/"+escape(name), nil, connector)
+}
+
+// DeleteConnector removes a pull connector.
+func (c *Client) DeleteConnector(ctx context.Context, name string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, "manage/connectors/"+escape(name), nil, nil, nil)
+	return err
+}
+
+// ListMailboxes lists the mailbox connectors and their last fetch.
+func (c *Client) ListMailboxes(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "manage/mailboxes", nil, nil)
+}
+
+// SaveMailbox creates or replaces a mailbox connector. Access is granted by
+// opening MailboxAuthorizeURL in a browser.
+func (c *Client) SaveMailbox(ctx context.Context, name string, mailbox Object) (Object, error) {
+	return c.object(ctx, http.MethodPut, "manage/mailboxes/"+escape(name), nil, mailbox)
+}
+
+// DeleteMailbox removes a mailbox connector.
+func (c *Client) DeleteMailbox(ctx context.Context, name string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, "manage/mailboxes/"+escape(name), nil, nil, nil)
+	return err
+}
+
+// MailboxAuthorizeURL is the page an admin opens to grant access to a mailbox.
+func (c *Client) MailboxAuthorizeURL(name string) string {
+	return c.baseURL + "/manage/mailboxes/" + escape(name) + "/authorize"
+}
+
+// NightlyJobHistory lists the nightly job's recent runs.
+func (c *Client) NightlyJobHistory(ctx context.Context, limit int) (Object, error) {
+	return c.object(ctx, http.MethodGet, "jobs/nightly/history", query("limit", itoa(limit)), nil)
+}
+
+// Usage returns the household's storage and email usage.
+func (c *Client) Usage(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "usage", nil, nil)
+}
+
+// Queues returns the processing queues' backlog and oldest message age.
+func (c *Client) Queues(ctx context.Context) (Object, error) {
+	return c.object(ctx, http.MethodGet, "manage/queues", nil, nil)
+}
+
+// object sends a request with an optional JSON payload and decodes a JSON
+// object response.
+func (c *Client) object(ctx context.Context, method, path string, q url.Values, payload any) (Object, error) {
+	var out Object
+	_, err := c.doJSON(ctx, method, path, q, payload, &out)
+	return out, err
+}
+
+// multipartFile encodes a file as the "file" field of a multipart form.
+func multipartFile(fileName string, content []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, "", err
+	}
+	if err := form.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), form.FormDataContentType(), nil
+}
+
+// itoa formats a positive count, or returns "" to leave the parameter out.
+func itoa(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
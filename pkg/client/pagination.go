@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+)
+
+// AllActivity returns every activity event of a kind (every kind if empty),
+// newest first, fetching pages of pageSize (0 for the server's default) as
+// they're consumed. Iteration stops at the first error, which is yielded.
+func (c *Client) AllActivity(ctx context.Context, kind string, pageSize int) iter.Seq2[ActivityEvent, error] {
+	return func(yield func(ActivityEvent, error) bool) {
+		q := ActivityQuery{Kind: kind, Limit: pageSize}
+		for {
+			page, err := c.ListActivity(ctx, q)
+			if err != nil {
+				yield(ActivityEvent{}, err)
+				return
+			}
+			for _, event := range page.Events {
+				if !yield(event, nil) {
+					return
+				}
+			}
+			if page.Cursor == nil || *page.Cursor == "" {
+				return
+			}
+			q.Cursor = *page.Cursor
+		}
+	}
+}
+
+// AllTransactions returns the transactions of every month from fromMonth to
+// toMonth (YYYY-MM, inclusive), oldest month first, fetching a month at a
+// time as they're consumed. filter and sort apply within each month.
+// Iteration stops at the first error, which is yielded.
+func (c *Client) AllTransactions(ctx context.Context, fromMonth, toMonth, filter, sort string) iter.Seq2[Transaction, error] {
+	return func(yield func(Transaction, error) bool) {
+		months, err := monthRange(fromMonth, toMonth)
+		if err != nil {
+			yield(Transaction{}, err)
+			return
+		}
+		for _, month := range months {
+			page, err := c.ListTransactions(ctx, TransactionQuery{Month: month, Filter: filter, Sort: sort})
+			if err != nil {
+				yield(Transaction{}, err)
+				return
+			}
+			for _, t := range page.Transactions {
+				if !yield(t, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// monthRange lists the months from one YYYY-MM month to another, inclusive.
+func monthRange(fromMonth, toMonth string) ([]string, error) {
+	start, err := time.Parse("2006-01", fromMonth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid month %q: %w", fromMonth, err)
+	}
+	end, err := time.Parse("2006-01", toMonth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid month %q: %w", toMonth, err)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("month %s is before %s", toMonth, fromMonth)
+	}
+	var months []string
+	for m := start; !m.After(end); m = m.AddDate(0, 1, 0) {
+		months = append(months, m.Format("2006-01"))
+	}
+	return months, nil
+}
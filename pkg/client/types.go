@@ -0,0 +1,148 @@
+package client
+
+// Object is a JSON object whose shape this package doesn't model in detail.
+type Object = map[string]any
+
+// UploadResult is the answer to an upload, which is processed asynchronously.
+type UploadResult struct {
+	BlobName string `json:"blobName"`
+	Status   string `json:"status"`
+}
+
+// Upload is an upload's processing status: queued, processing, completed or
+// failed, with its row counts and errors.
+type Upload struct {
+	BlobName     string   `json:"blobName"`
+	Format       *string  `json:"format"`
+	UploadedBy   *string  `json:"uploadedBy"`
+	Status       string   `json:"status"`
+	RowsImported *int     `json:"rowsImported"`
+	RowsSkipped  *int     `json:"rowsSkipped"`
+	Errors       []string `json:"errors"`
+	QueuedAt     *string  `json:"queuedAt"`
+	UpdatedAt    string   `json:"updatedAt"`
+	Provenance   Object   `json:"provenance"`
+}
+
+// Transaction is a stored transaction. ID is set on transactions read back
+// from the API.
+type Transaction struct {
+	ID             string   `json:"id,omitempty"`
+	Date           string   `json:"date"`
+	Name           string   `json:"name"`
+	AccountNumber  int      `json:"accountNumber"`
+	Amount         float64  `json:"amount"`
+	Category       string   `json:"category"`
+	Ignore         string   `json:"ignore"`
+	Owner          *string  `json:"owner"`
+	Currency       *string  `json:"currency"`
+	OriginalAmount *float64 `json:"originalAmount"`
+}
+
+// TransactionPage is a month's transactions, narrowed by a filter and sorted.
+type TransactionPage struct {
+	Month        string        `json:"month"`
+	Filter       *string       `json:"filter"`
+	Sort         string        `json:"sort"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// TransactionQuery narrows a month's transactions. Empty fields are left out:
+// the current month, every transaction, newest first.
+type TransactionQuery struct {
+	Month  string
+	Filter string
+	Sort   string
+}
+
+// TransactionChanges corrects a stored transaction. Nil fields are unchanged.
+type TransactionChanges struct {
+	Name          *string  `json:"name,omitempty"`
+	Amount        *float64 `json:"amount,omitempty"`
+	AccountNumber *int     `json:"accountNumber,omitempty"`
+	Category      *string  `json:"category,omitempty"`
+	Ignore        *string  `json:"ignore,omitempty"`
+}
+
+// SavingsItem is a planned expense in a savings month.
+type SavingsItem struct {
+	Name   string  `json:"name"`
+	Cost   float64 `json:"cost"`
+	OneOff bool    `json:"oneOff,omitempty"`
+}
+
+// Savings is a month's savings calculation.
+type Savings struct {
+	StartingBalance float64       `json:"startingBalance"`
+	Items           []SavingsItem `json:"items"`
+}
+
+// ActivityEvent is an entry in the household's activity feed.
+type ActivityEvent struct {
+	ID         string  `json:"id"`
+	Kind       string  `json:"kind"`
+	Summary    string  `json:"summary"`
+	Actor      *string `json:"actor"`
+	Details    Object  `json:"details"`
+	OccurredAt string  `json:"occurredAt"`
+}
+
+// ActivityPage is a page of the activity feed. Cursor is nil on the last page.
+type ActivityPage struct {
+	Events []ActivityEvent `json:"events"`
+	Cursor *string         `json:"cursor"`
+}
+
+// ActivityQuery selects a page of the activity feed.
+type ActivityQuery struct {
+	Kind   string
+	Limit  int
+	Cursor string
+}
+
+// Card is one of the caller's synced cards with its utilization.
+type Card struct {
+	AccountID           string             `json:"accountId"`
+	Name                *string            `json:"name"`
+	Institution         *string            `json:"institution"`
+	Mask                *string            `json:"mask"`
+	Balance             *float64           `json:"balance"`
+	Limit               *float64           `json:"limit"`
+	DueDay              *int               `json:"dueDay"`
+	StatementCloseDay   *int               `json:"statementCloseDay"`
+	StatementBalance    *float64           `json:"statementBalance"`
+	LastStatementDate   *string            `json:"lastStatementDate"`
+	AnnualFee           *float64           `json:"annualFee"`
+	APR                 *float64           `json:"apr"`
+	PromoAPR            *float64           `json:"promoApr"`
+	PromoExpiry         *string            `json:"promoExpiry"`
+	RewardRate          *float64           `json:"rewardRate"`
+	MinimumPayment      *float64           `json:"minimumPayment"`
+	CategoryRewardRates map[string]float64 `json:"categoryRewardRates"`
+	Utilization         *float64           `json:"utilization"`
+}
+
+// Utilization is the caller's balance against the limit across their cards.
+type Utilization struct {
+	Balance       float64  `json:"balance"`
+	Limit         float64  `json:"limit"`
+	Ratio         *float64 `json:"ratio"`
+	AlertAbove    float64  `json:"alertAbove"`
+	OverThreshold bool     `json:"overThreshold"`
+}
+
+// Cards lists the caller's cards with their aggregate utilization.
+type Cards struct {
+	Cards       []Card      `json:"cards"`
+	Utilization Utilization `json:"utilization"`
+}
+
+// Person is a household member.
+type Person struct {
+	Name           string             `json:"name"`
+	Email          string             `json:"email"`
+	Accounts       []int              `json:"accounts"`
+	Weights        map[string]float64 `json:"weights,omitempty"`
+	SplitShare     *float64           `json:"splitShare,omitempty"`
+	CategoryShares map[string]float64 `json:"categoryShares,omitempty"`
+}
@@ -2,16 +2,27 @@
 Azure Function App entry point for RMAnalyzer.
 """
 
+import logging
+from typing import Callable
+
 import azure.functions as func
-from rmanalyzer import controller
+from azurefunctions.extensions.http.fastapi import Request, Response, StreamingResponse
+from rmanalyzer import controller, middleware
+from rmanalyzer.logging_config import configure_logging
+
+configure_logging()
 
 app = func.FunctionApp()
 
 
 @app.route(route="upload", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
 def upload(req: func.HttpRequest) -> func.HttpResponse:
     """
-    Receives a CSV, uploads to Blob, enqueues message, returns 202.
+    Receives a CSV, OFX/QFX or XLSX statement, uploads to Blob, enqueues message, returns 202
+    with the blob name to poll /api/uploads/{blobName} with.
 
     Security Note:
     auth_level=ANONYMOUS is used because the function relies on Azure App Service Authentication
@@ -24,14 +35,1145 @@ def upload(req: func.HttpRequest) -> func.HttpResponse:
 @app.queue_trigger(
     arg_name="msg", queue_name="%QUEUE_NAME%", connection="StorageConnection"
 )
+@middleware.queue_recovery
 def process_upload_queue(msg: func.QueueMessage) -> None:
     """Processes a queued upload message."""
     controller.controller.process_queue_item(msg)
 
 
+@app.queue_trigger(
+    arg_name="msg", queue_name="%BACKFILL_QUEUE_NAME%", connection="StorageConnection"
+)
+@middleware.queue_recovery
+def process_backfill_queue(msg: func.QueueMessage) -> None:
+    """Processes a queued backfill (bulk import or rule re-apply) message."""
+    controller.controller.process_queue_item(msg)
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 */5 * * * *")
+@middleware.scheduled
+def scan_inbox(timer: func.TimerRequest) -> None:
+    """Queues statements dropped in the inbox blob folder every 5 minutes."""
+    if timer.past_due:
+        logging.warning("Inbox scan timer is past due.")
+    controller.controller.run_inbox_scan()
+
+
+@app.route(route="uploads", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def uploads(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists recent uploads with their processing status."""
+    return controller.controller.handle_uploads(req)
+
+
+@app.route(
+    route="uploads/{blobName}", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def upload_status(req: func.HttpRequest) -> func.HttpResponse:
+    """Returns an upload's processing status, for polling after an upload."""
+    return controller.controller.handle_upload_status(req)
+
+
+@app.route(
+    route="uploads/{blobName}/reprocess",
+    methods=["POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def upload_reprocess(req: func.HttpRequest) -> func.HttpResponse:
+    """Re-categorizes an upload's stored transactions from its original file."""
+    return controller.controller.handle_upload_reprocess(req)
+
+
+@app.route(
+    route="documents", methods=["GET", "POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def documents(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists a month's archived documents, or files a new one against a month."""
+    return controller.controller.handle_documents(req)
+
+
+@app.route(
+    route="documents/{id}",
+    methods=["GET", "DELETE"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def document(req: func.HttpRequest) -> func.HttpResponse:
+    """Returns an archived document with a fresh download link, or deletes it."""
+    return controller.controller.handle_document(req)
+
+
+@app.route(route="failures", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def failures(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists uploads that failed processing."""
+    return controller.controller.handle_failures(req)
+
+
+@app.route(
+    route="failures/{id}/retry", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def retry_failure(req: func.HttpRequest) -> func.HttpResponse:
+    """Re-enqueues a failed upload for processing."""
+    return controller.controller.handle_failure_retry(req)
+
+
+@app.route(route="alerts", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def alerts(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists the alerts sent to the caller with their snooze or dismiss state."""
+    return controller.controller.handle_alerts(req)
+
+
+@app.route(
+    route="alerts/{id}", methods=["PATCH"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging(log_body=True)
+@middleware.household()
+@middleware.http_recovery
+def alert(req: func.HttpRequest) -> func.HttpResponse:
+    """Snoozes, dismisses or reopens an alert."""
+    return controller.controller.handle_alert(req)
+
+
 @app.route(
     route="savings", methods=["GET", "POST"], auth_level=func.AuthLevel.ANONYMOUS
 )
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version()
+@middleware.http_recovery
 def handle_savings(req: func.HttpRequest) -> func.HttpResponse:
     """Handles getting and updating savings calculation data."""
     return controller.controller.handle_savings_dbrequest(req)
+
+
+@app.route(
+    route="savings/roundups",
+    methods=["GET", "POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version()
+@middleware.http_recovery
+def savings_round_ups(req: func.HttpRequest) -> func.HttpResponse:
+    """Suggests or records a savings contribution from purchase round-ups."""
+    return controller.controller.handle_round_ups(req)
+
+
+@app.route(
+    route="savings/suggestions/{id}/approve",
+    methods=["POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def savings_suggestion_approve(req: func.HttpRequest) -> func.HttpResponse:
+    """Records a suggested transfer to savings, confirmed from its email link."""
+    return controller.controller.handle_savings_suggestion_approve(req)
+
+
+@app.route(
+    route="savings/copy", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def savings_copy(req: func.HttpRequest) -> func.HttpResponse:
+    """Starts a month's savings from an earlier month's items and ending balance."""
+    return controller.controller.handle_savings_copy(req)
+
+
+@app.route(route="savings/export", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def savings_export(req: func.HttpRequest) -> func.HttpResponse:
+    """Exports a savings month as CSV."""
+    return controller.controller.handle_savings_export(req)
+
+
+@app.route(route="budget/export", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def budget_export(req: func.HttpRequest) -> func.HttpResponse:
+    """Exports the monthly budget status for a year as CSV."""
+    return controller.controller.handle_budget_export(req)
+
+
+@app.route(
+    route="reports/compare", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version(amount_fields=("p1", "p2"))
+@middleware.http_recovery
+def compare_report(req: func.HttpRequest) -> func.HttpResponse:
+    """Compares two people's spending by category for a month."""
+    return controller.controller.handle_compare_report(req)
+
+
+@app.route(
+    route="reports/trend", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version(amount_fields=("categories",))
+@middleware.http_recovery
+def trend_report(req: func.HttpRequest) -> func.HttpResponse:
+    """Returns monthly spend over a range, optionally adjusted for inflation."""
+    return controller.controller.handle_trend_report(req)
+
+
+@app.route(
+    route="reports/diff", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version(amount_fields=("a", "b"))
+@middleware.http_recovery
+def diff_report(req: func.HttpRequest) -> func.HttpResponse:
+    """Explains what changed in spending between two months."""
+    return controller.controller.handle_diff_report(req)
+
+
+@app.route(
+    route="reports/review", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version(amount_fields=("typicalAmount",))
+@middleware.http_recovery
+def review_packet(req: func.HttpRequest) -> func.HttpResponse:
+    """Returns a month's review packet, as JSON or a printable PDF."""
+    return controller.controller.handle_review_packet(req)
+
+
+@app.route(route="activity", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def activity(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists recent household activity, newest first."""
+    return controller.controller.handle_activity(req)
+
+
+@app.route(route="summary", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version()
+@middleware.http_recovery
+def summary(req: func.HttpRequest) -> func.HttpResponse:
+    """Returns a month's spend per category, account and person."""
+    return controller.controller.handle_summary(req)
+
+
+@app.route(route="debt", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version(amount_fields=("p1", "p2"))
+@middleware.http_recovery
+def debt(req: func.HttpRequest) -> func.HttpResponse:
+    """Returns who owes whom for a month, with a per-category breakdown."""
+    return controller.controller.handle_debt(req)
+
+
+@app.route(route="debts/history", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version()
+@middleware.http_recovery
+def debt_history(req: func.HttpRequest) -> func.HttpResponse:
+    """Returns the debt ledger with the running balance between partners."""
+    return controller.controller.handle_debt_history(req)
+
+
+@app.route(route="debts/settle", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def debt_settle(req: func.HttpRequest) -> func.HttpResponse:
+    """Records a settlement against a month's debt or the outstanding balance."""
+    return controller.controller.handle_debt_settle(req)
+
+
+@app.route(
+    route="settings", methods=["GET", "PUT"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging(log_body=True)
+@middleware.household()
+@middleware.http_recovery
+def handle_settings(req: func.HttpRequest) -> func.HttpResponse:
+    """Gets or updates household settings such as debt-excluded categories."""
+    return controller.controller.handle_settings(req)
+
+
+@app.route(
+    route="categories",
+    methods=["GET", "POST", "PUT", "DELETE"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging(log_body=True)
+@middleware.household()
+@middleware.http_recovery
+def handle_categories(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists, adds, updates or deletes categories."""
+    return controller.controller.handle_categories(req)
+
+
+@app.route(
+    route="budgets",
+    methods=["GET", "PUT", "DELETE"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def budgets(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists, sets or removes monthly category budgets."""
+    return controller.controller.handle_budgets(req)
+
+
+@app.route(route="budgets/status", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version()
+@middleware.http_recovery
+def budget_status(req: func.HttpRequest) -> func.HttpResponse:
+    """Compares category budgets with a month's spend."""
+    return controller.controller.handle_budget_status(req)
+
+
+@app.route(route="transactions", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version(amount_fields=("originalAmount",))
+@middleware.http_recovery
+def transactions(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists a month's stored transactions, optionally narrowed by a filter."""
+    return controller.controller.handle_transactions(req)
+
+
+def _stream_export(handler: Callable, req: Request) -> Response:
+    """
+    Runs an export handler and streams the download it returns.
+
+    Note: func.HttpResponse can't stream, so exports use the FastAPI HTTP
+    extension, wrapped in http_streaming rather than the response middleware.
+    """
+    result = middleware.http_streaming(handler)(
+        func.HttpRequest(
+            method=req.method,
+            url=str(req.url),
+            headers=dict(req.headers),
+            params=dict(req.query_params),
+            body=b"",
+        )
+    )
+    if isinstance(result, func.HttpResponse):
+        return Response(
+            result.get_body(),
+            status_code=result.status_code,
+            media_type=result.mimetype,
+        )
+    return StreamingResponse(
+        result.chunks,
+        media_type=result.media_type,
+        headers={"Content-Disposition": f'attachment; filename="{result.file_name}"'},
+    )
+
+
+@app.route(
+    route="transactions/export", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+async def transactions_export(req: Request) -> Response:
+    """Streams stored transactions over a range of months as CSV, NDJSON or XLSX."""
+    return _stream_export(controller.controller.handle_transactions_export, req)
+
+
+@app.route(route="export", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+async def export(req: Request) -> Response:
+    """Streams a month's transactions as CSV or XLSX in the upload format."""
+    return _stream_export(controller.controller.handle_export, req)
+
+
+@app.route(
+    route="transactions/search", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version(amount_fields=("originalAmount",))
+@middleware.http_recovery
+def transactions_search(req: func.HttpRequest) -> func.HttpResponse:
+    """Finds stored transactions by words in their description."""
+    return controller.controller.handle_transactions_search(req)
+
+
+@app.route(
+    route="transactions/bulk-edit",
+    methods=["POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def transactions_bulk_edit(req: func.HttpRequest) -> func.HttpResponse:
+    """Changes the category, ignore flag or owner of many transactions at once."""
+    return controller.controller.handle_transactions_bulk_edit(req)
+
+
+@app.route(
+    route="undo/{operationId}",
+    methods=["POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def undo(req: func.HttpRequest) -> func.HttpResponse:
+    """Reverts a recent bulk edit or delete."""
+    return controller.controller.handle_undo(req)
+
+
+@app.route(
+    route="transactions/{id}",
+    methods=["PUT", "DELETE"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def handle_transaction(req: func.HttpRequest) -> func.HttpResponse:
+    """Corrects or deletes a stored transaction."""
+    return controller.controller.handle_transaction(req)
+
+
+@app.route(route="rules", methods=["GET", "POST"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging(log_body=True)
+@middleware.household()
+@middleware.http_recovery
+def rules(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists or adds category rules applied to uploaded transactions."""
+    return controller.controller.handle_rules(req)
+
+
+@app.route(route="rules/apply", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging(log_body=True)
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def rules_apply(req: func.HttpRequest) -> func.HttpResponse:
+    """Re-applies category rules to stored transactions in the given months."""
+    return controller.controller.handle_rules_apply(req)
+
+
+@app.route(
+    route="rules/{id}",
+    methods=["PUT", "DELETE"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging(log_body=True)
+@middleware.household()
+@middleware.http_recovery
+def rule(req: func.HttpRequest) -> func.HttpResponse:
+    """Replaces or deletes a category rule."""
+    return controller.controller.handle_rule(req)
+
+
+@app.route(route="views", methods=["GET", "POST"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging(log_body=True)
+@middleware.household()
+@middleware.http_recovery
+def views(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists or saves the caller's transaction views."""
+    return controller.controller.handle_views(req)
+
+
+@app.route(
+    route="views/{id}",
+    methods=["PUT", "DELETE"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging(log_body=True)
+@middleware.household()
+@middleware.http_recovery
+def view(req: func.HttpRequest) -> func.HttpResponse:
+    """Replaces or deletes one of the caller's transaction views."""
+    return controller.controller.handle_view(req)
+
+
+@app.route(
+    route="transactions/{id}/owner",
+    methods=["PATCH"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def transaction_owner(req: func.HttpRequest) -> func.HttpResponse:
+    """Reassigns a transaction to another person for debt and report purposes."""
+    return controller.controller.handle_transaction_owner(req)
+
+
+@app.route(
+    route="accounts/unassigned", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version()
+@middleware.http_recovery
+def unassigned_accounts(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists account numbers in a month's transactions that belong to no person."""
+    return controller.controller.handle_unassigned_accounts(req)
+
+
+@app.route(
+    route="accounts/assign", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def assign_account(req: func.HttpRequest) -> func.HttpResponse:
+    """Maps an account number to a person for future imports. Admins only."""
+    return controller.controller.handle_assign_account(req)
+
+
+@app.route(
+    route="accounts/sync", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def account_sync(req: func.HttpRequest) -> func.HttpResponse:
+    """Stores the caller's accounts from an external account-sync payload."""
+    return controller.controller.handle_account_sync(req)
+
+
+@app.route(
+    route="accounts/{accountId}/reconcile",
+    methods=["POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def account_reconcile(req: func.HttpRequest) -> func.HttpResponse:
+    """Reconciles one of the caller's synced accounts against a statement balance."""
+    return controller.controller.handle_account_reconcile(req)
+
+
+@app.route(
+    route="cards/{accountId}/reconcile",
+    methods=["POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def card_reconcile(req: func.HttpRequest) -> func.HttpResponse:
+    """Reconciles one of the caller's cards against a statement balance."""
+    return controller.controller.handle_account_reconcile(req)
+
+
+@app.route(
+    route="cards/{accountId}/reconciliations",
+    methods=["GET"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version(amount_fields=("expectedBalance", "discrepancy"))
+@middleware.http_recovery
+def card_reconciliations(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists a card's reconcile attempts, newest first."""
+    return controller.controller.handle_reconciliations(req)
+
+
+@app.route(
+    route="people", methods=["GET", "POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging(log_body=True)
+@middleware.household()
+@middleware.http_recovery
+def people(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists household members or adds/updates one."""
+    return controller.controller.handle_people(req)
+
+
+@app.route(route="invites", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging(log_body=True)
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def invites(req: func.HttpRequest) -> func.HttpResponse:
+    """Emails a new household member an invite link. Admins only."""
+    return controller.controller.handle_invites(req)
+
+
+@app.route(
+    route="invites/accept", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def invite_accept(req: func.HttpRequest) -> func.HttpResponse:
+    """Adds the signed-in caller to the household from an invite link."""
+    return controller.controller.handle_invite_accept(req)
+
+
+@app.route(
+    route="shares", methods=["GET", "POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def shares(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists the caller's report share links, or creates one."""
+    return controller.controller.handle_shares(req)
+
+
+@app.route(
+    route="shares/{id}", methods=["DELETE"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def share(req: func.HttpRequest) -> func.HttpResponse:
+    """Revokes one of the caller's report share links."""
+    return controller.controller.handle_share(req)
+
+
+@app.route(route="shared", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def shared_report(req: func.HttpRequest) -> func.HttpResponse:
+    """Opens a shared report read-only, without signing in."""
+    return controller.controller.handle_shared_report(req)
+
+
+@app.route(
+    route="people/{id}", methods=["DELETE"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def delete_person(req: func.HttpRequest) -> func.HttpResponse:
+    """Removes a household member."""
+    return controller.controller.handle_people(req)
+
+
+@app.route(
+    route="people/{id}/accounts",
+    methods=["GET", "POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def person_accounts(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists a member's accounts or associates one with them (admins only)."""
+    return controller.controller.handle_person_accounts(req)
+
+
+@app.route(
+    route="people/{id}/accounts/{account}",
+    methods=["DELETE"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def delete_person_account(req: func.HttpRequest) -> func.HttpResponse:
+    """Removes an account from a member. Admins only."""
+    return controller.controller.handle_person_accounts(req)
+
+
+@app.route(
+    route="manage/purge", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def admin_purge(req: func.HttpRequest) -> func.HttpResponse:
+    """
+    Deletes all household data (dry run by default). Restricted to ADMIN_EMAILS.
+
+    Note: the Functions host reserves routes beginning with 'admin', hence 'manage/'.
+    """
+    return controller.controller.handle_admin_purge(req)
+
+
+@app.route(
+    route="manage/retention", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def retention_preview(req: func.HttpRequest) -> func.HttpResponse:
+    """Previews what the next retention run will delete. Restricted to ADMIN_EMAILS."""
+    return controller.controller.handle_retention_preview(req)
+
+
+@app.route(
+    route="manage/bootstrap", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def admin_bootstrap(req: func.HttpRequest) -> func.HttpResponse:
+    """Provisions storage, default settings and the first API key. Idempotent."""
+    return controller.controller.handle_admin_bootstrap(req)
+
+
+@app.route(
+    route="manage/sandbox/seed", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def sandbox_seed(req: func.HttpRequest) -> func.HttpResponse:
+    """Resets the sandbox household to fresh demo data (admin only)."""
+    return controller.controller.handle_sandbox_seed(req)
+
+
+@app.route(
+    route="manage/seed-demo", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def demo_seed(req: func.HttpRequest) -> func.HttpResponse:
+    """Fills an empty live household with demo data (admin only)."""
+    return controller.controller.handle_demo_seed(req)
+
+
+@app.route(
+    route="manage/backup/verify",
+    methods=["POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def backup_verify(req: func.HttpRequest) -> func.HttpResponse:
+    """
+    Checks the latest backup's manifest and that its tables can be restored.
+    Restricted to ADMIN_EMAILS.
+    """
+    return controller.controller.handle_backup_verify(req)
+
+
+@app.route(
+    route="manage/backup/rehearse",
+    methods=["POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def backup_rehearsal(req: func.HttpRequest) -> func.HttpResponse:
+    """
+    Queues restoring the latest backup into shadow tables and checking it.
+    Restricted to ADMIN_EMAILS.
+    """
+    return controller.controller.handle_backup_rehearsal(req)
+
+
+@app.route(
+    route="manage/backup/rehearsals",
+    methods=["GET"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def backup_rehearsals(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists recent restore rehearsals and their status. Restricted to ADMIN_EMAILS."""
+    return controller.controller.handle_backup_rehearsals(req)
+
+
+@app.route(
+    route="manage/tables", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def table_routes(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists the table each table name is switched to. Restricted to ADMIN_EMAILS."""
+    return controller.controller.handle_table_routes(req)
+
+
+@app.route(
+    route="manage/tables/switch",
+    methods=["POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging(log_body=True)
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def table_switch(req: func.HttpRequest) -> func.HttpResponse:
+    """Switches table names to other tables together. Restricted to ADMIN_EMAILS."""
+    return controller.controller.handle_table_switch(req)
+
+
+@app.route(
+    route="manage/tables/rollback",
+    methods=["POST"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def table_rollback(req: func.HttpRequest) -> func.HttpResponse:
+    """Undoes the last table switch. Restricted to ADMIN_EMAILS."""
+    return controller.controller.handle_table_rollback(req)
+
+
+@app.route(
+    route="manage/storage-ops", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def storage_ops(req: func.HttpRequest) -> func.HttpResponse:
+    """
+    Table requests per endpoint and their projected monthly cost. Restricted to
+    ADMIN_EMAILS.
+    """
+    return controller.controller.handle_storage_ops(req)
+
+
+@app.route(
+    route="manage/connectors", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def connectors(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists the pull connectors with their last pull. Restricted to ADMIN_EMAILS."""
+    return controller.controller.handle_connectors(req)
+
+
+@app.route(
+    route="manage/connectors/{name}",
+    methods=["PUT", "DELETE"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def connector(req: func.HttpRequest) -> func.HttpResponse:
+    """Creates, replaces or removes a pull connector. Restricted to ADMIN_EMAILS."""
+    return controller.controller.handle_connector(req)
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 */5 * * * *")
+@middleware.scheduled
+def pull_connectors(timer: func.TimerRequest) -> None:
+    """Pulls new statement files from connectors that are due, every 5 minutes."""
+    if timer.past_due:
+        logging.warning("Connector pull timer is past due.")
+    controller.controller.run_connector_pulls()
+
+
+@app.route(
+    route="manage/mailboxes", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def mailboxes(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists mailbox connectors and their last fetch. Restricted to ADMIN_EMAILS."""
+    return controller.controller.handle_mailboxes(req)
+
+
+@app.route(
+    route="manage/mailboxes/{name}",
+    methods=["PUT", "DELETE"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def mailbox(req: func.HttpRequest) -> func.HttpResponse:
+    """Creates, replaces or removes a mailbox connector. Restricted to ADMIN_EMAILS."""
+    return controller.controller.handle_mailbox(req)
+
+
+@app.route(
+    route="manage/mailboxes/{name}/authorize",
+    methods=["GET"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def mailbox_authorize(req: func.HttpRequest) -> func.HttpResponse:
+    """Redirects to the mail provider to grant access to a mailbox."""
+    return controller.controller.handle_mailbox_authorize(req)
+
+
+@app.route(
+    route="manage/mailbox-callback",
+    methods=["GET"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+# Not request-logged: the URL carries the authorization code
+@middleware.http_recovery
+def mailbox_callback(req: func.HttpRequest) -> func.HttpResponse:
+    """Where the mail provider returns after access is granted to a mailbox."""
+    return controller.controller.handle_mailbox_callback(req)
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 */5 * * * *")
+@middleware.scheduled
+def fetch_mailboxes(timer: func.TimerRequest) -> None:
+    """Fetches statement attachments from mailboxes that are due, every 5 minutes."""
+    if timer.past_due:
+        logging.warning("Mailbox fetch timer is past due.")
+    controller.controller.run_mailbox_fetches()
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 15 2 * * *")
+@middleware.scheduled
+def run_backup(timer: func.TimerRequest) -> None:
+    """Backs up every table, encrypted, daily at 02:15 UTC."""
+    if timer.past_due:
+        logging.warning("Backup timer is past due.")
+    controller.controller.run_backup_job()
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 0 7 1 * *")
+@middleware.scheduled
+def run_monthly_report(timer: func.TimerRequest) -> None:
+    """Emails every member last month's report at 07:00 UTC on the 1st."""
+    if timer.past_due:
+        logging.warning("Monthly report timer is past due.")
+    controller.controller.run_monthly_report_job()
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 0 7 2 * *")
+@middleware.scheduled
+def run_review_packet(timer: func.TimerRequest) -> None:
+    """
+    Emails last month's review packet at 07:00 UTC on the 2nd, once the month's
+    last statements are in.
+    """
+    if timer.past_due:
+        logging.warning("Review packet timer is past due.")
+    controller.controller.run_review_packet_job()
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 0 3 * * *")
+@middleware.scheduled
+def run_retention(timer: func.TimerRequest) -> None:
+    """Enforces data retention policies daily at 03:00 UTC."""
+    if timer.past_due:
+        logging.warning("Retention timer is past due.")
+    controller.controller.run_retention_job()
+
+
+@app.route(route="health-score", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def health_score(req: func.HttpRequest) -> func.HttpResponse:
+    """Returns the caller's financial health score with a per-factor breakdown."""
+    return controller.controller.handle_health_score(req)
+
+
+@app.route(
+    route="emergency-fund", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version()
+@middleware.http_recovery
+def emergency_fund(req: func.HttpRequest) -> func.HttpResponse:
+    """Returns the months of essential spending the emergency fund covers."""
+    return controller.controller.handle_emergency_fund(req)
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 30 2 * * *")
+@middleware.scheduled
+def run_health_scores(timer: func.TimerRequest) -> None:
+    """Records every member's financial health score daily at 02:30 UTC."""
+    if timer.past_due:
+        logging.warning("Health score timer is past due.")
+    controller.controller.run_health_score_job()
+
+
+@app.route(route="subscriptions", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version(amount_fields=("from", "to"))
+@middleware.http_recovery
+def subscriptions(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists detected recurring charges with their price change history."""
+    return controller.controller.handle_subscriptions(req)
+
+
+@app.route(
+    route="reminders/{id}/ack", methods=["POST"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def reminder_ack(req: func.HttpRequest) -> func.HttpResponse:
+    """Acknowledges a payment reminder, confirmed from its email link."""
+    return controller.controller.handle_reminder_ack(req)
+
+
+@app.route(route="cards", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version()
+@middleware.http_recovery
+def cards(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists the caller's cards with per-card and aggregate utilization."""
+    return controller.controller.handle_cards(req)
+
+
+@app.route(
+    route="cards/{id}/statements", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version()
+@middleware.http_recovery
+def card_statements(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists a card's closed statements, newest first."""
+    return controller.controller.handle_card_statements(req)
+
+
+@app.route(
+    route="cards/{id}/interest-projection",
+    methods=["GET"],
+    auth_level=func.AuthLevel.ANONYMOUS,
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version(amount_fields=("interest", "payment", "totalInterest"))
+@middleware.http_recovery
+def interest_projection(req: func.HttpRequest) -> func.HttpResponse:
+    """Projects a card's interest if only a set payment is made each month."""
+    return controller.controller.handle_interest_projection(req)
+
+
+@app.route(route="cards/fees", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version()
+@middleware.http_recovery
+def card_fees(req: func.HttpRequest) -> func.HttpResponse:
+    """Compares each synced card's annual fee with a year of spend on it."""
+    return controller.controller.handle_card_fees(req)
+
+
+@app.route(route="cards/rewards", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.api_version()
+@middleware.http_recovery
+def card_rewards(req: func.HttpRequest) -> func.HttpResponse:
+    """Estimates the rewards each synced card earned in a month."""
+    return controller.controller.handle_card_rewards(req)
+
+
+@app.route(route="cards/best", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def best_card(req: func.HttpRequest) -> func.HttpResponse:
+    """Suggests the synced card with the best reward rate for each category."""
+    return controller.controller.handle_best_card(req)
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 45 2 * * *")
+@middleware.scheduled
+def run_recurring_charges(timer: func.TimerRequest) -> None:
+    """
+    Checks recurring charges for missing bills and price increases, and reminds
+    about upcoming card annual fees, at 02:45 UTC.
+    """
+    if timer.past_due:
+        logging.warning("Recurring charge timer is past due.")
+    controller.controller.run_recurring_charge_job()
+
+
+@app.route(
+    route="jobs/nightly/history", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS
+)
+@middleware.http_logging()
+@middleware.household()
+@middleware.http_recovery
+def nightly_job_history(req: func.HttpRequest) -> func.HttpResponse:
+    """Lists the nightly job's recent runs. Restricted to ADMIN_EMAILS."""
+    return controller.controller.handle_nightly_job_history(req)
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 0 6 * * *")
+@middleware.scheduled
+def check_nightly_job(timer: func.TimerRequest) -> None:
+    """Alerts the admins at 06:00 UTC if the nightly job hasn't run in a day."""
+    if timer.past_due:
+        logging.warning("Nightly job check timer is past due.")
+    controller.controller.run_nightly_job_check()
+
+
+@app.route(route="usage", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def household_usage(req: func.HttpRequest) -> func.HttpResponse:
+    """Returns the household's storage and email usage (admin only)."""
+    return controller.controller.handle_usage(req)
+
+
+@app.route(route="manage/queues", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+@middleware.http_logging()
+@middleware.household(sandboxed=False)
+@middleware.http_recovery
+def queues(req: func.HttpRequest) -> func.HttpResponse:
+    """Returns the processing queues' backlog and oldest message age (admin only)."""
+    return controller.controller.handle_queues(req)
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 15 6 * * *")
+@middleware.scheduled
+def check_usage(timer: func.TimerRequest) -> None:
+    """Warns the admins at 06:15 UTC when a soft usage limit is reached."""
+    if timer.past_due:
+        logging.warning("Usage check timer is past due.")
+    controller.controller.run_usage_check()
+
+
+@app.timer_trigger(arg_name="timer", schedule="0 */15 * * * *")
+@middleware.scheduled
+def check_ops_alerts(timer: func.TimerRequest) -> None:
+    """Alerts the admins every 15 minutes when an ops metric reaches its threshold."""
+    if timer.past_due:
+        logging.warning("Ops alert check timer is past due.")
+    controller.controller.run_ops_alert_check()
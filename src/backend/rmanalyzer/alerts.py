@@ -0,0 +1,31 @@
+"""
+Alert lifecycle: each alert the scheduled checks email (a member's high
+utilization, a bill that went missing) has a stable id, so once acknowledged it
+can be snoozed until a date or dismissed rather than sent again.
+"""
+
+import hashlib
+from datetime import date
+from typing import Any, Dict, Optional
+
+__all__ = ["ALERT_STATUSES", "alert_id", "is_suppressed"]
+
+ALERT_STATUSES = ("new", "snoozed", "dismissed")
+
+
+def alert_id(kind: str, key: str) -> str:
+    """
+    The id of the alert of a kind about one thing, e.g. ("missing-bill", "RENT").
+    Keys can contain characters ids in URLs and RowKeys don't allow, so it's a hash.
+    """
+    return hashlib.sha256(f"{kind}:{key}".encode("utf-8")).hexdigest()[:32]
+
+
+def is_suppressed(alert: Optional[Dict[str, Any]], today: date) -> bool:
+    """Whether an alert is dismissed, or snoozed until after today."""
+    if alert is None:
+        return False
+    if alert["status"] == "dismissed":
+        return True
+    until = alert.get("snoozedUntil")
+    return alert["status"] == "snoozed" and bool(until) and until > today.isoformat()
@@ -0,0 +1,116 @@
+"""
+How money amounts are written in API responses, per API version.
+
+Version 1 writes amounts as JSON numbers, which JavaScript reads as binary floats.
+Version 2 writes them as decimal strings with two places ("12.30"), so clients
+can parse them exactly. Requests accept either form in every version.
+"""
+
+from decimal import Decimal
+from typing import Any, Iterable, Mapping, Optional
+
+__all__ = [
+    "API_VERSION_HEADER",
+    "DEFAULT_API_VERSION",
+    "SUPPORTED_API_VERSIONS",
+    "AMOUNT_FIELDS",
+    "requested_version",
+    "amounts_as_strings",
+    "stringify_amounts",
+]
+
+API_VERSION_HEADER = "x-api-version"
+
+# Clients that don't ask for a version keep the numbers they were built against
+DEFAULT_API_VERSION = 1
+SUPPORTED_API_VERSIONS = (1, 2)
+
+# First version to write amounts as strings
+STRING_AMOUNTS_VERSION = 2
+
+# Response fields holding money in every endpoint. Endpoints add their own, e.g.
+# a report's per-person columns.
+AMOUNT_FIELDS = frozenset(
+    {
+        "amount",
+        "annualFee",
+        "averageAmount",
+        "balance",
+        "change",
+        "cost",
+        "debt",
+        "delta",
+        "difference",
+        "fund",
+        "increment",
+        "lastAmount",
+        "limit",
+        "monthlyEssentialSpend",
+        "projectedInterest",
+        "reconciledBalance",
+        "remaining",
+        "rewards",
+        "spend",
+        "spent",
+        "startingBalance",
+        "statementBalance",
+        "total",
+        "unassigned",
+    }
+)
+
+
+def requested_version(headers: Mapping[str, str]) -> Optional[int]:
+    """
+    The API version asked for in the x-api-version header, DEFAULT_API_VERSION
+    when there is none, or None when it isn't a supported version.
+    """
+    raw = (headers.get(API_VERSION_HEADER) or "").strip()
+    if not raw:
+        return DEFAULT_API_VERSION
+    try:
+        version = int(raw)
+    except ValueError:
+        return None
+    return version if version in SUPPORTED_API_VERSIONS else None
+
+
+def amounts_as_strings(version: int) -> bool:
+    """Whether a version writes amounts as decimal strings."""
+    return version >= STRING_AMOUNTS_VERSION
+
+
+def _to_string(value: Any) -> Any:
+    """Writes a number as a two-place decimal string; anything else is unchanged."""
+    if isinstance(value, bool) or not isinstance(value, (int, float)):
+        return value
+    return f"{Decimal(str(value)):.2f}"
+
+
+def _all_amounts(value: Any) -> Any:
+    """Every number under an amount field is an amount, e.g. per-category totals."""
+    if isinstance(value, dict):
+        return {k: _all_amounts(v) for k, v in value.items()}
+    if isinstance(value, list):
+        return [_all_amounts(v) for v in value]
+    return _to_string(value)
+
+
+def stringify_amounts(payload: Any, fields: Iterable[str] = AMOUNT_FIELDS) -> Any:
+    """
+    Returns the payload with the numbers in amount fields written as strings,
+    searching nested objects and arrays. Other numbers (counts, ratios, rates)
+    are left as they are.
+    """
+    fields = frozenset(fields)
+
+    def walk(value: Any) -> Any:
+        if isinstance(value, dict):
+            return {
+                k: _all_amounts(v) if k in fields else walk(v) for k, v in value.items()
+            }
+        if isinstance(value, list):
+            return [walk(v) for v in value]
+        return value
+
+    return walk(payload)
@@ -0,0 +1,133 @@
+"""
+Backups: nightly snapshots of every table, each encrypted with a key from the
+secrets provider and listed with its checksum in a signed manifest, so a
+snapshot can be checked for tampering and restorability before it's needed.
+"""
+
+import base64
+import hashlib
+import hmac
+import json
+import os
+from datetime import datetime
+from typing import Any, Dict
+
+__all__ = [
+    "MANIFEST_NAME",
+    "parse_key",
+    "key_id",
+    "snapshot_id",
+    "table_blob_name",
+    "manifest_blob_name",
+    "checksum",
+    "encrypt",
+    "decrypt",
+    "seal",
+    "unseal",
+    "sign_manifest",
+    "manifest_valid",
+]
+
+# Name a snapshot's manifest is stored under, in the snapshot's folder
+MANIFEST_NAME = "manifest.json"
+
+# AES-256-GCM key and nonce sizes, in bytes
+KEY_SIZE = 32
+NONCE_SIZE = 12
+
+
+def parse_key(value: str) -> bytes:
+    """Decodes a base64 backup key. Raises ValueError unless it is 256 bits."""
+    try:
+        key = base64.b64decode(value, validate=True)
+    except ValueError as e:
+        raise ValueError("Backup key is not valid base64") from e
+    if len(key) != KEY_SIZE:
+        raise ValueError(f"Backup key must be {KEY_SIZE} bytes, not {len(key)}")
+    return key
+
+
+def key_id(key: bytes) -> str:
+    """
+    Identifies a key without revealing it, so a snapshot taken with a key that
+    has since been rotated is reported as such rather than as corrupt.
+    """
+    return hashlib.sha256(b"rm-analyzer backup key id" + key).hexdigest()[:16]
+
+
+def snapshot_id(taken_at: datetime) -> str:
+    """A snapshot's id, which sorts in the order snapshots were taken."""
+    return taken_at.strftime("%Y%m%dT%H%M%S")
+
+
+def table_blob_name(snapshot: str, table_name: str) -> str:
+    """Where a table's encrypted entities are stored in a snapshot."""
+    return f"{snapshot}/{table_name}.json.enc"
+
+
+def manifest_blob_name(snapshot: str) -> str:
+    """Where a snapshot's manifest is stored."""
+    return f"{snapshot}/{MANIFEST_NAME}"
+
+
+def checksum(data: bytes) -> str:
+    """The SHA-256 of stored bytes, as hex."""
+    return hashlib.sha256(data).hexdigest()
+
+
+def _aead(key: bytes) -> Any:
+    """The AES-256-GCM cipher for a key."""
+    # Imported here so the app runs without cryptography until backups are used
+    # pylint: disable-next=import-outside-toplevel
+    from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+
+    return AESGCM(key)
+
+
+def encrypt(key: bytes, blob_name: str, data: bytes) -> bytes:
+    """
+    Encrypts data to be stored under a blob name, as a random nonce followed by
+    the ciphertext. The name is authenticated with it, so blobs can't be swapped.
+    """
+    nonce = os.urandom(NONCE_SIZE)
+    return nonce + _aead(key).encrypt(nonce, data, blob_name.encode("utf-8"))
+
+
+def decrypt(key: bytes, blob_name: str, blob: bytes) -> bytes:
+    """Decrypts a blob stored under a name. Raises if it was altered or moved."""
+    nonce, ciphertext = blob[:NONCE_SIZE], blob[NONCE_SIZE:]
+    return _aead(key).decrypt(nonce, ciphertext, blob_name.encode("utf-8"))
+
+
+def seal(key: bytes, purpose: str, secret: str) -> str:
+    """
+    Encrypts a secret kept in a table, such as a mailbox's refresh token, as
+    base64 text. The purpose it's kept for is authenticated like a blob's name.
+    """
+    return base64.b64encode(encrypt(key, purpose, secret.encode("utf-8"))).decode()
+
+
+def unseal(key: bytes, purpose: str, sealed: str) -> str:
+    """Decrypts a sealed secret. Raises if it was altered or sealed for another use."""
+    return decrypt(key, purpose, base64.b64decode(sealed, validate=True)).decode()
+
+
+def _signature(key: bytes, manifest: Dict[str, Any]) -> str:
+    """An HMAC of a manifest's fields other than its signature."""
+    body = {k: v for k, v in manifest.items() if k != "signature"}
+    mac_key = hmac.new(key, b"rm-analyzer backup manifest", hashlib.sha256).digest()
+    message = json.dumps(body, sort_keys=True, separators=(",", ":"))
+    return hmac.new(mac_key, message.encode("utf-8"), hashlib.sha256).hexdigest()
+
+
+def sign_manifest(key: bytes, manifest: Dict[str, Any]) -> Dict[str, Any]:
+    """The manifest with a signature over its contents."""
+    return {**manifest, "signature": _signature(key, manifest)}
+
+
+def manifest_valid(key: bytes, manifest: Dict[str, Any]) -> bool:
+    """Whether a manifest is signed with the key and unchanged since."""
+    signature = manifest.get("signature")
+    return isinstance(signature, str) and hmac.compare_digest(
+        signature, _signature(key, manifest)
+    )
@@ -0,0 +1,90 @@
+"""
+Category budgets: monthly spending limits compared with actual spend, and what's
+left of them to move to savings.
+"""
+
+import calendar
+from datetime import date
+from decimal import ROUND_DOWN, Decimal
+from typing import Collection, Dict, List
+
+from rmanalyzer.models import AnyCategory, all_categories
+
+__all__ = [
+    "SAVINGS_TRANSFER_ITEM_NAME",
+    "budget_status",
+    "discretionary_leftover",
+    "month_elapsed",
+    "suggested_transfer",
+]
+
+# Name of the savings item an approved transfer suggestion is stored as
+SAVINGS_TRANSFER_ITEM_NAME = "Pay yourself first"
+
+
+def budget_status(
+    limits: Dict[AnyCategory, Decimal], spend: Dict[AnyCategory, Decimal]
+) -> List[Dict[str, object]]:
+    """
+    Compares each budgeted category's monthly limit with its spend, in category
+    order. `percentUsed` is None for a zero limit; `remaining` goes negative once
+    the budget is overspent.
+    """
+    status = []
+    for category in all_categories(c.value for c in limits):
+        if category not in limits:
+            continue
+        limit = limits[category]
+        spent = spend.get(category, Decimal("0.00"))
+        status.append(
+            {
+                "category": category.value,
+                "limit": float(limit),
+                "spent": float(spent),
+                "remaining": float(limit - spent),
+                "percentUsed": round(float(spent / limit * 100), 1) if limit else None,
+                "overBudget": spent > limit,
+            }
+        )
+    return status
+
+
+def discretionary_leftover(
+    limits: Dict[AnyCategory, Decimal],
+    spend: Dict[AnyCategory, Decimal],
+    essential: Collection[AnyCategory],
+    elapsed: Decimal = Decimal("1"),
+) -> Decimal:
+    """
+    What's unspent of the budgets of categories that aren't essential, with
+    overspending in one taken out of what's left of the others. Never negative.
+    Limits are pro-rated by elapsed, the share of the month gone (see
+    month_elapsed), so what's left mid-month isn't most of the budget.
+    """
+    leftover = sum(
+        (
+            limit * elapsed - spend.get(category, Decimal("0.00"))
+            for category, limit in limits.items()
+            if category not in essential
+        ),
+        start=Decimal("0.00"),
+    )
+    return max(leftover, Decimal("0.00"))
+
+
+def month_elapsed(month: str, today: date) -> Decimal:
+    """
+    The share of a month (YYYY-MM) gone by today: all of a past month and none
+    of a later one.
+    """
+    year, number = int(month[:4]), int(month[5:7])
+    if (year, number) < (today.year, today.month):
+        return Decimal("1")
+    if (year, number) > (today.year, today.month):
+        return Decimal("0")
+    return Decimal(today.day) / Decimal(calendar.monthrange(year, number)[1])
+
+
+def suggested_transfer(leftover: Decimal, share: Decimal) -> Decimal:
+    """A share of the leftover budget to move to savings, in whole units."""
+    return (leftover * share).quantize(Decimal("1"), rounding=ROUND_DOWN)
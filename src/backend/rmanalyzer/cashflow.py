@@ -0,0 +1,70 @@
+"""
+Cash flow: income detected from regular deposits, and whether a month's planned
+savings contributions and required card payments fit within it.
+"""
+
+from dataclasses import replace
+from decimal import Decimal
+from typing import Any, Dict, Iterable, List, Optional
+
+from rmanalyzer.interest import minimum_payment
+from rmanalyzer.models import Transaction
+from rmanalyzer.recurring import RecurringCharge, detect_recurring
+
+__all__ = [
+    "detect_income",
+    "monthly_income",
+    "required_payment",
+    "over_allocation",
+]
+
+# Average days in a month, to turn a deposit's cadence into a monthly amount
+AVERAGE_MONTH_DAYS = Decimal("365.25") / 12
+
+
+def detect_income(
+    transactions: List[Transaction], card_accounts: Iterable[int]
+) -> List[RecurringCharge]:
+    """
+    Deposits made at a regular cadence (pay, benefits), found the way recurring
+    charges are. Credits on cards are payments or refunds, not income, so they're
+    left out.
+    """
+    cards = set(card_accounts)
+    return detect_recurring(
+        [
+            replace(t, amount=-t.amount)
+            for t in transactions
+            if t.amount < 0 and t.account_number not in cards
+        ]
+    )
+
+
+def monthly_income(streams: List[RecurringCharge]) -> Decimal:
+    """What the income streams deposit in an average month, to the cent."""
+    total = sum(
+        (s.average_amount * AVERAGE_MONTH_DAYS / s.cadence_days for s in streams),
+        start=Decimal("0.00"),
+    )
+    return total.quantize(Decimal("0.01"))
+
+
+def required_payment(card: Dict[str, Any]) -> Decimal:
+    """
+    The least that must be paid on a card this month: its minimum payment as the
+    issuer reports it, or else estimated from the balance.
+    """
+    balance = Decimal(str(card.get("balance") or 0))
+    if balance <= 0:
+        return Decimal("0.00")
+    if card.get("minimumPayment") is not None:
+        return min(Decimal(str(card["minimumPayment"])), balance)
+    return minimum_payment(balance, Decimal(str(card.get("apr") or 0)))
+
+
+def over_allocation(
+    income: Decimal, contributions: Decimal, card_payments: Decimal
+) -> Optional[Decimal]:
+    """How far contributions and card payments exceed income, if they do."""
+    shortfall = contributions + card_payments - income
+    return shortfall if shortfall > 0 else None
@@ -0,0 +1,296 @@
+"""
+Pull connectors: SFTP or FTPS drops an institution delivers statement files to,
+which are checked on a schedule for new files to import.
+"""
+
+import base64
+import contextlib
+import ftplib
+import hashlib
+import io
+import ssl
+import stat
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Callable, ContextManager, Dict, Iterator, List, Optional, Protocol
+
+__all__ = [
+    "Connector",
+    "RemoteFile",
+    "RemoteSession",
+    "PROTOCOLS",
+    "DEFAULT_PORTS",
+    "open_session",
+    "file_key",
+]
+
+# Default ports per protocol. FTPS here is explicit TLS (AUTH TLS on the FTP port).
+DEFAULT_PORTS = {"sftp": 22, "ftps": 21}
+
+# Minutes between pulls, unless a connector sets its own
+DEFAULT_INTERVAL_MINUTES = 60
+
+# Seconds to wait on the remote server
+TIMEOUT = 30
+
+
+@dataclass(frozen=True)
+class Connector:
+    """
+    Where and how to pull statement files from. secret_name names the app
+    setting holding the password (FTPS) or password or private key (SFTP), so
+    the secret itself is never stored or returned. host_key pins an SFTP
+    server's public key ("ssh-ed25519 AAAA..."), which is verified on connect.
+    """
+
+    name: str
+    protocol: str
+    host: str
+    username: str
+    secret_name: str
+    path: str = "/"
+    port: Optional[int] = None
+    host_key: Optional[str] = None
+    interval_minutes: int = DEFAULT_INTERVAL_MINUTES
+
+    @property
+    def effective_port(self) -> int:
+        """The configured port, or the protocol's default."""
+        return self.port or DEFAULT_PORTS[self.protocol]
+
+    @classmethod
+    def from_json(cls, name: str, data: Dict[str, object]) -> "Connector":
+        """Create a connector from a validated API request body."""
+        port = data.get("port")
+        return cls(
+            name=name,
+            protocol=str(data["protocol"]),
+            host=str(data["host"]),
+            username=str(data["username"]),
+            secret_name=str(data["secretName"]),
+            path=str(data.get("path") or "/"),
+            port=None if port is None else int(port),
+            host_key=data.get("hostKey") or None,
+            interval_minutes=int(
+                data.get("intervalMinutes") or DEFAULT_INTERVAL_MINUTES
+            ),
+        )
+
+    def to_json(self) -> Dict[str, object]:
+        """Serialize the connector for the API."""
+        return {
+            "name": self.name,
+            "protocol": self.protocol,
+            "host": self.host,
+            "port": self.effective_port,
+            "username": self.username,
+            "secretName": self.secret_name,
+            "path": self.path,
+            "hostKey": self.host_key,
+            "intervalMinutes": self.interval_minutes,
+        }
+
+    @classmethod
+    def from_entity(cls, entity: Dict[str, object]) -> "Connector":
+        """Create a connector from a connectors table entity."""
+        port = entity.get("Port")
+        return cls(
+            name=str(entity["RowKey"]),
+            protocol=str(entity["Protocol"]),
+            host=str(entity["Host"]),
+            username=str(entity["Username"]),
+            secret_name=str(entity["SecretName"]),
+            path=str(entity.get("Path") or "/"),
+            port=None if port is None else int(port),
+            host_key=entity.get("HostKey") or None,
+            interval_minutes=int(
+                entity.get("IntervalMinutes") or DEFAULT_INTERVAL_MINUTES
+            ),
+        )
+
+    def to_entity(self, partition_key: str) -> Dict[str, object]:
+        """Serialize the connector for the connectors table, omitting unset fields."""
+        entity: Dict[str, object] = {
+            "PartitionKey": partition_key,
+            "RowKey": self.name,
+            "Protocol": self.protocol,
+            "Host": self.host,
+            "Username": self.username,
+            "SecretName": self.secret_name,
+            "Path": self.path,
+            "IntervalMinutes": self.interval_minutes,
+        }
+        optional = {"Port": self.port, "HostKey": self.host_key}
+        entity.update({k: v for k, v in optional.items() if v is not None})
+        return entity
+
+
+@dataclass(frozen=True)
+class RemoteFile:
+    """A file in a connector's folder. modified is an ISO timestamp if known."""
+
+    path: str
+    size: int
+    modified: Optional[str] = None
+
+
+def file_key(remote: RemoteFile) -> str:
+    """
+    Identifies a delivery of a file, so it's pulled once. A file replaced with
+    new content (a different size or time) counts as a new delivery.
+    """
+    delivery = f"{remote.path}|{remote.size}|{remote.modified or ''}"
+    return hashlib.sha256(delivery.encode("utf-8")).hexdigest()
+
+
+class RemoteSession(Protocol):
+    """An open connection to a connector's server."""
+
+    def list_files(self) -> List[RemoteFile]:
+        """The regular files in the connector's folder."""
+
+    def read(self, path: str) -> bytes:
+        """A file's content."""
+
+
+def _join(folder: str, name: str) -> str:
+    """A file's path in a folder."""
+    return f"{folder.rstrip('/')}/{name}"
+
+
+class _FtpsSession:
+    """FTPS with explicit TLS, with the data channel encrypted as well."""
+
+    def __init__(self, ftp: ftplib.FTP_TLS, folder: str) -> None:
+        self._ftp = ftp
+        self._folder = folder
+
+    def list_files(self) -> List[RemoteFile]:
+        """The regular files in the folder, from an MLSD listing."""
+        files = []
+        listing = self._ftp.mlsd(self._folder, facts=["type", "size", "modify"])
+        for name, facts in listing:
+            if facts.get("type") != "file":
+                continue
+            modify = facts.get("modify")
+            files.append(
+                RemoteFile(
+                    _join(self._folder, name),
+                    int(facts.get("size") or 0),
+                    (
+                        datetime.strptime(modify[:14], "%Y%m%d%H%M%S").isoformat()
+                        if modify
+                        else None
+                    ),
+                )
+            )
+        return files
+
+    def read(self, path: str) -> bytes:
+        """Downloads a file in binary mode."""
+        buffer = io.BytesIO()
+        self._ftp.retrbinary(f"RETR {path}", buffer.write)
+        return buffer.getvalue()
+
+
+@contextlib.contextmanager
+def _open_ftps(connector: Connector, secret: str) -> Iterator[RemoteSession]:
+    """
+    Signs in over FTPS with the secret as the password, once the server's
+    certificate is verified against the system's trusted CAs and its host name.
+    """
+    ftp = ftplib.FTP_TLS(context=ssl.create_default_context(), timeout=TIMEOUT)
+    try:
+        ftp.connect(connector.host, connector.effective_port)
+        ftp.login(connector.username, secret)
+        ftp.prot_p()
+        yield _FtpsSession(ftp, connector.path)
+    finally:
+        ftp.close()
+
+
+class _SftpSession:
+    """SFTP over a host-key-verified SSH connection."""
+
+    def __init__(self, sftp, folder: str) -> None:
+        self._sftp = sftp
+        self._folder = folder
+
+    def list_files(self) -> List[RemoteFile]:
+        """The regular files in the folder; links and subfolders are skipped."""
+        return [
+            RemoteFile(
+                _join(self._folder, attr.filename),
+                attr.st_size or 0,
+                (
+                    datetime.fromtimestamp(attr.st_mtime, timezone.utc).isoformat()
+                    if attr.st_mtime
+                    else None
+                ),
+            )
+            for attr in self._sftp.listdir_attr(self._folder)
+            if attr.st_mode is not None and stat.S_ISREG(attr.st_mode)
+        ]
+
+    def read(self, path: str) -> bytes:
+        """Downloads a file."""
+        with self._sftp.open(path, "rb") as remote:
+            return remote.read()
+
+
+def _private_key(secret: str):
+    """Loads an OpenSSH/PEM private key of any type paramiko supports."""
+    import paramiko  # pylint: disable=import-outside-toplevel
+
+    for key_class in (paramiko.Ed25519Key, paramiko.ECDSAKey, paramiko.RSAKey):
+        try:
+            return key_class.from_private_key(io.StringIO(secret))
+        except paramiko.SSHException:
+            continue
+    raise ValueError("The secret is not a supported private key")
+
+
+@contextlib.contextmanager
+def _open_sftp(connector: Connector, secret: str) -> Iterator[RemoteSession]:
+    """
+    Connects over SSH, refusing servers whose key isn't the pinned host key, and
+    signs in with the secret as a private key if it is one, else as a password.
+    """
+    # Imported here so the app runs without paramiko until SFTP is used
+    import paramiko  # pylint: disable=import-outside-toplevel
+
+    if not connector.host_key:
+        raise ValueError("SFTP connectors need the server's host key")
+
+    transport = paramiko.Transport((connector.host, connector.effective_port))
+    try:
+        transport.banner_timeout = TIMEOUT
+        transport.start_client(timeout=TIMEOUT)
+        key = transport.get_remote_server_key()
+        offered = f"{key.get_name()} {base64.b64encode(key.asbytes()).decode()}"
+        if offered.split() != connector.host_key.split()[:2]:
+            raise ValueError(f"Host key mismatch for {connector.host}")
+
+        if secret.lstrip().startswith("-----BEGIN"):
+            transport.auth_publickey(connector.username, _private_key(secret))
+        else:
+            transport.auth_password(connector.username, secret)
+        sftp = paramiko.SFTPClient.from_transport(transport)
+        try:
+            yield _SftpSession(sftp, connector.path)
+        finally:
+            sftp.close()
+    finally:
+        transport.close()
+
+
+# Session openers by protocol name
+PROTOCOLS: Dict[str, Callable[[Connector, str], ContextManager[RemoteSession]]] = {
+    "sftp": _open_sftp,
+    "ftps": _open_ftps,
+}
+
+
+def open_session(connector: Connector, secret: str) -> ContextManager[RemoteSession]:
+    """Connects and signs in to a connector's server, closing it on exit."""
+    return PROTOCOLS[connector.protocol](connector, secret)
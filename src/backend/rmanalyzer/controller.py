@@ -3,16 +3,104 @@ Controllers for handling application logic.
 """
 
 import base64
+import functools
+import hashlib
+import hmac
 import json
 import logging
 import os
-from datetime import datetime
+import re
+import secrets
+import uuid
+from datetime import date, datetime, timedelta
+from decimal import Decimal
 from http import HTTPStatus
+from typing import Iterator
+from urllib.parse import urlsplit
 
 import azure.functions as func
-from rmanalyzer import services
-from rmanalyzer.models import Group, Person
-from rmanalyzer.utils import get_transactions
+from rmanalyzer import (
+    alerts,
+    backups,
+    cashflow,
+    connectors,
+    documents,
+    exports,
+    filters,
+    middleware,
+    ops_alerts,
+    pdf,
+    reconcile,
+    review,
+    sandbox,
+    search,
+    services,
+    statement,
+    usage,
+)
+from rmanalyzer.budgets import (
+    SAVINGS_TRANSFER_ITEM_NAME,
+    budget_status,
+    discretionary_leftover,
+    month_elapsed,
+    suggested_transfer,
+)
+from rmanalyzer.cycles import cycle_start, statement_due
+from rmanalyzer.diff import largest_deltas, merchant_name
+from rmanalyzer.emergency import (
+    LOOKBACK_MONTHS,
+    average_monthly_spend,
+    coverage_months,
+    previous_months,
+)
+from rmanalyzer.fees import fee_summary, upcoming_fees
+from rmanalyzer.health import health_score
+from rmanalyzer.inflation import CPI_INDEX, months_between, to_real
+from rmanalyzer.ledger import LedgerEntry, running_balance, settled_in_month
+from rmanalyzer.mailbox import PROVIDERS as MAIL_PROVIDERS
+from rmanalyzer.mailbox import Mailbox, message_key
+from rmanalyzer.models import (
+    AnyCategory,
+    Category,
+    Group,
+    IgnoredFrom,
+    Person,
+    Transaction,
+    all_categories,
+    category_names,
+    parse_category,
+    split_by_weight,
+)
+from rmanalyzer.payments import (
+    MAX_REMINDER_LEADS,
+    leads_due,
+    next_due_date,
+    payment_made,
+)
+from rmanalyzer.interest import (
+    MAX_PROJECTION_MONTHS,
+    minimum_payment,
+    project_interest,
+    promo_months_left,
+)
+from rmanalyzer.promos import expiring_promos, monthly_interest
+from rmanalyzer.recurring import (
+    HISTORY_MONTHS,
+    RecurringCharge,
+    detect_recurring,
+    missing_charges,
+    price_increase,
+)
+from rmanalyzer.retention import RetentionPolicy
+from rmanalyzer.rewards import best_cards, card_rewards
+from rmanalyzer.roundups import ROUND_UP_ITEM_NAME, person_round_ups
+from rmanalyzer.rules import Rule, apply_rules, has_nested_quantifier
+from rmanalyzer.savings import copy_forward
+from rmanalyzer.services import shared_metrics, table_metrics
+from rmanalyzer.utils import category_aliases
+from rmanalyzer.utilization import aggregate_utilization, card_utilization
+from rmanalyzer.validation import DATE_PATTERN, MONTH_PATTERN, FieldError, Schema
+from rmanalyzer.xlsx import XLSX_MEDIA_TYPE
 
 __all__ = ["controller"]
 
@@ -22,6 +110,820 @@ logger = logging.getLogger(__name__)
 # Limit file size to 10MB to prevent DoS
 MAX_FILE_SIZE = 10 * 1024 * 1024
 
+# Largest total attachment size for an email. ACS caps a message at 10 MB and
+# base64 encoding adds a third.
+MAX_ATTACHMENT_SIZE = 7 * 1024 * 1024
+
+# Blob prefix in the uploads container that automation (e.g. azcopy) drops
+# statements under to be imported
+DEFAULT_INBOX_PREFIX = "inbox/"
+
+# Who inbox imports are recorded as uploaded by
+INBOX_UPLOADER = "inbox"
+
+# Days of mail searched for statements on each fetch, unless MAILBOX_LOOKBACK_DAYS
+# is set. Messages already fetched are skipped, so the window can overlap.
+DEFAULT_MAILBOX_LOOKBACK_DAYS = 7
+
+# Longest display name or institution name a synced account may have
+MAX_ACCOUNT_NAME_LENGTH = 100
+
+# Kinds of event in the activity feed
+ACTIVITY_KINDS = (
+    "import",
+    "edit",
+    "reminder",
+    "settlement",
+    "document",
+    "savings",
+    "report",
+)
+
+# A savings transfer suggestion's id: its month and whole amount
+SAVINGS_SUGGESTION_ID_PATTERN = r"^(\d{4}-\d{2})_(\d+)$"
+
+# Months of savings transfer suggestions kept, so their links still work
+SAVINGS_SUGGESTION_MONTHS = 3
+
+# Months back the nightly rollover looks for savings to start a missing month from
+SAVINGS_ROLLOVER_MONTHS = 12
+
+# Minutes a document download link works for, unless DOCUMENT_LINK_MINUTES is set
+DEFAULT_DOCUMENT_LINK_MINUTES = 15
+
+# Reports a read-only share link can be made for
+SHARE_REPORTS = ("summary", "trends")
+
+# How long a share link stays valid by default, and at most, in days
+SHARE_TTL_DAYS = 7
+MAX_SHARE_TTL_DAYS = 90
+
+# Longest transaction filter expression accepted
+MAX_FILTER_LENGTH = 500
+
+# Most months one bulk edit or rules run can go over
+MAX_BULK_MONTHS = 24
+
+# Fields bulk edit can change, by request field, with their entity column
+BULK_EDIT_FIELDS = {"category": "Category", "ignore": "IgnoredFrom", "owner": "Owner"}
+
+# Orders the transactions list can be sorted in, each with its key and direction
+TRANSACTION_SORTS = {
+    "newest": (lambda t: t.date, True),
+    "oldest": (lambda t: t.date, False),
+    "largest": (lambda t: t.amount, True),
+    "smallest": (lambda t: t.amount, False),
+}
+
+# Months a transaction search looks through by default, counting the current
+# one, unless TRANSACTION_SEARCH_MONTHS is set, and at most
+DEFAULT_SEARCH_MONTHS = 12
+MAX_SEARCH_MONTHS = 60
+
+# Transactions a search returns by default, and at most
+DEFAULT_SEARCH_LIMIT = 100
+MAX_SEARCH_LIMIT = 500
+
+# Longest name of a saved transaction view
+MAX_VIEW_NAME_LENGTH = 100
+
+# Longest name of a category the household adds
+MAX_CATEGORY_NAME_LENGTH = 50
+
+# Synced account fields stored as floats
+SYNCED_NUMBER_FIELDS = (
+    "balance", "limit", "annualFee", "apr", "promoApr", "rewardRate", "minimumPayment"
+)
+
+
+def _check_savings_item(item: object) -> str | None:
+    """Validates a single savings line item."""
+    if not isinstance(item, dict):
+        return "must be an object"
+    errors = Schema().string("name").number("cost").boolean("oneOff").validate(item)
+    return "; ".join(f"{e.field} {e.message}" for e in errors) or None
+
+
+def _household_category_names() -> list[str]:
+    """
+    The names of the categories of the household the request is in, built-in
+    or added, for the schemas to check names against.
+    """
+    return category_names(controller.db_service.category_names())
+
+
+def _check_category(item: object) -> str | None:
+    """Validates a category name."""
+    if item not in _household_category_names():
+        return "is not a known category"
+    return None
+
+
+def _check_split_share(value: object) -> str | None:
+    """Validates a member's share of shared spend, from 0 to 1."""
+    errors = Schema().number("value", minimum=0, maximum=1).validate({"value": value})
+    return errors[0].message if errors else None
+
+
+def _check_increment(value: object) -> str | None:
+    """Validates a round-up increment. 0 disables round-ups."""
+    errors = Schema().number("value", minimum=0).validate({"value": value})
+    return errors[0].message if errors else None
+
+
+def _check_lead_day(item: object) -> str | None:
+    """Validates a payment reminder lead, in days before the due date."""
+    errors = Schema().integer("value", minimum=0, maximum=31).validate({"value": item})
+    return errors[0].message if errors else None
+
+
+def _check_lead_days(body: dict, field: str) -> list[FieldError]:
+    """Checks what a schema can't: a lead days array isn't too long."""
+    if len(body.get(field) or []) > MAX_REMINDER_LEADS:
+        return [FieldError(field, f"must have at most {MAX_REMINDER_LEADS} items")]
+    return []
+
+
+def _check_account_number(item: object) -> str | None:
+    """Validates an account number."""
+    errors = Schema().integer("value", minimum=0).validate({"value": item})
+    return errors[0].message if errors else None
+
+
+def _check_month(item: object) -> str | None:
+    """Validates a month (YYYY-MM)."""
+    errors = Schema().string("value", pattern=MONTH_PATTERN).validate({"value": item})
+    return errors[0].message if errors else None
+
+
+def _synced_account_errors(accounts: list) -> list[FieldError]:
+    """
+    Validates each account in an external account-sync payload, naming fields by
+    their position, e.g. accounts[0].limit.
+    """
+    errors = []
+    for i, item in enumerate(accounts):
+        prefix = f"accounts[{i}]"
+        if not isinstance(item, dict):
+            errors.append(FieldError(prefix, "must be an object"))
+            continue
+        errors.extend(
+            FieldError(f"{prefix}.{e.field}", e.message)
+            for e in _check_synced_account(item)
+        )
+    return errors
+
+
+def _check_synced_account(item: dict) -> list[FieldError]:
+    """Validates a single account from an external account-sync payload."""
+    errors = (
+        Schema()
+        # Used as the RowKey, which can't contain these characters
+        .string("accountId", required=True, pattern=r"^[^/\\#?]+$")
+        .string("name", max_length=MAX_ACCOUNT_NAME_LENGTH)
+        .string("institution", max_length=MAX_ACCOUNT_NAME_LENGTH)
+        .string("mask", pattern=r"^\d{2,4}$")
+        .number("balance", minimum=0)
+        .number("limit", minimum=0)
+        .integer("dueDay", minimum=1, maximum=31)
+        .integer("statementCloseDay", minimum=1, maximum=31)
+        .number("annualFee", minimum=0)
+        .integer("feeMonth", minimum=1, maximum=12)
+        .number("apr", minimum=0)
+        .number("promoApr", minimum=0)
+        .string("promoExpiry", pattern=DATE_PATTERN)
+        .number("minimumPayment", minimum=0)
+        .number("rewardRate", minimum=0)
+        .mapping("categoryRewardRates")
+        .array("reminderLeadDays", check=_check_lead_day)
+        .validate(item)
+    )
+    errors.extend(_check_lead_days(item, "reminderLeadDays"))
+    if isinstance(item.get("categoryRewardRates"), dict):
+        for category, rate in item["categoryRewardRates"].items():
+            message = _check_category(category) or _check_reward_rate(rate)
+            if message:
+                errors.append(FieldError(f"categoryRewardRates.{category}", message))
+    if not any(e.field == "promoExpiry" for e in errors) and item.get("promoExpiry"):
+        try:
+            date.fromisoformat(item["promoExpiry"])
+        except ValueError:
+            errors.append(FieldError("promoExpiry", "is not a valid date"))
+    return errors
+
+
+def _check_transaction_id(item: object) -> str | None:
+    """Validates a transaction ID (RowKey)."""
+    if not isinstance(item, str) or not item:
+        return "must be a transaction ID"
+    return None
+
+
+def _check_bulk_edit(body: dict) -> list[FieldError]:
+    """
+    Checks what BULK_EDIT_BODY can't: transactions are picked by a filter or by
+    IDs but not both, the filter parses, and the changes are ones bulk edit makes.
+    """
+    errors = []
+    if bool(body.get("filter")) == bool(body.get("ids")):
+        errors.append(FieldError("body", "must set one of: filter, ids"))
+    if body.get("filter"):
+        try:
+            filters.parse(body["filter"], tuple(_household_category_names()))
+        except filters.FilterError as e:
+            errors.append(FieldError("filter", str(e)))
+    changes = body["changes"]
+    unknown = sorted(set(changes) - set(BULK_EDIT_FIELDS))
+    if unknown:
+        errors.append(
+            FieldError(
+                "changes",
+                f"can't change {', '.join(unknown)}; "
+                f"use {', '.join(BULK_EDIT_FIELDS)}",
+            )
+        )
+    elif not changes:
+        errors.append(FieldError("changes", "must change at least one field"))
+    errors.extend(
+        FieldError(f"changes.{e.field}", e.message)
+        for e in BULK_EDIT_CHANGES.validate(changes)
+    )
+    return errors
+
+
+def _check_view(body: dict) -> list[FieldError]:
+    """Checks what VIEW_BODY can't: the name isn't blank and the filter parses."""
+    errors = []
+    if not body["name"].strip():
+        errors.append(FieldError("name", "must not be blank"))
+    if body.get("filter"):
+        try:
+            filters.parse(body["filter"], tuple(_household_category_names()))
+        except filters.FilterError as e:
+            errors.append(FieldError("filter", str(e)))
+    return errors
+
+
+def _filter_uses(expression: str | None, category: AnyCategory) -> bool:
+    """Whether a saved filter compares with a category; one that won't parse doesn't."""
+    if not expression:
+        return False
+    try:
+        return category.value.lower() in filters.parse(expression).categories
+    except filters.FilterError:
+        return False
+
+
+def _check_rule(body: dict) -> list[FieldError]:
+    """
+    Checks what RULE_BODY can't: the regex compiles without nested repetition and
+    some condition is set.
+    """
+    errors = []
+    if body.get("pattern"):
+        try:
+            re.compile(body["pattern"])
+        except re.error:
+            errors.append(FieldError("pattern", "must be a valid regular expression"))
+        else:
+            if has_nested_quantifier(body["pattern"]):
+                errors.append(
+                    FieldError("pattern", "must not repeat a group that repeats")
+                )
+    if body.get("filter"):
+        try:
+            filters.parse(body["filter"], tuple(_household_category_names()))
+        except filters.FilterError as e:
+            errors.append(FieldError("filter", str(e)))
+    conditions = (
+        "contains",
+        "pattern",
+        "minAmount",
+        "maxAmount",
+        "accountNumber",
+        "filter",
+    )
+    if all(body.get(field) in (None, "") for field in conditions):
+        errors.append(FieldError("body", f"must set one of: {', '.join(conditions)}"))
+    low, high = body.get("minAmount"), body.get("maxAmount")
+    if low is not None and high is not None and float(low) > float(high):
+        errors.append(FieldError("maxAmount", "must be at least minAmount"))
+    return errors
+
+
+def _check_connector(body: dict) -> list[FieldError]:
+    """Checks what CONNECTOR_BODY can't: SFTP servers are pinned to a host key."""
+    if body.get("protocol") == "sftp" and not body.get("hostKey"):
+        return [FieldError("hostKey", "is required for SFTP connectors")]
+    return []
+
+
+def _check_mail_rule(item: object) -> str | None:
+    """Validates a mailbox rule, which must narrow the emails it matches."""
+    if not isinstance(item, dict):
+        return "must be an object"
+    errors = (
+        Schema()
+        .string("from", max_length=254)
+        .string("subject", max_length=200)
+        .validate(item)
+    )
+    if not errors and not item.get("from") and not item.get("subject"):
+        return "must set from or subject"
+    return "; ".join(f"{e.field} {e.message}" for e in errors) or None
+
+
+def _check_mailbox(body: dict) -> list[FieldError]:
+    """Checks what MAILBOX_BODY can't: there's a rule to match statements by."""
+    if not body.get("rules"):
+        return [FieldError("rules", "must have at least one rule")]
+    return []
+
+
+def _normalize_synced_account(account: dict) -> dict:
+    """Converts a validated synced account's numeric fields, which may be strings."""
+    normalized = {
+        **account,
+        **{
+            field: float(account[field])
+            for field in SYNCED_NUMBER_FIELDS
+            if account.get(field) is not None
+        },
+    }
+    for field in ("feeMonth", "dueDay", "statementCloseDay"):
+        if account.get(field) is not None:
+            normalized[field] = int(account[field])
+    if account.get("reminderLeadDays") is not None:
+        normalized["reminderLeadDays"] = sorted(
+            {int(lead) for lead in account["reminderLeadDays"]}, reverse=True
+        )
+    if account.get("categoryRewardRates") is not None:
+        normalized["categoryRewardRates"] = {
+            category: float(rate)
+            for category, rate in account["categoryRewardRates"].items()
+        }
+    return normalized
+
+
+def _check_reward_rate(value: object) -> str | None:
+    """Validates a reward rate percentage."""
+    errors = Schema().number("value", minimum=0).validate({"value": value})
+    return errors[0].message if errors else None
+
+
+def _check_cpi_value(value: object) -> str | None:
+    """Validates a CPI index value."""
+    errors = Schema().number("value", minimum=0.01).validate({"value": value})
+    return errors[0].message if errors else None
+
+
+# Request schemas
+UPLOAD_PARAMS = Schema().string(
+    "priority", choices=[p.value for p in services.QueuePriority]
+)
+SAVINGS_PARAMS = Schema().string("month", pattern=MONTH_PATTERN)
+DOCUMENTS_PARAMS = (
+    Schema()
+    .string("month", required=True, pattern=MONTH_PATTERN)
+    .integer("accountNumber", minimum=0)
+)
+DOCUMENT_UPLOAD_PARAMS = (
+    Schema()
+    .string("month", required=True, pattern=MONTH_PATTERN)
+    .integer("accountNumber", minimum=0)
+    .string("description", max_length=200)
+)
+DOCUMENT_ID_PATTERN = r"^[0-9a-f]{32}$"
+SAVINGS_BODY = (
+    Schema()
+    .string("month", pattern=MONTH_PATTERN)
+    .number("startingBalance", required=True)
+    .array("items", check=_check_savings_item)
+)
+SAVINGS_COPY_PARAMS = (
+    Schema()
+    .string("from", required=True, pattern=MONTH_PATTERN)
+    .string("to", required=True, pattern=MONTH_PATTERN)
+    .boolean("excludeOneOff")
+)
+COMPARE_PARAMS = (
+    Schema()
+    .string("p1", required=True)
+    .string("p2", required=True)
+    .string("month", pattern=MONTH_PATTERN)
+)
+SETTLE_BODY = (
+    Schema()
+    .number("amount", required=True, minimum=0.01)
+    .string("month", pattern=MONTH_PATTERN)
+)
+ASSIGN_BODY = Schema().integer("accountNumber", required=True).string(
+    "email", required=True
+)
+INVITE_BODY = (
+    Schema()
+    .string("name", required=True)
+    .string("email", required=True, pattern=r"^[^@\s]+@[^@\s]+$")
+)
+INVITE_ACCEPT_BODY = Schema().string("token", required=True)
+SIGNED_LINK_BODY = Schema().string("sig", required=True)
+SHARE_BODY = (
+    Schema()
+    .string("report", required=True, choices=list(SHARE_REPORTS))
+    .string("month", pattern=MONTH_PATTERN)
+    .string("from", pattern=MONTH_PATTERN)
+    .string("to", pattern=MONTH_PATTERN)
+    .boolean("real")
+    .integer("expiresInDays", minimum=1, maximum=MAX_SHARE_TTL_DAYS)
+)
+PERSON_BODY = (
+    Schema()
+    .string("name", required=True)
+    .string("email", required=True, pattern=r"^[^@\s]+@[^@\s]+$")
+    .array("accounts", check=_check_account_number)
+    .number("splitShare", minimum=0, maximum=1)
+    .mapping("categoryShares")
+)
+ACCOUNT_SYNC_BODY = (
+    Schema()
+    .array("accounts", required=True)
+    .boolean("override")
+)
+ACCOUNT_BODY = (
+    Schema()
+    .integer("accountNumber", required=True, minimum=0)
+    .number("weight", minimum=0.01, maximum=1)
+)
+RECONCILE_BODY = (
+    Schema()
+    .number("balance", required=True)
+    .string("date", pattern=DATE_PATTERN)
+    .boolean("accept")
+)
+OWNER_BODY = Schema().string("owner")
+TRANSACTION_BODY = (
+    Schema()
+    .string("name")
+    .number("amount")
+    .integer("accountNumber")
+    .string("category", choices=_household_category_names)
+    .string("ignore", choices=[i.value for i in IgnoredFrom])
+)
+SETTINGS_BODY = (
+    Schema()
+    .array("debtExcludedCategories", check=_check_category)
+    .mapping("roundUpIncrements")
+    .mapping("cpiIndex")
+    .number("emergencyFund", minimum=0)
+    .number("emergencyFundAlertMonths", minimum=0)
+    .integer("missingBillGraceDays", minimum=0)
+    .number("priceIncreaseThreshold", minimum=0)
+    .integer("promoExpiryWarningDays", minimum=0)
+    .integer("paymentReminderDays", minimum=1)
+    .array("paymentReminderLeadDays", check=_check_lead_day)
+    .number("utilizationAlertThreshold", minimum=0, maximum=1)
+    .number("savingsSuggestionShare", minimum=0, maximum=1)
+    .number("errorRateAlertThreshold", minimum=0, maximum=1)
+    .integer("queueBacklogAlertThreshold", minimum=0)
+    .integer("queueAgeAlertMinutes", minimum=0)
+    .integer("jobFailureAlertThreshold", minimum=0)
+    .boolean("summaryAttachments")
+)
+# Characters category names can't have, as they end up in RowKeys and URLs
+CATEGORY_NAME_PATTERN = r"^[^/\\#?\x00-\x1f]+$"
+COLOR_PATTERN = r"^#[0-9A-Fa-f]{6}$"
+CATEGORY_BODY = (
+    Schema()
+    .string("category", required=True, choices=_household_category_names)
+    .boolean("essential")
+    .string("color", pattern=COLOR_PATTERN)
+    .boolean("budgetEligible")
+)
+NEW_CATEGORY_BODY = (
+    Schema()
+    .string(
+        "name",
+        required=True,
+        pattern=CATEGORY_NAME_PATTERN,
+        max_length=MAX_CATEGORY_NAME_LENGTH,
+    )
+    .boolean("essential")
+    .string("color", pattern=COLOR_PATTERN)
+    .boolean("budgetEligible")
+)
+CATEGORY_PARAMS = Schema().string(
+    "name", required=True, choices=_household_category_names
+)
+RULE_BODY = (
+    Schema()
+    .string("category", required=True, choices=_household_category_names)
+    .string("contains")
+    .string("pattern", max_length=MAX_FILTER_LENGTH)
+    .number("minAmount")
+    .number("maxAmount")
+    .integer("accountNumber", minimum=0)
+    .integer("priority")
+    .string("filter", max_length=MAX_FILTER_LENGTH)
+)
+BUDGET_BODY = (
+    Schema()
+    .string("category", required=True, choices=_household_category_names)
+    .number("limit", required=True, minimum=0)
+)
+BUDGET_PARAMS = Schema().string(
+    "category", required=True, choices=_household_category_names
+)
+REVIEW_PARAMS = (
+    Schema()
+    .string("month", pattern=MONTH_PATTERN)
+    .string("format", choices=["json", "pdf"])
+)
+ALERT_BODY = (
+    Schema()
+    .string("status", required=True, choices=list(alerts.ALERT_STATUSES))
+    .string("snoozedUntil", pattern=DATE_PATTERN)
+)
+REWARDS_PARAMS = Schema().string("month", pattern=MONTH_PATTERN)
+INTEREST_PROJECTION_PARAMS = (
+    Schema()
+    .number("payment", minimum=0.01)
+    .integer("months", minimum=1, maximum=MAX_PROJECTION_MONTHS)
+)
+BEST_CARD_PARAMS = Schema().string("category", choices=_household_category_names)
+RULES_APPLY_BODY = (
+    Schema()
+    .array("months", required=True, check=_check_month, max_length=MAX_BULK_MONTHS)
+    .boolean("async")
+    .boolean("override")
+)
+BULK_EDIT_BODY = (
+    Schema()
+    .array("months", required=True, check=_check_month, max_length=MAX_BULK_MONTHS)
+    .string("filter", max_length=MAX_FILTER_LENGTH)
+    .array("ids", check=_check_transaction_id)
+    .mapping("changes", required=True)
+    .boolean("dryRun")
+)
+BULK_EDIT_CHANGES = (
+    Schema()
+    .string("category", choices=_household_category_names)
+    .string("ignore", choices=[i.value for i in IgnoredFrom])
+    .string("owner")
+)
+TREND_PARAMS = (
+    Schema()
+    .string("from", required=True, pattern=MONTH_PATTERN)
+    .string("to", required=True, pattern=MONTH_PATTERN)
+    .boolean("real")
+)
+DIFF_PARAMS = (
+    Schema()
+    .string("a", required=True, pattern=MONTH_PATTERN)
+    .string("b", required=True, pattern=MONTH_PATTERN)
+    .integer("limit", minimum=1)
+)
+BUDGET_EXPORT_PARAMS = Schema().string("year", required=True, pattern=r"^\d{4}$")
+TRANSACTIONS_EXPORT_PARAMS = (
+    Schema()
+    .string("from", required=True, pattern=MONTH_PATTERN)
+    .string("to", required=True, pattern=MONTH_PATTERN)
+    .string("format", choices=["csv", "ndjson", "xlsx"])
+    .string("filter", max_length=MAX_FILTER_LENGTH)
+)
+EXPORT_PARAMS = (
+    Schema()
+    .string("month", required=True, pattern=MONTH_PATTERN)
+    .string("format", choices=["csv", "xlsx"])
+)
+TRANSACTIONS_PARAMS = (
+    Schema()
+    .string("month", pattern=MONTH_PATTERN)
+    .string("filter", max_length=MAX_FILTER_LENGTH)
+    .string("sort", choices=list(TRANSACTION_SORTS))
+)
+TRANSACTIONS_SEARCH_PARAMS = (
+    Schema()
+    .string("q", required=True, max_length=200)
+    .integer("months", minimum=1, maximum=MAX_SEARCH_MONTHS)
+    .number("minAmount")
+    .number("maxAmount")
+    .string("from", pattern=DATE_PATTERN)
+    .string("to", pattern=DATE_PATTERN)
+    .integer("limit", minimum=1, maximum=MAX_SEARCH_LIMIT)
+)
+VIEW_BODY = (
+    Schema()
+    .string("name", required=True, max_length=MAX_VIEW_NAME_LENGTH)
+    .string("filter", max_length=MAX_FILTER_LENGTH)
+    .string("sort", choices=list(TRANSACTION_SORTS))
+)
+PURGE_BODY = Schema().boolean("dryRun").string("confirmationToken")
+TABLE_SWITCH_BODY = Schema().mapping("tables", required=True)
+
+# What Table Storage allows in a table name
+TABLE_NAME_PATTERN = r"^[A-Za-z][A-Za-z0-9]{2,62}$"
+ACTIVITY_PARAMS = (
+    Schema()
+    .integer("limit", minimum=1)
+    .string("cursor", pattern=r"^\d{13}_[0-9a-f]{8}$")
+    .string("kind", choices=list(ACTIVITY_KINDS))
+)
+JOB_HISTORY_PARAMS = Schema().integer("limit", minimum=1)
+UPLOADS_PARAMS = Schema().integer("limit", minimum=1)
+STORAGE_OPS_PARAMS = Schema().integer("days", minimum=1)
+REPROCESS_PARAMS = Schema().boolean("override")
+
+CONNECTOR_NAME_PATTERN = r"^[a-z0-9][a-z0-9-]{0,49}$"
+CONNECTOR_BODY = (
+    Schema()
+    .string("protocol", required=True, choices=list(connectors.PROTOCOLS))
+    .string("host", required=True, max_length=253)
+    .integer("port", minimum=1, maximum=65535)
+    .string("username", required=True, max_length=100)
+    .string(
+        "secretName", required=True, pattern=rf"^{services.SECRET_PREFIX}[A-Z0-9_]+$"
+    )
+    .string("path", max_length=500)
+    .string("hostKey", max_length=2000)
+    .integer("intervalMinutes", minimum=5, maximum=7 * 24 * 60)
+)
+
+MAILBOX_BODY = (
+    Schema()
+    .string("provider", required=True, choices=list(MAIL_PROVIDERS))
+    .string("clientId", required=True, max_length=200)
+    .string(
+        "secretName", required=True, pattern=rf"^{services.SECRET_PREFIX}[A-Z0-9_]+$"
+    )
+    .string("tenantId", max_length=100, pattern=r"^[A-Za-z0-9.-]+$")
+    .array("rules", required=True, check=_check_mail_rule)
+    .integer("intervalMinutes", minimum=5, maximum=7 * 24 * 60)
+)
+
+# Settings written on first bootstrap; existing values are never overwritten
+DEFAULT_SETTINGS = {
+    "SchemaVersion": "1",
+    "DefaultTenant": "default",
+    "DebtExcludedCategories": "[]",
+    "EssentialCategories": json.dumps([Category.GROCERIES.value, Category.BILLS.value]),
+    "RoundUpIncrements": "{}",
+    "CpiIndex": "{}",
+    "EmergencyFund": "0",
+    "EmergencyFundAlertMonths": "3",
+    "MissingBillGraceDays": "3",
+    "PriceIncreaseThreshold": "0.1",
+    "PromoExpiryWarningDays": "30",
+    "PaymentReminderDays": "5",
+    "PaymentReminderLeadDays": "[]",
+    "UtilizationAlertThreshold": "0.3",
+    "SavingsSuggestionShare": "0.5",
+    "ErrorRateAlertThreshold": "0.05",
+    "QueueBacklogAlertThreshold": "100",
+    "QueueAgeAlertMinutes": "30",
+    "JobFailureAlertThreshold": "2",
+    "SummaryAttachments": "false",
+}
+
+# Editable transaction fields, by request field, with their entity column
+TRANSACTION_FIELDS = {
+    "name": ("Description", str),
+    "amount": ("Amount", float),
+    "accountNumber": ("AccountNumber", int),
+    "category": ("Category", str),
+    "ignore": ("IgnoredFrom", str),
+}
+
+# Numeric settings exposed through the settings API, by request field
+NUMERIC_SETTINGS = {
+    "emergencyFund": "EmergencyFund",
+    "emergencyFundAlertMonths": "EmergencyFundAlertMonths",
+    "missingBillGraceDays": "MissingBillGraceDays",
+    "priceIncreaseThreshold": "PriceIncreaseThreshold",
+    "promoExpiryWarningDays": "PromoExpiryWarningDays",
+    "paymentReminderDays": "PaymentReminderDays",
+    "utilizationAlertThreshold": "UtilizationAlertThreshold",
+    "savingsSuggestionShare": "SavingsSuggestionShare",
+    "errorRateAlertThreshold": "ErrorRateAlertThreshold",
+    "queueBacklogAlertThreshold": "QueueBacklogAlertThreshold",
+    "queueAgeAlertMinutes": "QueueAgeAlertMinutes",
+    "jobFailureAlertThreshold": "JobFailureAlertThreshold",
+}
+
+# Queue message task that re-applies category rules to stored months
+APPLY_RULES_TASK = "apply_rules"
+
+# Queue message task that rehearses restoring a backup
+DR_REHEARSAL_TASK = "dr_rehearsal"
+
+# Attempts the Functions host makes at a queue message before moving it to the
+# poison queue (the host's default maxDequeueCount)
+MAX_DEQUEUE_COUNT = 5
+
+# Failure record statuses that can be retried
+RETRYABLE_STATUSES = ("pending", "failed")
+
+# Longest range a trend report covers, in months
+MAX_TREND_MONTHS = 120
+
+# Longest range a transactions export covers, in months
+MAX_EXPORT_MONTHS = 120
+
+# Most category and merchant deltas a diff report returns
+MAX_DIFF_ITEMS = 50
+
+# Activity feed page sizes
+DEFAULT_ACTIVITY_LIMIT = 50
+MAX_ACTIVITY_LIMIT = 200
+
+# Upload status page sizes
+DEFAULT_UPLOADS_LIMIT = 50
+MAX_UPLOADS_LIMIT = 200
+
+# Months an interest projection lists by default
+DEFAULT_PROJECTION_MONTHS = 12
+
+# Name the nightly recurring charge job's runs are recorded under
+NIGHTLY_JOB = "nightly"
+
+# How long after its last run the nightly job is reported as missing
+NIGHTLY_STALE_AFTER = timedelta(hours=24)
+
+# Name backup runs are recorded under
+BACKUP_JOB = "backup"
+
+# Name monthly review packet runs are recorded under
+REVIEW_PACKET_JOB = "review-packet"
+
+# Name monthly report runs are recorded under
+MONTHLY_REPORT_JOB = "monthly-report"
+
+# Name restore rehearsals are recorded under
+DR_REHEARSAL_JOB = "dr-rehearsal"
+
+# Prefix of the shadow tables a rehearsal restores backups into. Table names
+# can only hold letters and digits, so there's no separator.
+DR_TABLE_PREFIX = "drtest"
+
+# Secret holding the base64 AES-256 backup key, unless BACKUP_KEY_SECRET names
+# another
+DEFAULT_BACKUP_KEY_SECRET = "SECRET_BACKUP_KEY"
+
+# Job history page sizes
+DEFAULT_JOB_HISTORY_LIMIT = 30
+MAX_JOB_HISTORY_LIMIT = 365
+
+API_KEY_HEADER = "x-api-key"
+API_KEY_PREFIX = "rmk_"
+
+INVITE_TOKEN_PREFIX = "rmi_"
+
+# How long an invite link stays valid
+INVITE_TTL_DAYS = 7
+
+SHARE_TOKEN_PREFIX = "rms_"
+# Shared reports take their token in a header, keeping it out of logged URLs
+SHARE_TOKEN_HEADER = "x-share-token"
+
+# Responses that delete something name the undo operation that restores it here
+UNDO_HEADER = "x-undo-id"
+
+# How long a destructive change can be undone for, by default
+DEFAULT_UNDO_WINDOW_MINUTES = 30
+
+# Longest inverse kept in the undo log, in chunks well within the 1MB an entity holds
+MAX_UNDO_INVERSE_LENGTH = 15 * services.UNDO_CHUNK_LENGTH
+
+
+def _hash_token(token: str) -> str:
+    """
+    API keys, invite tokens and share link tokens are stored and looked up by
+    their SHA-256 hash only.
+    """
+    return hashlib.sha256(token.encode("utf-8")).hexdigest()
+
+
+def _reminder_id(key: str, due: str) -> str:
+    """Encodes a payment reminder's state key and due date for use in a URL."""
+    raw = f"{key}/{due}".encode("utf-8")
+    return base64.urlsafe_b64encode(raw).decode("ascii").rstrip("=")
+
+
+def _parse_reminder_id(reminder_id: str) -> tuple[str, str]:
+    """Splits a reminder ID into its state key and due date. Raises ValueError."""
+    padded = reminder_id + "=" * (-len(reminder_id) % 4)
+    key, due = base64.urlsafe_b64decode(padded).decode("utf-8").rsplit("/", 1)
+    return key, due
+
+
+def _sign_reminder(reminder_id: str) -> str | None:
+    """Signs a reminder ID with REMINDER_SIGNING_KEY. None when no key is set."""
+    key = os.environ.get("REMINDER_SIGNING_KEY")
+    if not key:
+        return None
+    return hmac.new(
+        key.encode("utf-8"), reminder_id.encode("utf-8"), hashlib.sha256
+    ).hexdigest()
+
 
 class Controller:
     """
@@ -33,25 +935,72 @@ class Controller:
         queue_service: Services for queue operations.
         email_service: Services for sending emails.
         email_renderer: Helper for rendering email content.
+        exchange_rates: Services for converting foreign currency transactions.
+        secret_provider: Provider of credentials for pull connectors.
     """
 
     def __init__(self) -> None:
         # Instantiate Services
         # We do this at instance level (singleton) to cache clients
-        self.db_service = services.DatabaseService()
+        self.live_db_service = services.DatabaseService()
         self.blob_service = services.BlobService()
-        self.queue_service = services.QueueService()
+        self.queue_service = services.QueueService(self.blob_service)
         self.email_service = services.EmailService()
         self.email_renderer = services.EmailRenderer()
+        self.exchange_rates = services.ExchangeRateService(self.live_db_service)
+        self.secret_provider = services.SecretProvider()
+        self._sandbox_db_service: services.DatabaseService | None = None
+        self.search_index = search.SearchIndex(
+            float(os.environ.get("TRANSACTION_SEARCH_INDEX_SECONDS", "0"))
+        )
+        # The sandbox's copy of the service is made from this one, so it's told too
+        self.live_db_service.on_transactions_changed = self.search_index.forget
+        self.email_service.on_sent = self._count_emails_sent
+        # Metrics are counted across households, so they go to the live tables
+        shared_metrics.configure(self.live_db_service.save_metric_counts)
+
+    @property
+    def db_service(self) -> services.DatabaseService:
+        """
+        The tables of the household the request is in: the live ones, or the
+        sandbox's copies while a request is in the sandbox household.
+        """
+        return self.sandbox_db_service if sandbox.active() else self.live_db_service
+
+    def _categories(self) -> list[AnyCategory]:
+        """The household's categories: the built-in ones, then those it added."""
+        return all_categories(self.db_service.category_names())
+
+    def _category(self, name: object) -> AnyCategory:
+        """The household's category with a name. Raises ValueError if it has none."""
+        return parse_category(name, self.db_service.category_names())
+
+    def _person(self, config: dict) -> Person:
+        """A member from their people table entity, with the household's categories."""
+        return Person.from_config(config, self.db_service.category_names())
+
+    @property
+    def sandbox_db_service(self) -> services.DatabaseService:
+        """The sandbox household's copy of every table."""
+        if self._sandbox_db_service is None:
+            self._sandbox_db_service = self.live_db_service.shadow(
+                sandbox.TABLE_PREFIX
+            )
+        return self._sandbox_db_service
 
     def _get_user_email(self, req: func.HttpRequest) -> str | None:
         """
         Parses the 'x-ms-client-principal' header to get the user's email (userDetails).
-        Returns None if header is missing or invalid.
+        Falls back to the owner of the key in the 'x-api-key' header for automation.
+        Returns None if neither is present or valid.
         """
         header = req.headers.get("x-ms-client-principal")
         if not header:
-            return None
+            api_key = req.headers.get(API_KEY_HEADER)
+            if not api_key:
+                return None
+            # Keys are issued per deployment, not per household
+            return self.live_db_service.get_api_key_owner(_hash_token(api_key))
 
         try:
             decoded = base64.b64decode(header).decode("utf-8")
@@ -61,6 +1010,189 @@ class Controller:
             logging.error("Failed to parse x-ms-client-principal: %s", e)
             return None
 
+    @staticmethod
+    def _admin_emails() -> list[str]:
+        """The administrators listed in the comma-separated ADMIN_EMAILS setting."""
+        admins = os.environ.get("ADMIN_EMAILS", "")
+        return [a.strip() for a in admins.split(",") if a.strip()]
+
+    @classmethod
+    def _is_admin(cls, user_email: str) -> bool:
+        """Checks the user against the ADMIN_EMAILS setting."""
+        return user_email.lower() in {a.lower() for a in cls._admin_emails()}
+
+    def _require_admin(
+        self, req: func.HttpRequest
+    ) -> tuple[str, func.HttpResponse | None]:
+        """
+        Helper to authenticate an administrative request.
+        Returns (user_email, error_response).
+        """
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return "", func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+        if not self._is_admin(user_email):
+            return "", func.HttpResponse("Forbidden", status_code=HTTPStatus.FORBIDDEN)
+        return user_email, None
+
+    @staticmethod
+    def _summarize_keys(tables: dict[str, list[dict]]) -> dict[str, dict]:
+        """Summarizes entity keys per table as a count and the affected partitions."""
+        return {
+            name: {
+                "count": len(keys),
+                "partitions": sorted({k["PartitionKey"] for k in keys}),
+            }
+            for name, keys in tables.items()
+        }
+
+    @staticmethod
+    def _validation_error(errors: list[FieldError]) -> func.HttpResponse:
+        """Builds a 400 response listing field-level validation errors."""
+        return func.HttpResponse(
+            json.dumps(
+                {
+                    "error": "Validation failed",
+                    "fields": [e.to_dict() for e in errors],
+                }
+            ),
+            mimetype="application/json",
+            status_code=HTTPStatus.BAD_REQUEST,
+        )
+
+    def _record_activity(
+        self,
+        kind: str,
+        summary: str,
+        actor: str | None = None,
+        details: dict | None = None,
+    ) -> None:
+        """
+        Adds an event to the activity feed. The feed is informational, so a failure
+        is logged rather than failing the action it describes.
+        """
+        try:
+            self.db_service.record_activity(kind, summary, actor, details)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to record %s activity: %s", kind, e)
+
+    @staticmethod
+    def _undo_window() -> timedelta:
+        """How long a change can be undone for (UNDO_WINDOW_MINUTES)."""
+        return timedelta(
+            minutes=int(
+                os.environ.get("UNDO_WINDOW_MINUTES", DEFAULT_UNDO_WINDOW_MINUTES)
+            )
+        )
+
+    def _record_undo(
+        self, kind: str, summary: str, actor: str, inverse: dict
+    ) -> str | None:
+        """
+        Adds a change's inverse to the undo log and returns the operation's id.
+        Like the activity feed, a failure is logged rather than failing the
+        change; so is an inverse too large to store. Either way it returns None.
+        """
+        if len(json.dumps(inverse)) > MAX_UNDO_INVERSE_LENGTH:
+            logging.warning("Not recording undo for %s: the change is too large", kind)
+            return None
+        operation_id = uuid.uuid4().hex
+        try:
+            self.db_service.save_undo_operation(
+                {
+                    "id": operation_id,
+                    "kind": kind,
+                    "summary": summary,
+                    "actor": actor,
+                    "inverse": inverse,
+                },
+                (datetime.now() - self._undo_window()).isoformat(),
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to record undo for %s: %s", kind, e)
+            return None
+        return operation_id
+
+    def _send_reminder(
+        self,
+        recipients: list[str],
+        subject: str,
+        body: str,
+        high_priority: bool = False,
+    ) -> None:
+        """Emails a reminder or alert and records it in the activity feed."""
+        self.email_service.send_email(
+            recipients, subject, body, high_priority=high_priority
+        )
+        self._record_activity("reminder", subject, details={"recipients": recipients})
+
+    def _suppressed_alerts(self, kind: str, today: date) -> set[str]:
+        """The keys of a kind of alert that are dismissed or snoozed past today."""
+        return {
+            a["key"]
+            for a in self.db_service.get_alerts()
+            if a["kind"] == kind and alerts.is_suppressed(a, today)
+        }
+
+    def _send_alert(
+        self,
+        kind: str,
+        keys: list[str],
+        recipients: list[str],
+        subject: str,
+        body: str,
+    ) -> None:
+        """
+        Emails an alert about one or more things of a kind (the bills that went
+        missing, a member's utilization) and records each, so members can snooze
+        or dismiss them. A failure to record is logged rather than failing the check.
+        """
+        self._send_reminder(recipients, subject, body)
+        for key in keys:
+            try:
+                self.db_service.record_alert(
+                    alerts.alert_id(kind, key),
+                    {
+                        "kind": kind,
+                        "key": key,
+                        "subject": subject,
+                        "recipients": recipients,
+                    },
+                )
+            except Exception as e:  # pylint: disable=broad-exception-caught
+                logging.error("Failed to record %s alert: %s", kind, e)
+
+    def _record_job_run(
+        self,
+        job: str,
+        started_at: datetime,
+        details: dict,
+        errors: list[str],
+    ) -> None:
+        """
+        Adds a scheduled job's run to its history. A failure is logged rather than
+        failing the run it describes.
+        """
+        try:
+            self.db_service.record_job_run(
+                job, started_at, "failed" if errors else "succeeded", details, errors
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to record %s job run: %s", job, e)
+
+    def _alert_admins(self, subject: str, body: str) -> None:
+        """Emails the administrators a high-priority operational alert."""
+        admins = self._admin_emails()
+        if not admins:
+            logging.warning("No ADMIN_EMAILS to alert: %s", subject)
+            return
+        try:
+            self.email_service.send_email(admins, subject, body, high_priority=True)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to alert admins: %s", e)
+
     def _get_uploaded_file_content(
         self,
         req: func.HttpRequest,
@@ -97,16 +1229,25 @@ class Controller:
 
     def handle_upload_async(self, req: func.HttpRequest) -> func.HttpResponse:
         """
-        Receives a CSV, uploads it to Blob Storage, and queues a processing message.
-        Returns 202 Accepted.
+        Receives a CSV, Mint export, OFX/QFX, QIF or XLSX statement, uploads it to
+        Blob Storage, and queues a processing message recording the file format
+        (by extension, or by content when the extension is ambiguous).
+        Bulk historical imports pass priority=backfill to use the backfill queue.
+        Returns 202 Accepted with the blob name to poll /api/uploads/{blobName} with.
         """
         logging.info("Processing async upload request.")
 
-        if not self._get_user_email(req):
+        user_email = self._get_user_email(req)
+        if not user_email:
             return func.HttpResponse(
                 "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
             )
 
+        errors = UPLOAD_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+        priority = services.QueuePriority(req.params.get("priority", "interactive"))
+
         try:
             # Extract File
             filename, content, error_resp = self._get_uploaded_file_content(req)
@@ -121,12 +1262,24 @@ class Controller:
             blob_url = self.blob_service.upload_csv(blob_name, content)
             logging.info("Uploaded blob: %s", blob_url)
 
+            # Track before enqueueing so processing can't start untracked
+            file_format = statement.detect_format(base_name, content)
+            try:
+                self.db_service.record_upload(blob_name, file_format, user_email)
+            except Exception as e:  # pylint: disable=broad-exception-caught
+                logging.error("Failed to track upload %s: %s", blob_name, e)
+
             # Enqueue Message
-            self.queue_service.enqueue_message({"blob_name": blob_name})
-            logging.info("Enqueued processing message for: %s", blob_name)
+            self.queue_service.enqueue_message(
+                {"blob_name": blob_name, "format": file_format}, priority=priority
+            )
+            logging.info(
+                "Enqueued %s processing message for: %s", priority.value, blob_name
+            )
 
             return func.HttpResponse(
-                "Upload accepted for processing.",
+                json.dumps({"blobName": blob_name, "status": "queued"}),
+                mimetype="application/json",
                 status_code=HTTPStatus.ACCEPTED,
             )
 
@@ -136,103 +1289,722 @@ class Controller:
                 f"Upload Error: {str(e)}", status_code=HTTPStatus.INTERNAL_SERVER_ERROR
             )
 
-    def process_queue_item(self, msg: func.QueueMessage) -> None:
+    def run_inbox_scan(self) -> None:
         """
-        Queue Trigger handler. Downloads CSV, analyzes it, saves to DB, and emails summary.
+        Timer Trigger handler. Queues processing for statements dropped under the
+        INBOX_PREFIX blob prefix, moving each out of the inbox once queued so it's
+        only imported once. Files that aren't statements are left where they are.
         """
+        prefix = os.environ.get("INBOX_PREFIX", DEFAULT_INBOX_PREFIX)
         try:
-            message_body = msg.get_body().decode("utf-8")
-            logging.info("Processing queue item: %s", message_body)
-
-            data = json.loads(message_body)
-            blob_name = data.get("blob_name")
+            blobs = self.blob_service.list_blob_properties(prefix)
+        except Exception as e:
+            logging.error("Error scanning inbox: %s", e)
+            raise
 
-            if not blob_name:
-                logging.error("Invalid message: missing blob_name")
-                return
+        queued = 0
+        for blob in blobs:
+            try:
+                queued += self._queue_inbox_file(prefix, blob)
+            except Exception as e:  # pylint: disable=broad-exception-caught
+                # Left in the inbox, so the next scan tries it again
+                logging.error("Failed to queue inbox file %s: %s", blob["name"], e)
 
-            # Download CSV
-            csv_content = self.blob_service.download_csv(blob_name)
+        if queued:
+            logging.info("Queued %d of %d inbox files.", queued, len(blobs))
 
-            # Analysis
-            transactions, errors = get_transactions(csv_content)
+    def _queue_inbox_file(self, prefix: str, blob: dict) -> bool:
+        """
+        Copies an inbox file to its own upload, tracks and queues it, then removes it
+        from the inbox. Returns whether it was queued.
+        """
+        source = blob["name"]
+        if not source.lower().endswith(statement.STATEMENT_EXTENSIONS):
+            logging.warning("Skipping inbox file that isn't a statement: %s", source)
+            return False
+        if (blob.get("size") or 0) > MAX_FILE_SIZE:
+            logging.warning(
+                "Skipping inbox file %s: larger than %dMB",
+                source,
+                MAX_FILE_SIZE // 1024 // 1024,
+            )
+            return False
 
-            # Retrieve People from DB
-            people_data = self.db_service.get_all_people()
-            members = [Person.from_config(p) for p in people_data]
+        # Files in subfolders keep their folder in the name, so they can't collide
+        now = datetime.now()
+        relative = source[len(prefix) :].replace("/", "_")
+        blob_name = f"{now.strftime('%Y%m%d%H%M%S')}_{relative}"
 
-            if errors and len(transactions) == 0:
-                logging.error("CSV Validation Errors: %s", errors)
+        content = self.blob_service.download_blob(services.BlobKind.UPLOADS, source)
+        last_modified = blob.get("lastModified")
+        queued = self._queue_collected_file(
+            blob_name,
+            content,
+            INBOX_UPLOADER,
+            {
+                "source": "inbox",
+                "path": source,
+                "size": blob.get("size"),
+                "lastModified": last_modified.isoformat() if last_modified else None,
+                "discoveredAt": now.isoformat(),
+            },
+        )
 
-                # Send Error Email
-                recipients = [p.email for p in members]
-                self.email_service.send_error_email(recipients, errors)
-                return
+        # A repeat of a file already imported is removed all the same
+        self.blob_service.delete_blob(source)
+        if queued:
+            logging.info("Queued inbox file %s as %s", source, blob_name)
+        return queued
 
-            # Save to DB
-            try:
-                self.db_service.save_transactions(transactions)
-            except Exception as e:  # pylint: disable=broad-exception-caught
-                logging.error("Failed to save transactions to DB: %s", e)
+    def _queue_collected_file(
+        self, blob_name: str, content: bytes, uploaded_by: str, provenance: dict
+    ) -> bool:
+        """
+        Uploads a statement collected from somewhere other than the upload endpoint,
+        tracks it with where it came from, and queues it for processing. Files are
+        deduped on a hash of their content, so the same statement collected twice
+        is only imported once; one that was interrupted before it was queued is
+        queued again under its first upload's name. Returns whether it was queued.
+        """
+        content_hash = hashlib.sha256(content).hexdigest()
+        source = provenance.get("path") or provenance.get("attachment") or ""
+        collected = self.db_service.get_collected_file(content_hash)
+        if collected and collected["status"] == "queued":
+            logging.info(
+                "Skipping %s: the same file was queued as %s",
+                source,
+                collected["blobName"],
+            )
+            return False
+        if collected:
+            blob_name = collected["blobName"]
+        else:
+            self.db_service.record_collected_file(
+                content_hash, blob_name, source, "pending"
+            )
 
-            # Email
-            group = Group(members)
-            group.add_transactions(transactions)
+        self.blob_service.upload_csv(blob_name, content)
+        file_format = statement.detect_format(blob_name, content)
+        self.db_service.record_upload(
+            blob_name, file_format, uploaded_by, provenance=provenance
+        )
+        # Collected files are bulk imports, so they don't hold up interactive uploads
+        self.queue_service.enqueue_message(
+            {"blob_name": blob_name, "format": file_format},
+            priority=services.QueuePriority.BACKFILL,
+        )
+        self.db_service.record_collected_file(
+            content_hash, blob_name, source, "queued"
+        )
+        return True
 
-            if not any(p.transactions for p in group.members):
-                logging.warning("No valid transactions found for configured accounts.")
-                return
+    def handle_connectors(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Lists the pull connectors with the outcome of each one's latest pull."""
+        logging.info("Processing connectors request.")
 
-            body = self.email_renderer.render_body(group, errors=errors)
-            subject = self.email_renderer.render_subject(group)
-            recipients = [p.email for p in group.members]
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
 
-            self.email_service.send_email(recipients, subject, body)
+        try:
+            statuses = self.db_service.get_connector_statuses()
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "connectors": [
+                            {**c.to_json(), "lastPull": statuses.get(c.name)}
+                            for c in self.db_service.get_connectors()
+                        ]
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in connectors handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
 
-            logging.info("Processing complete for %s", blob_name)
+    def handle_connector(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Creates or replaces (PUT) or removes (DELETE) a pull connector."""
+        logging.info("Processing connector %s request.", req.method)
 
-        except Exception as e:
-            logging.error("Error processing queue item: %s", e)
-            # Raising exception ensures the message goes to poison queue after retries
-            raise
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
 
-    def _handle_savings_get(
-        self, _: func.HttpRequest, month: str, user_email: str
-    ) -> func.HttpResponse:
-        """Helper for GET savings request."""
-        data = self.db_service.get_savings(month, user_email)
-        if data is None:
-            return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+        name = req.route_params.get("name", "")
+        req_body = {}
+        if req.method == "PUT":
+            if not re.match(CONNECTOR_NAME_PATTERN, name):
+                return self._validation_error(
+                    [FieldError("name", f"must match {CONNECTOR_NAME_PATTERN}")]
+                )
+            try:
+                req_body = req.get_json()
+            except ValueError:
+                return func.HttpResponse(
+                    "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                )
 
-        return func.HttpResponse(
-            json.dumps(data),
-            mimetype="application/json",
-            status_code=HTTPStatus.OK,
-        )
+            errors = CONNECTOR_BODY.validate(req_body) or _check_connector(req_body)
+            if errors:
+                return self._validation_error(errors)
 
-    def _handle_savings_post(
-        self, req: func.HttpRequest, month: str, user_email: str
-    ) -> func.HttpResponse:
-        """Helper for POST savings request."""
         try:
-            req_body = req.get_json()
-        except ValueError:
-            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
-
-        target_month = req_body.get("month", month)
+            if req.method == "DELETE":
+                if not self.db_service.delete_connector(name):
+                    return func.HttpResponse(
+                        "Not Found", status_code=HTTPStatus.NOT_FOUND
+                    )
+                return func.HttpResponse(status_code=HTTPStatus.NO_CONTENT)
 
-        # Basic validation
-        if "startingBalance" not in req_body:
+            connector = connectors.Connector.from_json(name, req_body)
+            self.db_service.save_connector(connector)
             return func.HttpResponse(
-                "Missing required fields", status_code=HTTPStatus.BAD_REQUEST
+                json.dumps(connector.to_json()),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in connector handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
             )
 
-        self.db_service.save_savings(target_month, req_body, user_email)
-        return func.HttpResponse("Saved successfully", status_code=HTTPStatus.OK)
+    def run_connector_pulls(self) -> None:
+        """
+        Timer Trigger handler. Pulls new statement files from each connector whose
+        interval has passed since its last pull, queueing them for processing. A
+        connector that fails is recorded as failed and retried next interval.
+        """
+        try:
+            statuses = self.db_service.get_connector_statuses()
+            due = [
+                c
+                for c in self.db_service.get_connectors()
+                if self._connector_due(c, statuses.get(c.name), datetime.now())
+            ]
+        except Exception as e:
+            logging.error("Error listing connectors: %s", e)
+            raise
 
-    def handle_savings_dbrequest(self, req: func.HttpRequest) -> func.HttpResponse:
-        """Handles getting and updating savings calculation data."""
-        logging.info("Processing savings request.")
+        for connector in due:
+            try:
+                pulled = self._pull_connector(connector)
+            except Exception as e:  # pylint: disable=broad-exception-caught
+                logging.error("Connector %s failed: %s", connector.name, e)
+                self.db_service.set_connector_status(
+                    connector.name, "failed", 0, str(e)
+                )
+                continue
+            self.db_service.set_connector_status(connector.name, "succeeded", pulled)
+            logging.info("Connector %s pulled %d files", connector.name, pulled)
+
+    @staticmethod
+    def _connector_due(
+        connector: connectors.Connector | Mailbox, status: dict | None, now: datetime
+    ) -> bool:
+        """Whether a connector has never run or its interval has passed."""
+        if not status:
+            return True
+        last_run = datetime.fromisoformat(status["lastRunAt"])
+        return now - last_run >= timedelta(minutes=connector.interval_minutes)
+
+    def _pull_connector(self, connector: connectors.Connector) -> int:
+        """
+        Queues the statement files in a connector's folder it hasn't pulled before.
+        Each is recorded as pulled once queued. Returns how many were pulled.
+        """
+        secret = self.secret_provider.get(connector.secret_name)
+        pulled = self.db_service.get_pulled_files(connector.name)
+        count = 0
+        with connectors.open_session(connector, secret) as session:
+            for remote in session.list_files():
+                key = connectors.file_key(remote)
+                if key in pulled or not remote.path.lower().endswith(
+                    statement.STATEMENT_EXTENSIONS
+                ):
+                    continue
+                if remote.size > MAX_FILE_SIZE:
+                    logging.warning(
+                        "Skipping %s from %s: larger than %dMB",
+                        remote.path,
+                        connector.name,
+                        MAX_FILE_SIZE // 1024 // 1024,
+                    )
+                    continue
+
+                content = session.read(remote.path)
+                now = datetime.now()
+                blob_name = (
+                    f"{now.strftime('%Y%m%d%H%M%S')}_{os.path.basename(remote.path)}"
+                )
+                queued = self._queue_collected_file(
+                    blob_name,
+                    content,
+                    f"connector:{connector.name}",
+                    {
+                        "source": "connector",
+                        "connector": connector.name,
+                        "protocol": connector.protocol,
+                        "host": connector.host,
+                        "path": remote.path,
+                        "size": remote.size,
+                        "lastModified": remote.modified,
+                        "discoveredAt": now.isoformat(),
+                    },
+                )
+                self.db_service.record_pulled_file(
+                    connector.name, key, remote.path, blob_name if queued else ""
+                )
+                count += queued
+        return count
+
+    def handle_mailboxes(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists the mailbox connectors, whether each has been signed in to, and the
+        outcome of each one's latest fetch.
+        """
+        logging.info("Processing mailboxes request.")
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            statuses = self.db_service.get_connector_statuses()
+            mailboxes = []
+            for mailbox in self.db_service.get_mailboxes():
+                token = self.db_service.get_mailbox_token(mailbox.name)
+                mailboxes.append(
+                    {
+                        **mailbox.to_json(),
+                        "authorized": token["refreshToken"] is not None,
+                        "authorizedAt": token["authorizedAt"],
+                        "lastFetch": statuses.get(mailbox.status_key),
+                    }
+                )
+            return func.HttpResponse(
+                json.dumps({"mailboxes": mailboxes}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in mailboxes handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_mailbox(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Creates or replaces (PUT) or removes (DELETE) a mailbox connector."""
+        logging.info("Processing mailbox %s request.", req.method)
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        name = req.route_params.get("name", "")
+        req_body = {}
+        if req.method == "PUT":
+            if not re.match(CONNECTOR_NAME_PATTERN, name):
+                return self._validation_error(
+                    [FieldError("name", f"must match {CONNECTOR_NAME_PATTERN}")]
+                )
+            try:
+                req_body = req.get_json()
+            except ValueError:
+                return func.HttpResponse(
+                    "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                )
+
+            errors = MAILBOX_BODY.validate(req_body) or _check_mailbox(req_body)
+            if errors:
+                return self._validation_error(errors)
+
+        try:
+            if req.method == "DELETE":
+                if not self.db_service.delete_mailbox(name):
+                    return func.HttpResponse(
+                        "Not Found", status_code=HTTPStatus.NOT_FOUND
+                    )
+                return func.HttpResponse(status_code=HTTPStatus.NO_CONTENT)
+
+            mailbox = Mailbox.from_json(name, req_body)
+            self.db_service.save_mailbox(mailbox)
+            return func.HttpResponse(
+                json.dumps(mailbox.to_json()),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in mailbox handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _find_mailbox(self, name: str) -> Mailbox | None:
+        """The mailbox connector with a name, if there is one."""
+        return next(
+            (m for m in self.db_service.get_mailboxes() if m.name == name), None
+        )
+
+    @staticmethod
+    def _mailbox_redirect_url(req: func.HttpRequest) -> str:
+        """
+        Where the mail provider sends the admin back after signing in. It must be
+        registered with the OAuth app, so it can be pinned with MAILBOX_REDIRECT_URL.
+        """
+        configured = os.environ.get("MAILBOX_REDIRECT_URL")
+        if configured:
+            return configured
+        url = urlsplit(req.url)
+        return f"{url.scheme}://{url.netloc}/api/manage/mailbox-callback"
+
+    def handle_mailbox_authorize(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Starts signing in to a mailbox: redirects the admin to the mail provider
+        to grant read access, with a one-time state the callback checks.
+        """
+        logging.info("Processing mailbox authorize request.")
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            mailbox = self._find_mailbox(req.route_params.get("name", ""))
+            if mailbox is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            state = f"{mailbox.name}.{secrets.token_urlsafe(32)}"
+            self.db_service.set_mailbox_auth_state(mailbox.name, state)
+            location = MAIL_PROVIDERS[mailbox.provider].authorize_url(
+                mailbox, self._mailbox_redirect_url(req), state
+            )
+            return func.HttpResponse(
+                status_code=HTTPStatus.FOUND, headers={"Location": location}
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in mailbox authorize handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_mailbox_callback(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Finishes signing in to a mailbox: checks the state matches the sign-in that
+        was started, then trades the authorization code for a refresh token.
+        """
+        logging.info("Processing mailbox callback request.")
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        if req.params.get("error"):
+            reason = req.params.get("error_description") or req.params["error"]
+            return func.HttpResponse(
+                f"Sign-in failed: {reason}", status_code=HTTPStatus.BAD_REQUEST
+            )
+        state = req.params.get("state", "")
+        code = req.params.get("code")
+        name = state.split(".", 1)[0]
+        try:
+            pending = self.db_service.get_mailbox_token(name)["authState"]
+            mailbox = self._find_mailbox(name)
+            if (
+                not code
+                or mailbox is None
+                or not pending
+                or not hmac.compare_digest(pending, state)
+            ):
+                return func.HttpResponse(
+                    "Invalid or expired sign-in", status_code=HTTPStatus.BAD_REQUEST
+                )
+
+            tokens = MAIL_PROVIDERS[mailbox.provider].redeem(
+                mailbox,
+                self.secret_provider.get(mailbox.secret_name),
+                {
+                    "grant_type": "authorization_code",
+                    "code": code,
+                    "redirect_uri": self._mailbox_redirect_url(req),
+                },
+            )
+            if not tokens.get("refresh_token"):
+                return func.HttpResponse(
+                    "The provider didn't grant offline access",
+                    status_code=HTTPStatus.BAD_REQUEST,
+                )
+            self._save_mailbox_token(mailbox.name, tokens["refresh_token"])
+            return func.HttpResponse(
+                f"Mailbox {mailbox.name} connected. You can close this window.",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in mailbox callback handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _save_mailbox_token(self, name: str, refresh_token: str) -> None:
+        """Stores a mailbox's refresh token, encrypted with the backup key."""
+        self.db_service.save_mailbox_token(
+            name, backups.seal(self._backup_key(), f"mailbox:{name}", refresh_token)
+        )
+
+    def _mailbox_refresh_token(self, name: str) -> str:
+        """
+        A mailbox's decrypted refresh token. Raises ValueError if nobody has
+        signed in to it, or if it was stored under a backup key since rotated,
+        which takes signing in again.
+        """
+        sealed = self.db_service.get_mailbox_token(name)["refreshToken"]
+        if not sealed:
+            raise ValueError("The mailbox hasn't been signed in to")
+        try:
+            return backups.unseal(self._backup_key(), f"mailbox:{name}", sealed)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            raise ValueError(
+                "The mailbox's sign-in can't be decrypted with the backup key; "
+                "sign in to it again"
+            ) from e
+
+    def run_mailbox_fetches(self) -> None:
+        """
+        Timer Trigger handler. Fetches statement attachments from each signed-in
+        mailbox whose interval has passed since its last fetch, queueing them for
+        processing. A mailbox that fails is recorded as failed and retried next
+        interval.
+        """
+        try:
+            statuses = self.db_service.get_connector_statuses()
+            due = [
+                m
+                for m in self.db_service.get_mailboxes()
+                if self._connector_due(m, statuses.get(m.status_key), datetime.now())
+            ]
+        except Exception as e:
+            logging.error("Error listing mailboxes: %s", e)
+            raise
+
+        for mailbox in due:
+            try:
+                fetched = self._fetch_mailbox(mailbox)
+            except Exception as e:  # pylint: disable=broad-exception-caught
+                logging.error("Mailbox %s failed: %s", mailbox.name, e)
+                self.db_service.set_connector_status(
+                    mailbox.status_key, "failed", 0, str(e)
+                )
+                continue
+            self.db_service.set_connector_status(
+                mailbox.status_key, "succeeded", fetched
+            )
+            logging.info("Mailbox %s fetched %d files", mailbox.name, fetched)
+
+    def _fetch_mailbox(self, mailbox: Mailbox) -> int:
+        """
+        Queues the statement attachments of recent emails matching a mailbox's rules
+        that it hasn't fetched before. Each message is recorded as fetched once its
+        attachments are queued. Returns how many attachments were queued.
+        """
+        refresh_token = self._mailbox_refresh_token(mailbox.name)
+
+        provider = MAIL_PROVIDERS[mailbox.provider]
+        tokens = provider.redeem(
+            mailbox,
+            self.secret_provider.get(mailbox.secret_name),
+            {"grant_type": "refresh_token", "refresh_token": refresh_token},
+        )
+        # Providers may rotate the refresh token; the old one stops working
+        if tokens.get("refresh_token", refresh_token) != refresh_token:
+            self._save_mailbox_token(mailbox.name, tokens["refresh_token"])
+        access_token = tokens["access_token"]
+
+        lookback = int(
+            os.environ.get("MAILBOX_LOOKBACK_DAYS", DEFAULT_MAILBOX_LOOKBACK_DAYS)
+        )
+        since = datetime.now().astimezone() - timedelta(days=lookback)
+        fetched = self.db_service.get_pulled_files(mailbox.status_key)
+        count = 0
+        for message in provider.messages(access_token, since):
+            key = message_key(message.id)
+            if key in fetched or not mailbox.matches(message):
+                continue
+
+            blob_names = []
+            for file_name, content in provider.attachments(access_token, message.id):
+                if not file_name.lower().endswith(statement.STATEMENT_EXTENSIONS):
+                    continue
+                if len(content) > MAX_FILE_SIZE:
+                    logging.warning(
+                        "Skipping %s from %s: larger than %dMB",
+                        file_name,
+                        mailbox.name,
+                        MAX_FILE_SIZE // 1024 // 1024,
+                    )
+                    continue
+
+                now = datetime.now()
+                # Attachment names are chosen by the sender, so keep them to safe
+                # characters
+                safe_name = re.sub(r"[^\w.-]", "_", os.path.basename(file_name))
+                blob_name = f"{now.strftime('%Y%m%d%H%M%S')}_{safe_name}"
+                queued = self._queue_collected_file(
+                    blob_name,
+                    content,
+                    f"mailbox:{mailbox.name}",
+                    {
+                        "source": "mailbox",
+                        "mailbox": mailbox.name,
+                        "provider": mailbox.provider,
+                        "messageId": message.id,
+                        "from": message.sender,
+                        "subject": message.subject,
+                        "received": message.received,
+                        "attachment": file_name,
+                        "discoveredAt": now.isoformat(),
+                    },
+                )
+                if queued:
+                    blob_names.append(blob_name)
+
+            self.db_service.record_pulled_file(
+                mailbox.status_key, key, message.subject, ",".join(blob_names)
+            )
+            count += len(blob_names)
+        return count
+
+    def process_queue_item(self, msg: func.QueueMessage) -> None:
+        """
+        Queue Trigger handler. Downloads the upload, analyzes it, saves to DB, and emails
+        summary. Messages queued before formats were recorded are treated by extension.
+        Messages with the apply_rules task re-categorize stored months instead, and
+        those with the dr_rehearsal task rehearse restoring a backup.
+        Each upload's status is tracked as it goes, and uploads that fail their last
+        attempt are recorded so they can be retried.
+        """
+        data = None
+        try:
+            message_body = msg.get_body().decode("utf-8")
+            logging.info("Processing queue item: %s", msg.id)
+            logging.debug("Queue item body: %s", message_body)
+
+            data = self.queue_service.load_message(message_body)
+            if data.get("task") == APPLY_RULES_TASK:
+                self._apply_rules_to_months(
+                    data.get("months") or [], bool(data.get("override"))
+                )
+            elif data.get("task") == DR_REHEARSAL_TASK:
+                self._rehearse_backup(
+                    data["snapshot"],
+                    data["requestedBy"],
+                    datetime.fromisoformat(data["startedAt"]),
+                )
+            else:
+                blob_name = data.get("blob_name")
+
+                if not blob_name:
+                    logging.error("Invalid message: missing blob_name")
+                    return
+
+                self._set_upload_status(blob_name, "processing")
+                rows, errors = self._process_upload(blob_name, data.get("format"))
+                self._set_upload_status(
+                    blob_name,
+                    "failed" if errors and not rows else "completed",
+                    rows_imported=rows,
+                    rows_skipped=len(errors),
+                    errors=errors,
+                )
+
+            if data.get(services.FAILURE_ID_KEY):
+                self.db_service.set_failure_status(
+                    data[services.FAILURE_ID_KEY], "succeeded"
+                )
+
+            # Only release a claim-checked payload once processing has succeeded
+            self.queue_service.discard_message(message_body)
+
+        except Exception as e:
+            logging.error("Error processing queue item: %s", e)
+            self._record_queue_failure(msg, data, e)
+            # Raising exception ensures the message goes to poison queue after retries
+            raise
+
+    def _record_queue_failure(
+        self, msg: func.QueueMessage, data: dict | None, error: Exception
+    ) -> None:
+        """
+        Records an upload that failed its last attempt, keyed by message ID, so it
+        can be retried. A retried upload's existing record is marked failed instead.
+        Earlier attempts leave the upload queued for the host's next try.
+        """
+        if not isinstance(data, dict) or not data.get("blob_name"):
+            return
+
+        final = msg.dequeue_count >= MAX_DEQUEUE_COUNT
+        self._set_upload_status(
+            data["blob_name"], "failed" if final else "queued", errors=[str(error)]
+        )
+        if not final:
+            return
+
+        try:
+            failure_id = data.get(services.FAILURE_ID_KEY)
+            if failure_id:
+                self.db_service.set_failure_status(failure_id, "failed", str(error))
+            else:
+                self.db_service.record_failure(msg.id, data, str(error))
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to record queue failure: %s", e)
+
+    def _set_upload_status(self, blob_name: str, status: str, **fields) -> None:
+        """
+        Updates an upload's tracked status. Tracking is informational, so a failure
+        is logged rather than failing the processing it describes.
+        """
+        try:
+            self.db_service.set_upload_status(blob_name, status, **fields)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to mark upload %s %s: %s", blob_name, status, e)
+
+    def handle_failures(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Lists uploads that failed processing, most recent first."""
+        logging.info("Processing failures request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            return func.HttpResponse(
+                json.dumps({"failures": self.db_service.get_failures()}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in failures handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_failure_retry(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Re-enqueues a failed upload for processing from its stored blob. Its record
+        moves to retried, then to succeeded or failed once the retry runs.
+        """
+        logging.info("Processing failure retry request.")
 
         user_email = self._get_user_email(req)
         if not user_email:
@@ -241,26 +2013,6567 @@ class Controller:
             )
 
         try:
-            # Default month to current month if not provided
-            current_month = datetime.now().strftime("%Y-%m")
-            month = req.params.get("month", current_month)
+            failure_id = req.route_params.get("id", "")
+            failure = self.db_service.get_failure(failure_id)
+            if failure is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+            if failure["status"] not in RETRYABLE_STATUSES:
+                return func.HttpResponse(
+                    f"Upload is already {failure['status']}",
+                    status_code=HTTPStatus.CONFLICT,
+                )
 
-            if req.method == "GET":
-                return self._handle_savings_get(req, month, user_email)
+            message = {
+                k: v
+                for k, v in failure["message"].items()
+                if k != services.FAILURE_ID_KEY
+            }
+            self.queue_service.resubmit(message, failure_id)
+            self.db_service.set_failure_status(failure_id, "retried")
+            self._set_upload_status(message.get("blob_name"), "queued", errors=[])
+            logging.info(
+                "%s retried failed upload %s", user_email, message.get("blob_name")
+            )
 
-            if req.method == "POST":
-                return self._handle_savings_post(req, month, user_email)
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "id": failure_id,
+                        "status": "retried",
+                        "blobName": message.get("blob_name"),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.ACCEPTED,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in failure retry handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_alerts(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Lists the alerts sent to the caller, most recently sent first."""
+        logging.info("Processing alerts request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
 
+        try:
+            sent = [
+                a
+                for a in self.db_service.get_alerts()
+                if user_email.lower() in (r.lower() for r in a["recipients"])
+            ]
+            return func.HttpResponse(
+                json.dumps({"alerts": sent}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
         except Exception as e:  # pylint: disable=broad-exception-caught
-            logging.error("Error in savings handler: %s", e)
+            logging.error("Error in alerts handler: %s", e)
             return func.HttpResponse(
                 f"Internal Error: {str(e)}",
                 status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
             )
 
-        return func.HttpResponse(
-            "Method not supported", status_code=HTTPStatus.METHOD_NOT_ALLOWED
+    def handle_alert(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Acknowledges an alert sent to the caller: snoozed until a date, dismissed
+        for good, or reopened as new. The nightly checks don't send snoozed or
+        dismissed alerts again.
+        """
+        logging.info("Processing alert request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = ALERT_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        status = req_body["status"]
+        until = req_body.get("snoozedUntil")
+        if status == "snoozed":
+            if not until:
+                errors = [FieldError("snoozedUntil", "is required to snooze")]
+            elif until <= datetime.now().date().isoformat():
+                errors = [FieldError("snoozedUntil", "must be in the future")]
+        elif until:
+            errors = [FieldError("snoozedUntil", "is only allowed when snoozing")]
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            alert_id = req.route_params.get("id", "")
+            alert = self.db_service.get_alert(alert_id)
+            if alert is None or user_email.lower() not in (
+                r.lower() for r in alert["recipients"]
+            ):
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            self.db_service.set_alert_status(alert_id, status, until, user_email)
+            logging.info("%s marked alert %s %s", user_email, alert_id, status)
+
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        **alert,
+                        "status": status,
+                        "snoozedUntil": until,
+                        "updatedBy": user_email,
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in alert handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _document_json(self, document: documents.Document) -> dict:
+        """A document for the API, with a download link that expires."""
+        minutes = int(
+            os.environ.get("DOCUMENT_LINK_MINUTES", DEFAULT_DOCUMENT_LINK_MINUTES)
         )
+        return {
+            **document.to_json(),
+            "downloadUrl": self.blob_service.download_url(
+                services.BlobKind.DOCUMENTS,
+                document.blob_name,
+                timedelta(minutes=minutes),
+                document.file_name,
+                document.content_type,
+            ),
+            "expiresAt": (datetime.now() + timedelta(minutes=minutes)).isoformat(),
+        }
+
+    def handle_documents(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists a month's documents (GET), optionally for one account, with download
+        links that expire; or files a document against a month (POST).
+        """
+        logging.info("Processing documents %s request.", req.method)
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        schema = DOCUMENT_UPLOAD_PARAMS if req.method == "POST" else DOCUMENTS_PARAMS
+        errors = schema.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+        month = req.params["month"]
+        account = req.params.get("accountNumber")
+        account_number = None if account in (None, "") else int(account)
+
+        try:
+            if req.method == "GET":
+                return func.HttpResponse(
+                    json.dumps(
+                        {
+                            "month": month,
+                            "documents": [
+                                self._document_json(d)
+                                for d in self.db_service.get_documents(
+                                    month, account_number
+                                )
+                            ],
+                        }
+                    ),
+                    mimetype="application/json",
+                    status_code=HTTPStatus.OK,
+                )
+
+            file_name, content, error_resp = self._get_uploaded_file_content(req)
+            if error_resp:
+                return error_resp
+            file_name = os.path.basename(file_name)
+            file_type = documents.content_type(file_name)
+            if file_type is None:
+                return self._validation_error(
+                    [
+                        FieldError(
+                            "file",
+                            "must be one of: " + ", ".join(documents.DOCUMENT_TYPES),
+                        )
+                    ]
+                )
+
+            document_id = uuid.uuid4().hex
+            document = documents.Document(
+                document_id=document_id,
+                month=month,
+                file_name=file_name,
+                content_type=file_type,
+                size=len(content),
+                blob_name=documents.blob_name(month, document_id, file_name),
+                uploaded_by=user_email,
+                uploaded_at=datetime.now().isoformat(),
+                account_number=account_number,
+                description=req.params.get("description") or None,
+            )
+            self.blob_service.upload_blob(
+                services.BlobKind.DOCUMENTS, document.blob_name, content
+            )
+            self.db_service.save_document(document)
+            self._record_activity(
+                "document",
+                f"Filed {file_name} for {month}",
+                user_email,
+                {"documentId": document_id, "accountNumber": account_number},
+            )
+            return func.HttpResponse(
+                json.dumps(self._document_json(document)),
+                mimetype="application/json",
+                status_code=HTTPStatus.CREATED,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in documents handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_document(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Returns a document with a fresh download link (GET), or deletes it with its
+        file (DELETE). Only whoever filed a document, or an admin, may delete it.
+        """
+        logging.info("Processing document %s request.", req.method)
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        document_id = req.route_params.get("id", "")
+        if not re.match(DOCUMENT_ID_PATTERN, document_id):
+            return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+        try:
+            document = self.db_service.get_document(document_id)
+            if document is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            if req.method == "GET":
+                return func.HttpResponse(
+                    json.dumps(self._document_json(document)),
+                    mimetype="application/json",
+                    status_code=HTTPStatus.OK,
+                )
+
+            if (
+                document.uploaded_by.lower() != user_email.lower()
+                and not self._is_admin(user_email)
+            ):
+                return func.HttpResponse(
+                    "Forbidden", status_code=HTTPStatus.FORBIDDEN
+                )
+            self.blob_service.delete_blob(
+                document.blob_name, services.BlobKind.DOCUMENTS
+            )
+            self.db_service.delete_document(document)
+            self._record_activity(
+                "document",
+                f"Deleted {document.file_name} from {document.month}",
+                user_email,
+                {"documentId": document_id},
+            )
+            return func.HttpResponse(status_code=HTTPStatus.NO_CONTENT)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in document handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_uploads(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists recent uploads, newest first, with each one's processing status: queued,
+        processing, completed or failed, the rows imported and skipped, and errors.
+        """
+        logging.info("Processing uploads request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = UPLOADS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            limit = min(
+                int(req.params.get("limit", DEFAULT_UPLOADS_LIMIT)), MAX_UPLOADS_LIMIT
+            )
+            return func.HttpResponse(
+                json.dumps({"uploads": self.db_service.get_uploads(limit)}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in uploads handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_upload_status(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Returns one upload's processing status, for polling after an upload."""
+        logging.info("Processing upload status request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            upload = self.db_service.get_upload(req.route_params.get("blobName", ""))
+            if upload is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+            return func.HttpResponse(
+                json.dumps(upload),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in upload status handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_upload_reprocess(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Parses a processed upload's stored file again and re-runs the category
+        rules over it, e.g. after improving the rules or fixing a parser. Only the
+        categories of its stored transactions are updated: rows that weren't
+        imported the first time aren't added, and no balances, ledger entries or
+        emails change. Categories set by hand are kept unless override=true.
+        """
+        logging.info("Processing upload reprocess request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = REPROCESS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+        override = str(req.params.get("override", "false")).lower() == "true"
+
+        try:
+            blob_name = req.route_params.get("blobName", "")
+            upload = self.db_service.get_upload(blob_name)
+            if upload is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+            if upload["status"] != "completed":
+                return func.HttpResponse(
+                    f"Upload is {upload['status']}, not completed",
+                    status_code=HTTPStatus.CONFLICT,
+                )
+
+            content = self.blob_service.download_blob(
+                services.BlobKind.UPLOADS, blob_name
+            )
+            file_format = upload["format"] or statement.detect_format(
+                blob_name, content
+            )
+            transactions, errors = statement.parse(
+            file_format, content, blob_name, self.db_service.category_names()
+        )
+            # Converted as on import, so rules matching on amounts see the same ones
+            transactions, rate_errors = self.exchange_rates.normalize(transactions)
+            transactions = apply_rules(self.db_service.get_rules(), transactions)
+            result = self.db_service.recategorize_transactions(
+                transactions, override
+            )
+
+            self._record_activity(
+                "import",
+                f"Re-categorized {result['updated']} transactions from {blob_name}",
+                user_email,
+                {"file": blob_name, **result},
+            )
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "blobName": blob_name,
+                        "parsed": len(transactions),
+                        **result,
+                        "errors": errors + rate_errors,
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in upload reprocess handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _process_upload(
+        self, blob_name: str, file_format: str | None = None
+    ) -> tuple[int, list[str]]:
+        """
+        Imports an uploaded statement once. The processing log is keyed on the blob
+        name and a hash of its content, so a redelivered message for a blob that
+        was already processed is skipped rather than importing and emailing again.
+        A delivery that failed part-way was never logged completed and runs again,
+        skipping the summary emails it already sent.
+        Messages queued before formats were recorded have the format detected.
+        Returns the number of transactions imported and the errors for the rows
+        that were skipped.
+        """
+        content = self.blob_service.download_blob(services.BlobKind.UPLOADS, blob_name)
+        content_hash = hashlib.sha256(content).hexdigest()
+        file_format = file_format or statement.detect_format(blob_name, content)
+
+        processed = self.db_service.get_processing_log(blob_name, content_hash)
+        if processed and processed["status"] == "completed":
+            logging.info("Skipping duplicate delivery of %s", blob_name)
+            return processed["rowsImported"] or 0, processed["errors"]
+
+        self.db_service.log_processing(blob_name, content_hash, "processing")
+        rows, errors = self._import_upload(
+            blob_name,
+            content,
+            content_hash,
+            file_format,
+            sent_emails=(processed or {}).get("sentEmails") or {},
+        )
+        try:
+            self.db_service.log_processing(
+                blob_name, content_hash, "completed", rows, errors
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to log %s as processed: %s", blob_name, e)
+        return rows, errors
+
+    def _import_upload(
+        self,
+        blob_name: str,
+        content: bytes,
+        content_hash: str,
+        file_format: str,
+        sent_emails: dict[str, str] | None = None,
+    ) -> tuple[int, list[str]]:
+        """
+        Analyzes an uploaded statement, saves its transactions, and emails the
+        summary. Each summary sent is logged against the upload, and members in
+        sent_emails, who got theirs on an earlier attempt, aren't emailed again.
+        Returns the number of transactions imported and the errors for the rows
+        that were skipped.
+        """
+        transactions, errors = statement.parse(
+            file_format, content, blob_name, self.db_service.category_names()
+        )
+
+        # Convert foreign currency amounts so everything adds up in one currency
+        transactions, rate_errors = self.exchange_rates.normalize(transactions)
+        errors += rate_errors
+
+        # Categorize with the household's rules before anything is saved
+        try:
+            transactions = apply_rules(self.db_service.get_rules(), transactions)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to apply category rules: %s", e)
+
+        # Retrieve People from DB
+        people_data = self.db_service.get_all_people()
+        members = [self._person(p) for p in people_data]
+
+        if errors and len(transactions) == 0:
+            logging.error("CSV Validation Errors: %s", errors)
+
+            # Send Error Email
+            recipients = [p.email for p in members]
+            self.email_service.send_error_email(recipients, errors)
+            return 0, errors
+
+        # Save to DB
+        try:
+            self.db_service.save_transactions(transactions)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to save transactions to DB: %s", e)
+        else:
+            self._record_activity(
+                "import",
+                f"Imported {len(transactions)} transactions from {blob_name}",
+                details={"file": blob_name, "count": len(transactions)},
+            )
+            self._record_account_imports(people_data, transactions)
+
+        # Email
+        try:
+            transactions = self.db_service.apply_owner_overrides(transactions)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to apply owner overrides: %s", e)
+
+        group = Group(members, self._debt_excluded_categories())
+        group.add_transactions(transactions)
+
+        if not any(p.transactions for p in group.members):
+            logging.warning("No valid transactions found for configured accounts.")
+            return len(transactions), errors
+
+        outstanding = None
+        if len(group.members) == 2:
+            entry_id = self._record_import_debt(group, blob_name)
+            if entry_id:
+                self.db_service.log_processing(
+                    blob_name, content_hash, "processing", ledger_entry_id=entry_id
+                )
+            outstanding = self._outstanding_balance()
+
+        # Each member gets their own personalized copy
+        sent = dict(sent_emails or {})
+
+        def record_sent(to: list[str], message_id: str | None) -> None:
+            sent.update({email: message_id or "" for email in to})
+            self.db_service.log_processing(
+                blob_name, content_hash, "processing", sent_emails=sent
+            )
+
+        subject = self.email_renderer.render_subject(group)
+        self.email_service.send_emails(
+            [
+                (
+                    [p.email],
+                    subject,
+                    self.email_renderer.render_body(
+                        group, errors=errors, recipient=p, outstanding=outstanding
+                    ),
+                )
+                for p in group.members
+                if p.email not in sent
+            ],
+            self._summary_attachments(blob_name, content, file_format, transactions),
+            on_delivered=record_sent,
+        )
+        self._suggest_savings_transfer(transactions, group.members)
+
+        logging.info("Processing complete for %s", blob_name)
+        return len(transactions), errors
+
+    def _suggest_savings_transfer(
+        self, transactions: list[Transaction], members: list[Person]
+    ) -> None:
+        """
+        Emails the members a transfer to savings: the SavingsSuggestionShare of
+        what's left of the discretionary budgets of the newest month imported,
+        pro-rated by how much of the month is gone, with a link to a page that
+        records it. One is suggested a month at most, by the first import that
+        leaves something over. A share of 0 turns suggestions off.
+        """
+        if not transactions:
+            return
+        month = max(t.date for t in transactions).strftime("%Y-%m")
+        try:
+            settings = self.db_service.get_settings()
+            share = Decimal(
+                settings.get(
+                    "SavingsSuggestionShare",
+                    DEFAULT_SETTINGS["SavingsSuggestionShare"],
+                )
+            )
+            budgets = self.db_service.get_budgets()
+            if share <= 0 or not budgets:
+                return
+
+            spend: dict[AnyCategory, Decimal] = {}
+            for row in self.db_service.get_spending_totals(month):
+                category = self._category(row["Category"])
+                spend[category] = spend.get(category, Decimal("0.00")) + row["Total"]
+            leftover = discretionary_leftover(
+                budgets,
+                spend,
+                self._essential_categories(),
+                month_elapsed(month, datetime.now().date()),
+            )
+            amount = suggested_transfer(leftover, share)
+
+            suggestions = json.loads(settings.get("SavingsSuggestions", "{}"))
+            if amount <= 0 or month in suggestions:
+                return
+            suggestions[month] = {"amount": str(amount)}
+            suggestions = dict(sorted(suggestions.items())[-SAVINGS_SUGGESTION_MONTHS:])
+            self.db_service.save_setting("SavingsSuggestions", json.dumps(suggestions))
+
+            self._send_reminder(
+                [p.email for p in members],
+                f"Move ${amount} to savings?",
+                self.email_renderer.render_savings_suggestion(
+                    month,
+                    float(leftover),
+                    float(amount),
+                    self._savings_approve_url(month, amount),
+                ),
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to suggest a savings transfer: %s", e)
+
+    @staticmethod
+    def _savings_approve_url(month: str, amount: Decimal) -> str | None:
+        """
+        The signed link for a savings suggestion, if signing is set up. It opens
+        a page that approves it once the member confirms, so a mail scanner
+        following the link approves nothing.
+        """
+        suggestion_id = f"{month}_{amount}"
+        signature = _sign_reminder(suggestion_id)
+        if signature is None:
+            return None
+        app_url = os.environ.get("APP_URL", "")
+        query = f"action=savings&id={suggestion_id}&sig={signature}"
+        return f"{app_url}/confirm.html?{query}"
+
+    def _record_account_imports(
+        self, people_data: list[dict], transactions: list[Transaction]
+    ) -> None:
+        """
+        Advances each member's synced accounts' LastImportedDate to the newest
+        imported transaction on the account. This says nothing about whether the
+        balance is right; that is LastReconciledDate, set by reconciling.
+        """
+        for person in people_data:
+            try:
+                for account in self.db_service.get_accounts(person["Email"]):
+                    if not account.get("mask"):
+                        continue
+                    dates = [
+                        t.date
+                        for t in transactions
+                        if t.account_number == int(account["mask"])
+                    ]
+                    if dates:
+                        self.db_service.record_account_import(
+                            person["Email"], account["accountId"], max(dates)
+                        )
+            except Exception as e:  # pylint: disable=broad-exception-caught
+                logging.error(
+                    "Failed to record imports for %s's accounts: %s", person["Email"], e
+                )
+
+    @staticmethod
+    def _charges(entities: list[dict], sign: int = 1) -> dict[int, Decimal]:
+        """The total amount of transaction entities by account number, times sign."""
+        totals: dict[int, Decimal] = {}
+        for e in entities:
+            account = int(e["AccountNumber"])
+            totals[account] = totals.get(account, Decimal("0")) + sign * Decimal(
+                str(e["Amount"])
+            )
+        return totals
+
+    def _adjust_card_balances(self, changes: dict[int, Decimal]) -> None:
+        """
+        Moves the balance of each member's synced card whose mask is a changed
+        account number by how much the charges on it changed, so correcting or
+        deleting a transaction doesn't leave the card's balance drifted.
+        """
+        changes = {account: amount for account, amount in changes.items() if amount}
+        if not changes:
+            return
+        for person in self.db_service.get_all_people():
+            try:
+                for account in self.db_service.get_accounts(person["Email"]):
+                    mask = account.get("mask") or ""
+                    if mask.isdigit() and int(mask) in changes:
+                        self.db_service.adjust_account_balance(
+                            person["Email"], account["accountId"], changes[int(mask)]
+                        )
+            except Exception as e:  # pylint: disable=broad-exception-caught
+                logging.error(
+                    "Failed to adjust %s's card balances: %s", person["Email"], e
+                )
+
+    def _summary_attachments(
+        self,
+        blob_name: str,
+        content: bytes,
+        file_format: str,
+        transactions: list[Transaction],
+    ) -> list[services.EmailAttachment] | None:
+        """
+        The uploaded statement and a CSV of its categorized transactions, when the
+        SummaryAttachments setting is on. Left off if they'd push the email past
+        the ACS size limit.
+        """
+        if self.db_service.get_settings().get("SummaryAttachments") != "true":
+            return None
+
+        name = os.path.basename(blob_name)
+        stem = os.path.splitext(name)[0]
+        attachments = [
+            services.EmailAttachment(
+                name,
+                statement.get_parser(file_format).content_type,
+                content,
+            ),
+            services.EmailAttachment(
+                f"{stem}-processed.csv",
+                "text/csv",
+                exports.transactions_csv(transactions).encode("utf-8"),
+            ),
+        ]
+        if sum(len(a.content) for a in attachments) > MAX_ATTACHMENT_SIZE:
+            logging.warning("Attachments for %s are too large to send", blob_name)
+            return None
+        return attachments
+
+    def _debt_excluded_categories(self) -> list[AnyCategory]:
+        """Reads the categories excluded from shared debt. Defaults to none."""
+        try:
+            raw = self.db_service.get_settings().get("DebtExcludedCategories", "[]")
+            added = self.db_service.category_names()
+            return [
+                parse_category(c, added)
+                for c in json.loads(raw)
+                if Category.is_valid(c, added)
+            ]
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to read debt exclusions, using none: %s", e)
+            return []
+
+    def _essential_categories(self) -> list[AnyCategory]:
+        """
+        Reads the categories flagged as essential: fixed obligations that still
+        have to be paid in an emergency, as opposed to discretionary spending.
+        """
+        raw = self.db_service.get_settings().get(
+            "EssentialCategories", DEFAULT_SETTINGS["EssentialCategories"]
+        )
+        added = self.db_service.category_names()
+        return [
+            parse_category(c, added)
+            for c in json.loads(raw)
+            if Category.is_valid(c, added)
+        ]
+
+    def _round_up_increments(self) -> dict[str, Decimal]:
+        """Reads the round-up increment per person email. Absent means disabled."""
+        raw = self.db_service.get_settings().get("RoundUpIncrements", "{}")
+        return {email: Decimal(inc) for email, inc in json.loads(raw).items()}
+
+    def _cpi_index(self) -> dict[int, Decimal]:
+        """The built-in CPI table with the household's CpiIndex overrides applied."""
+        raw = self.db_service.get_settings().get("CpiIndex", "{}")
+        return {
+            **CPI_INDEX,
+            **{int(year): Decimal(value) for year, value in json.loads(raw).items()},
+        }
+
+    def handle_settings(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Gets or updates the household settings. Anyone signed in can read them;
+        only admins can change them.
+        """
+        logging.info("Processing settings request.")
+
+        if req.method == "PUT":
+            _, error_resp = self._require_admin(req)
+        elif not self._get_user_email(req):
+            error_resp = func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+        else:
+            error_resp = None
+        if error_resp:
+            return error_resp
+
+        try:
+            if req.method == "PUT":
+                try:
+                    req_body = req.get_json()
+                except ValueError:
+                    return func.HttpResponse(
+                        "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                    )
+
+                errors = SETTINGS_BODY.validate(req_body) or _check_lead_days(
+                    req_body, "paymentReminderLeadDays"
+                )
+                if errors:
+                    return self._validation_error(errors)
+
+                increments = req_body.get("roundUpIncrements") or {}
+                for email, value in increments.items():
+                    message = _check_increment(value)
+                    if message:
+                        errors.append(FieldError(f"roundUpIncrements.{email}", message))
+                cpi_index = req_body.get("cpiIndex") or {}
+                for year, value in cpi_index.items():
+                    message = (
+                        "must be a year"
+                        if not re.fullmatch(r"\d{4}", year)
+                        else _check_cpi_value(value)
+                    )
+                    if message:
+                        errors.append(FieldError(f"cpiIndex.{year}", message))
+                if errors:
+                    return self._validation_error(errors)
+
+                if "debtExcludedCategories" in req_body:
+                    self.db_service.save_setting(
+                        "DebtExcludedCategories",
+                        json.dumps(sorted(set(req_body["debtExcludedCategories"]))),
+                    )
+                if "roundUpIncrements" in req_body:
+                    self.db_service.save_setting(
+                        "RoundUpIncrements",
+                        json.dumps({k.lower(): str(v) for k, v in increments.items()}),
+                    )
+                if "cpiIndex" in req_body:
+                    self.db_service.save_setting(
+                        "CpiIndex",
+                        json.dumps({k: str(v) for k, v in sorted(cpi_index.items())}),
+                    )
+                if "paymentReminderLeadDays" in req_body:
+                    leads = req_body["paymentReminderLeadDays"] or []
+                    self.db_service.save_setting(
+                        "PaymentReminderLeadDays",
+                        json.dumps(sorted({int(lead) for lead in leads}, reverse=True)),
+                    )
+                for field, name in NUMERIC_SETTINGS.items():
+                    if field in req_body:
+                        self.db_service.save_setting(name, str(req_body[field]))
+                if "summaryAttachments" in req_body:
+                    self.db_service.save_setting(
+                        "SummaryAttachments",
+                        str(req_body["summaryAttachments"]).lower(),
+                    )
+
+            settings = self.db_service.get_settings()
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "debtExcludedCategories": [
+                            c.value for c in self._debt_excluded_categories()
+                        ],
+                        "roundUpIncrements": {
+                            email: float(inc)
+                            for email, inc in self._round_up_increments().items()
+                        },
+                        "cpiIndex": {
+                            year: float(value)
+                            for year, value in json.loads(
+                                settings.get("CpiIndex", "{}")
+                            ).items()
+                        },
+                        **{
+                            field: float(settings.get(name, DEFAULT_SETTINGS[name]))
+                            for field, name in NUMERIC_SETTINGS.items()
+                        },
+                        "paymentReminderLeadDays": self._reminder_lead_days(
+                            settings
+                        ),
+                        "summaryAttachments": settings.get("SummaryAttachments")
+                        == "true",
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in settings handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_categories(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists the categories, built-in and added, with their essential flag,
+        color and whether they can be budgeted. Admins can add one (POST), change
+        one's flags and color (PUT), or delete an added one (DELETE ?name=).
+        Anyone signed in can read them.
+        """
+        logging.info("Processing categories %s request.", req.method)
+
+        if req.method in ("POST", "PUT", "DELETE"):
+            _, error_resp = self._require_admin(req)
+        elif not self._get_user_email(req):
+            error_resp = func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+        else:
+            error_resp = None
+        if error_resp:
+            return error_resp
+
+        try:
+            if req.method == "DELETE":
+                return self._delete_category(req)
+
+            if req.method in ("POST", "PUT"):
+                try:
+                    req_body = req.get_json()
+                except ValueError:
+                    return func.HttpResponse(
+                        "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                    )
+
+                if req.method == "POST":
+                    errors = NEW_CATEGORY_BODY.validate(req_body)
+                    errors = errors or self._check_new_category(req_body)
+                else:
+                    errors = CATEGORY_BODY.validate(req_body)
+                if errors:
+                    return self._validation_error(errors)
+
+                name = req_body.get("name") or req_body["category"]
+                if req.method == "POST" and Category.is_valid(
+                    name, self.db_service.category_names()
+                ):
+                    return func.HttpResponse(
+                        f"Category '{name}' already exists",
+                        status_code=HTTPStatus.CONFLICT,
+                    )
+                self._save_category(name, req_body, req.method == "POST")
+
+            return self._categories_response(
+                HTTPStatus.CREATED if req.method == "POST" else HTTPStatus.OK
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in categories handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _check_new_category(self, body: dict) -> list[FieldError]:
+        """Checks an added category's name against those there are."""
+        name = body["name"]
+        if name != name.strip():
+            return [FieldError("name", "must not start or end with a space")]
+        names = category_names(self.db_service.category_names())
+        if name not in names and name.lower() in (n.lower() for n in names):
+            return [FieldError("name", "differs from a category only in case")]
+        return []
+
+    def _save_category(self, name: str, body: dict, added: bool) -> None:
+        """
+        Saves a category's color and budget flag, keeping those the body leaves
+        out, and sets or clears its essential flag if the body has one.
+        """
+        if added or "color" in body or "budgetEligible" in body:
+            stored = next(
+                (c for c in self.db_service.get_categories() if c["name"] == name), {}
+            )
+            self.db_service.save_category(
+                {
+                    "name": name,
+                    "color": body.get("color", stored.get("color")),
+                    "budgetEligible": str(
+                        body.get("budgetEligible", stored.get("budgetEligible", True))
+                    ).lower()
+                    == "true",
+                }
+            )
+        if "essential" not in body:
+            return
+        essential = self._essential_categories()
+        category = self._category(name)
+        if str(body["essential"]).lower() == "true":
+            essential = [c for c in self._categories() if c in essential + [category]]
+        else:
+            essential = [c for c in essential if c != category]
+        self.db_service.save_setting(
+            "EssentialCategories", json.dumps([c.value for c in essential])
+        )
+
+    def _delete_category(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Deletes an added category, unless rules (by category or in their filter),
+        budgets, split shares, category aliases or saved views still use it. Its
+        transactions read as Other from then on.
+        """
+        errors = CATEGORY_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+        category = self._category(req.params["name"])
+        if category.built_in:
+            return self._validation_error(
+                [FieldError("name", "is a built-in category, which can't be deleted")]
+            )
+
+        used_by = []
+        rules = self.db_service.get_rules()
+        if any(
+            r.category == category or _filter_uses(r.filter_expression, category)
+            for r in rules
+        ):
+            used_by.append("rules")
+        if category in self.db_service.get_budgets():
+            used_by.append("budgets")
+        if any(
+            category.value in (p.get("CategoryShares") or {})
+            for p in self.db_service.get_all_people()
+        ):
+            used_by.append("split shares")
+        aliases = category_aliases(self.db_service.category_names())
+        if category in aliases.values():
+            used_by.append("category aliases")
+        if any(
+            _filter_uses(v["filter"], category) for v in self.db_service.get_views()
+        ):
+            used_by.append("saved views")
+        if used_by:
+            return func.HttpResponse(
+                f"Category '{category.value}' is still used by {', '.join(used_by)}",
+                status_code=HTTPStatus.CONFLICT,
+            )
+
+        self.db_service.delete_category(category.value)
+        return self._categories_response(HTTPStatus.OK)
+
+    def _categories_response(self, status: HTTPStatus) -> func.HttpResponse:
+        """Every category with its flags and color."""
+        essential = self._essential_categories()
+        stored = {c["name"]: c for c in self.db_service.get_categories()}
+        return func.HttpResponse(
+            json.dumps(
+                {
+                    "categories": [
+                        {
+                            "name": c.value,
+                            "builtIn": c.built_in,
+                            "essential": c in essential,
+                            "color": stored.get(c.value, {}).get("color"),
+                            "budgetEligible": stored.get(c.value, {}).get(
+                                "budgetEligible", True
+                            ),
+                        }
+                        for c in self._categories()
+                    ]
+                }
+            ),
+            mimetype="application/json",
+            status_code=status,
+        )
+
+    def handle_budgets(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists the monthly category budgets (GET), sets one (PUT), or removes one
+        (DELETE ?category=). Anyone signed in can read them; only admins can
+        change them.
+        """
+        logging.info("Processing budgets %s request.", req.method)
+
+        if req.method in ("PUT", "DELETE"):
+            _, error_resp = self._require_admin(req)
+        elif not self._get_user_email(req):
+            error_resp = func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+        else:
+            error_resp = None
+        if error_resp:
+            return error_resp
+
+        try:
+            if req.method == "PUT":
+                try:
+                    req_body = req.get_json()
+                except ValueError:
+                    return func.HttpResponse(
+                        "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                    )
+
+                errors = BUDGET_BODY.validate(req_body) or self._check_budgetable(
+                    req_body["category"]
+                )
+                if errors:
+                    return self._validation_error(errors)
+
+                self.db_service.save_budget(
+                    self._category(req_body["category"]),
+                    Decimal(str(req_body["limit"])),
+                )
+            elif req.method == "DELETE":
+                errors = BUDGET_PARAMS.validate(req.params)
+                if errors:
+                    return self._validation_error(errors)
+
+                category = self._category(req.params["category"])
+                if not self.db_service.delete_budget(category):
+                    return func.HttpResponse(
+                        "Not Found", status_code=HTTPStatus.NOT_FOUND
+                    )
+
+            budgets = self.db_service.get_budgets()
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "budgets": [
+                            {"category": c.value, "limit": float(budgets[c])}
+                            for c in self._categories()
+                            if c in budgets
+                        ]
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in budgets handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _check_budgetable(self, name: str) -> list[FieldError]:
+        """Checks a category wasn't marked as not to be budgeted."""
+        stored = {c["name"]: c for c in self.db_service.get_categories()}
+        if not stored.get(name, {}).get("budgetEligible", True):
+            return [FieldError("category", "is not budget-eligible")]
+        return []
+
+    def handle_budget_status(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Compares each category budget with the month's (default current) spend,
+        reporting the remaining amount and percent used. Transactions ignored from
+        the budget are left out.
+        """
+        logging.info("Processing budget status request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = SAVINGS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+        month = req.params.get("month") or datetime.now().strftime("%Y-%m")
+
+        try:
+            spend: dict[AnyCategory, Decimal] = {}
+            for row in self.db_service.get_spending_totals(month):
+                category = self._category(row["Category"])
+                spend[category] = spend.get(category, Decimal("0.00")) + row["Total"]
+
+            status = budget_status(self.db_service.get_budgets(), spend)
+            limit = sum(b["limit"] for b in status)
+            spent = sum(b["spent"] for b in status)
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "month": month,
+                        "budgets": status,
+                        "total": {
+                            "limit": round(limit, 2),
+                            "spent": round(spent, 2),
+                            "remaining": round(limit - spent, 2),
+                        },
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in budget status handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_transaction_owner(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Reassigns a stored transaction to another member for debt and report purposes,
+        without changing its account number. A null owner restores account ownership.
+        Only an admin or the transaction's current owner can reassign it.
+        """
+        logging.info("Processing transaction owner request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = OWNER_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            people = self.db_service.get_all_people()
+            owner = req_body.get("owner") or None
+            if owner:
+                emails = {p["Email"].lower() for p in people}
+                if owner.lower() not in emails:
+                    return self._validation_error(
+                        [FieldError("owner", "is not a household member")]
+                    )
+
+            def check(entity: dict) -> None:
+                if self._is_admin(user_email):
+                    return
+                current = (
+                    {entity["Owner"].lower()}
+                    if entity.get("Owner")
+                    else {
+                        p["Email"].lower()
+                        for p in people
+                        if int(entity["AccountNumber"]) in p["Accounts"]
+                    }
+                )
+                if user_email.lower() not in current:
+                    raise PermissionError("not the transaction's owner")
+
+            transaction_id = req.route_params.get("id", "")
+            try:
+                found = self.db_service.set_transaction_owner(
+                    transaction_id, owner, check=check
+                )
+            except PermissionError:
+                return func.HttpResponse("Forbidden", status_code=HTTPStatus.FORBIDDEN)
+            if not found:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            self._record_activity(
+                "edit",
+                f"Assigned a transaction to {owner}"
+                if owner
+                else "Restored a transaction's account owner",
+                user_email,
+                {"transactionId": transaction_id, "owner": owner},
+            )
+
+            return func.HttpResponse(
+                json.dumps({"id": transaction_id, "owner": owner}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in transaction owner handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_transaction(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Corrects (PUT) or removes (DELETE) a stored transaction, e.g. one that was
+        mis-categorized or imported twice. PUT only changes the fields it sends.
+        """
+        logging.info("Processing transaction %s request.", req.method)
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        transaction_id = req.route_params.get("id", "")
+        changes = {}
+        if req.method == "PUT":
+            try:
+                req_body = req.get_json()
+            except ValueError:
+                return func.HttpResponse(
+                    "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                )
+
+            errors = TRANSACTION_BODY.validate(req_body)
+            if errors:
+                return self._validation_error(errors)
+
+            changes = {
+                column: convert(req_body[field])
+                for field, (column, convert) in TRANSACTION_FIELDS.items()
+                if req_body.get(field) is not None
+            }
+            if not changes:
+                return func.HttpResponse(
+                    "No fields to update", status_code=HTTPStatus.BAD_REQUEST
+                )
+            if "Category" in changes:
+                changes["CategorySource"] = services.MANUAL_CATEGORY
+
+        try:
+            if req.method == "DELETE":
+                found = self.db_service.delete_transaction(transaction_id)
+            else:
+                found = self.db_service.update_transaction(transaction_id, changes)
+            if not found:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            # Take the charge as it was off its card, and put back what it is now
+            balance_changes = self._charges([found], -1)
+            if req.method == "PUT":
+                for account, amount in self._charges([{**found, **changes}]).items():
+                    balance_changes[account] = (
+                        balance_changes.get(account, Decimal("0")) + amount
+                    )
+            self._adjust_card_balances(balance_changes)
+
+            if req.method == "DELETE":
+                self._record_activity(
+                    "edit",
+                    "Deleted a transaction",
+                    user_email,
+                    {"transactionId": transaction_id},
+                )
+                undo_id = self._record_undo(
+                    "delete", "Deleted a transaction", user_email, {"restore": [found]}
+                )
+                return func.HttpResponse(
+                    status_code=HTTPStatus.NO_CONTENT,
+                    headers={UNDO_HEADER: undo_id} if undo_id else None,
+                )
+
+            updated = {
+                field: changes[column]
+                for field, (column, _) in TRANSACTION_FIELDS.items()
+                if column in changes
+            }
+            self._record_activity(
+                "edit",
+                f"Edited a transaction's {', '.join(updated)}",
+                user_email,
+                {"transactionId": transaction_id, "changes": updated},
+            )
+            return func.HttpResponse(
+                json.dumps({"id": transaction_id, **updated}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in transaction handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_transactions_bulk_edit(
+        self, req: func.HttpRequest
+    ) -> func.HttpResponse:
+        """
+        Changes the category, ignore flag or owner of every stored transaction in
+        the given months that a filter matches, or of those with the given IDs,
+        in batches. With dryRun=true nothing is saved and the counts are a preview.
+        Only an admin can change owners.
+        """
+        logging.info("Processing transactions bulk edit request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = BULK_EDIT_BODY.validate(req_body) or _check_bulk_edit(req_body)
+        if errors:
+            return self._validation_error(errors)
+        if "owner" in req_body["changes"] and not self._is_admin(user_email):
+            return func.HttpResponse("Forbidden", status_code=HTTPStatus.FORBIDDEN)
+
+        try:
+            changes = {
+                BULK_EDIT_FIELDS[field]: value or ""
+                for field, value in req_body["changes"].items()
+            }
+            owner = changes.get("Owner")
+            if owner:
+                emails = {p["Email"].lower() for p in self.db_service.get_all_people()}
+                if owner.lower() not in emails:
+                    return self._validation_error(
+                        [FieldError("changes.owner", "is not a household member")]
+                    )
+
+            dry_run = str(req_body.get("dryRun", False)).lower() == "true"
+            selected = (
+                filters.parse(req_body["filter"]) if req_body.get("filter") else None
+            )
+            ids = set(req_body.get("ids") or [])
+            months = sorted(set(req_body["months"]))
+            result = {"changed": 0, "updated": 0, "failed": 0}
+            found: set[str] = set()
+            # Rows to put back, grouped by month and the values they had, with
+            # the values they were given
+            previous: dict[tuple[str, str], dict] = {}
+            for month in months:
+                month_changes = {}
+                manual = (
+                    self.db_service.get_manual_category_ids(month)
+                    if "Category" in changes
+                    else set()
+                )
+                for row_key, t in self.db_service.get_keyed_transactions(month):
+                    picked = selected.matches(t) if selected else row_key in ids
+                    if not picked:
+                        continue
+                    found.add(row_key)
+                    current = {
+                        "Category": t.category.value,
+                        "IgnoredFrom": t.ignore.value,
+                        "Owner": t.owner or "",
+                        "CategorySource": (
+                            services.MANUAL_CATEGORY if row_key in manual else ""
+                        ),
+                    }
+                    properties = {
+                        column: value
+                        for column, value in changes.items()
+                        if current[column].lower() != value.lower()
+                    }
+                    if "Category" in properties:
+                        # Rule runs keep a category set here
+                        properties["CategorySource"] = services.MANUAL_CATEGORY
+                    if properties:
+                        month_changes[row_key] = properties
+                        had = {column: current[column] for column in properties}
+                        previous.setdefault(
+                            (month, json.dumps(had, sort_keys=True)),
+                            {"after": properties, "ids": []},
+                        )["ids"].append(row_key)
+                result["changed"] += len(month_changes)
+                if not dry_run:
+                    updated = self.db_service.update_transactions(month, month_changes)
+                    result["updated"] += updated
+                    result["failed"] += len(month_changes) - updated
+
+            inverse = {
+                "updates": [
+                    {"month": m, "properties": json.loads(had), **rows}
+                    for (m, had), rows in previous.items()
+                ]
+            }
+            undo_id = None
+            if not dry_run and result["updated"]:
+                summary = (
+                    f"Bulk edited {result['updated']} transactions' "
+                    f"{', '.join(req_body['changes'])}"
+                )
+                self._record_activity(
+                    "edit",
+                    summary,
+                    user_email,
+                    {"months": months, "changes": req_body["changes"]},
+                )
+                undo_id = self._record_undo("bulk-edit", summary, user_email, inverse)
+
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "dryRun": dry_run,
+                        "months": months,
+                        "matched": len(found),
+                        **result,
+                        "missing": sorted(ids - found),
+                        # Too large a change can't be undone, which a dry run warns of
+                        "undoable": len(json.dumps(inverse)) <= MAX_UNDO_INVERSE_LENGTH,
+                        "undoId": undo_id,
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in transactions bulk edit handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_undo(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Reverts one of the caller's recent bulk edits or deletes from its inverse
+        in the undo log, within UNDO_WINDOW_MINUTES of the change. The caller's
+        changes are undone newest first, and rows anyone changed again since
+        are left as they are, so a later change is never overwritten.
+        """
+        logging.info("Processing undo request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            operation_id = req.route_params.get("operationId", "")
+            operations = self.db_service.get_undo_operations()
+            index = next(
+                (i for i, o in enumerate(operations) if o["id"] == operation_id), None
+            )
+            if (
+                index is None
+                or (operations[index]["actor"] or "").lower() != user_email.lower()
+            ):
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            operation = operations[index]
+            if operation["undoneAt"]:
+                return func.HttpResponse(
+                    "This change was already undone", status_code=HTTPStatus.CONFLICT
+                )
+            expired_before = (datetime.now() - self._undo_window()).isoformat()
+            if operation["createdAt"] < expired_before:
+                return func.HttpResponse(
+                    "This change is too old to undo", status_code=HTTPStatus.GONE
+                )
+            if any(
+                not o["undoneAt"]
+                and (o["actor"] or "").lower() == user_email.lower()
+                for o in operations[:index]
+            ):
+                return func.HttpResponse(
+                    "Undo the changes made since first",
+                    status_code=HTTPStatus.CONFLICT,
+                )
+
+            inverse = operation["inverse"]
+            restored = self.db_service.restore_transactions(inverse.get("restore", []))
+            self._adjust_card_balances(self._charges(inverse.get("restore", [])))
+            skipped = 0
+            for update in inverse.get("updates", []):
+                # Rows no longer holding what the change set were changed since
+                updated = self.db_service.update_transactions(
+                    update["month"],
+                    {row_key: update["properties"] for row_key in update["ids"]},
+                    expected=update.get("after"),
+                )
+                restored += updated
+                skipped += len(update["ids"]) - updated
+            self.db_service.mark_undone(operation_id)
+            self._record_activity(
+                "edit",
+                f"Undid: {operation['summary']}",
+                user_email,
+                {"operationId": operation_id},
+            )
+
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "id": operation_id,
+                        "kind": operation["kind"],
+                        "restored": restored,
+                        "skipped": skipped,
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in undo handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_rules(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists the category rules in the order they run (GET) or adds one (POST).
+        Rules categorize uploaded transactions before they are saved.
+        """
+        logging.info("Processing rules %s request.", req.method)
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            if req.method == "GET":
+                return func.HttpResponse(
+                    json.dumps(
+                        {"rules": [r.to_json() for r in self.db_service.get_rules()]}
+                    ),
+                    mimetype="application/json",
+                    status_code=HTTPStatus.OK,
+                )
+
+            try:
+                req_body = req.get_json()
+            except ValueError:
+                return func.HttpResponse(
+                    "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                )
+
+            errors = RULE_BODY.validate(req_body) or _check_rule(req_body)
+            if errors:
+                return self._validation_error(errors)
+
+            rule = Rule.from_json(
+                uuid.uuid4().hex, req_body, self.db_service.category_names()
+            )
+            self.db_service.save_rule(rule)
+            return func.HttpResponse(
+                json.dumps(rule.to_json()),
+                mimetype="application/json",
+                status_code=HTTPStatus.CREATED,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in rules handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_rule(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Replaces (PUT) or removes (DELETE) a category rule."""
+        logging.info("Processing rule %s request.", req.method)
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        rule_id = req.route_params.get("id", "")
+        req_body = {}
+        if req.method == "PUT":
+            try:
+                req_body = req.get_json()
+            except ValueError:
+                return func.HttpResponse(
+                    "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                )
+
+            errors = RULE_BODY.validate(req_body) or _check_rule(req_body)
+            if errors:
+                return self._validation_error(errors)
+
+        try:
+            if req.method == "DELETE":
+                if not self.db_service.delete_rule(rule_id):
+                    return func.HttpResponse(
+                        "Not Found", status_code=HTTPStatus.NOT_FOUND
+                    )
+                return func.HttpResponse(status_code=HTTPStatus.NO_CONTENT)
+
+            if all(r.rule_id != rule_id for r in self.db_service.get_rules()):
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            rule = Rule.from_json(rule_id, req_body, self.db_service.category_names())
+            self.db_service.save_rule(rule)
+            return func.HttpResponse(
+                json.dumps(rule.to_json()),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in rule handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_views(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists the caller's saved transaction views (GET) or saves a new one
+        (POST): a named filter expression and sort, applied by passing them to
+        GET /api/transactions.
+        """
+        logging.info("Processing views %s request.", req.method)
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            if req.method == "GET":
+                return func.HttpResponse(
+                    json.dumps({"views": self.db_service.get_views(user_email)}),
+                    mimetype="application/json",
+                    status_code=HTTPStatus.OK,
+                )
+
+            try:
+                req_body = req.get_json()
+            except ValueError:
+                return func.HttpResponse(
+                    "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                )
+
+            errors = VIEW_BODY.validate(req_body) or _check_view(req_body)
+            if errors:
+                return self._validation_error(errors)
+
+            view = self._view_from_json(uuid.uuid4().hex, req_body)
+            self.db_service.save_view(view, user_email)
+            return func.HttpResponse(
+                json.dumps(view),
+                mimetype="application/json",
+                status_code=HTTPStatus.CREATED,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in views handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_view(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Replaces (PUT) or removes (DELETE) one of the caller's saved views."""
+        logging.info("Processing view %s request.", req.method)
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        view_id = req.route_params.get("id", "")
+        req_body = {}
+        if req.method == "PUT":
+            try:
+                req_body = req.get_json()
+            except ValueError:
+                return func.HttpResponse(
+                    "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                )
+
+            errors = VIEW_BODY.validate(req_body) or _check_view(req_body)
+            if errors:
+                return self._validation_error(errors)
+
+        try:
+            # Another member's views are as good as missing
+            if all(v["id"] != view_id for v in self.db_service.get_views(user_email)):
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            if req.method == "DELETE":
+                self.db_service.delete_view(view_id)
+                return func.HttpResponse(status_code=HTTPStatus.NO_CONTENT)
+
+            view = self._view_from_json(view_id, req_body)
+            self.db_service.save_view(view, user_email)
+            return func.HttpResponse(
+                json.dumps(view),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in view handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    @staticmethod
+    def _view_from_json(view_id: str, body: dict) -> dict:
+        """A saved view from a validated request body."""
+        return {
+            "id": view_id,
+            "name": body["name"].strip(),
+            "filter": body.get("filter") or None,
+            "sort": body.get("sort") or None,
+        }
+
+    def _apply_rules_to_months(
+        self, months: list[str], override: bool = False
+    ) -> dict:
+        """
+        Re-runs the category rules over the stored transactions in each month and
+        saves the rows whose category changes. Categories set by hand are kept
+        unless override is set. Returns the counts.
+        """
+        rules = self.db_service.get_rules()
+        result = {
+            "months": months,
+            "scanned": 0,
+            "updated": 0,
+            "locked": 0,
+            "failed": 0,
+        }
+        for month in months:
+            keyed = self.db_service.get_keyed_transactions(month)
+            manual = (
+                set() if override else self.db_service.get_manual_category_ids(month)
+            )
+            categorized = apply_rules(rules, [t for _, t in keyed])
+            recategorized = [
+                (row_key, new)
+                for (row_key, old), new in zip(keyed, categorized)
+                if new.category != old.category
+            ]
+            changes = {
+                row_key: {"Category": new.category.value, "CategorySource": ""}
+                for row_key, new in recategorized
+                if row_key not in manual
+            }
+            updated = self.db_service.update_transactions(month, changes)
+            result["scanned"] += len(keyed)
+            result["updated"] += updated
+            result["locked"] += len(recategorized) - len(changes)
+            result["failed"] += len(changes) - updated
+        logging.info(
+            "Re-applied %d rules to %s: %d of %d transactions updated",
+            len(rules),
+            ", ".join(months),
+            result["updated"],
+            result["scanned"],
+        )
+        return result
+
+    def handle_rules_apply(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Re-applies the category rules to the stored transactions in the given months.
+        Runs inline and returns the counts, or with async=true queues the work on the
+        backfill queue and returns 202 Accepted. Categories set by hand are kept
+        unless override=true.
+        """
+        logging.info("Processing rules apply request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = RULES_APPLY_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+        months = sorted(set(req_body["months"]))
+        override = str(req_body.get("override", False)).lower() == "true"
+
+        try:
+            if str(req_body.get("async", False)).lower() == "true":
+                self.queue_service.enqueue_message(
+                    {"task": APPLY_RULES_TASK, "months": months, "override": override},
+                    priority=services.QueuePriority.BACKFILL,
+                )
+                return func.HttpResponse(
+                    json.dumps({"months": months, "queued": True}),
+                    mimetype="application/json",
+                    status_code=HTTPStatus.ACCEPTED,
+                )
+
+            return func.HttpResponse(
+                json.dumps(self._apply_rules_to_months(months, override)),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in rules apply handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_unassigned_accounts(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Lists the accounts in a month's transactions that belong to no person."""
+        logging.info("Processing unassigned accounts request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = SAVINGS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            month = req.params.get("month", datetime.now().strftime("%Y-%m"))
+            members = [self._person(p) for p in self.db_service.get_all_people()]
+            group = Group(members)
+            group.add_transactions(self.db_service.get_transactions(month))
+
+            accounts: dict[int, dict] = {}
+            for t in group.unassigned:
+                entry = accounts.setdefault(
+                    t.account_number,
+                    {"accountNumber": t.account_number, "count": 0, "total": 0.0},
+                )
+                entry["count"] += 1
+                entry["total"] = round(entry["total"] + float(t.amount), 2)
+
+            listed = sorted(accounts.values(), key=lambda a: a["accountNumber"])
+            return func.HttpResponse(
+                json.dumps({"month": month, "accounts": listed}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in unassigned accounts handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_assign_account(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Maps an account number to a person, so its transactions are assigned to them
+        on future imports and reports. Admins only.
+        """
+        logging.info("Processing assign account request.")
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = ASSIGN_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            account = int(req_body["accountNumber"])
+            people = self.db_service.get_all_people()
+            person = next(
+                (p for p in people if p["Email"].lower() == req_body["email"].lower()),
+                None,
+            )
+            if person is None:
+                return self._validation_error(
+                    [FieldError("email", "is not a household member")]
+                )
+
+            conflict = self._account_conflict(people, person, account)
+            if conflict:
+                return conflict
+
+            if account not in person["Accounts"]:
+                person["Accounts"] = [*person["Accounts"], account]
+                self.db_service.save_person(person)
+
+            return func.HttpResponse(
+                json.dumps({"email": person["Email"], "accounts": person["Accounts"]}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in assign account handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    @staticmethod
+    def _account_conflict(
+        people: list[dict], person: dict, account: int, weight: float | None = None
+    ) -> func.HttpResponse | None:
+        """
+        Returns a conflict response if the account belongs to someone else, unless
+        it is being shared as a joint account: the new holder gives a weight and
+        every other holder already has one.
+        """
+        owner = next(
+            (
+                p
+                for p in people
+                if account in p["Accounts"]
+                and p is not person
+                and (
+                    weight is None or str(account) not in p.get("AccountWeights", {})
+                )
+            ),
+            None,
+        )
+        if owner is not None:
+            return func.HttpResponse(
+                f"Account already belongs to {owner['Email']}",
+                status_code=HTTPStatus.CONFLICT,
+            )
+        return None
+
+    @staticmethod
+    def _mask_conflicts(stored: list[dict], incoming: list[dict]) -> list[dict]:
+        """
+        Finds incoming accounts whose mask is already used by a different account.
+        Card spend is matched to accounts by mask, so a shared one is counted twice.
+        """
+        accounts = {a["accountId"]: a for a in stored}
+        conflicts = []
+        for account in incoming:
+            mask = account.get("mask")
+            existing = next(
+                (
+                    a
+                    for a in accounts.values()
+                    if mask
+                    and a.get("mask") == mask
+                    and a["accountId"] != account["accountId"]
+                ),
+                None,
+            )
+            if existing is not None:
+                conflicts.append(
+                    {
+                        "accountId": account["accountId"],
+                        "mask": mask,
+                        "conflictsWith": {
+                            "accountId": existing["accountId"],
+                            "institution": existing.get("institution"),
+                            "mask": existing.get("mask"),
+                        },
+                    }
+                )
+            accounts[account["accountId"]] = {
+                **accounts.get(account["accountId"], {}),
+                **account,
+            }
+        return conflicts
+
+    @staticmethod
+    def _find_person(people: list[dict], email: str) -> dict | None:
+        """Finds a person by email, ignoring case."""
+        return next((p for p in people if p["Email"].lower() == email.lower()), None)
+
+    @staticmethod
+    def _person_json(person: dict) -> dict:
+        """Serializes a People table record for the API."""
+        return {
+            "name": person["Name"],
+            "email": person["Email"],
+            "accounts": person["Accounts"],
+            "weights": person.get("AccountWeights", {}),
+            "splitShare": person.get("SplitShare"),
+            "categoryShares": person.get("CategoryShares", {}),
+        }
+
+    @staticmethod
+    def _split_conflicts(others: list[dict], person: dict) -> list[FieldError]:
+        """
+        Checks a member's split shares against the other member's: where both
+        set a share for the same spend, they have to add up to 1.
+        """
+        if len(others) != 1:
+            return []
+        other = others[0]
+        pairs = [("splitShare", person.get("SplitShare"), other.get("SplitShare"))]
+        pairs += [
+            (f"categoryShares.{c}", share, other.get("CategoryShares", {}).get(c))
+            for c, share in person.get("CategoryShares", {}).items()
+        ]
+        return [
+            FieldError(name, f"must add up to 1 with {other['Name']}'s share")
+            for name, share, theirs in pairs
+            if share is not None
+            and theirs is not None
+            and Decimal(str(share)) + Decimal(str(theirs)) != 1
+        ]
+
+    def handle_people(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists household members (GET), adds or updates one (POST) or removes one
+        (DELETE /people/{id}, by email). Anyone signed in can list them; only admins
+        can change them.
+        """
+        logging.info("Processing people %s request.", req.method)
+
+        if req.method == "GET":
+            error_resp = (
+                None
+                if self._get_user_email(req)
+                else func.HttpResponse(
+                    "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+                )
+            )
+        else:
+            _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        req_body = {}
+        if req.method == "POST":
+            try:
+                req_body = req.get_json()
+            except ValueError:
+                return func.HttpResponse(
+                    "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                )
+
+            errors = PERSON_BODY.validate(req_body)
+            for category, share in (req_body.get("categoryShares") or {}).items():
+                message = _check_category(category) or _check_split_share(share)
+                if message:
+                    errors.append(FieldError(f"categoryShares.{category}", message))
+            if errors:
+                return self._validation_error(errors)
+
+        try:
+            people = self.db_service.get_all_people()
+
+            if req.method == "DELETE":
+                person = self._find_person(people, req.route_params.get("id", ""))
+                if person is None or not self.db_service.delete_person(
+                    person["Email"]
+                ):
+                    return func.HttpResponse(
+                        "Not Found", status_code=HTTPStatus.NOT_FOUND
+                    )
+                return func.HttpResponse(status_code=HTTPStatus.NO_CONTENT)
+
+            if req.method == "POST":
+                existing = self._find_person(people, req_body["email"])
+                person = {
+                    "Name": req_body["name"],
+                    # Keep the stored key of an existing member
+                    "Email": existing["Email"] if existing else req_body["email"],
+                    "Accounts": sorted(
+                        {int(a) for a in req_body.get("accounts") or []}
+                        if "accounts" in req_body
+                        else set(existing["Accounts"] if existing else [])
+                    ),
+                }
+                # Weights on the joint accounts the member keeps carry over
+                weights = existing.get("AccountWeights", {}) if existing else {}
+                person["AccountWeights"] = {
+                    a: w for a, w in weights.items() if int(a) in person["Accounts"]
+                }
+                # Split shares carry over unless given; null clears them
+                for field, name, empty in [
+                    ("splitShare", "SplitShare", None),
+                    ("categoryShares", "CategoryShares", {}),
+                ]:
+                    if field in req_body:
+                        value = req_body[field]
+                        person[name] = empty if value is None else value
+                    else:
+                        person[name] = existing.get(name, empty) if existing else empty
+                others = [p for p in people if p is not existing]
+                errors = self._split_conflicts(others, person)
+                if errors:
+                    return self._validation_error(errors)
+                for account in person["Accounts"]:
+                    conflict = self._account_conflict(
+                        others,
+                        person,
+                        account,
+                        person["AccountWeights"].get(str(account)),
+                    )
+                    if conflict:
+                        return conflict
+
+                self.db_service.save_person(person)
+                return func.HttpResponse(
+                    json.dumps(self._person_json(person)),
+                    mimetype="application/json",
+                    status_code=HTTPStatus.OK if existing else HTTPStatus.CREATED,
+                )
+
+            return func.HttpResponse(
+                json.dumps({"people": [self._person_json(p) for p in people]}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in people handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_invites(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Invites a new household member: emails them a single-use link that adds
+        them to the household when they accept it signed in. Admins only.
+        """
+        logging.info("Processing invite request.")
+
+        user_email, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = INVITE_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            if self._find_person(self.db_service.get_all_people(), req_body["email"]):
+                return func.HttpResponse(
+                    f"{req_body['email']} is already a member",
+                    status_code=HTTPStatus.CONFLICT,
+                )
+
+            token = INVITE_TOKEN_PREFIX + secrets.token_urlsafe(32)
+            expires_at = (datetime.now() + timedelta(days=INVITE_TTL_DAYS)).isoformat()
+            self.db_service.save_invite(
+                _hash_token(token),
+                req_body["email"],
+                req_body["name"],
+                user_email,
+                expires_at,
+            )
+            # Sent once; only the hash is stored
+            link = f"{os.environ.get('APP_URL', '')}/invite.html?token={token}"
+            self.email_service.send_email(
+                [req_body["email"]],
+                "You're invited to join the household",
+                self.email_renderer.render_invite(
+                    req_body["name"], user_email, link, expires_at
+                ),
+            )
+            logging.info("%s invited %s", user_email, req_body["email"])
+
+            return func.HttpResponse(
+                json.dumps({"email": req_body["email"], "expiresAt": expires_at}),
+                mimetype="application/json",
+                status_code=HTTPStatus.CREATED,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in invite handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_invite_accept(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Completes signup from an invite link: adds the signed-in caller to the
+        household under the invited name, bound to their own sign-in email.
+        """
+        logging.info("Processing invite accept request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = INVITE_ACCEPT_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            token_hash = _hash_token(req_body["token"])
+            invite = self.db_service.get_invite(token_hash)
+            if invite is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+            if invite["acceptedBy"] or invite["expiresAt"] < datetime.now().isoformat():
+                return func.HttpResponse(
+                    "Invite has expired or was already used",
+                    status_code=HTTPStatus.GONE,
+                )
+            if self._find_person(self.db_service.get_all_people(), user_email):
+                return func.HttpResponse(
+                    f"{user_email} is already a member",
+                    status_code=HTTPStatus.CONFLICT,
+                )
+
+            # Used up before the member is added, so a second accept can't also join
+            if not self.db_service.mark_invite_accepted(token_hash, user_email):
+                return func.HttpResponse(
+                    "Invite has expired or was already used",
+                    status_code=HTTPStatus.GONE,
+                )
+            person = {"Name": invite["name"], "Email": user_email, "Accounts": []}
+            self.db_service.save_person(person)
+            logging.info("%s accepted the invite for %s", user_email, invite["email"])
+
+            return func.HttpResponse(
+                json.dumps(self._person_json(person)),
+                mimetype="application/json",
+                status_code=HTTPStatus.CREATED,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in invite accept handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    @staticmethod
+    def _share_params(body: dict) -> tuple[dict, list[FieldError]]:
+        """
+        The params a shared report is rendered with: the month of a summary
+        (this month by default) or the range of a trend report.
+        """
+        if body["report"] == "summary":
+            return {"month": body.get("month", datetime.now().strftime("%Y-%m"))}, []
+
+        errors = [
+            FieldError(field, "is required for a trends report")
+            for field in ("from", "to")
+            if field not in body
+        ]
+        if errors:
+            return {}, errors
+        months = months_between(body["from"], body["to"])
+        params = {
+            "from": body["from"],
+            "to": body["to"],
+            "real": body.get("real", False),
+        }
+        return params, Controller._check_trend_months(months)
+
+    def _shared_report(self, report: str, params: dict) -> dict:
+        """
+        Renders a shared report from the params it was shared with. Members are
+        named but their emails are left out, and accounts are shown by only the
+        last four digits of their numbers, as the reader isn't one of them.
+        """
+        if report == "summary":
+            summary = self._month_summary(params["month"])
+            for person in summary["people"]:
+                del person["email"]
+            for account in summary["accounts"]:
+                account["mask"] = str(account.pop("accountNumber"))[-4:]
+            return summary
+        return self._trend_report(
+            months_between(params["from"], params["to"]), bool(params["real"])
+        )
+
+    def handle_shares(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists the caller's report share links (GET), or creates one (POST): a
+        link, valid for expiresInDays, that opens a read-only copy of a report
+        without signing in. The link is returned once; only its hash is stored.
+        """
+        logging.info("Processing shares request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        if req.method == "GET":
+            try:
+                return func.HttpResponse(
+                    json.dumps({"shares": self.db_service.get_shares(user_email)}),
+                    mimetype="application/json",
+                    status_code=HTTPStatus.OK,
+                )
+            except Exception as e:  # pylint: disable=broad-exception-caught
+                logging.error("Error in shares handler: %s", e)
+                return func.HttpResponse(
+                    f"Internal Error: {str(e)}",
+                    status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+                )
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = SHARE_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+        params, errors = self._share_params(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            token = SHARE_TOKEN_PREFIX + secrets.token_urlsafe(32)
+            days = req_body.get("expiresInDays", SHARE_TTL_DAYS)
+            expires_at = (datetime.now() + timedelta(days=days)).isoformat()
+            token_hash = _hash_token(token)
+            self.db_service.save_share(
+                token_hash,
+                {
+                    "report": req_body["report"],
+                    "params": params,
+                    "createdBy": user_email,
+                    "expiresAt": expires_at,
+                },
+            )
+            logging.info("%s shared a %s report", user_email, req_body["report"])
+
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "id": token_hash,
+                        "report": req_body["report"],
+                        "params": params,
+                        "expiresAt": expires_at,
+                        "url": f"{os.environ.get('APP_URL', '')}"
+                        f"/shared.html?token={token}",
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.CREATED,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in shares handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_share(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Revokes one of the caller's share links, so it no longer opens."""
+        logging.info("Processing share revoke request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            share = self.db_service.get_share(req.route_params.get("id", ""))
+            if share is None or share["createdBy"].lower() != user_email.lower():
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            if not share["revokedAt"]:
+                self.db_service.revoke_share(share["id"])
+                logging.info("%s revoked share %s", user_email, share["id"])
+            return func.HttpResponse(status_code=HTTPStatus.NO_CONTENT)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in share revoke handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_shared_report(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Opens a shared report from its link, without signing in. The report is
+        rendered from current data each time, until the link expires or is revoked.
+        """
+        logging.info("Processing shared report request.")
+
+        try:
+            share = self.db_service.get_share(
+                _hash_token(req.headers.get(SHARE_TOKEN_HEADER, ""))
+            )
+            if share is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+            if share["revokedAt"] or share["expiresAt"] < datetime.now().isoformat():
+                return func.HttpResponse(
+                    "This link has expired or was revoked",
+                    status_code=HTTPStatus.GONE,
+                )
+
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "report": share["report"],
+                        "expiresAt": share["expiresAt"],
+                        "data": self._shared_report(share["report"], share["params"]),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in shared report handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_person_accounts(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists a member's account numbers (GET), associates one (POST) or removes one
+        (DELETE /people/{id}/accounts/{account}). A POST with a weight makes the
+        account joint: it may be shared with other holders that have a weight too,
+        and its transactions are split between them in proportion (0.6 and 0.4 for
+        a card used 60/40). Only admins can change them.
+        """
+        logging.info("Processing person accounts %s request.", req.method)
+
+        if req.method == "GET":
+            if not self._get_user_email(req):
+                return func.HttpResponse(
+                    "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+                )
+        else:
+            _, error_resp = self._require_admin(req)
+            if error_resp:
+                return error_resp
+
+        account = None
+        weight = None
+        if req.method == "POST":
+            try:
+                req_body = req.get_json()
+            except ValueError:
+                return func.HttpResponse(
+                    "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+                )
+
+            errors = ACCOUNT_BODY.validate(req_body)
+            if errors:
+                return self._validation_error(errors)
+            account = int(req_body["accountNumber"])
+            if req_body.get("weight") is not None:
+                weight = float(req_body["weight"])
+        elif req.method == "DELETE":
+            errors = ACCOUNT_BODY.validate(
+                {"accountNumber": req.route_params.get("account", "")}
+            )
+            if errors:
+                return self._validation_error(errors)
+            account = int(req.route_params["account"])
+
+        try:
+            people = self.db_service.get_all_people()
+            person = self._find_person(people, req.route_params.get("id", ""))
+            if person is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            accounts = person["Accounts"]
+            weights = dict(person.get("AccountWeights", {}))
+            if req.method == "POST":
+                conflict = self._account_conflict(people, person, account, weight)
+                if conflict:
+                    return conflict
+                accounts = self.db_service.add_person_account(
+                    person["Email"], account, weight
+                )
+                weights.pop(str(account), None)
+                if weight is not None:
+                    weights[str(account)] = weight
+            elif req.method == "DELETE":
+                if account not in accounts:
+                    return func.HttpResponse(
+                        "Not Found", status_code=HTTPStatus.NOT_FOUND
+                    )
+                accounts = self.db_service.remove_person_account(
+                    person["Email"], account
+                )
+                weights.pop(str(account), None)
+
+            return func.HttpResponse(
+                json.dumps(
+                    {"email": person["Email"], "accounts": accounts, "weights": weights}
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in person accounts handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_account_sync(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Stores the caller's accounts from an external account-sync payload
+        (balance, limit, mask, institution), keyed by each account's external ID.
+        A mask already used by another of the caller's accounts is rejected with a
+        409 unless the request sets override.
+        """
+        logging.info("Processing account sync request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = ACCOUNT_SYNC_BODY.validate(req_body) or _synced_account_errors(
+            req_body["accounts"]
+        )
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            accounts = [_normalize_synced_account(a) for a in req_body["accounts"]]
+            override = str(req_body.get("override", False)).lower() == "true"
+            if not override:
+                conflicts = self._mask_conflicts(
+                    self.db_service.get_accounts(user_email), accounts
+                )
+                if conflicts:
+                    return func.HttpResponse(
+                        json.dumps(
+                            {
+                                "error": "Account number already in use",
+                                "conflicts": conflicts,
+                            }
+                        ),
+                        mimetype="application/json",
+                        status_code=HTTPStatus.CONFLICT,
+                    )
+            synced = self.db_service.upsert_accounts(user_email, accounts)
+
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "email": user_email,
+                        "synced": synced,
+                        "failed": len(accounts) - synced,
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in account sync handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_account_reconcile(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Reconciles one of the caller's cards against a statement's ending balance
+        as of its date (today by default). The balance is checked against the
+        last reconciled balance plus the transactions stored since; if they don't
+        match, the card stays unreconciled unless the discrepancy is accepted.
+        Every attempt is added to the card's audit trail. This is separate from
+        LastImportedDate, which only tracks the newest transaction imported, so
+        backfilling old statements never counts as verification.
+        """
+        logging.info("Processing account reconcile request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = RECONCILE_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        today = datetime.now().date()
+        try:
+            reconciled = (
+                date.fromisoformat(req_body["date"]) if req_body.get("date") else today
+            )
+        except ValueError:
+            return self._validation_error([FieldError("date", "is not a valid date")])
+        if reconciled > today:
+            return self._validation_error(
+                [FieldError("date", "must not be in the future")]
+            )
+
+        account_id = req.route_params.get("accountId", "")
+        try:
+            card = next(
+                (
+                    a
+                    for a in self.db_service.get_accounts(user_email)
+                    if a["accountId"] == account_id
+                ),
+                None,
+            )
+            if card is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            since = card.get("lastReconciledDate")
+            if since and reconciled <= date.fromisoformat(since):
+                return self._validation_error(
+                    [FieldError("date", f"must be after the last reconcile, {since}")]
+                )
+
+            balance = Decimal(str(req_body["balance"]))
+            prior = card.get("reconciledBalance")
+            transactions = []
+            if since and card.get("mask"):
+                transactions = reconcile.card_transactions(
+                    [
+                        t
+                        for month in months_between(
+                            since[:7], reconciled.strftime("%Y-%m")
+                        )
+                        for t in self.db_service.get_transactions(month)
+                    ],
+                    card["mask"],
+                    date.fromisoformat(since),
+                    reconciled,
+                )
+            else:
+                # Without a mask the card's transactions can't be told apart
+                prior = None
+            result = reconcile.compare_balance(
+                balance, None if prior is None else Decimal(str(prior)), transactions
+            )
+            accepted = bool(req_body.get("accept"))
+            is_reconciled = result["status"] != "discrepancy" or accepted
+            if is_reconciled:
+                self.db_service.reconcile_account(
+                    user_email, account_id, float(balance), reconciled
+                )
+            self.db_service.record_reconciliation(
+                user_email,
+                account_id,
+                {
+                    "statementDate": reconciled.isoformat(),
+                    "balance": float(balance),
+                    "since": since,
+                    **result,
+                    "reconciled": is_reconciled,
+                    "reconciledBy": user_email,
+                },
+            )
+
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "accountId": account_id,
+                        **result,
+                        "reconciled": is_reconciled,
+                        "reconciledBalance": (
+                            float(balance) if is_reconciled else prior
+                        ),
+                        "lastReconciledDate": (
+                            reconciled.isoformat() if is_reconciled else since
+                        ),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in account reconcile handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_reconciliations(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Lists one of the caller's cards' reconcile attempts, newest first."""
+        logging.info("Processing reconciliations request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        account_id = req.route_params.get("accountId", "")
+        try:
+            if not any(
+                a["accountId"] == account_id
+                for a in self.db_service.get_accounts(user_email)
+            ):
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "accountId": account_id,
+                        "reconciliations": self.db_service.get_reconciliations(
+                            user_email, account_id
+                        ),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in reconciliations handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_round_ups(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        GET suggests a savings contribution from the spare-change round-ups of the
+        caller's purchases in a month. POST records it as the month's round-up item.
+        """
+        logging.info("Processing round-ups request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = SAVINGS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            month = req.params.get("month", datetime.now().strftime("%Y-%m"))
+            person = next(
+                (
+                    self._person(p)
+                    for p in self.db_service.get_all_people()
+                    if p["Email"].lower() == user_email.lower()
+                ),
+                None,
+            )
+            if person is None:
+                return func.HttpResponse("Forbidden", status_code=HTTPStatus.FORBIDDEN)
+
+            increment = self._round_up_increments().get(
+                user_email.lower(), Decimal("0")
+            )
+            if not increment:
+                return func.HttpResponse(
+                    "Round-ups are not enabled", status_code=HTTPStatus.CONFLICT
+                )
+
+            round_ups = person_round_ups(
+                person, self.db_service.get_transactions(month), increment
+            )
+            total = round_ups["total"]
+
+            if req.method == "POST":
+                data = self.db_service.get_savings(month, user_email) or {
+                    "startingBalance": 0.0,
+                    "items": [],
+                }
+                items = [i for i in data["items"] if i["name"] != ROUND_UP_ITEM_NAME]
+                items.append({"name": ROUND_UP_ITEM_NAME, "cost": float(total)})
+                self.db_service.save_savings(
+                    month, {**data, "items": items}, user_email
+                )
+
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "month": month,
+                        "increment": float(increment),
+                        "purchases": round_ups["purchases"],
+                        "total": float(total),
+                        "recorded": req.method == "POST",
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in round-ups handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_savings_suggestion_approve(
+        self, req: func.HttpRequest
+    ) -> func.HttpResponse:
+        """
+        Approves a suggested transfer to savings, posted with the signature from
+        its email link, recording it as a one-off item in the member's savings
+        for the month.
+        """
+        logging.info("Processing savings suggestion approval request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse(
+                "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+            )
+        errors = SIGNED_LINK_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        suggestion_id = req.route_params.get("id", "")
+        expected = _sign_reminder(suggestion_id)
+        if expected is None or not hmac.compare_digest(expected, req_body["sig"]):
+            return func.HttpResponse("Forbidden", status_code=HTTPStatus.FORBIDDEN)
+
+        match = re.fullmatch(SAVINGS_SUGGESTION_ID_PATTERN, suggestion_id)
+        if not match:
+            return func.HttpResponse(
+                "Invalid suggestion", status_code=HTTPStatus.BAD_REQUEST
+            )
+        month, amount = match.groups()
+
+        try:
+            suggestions = json.loads(
+                self.db_service.get_settings().get("SavingsSuggestions", "{}")
+            )
+            state = suggestions.get(month)
+            if not state or state["amount"] != amount:
+                return func.HttpResponse(
+                    "This suggestion has expired", status_code=HTTPStatus.GONE
+                )
+            if state.get("approvedBy"):
+                return func.HttpResponse(
+                    f"This transfer was already recorded by {state['approvedBy']}.",
+                    status_code=HTTPStatus.OK,
+                )
+
+            data = self.db_service.get_savings(month, user_email) or {
+                "startingBalance": 0.0,
+                "items": [],
+            }
+            items = [
+                i for i in data["items"] if i["name"] != SAVINGS_TRANSFER_ITEM_NAME
+            ]
+            items.append(
+                {
+                    "name": SAVINGS_TRANSFER_ITEM_NAME,
+                    "cost": float(amount),
+                    "oneOff": True,
+                }
+            )
+            self.db_service.save_savings(month, {**data, "items": items}, user_email)
+
+            suggestions[month] = {**state, "approvedBy": user_email}
+            self.db_service.save_setting("SavingsSuggestions", json.dumps(suggestions))
+            self._record_activity(
+                "savings",
+                f"Moved ${amount} to savings for {month}",
+                user_email,
+                {"month": month, "amount": float(amount)},
+            )
+            return func.HttpResponse(
+                f"Thanks! ${amount} was added to your savings for {month}.",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in savings suggestion approval handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_health_score(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Returns the caller's financial health score for a month with its per-factor
+        breakdown, and the history recorded by the nightly job.
+        """
+        logging.info("Processing health score request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = SAVINGS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            month = req.params.get("month", datetime.now().strftime("%Y-%m"))
+            coverage = self._emergency_fund_coverage(datetime.now())
+            result = health_score(
+                self.db_service.get_savings(month, user_email),
+                coverage["months"],
+                aggregate_utilization(self.db_service.get_accounts(user_email))[
+                    "ratio"
+                ],
+            )
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "month": month,
+                        **result,
+                        "history": self.db_service.get_health_scores(user_email),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in health score handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _emergency_fund_coverage(self, now: datetime) -> dict:
+        """
+        Computes how many months the emergency fund covers at the average essential
+        spend of the complete months before now.
+        """
+        settings = self.db_service.get_settings()
+        fund = Decimal(settings.get("EmergencyFund", "0"))
+        monthly = {
+            month: self.db_service.get_transactions(month)
+            for month in previous_months(now, LOOKBACK_MONTHS)
+        }
+        essential = self._essential_categories()
+        spend = average_monthly_spend(monthly, essential)
+        return {
+            "fund": float(fund),
+            "monthlyEssentialSpend": float(spend),
+            "months": coverage_months(fund, spend),
+            "alertBelowMonths": float(settings.get("EmergencyFundAlertMonths", "3")),
+            "essentialCategories": [c.value for c in essential],
+        }
+
+    def _check_emergency_fund_alert(
+        self, coverage: dict, today: date, recipients: list[str]
+    ) -> None:
+        """
+        Emails the household when coverage first drops below the alert threshold.
+        The alert is re-armed once coverage recovers, so it is sent once per drop.
+        While it's snoozed or dismissed it isn't sent, or counted as sent.
+        """
+        months = coverage["months"]
+        if months is None or not recipients:
+            return
+
+        alerted = self.db_service.get_settings().get("EmergencyFundAlerted") == "true"
+        low = months < coverage["alertBelowMonths"]
+        if "household" in self._suppressed_alerts("emergency-fund", today):
+            low = low and alerted
+        elif low and not alerted:
+            self._send_alert(
+                "emergency-fund",
+                ["household"],
+                recipients,
+                "Emergency fund coverage is low",
+                self.email_renderer.render_emergency_fund_alert(
+                    months, coverage["alertBelowMonths"]
+                ),
+            )
+        if low != alerted:
+            self.db_service.save_setting("EmergencyFundAlerted", str(low).lower())
+
+    def handle_emergency_fund(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Returns how many months of essential spending the emergency fund covers."""
+        logging.info("Processing emergency fund request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            return func.HttpResponse(
+                json.dumps(self._emergency_fund_coverage(datetime.now())),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in emergency fund handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def run_health_score_job(self) -> None:
+        """
+        Timer Trigger handler. Records every member's health score for the current
+        month so it can be tracked over time, and alerts on low emergency fund coverage.
+        """
+        try:
+            now = datetime.now()
+            month = now.strftime("%Y-%m")
+            coverage = self._emergency_fund_coverage(now)
+            people = self.db_service.get_all_people()
+            for person in people:
+                email = person["Email"]
+                result = health_score(
+                    self.db_service.get_savings(month, email),
+                    coverage["months"],
+                    aggregate_utilization(self.db_service.get_accounts(email))["ratio"],
+                )
+                self.db_service.save_health_score(email, now.date(), month, result)
+            logging.info("Health scores recorded for %s", month)
+
+            self._check_emergency_fund_alert(
+                coverage, now.date(), [p["Email"] for p in people]
+            )
+
+        except Exception as e:
+            logging.error("Error running health score job: %s", e)
+            raise
+
+    def _alert_missing_bills(
+        self,
+        charges: list[RecurringCharge],
+        today: date,
+        settings: dict[str, str],
+        recipients: list[str],
+    ) -> int:
+        """
+        Emails the household about recurring bills overdue by more than their usual
+        cadence plus the grace days. Each missed due date is reported once, and
+        bills whose alert is snoozed or dismissed are left out until it's reopened
+        or the snooze ends. Returns the number of emails sent.
+        """
+        grace_days = int(settings.get("MissingBillGraceDays", "3"))
+        suppressed = self._suppressed_alerts("missing-bill", today)
+        missing = [
+            c
+            for c in missing_charges(charges, today, grace_days)
+            if c.merchant not in suppressed
+        ]
+
+        alerted = json.loads(settings.get("MissingBillAlerts", "{}"))
+        newly_missing = [
+            c for c in missing if alerted.get(c.merchant) != c.expected_date.isoformat()
+        ]
+        emails = 0
+        if newly_missing and recipients:
+            emails += 1
+            self._send_alert(
+                "missing-bill",
+                [c.merchant for c in newly_missing],
+                recipients,
+                "Recurring bills are missing",
+                self.email_renderer.render_missing_bills_alert(
+                    [c.to_json() for c in newly_missing]
+                ),
+            )
+
+        # Bills that have since appeared drop out, re-arming their alert
+        current = {c.merchant: c.expected_date.isoformat() for c in missing}
+        if current != alerted:
+            self.db_service.save_setting("MissingBillAlerts", json.dumps(current))
+        logging.info("Missing bill check found %d missing bills", len(missing))
+        return emails
+
+    def _track_price_changes(
+        self,
+        charges: list[RecurringCharge],
+        today: date,
+        settings: dict[str, str],
+        recipients: list[str],
+    ) -> int:
+        """
+        Saves each recurring charge as a subscription record. When the latest charge
+        exceeds the trailing average by more than the threshold, the change is added
+        to the record's price history and the household is emailed, once per charge,
+        unless the merchant's price alert is snoozed or dismissed. Returns the
+        number of emails sent.
+        """
+        threshold = Decimal(settings.get("PriceIncreaseThreshold", "0.1"))
+        stored = {s["merchant"]: s for s in self.db_service.get_subscriptions()}
+
+        increases = []
+        for charge in charges:
+            history = stored.get(charge.merchant, {}).get("priceHistory", [])
+            change = price_increase(charge, threshold)
+            last_date = charge.last_date.isoformat()
+            if change is not None and all(h["date"] != last_date for h in history):
+                entry = {
+                    "date": last_date,
+                    "from": float(charge.trailing_average),
+                    "to": float(charge.last_amount),
+                    "change": float(change),
+                }
+                history = [*history, entry]
+                increases.append({"merchant": charge.merchant, **entry})
+
+            self.db_service.save_subscription(
+                {**charge.to_json(), "priceHistory": history}
+            )
+
+        suppressed = self._suppressed_alerts("price-increase", today)
+        increases = [i for i in increases if i["merchant"] not in suppressed]
+        emails = 0
+        if increases and recipients:
+            emails += 1
+            self._send_alert(
+                "price-increase",
+                [i["merchant"] for i in increases],
+                recipients,
+                "Subscription prices went up",
+                self.email_renderer.render_price_increase_alert(increases),
+            )
+        logging.info("Price check found %d increases", len(increases))
+        return emails
+
+    def _history_transactions(self, now: datetime) -> list[Transaction]:
+        """The last HISTORY_MONTHS complete months of transactions plus this month's."""
+        return [
+            t
+            for month in [*previous_months(now, HISTORY_MONTHS), now.strftime("%Y-%m")]
+            for t in self.db_service.get_transactions(month)
+        ]
+
+    def _remind_annual_fees(
+        self,
+        transactions: list[Transaction],
+        today: date,
+        settings: dict[str, str],
+        recipients: list[str],
+    ) -> int:
+        """
+        Emails each member about their cards with an annual fee posting within
+        FEE_REMINDER_DAYS, with a year of spend on the card for comparison. Each
+        fee is reminded about once, unless its reminder is snoozed or dismissed.
+        Returns the number of emails sent.
+        """
+        reminded = json.loads(settings.get("AnnualFeeReminders", "{}"))
+        suppressed = self._suppressed_alerts("annual-fee", today)
+        current = {}
+        emails = 0
+        for email in recipients:
+            due = []
+            keys = []
+            for card in upcoming_fees(self.db_service.get_accounts(email), today):
+                key = f"{email}/{card['accountId']}"
+                if key in suppressed:
+                    continue
+                current[key] = card["feeDate"]
+                if reminded.get(key) != card["feeDate"]:
+                    due.append(fee_summary(card, transactions, today))
+                    keys.append(key)
+            if due:
+                emails += 1
+                self._send_alert(
+                    "annual-fee",
+                    keys,
+                    [email],
+                    "Card annual fees are coming up",
+                    self.email_renderer.render_annual_fee_reminder(due),
+                )
+
+        # Fees that have posted drop out, re-arming next year's reminder
+        if current != reminded:
+            self.db_service.save_setting("AnnualFeeReminders", json.dumps(current))
+        logging.info("Annual fee check found %d upcoming fees", len(current))
+        return emails
+
+    def _close_statements(self, today: date, recipients: list[str]) -> int:
+        """
+        Takes each member's card statements that closed since the card's last one:
+        the card's current balance goes into its statement history and becomes its
+        statement balance. Returns the number of statements taken.
+        """
+        closed = 0
+        for email in recipients:
+            for card in self.db_service.get_accounts(email):
+                if not card.get("statementCloseDay") or card.get("balance") is None:
+                    continue
+                close_day = int(card["statementCloseDay"])
+                last = card.get("lastStatementDate")
+                close = statement_due(
+                    close_day, date.fromisoformat(last) if last else None, today
+                )
+                if close is None:
+                    continue
+                closed += self.db_service.close_statement(
+                    email,
+                    card["accountId"],
+                    {
+                        "closeDate": close.isoformat(),
+                        "cycleStart": cycle_start(close_day, close).isoformat(),
+                        "balance": card["balance"],
+                        "limit": card.get("limit"),
+                    },
+                )
+        logging.info("Closed %d card statements", closed)
+        return closed
+
+    def _convert_pending_currencies(self) -> int:
+        """
+        Converts the foreign currency transactions saved while their rates couldn't
+        be fetched, now that they can be. Those whose rates still can't be are left
+        for the next run. Returns the number converted.
+        """
+        pending = self.db_service.get_unconverted_transactions()
+        if not pending:
+            return 0
+        converted = self.exchange_rates.convert_saved(pending)
+        self.db_service.save_conversions(converted)
+        logging.info(
+            "Converted %d of %d foreign currency transactions",
+            len(converted),
+            len(pending),
+        )
+        return len(converted)
+
+    def _roll_over_savings(self, today: date, recipients: list[str]) -> int:
+        """
+        Starts each member's savings for every month since their last saved one,
+        up to this month, as the savings page would on its first visit: each
+        month's ending balance becomes the next month's starting balance and its
+        items, less one-offs, are carried over. Run nightly, so a missed run is
+        made up by the next. Members who already saved this month's, or saved
+        none in the last SAVINGS_ROLLOVER_MONTHS, are left alone. Returns the
+        number of months started.
+        """
+        month = today.strftime("%Y-%m")
+        earlier = previous_months(today, SAVINGS_ROLLOVER_MONTHS)
+        started = 0
+        for email in recipients:
+            if self.db_service.get_savings(month, email) is not None:
+                continue
+            # Newest first, until the last saved month
+            missing, source = [month], None
+            for previous in reversed(earlier):
+                source = self.db_service.get_savings(previous, email)
+                if source is not None:
+                    break
+                missing.append(previous)
+            if source is None:
+                continue
+            for target in reversed(missing):
+                source = copy_forward(source, exclude_one_off=True)
+                self.db_service.save_savings(target, source, email)
+                started += 1
+        logging.info("Rolled over %d months of savings", started)
+        return started
+
+    def _remind_payments(
+        self,
+        transactions: list[Transaction],
+        today: date,
+        settings: dict[str, str],
+        recipients: list[str],
+    ) -> int:
+        """
+        Emails each member about card payments coming due that no payment has
+        been seen for, once for each of the card's reminder lead days (or the
+        PaymentReminderLeadDays setting's) it reaches. If there is still none the
+        day before the due date, a high-priority escalation follows unless the
+        member acknowledged a reminder. The leads sent are logged per card and
+        due date, so nothing is sent twice. Returns the number of emails sent.
+        """
+        default_leads = self._reminder_lead_days(settings)
+        sent = json.loads(settings.get("PaymentReminders", "{}"))
+        current = {}
+        emails = 0
+        for email in recipients:
+            reminders, escalations = [], []
+            for card in self.db_service.get_accounts(email):
+                if not card.get("dueDay") or (card.get("balance") or 0) <= 0:
+                    continue
+                leads = card.get("reminderLeadDays") or default_leads
+                due = next_due_date(int(card["dueDay"]), today)
+                days_left = (due - today).days
+                if days_left > max(leads) or payment_made(
+                    transactions, card.get("mask"), due
+                ):
+                    continue
+
+                key = f"{email}/{card['accountId']}"
+                state = sent.get(key, {})
+                same_cycle = state.get("due") == due.isoformat()
+                stage = state.get("stage") if same_cycle else None
+                # Logged before leads were, when a cycle had a single reminder
+                log = state.get("leads", list(leads)) if same_cycle else []
+                due_leads = leads_due(leads, days_left, log)
+                summary = {
+                    **card,
+                    "dueDate": due.isoformat(),
+                    "daysLeft": days_left,
+                    # What a month of carrying the balance costs
+                    "projectedInterest": float(
+                        monthly_interest(
+                            Decimal(str(card["balance"])),
+                            Decimal(str(card.get("apr") or 0)),
+                        )
+                    ),
+                    "ackUrl": self._reminder_ack_url(key, due.isoformat()),
+                }
+                if stage == "reminded" and days_left <= 1:
+                    # Stands in for any reminder due today
+                    escalations.append(summary)
+                    stage = "escalated"
+                elif stage in (None, "reminded") and due_leads:
+                    reminders.append(summary)
+                    stage = "reminded"
+                log = sorted({*log, *due_leads}, reverse=True)
+                current[key] = {"due": due.isoformat(), "stage": stage, "leads": log}
+
+            if reminders:
+                emails += 1
+                self._send_reminder(
+                    [email],
+                    "Card payments are due soon",
+                    self.email_renderer.render_payment_reminder(reminders),
+                )
+            if escalations:
+                emails += 1
+                self._send_reminder(
+                    [email],
+                    "Action needed: card payment due tomorrow",
+                    self.email_renderer.render_payment_reminder(
+                        escalations, escalated=True
+                    ),
+                    high_priority=True,
+                )
+
+        # Paid cards and past due dates drop out, re-arming the next cycle
+        if current != sent:
+            self.db_service.save_setting("PaymentReminders", json.dumps(current))
+        logging.info("Payment check found %d unpaid cards due", len(current))
+        return emails
+
+    @staticmethod
+    def _reminder_lead_days(settings: dict[str, str]) -> list[int]:
+        """
+        Days before a due date payment reminders go out, from the
+        PaymentReminderLeadDays setting, or else the single PaymentReminderDays.
+        """
+        leads = json.loads(settings.get("PaymentReminderLeadDays") or "[]")
+        return leads or [int(settings.get("PaymentReminderDays", "5"))]
+
+    @staticmethod
+    def _reminder_ack_url(key: str, due: str) -> str | None:
+        """
+        The signed "I've paid this" link for a reminder, if signing is set up. It
+        opens a page that acknowledges the reminder once the member confirms.
+        """
+        reminder_id = _reminder_id(key, due)
+        signature = _sign_reminder(reminder_id)
+        if signature is None:
+            return None
+        app_url = os.environ.get("APP_URL", "")
+        query = f"action=reminder&id={reminder_id}&sig={signature}"
+        return f"{app_url}/confirm.html?{query}"
+
+    def handle_reminder_ack(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Acknowledges a payment reminder, posted with the signature from its email
+        link, so the payment isn't escalated this cycle.
+        """
+        logging.info("Processing reminder acknowledgement request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse(
+                "Invalid JSON", status_code=HTTPStatus.BAD_REQUEST
+            )
+        errors = SIGNED_LINK_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        reminder_id = req.route_params.get("id", "")
+        expected = _sign_reminder(reminder_id)
+        if expected is None or not hmac.compare_digest(expected, req_body["sig"]):
+            return func.HttpResponse("Forbidden", status_code=HTTPStatus.FORBIDDEN)
+
+        try:
+            key, due = _parse_reminder_id(reminder_id)
+        except ValueError:
+            return func.HttpResponse(
+                "Invalid reminder", status_code=HTTPStatus.BAD_REQUEST
+            )
+
+        try:
+            sent = json.loads(
+                self.db_service.get_settings().get("PaymentReminders", "{}")
+            )
+            state = sent.get(key)
+            if not state or state["due"] != due:
+                return func.HttpResponse(
+                    "This reminder has expired", status_code=HTTPStatus.GONE
+                )
+
+            if state["stage"] != "acknowledged":
+                sent[key] = {**state, "stage": "acknowledged"}
+                self.db_service.save_setting("PaymentReminders", json.dumps(sent))
+                self._record_activity(
+                    "reminder",
+                    f"Acknowledged the card payment due {due}",
+                    user_email,
+                    {"reminder": key, "due": due},
+                )
+
+            return func.HttpResponse(
+                "Thanks! You won't be reminded about this payment again.",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in reminder acknowledgement handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _warn_promo_expiries(
+        self, today: date, settings: dict[str, str], recipients: list[str]
+    ) -> int:
+        """
+        Emails each member about their cards whose promo APR ends within the
+        PromoExpiryWarningDays setting while carrying a balance, with the monthly
+        interest that balance would accrue afterwards. Each expiry is warned once,
+        unless its warning is snoozed or dismissed. Returns the number of emails sent.
+        """
+        days = int(settings.get("PromoExpiryWarningDays", "30"))
+        warned = json.loads(settings.get("PromoExpiryWarnings", "{}"))
+        suppressed = self._suppressed_alerts("promo-expiry", today)
+        current = {}
+        emails = 0
+        for email in recipients:
+            due = []
+            accounts = self.db_service.get_accounts(email)
+            for card in expiring_promos(accounts, today, days):
+                key = f"{email}/{card['accountId']}"
+                if key in suppressed:
+                    continue
+                current[key] = card["promoExpiry"]
+                if warned.get(key) != card["promoExpiry"]:
+                    due.append(card)
+            if due:
+                emails += 1
+                self._send_alert(
+                    "promo-expiry",
+                    [f"{email}/{card['accountId']}" for card in due],
+                    [email],
+                    "Promo APRs are ending",
+                    self.email_renderer.render_promo_expiry_warning(due),
+                )
+
+        # Expired or paid-off promos drop out
+        if current != warned:
+            self.db_service.save_setting("PromoExpiryWarnings", json.dumps(current))
+        logging.info("Promo APR check found %d expiring promos", len(current))
+        return emails
+
+    @staticmethod
+    def _utilization_threshold(settings: dict[str, str]) -> float:
+        """The aggregate utilization above which members are alerted."""
+        return float(
+            settings.get(
+                "UtilizationAlertThreshold",
+                DEFAULT_SETTINGS["UtilizationAlertThreshold"],
+            )
+        )
+
+    def _alert_high_utilization(
+        self, today: date, settings: dict[str, str], recipients: list[str]
+    ) -> int:
+        """
+        Emails each member whose utilization across all their cards first rises
+        above the threshold. The alert re-arms once it drops back below, and isn't
+        sent while it's snoozed or dismissed. Returns the number of emails sent.
+        """
+        threshold = self._utilization_threshold(settings)
+        alerted = set(json.loads(settings.get("UtilizationAlerts", "[]")))
+        suppressed = self._suppressed_alerts("utilization", today)
+        current = set()
+        emails = 0
+        for email in recipients:
+            if email in suppressed:
+                continue
+            accounts = self.db_service.get_accounts(email)
+            aggregate = aggregate_utilization(accounts)
+            if aggregate["ratio"] is None or aggregate["ratio"] <= threshold:
+                continue
+            current.add(email)
+            if email not in alerted:
+                emails += 1
+                self._send_alert(
+                    "utilization",
+                    [email],
+                    [email],
+                    "Credit utilization is high",
+                    self.email_renderer.render_utilization_alert(
+                        aggregate,
+                        threshold,
+                        [
+                            {**a, "utilization": card_utilization(a)}
+                            for a in accounts
+                            if card_utilization(a) is not None
+                        ],
+                    ),
+                )
+
+        if current != alerted:
+            self.db_service.save_setting(
+                "UtilizationAlerts", json.dumps(sorted(current))
+            )
+        logging.info("Utilization check found %d members over", len(current))
+        return emails
+
+    def _warn_over_allocation(
+        self,
+        transactions: list[Transaction],
+        today: date,
+        settings: dict[str, str],
+        people: list[dict],
+    ) -> int:
+        """
+        Emails each member whose planned savings contributions this month plus the
+        required payments on their cards exceed the income detected on their
+        accounts, before the money runs short. Members with no detected income
+        are skipped, since there's nothing to compare against. Each member is
+        warned once a month while over, unless the warning is snoozed or dismissed.
+        Returns the number of emails sent.
+        """
+        month = today.strftime("%Y-%m")
+        warned = json.loads(settings.get("OverAllocationAlerts", "{}"))
+        suppressed = self._suppressed_alerts("over-allocation", today)
+        current = {}
+        emails = 0
+        for person in people:
+            email = person["Email"]
+            if email in suppressed:
+                continue
+            cards = self.db_service.get_accounts(email)
+            streams = cashflow.detect_income(
+                [t for t in transactions if t.account_number in person["Accounts"]],
+                [int(c["mask"]) for c in cards if c.get("mask")],
+            )
+            income = cashflow.monthly_income(streams)
+            if income <= 0:
+                continue
+            savings = self.db_service.get_savings(month, email) or {"items": []}
+            contributions = sum(
+                (Decimal(str(i.get("cost") or 0)) for i in savings["items"]),
+                start=Decimal("0.00"),
+            )
+            payments = [
+                {**c, "payment": float(cashflow.required_payment(c))}
+                for c in cards
+                if cashflow.required_payment(c) > 0
+            ]
+            card_payments = sum(
+                (Decimal(str(c["payment"])) for c in payments), start=Decimal("0.00")
+            )
+            shortfall = cashflow.over_allocation(income, contributions, card_payments)
+            if shortfall is None:
+                continue
+            current[email] = month
+            if warned.get(email) != month:
+                emails += 1
+                self._send_alert(
+                    "over-allocation",
+                    [email],
+                    [email],
+                    "Your plans this month exceed your income",
+                    self.email_renderer.render_over_allocation_alert(
+                        {
+                            "month": month,
+                            "income": float(income),
+                            "contributions": float(contributions),
+                            "cardPayments": float(card_payments),
+                            "shortfall": float(shortfall),
+                        },
+                        payments,
+                    ),
+                )
+
+        if current != warned:
+            self.db_service.save_setting("OverAllocationAlerts", json.dumps(current))
+        logging.info("Cash flow check found %d members over-allocated", len(current))
+        return emails
+
+    def run_recurring_charge_job(self) -> None:
+        """
+        Timer Trigger handler. Detects recurring charges in the last year of
+        transactions, records them as subscriptions, starts the savings of months
+        since each member's last saved one, converts foreign currency transactions
+        saved while their rates were unavailable, and alerts on missing bills
+        (catching failed autopays), price increases, upcoming card annual fees,
+        unpaid card payments coming due, expiring promo APRs, high overall credit
+        utilization and plans that exceed income.
+
+        A failing check doesn't stop the others. The run is recorded in the nightly
+        job history, and if anything failed the admins are alerted and the run is
+        failed once every check has had its turn.
+        """
+        now = datetime.now()
+        details = {
+            "cardsEvaluated": 0,
+            "statementsClosed": 0,
+            "savingsRolledOver": 0,
+            "currenciesConverted": 0,
+            "remindersSent": 0,
+        }
+        errors = []
+        # Converted first, so the checks see home currency amounts
+        try:
+            details["currenciesConverted"] = self._convert_pending_currencies()
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error converting foreign currency transactions: %s", e)
+            errors.append(f"currency conversions: {e}")
+
+        try:
+            transactions = self._history_transactions(now)
+            charges = detect_recurring(transactions)
+            settings = self.db_service.get_settings()
+            people = self.db_service.get_all_people()
+            recipients = [p["Email"] for p in people]
+            details["cardsEvaluated"] = sum(
+                len(self.db_service.get_accounts(email)) for email in recipients
+            )
+
+            today = now.date()
+            # Closed first, so reminders see the statement balances they roll over to
+            try:
+                details["statementsClosed"] = self._close_statements(today, recipients)
+            except Exception as e:  # pylint: disable=broad-exception-caught
+                logging.error("Error closing card statements: %s", e)
+                errors.append(f"statement closes: {e}")
+
+            try:
+                details["savingsRolledOver"] = self._roll_over_savings(
+                    today, recipients
+                )
+            except Exception as e:  # pylint: disable=broad-exception-caught
+                logging.error("Error rolling over savings: %s", e)
+                errors.append(f"savings rollover: {e}")
+
+            checks = [
+                (
+                    "missing bills",
+                    lambda: self._alert_missing_bills(
+                        charges, today, settings, recipients
+                    ),
+                ),
+                (
+                    "price changes",
+                    lambda: self._track_price_changes(
+                        charges, today, settings, recipients
+                    ),
+                ),
+                (
+                    "annual fees",
+                    lambda: self._remind_annual_fees(
+                        transactions, today, settings, recipients
+                    ),
+                ),
+                (
+                    "card payments",
+                    lambda: self._remind_payments(
+                        transactions, today, settings, recipients
+                    ),
+                ),
+                (
+                    "promo APRs",
+                    lambda: self._warn_promo_expiries(today, settings, recipients),
+                ),
+                (
+                    "credit utilization",
+                    lambda: self._alert_high_utilization(
+                        today, settings, recipients
+                    ),
+                ),
+                (
+                    "over-allocation",
+                    lambda: self._warn_over_allocation(
+                        transactions, today, settings, people
+                    ),
+                ),
+            ]
+            for name, check in checks:
+                try:
+                    details["remindersSent"] += check()
+                except Exception as e:  # pylint: disable=broad-exception-caught
+                    logging.error("Error running %s check: %s", name, e)
+                    errors.append(f"{name}: {e}")
+
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error running recurring charge job: %s", e)
+            errors.append(str(e))
+
+        self._record_job_run(NIGHTLY_JOB, now, details, errors)
+        if errors:
+            self._alert_admins(
+                "The nightly job failed",
+                self.email_renderer.render_job_alert(
+                    "The nightly reminder job failed.", errors
+                ),
+            )
+            raise RuntimeError(f"Nightly job failed: {'; '.join(errors)}")
+
+    def run_nightly_job_check(self) -> None:
+        """
+        Timer Trigger handler. Alerts the admins when the nightly job hasn't run
+        for NIGHTLY_STALE_AFTER, e.g. because its trigger stopped firing. Nothing
+        is reported until the job has run at least once.
+        """
+        try:
+            runs = self.db_service.get_job_runs(NIGHTLY_JOB, 1)
+            if not runs:
+                logging.info("The nightly job has no recorded runs yet")
+                return
+
+            last_run = datetime.fromisoformat(runs[0]["startedAt"])
+            if datetime.now() - last_run >= NIGHTLY_STALE_AFTER:
+                self._alert_admins(
+                    "The nightly job hasn't run",
+                    self.email_renderer.render_job_alert(
+                        "The nightly reminder job hasn't run since "
+                        f"{last_run:%Y-%m-%d %H:%M} UTC.",
+                        [],
+                    ),
+                )
+
+        except Exception as e:
+            logging.error("Error checking the nightly job: %s", e)
+            raise
+
+    def handle_nightly_job_history(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists the nightly job's recent runs, newest first, with the cards each
+        evaluated, the reminders it sent and any errors. Restricted to admins.
+        """
+        logging.info("Processing nightly job history request.")
+
+        _, error_response = self._require_admin(req)
+        if error_response:
+            return error_response
+
+        errors = JOB_HISTORY_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            limit = min(
+                int(req.params.get("limit", DEFAULT_JOB_HISTORY_LIMIT)),
+                MAX_JOB_HISTORY_LIMIT,
+            )
+            return func.HttpResponse(
+                json.dumps({"runs": self.db_service.get_job_runs(NIGHTLY_JOB, limit)}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in nightly job history handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_subscriptions(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Lists the detected recurring charges with their price change history."""
+        logging.info("Processing subscriptions request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            return func.HttpResponse(
+                json.dumps({"subscriptions": self.db_service.get_subscriptions()}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in subscriptions handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_cards(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists the caller's synced cards with each one's utilization, and the
+        aggregate utilization across all of them against its alert threshold.
+        """
+        logging.info("Processing cards request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            accounts = self.db_service.get_accounts(user_email)
+            threshold = self._utilization_threshold(self.db_service.get_settings())
+            aggregate = aggregate_utilization(accounts)
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "cards": [
+                            {**a, "utilization": card_utilization(a)}
+                            for a in accounts
+                        ],
+                        "utilization": {
+                            **aggregate,
+                            "alertAbove": threshold,
+                            "overThreshold": aggregate["ratio"] is not None
+                            and aggregate["ratio"] > threshold,
+                        },
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in cards handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_card_statements(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Lists one of the caller's cards' closed statements, newest first."""
+        logging.info("Processing card statements request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        account_id = req.route_params.get("id", "")
+        try:
+            card = next(
+                (
+                    a
+                    for a in self.db_service.get_accounts(user_email)
+                    if a["accountId"] == account_id
+                ),
+                None,
+            )
+            if card is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "accountId": account_id,
+                        "statementCloseDay": card.get("statementCloseDay"),
+                        "statementBalance": card.get("statementBalance"),
+                        "statements": self.db_service.get_statements(
+                            user_email, account_id
+                        ),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in card statements handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_interest_projection(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Projects the interest one of the caller's cards charges if only the
+        payment given is made each month, or else the card's minimum payment (as
+        its issuer reports it, or estimated).
+        """
+        logging.info("Processing interest projection request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = INTEREST_PROJECTION_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        account_id = req.route_params.get("id", "")
+        try:
+            card = next(
+                (
+                    a
+                    for a in self.db_service.get_accounts(user_email)
+                    if a["accountId"] == account_id
+                ),
+                None,
+            )
+            if card is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            balance = Decimal(str(card.get("balance") or 0))
+            apr = Decimal(str(card.get("apr") or 0))
+            if req.params.get("payment"):
+                payment, source = Decimal(req.params["payment"]), "target"
+            elif card.get("minimumPayment"):
+                payment = Decimal(str(card["minimumPayment"]))
+                source = "minimum"
+            else:
+                payment, source = minimum_payment(balance, apr), "estimatedMinimum"
+            promo_apr = card.get("promoApr")
+            projection = project_interest(
+                balance,
+                apr,
+                payment,
+                int(req.params.get("months", DEFAULT_PROJECTION_MONTHS)),
+                None if promo_apr is None else Decimal(str(promo_apr)),
+                promo_months_left(card.get("promoExpiry"), datetime.now().date()),
+            )
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "accountId": account_id,
+                        "balance": float(balance),
+                        "apr": float(apr),
+                        "payment": float(payment),
+                        "paymentSource": source,
+                        **projection,
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in interest projection handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_card_fees(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Compares the annual fee on each of the caller's synced cards with the last
+        year of spend on it, to help decide which cards are worth keeping.
+        """
+        logging.info("Processing card fees request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            now = datetime.now()
+            cards = [
+                a for a in self.db_service.get_accounts(user_email) if a["annualFee"]
+            ]
+            transactions = self._history_transactions(now) if cards else []
+            summaries = [fee_summary(c, transactions, now.date()) for c in cards]
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "cards": sorted(summaries, key=lambda c: str(c["feeDate"])),
+                        "totalFees": sum(c["annualFee"] for c in summaries),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in card fees handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _reward_cards(self, user_email: str) -> list[dict]:
+        """The caller's synced cards with a flat or per-category reward rate."""
+        return [
+            a
+            for a in self.db_service.get_accounts(user_email)
+            if a["rewardRate"] or a["categoryRewardRates"]
+        ]
+
+    def handle_card_rewards(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Estimates the rewards each of the caller's cards earned in a month (default
+        current) from its reward rates and the month's imported transactions.
+        """
+        logging.info("Processing card rewards request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = REWARDS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+        month = req.params.get("month") or datetime.now().strftime("%Y-%m")
+
+        try:
+            cards = self._reward_cards(user_email)
+            transactions = self.db_service.get_transactions(month) if cards else []
+            rewards = [card_rewards(c, transactions) for c in cards]
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "month": month,
+                        "cards": rewards,
+                        "totalRewards": round(sum(c["rewards"] for c in rewards), 2),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in card rewards handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_best_card(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Suggests which of the caller's cards to use for each category (or just the
+        requested one): the card with the highest reward rate for it.
+        """
+        logging.info("Processing best card request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = BEST_CARD_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+        category = req.params.get("category")
+
+        try:
+            best = best_cards(
+                self._reward_cards(user_email),
+                [self._category(category)] if category else self._categories(),
+            )
+            return func.HttpResponse(
+                json.dumps({"best": best}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in best card handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _record_import_debt(self, group: Group, blob_name: str) -> str | None:
+        """
+        Adds the debt computed for an import to the ledger. Reprocessing the same blob
+        replaces its entry. Failures are logged so the summary email still goes out.
+        Returns the entry's ID, or None if it couldn't be recorded.
+        """
+        p1, p2 = group.members
+        source = hashlib.sha256(blob_name.encode("utf-8")).hexdigest()[:16]
+        entry = LedgerEntry.from_debt(
+            f"IMPORT_{source}",
+            "import",
+            p1.email,
+            p2.email,
+            group.get_debt(p1, p2),
+            group.get_newest_transaction().strftime("%Y-%m"),
+            datetime.now().isoformat(),
+        )
+        try:
+            self.db_service.save_ledger_entry(entry)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to record debt for %s: %s", blob_name, e)
+            return None
+        return entry.entry_id
+
+    def _outstanding_balance(self) -> dict | None:
+        """Returns the ledger balance for the summary email, or None if unavailable."""
+        try:
+            return running_balance(self.db_service.get_ledger_entries())["balance"]
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Failed to compute outstanding balance: %s", e)
+            return None
+
+    def _owed_for_month(
+        self, month: str, people: list[Person], entries: list[LedgerEntry]
+    ) -> Decimal:
+        """
+        What the first of two members still owes the second for a month (negative
+        if the second owes): the month's debt less the settlements paying it.
+        """
+        group = Group(people, self._debt_excluded_categories())
+        group.add_transactions(
+            self.db_service.apply_owner_overrides(
+                self.db_service.get_transactions(month)
+            )
+        )
+        p1, p2 = people
+        return group.get_debt(p1, p2) - settled_in_month(
+            entries, p1.email, p2.email, month
+        )
+
+    def handle_debt_settle(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Records a (partial) settlement, paid by whoever owes. With a month, it
+        pays that month's debt and is netted out of the month's debt and report;
+        without one, it pays down the outstanding ledger balance. Either way it
+        may not exceed what is owed.
+        """
+        logging.info("Processing debt settle request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = SETTLE_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            entries = self.db_service.get_ledger_entries()
+            month = req_body.get("month", "")
+            if month:
+                people = [
+                    self._person(p) for p in self.db_service.get_all_people()
+                ]
+                if len(people) != 2:
+                    return func.HttpResponse(
+                        "Debt is only worked out between exactly two members",
+                        status_code=HTTPStatus.CONFLICT,
+                    )
+                owed = self._owed_for_month(month, people, entries)
+                p1, p2 = (p.email for p in people)
+                debtor, creditor = (p1, p2) if owed >= 0 else (p2, p1)
+                outstanding = abs(owed)
+            else:
+                balance = running_balance(entries)["balance"]
+                debtor, creditor = str(balance["debtor"]), str(balance["creditor"])
+                outstanding = Decimal(str(balance["amount"]))
+            if not outstanding:
+                return func.HttpResponse(
+                    "No outstanding balance", status_code=HTTPStatus.CONFLICT
+                )
+
+            amount = Decimal(str(req_body["amount"])).quantize(Decimal("0.01"))
+            if amount > outstanding:
+                return self._validation_error(
+                    [FieldError("amount", "must not exceed the outstanding balance")]
+                )
+
+            now = datetime.now()
+            # The debtor pays the creditor, so the entry runs the other way
+            self.db_service.save_ledger_entry(
+                LedgerEntry(
+                    f"SETTLE_{now.strftime('%Y%m%d%H%M%S')}_{uuid.uuid4().hex[:8]}",
+                    "settlement",
+                    creditor,
+                    debtor,
+                    amount,
+                    now.strftime("%Y-%m"),
+                    now.isoformat(),
+                    month,
+                )
+            )
+            self._record_activity(
+                "settlement",
+                f"{debtor} paid {creditor} ${amount:,.2f}"
+                + (f" for {month}" if month else ""),
+                user_email,
+                {
+                    "from": debtor,
+                    "to": creditor,
+                    "amount": float(amount),
+                    "month": month or None,
+                },
+            )
+
+            entries = self.db_service.get_ledger_entries()
+            return func.HttpResponse(
+                json.dumps(running_balance(entries)["balance"]),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in debt settle handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_debt_history(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Returns every debt ledger entry with the running balance after each."""
+        logging.info("Processing debt history request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        try:
+            entries = self.db_service.get_ledger_entries()
+            return func.HttpResponse(
+                json.dumps(running_balance(entries)),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in debt history handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _handle_savings_get(
+        self, _: func.HttpRequest, month: str, user_email: str
+    ) -> func.HttpResponse:
+        """Helper for GET savings request."""
+        data = self.db_service.get_savings(month, user_email)
+        if data is None:
+            return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+        return func.HttpResponse(
+            json.dumps(data),
+            mimetype="application/json",
+            status_code=HTTPStatus.OK,
+        )
+
+    def _handle_savings_post(
+        self, req: func.HttpRequest, month: str, user_email: str
+    ) -> func.HttpResponse:
+        """Helper for POST savings request."""
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = SAVINGS_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        target_month = req_body.get("month", month)
+        self.db_service.save_savings(target_month, req_body, user_email)
+        return func.HttpResponse("Saved successfully", status_code=HTTPStatus.OK)
+
+    def handle_savings_dbrequest(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Handles getting and updating savings calculation data."""
+        logging.info("Processing savings request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = SAVINGS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            # Default month to current month if not provided
+            current_month = datetime.now().strftime("%Y-%m")
+            month = req.params.get("month", current_month)
+
+            if req.method == "GET":
+                return self._handle_savings_get(req, month, user_email)
+
+            if req.method == "POST":
+                return self._handle_savings_post(req, month, user_email)
+
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in savings handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+        return func.HttpResponse(
+            "Method not supported", status_code=HTTPStatus.METHOD_NOT_ALLOWED
+        )
+
+    def handle_savings_copy(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Starts a month's savings from an earlier month's: its items are copied, less
+        any marked one-off if excludeOneOff is set, and its ending balance becomes
+        the new month's starting balance. A month that's already saved is left alone.
+        """
+        logging.info("Processing savings copy request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = SAVINGS_COPY_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+        if req.params["to"] <= req.params["from"]:
+            return self._validation_error([FieldError("to", "must be after from")])
+
+        try:
+            source = self.db_service.get_savings(req.params["from"], user_email)
+            if source is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+            if self.db_service.get_savings(req.params["to"], user_email) is not None:
+                return func.HttpResponse(
+                    f"Savings for {req.params['to']} are already saved",
+                    status_code=HTTPStatus.CONFLICT,
+                )
+
+            exclude = str(req.params.get("excludeOneOff", "false")).lower() == "true"
+            data = copy_forward(source, exclude)
+            self.db_service.save_savings(req.params["to"], data, user_email)
+
+            return func.HttpResponse(
+                json.dumps({**data, "month": req.params["to"]}),
+                mimetype="application/json",
+                status_code=HTTPStatus.CREATED,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in savings copy handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    @staticmethod
+    def _csv_response(content: str, file_name: str) -> func.HttpResponse:
+        """Builds a CSV download response."""
+        return func.HttpResponse(
+            content,
+            mimetype="text/csv",
+            headers={"Content-Disposition": f'attachment; filename="{file_name}"'},
+            status_code=HTTPStatus.OK,
+        )
+
+    def handle_savings_export(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Exports a savings month as CSV with running totals and remaining balance."""
+        logging.info("Processing savings export request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = SAVINGS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            month = req.params.get("month", datetime.now().strftime("%Y-%m"))
+            data = self.db_service.get_savings(month, user_email)
+            if data is None:
+                return func.HttpResponse("Not Found", status_code=HTTPStatus.NOT_FOUND)
+
+            return self._csv_response(exports.savings_csv(data), f"savings-{month}.csv")
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in savings export handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_budget_export(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Exports the budget status of every saved month in a year as CSV."""
+        logging.info("Processing budget export request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = BUDGET_EXPORT_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            year = req.params["year"]
+            months = {}
+            for m in range(1, 13):
+                month = f"{year}-{m:02d}"
+                data = self.db_service.get_savings(month, user_email)
+                if data is not None:
+                    months[month] = data
+
+            return self._csv_response(
+                exports.budget_status_csv(months), f"budget-{year}.csv"
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in budget export handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_transactions_export(
+        self, req: func.HttpRequest
+    ) -> func.HttpResponse | exports.ExportStream:
+        """
+        Exports the stored transactions of a range of months as CSV, NDJSON or an
+        Excel workbook, streamed a row at a time as pages arrive from the table, so
+        even multi-hundred-thousand-row ranges never sit in memory. A filter
+        expression narrows the rows exported. Returns the stream for the entry
+        point to send, or an error response.
+        """
+        logging.info("Processing transactions export request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = TRANSACTIONS_EXPORT_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        months = months_between(req.params["from"], req.params["to"])
+        if not months:
+            return self._validation_error([FieldError("to", "is before from")])
+        if len(months) > MAX_EXPORT_MONTHS:
+            return self._validation_error(
+                [FieldError("to", f"is more than {MAX_EXPORT_MONTHS} months after from")]
+            )
+        try:
+            selected = self._transaction_filter(req)
+        except filters.FilterError as e:
+            return self._validation_error([FieldError("filter", str(e))])
+
+        transactions = filter(
+            selected.matches, self.db_service.iter_transactions(months)
+        )
+        return self._export_stream(
+            transactions,
+            req.params.get("format", "csv"),
+            f"transactions-{months[0]}-to-{months[-1]}",
+        )
+
+    def handle_export(
+        self, req: func.HttpRequest
+    ) -> func.HttpResponse | exports.ExportStream:
+        """
+        Exports a month's stored transactions as CSV or an Excel workbook, in the
+        upload format with their category and ignore flags, so they can be edited
+        in a spreadsheet and uploaded again. Streamed like the transactions export.
+        """
+        logging.info("Processing export request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = EXPORT_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        month = req.params["month"]
+        return self._export_stream(
+            self.db_service.iter_transactions([month]),
+            req.params.get("format", "csv"),
+            f"transactions-{month}",
+        )
+
+    def _export_stream(
+        self, transactions: Iterator[Transaction], export_format: str, name: str
+    ) -> exports.ExportStream:
+        """Renders transactions in an export format, as a download named name."""
+        if export_format == "ndjson":
+            return exports.ExportStream(
+                exports.transactions_ndjson_stream(transactions),
+                "application/x-ndjson",
+                f"{name}.ndjson",
+            )
+        if export_format == "xlsx":
+            return exports.ExportStream(
+                exports.transactions_xlsx_stream(transactions),
+                XLSX_MEDIA_TYPE,
+                f"{name}.xlsx",
+            )
+        return exports.ExportStream(
+            exports.transactions_csv_stream(transactions),
+            "text/csv",
+            f"{name}.csv",
+        )
+
+    @staticmethod
+    def _transaction_filter(req: func.HttpRequest) -> filters.Filter:
+        """The request's filter expression, or one every transaction matches."""
+        expression = req.params.get("filter")
+        if not expression:
+            return filters.Filter("", lambda _: True)
+        return filters.parse(expression)
+
+    def handle_transactions(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists a month's stored transactions (this month by default) with their
+        IDs, narrowed by a filter expression if one is given, in the order sort
+        names (newest first by default).
+        """
+        logging.info("Processing transactions request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = TRANSACTIONS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+        try:
+            selected = self._transaction_filter(req)
+        except filters.FilterError as e:
+            return self._validation_error([FieldError("filter", str(e))])
+
+        try:
+            month = req.params.get("month", datetime.now().strftime("%Y-%m"))
+            keyed = [
+                (row_key, t)
+                for row_key, t in self.db_service.get_keyed_transactions(month)
+                if selected.matches(t)
+            ]
+            sort = req.params.get("sort", "newest")
+            key, descending = TRANSACTION_SORTS[sort]
+            keyed.sort(key=lambda pair: key(pair[1]), reverse=descending)
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "month": month,
+                        "filter": selected.expression or None,
+                        "sort": sort,
+                        "transactions": [
+                            {"id": row_key, **exports.transaction_json(t)}
+                            for row_key, t in keyed
+                        ],
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in transactions handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_transactions_search(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Finds stored transactions whose description has every word of q in it,
+        newest first, over this month and those before it in the window (months,
+        or TRANSACTION_SEARCH_MONTHS). Amount and date bounds narrow the search.
+        """
+        logging.info("Processing transactions search request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = TRANSACTIONS_SEARCH_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+        words = search.terms(req.params["q"])
+        if not words:
+            return self._validation_error(
+                [FieldError("q", "must have a word to search for")]
+            )
+        start, end = (
+            date.fromisoformat(req.params[p]) if req.params.get(p) else None
+            for p in ("from", "to")
+        )
+        if start and end and end < start:
+            return self._validation_error([FieldError("to", "must not be before from")])
+        min_amount, max_amount = (
+            Decimal(str(req.params[p])) if req.params.get(p) is not None else None
+            for p in ("minAmount", "maxAmount")
+        )
+
+        try:
+            now = datetime.now()
+            window = int(req.params.get("months") or self._search_months())
+            months = [
+                m
+                for m in [*previous_months(now, window - 1), now.strftime("%Y-%m")]
+                if not (start and m < start.isoformat()[:7])
+                and not (end and m > end.isoformat()[:7])
+            ]
+
+            found = []
+            for month in months:
+                if self.search_index.enabled:
+                    keyed = [
+                        (row_key, t)
+                        for row_key, t in self.search_index.month(
+                            self.db_service.transactions_table,
+                            month,
+                            functools.partial(
+                                self.db_service.get_keyed_transactions, month
+                            ),
+                        )
+                        if search.within(t, min_amount, max_amount, start, end)
+                    ]
+                else:
+                    keyed = self.db_service.search_transactions(
+                        month, min_amount, max_amount, start, end
+                    )
+                found += [
+                    (row_key, t)
+                    for row_key, t in keyed
+                    if search.matches(words, t.name)
+                ]
+            found.sort(key=lambda pair: pair[1].date, reverse=True)
+
+            limit = int(req.params.get("limit") or DEFAULT_SEARCH_LIMIT)
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "query": req.params["q"],
+                        "months": months,
+                        "count": len(found),
+                        "truncated": len(found) > limit,
+                        "transactions": [
+                            {"id": row_key, **exports.transaction_json(t)}
+                            for row_key, t in found[:limit]
+                        ],
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in transactions search handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    @staticmethod
+    def _search_months() -> int:
+        """Months a search looks through, from TRANSACTION_SEARCH_MONTHS."""
+        months = int(os.environ.get("TRANSACTION_SEARCH_MONTHS", DEFAULT_SEARCH_MONTHS))
+        return min(max(months, 1), MAX_SEARCH_MONTHS)
+
+    @staticmethod
+    def _compare(group: Group, p1: Person, p2: Person) -> dict:
+        """Side-by-side spend per category for two people and the debt between them."""
+        categories = [
+            {
+                "category": c.value,
+                "p1": float(p1.get_expenses(c)),
+                "p2": float(p2.get_expenses(c)),
+                "difference": float(group.get_expenses_difference(p1, p2, c)),
+            }
+            for c in group.categories()
+            if c != Category.OTHER
+        ]
+        return {
+            "p1": {"name": p1.name, "email": p1.email},
+            "p2": {"name": p2.name, "email": p2.email},
+            "categories": categories,
+            "totals": {
+                "p1": float(p1.get_expenses()),
+                "p2": float(p2.get_expenses()),
+                "difference": float(group.get_expenses_difference(p1, p2)),
+            },
+            # Positive if p1 owes p2, negative if p2 owes p1
+            "debt": float(group.get_debt(p1, p2)),
+        }
+
+    @staticmethod
+    def _summary(rows: list[dict], people: list[Person]) -> dict:
+        """
+        Rolls aggregated spend rows up per category, account and person.
+        A row's owner override, when it is a member, takes precedence over account
+        ownership; rows on accounts no member owns are reported as unassigned.
+        """
+        zero = Decimal("0.00")
+        categories = {
+            c.value: zero for c in all_categories(row["Category"] for row in rows)
+        }
+        accounts: dict[int, dict] = {}
+        by_email = {p.email.lower(): p for p in people}
+        person_totals = {p.email: zero for p in people}
+        unassigned = zero
+
+        for row in rows:
+            total = row["Total"]
+            categories[row["Category"]] += total
+            account = accounts.setdefault(
+                row["AccountNumber"], {"total": zero, "count": 0}
+            )
+            account["total"] += total
+            account["count"] += row["Count"]
+
+            owner = by_email.get(row["Owner"].lower()) if row["Owner"] else None
+            matched = (
+                [owner]
+                if owner
+                else [p for p in people if row["AccountNumber"] in p.account_numbers]
+            )
+            if not matched:
+                unassigned += total
+                continue
+            for p, share in split_by_weight(total, matched, row["AccountNumber"]):
+                person_totals[p.email] += share
+
+        return {
+            "total": float(sum(categories.values(), start=zero)),
+            "categories": [
+                {"category": name, "total": float(total)}
+                for name, total in categories.items()
+            ],
+            "accounts": [
+                {
+                    "accountNumber": number,
+                    "total": float(account["total"]),
+                    "count": account["count"],
+                }
+                for number, account in sorted(accounts.items())
+            ],
+            "people": [
+                {
+                    "name": p.name,
+                    "email": p.email,
+                    "total": float(person_totals[p.email]),
+                }
+                for p in people
+            ],
+            "unassigned": float(unassigned),
+        }
+
+    def _month_summary(self, month: str) -> dict:
+        """A month's total spend per category, per account and per person."""
+        people = [self._person(p) for p in self.db_service.get_all_people()]
+        rows = self.db_service.get_spending_totals(month)
+        return {"month": month, **self._summary(rows, people)}
+
+    def handle_summary(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Returns a month's total spend per category, per account and per person,
+        with the caller's saved transaction views for the dashboard.
+        """
+        logging.info("Processing summary request.")
+
+        user_email = self._get_user_email(req)
+        if not user_email:
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = SAVINGS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            month = req.params.get("month", datetime.now().strftime("%Y-%m"))
+            summary = {
+                **self._month_summary(month),
+                "views": self.db_service.get_views(user_email),
+            }
+            return func.HttpResponse(
+                json.dumps(summary),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in summary handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _review_packet(self, month: str) -> dict:
+        """
+        Everything a monthly money meeting goes over: the month's summary, each
+        budget against its spend, charges far above their category's usual,
+        possible duplicate imports and transactions no member owns.
+        """
+        people = [self._person(p) for p in self.db_service.get_all_people()]
+        rows = self.db_service.get_spending_totals(month)
+        transactions = self.db_service.apply_owner_overrides(
+            self.db_service.get_transactions(month)
+        )
+        history = [
+            t
+            for previous in previous_months(
+                datetime.strptime(month, "%Y-%m"), HISTORY_MONTHS
+            )
+            for t in self.db_service.get_transactions(previous)
+        ]
+        group = Group(people, self._debt_excluded_categories())
+        group.add_transactions(transactions)
+        return {
+            "month": month,
+            "summary": self._summary(rows, people),
+            "budgets": budget_status(
+                self.db_service.get_budgets(), self._category_spend(rows)
+            ),
+            "unusual": review.unusual_transactions(transactions, history),
+            "duplicates": review.possible_duplicates(transactions),
+            "unassigned": [
+                {
+                    "date": t.date.isoformat(),
+                    "name": t.name,
+                    "accountNumber": t.account_number,
+                    "amount": float(t.amount),
+                    "category": t.category.value,
+                }
+                for t in group.unassigned
+            ],
+        }
+
+    def _category_spend(self, rows: list[dict]) -> dict[AnyCategory, Decimal]:
+        """Aggregated spend rows totalled per category."""
+        spend: dict[AnyCategory, Decimal] = {}
+        for row in rows:
+            category = self._category(row["Category"])
+            spend[category] = spend.get(category, Decimal("0.00")) + row["Total"]
+        return spend
+
+    def _monthly_report(self, month: str) -> dict:
+        """
+        A month's report: total spend, spend by person and by category, each
+        budget against its spend and, between two members, who still owes whom
+        once the settlements paying the month's debt are taken off.
+        """
+        people = [self._person(p) for p in self.db_service.get_all_people()]
+        rows = self.db_service.get_spending_totals(month)
+        summary = self._summary(rows, people)
+        report = {
+            "month": month,
+            "total": summary["total"],
+            "people": summary["people"],
+            "categories": sorted(
+                (c for c in summary["categories"] if c["total"]),
+                key=lambda c: -c["total"],
+            ),
+            "budgets": budget_status(
+                self.db_service.get_budgets(), self._category_spend(rows)
+            ),
+            "debt": None,
+        }
+        if len(people) == 2:
+            group = Group(people, self._debt_excluded_categories())
+            group.add_transactions(
+                self.db_service.apply_owner_overrides(
+                    self.db_service.get_transactions(month)
+                )
+            )
+            p1, p2 = people
+            settled = settled_in_month(
+                self.db_service.get_ledger_entries(), p1.email, p2.email, month
+            )
+            debt = group.get_debt(p1, p2) - settled
+            debtor, creditor = (p1, p2) if debt >= 0 else (p2, p1)
+            report["debt"] = {
+                "from": debtor.name,
+                "to": creditor.name,
+                "amount": float(abs(debt)),
+                "settled": float(abs(settled)),
+            }
+        return report
+
+    @staticmethod
+    def _review_packet_pdf(packet: dict) -> bytes:
+        """The review packet printed as a PDF."""
+        return pdf.text_pdf(
+            f"Monthly Review: {packet['month']}", review.packet_lines(packet)
+        )
+
+    def handle_review_packet(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Returns a month's review packet (last month's by default), as JSON or,
+        with format=pdf, as a printable PDF.
+        """
+        logging.info("Processing review packet request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = REVIEW_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            month = req.params.get("month") or previous_months(datetime.now(), 1)[0]
+            packet = self._review_packet(month)
+            if req.params.get("format") == "pdf":
+                return func.HttpResponse(
+                    self._review_packet_pdf(packet),
+                    mimetype="application/pdf",
+                    headers={
+                        "Content-Disposition": (
+                            f'attachment; filename="review-{month}.pdf"'
+                        )
+                    },
+                    status_code=HTTPStatus.OK,
+                )
+            return func.HttpResponse(
+                json.dumps(packet),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in review packet handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def run_review_packet_job(self) -> None:
+        """
+        Timer Trigger handler. Emails every member last month's review packet,
+        with the printable PDF attached, ahead of the monthly money meeting.
+        """
+        now = datetime.now()
+        month = previous_months(now, 1)[0]
+        details: dict = {"month": month}
+        errors = []
+        try:
+            packet = self._review_packet(month)
+            recipients = [p["Email"] for p in self.db_service.get_all_people()]
+            subject = f"Monthly review packet: {month}"
+            self.email_service.send_emails(
+                [
+                    (
+                        [email],
+                        subject,
+                        self.email_renderer.render_review_packet(packet),
+                    )
+                    for email in recipients
+                ],
+                [
+                    services.EmailAttachment(
+                        f"review-{month}.pdf",
+                        "application/pdf",
+                        self._review_packet_pdf(packet),
+                    )
+                ],
+            )
+            self._record_activity(
+                "reminder", subject, details={"recipients": recipients}
+            )
+            details.update(
+                {
+                    "recipients": len(recipients),
+                    "unusual": len(packet["unusual"]),
+                    "duplicates": len(packet["duplicates"]),
+                    "unassigned": len(packet["unassigned"]),
+                }
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error running review packet job: %s", e)
+            errors.append(str(e))
+
+        self._record_job_run(REVIEW_PACKET_JOB, now, details, errors)
+        if errors:
+            self._alert_admins(
+                "The review packet failed",
+                self.email_renderer.render_job_alert(
+                    f"The review packet for {month} could not be sent.", errors
+                ),
+            )
+            raise RuntimeError(f"Review packet failed: {'; '.join(errors)}")
+
+    def run_monthly_report_job(self) -> None:
+        """
+        Timer Trigger handler. Emails every member last month's report: total
+        spend, the category breakdown, budgets against actual spend and the debt
+        still to settle.
+        """
+        now = datetime.now()
+        month = previous_months(now, 1)[0]
+        details: dict = {"month": month}
+        errors = []
+        try:
+            report = self._monthly_report(month)
+            recipients = [p["Email"] for p in self.db_service.get_all_people()]
+            subject = f"Monthly report: {month}"
+            body = self.email_renderer.render_monthly_report(report)
+            self.email_service.send_emails(
+                [([email], subject, body) for email in recipients]
+            )
+            self._record_activity(
+                "report", subject, details={"recipients": recipients}
+            )
+            details.update({"recipients": len(recipients), "total": report["total"]})
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error running monthly report job: %s", e)
+            errors.append(str(e))
+
+        self._record_job_run(MONTHLY_REPORT_JOB, now, details, errors)
+        if errors:
+            self._alert_admins(
+                "The monthly report failed",
+                self.email_renderer.render_job_alert(
+                    f"The monthly report for {month} could not be sent.", errors
+                ),
+            )
+            raise RuntimeError(f"Monthly report failed: {'; '.join(errors)}")
+
+    @staticmethod
+    def _check_trend_months(months: list[str]) -> list[FieldError]:
+        """Checks the range a trend report covers isn't empty or too long."""
+        if not months:
+            return [FieldError("to", "is before from")]
+        if len(months) > MAX_TREND_MONTHS:
+            return [
+                FieldError("to", f"is more than {MAX_TREND_MONTHS} months after from")
+            ]
+        return []
+
+    def _trend_report(self, months: list[str], real: bool) -> dict:
+        """
+        The total and per-category spend of each month. With real, amounts are
+        converted into dollars of the final month's year.
+        """
+        base_year = int(months[-1][:4])
+        index = self._cpi_index() if real else {}
+
+        def adjust(amount: Decimal, month: str) -> float:
+            if real:
+                amount = to_real(amount, int(month[:4]), base_year, index)
+            return float(amount)
+
+        report = []
+        for month in months:
+            categories = {c.value: Decimal("0.00") for c in self._categories()}
+            for row in self.db_service.get_spending_totals(month):
+                categories[row["Category"]] += row["Total"]
+            report.append(
+                {
+                    "month": month,
+                    "total": adjust(sum(categories.values()), month),
+                    "categories": {
+                        name: adjust(total, month) for name, total in categories.items()
+                    },
+                }
+            )
+
+        return {
+            "from": months[0],
+            "to": months[-1],
+            "real": real,
+            "baseYear": base_year if real else None,
+            "months": report,
+        }
+
+    def handle_trend_report(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Returns the total and per-category spend of every month in a range.
+        With real=true, amounts are converted into dollars of the range's final year.
+        """
+        logging.info("Processing trend report request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = TREND_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        months = months_between(req.params["from"], req.params["to"])
+        errors = self._check_trend_months(months)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            real = str(req.params.get("real", "false")).lower() == "true"
+            return func.HttpResponse(
+                json.dumps(self._trend_report(months, real)),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in trend report handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_diff_report(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Explains what changed between months a and b: the categories and merchants
+        whose spend moved the most, with the transactions behind each change.
+        """
+        logging.info("Processing diff report request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = DIFF_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            a, b = req.params["a"], req.params["b"]
+            limit = min(int(req.params.get("limit", 5)), MAX_DIFF_ITEMS)
+            a_transactions = self.db_service.get_transactions(a)
+            b_transactions = self.db_service.get_transactions(b)
+
+            def deltas(key, name: str) -> list[dict]:
+                return [
+                    {name: d.pop("key"), **d}
+                    for d in largest_deltas(a_transactions, b_transactions, key, limit)
+                ]
+
+            totals = {
+                side: sum(
+                    (t.amount for t in transactions if t.ignore == IgnoredFrom.NOTHING),
+                    start=Decimal("0.00"),
+                )
+                for side, transactions in (("a", a_transactions), ("b", b_transactions))
+            }
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "a": a,
+                        "b": b,
+                        "totals": {
+                            "a": float(totals["a"]),
+                            "b": float(totals["b"]),
+                            "delta": float(totals["b"] - totals["a"]),
+                        },
+                        "categories": deltas(
+                            lambda t: t.category.value, "category"
+                        ),
+                        "merchants": deltas(
+                            lambda t: merchant_name(t.name), "merchant"
+                        ),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in diff report handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_activity(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Returns the household's recent activity, newest first: imports, transaction
+        edits, reminders sent and settlements. Pass the returned cursor to page on.
+        """
+        logging.info("Processing activity request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = ACTIVITY_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            limit = min(
+                int(req.params.get("limit", DEFAULT_ACTIVITY_LIMIT)), MAX_ACTIVITY_LIMIT
+            )
+            events, cursor = self.db_service.get_activity(
+                limit, req.params.get("cursor"), req.params.get("kind")
+            )
+            return func.HttpResponse(
+                json.dumps({"events": events, "cursor": cursor}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in activity handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_compare_report(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Compares the spending of two people (by email) for a month."""
+        logging.info("Processing compare report request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = COMPARE_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            month = req.params.get("month", datetime.now().strftime("%Y-%m"))
+            people = {
+                p["Email"].lower(): self._person(p)
+                for p in self.db_service.get_all_people()
+            }
+            p1 = people.get(req.params["p1"].lower())
+            p2 = people.get(req.params["p2"].lower())
+            if not p1 or not p2 or p1 is p2:
+                return self._validation_error(
+                    [FieldError("p1" if not p1 else "p2", "is not a distinct member")]
+                )
+
+            group = Group([p1, p2], self._debt_excluded_categories())
+            group.add_transactions(self.db_service.get_transactions(month))
+
+            return func.HttpResponse(
+                json.dumps({"month": month, **self._compare(group, p1, p2)}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in compare report handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_debt(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Works out who owes whom for a month: its transactions are assigned to the
+        two members by account (or owner), shared spend is split by the members'
+        split shares (evenly unless set) and the per-category breakdown shows each
+        category's split and what it adds to the debt.
+        Categories excluded from shared debt are listed but add nothing, and spend
+        on accounts neither member owns is totalled as unassigned. Settlements
+        paying the month's debt are subtracted, so the debt is what's still owed.
+        """
+        logging.info("Processing debt request.")
+
+        if not self._get_user_email(req):
+            return func.HttpResponse(
+                "Unauthorized", status_code=HTTPStatus.UNAUTHORIZED
+            )
+
+        errors = SAVINGS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            month = req.params.get("month", datetime.now().strftime("%Y-%m"))
+            people = [self._person(p) for p in self.db_service.get_all_people()]
+            if len(people) != 2:
+                return func.HttpResponse(
+                    "Debt is only worked out between exactly two members",
+                    status_code=HTTPStatus.CONFLICT,
+                )
+
+            excluded = self._debt_excluded_categories()
+            group = Group(people, excluded)
+            group.add_transactions(
+                self.db_service.apply_owner_overrides(
+                    self.db_service.get_transactions(month)
+                )
+            )
+            p1, p2 = people
+            settled = settled_in_month(
+                self.db_service.get_ledger_entries(), p1.email, p2.email, month
+            )
+            debt = group.get_debt(p1, p2) - settled
+
+            def member(p: Person) -> dict:
+                return {
+                    "name": p.name,
+                    "email": p.email,
+                    "shared": float(p.get_expenses(exclude=excluded)),
+                }
+
+            debtor, creditor = (p1, p2) if debt >= 0 else (p2, p1)
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "month": month,
+                        "p1": member(p1),
+                        "p2": member(p2),
+                        "owes": {
+                            "from": debtor.email,
+                            "to": creditor.email,
+                            "amount": float(abs(debt)),
+                        },
+                        # Positive if p1 owes p2, negative if p2 owes p1
+                        "debt": float(debt),
+                        # Positive if p1 paid p2, negative if p2 paid p1
+                        "settled": float(settled),
+                        "categories": [
+                            {
+                                "category": c.value,
+                                "p1": float(p1.get_expenses(c)),
+                                "p2": float(p2.get_expenses(c)),
+                                "excluded": c in excluded,
+                                # p1's share of the category's shared spend
+                                "split": float(group.split_share(p1, p2, c)),
+                                "debt": float(group.get_category_debt(p1, p2, c)),
+                            }
+                            for c in group.categories()
+                            if c != Category.OTHER
+                        ],
+                        "unassigned": float(
+                            sum(
+                                (t.amount for t in group.unassigned),
+                                start=Decimal("0.00"),
+                            )
+                        ),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in debt handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _purge_inventory(self) -> dict:
+        """Collects the keys of every table entity and blob owned by the household."""
+        return {
+            "tables": self.db_service.list_household_keys(),
+            "blobs": {
+                kind.value: sorted(self.blob_service.list_blobs(kind))
+                for kind in services.BlobKind
+            },
+        }
+
+    @staticmethod
+    def _purge_token(inventory: dict) -> str:
+        """
+        Derives a confirmation token from the inventory.
+        The token changes whenever the data changes, so a purge only removes what a dry run showed.
+        """
+        digest = hashlib.sha256(json.dumps(inventory, sort_keys=True).encode("utf-8"))
+        return digest.hexdigest()[:16]
+
+    def handle_admin_purge(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Deletes all data for the household: table rows and blobs of every kind.
+        Defaults to a dry run that lists what would be removed along with a confirmation token;
+        the token must be sent back with dryRun=false to perform the purge.
+        """
+        logging.info("Processing purge request.")
+
+        user_email, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = PURGE_BODY.validate(req_body)
+        if errors:
+            return self._validation_error(errors)
+
+        # Anything other than an explicit false is treated as a dry run
+        dry_run = str(req_body.get("dryRun", True)).lower() != "false"
+
+        try:
+            inventory = self._purge_inventory()
+            token = self._purge_token(inventory)
+            tables = self._summarize_keys(inventory["tables"])
+
+            if dry_run:
+                return func.HttpResponse(
+                    json.dumps(
+                        {
+                            "dryRun": True,
+                            "tables": tables,
+                            "blobs": inventory["blobs"],
+                            "confirmationToken": token,
+                        }
+                    ),
+                    mimetype="application/json",
+                    status_code=HTTPStatus.OK,
+                )
+
+            if req_body.get("confirmationToken") != token:
+                return func.HttpResponse(
+                    "Confirmation token is missing or stale. Run a dry run first.",
+                    status_code=HTTPStatus.CONFLICT,
+                )
+
+            for table_name, keys in inventory["tables"].items():
+                self.db_service.delete_entities(table_name, keys)
+            for kind_value, blob_names in inventory["blobs"].items():
+                for blob_name in blob_names:
+                    self.blob_service.delete_blob(
+                        blob_name, services.BlobKind(kind_value)
+                    )
+
+            blob_counts = {
+                kind: len(names) for kind, names in inventory["blobs"].items()
+            }
+            logging.warning(
+                "Household data purged by %s: blobs %s, tables %s",
+                user_email,
+                blob_counts,
+                {name: t["count"] for name, t in tables.items()},
+            )
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "dryRun": False,
+                        "tables": {name: t["count"] for name, t in tables.items()},
+                        "blobs": blob_counts,
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in purge handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _table_routes_json(self, setting: dict) -> dict:
+        """Each switchable table name with the table it's switched to."""
+        return {
+            "tables": {
+                name: setting["routes"].get(name, name)
+                for name in self.db_service.switchable_tables()
+            },
+            "previous": setting["previous"],
+            "switchedAt": setting["switchedAt"],
+        }
+
+    def _check_table_switch(self, tables: dict) -> list[FieldError]:
+        """Field errors for switches of unknown names or to invalid table names."""
+        errors = []
+        switchable = self.db_service.switchable_tables()
+        for name, table in tables.items():
+            if name not in switchable:
+                errors.append(FieldError(f"tables.{name}", "is not a switchable table"))
+            elif not isinstance(table, str) or not re.match(TABLE_NAME_PATTERN, table):
+                errors.append(FieldError(f"tables.{name}", "is not a valid table name"))
+        if not tables:
+            errors.append(FieldError("tables", "must switch at least one table"))
+        return errors
+
+    def handle_table_routes(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists the table each table name is switched to, the routes before the
+        last switch and when it was. Restricted to admins.
+        """
+        logging.info("Processing table routes request.")
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            return func.HttpResponse(
+                json.dumps(self._table_routes_json(self.db_service.table_routes())),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in table routes handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_table_switch(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Switches table names to other tables together, e.g. the tables a
+        migration wrote its output to, keeping the current routes to roll back
+        to. Each table switched to must exist and hold everything its migration
+        wrote. A name switched to itself goes back to its configured table.
+        Restricted to admins.
+        """
+        logging.info("Processing table switch request.")
+
+        user_email, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            req_body = req.get_json()
+        except ValueError:
+            return func.HttpResponse("Invalid JSON", status_code=HTTPStatus.BAD_REQUEST)
+
+        errors = TABLE_SWITCH_BODY.validate(req_body) or self._check_table_switch(
+            req_body["tables"]
+        )
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            # Tables already in use, or configured, needn't be checked again
+            routes = self.db_service.table_routes()["routes"]
+            problems = []
+            for name, table in req_body["tables"].items():
+                if table in (name, routes.get(name)):
+                    continue
+                problem = self.db_service.switch_target_problem(table)
+                if problem:
+                    problems.append(FieldError(f"tables.{name}", problem))
+            if problems:
+                return self._validation_error(problems)
+
+            self.db_service.switch_tables(req_body["tables"])
+            logging.warning("Tables switched by %s: %s", user_email, req_body["tables"])
+            return func.HttpResponse(
+                json.dumps(self._table_routes_json(self.db_service.table_routes())),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in table switch handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_table_rollback(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Switches table names back to the tables they used before the last
+        switch. Rolling back again undoes the rollback. Restricted to admins.
+        """
+        logging.info("Processing table rollback request.")
+
+        user_email, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            routes = self.db_service.rollback_tables()
+            if routes is None:
+                return func.HttpResponse(
+                    "No table switch to roll back", status_code=HTTPStatus.CONFLICT
+                )
+
+            logging.warning("Tables rolled back by %s: %s", user_email, routes)
+            return func.HttpResponse(
+                json.dumps(self._table_routes_json(self.db_service.table_routes())),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in table rollback handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_admin_bootstrap(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Provisions tables, containers and queues, seeds default settings and issues
+        the first admin API key. Safe to call repeatedly: existing resources and
+        settings are left alone and a key is only issued while none exists.
+        """
+        logging.info("Processing bootstrap request.")
+
+        user_email, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            result = {
+                "tables": self.db_service.ensure_tables(),
+                "containers": self.blob_service.ensure_containers(),
+                "queues": self.queue_service.ensure_queues(),
+                "settingsAdded": self.db_service.seed_settings(DEFAULT_SETTINGS),
+                "apiKey": None,
+            }
+
+            if not self.db_service.has_api_keys():
+                api_key = API_KEY_PREFIX + secrets.token_urlsafe(32)
+                self.db_service.save_api_key(
+                    _hash_token(api_key), user_email, "bootstrap"
+                )
+                # Returned once; only the hash is stored
+                result["apiKey"] = api_key
+                logging.info("Issued initial API key for %s", user_email)
+
+            return func.HttpResponse(
+                json.dumps(result),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in bootstrap handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_sandbox_seed(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Resets the sandbox household to fresh demo data. Whatever the sandbox held
+        before is deleted; the live tables aren't touched.
+        """
+        return self._seed_demo(req, sandbox.SANDBOX)
+
+    def handle_demo_seed(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Fills an empty live household with demo data, so a first look (or a
+        screenshot) shows a populated app.
+        """
+        return self._seed_demo(req, sandbox.LIVE)
+
+    def _seed_demo(self, req: func.HttpRequest, household: str) -> func.HttpResponse:
+        """
+        Seeds a household with demo data: the admin and a demo partner, six months
+        of transactions, budgets, a card and this month's savings. Restricted to
+        admins. Otherwise the households only differ in what they refuse:
+
+        - sandbox: 404 when the deployment has none. What it held is deleted
+          first, so it's never refused for having data.
+        - live: 409 when it already has members, or transactions in the demo's
+          months, rather than mixing them with demo records.
+        """
+        logging.info("Processing demo seed request for the %s household.", household)
+
+        user_email, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+        in_sandbox = household == sandbox.SANDBOX
+        if in_sandbox and not sandbox.enabled():
+            return func.HttpResponse(
+                "The sandbox household is not enabled",
+                status_code=HTTPStatus.NOT_FOUND,
+            )
+
+        try:
+            if in_sandbox:
+                db = self.sandbox_db_service
+            else:
+                db = self.live_db_service
+                if self._has_household_data(db):
+                    return func.HttpResponse(
+                        "The household already has data",
+                        status_code=HTTPStatus.CONFLICT,
+                    )
+
+            db.ensure_tables()
+            if in_sandbox:
+                for table_name, keys in db.list_household_keys().items():
+                    db.delete_entities(table_name, keys)
+
+            name = next(
+                (
+                    p["Name"]
+                    for p in self.live_db_service.get_all_people()
+                    if p["Email"].lower() == user_email.lower()
+                ),
+                user_email.split("@")[0],
+            )
+            result = self._save_demo(db, {"Name": name, "Email": user_email})
+
+            logging.warning(
+                "Demo data seeded in the %s household by %s", household, user_email
+            )
+            return func.HttpResponse(
+                json.dumps(result),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK if in_sandbox else HTTPStatus.CREATED,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in demo seed handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    @staticmethod
+    def _has_household_data(db: services.DatabaseService) -> bool:
+        """Whether a household has members, or transactions in the demo's months."""
+        now = datetime.now()
+        months = [*previous_months(now, sandbox.DEMO_MONTHS), now.strftime("%Y-%m")]
+        return bool(db.get_all_people()) or any(db.get_transactions(m) for m in months)
+
+    @staticmethod
+    def _save_demo(db: services.DatabaseService, member: dict[str, str]) -> dict:
+        """
+        Saves a demo household, with the member as one of its two people, and
+        returns what was seeded.
+        """
+        now = datetime.now()
+        demo = sandbox.demo_household(member, now.date())
+        for person in demo["people"]:
+            db.save_person(person)
+        transactions = [t for month in demo["transactions"].values() for t in month]
+        db.save_transactions(transactions)
+        for category, limit in demo["budgets"].items():
+            db.save_budget(category, limit)
+        db.upsert_accounts(member["Email"], demo["accounts"])
+        db.save_savings(now.strftime("%Y-%m"), demo["savings"], member["Email"])
+        db.seed_settings(DEFAULT_SETTINGS)
+        for setting, value in demo["settings"].items():
+            db.save_setting(setting, value)
+        return {
+            "people": [p["Email"] for p in demo["people"]],
+            "months": sorted(demo["transactions"]),
+            "transactions": len(transactions),
+        }
+
+    def _expired_backups(self, cutoff: date | None) -> list[str]:
+        """
+        The blobs of snapshots taken before the cutoff. The latest complete
+        snapshot is always kept, however old, so there's one to restore.
+        """
+        if cutoff is None:
+            return []
+        names = self.blob_service.list_blobs(services.BlobKind.BACKUPS)
+        latest = self._latest_backup(names)
+        # Snapshot ids start with the day taken, so earlier days' sort before it
+        oldest = cutoff.strftime("%Y%m%d")
+        expired = []
+        for name in names:
+            snapshot = name.split("/", 1)[0]
+            if snapshot < oldest and snapshot != latest:
+                expired.append(name)
+        return expired
+
+    def _expired_shadow_tables(self, cutoff: date | None) -> list[str]:
+        """
+        The shadow tables of a restore rehearsal finished before the cutoff. None
+        while a rehearsal is queued or running, as it's writing to them.
+        """
+        if cutoff is None:
+            return []
+        runs = self.db_service.get_job_runs(DR_REHEARSAL_JOB, 1)
+        if runs and (
+            runs[0]["status"] in ("queued", "running")
+            or runs[0]["startedAt"][:10] >= cutoff.isoformat()
+        ):
+            return []
+        return self.db_service.list_tables(DR_TABLE_PREFIX)
+
+    def _retention_plan(self, policy: RetentionPolicy, now: datetime) -> dict:
+        """
+        Collects the table entities, blobs, backups and rehearsal shadow tables
+        that have expired under the policy.
+        """
+        cutoffs = policy.cutoffs(now)
+        uploads_cutoff = cutoffs["uploads"]
+        return {
+            "cutoffs": cutoffs,
+            "tables": self.db_service.list_expired_keys(
+                cutoffs["transactions"],
+                cutoffs["savings"],
+                cutoffs["activity"],
+                cutoffs["jobs"],
+                cutoffs["metrics"],
+            ),
+            "blobs": (
+                self.blob_service.list_blobs_before(uploads_cutoff)
+                if uploads_cutoff
+                else []
+            ),
+            "backups": self._expired_backups(cutoffs["backups"]),
+            "shadowTables": self._expired_shadow_tables(cutoffs["rehearsals"]),
+        }
+
+    def handle_retention_preview(self, req: func.HttpRequest) -> func.HttpResponse:
+        """Shows the retention policy and what the next retention run will delete."""
+        logging.info("Processing retention preview request.")
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            policy = RetentionPolicy.from_env()
+            plan = self._retention_plan(policy, datetime.now())
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "policy": policy.to_dict(),
+                        "cutoffs": {
+                            name: cutoff.isoformat() if cutoff else None
+                            for name, cutoff in plan["cutoffs"].items()
+                        },
+                        "tables": self._summarize_keys(plan["tables"]),
+                        "blobs": plan["blobs"],
+                        "backups": plan["backups"],
+                        "shadowTables": plan["shadowTables"],
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in retention preview handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_storage_ops(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Shows the table requests, entities read and written, and batches per
+        endpoint across every worker over the last days (1 by default), with the
+        month of table requests they project to and its estimated cost.
+        Restricted to admins.
+        """
+        logging.info("Processing storage ops request.")
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        errors = STORAGE_OPS_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            now = datetime.now()
+            start = shared_metrics.bucket(
+                now - timedelta(days=int(req.params.get("days", 1)))
+            )
+            # Include what this worker counted since it last saved
+            shared_metrics.flush(force=True)
+            ops = table_metrics.storage_ops(
+                shared_metrics.total(self.live_db_service.get_metric_counts(start)),
+                datetime.fromisoformat(start),
+            )
+            cost = table_metrics.monthly_cost(
+                ops["totals"]["requests"],
+                datetime.fromisoformat(ops["since"]),
+                now,
+            )
+            return func.HttpResponse(
+                json.dumps({**ops, "monthlyCost": cost}),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in storage ops handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _count_emails_sent(self, count: int) -> None:
+        """Adds emails sent to this month's usage."""
+        self.db_service.add_usage(datetime.now().strftime("%Y-%m"), "EmailsSent", count)
+
+    def _household_usage(self, month: str) -> dict:
+        """
+        The household's stored transactions, blob storage by kind and emails sent
+        in a month.
+        """
+        blobs = {
+            kind.value: self.blob_service.list_blob_properties("", kind)
+            for kind in services.BlobKind
+        }
+        storage = {
+            kind: {"count": len(props), "bytes": sum(p["size"] or 0 for p in props)}
+            for kind, props in blobs.items()
+        }
+        return {
+            "month": month,
+            "transactions": self.db_service.count_transactions(),
+            "storageBytes": sum(s["bytes"] for s in storage.values()),
+            "storage": storage,
+            "emailsSent": self.db_service.get_usage(month).get("EmailsSent", 0),
+        }
+
+    def handle_usage(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Shows the household's usage: stored transactions, blob storage and this
+        month's emails, with the soft limits that are set and any reached.
+        Restricted to admins.
+        """
+        logging.info("Processing usage request.")
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            current = self._household_usage(datetime.now().strftime("%Y-%m"))
+            limits = usage.soft_limits()
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        **current,
+                        "limits": limits,
+                        "overLimit": usage.over_limits(current, limits),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in usage handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def handle_queues(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Shows each processing queue and its poison queue with the approximate
+        number of messages waiting and the oldest one's age, and the queue alerts
+        that are firing, to catch stuck processing early. Restricted to admins.
+        """
+        logging.info("Processing queues request.")
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            queues = self.queue_service.queue_status()
+            values = ops_alerts.queue_values(queues)
+            thresholds = self._ops_thresholds(self.db_service.get_settings())
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "queues": queues,
+                        "alerts": ops_alerts.firing(
+                            values, {m: thresholds[m] for m in values}
+                        ),
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in queues handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    @staticmethod
+    def _ops_thresholds(settings: dict) -> dict[str, float]:
+        """Each operational metric's alert threshold from the settings."""
+        return {
+            metric: float(settings.get(name, DEFAULT_SETTINGS[name]))
+            for metric, name in ops_alerts.OPS_METRICS.items()
+        }
+
+    def run_usage_check(self) -> None:
+        """
+        Timer Trigger handler. Warns the admins when the household reaches a soft
+        usage limit, once a month per limit. Nothing is blocked.
+        """
+        try:
+            limits = usage.soft_limits()
+            if not limits:
+                return
+
+            month = datetime.now().strftime("%Y-%m")
+            over = usage.over_limits(self._household_usage(month), limits)
+            warned = json.loads(
+                self.db_service.get_settings().get("UsageWarnings", "{}")
+            )
+            new = [o for o in over if warned.get(o["metric"]) != month]
+            if new:
+                self._alert_admins(
+                    "The household reached a usage limit",
+                    self.email_renderer.render_job_alert(
+                        "The household's usage reached its soft limits:",
+                        [f"{o['metric']}: {o['usage']} of {o['limit']}" for o in new],
+                    ),
+                )
+                warned.update({o["metric"]: month for o in new})
+                self.db_service.save_setting("UsageWarnings", json.dumps(warned))
+            logging.info("Usage check found %d limits reached", len(over))
+
+        except Exception as e:
+            logging.error("Error checking usage: %s", e)
+            raise
+
+    def run_ops_alert_check(self) -> None:
+        """
+        Timer Trigger handler. Alerts the admins when an operational metric reaches
+        its threshold in the settings: the server error rate across every worker
+        since the last check, the longest processing queue's backlog or its oldest
+        message, or the nightly job's failed runs in a row. A metric is alerted as
+        it starts firing and again only once it has recovered in between.
+        """
+        try:
+            settings = self.db_service.get_settings()
+            thresholds = self._ops_thresholds(settings)
+            shared_metrics.flush(force=True)
+            start, end = shared_metrics.window(
+                ops_alerts.CHECK_MINUTES, datetime.now()
+            )
+            counts = shared_metrics.total(
+                self.live_db_service.get_metric_counts(start, end)
+            ).get(middleware.REQUESTS_GROUP, {})
+            runs = self.db_service.get_job_runs(
+                NIGHTLY_JOB, max(int(thresholds["jobFailures"]), 1)
+            )
+            values = {
+                "errorRate": ops_alerts.error_rate(counts),
+                **ops_alerts.queue_values(self.queue_service.queue_status()),
+                "jobFailures": ops_alerts.consecutive_failures(runs),
+            }
+
+            now_firing = ops_alerts.firing(values, thresholds)
+            was_firing = json.loads(settings.get("OpsAlertsFiring", "[]"))
+            new = [f for f in now_firing if f["metric"] not in was_firing]
+            if new:
+                self._alert_admins(
+                    "An operational metric reached its alert threshold",
+                    self.email_renderer.render_job_alert(
+                        "These metrics reached their alert thresholds:",
+                        [
+                            f"{f['metric']}: {f['value']:g} of {f['threshold']:g}"
+                            for f in new
+                        ],
+                    ),
+                )
+            firing_metrics = sorted(f["metric"] for f in now_firing)
+            if firing_metrics != sorted(was_firing):
+                self.db_service.save_setting(
+                    "OpsAlertsFiring", json.dumps(firing_metrics)
+                )
+            logging.info("Ops alert check found %d metrics firing", len(now_firing))
+
+        except Exception as e:
+            logging.error("Error checking ops alerts: %s", e)
+            raise
+
+    def run_retention_job(self) -> None:
+        """
+        Timer Trigger handler. Deletes table entities, blobs, backups and restore
+        rehearsal shadow tables past their retention period.
+        """
+        try:
+            policy = RetentionPolicy.from_env()
+            plan = self._retention_plan(policy, datetime.now())
+
+            deleted = {
+                table_name: self.db_service.delete_entities(table_name, keys)
+                for table_name, keys in plan["tables"].items()
+            }
+            for blob_name in plan["blobs"]:
+                self.blob_service.delete_blob(blob_name)
+            for blob_name in plan["backups"]:
+                self.blob_service.delete_blob(blob_name, services.BlobKind.BACKUPS)
+            for table_name in plan["shadowTables"]:
+                self.db_service.drop_table(table_name)
+
+            logging.info(
+                "Retention run complete: %d blobs, %d backup blobs, %d shadow "
+                "tables, tables %s",
+                len(plan["blobs"]),
+                len(plan["backups"]),
+                len(plan["shadowTables"]),
+                deleted,
+            )
+
+        except Exception as e:
+            logging.error("Error running retention job: %s", e)
+            raise
+
+    def _backup_key(self) -> bytes:
+        """The key backups are encrypted and their manifests signed with."""
+        return backups.parse_key(
+            self.secret_provider.get(
+                os.environ.get("BACKUP_KEY_SECRET", DEFAULT_BACKUP_KEY_SECRET)
+            )
+        )
+
+    def run_backup_job(self) -> None:
+        """
+        Timer Trigger handler. Snapshots every table into the backups container,
+        each encrypted with the backup key, then writes the snapshot's signed
+        manifest with each table's entity count and SHA-256. The manifest goes
+        last, so a snapshot without one is incomplete and is never verified.
+        """
+        now = datetime.now()
+        details: dict = {"tables": 0, "entities": 0}
+        errors = []
+        try:
+            key = self._backup_key()
+            snapshot = backups.snapshot_id(now)
+            tables = []
+            for table_name in self.db_service.table_names():
+                entities = self.db_service.export_table(table_name)
+                blob_name = backups.table_blob_name(snapshot, table_name)
+                blob = backups.encrypt(
+                    key, blob_name, json.dumps(entities, default=str).encode("utf-8")
+                )
+                self.blob_service.upload_blob(
+                    services.BlobKind.BACKUPS, blob_name, blob
+                )
+                tables.append(
+                    {
+                        "table": table_name,
+                        "blob": blob_name,
+                        "entities": len(entities),
+                        "size": len(blob),
+                        "sha256": backups.checksum(blob),
+                    }
+                )
+
+            manifest = backups.sign_manifest(
+                key,
+                {
+                    "snapshot": snapshot,
+                    "createdAt": now.isoformat(),
+                    "keyId": backups.key_id(key),
+                    "tables": tables,
+                },
+            )
+            self.blob_service.upload_blob(
+                services.BlobKind.BACKUPS,
+                backups.manifest_blob_name(snapshot),
+                json.dumps(manifest, indent=2).encode("utf-8"),
+            )
+            details = {
+                "snapshot": snapshot,
+                "tables": len(tables),
+                "entities": sum(t["entities"] for t in tables),
+            }
+            logging.info("Backup %s complete: %s", snapshot, details)
+
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error running backup job: %s", e)
+            errors.append(str(e))
+
+        self._record_job_run(BACKUP_JOB, now, details, errors)
+        if errors:
+            self._alert_admins(
+                "The backup failed",
+                self.email_renderer.render_job_alert(
+                    "The nightly backup failed.", errors
+                ),
+            )
+            raise RuntimeError(f"Backup failed: {'; '.join(errors)}")
+
+    def _latest_backup(self, names: list[str] | None = None) -> str | None:
+        """
+        The id of the latest snapshot with a manifest, if any, among the backup
+        blob names given or else those stored.
+        """
+        if names is None:
+            names = self.blob_service.list_blobs(services.BlobKind.BACKUPS)
+        suffix = f"/{backups.MANIFEST_NAME}"
+        snapshots = [name[: -len(suffix)] for name in names if name.endswith(suffix)]
+        return max(snapshots, default=None)
+
+    def _backup_manifest(self, key: bytes, snapshot: str) -> tuple[dict, str | None]:
+        """
+        A snapshot's manifest, and what's wrong with it if it isn't signed with
+        the key or was changed since. Nothing it lists can be trusted if so.
+        """
+        manifest = json.loads(
+            self.blob_service.download_blob(
+                services.BlobKind.BACKUPS, backups.manifest_blob_name(snapshot)
+            )
+        )
+        if manifest.get("keyId") != backups.key_id(key):
+            return manifest, "Taken with a different backup key"
+        if not backups.manifest_valid(key, manifest):
+            return manifest, "Manifest does not match its signature"
+        return manifest, None
+
+    def _read_backup_table(
+        self, key: bytes, entry: dict
+    ) -> tuple[list[dict], str | None]:
+        """
+        A snapshot's table's entities, checking they can be restored: the blob
+        matches its checksum, decrypts, and holds the entities the manifest lists,
+        each with its keys. Returns what's wrong with it instead, if anything.
+        """
+        if entry["table"] not in self.db_service.table_names():
+            return [], "Not a table the application uses"
+        blob = self.blob_service.download_blob(
+            services.BlobKind.BACKUPS, entry["blob"]
+        )
+        if backups.checksum(blob) != entry["sha256"]:
+            return [], "Does not match its checksum"
+        try:
+            entities = json.loads(backups.decrypt(key, entry["blob"], blob))
+        except Exception:  # pylint: disable=broad-exception-caught
+            return [], "Could not be decrypted"
+        if len(entities) != entry["entities"]:
+            return [], f"Has {len(entities)} entities, not {entry['entities']}"
+        if any("PartitionKey" not in e or "RowKey" not in e for e in entities):
+            return [], "Has entities without keys"
+        return entities, None
+
+    def handle_backup_verify(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Verifies the latest backup: that its manifest is signed with the current
+        backup key and unchanged, and that every table in it can be restored.
+        Reports the problems found rather than failing. Restricted to admins.
+        """
+        logging.info("Processing backup verify request.")
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            snapshot = self._latest_backup()
+            if snapshot is None:
+                return func.HttpResponse(
+                    "No backups found", status_code=HTTPStatus.NOT_FOUND
+                )
+
+            key = self._backup_key()
+            manifest, error = self._backup_manifest(key, snapshot)
+            result: dict = {
+                "snapshot": snapshot,
+                "createdAt": manifest.get("createdAt"),
+                "manifestValid": error is None,
+                "tables": [],
+            }
+            if error:
+                result["error"] = error
+            else:
+                for entry in manifest["tables"]:
+                    _, problem = self._read_backup_table(key, entry)
+                    result["tables"].append(
+                        {
+                            "table": entry["table"],
+                            "entities": entry["entities"],
+                            "valid": problem is None,
+                            "error": problem,
+                        }
+                    )
+            result["valid"] = result["manifestValid"] and all(
+                t["valid"] for t in result["tables"]
+            )
+            if not result["valid"]:
+                logging.warning("Backup %s failed verification", snapshot)
+
+            return func.HttpResponse(
+                json.dumps(result),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in backup verify handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _rehearsal_checks(
+        self, restored: services.DatabaseService, tables: dict[str, list[dict]]
+    ) -> list[dict]:
+        """
+        Recomputes balances from the restored tables through the usual queries
+        and compares them with the same figures computed from the backup itself:
+        the debt ledger's outstanding balance and each month's transaction total.
+        """
+        ledger = [
+            LedgerEntry.from_entity(e)
+            for e in tables.get(self.db_service.debts_table, [])
+            if e["PartitionKey"] == "default_LEDGER"
+        ]
+        ledger_check = {
+            "name": "ledger balance",
+            "expected": running_balance(ledger)["balance"],
+            "actual": running_balance(restored.get_ledger_entries())["balance"],
+        }
+
+        expected_totals: dict[str, Decimal] = {}
+        for e in tables.get(self.db_service.transactions_table, []):
+            month = str(e["PartitionKey"]).removeprefix("default_")
+            if re.match(MONTH_PATTERN, month):
+                expected_totals[month] = expected_totals.get(
+                    month, Decimal("0.00")
+                ) + Decimal(str(e["Amount"]))
+        totals_check = {
+            "name": "monthly transaction totals",
+            "expected": {m: float(t) for m, t in sorted(expected_totals.items())},
+            "actual": {
+                m: float(sum(t.amount for t in restored.get_transactions(m)))
+                for m in sorted(expected_totals)
+            },
+        }
+
+        checks = [ledger_check, totals_check]
+        for check in checks:
+            check["passed"] = check["expected"] == check["actual"]
+        return checks
+
+    def handle_backup_rehearsal(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Queues a disaster recovery rehearsal of the latest backup on the backfill
+        queue, as restoring every table takes longer than a request may. Returns
+        202 Accepted; the run's status is at /api/manage/backup/rehearsals.
+        Restricted to admins.
+        """
+        logging.info("Processing backup rehearsal request.")
+
+        user_email, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        try:
+            snapshot = self._latest_backup()
+            if snapshot is None:
+                return func.HttpResponse(
+                    "No backups found", status_code=HTTPStatus.NOT_FOUND
+                )
+
+            started_at = datetime.now()
+            details = {"snapshot": snapshot, "requestedBy": user_email}
+            self.db_service.record_job_run(
+                DR_REHEARSAL_JOB, started_at, "queued", details, []
+            )
+            self.queue_service.enqueue_message(
+                {
+                    "task": DR_REHEARSAL_TASK,
+                    "startedAt": started_at.isoformat(),
+                    **details,
+                },
+                priority=services.QueuePriority.BACKFILL,
+            )
+            return func.HttpResponse(
+                json.dumps(
+                    {
+                        "snapshot": snapshot,
+                        "startedAt": started_at.isoformat(),
+                        "status": "queued",
+                    }
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.ACCEPTED,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in backup rehearsal handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    def _rehearse_backup(
+        self, snapshot: str, requested_by: str, started_at: datetime
+    ) -> None:
+        """
+        Rehearses disaster recovery: restores a backup into shadow copies of the
+        tables, named with DR_TABLE_PREFIX, then checks each holds the entities
+        the manifest lists and that balances recomputed from them match the
+        backup. The live tables are never touched; the shadow tables are left for
+        inspection until the retention job drops them, or the next rehearsal
+        replaces them. The outcome is recorded on the run queued at started_at,
+        and a run that errors is recorded as failed and raised to be retried.
+        """
+        details: dict = {"snapshot": snapshot, "requestedBy": requested_by}
+        self.db_service.record_job_run(
+            DR_REHEARSAL_JOB, started_at, "running", details, []
+        )
+        try:
+            key = self._backup_key()
+            manifest, error = self._backup_manifest(key, snapshot)
+            result: dict = {
+                "createdAt": manifest.get("createdAt"),
+                "prefix": DR_TABLE_PREFIX,
+                "tables": [],
+                "checks": [],
+            }
+            if error:
+                result["error"] = error
+            else:
+                restored = self.db_service.shadow(DR_TABLE_PREFIX)
+                tables = {}
+                for entry in manifest["tables"]:
+                    entities, problem = self._read_backup_table(key, entry)
+                    shadow_table = f"{DR_TABLE_PREFIX}{entry['table']}"
+                    count = None
+                    if problem is None:
+                        self.db_service.restore_table(shadow_table, entities)
+                        count = self.db_service.count_entities(shadow_table)
+                        tables[entry["table"]] = entities
+                        if count != entry["entities"]:
+                            problem = (
+                                f"Restored {count} entities, not {entry['entities']}"
+                            )
+                    result["tables"].append(
+                        {
+                            "table": entry["table"],
+                            "shadowTable": shadow_table,
+                            "expected": entry["entities"],
+                            "restored": count,
+                            "passed": problem is None,
+                            "error": problem,
+                        }
+                    )
+                result["checks"] = self._rehearsal_checks(restored, tables)
+            result["passed"] = not error and all(
+                item["passed"] for item in result["tables"] + result["checks"]
+            )
+        except Exception as e:
+            logging.error("Error rehearsing backup %s: %s", snapshot, e)
+            self._record_job_run(DR_REHEARSAL_JOB, started_at, details, [str(e)])
+            raise
+
+        failures = [
+            f"{t['table']}: {t['error']}" for t in result["tables"] if t["error"]
+        ] + [f"{c['name']} differs" for c in result["checks"] if not c["passed"]]
+        if error:
+            failures.append(error)
+        self._record_job_run(
+            DR_REHEARSAL_JOB, started_at, {**details, **result}, failures
+        )
+        if not result["passed"]:
+            logging.warning("Backup %s failed its restore rehearsal", snapshot)
+
+    def handle_backup_rehearsals(self, req: func.HttpRequest) -> func.HttpResponse:
+        """
+        Lists recent restore rehearsals, newest first: queued, running, or with
+        the tables restored, the balance checks and any errors. Restricted to
+        admins.
+        """
+        logging.info("Processing backup rehearsals request.")
+
+        _, error_resp = self._require_admin(req)
+        if error_resp:
+            return error_resp
+
+        errors = JOB_HISTORY_PARAMS.validate(req.params)
+        if errors:
+            return self._validation_error(errors)
+
+        try:
+            limit = min(
+                int(req.params.get("limit", DEFAULT_JOB_HISTORY_LIMIT)),
+                MAX_JOB_HISTORY_LIMIT,
+            )
+            return func.HttpResponse(
+                json.dumps(
+                    {"runs": self.db_service.get_job_runs(DR_REHEARSAL_JOB, limit)}
+                ),
+                mimetype="application/json",
+                status_code=HTTPStatus.OK,
+            )
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logging.error("Error in backup rehearsals handler: %s", e)
+            return func.HttpResponse(
+                f"Internal Error: {str(e)}",
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
 
 
 # Singleton instance
@@ -0,0 +1,44 @@
+"""
+Card statement cycles: when a card's statement closes and the days it covers.
+"""
+
+import calendar
+from datetime import date, timedelta
+from typing import Optional
+
+__all__ = ["last_close_date", "cycle_start", "statement_due"]
+
+
+def _close_in_month(close_day: int, year: int, month: int) -> date:
+    """The close date in a month, moved to its last day when the month is short."""
+    return date(year, month, min(close_day, calendar.monthrange(year, month)[1]))
+
+
+def last_close_date(close_day: int, today: date) -> date:
+    """The latest statement close on or before today."""
+    close = _close_in_month(close_day, today.year, today.month)
+    if close <= today:
+        return close
+    if today.month == 1:
+        return _close_in_month(close_day, today.year - 1, 12)
+    return _close_in_month(close_day, today.year, today.month - 1)
+
+
+def cycle_start(close_day: int, close: date) -> date:
+    """The first day of the cycle a statement closing on a date covers."""
+    return last_close_date(close_day, close - timedelta(days=1)) + timedelta(days=1)
+
+
+def statement_due(
+    close_day: int, last_statement: Optional[date], today: date
+) -> Optional[date]:
+    """
+    The close date a card's statement should be taken for today, if any. A card
+    that has closed before catches up on the latest close it missed; a card that
+    never has only closes on the day, since its balance then is all there is to
+    go on.
+    """
+    close = last_close_date(close_day, today)
+    if last_statement is None:
+        return close if close == today else None
+    return close if close > last_statement else None
@@ -0,0 +1,71 @@
+"""
+What changed between two months: the largest category and merchant deltas.
+"""
+
+import re
+from decimal import Decimal
+from typing import Callable, Dict, List
+
+from rmanalyzer.models import IgnoredFrom, Transaction
+
+__all__ = ["merchant_name", "largest_deltas"]
+
+# Trailing store numbers and reference codes, e.g. "SAFEWAY #1234" or "UBER 8XY2"
+_MERCHANT_SUFFIX = re.compile(r"(\s+#?\w*\d\w*)+$")
+
+
+def merchant_name(description: str) -> str:
+    """Normalizes a transaction description so one merchant's visits group together."""
+    name = " ".join(description.upper().split())
+    return _MERCHANT_SUFFIX.sub("", name) or name
+
+
+def _transaction_json(t: Transaction) -> Dict[str, object]:
+    """Serializes a contributing transaction for the API."""
+    return {
+        "date": t.date.isoformat(),
+        "name": t.name,
+        "accountNumber": t.account_number,
+        "amount": float(t.amount),
+    }
+
+
+def largest_deltas(
+    a: List[Transaction],
+    b: List[Transaction],
+    key: Callable[[Transaction], str],
+    limit: int,
+) -> List[Dict[str, object]]:
+    """
+    Groups both months' transactions by key and returns the `limit` groups whose
+    spend changed the most from a to b, with the transactions that contributed.
+    Ignored transactions are left out.
+    """
+    groups: Dict[str, Dict[str, object]] = {}
+    for side, transactions in (("a", a), ("b", b)):
+        for t in transactions:
+            if t.ignore != IgnoredFrom.NOTHING:
+                continue
+            group = groups.setdefault(
+                key(t),
+                {"a": Decimal("0.00"), "b": Decimal("0.00"), "transactions": []},
+            )
+            group[side] += t.amount
+            group["transactions"].append({"period": side, **_transaction_json(t)})
+
+    ranked = sorted(
+        groups.items(), key=lambda item: abs(item[1]["b"] - item[1]["a"]), reverse=True
+    )
+    return [
+        {
+            "key": name,
+            "a": float(group["a"]),
+            "b": float(group["b"]),
+            "delta": float(group["b"] - group["a"]),
+            "transactions": sorted(
+                group["transactions"], key=lambda t: abs(t["amount"]), reverse=True
+            ),
+        }
+        for name, group in ranked[:limit]
+        if group["b"] != group["a"]
+    ]
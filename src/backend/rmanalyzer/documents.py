@@ -0,0 +1,108 @@
+"""
+Documents: statements, bills and other files archived against a month and,
+optionally, an account, so the household keeps its paperwork with its data.
+"""
+
+import os
+import re
+from dataclasses import dataclass
+from typing import Dict, Optional
+
+__all__ = ["Document", "DOCUMENT_TYPES", "content_type", "blob_name"]
+
+# Content types by the extensions documents may have. Links are served with
+# these, so nothing uploaded is ever rendered as HTML or script.
+DOCUMENT_TYPES = {
+    ".pdf": "application/pdf",
+    ".png": "image/png",
+    ".jpg": "image/jpeg",
+    ".jpeg": "image/jpeg",
+    ".heic": "image/heic",
+    ".csv": "text/csv",
+    ".ofx": "application/x-ofx",
+    ".qfx": "application/x-ofx",
+    ".qif": "application/qif",
+    ".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+    ".txt": "text/plain",
+}
+
+
+def content_type(file_name: str) -> Optional[str]:
+    """The content type of a document by its extension, or None if not allowed."""
+    return DOCUMENT_TYPES.get(os.path.splitext(file_name)[1].lower())
+
+
+def blob_name(month: str, document_id: str, file_name: str) -> str:
+    """
+    Where a document is stored: under its month, prefixed with its id so names
+    can't collide, keeping only characters that are safe in a blob name.
+    """
+    safe_name = re.sub(r"[^\w.-]", "_", os.path.basename(file_name))
+    return f"{month}/{document_id}_{safe_name}"
+
+
+@dataclass(frozen=True)
+class Document:
+    """A stored document and who filed it against which month and account."""
+
+    document_id: str
+    month: str
+    file_name: str
+    content_type: str
+    size: int
+    blob_name: str
+    uploaded_by: str
+    uploaded_at: str
+    account_number: Optional[int] = None
+    description: Optional[str] = None
+
+    def to_json(self) -> Dict[str, object]:
+        """Serialize the document for the API."""
+        return {
+            "id": self.document_id,
+            "month": self.month,
+            "fileName": self.file_name,
+            "contentType": self.content_type,
+            "size": self.size,
+            "accountNumber": self.account_number,
+            "description": self.description,
+            "uploadedBy": self.uploaded_by,
+            "uploadedAt": self.uploaded_at,
+        }
+
+    @classmethod
+    def from_entity(cls, entity: Dict[str, object]) -> "Document":
+        """Create a document from a documents table entity."""
+        account = entity.get("AccountNumber")
+        return cls(
+            document_id=str(entity["RowKey"]),
+            month=str(entity["Month"]),
+            file_name=str(entity["FileName"]),
+            content_type=str(entity["ContentType"]),
+            size=int(entity["Size"]),
+            blob_name=str(entity["BlobName"]),
+            uploaded_by=str(entity["UploadedBy"]),
+            uploaded_at=str(entity["UploadedAt"]),
+            account_number=None if account is None else int(account),
+            description=entity.get("Description") or None,
+        )
+
+    def to_entity(self, partition_key: str) -> Dict[str, object]:
+        """Serialize the document for the documents table, omitting unset fields."""
+        entity: Dict[str, object] = {
+            "PartitionKey": partition_key,
+            "RowKey": self.document_id,
+            "Month": self.month,
+            "FileName": self.file_name,
+            "ContentType": self.content_type,
+            "Size": self.size,
+            "BlobName": self.blob_name,
+            "UploadedBy": self.uploaded_by,
+            "UploadedAt": self.uploaded_at,
+        }
+        optional = {
+            "AccountNumber": self.account_number,
+            "Description": self.description,
+        }
+        entity.update({k: v for k, v in optional.items() if v is not None})
+        return entity
@@ -0,0 +1,60 @@
+"""
+Emergency fund coverage: how many months of essential spending savings would cover.
+"""
+
+from datetime import datetime
+from decimal import Decimal
+from typing import Dict, List, Optional
+
+from rmanalyzer.models import AnyCategory, IgnoredFrom, Transaction
+
+__all__ = [
+    "LOOKBACK_MONTHS",
+    "previous_months",
+    "average_monthly_spend",
+    "coverage_months",
+]
+
+# Complete months averaged to estimate monthly essential spend
+LOOKBACK_MONTHS = 6
+
+
+def previous_months(now: datetime, count: int) -> List[str]:
+    """Returns the `count` complete months (YYYY-MM) before `now`, oldest first."""
+    months = []
+    year, month = now.year, now.month
+    for _ in range(count):
+        year, month = (year - 1, 12) if month == 1 else (year, month - 1)
+        months.append(f"{year:04d}-{month:02d}")
+    return list(reversed(months))
+
+
+def average_monthly_spend(
+    monthly: Dict[str, List[Transaction]], categories: List[AnyCategory]
+) -> Decimal:
+    """
+    Averages the spend in the given categories over the months that have any
+    transactions, so a household's first months don't drag the average down.
+    """
+    totals = [
+        sum(
+            (
+                t.amount
+                for t in transactions
+                if t.category in categories and t.ignore == IgnoredFrom.NOTHING
+            ),
+            start=Decimal("0.00"),
+        )
+        for transactions in monthly.values()
+        if transactions
+    ]
+    if not totals:
+        return Decimal("0.00")
+    return (sum(totals, start=Decimal("0.00")) / len(totals)).quantize(Decimal("0.01"))
+
+
+def coverage_months(fund: Decimal, monthly_spend: Decimal) -> Optional[float]:
+    """Months the fund covers. None when there is no essential spend to cover."""
+    if monthly_spend <= 0:
+        return None
+    return round(float(fund / monthly_spend), 1)
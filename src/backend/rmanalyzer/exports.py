@@ -0,0 +1,210 @@
+"""
+CSV and Excel exports of savings data and processed transactions for use in
+spreadsheets.
+"""
+
+import csv
+import io
+import itertools
+import json
+import re
+from dataclasses import dataclass
+from decimal import Decimal
+from typing import Dict, Iterable, Iterator, List, Union
+
+from rmanalyzer import xlsx
+from rmanalyzer.models import Transaction
+from rmanalyzer.utils import FORMULA_PREFIXES, to_currency
+
+__all__ = [
+    "ExportStream",
+    "savings_csv",
+    "budget_status_csv",
+    "transactions_csv",
+    "transactions_csv_stream",
+    "transactions_ndjson_stream",
+    "transactions_xlsx_stream",
+    "transaction_json",
+    "neutralize",
+]
+
+SAVINGS_COLUMNS = ["Item", "Cost", "Cumulative Cost", "Remaining", "Percent Used"]
+BUDGET_COLUMNS = [
+    "Month",
+    "Starting Balance",
+    "Total Cost",
+    "Remaining",
+    "Percent Used",
+]
+# The upload format's columns, so a processed report can be uploaded again
+TRANSACTION_COLUMNS = [
+    "Date",
+    "Name",
+    "Account Number",
+    "Amount",
+    "Category",
+    "Ignored From",
+    "Owner",
+]
+
+
+_NUMBER = re.compile(r"^[+-]?\d+(\.\d+)?$")
+
+
+def neutralize(value: str) -> str:
+    """
+    Quotes a cell a spreadsheet would otherwise run as a formula, such as a
+    transaction named =HYPERLINK(...). Plain numbers like -12.50 are left alone.
+    Uploads strip the quote again; see utils.unquote_formula.
+    """
+    if value.startswith(FORMULA_PREFIXES) and not _NUMBER.match(value):
+        return "'" + value
+    return value
+
+
+def _percent(part: Decimal, whole: Decimal) -> str:
+    """Formats part as a percentage of whole. Empty when there is no balance."""
+    if whole == 0:
+        return ""
+    return f"{part / whole * 100:.1f}"
+
+
+@dataclass
+class ExportStream:
+    """A download rendered a chunk at a time as its rows are read."""
+
+    chunks: Iterator[Union[str, bytes]]
+    media_type: str
+    file_name: str
+
+
+def _to_csv(header: List[str], rows: List[List[str]]) -> str:
+    """Writes rows to a CSV string with a header row, neutralizing formulas."""
+    out = io.StringIO()
+    writer = csv.writer(out, lineterminator="\n")
+    writer.writerow(header)
+    writer.writerows([neutralize(cell) for cell in row] for row in rows)
+    return out.getvalue()
+
+
+def savings_csv(data: Dict[str, object]) -> str:
+    """
+    Renders a savings month as one row per item, with the running total and what is
+    left of the starting balance after each item.
+    """
+    balance = Decimal(str(data.get("startingBalance", 0)))
+    spent = Decimal("0.00")
+    rows = []
+    for item in data.get("items", []):  # type: ignore
+        cost = Decimal(str(item.get("cost", 0)))
+        spent += cost
+        rows.append(
+            [
+                item.get("name", ""),
+                to_currency(cost),
+                to_currency(spent),
+                to_currency(balance - spent),
+                _percent(spent, balance),
+            ]
+        )
+    return _to_csv(SAVINGS_COLUMNS, rows)
+
+
+def budget_status_csv(months: Dict[str, Dict[str, object]]) -> str:
+    """Renders one summary row per month: starting balance, total cost and remaining."""
+    rows = []
+    for month in sorted(months):
+        data = months[month]
+        balance = Decimal(str(data.get("startingBalance", 0)))
+        items: List[Dict[str, object]] = data.get("items", [])  # type: ignore
+        costs = [Decimal(str(i.get("cost", 0))) for i in items]
+        spent = sum(costs, start=Decimal("0.00"))
+        rows.append(
+            [
+                month,
+                to_currency(balance),
+                to_currency(spent),
+                to_currency(balance - spent),
+                _percent(spent, balance),
+            ]
+        )
+    return _to_csv(BUDGET_COLUMNS, rows)
+
+
+def _transaction_row(t: Transaction) -> List[str]:
+    """A transaction's CSV cells, with any formula in its text neutralized."""
+    row = [
+        t.date.isoformat(),
+        t.name,
+        str(t.account_number),
+        to_currency(t.amount),
+        t.category.value,
+        t.ignore.value,
+        t.owner or "",
+    ]
+    return [neutralize(cell) for cell in row]
+
+
+def transactions_csv(transactions: List[Transaction]) -> str:
+    """Renders categorized transactions as one row each, in date order."""
+    rows = [_transaction_row(t) for t in sorted(transactions, key=lambda t: t.date)]
+    return _to_csv(TRANSACTION_COLUMNS, rows)
+
+
+def transactions_csv_stream(transactions: Iterable[Transaction]) -> Iterator[str]:
+    """
+    Renders transactions as CSV a line at a time, in the order they arrive, so an
+    export never holds more than one row. Columns match transactions_csv.
+    """
+    out = io.StringIO()
+    writer = csv.writer(out, lineterminator="\n")
+    rows = map(_transaction_row, transactions)
+    for row in itertools.chain([TRANSACTION_COLUMNS], rows):
+        writer.writerow(row)
+        yield out.getvalue()
+        out.seek(0)
+        out.truncate()
+
+
+def transactions_xlsx_stream(transactions: Iterable[Transaction]) -> Iterator[bytes]:
+    """
+    Renders transactions as an Excel workbook, written as they arrive like
+    transactions_csv_stream and with its columns. Account numbers and amounts are
+    numbers, so the sheet can total them, and the workbook can be uploaded again.
+    """
+    rows = (
+        [
+            t.date.isoformat(),
+            t.name,
+            t.account_number,
+            t.amount,
+            t.category.value,
+            t.ignore.value,
+            t.owner or "",
+        ]
+        for t in transactions
+    )
+    return xlsx.workbook_stream(itertools.chain([TRANSACTION_COLUMNS], rows))
+
+
+def transaction_json(t: Transaction) -> Dict[str, object]:
+    """Serializes a transaction with every field for the API and NDJSON exports."""
+    return {
+        "date": t.date.isoformat(),
+        "name": t.name,
+        "accountNumber": t.account_number,
+        "amount": float(t.amount),
+        "category": t.category.value,
+        "ignore": t.ignore.value,
+        "owner": t.owner,
+        "currency": t.currency,
+        "originalAmount": (
+            float(t.original_amount) if t.original_amount is not None else None
+        ),
+    }
+
+
+def transactions_ndjson_stream(transactions: Iterable[Transaction]) -> Iterator[str]:
+    """Renders transactions as newline-delimited JSON, one object per line."""
+    for t in transactions:
+        yield json.dumps(transaction_json(t)) + "\n"
@@ -0,0 +1,96 @@
+"""
+Card annual fees: when the next fee posts and whether the card earns its keep.
+"""
+
+from datetime import date
+from decimal import Decimal
+from typing import Any, Dict, List, Optional
+
+from rmanalyzer.models import IgnoredFrom, Transaction
+
+__all__ = [
+    "FEE_REMINDER_DAYS",
+    "next_fee_date",
+    "upcoming_fees",
+    "card_transactions",
+    "card_spend",
+    "fee_summary",
+]
+
+# How far ahead of a fee month its reminder is sent
+FEE_REMINDER_DAYS = 30
+
+
+def next_fee_date(fee_month: int, today: date) -> date:
+    """
+    First day of the next month the fee posts in. A fee due this month counts as
+    upcoming until the month is over.
+    """
+    year = today.year if fee_month >= today.month else today.year + 1
+    return date(year, fee_month, 1)
+
+
+def upcoming_fees(
+    accounts: List[Dict[str, Any]], today: date, days: int = FEE_REMINDER_DAYS
+) -> List[Dict[str, Any]]:
+    """
+    Accounts with an annual fee posting within `days` of today, soonest first, each
+    with its `feeDate` added.
+    """
+    upcoming = []
+    for account in accounts:
+        if not account.get("annualFee") or not account.get("feeMonth"):
+            continue
+        fee_date = next_fee_date(int(account["feeMonth"]), today)
+        if (fee_date - today).days <= days:
+            upcoming.append({**account, "feeDate": fee_date.isoformat()})
+    return sorted(upcoming, key=lambda a: a["feeDate"])
+
+
+def card_transactions(
+    transactions: List[Transaction], mask: Optional[str]
+) -> List[Transaction]:
+    """
+    Purchases on the card whose account number ends in the mask. Credits
+    (payments, refunds) and ignored transactions are left out.
+    """
+    if not mask:
+        return []
+    return [
+        t
+        for t in transactions
+        if t.account_number == int(mask)
+        and t.amount > 0
+        and t.ignore == IgnoredFrom.NOTHING
+    ]
+
+
+def card_spend(transactions: List[Transaction], mask: Optional[str]) -> Decimal:
+    """Total purchases on the card whose account number ends in the mask."""
+    return sum(
+        (t.amount for t in card_transactions(transactions, mask)),
+        start=Decimal("0.00"),
+    )
+
+
+def fee_summary(
+    account: Dict[str, Any], transactions: List[Transaction], today: date
+) -> Dict[str, Any]:
+    """
+    Compares a card's annual fee with a year of spend on it. `feeRate` is the fee
+    as a share of spend (None when the card went unused), the return a card's
+    rewards need to beat to be worth keeping.
+    """
+    fee = Decimal(str(account["annualFee"]))
+    spend = card_spend(transactions, account.get("mask"))
+    return {
+        "accountId": account["accountId"],
+        "institution": account.get("institution"),
+        "mask": account.get("mask"),
+        "annualFee": float(fee),
+        "feeDate": next_fee_date(int(account["feeMonth"]), today).isoformat()
+        if account.get("feeMonth")
+        else None,
+        "spend": float(spend),
+        "feeRate": round(float(fee / spend), 4) if spend > 0 else None,
+    }
@@ -0,0 +1,249 @@
+"""
+Transaction filters: a compact expression language, e.g.
+`amount>100 and category:"Groceries" and merchant~costco`, parsed once into a
+predicate the transactions endpoints and category rules share.
+
+Terms compare a field with a value: `:` (or `=`) equals, `!=` differs, `~`
+contains and `>`, `>=`, `<`, `<=` order. Text comparisons ignore case. Terms
+combine with `not`, `and` and `or`, binding in that order, and parentheses.
+Values with spaces or operators in them are double-quoted.
+"""
+
+import functools
+import operator
+import re
+from dataclasses import dataclass, field
+from datetime import date
+from decimal import Decimal, InvalidOperation
+from typing import Any, Callable, Dict, FrozenSet, List, Optional, Set, Tuple
+
+from rmanalyzer.diff import merchant_name
+from rmanalyzer.models import Transaction
+
+__all__ = ["FILTER_FIELDS", "MAX_DEPTH", "Filter", "FilterError", "parse"]
+
+Predicate = Callable[[Transaction], bool]
+
+# Field name to (kind, value of a transaction)
+FILTER_FIELDS: Dict[str, Tuple[str, Callable[[Transaction], Any]]] = {
+    "amount": ("number", lambda t: t.amount),
+    "account": ("number", lambda t: Decimal(t.account_number)),
+    "date": ("date", lambda t: t.date),
+    "category": ("text", lambda t: t.category.value),
+    "merchant": ("text", lambda t: merchant_name(t.name)),
+    "name": ("text", lambda t: t.name),
+    "owner": ("text", lambda t: t.owner or ""),
+}
+
+_ORDERED = {
+    ":": operator.eq,
+    "!=": operator.ne,
+    ">": operator.gt,
+    ">=": operator.ge,
+    "<": operator.lt,
+    "<=": operator.le,
+}
+_TEXT = {
+    ":": operator.eq,
+    "!=": operator.ne,
+    "~": operator.contains,
+}
+
+_TOKEN = re.compile(
+    r"""\s*(?:
+        (?P<paren>[()])
+        |(?P<op>>=|<=|!=|[><:~=])
+        |"(?P<string>(?:[^"\\]|\\.)*)"
+        |(?P<word>[^\s()"<>=!:~]+)
+    )""",
+    re.VERBOSE,
+)
+
+_KEYWORDS = ("and", "or", "not")
+
+# Most parentheses and nots a term can sit inside, well short of the recursion limit
+MAX_DEPTH = 32
+
+
+class FilterError(ValueError):
+    """An expression that doesn't parse, saying what went wrong and where."""
+
+
+@dataclass(frozen=True)
+class Filter:
+    """
+    A parsed filter expression. categories holds the category names its terms
+    compare with (other than by `~`), lowercased.
+    """
+
+    expression: str
+    predicate: Predicate = field(repr=False, compare=False)
+    categories: FrozenSet[str] = field(default=frozenset(), compare=False)
+
+    def matches(self, t: Transaction) -> bool:
+        """True when the transaction meets the expression."""
+        return self.predicate(t)
+
+
+def _tokenize(expression: str) -> List[Tuple[str, str, int]]:
+    """Splits an expression into (kind, text, column) tokens."""
+    tokens = []
+    position = 0
+    while expression[position:].strip():
+        match = _TOKEN.match(expression, position)
+        if not match:
+            start = len(expression) - len(expression[position:].lstrip())
+            raise FilterError(f"Unexpected character at column {start + 1}")
+        kind = match.lastgroup or ""
+        text, column = match.group(kind), match.start(kind) + 1
+        if kind == "string":
+            text = re.sub(r"\\(.)", r"\1", text)
+        elif kind == "word" and text.lower() in _KEYWORDS:
+            kind, text = "keyword", text.lower()
+        tokens.append((kind, text, column))
+        position = match.end()
+    return tokens
+
+
+def _term(
+    name: str, op: str, value: str, column: int, known: Optional[FrozenSet[str]]
+) -> Predicate:
+    """
+    Compiles one `field op value` comparison. Categories must be among the known
+    names, lowercased, when they're given.
+    """
+    op = ":" if op == "=" else op
+    if name.lower() not in FILTER_FIELDS:
+        fields = ", ".join(FILTER_FIELDS)
+        raise FilterError(f"Unknown field '{name}' at column {column}: use {fields}")
+    kind, get = FILTER_FIELDS[name.lower()]
+
+    if kind == "text":
+        if op not in _TEXT:
+            raise FilterError(f"'{name}' can't be compared with {op}")
+        wanted = value.lower()
+        if name.lower() == "category" and op != "~":
+            if known is not None and wanted not in known:
+                raise FilterError(f"'{value}' is not a known category")
+        elif name.lower() == "merchant" and op != "~":
+            wanted = merchant_name(value).lower()
+        compare = _TEXT[op]
+        return lambda t: compare(get(t).lower(), wanted)
+
+    if op not in _ORDERED:
+        raise FilterError(f"'{name}' can't be compared with {op}")
+    try:
+        target = Decimal(value) if kind == "number" else date.fromisoformat(value)
+    except (InvalidOperation, ValueError):
+        expected = "a number" if kind == "number" else "a date (YYYY-MM-DD)"
+        raise FilterError(
+            f"'{name}' must be compared with {expected}, not '{value}'"
+        ) from None
+    compare = _ORDERED[op]
+    return lambda t: compare(get(t), target)
+
+
+class _Parser:
+    """Recursive descent over the tokens of one expression."""
+
+    def __init__(
+        self, tokens: List[Tuple[str, str, int]], known: Optional[FrozenSet[str]]
+    ) -> None:
+        self.tokens = tokens
+        self.index = 0
+        self.depth = 0
+        self.known = known
+        self.categories: Set[str] = set()
+
+    def peek(self) -> Tuple[str, str, int]:
+        if self.index < len(self.tokens):
+            return self.tokens[self.index]
+        return ("end", "", 0)
+
+    def take(self) -> Tuple[str, str, int]:
+        token = self.peek()
+        self.index += 1
+        return token
+
+    def nested(self, parse: Callable[[], Predicate], column: int) -> Predicate:
+        """Parses a parenthesized or negated part, one level deeper."""
+        if self.depth == MAX_DEPTH:
+            raise FilterError(
+                f"Filter nests more than {MAX_DEPTH} levels deep at column {column}"
+            )
+        self.depth += 1
+        try:
+            return parse()
+        finally:
+            self.depth -= 1
+
+    def parse(self) -> Predicate:
+        predicate = self.any_of()
+        kind, text, column = self.peek()
+        if kind != "end":
+            raise FilterError(f"Unexpected '{text}' at column {column}")
+        return predicate
+
+    def any_of(self) -> Predicate:
+        terms = [self.all_of()]
+        while self.peek()[:2] == ("keyword", "or"):
+            self.take()
+            terms.append(self.all_of())
+        if len(terms) == 1:
+            return terms[0]
+        return lambda t: any(term(t) for term in terms)
+
+    def all_of(self) -> Predicate:
+        terms = [self.negation()]
+        while self.peek()[:2] == ("keyword", "and"):
+            self.take()
+            terms.append(self.negation())
+        if len(terms) == 1:
+            return terms[0]
+        return lambda t: all(term(t) for term in terms)
+
+    def negation(self) -> Predicate:
+        if self.peek()[:2] == ("keyword", "not"):
+            column = self.take()[2]
+            inner = self.nested(self.negation, column)
+            return lambda t: not inner(t)
+        return self.primary()
+
+    def primary(self) -> Predicate:
+        kind, text, column = self.take()
+        if (kind, text) == ("paren", "("):
+            inner = self.nested(self.any_of, column)
+            if self.take()[:2] != ("paren", ")"):
+                raise FilterError(f"Unclosed '(' at column {column}")
+            return inner
+        if kind == "end":
+            raise FilterError("Expected a field, found the end of the filter")
+        if kind != "word":
+            raise FilterError(f"Expected a field at column {column}, found '{text}'")
+        op_kind, op, _ = self.take()
+        if op_kind != "op":
+            raise FilterError(f"Expected an operator after '{text}'")
+        value_kind, value, _ = self.take()
+        if value_kind not in ("word", "string"):
+            raise FilterError(f"Expected a value after '{text}{op}'")
+        predicate = _term(text, op, value, column, self.known)
+        if text.lower() == "category" and op != "~":
+            self.categories.add(value.lower())
+        return predicate
+
+
+@functools.lru_cache(maxsize=256)
+def parse(expression: str, categories: Optional[Tuple[str, ...]] = None) -> Filter:
+    """
+    Parses a filter expression, raising FilterError if it isn't valid. When the
+    household's category names are given, comparisons with any other category
+    are errors too; a filter saved once checked is parsed without them. Parsed
+    filters are cached, so rules can parse theirs for every transaction.
+    """
+    tokens = _tokenize(expression)
+    if not tokens:
+        raise FilterError("Filter is empty")
+    known = None if categories is None else frozenset(c.lower() for c in categories)
+    parser = _Parser(tokens, known)
+    predicate = parser.parse()
+    return Filter(expression, predicate, frozenset(parser.categories))
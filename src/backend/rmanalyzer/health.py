@@ -0,0 +1,110 @@
+"""
+Financial health score: a composite of per-factor scores from 0 to 100.
+"""
+
+from decimal import Decimal
+from typing import Dict, Optional
+
+__all__ = ["FACTOR_WEIGHTS", "health_score"]
+
+# Relative weight of each factor in the composite score
+FACTOR_WEIGHTS = {
+    "utilization": 0.25,
+    "savingsRate": 0.25,
+    "budgetAdherence": 0.3,
+    "emergencyFundMonths": 0.2,
+}
+
+# A savings rate at or above this earns the full factor score
+TARGET_SAVINGS_RATE = Decimal("0.2")
+
+# Emergency fund coverage at or above this many months earns the full factor score
+TARGET_EMERGENCY_MONTHS = 6
+
+# Credit utilization at or below this earns the full factor score, falling to
+# zero when every card is at its limit
+TARGET_UTILIZATION = 0.1
+
+
+def _totals(savings: Dict[str, object]) -> tuple[Decimal, Decimal]:
+    """Returns the starting balance and total planned cost of a savings month."""
+    balance = Decimal(str(savings.get("startingBalance", 0)))
+    items = savings.get("items", [])
+    cost = sum(
+        (Decimal(str(i.get("cost", 0))) for i in items),  # type: ignore
+        start=Decimal("0.00"),
+    )
+    return balance, cost
+
+
+def _budget_adherence(balance: Decimal, cost: Decimal) -> float:
+    """100 when costs stay within the balance, dropping with the overspend ratio."""
+    if cost <= balance:
+        return 100.0
+    if balance <= 0:
+        return 0.0
+    return float(max(Decimal("0"), 100 * (1 - (cost - balance) / balance)))
+
+
+def _savings_rate(balance: Decimal, cost: Decimal) -> Optional[float]:
+    """Scores the share of the balance left over after costs against the target."""
+    if balance <= 0:
+        return None
+    rate = max(Decimal("0"), (balance - cost) / balance)
+    return float(min(Decimal("100"), rate / TARGET_SAVINGS_RATE * 100))
+
+
+def _utilization(ratio: float) -> float:
+    """100 up to the target utilization, falling linearly to 0 at full utilization."""
+    if ratio <= TARGET_UTILIZATION:
+        return 100.0
+    return max(0.0, 100 * (1 - ratio) / (1 - TARGET_UTILIZATION))
+
+
+def health_score(
+    savings: Optional[Dict[str, object]],
+    emergency_months: Optional[float] = None,
+    utilization: Optional[float] = None,
+) -> Dict[str, object]:
+    """
+    Computes the per-factor breakdown and the composite score for a savings month,
+    the household's emergency fund coverage in months and the member's aggregate
+    credit utilization ratio.
+    The composite is the weighted average of the factors that could be computed;
+    it is None when none could.
+    """
+    scores: Dict[str, float] = {}
+    reasons: Dict[str, str] = {}
+
+    if utilization is None:
+        reasons["utilization"] = "No cards with a credit limit."
+    else:
+        scores["utilization"] = round(_utilization(utilization), 1)
+
+    if emergency_months is None:
+        reasons["emergencyFundMonths"] = "No essential spending to cover."
+    else:
+        ratio = min(emergency_months / TARGET_EMERGENCY_MONTHS, 1.0)
+        scores["emergencyFundMonths"] = round(ratio * 100, 1)
+
+    if savings is None:
+        reasons["savingsRate"] = reasons["budgetAdherence"] = "No savings data."
+    else:
+        balance, cost = _totals(savings)
+        scores["budgetAdherence"] = round(_budget_adherence(balance, cost), 1)
+        rate = _savings_rate(balance, cost)
+        if rate is None:
+            reasons["savingsRate"] = "No starting balance for the month."
+        else:
+            scores["savingsRate"] = round(rate, 1)
+
+    factors = {
+        name: {"score": scores.get(name), "weight": weight, "reason": reasons.get(name)}
+        for name, weight in FACTOR_WEIGHTS.items()
+    }
+    if not scores:
+        return {"score": None, "factors": factors}
+
+    total_weight = sum(FACTOR_WEIGHTS[name] for name in scores)
+    weighted = sum(score * FACTOR_WEIGHTS[name] for name, score in scores.items())
+    return {"score": round(weighted / total_weight, 1), "factors": factors}
@@ -0,0 +1,52 @@
+"""
+Inflation adjustment of historical amounts using a consumer price index.
+"""
+
+from decimal import Decimal
+from typing import Dict, List, Mapping
+
+__all__ = ["CPI_INDEX", "months_between", "price_index", "to_real"]
+
+# US CPI-U annual averages (1982-84 = 100), published by the BLS.
+# Households can override or extend these with the CpiIndex setting.
+CPI_INDEX: Dict[int, Decimal] = {
+    2015: Decimal("237.017"),
+    2016: Decimal("240.007"),
+    2017: Decimal("245.120"),
+    2018: Decimal("251.107"),
+    2019: Decimal("255.657"),
+    2020: Decimal("258.811"),
+    2021: Decimal("270.970"),
+    2022: Decimal("292.655"),
+    2023: Decimal("304.702"),
+    2024: Decimal("313.689"),
+}
+
+
+def months_between(start: str, end: str) -> List[str]:
+    """Returns the months (YYYY-MM) from start to end inclusive, oldest first."""
+    year, month = int(start[:4]), int(start[5:])
+    months = []
+    while f"{year:04d}-{month:02d}" <= end:
+        months.append(f"{year:04d}-{month:02d}")
+        year, month = (year + 1, 1) if month == 12 else (year, month + 1)
+    return months
+
+
+def price_index(year: int, index: Mapping[int, Decimal]) -> Decimal:
+    """
+    Returns the index value for a year. Years outside the table use the nearest
+    known year, so amounts are never adjusted beyond the published data.
+    """
+    if year in index:
+        return index[year]
+    known = min(index, key=lambda y: abs(y - year))
+    return index[known]
+
+
+def to_real(
+    amount: Decimal, year: int, base_year: int, index: Mapping[int, Decimal]
+) -> Decimal:
+    """Converts a nominal amount from `year` into `base_year` dollars."""
+    factor = price_index(base_year, index) / price_index(year, index)
+    return (amount * factor).quantize(Decimal("0.01"))
@@ -0,0 +1,92 @@
+"""
+Interest projections: what carrying a card balance costs if only a set payment
+is made each month.
+"""
+
+from datetime import date
+from decimal import Decimal
+from typing import Any, Dict, List, Optional
+
+from rmanalyzer.promos import monthly_interest
+
+__all__ = [
+    "MAX_PROJECTION_MONTHS",
+    "minimum_payment",
+    "promo_months_left",
+    "project_interest",
+]
+
+# Longest a projection runs before calling a balance never paid off
+MAX_PROJECTION_MONTHS = 600
+
+# Typical card minimum: the month's interest plus 1% of the balance, at least $25
+MINIMUM_PAYMENT_RATE = Decimal("0.01")
+MINIMUM_PAYMENT_FLOOR = Decimal("25.00")
+
+
+def minimum_payment(balance: Decimal, apr: Decimal) -> Decimal:
+    """
+    An estimate of a card's minimum payment, for cards whose issuer doesn't report
+    one. Never more than the balance.
+    """
+    estimate = monthly_interest(balance, apr) + (
+        balance * MINIMUM_PAYMENT_RATE
+    ).quantize(Decimal("0.01"))
+    return min(max(estimate, MINIMUM_PAYMENT_FLOOR), max(balance, Decimal("0.00")))
+
+
+def promo_months_left(promo_expiry: Optional[str], today: date) -> int:
+    """Whole months a promo APR still applies for, counting the current one."""
+    if not promo_expiry:
+        return 0
+    expiry = date.fromisoformat(promo_expiry)
+    if expiry <= today:
+        return 0
+    return (expiry.year - today.year) * 12 + expiry.month - today.month
+
+
+def project_interest(
+    balance: Decimal,
+    apr: Decimal,
+    payment: Decimal,
+    schedule_months: int,
+    promo_apr: Optional[Decimal] = None,
+    promo_months: int = 0,
+) -> Dict[str, Any]:
+    """
+    Projects a balance month by month when only `payment` is made: each month
+    accrues interest at the APR (or the promo APR while it lasts), then the
+    payment comes off. Returns the first `schedule_months` months, and the
+    interest paid and months taken until the balance is gone. A payment that
+    doesn't cover the interest never pays it off, so those are None.
+    """
+    schedule: List[Dict[str, Any]] = []
+    total_interest = Decimal("0.00")
+    month = 0
+    while balance > 0 and month < MAX_PROJECTION_MONTHS:
+        rate = promo_apr if promo_apr is not None and month < promo_months else apr
+        interest = monthly_interest(balance, rate)
+        paid = min(payment, balance + interest)
+        balance = balance + interest - paid
+        total_interest += interest
+        month += 1
+        if month <= schedule_months:
+            schedule.append(
+                {
+                    "month": month,
+                    "interest": float(interest),
+                    "payment": float(paid),
+                    "balance": float(balance),
+                }
+            )
+        if paid <= interest and month >= max(promo_months, schedule_months):
+            # The balance only grows from here
+            break
+
+    paid_off = balance <= 0
+    return {
+        "schedule": schedule,
+        "paidOff": paid_off,
+        "monthsToPayOff": month if paid_off else None,
+        "totalInterest": float(total_interest) if paid_off else None,
+    }
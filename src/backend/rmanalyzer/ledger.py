@@ -0,0 +1,131 @@
+"""
+Running debt ledger between two household members.
+"""
+
+from dataclasses import dataclass
+from decimal import Decimal
+from typing import Dict, List
+
+__all__ = ["LedgerEntry", "running_balance", "settled_in_month"]
+
+
+@dataclass(frozen=True)
+class LedgerEntry:
+    """
+    A change to the balance between two people: debtor owes creditor amount.
+    Entries are keyed by entry_id so re-recording the same source replaces it.
+    A settlement's period is the month whose debt it paid, or empty if it paid
+    down the running balance.
+    """
+
+    entry_id: str
+    kind: str
+    debtor: str
+    creditor: str
+    amount: Decimal
+    month: str
+    recorded_at: str
+    period: str = ""
+
+    @classmethod
+    def from_debt(
+        cls,
+        entry_id: str,
+        kind: str,
+        p1: str,
+        p2: str,
+        debt: Decimal,
+        month: str,
+        recorded_at: str,
+    ) -> "LedgerEntry":
+        """Create an entry from a signed debt: positive if p1 owes p2."""
+        debtor, creditor = (p1, p2) if debt >= 0 else (p2, p1)
+        return cls(entry_id, kind, debtor, creditor, abs(debt), month, recorded_at)
+
+    @classmethod
+    def from_entity(cls, entity: Dict[str, object]) -> "LedgerEntry":
+        """Create an entry from a debts table entity."""
+        return cls(
+            entry_id=str(entity["RowKey"]),
+            kind=str(entity.get("Kind", "")),
+            debtor=str(entity["Debtor"]),
+            creditor=str(entity["Creditor"]),
+            amount=Decimal(str(entity["Amount"])).quantize(Decimal("0.01")),
+            month=str(entity.get("Month", "")),
+            recorded_at=str(entity.get("RecordedAt", "")),
+            period=str(entity.get("Period", "")),
+        )
+
+    def to_entity(self, partition_key: str) -> Dict[str, object]:
+        """Serialize the entry for the debts table."""
+        return {
+            "PartitionKey": partition_key,
+            "RowKey": self.entry_id,
+            "Kind": self.kind,
+            "Debtor": self.debtor,
+            "Creditor": self.creditor,
+            # Convert Decimal to float for Table Storage
+            "Amount": float(self.amount),
+            "Month": self.month,
+            "RecordedAt": self.recorded_at,
+            "Period": self.period,
+        }
+
+    def to_dict(self) -> Dict[str, object]:
+        """Serialize the entry for a JSON response."""
+        return {
+            "id": self.entry_id,
+            "kind": self.kind,
+            "debtor": self.debtor,
+            "creditor": self.creditor,
+            "amount": float(self.amount),
+            "month": self.month,
+            "recordedAt": self.recorded_at,
+            "period": self.period,
+        }
+
+
+def _balance_dict(pair: List[str], balance: Decimal) -> Dict[str, object]:
+    """Expresses a signed balance (positive if pair[0] owes pair[1]) as who owes whom."""
+    if balance == 0 or len(pair) < 2:
+        return {"debtor": None, "creditor": None, "amount": 0.0}
+    debtor, creditor = pair if balance > 0 else reversed(pair)
+    return {"debtor": debtor, "creditor": creditor, "amount": float(abs(balance))}
+
+
+def running_balance(entries: List[LedgerEntry]) -> Dict[str, object]:
+    """
+    Replays the entries in the order they were recorded and returns each entry with
+    the outstanding balance after it, plus the final balance.
+    """
+    ordered = sorted(entries, key=lambda e: (e.recorded_at, e.entry_id))
+    pair = sorted({p for e in ordered for p in (e.debtor, e.creditor)})[:2]
+
+    balance = Decimal("0.00")
+    history = []
+    for entry in ordered:
+        balance += entry.amount if entry.debtor == pair[0] else -entry.amount
+        history.append({**entry.to_dict(), "balance": _balance_dict(pair, balance)})
+
+    return {"entries": history, "balance": _balance_dict(pair, balance)}
+
+
+def settled_in_month(
+    entries: List[LedgerEntry], p1: str, p2: str, month: str
+) -> Decimal:
+    """
+    The settlements paying a month's debt between two people, positive when p1
+    paid p2 on balance. Payments made in the month towards the running balance
+    don't count. A settlement entry runs from payee to payer, the payer being
+    its creditor.
+    """
+    total = Decimal("0.00")
+    for e in entries:
+        if e.kind != "settlement" or e.period != month:
+            continue
+        payer, payee = e.creditor.lower(), e.debtor.lower()
+        if (payer, payee) == (p1.lower(), p2.lower()):
+            total += e.amount
+        elif (payer, payee) == (p2.lower(), p1.lower()):
+            total -= e.amount
+    return total
@@ -0,0 +1,102 @@
+"""
+Logging configuration: level, JSON vs text output, and sampling of noisy debug lines.
+"""
+
+import collections
+import json
+import logging
+import os
+from datetime import datetime, timezone
+
+__all__ = ["configure_logging", "JsonFormatter", "DebugSamplingFilter"]
+
+TEXT_FORMAT = "%(asctime)s %(levelname)s %(name)s: %(message)s"
+
+# The Azure SDK logs every HTTP request and response header at INFO
+NOISY_LOGGERS = ["azure.core.pipeline.policies.http_logging_policy", "azure.identity"]
+
+
+class JsonFormatter(logging.Formatter):
+    """Formats records as single-line JSON objects."""
+
+    def format(self, record: logging.LogRecord) -> str:
+        timestamp = datetime.fromtimestamp(record.created, timezone.utc)
+        entry = {
+            "timestamp": timestamp.isoformat(),
+            "level": record.levelname,
+            "logger": record.name,
+            "message": record.getMessage(),
+        }
+        if record.exc_info:
+            entry["exception"] = self.formatException(record.exc_info)
+        return json.dumps(entry)
+
+
+class DebugSamplingFilter(logging.Filter):
+    """
+    Keeps only every Nth DEBUG record per call site so verbose payload logging
+    can stay enabled without flooding the logs. Other levels always pass.
+    """
+
+    def __init__(self, every: int) -> None:
+        super().__init__()
+        self._every = max(every, 1)
+        self._seen: collections.Counter[tuple[str, str]] = collections.Counter()
+
+    def filter(self, record: logging.LogRecord) -> bool:
+        if record.levelno != logging.DEBUG or self._every == 1:
+            return True
+        key = (record.name, str(record.msg))
+        self._seen[key] += 1
+        return (self._seen[key] - 1) % self._every == 0
+
+
+def configure_logging() -> None:
+    """
+    Configures the root logger from the environment:
+    LOG_LEVEL (default INFO), LOG_FORMAT (text or json, default text) and
+    LOG_DEBUG_SAMPLE_EVERY (keep every Nth repeated debug line, default 1).
+
+    The Functions worker installs its own root handler, so existing handlers are
+    reconfigured rather than replaced. Invalid values never stop the app from
+    loading: they are logged as warnings and the defaults are used instead.
+    """
+    problems: list[str] = []
+
+    level_name = os.environ.get("LOG_LEVEL", "INFO").upper()
+    level = logging.getLevelName(level_name)
+    if not isinstance(level, int):
+        problems.append(f"Invalid LOG_LEVEL {level_name!r}, using INFO.")
+        level = logging.INFO
+
+    log_format = os.environ.get("LOG_FORMAT", "text").lower()
+    if log_format not in ("text", "json"):
+        problems.append(f"Invalid LOG_FORMAT {log_format!r}, using text.")
+        log_format = "text"
+
+    raw_sample = os.environ.get("LOG_DEBUG_SAMPLE_EVERY", "1")
+    try:
+        sample_every = int(raw_sample)
+    except ValueError:
+        problems.append(f"Invalid LOG_DEBUG_SAMPLE_EVERY {raw_sample!r}, using 1.")
+        sample_every = 1
+
+    root = logging.getLogger()
+    root.setLevel(level)
+    if not root.handlers:
+        root.addHandler(logging.StreamHandler())
+
+    formatter = (
+        JsonFormatter() if log_format == "json" else logging.Formatter(TEXT_FORMAT)
+    )
+    for handler in root.handlers:
+        handler.setFormatter(formatter)
+        for existing in [f for f in handler.filters if isinstance(f, DebugSamplingFilter)]:
+            handler.removeFilter(existing)
+        handler.addFilter(DebugSamplingFilter(sample_every))
+
+    for name in NOISY_LOGGERS:
+        logging.getLogger(name).setLevel(max(level, logging.WARNING))
+
+    for problem in problems:
+        logging.getLogger(__name__).warning(problem)
@@ -0,0 +1,363 @@
+"""
+Mailbox connectors: an Outlook (Microsoft Graph) or Gmail mailbox searched on a
+schedule for statement emails, whose attachments are imported.
+"""
+
+import base64
+import hashlib
+import json
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Callable, Dict, Iterator, List, Optional, Tuple
+from urllib import parse, request
+
+__all__ = [
+    "MailRule",
+    "Mailbox",
+    "MailMessage",
+    "MailProvider",
+    "PROVIDERS",
+    "message_key",
+]
+
+# Minutes between fetches, unless a mailbox sets its own
+DEFAULT_INTERVAL_MINUTES = 60
+
+# Seconds to wait on the mail provider
+TIMEOUT = 30
+
+GRAPH_LOGIN_URL = "https://login.microsoftonline.com"
+GRAPH_API_URL = "https://graph.microsoft.com/v1.0"
+GRAPH_SCOPE = "offline_access https://graph.microsoft.com/Mail.Read"
+
+GOOGLE_AUTH_URL = "https://accounts.google.com/o/oauth2/v2/auth"
+GOOGLE_TOKEN_URL = "https://oauth2.googleapis.com/token"
+GMAIL_API_URL = "https://gmail.googleapis.com/gmail/v1/users/me"
+GMAIL_SCOPE = "https://www.googleapis.com/auth/gmail.readonly"
+
+
+@dataclass(frozen=True)
+class MailRule:
+    """
+    Which emails are statements: those from a sender and/or with a subject
+    containing some text, both case-insensitive. Unset parts match anything.
+    """
+
+    sender: Optional[str] = None
+    subject: Optional[str] = None
+
+    def matches(self, message: "MailMessage") -> bool:
+        """Whether a message is from the sender and has the subject."""
+        return (
+            not self.sender or self.sender.lower() in message.sender.lower()
+        ) and (not self.subject or self.subject.lower() in message.subject.lower())
+
+    @classmethod
+    def from_json(cls, data: Dict[str, object]) -> "MailRule":
+        """Create a rule from an API request body's rule."""
+        return cls(
+            sender=data.get("from") or None, subject=data.get("subject") or None
+        )
+
+    def to_json(self) -> Dict[str, object]:
+        """Serialize the rule for the API."""
+        return {"from": self.sender, "subject": self.subject}
+
+
+@dataclass(frozen=True)
+class Mailbox:
+    """
+    A mailbox to fetch statements from. client_id and secret_name identify the
+    OAuth app registration; secret_name names the app setting holding its client
+    secret. Access is granted by signing in to the mailbox once, which stores a
+    refresh token. tenant_id is the Entra tenant for Graph ("common" by default).
+    """
+
+    name: str
+    provider: str
+    client_id: str
+    secret_name: str
+    rules: Tuple[MailRule, ...]
+    tenant_id: str = "common"
+    interval_minutes: int = DEFAULT_INTERVAL_MINUTES
+
+    @property
+    def status_key(self) -> str:
+        """Name its status and fetched messages are kept under, apart from files."""
+        return f"mail:{self.name}"
+
+    def matches(self, message: "MailMessage") -> bool:
+        """Whether a message matches any of the mailbox's rules."""
+        return any(rule.matches(message) for rule in self.rules)
+
+    @classmethod
+    def from_json(cls, name: str, data: Dict[str, object]) -> "Mailbox":
+        """Create a mailbox from a validated API request body."""
+        return cls(
+            name=name,
+            provider=str(data["provider"]),
+            client_id=str(data["clientId"]),
+            secret_name=str(data["secretName"]),
+            rules=tuple(MailRule.from_json(r) for r in data["rules"]),
+            tenant_id=str(data.get("tenantId") or "common"),
+            interval_minutes=int(
+                data.get("intervalMinutes") or DEFAULT_INTERVAL_MINUTES
+            ),
+        )
+
+    def to_json(self) -> Dict[str, object]:
+        """Serialize the mailbox for the API."""
+        return {
+            "name": self.name,
+            "provider": self.provider,
+            "clientId": self.client_id,
+            "secretName": self.secret_name,
+            "rules": [rule.to_json() for rule in self.rules],
+            "tenantId": self.tenant_id,
+            "intervalMinutes": self.interval_minutes,
+        }
+
+    @classmethod
+    def from_entity(cls, entity: Dict[str, object]) -> "Mailbox":
+        """Create a mailbox from a connectors table entity."""
+        return cls(
+            name=str(entity["RowKey"]),
+            provider=str(entity["Provider"]),
+            client_id=str(entity["ClientId"]),
+            secret_name=str(entity["SecretName"]),
+            rules=tuple(
+                MailRule.from_json(r) for r in json.loads(str(entity["Rules"]))
+            ),
+            tenant_id=str(entity.get("TenantId") or "common"),
+            interval_minutes=int(
+                entity.get("IntervalMinutes") or DEFAULT_INTERVAL_MINUTES
+            ),
+        )
+
+    def to_entity(self, partition_key: str) -> Dict[str, object]:
+        """Serialize the mailbox for the connectors table."""
+        return {
+            "PartitionKey": partition_key,
+            "RowKey": self.name,
+            "Provider": self.provider,
+            "ClientId": self.client_id,
+            "SecretName": self.secret_name,
+            "Rules": json.dumps([rule.to_json() for rule in self.rules]),
+            "TenantId": self.tenant_id,
+            "IntervalMinutes": self.interval_minutes,
+        }
+
+
+@dataclass(frozen=True)
+class MailMessage:
+    """An email with attachments. received is an ISO timestamp."""
+
+    id: str
+    sender: str
+    subject: str
+    received: str
+
+
+def message_key(message_id: str) -> str:
+    """
+    Identifies a message, so its attachments are fetched once. Provider ids can
+    hold characters table keys can't, so they're hashed.
+    """
+    return hashlib.sha256(message_id.encode("utf-8")).hexdigest()
+
+
+@dataclass(frozen=True)
+class MailProvider:
+    """
+    A mail API. authorize_url is where an admin signs in to grant access, given
+    the redirect URL and OAuth state; redeem trades an authorization code (or a
+    refresh token) for tokens; messages lists messages with attachments received
+    since a time; attachments returns a message's file attachments by name.
+    """
+
+    name: str
+    authorize_url: Callable[[Mailbox, str, str], str]
+    redeem: Callable[[Mailbox, str, Dict[str, str]], Dict[str, str]]
+    messages: Callable[[str, datetime], Iterator[MailMessage]]
+    attachments: Callable[[str, str], List[Tuple[str, bytes]]]
+
+
+def _fetch(url: str, token: Optional[str] = None, form=None) -> dict:
+    """GETs (or POSTs a form to) a URL, returning the JSON response."""
+    headers = {"Accept": "application/json"}
+    if token:
+        headers["Authorization"] = f"Bearer {token}"
+    data = parse.urlencode(form).encode("utf-8") if form else None
+    req = request.Request(url, data=data, headers=headers)
+    with request.urlopen(req, timeout=TIMEOUT) as resp:
+        return json.loads(resp.read().decode("utf-8"))
+
+
+def _graph_authorize_url(mailbox: Mailbox, redirect_url: str, state: str) -> str:
+    """The Microsoft identity platform sign-in page for reading mail."""
+    query = parse.urlencode(
+        {
+            "client_id": mailbox.client_id,
+            "response_type": "code",
+            "redirect_uri": redirect_url,
+            "response_mode": "query",
+            "scope": GRAPH_SCOPE,
+            "state": state,
+        }
+    )
+    return f"{GRAPH_LOGIN_URL}/{mailbox.tenant_id}/oauth2/v2.0/authorize?{query}"
+
+
+def _graph_redeem(
+    mailbox: Mailbox, secret: str, grant: Dict[str, str]
+) -> Dict[str, str]:
+    """Redeems a grant at the Microsoft identity platform token endpoint."""
+    return _fetch(
+        f"{GRAPH_LOGIN_URL}/{mailbox.tenant_id}/oauth2/v2.0/token",
+        form={
+            "client_id": mailbox.client_id,
+            "client_secret": secret,
+            "scope": GRAPH_SCOPE,
+            **grant,
+        },
+    )
+
+
+def _graph_messages(token: str, since: datetime) -> Iterator[MailMessage]:
+    """Messages with attachments received since a time, following pages."""
+    query = parse.urlencode(
+        {
+            "$filter": (
+                "hasAttachments eq true and receivedDateTime ge "
+                f"{since.astimezone(timezone.utc).strftime('%Y-%m-%dT%H:%M:%SZ')}"
+            ),
+            "$select": "id,subject,from,receivedDateTime",
+            "$top": "50",
+        }
+    )
+    url: Optional[str] = f"{GRAPH_API_URL}/me/messages?{query}"
+    while url:
+        page = _fetch(url, token)
+        for m in page.get("value", []):
+            yield MailMessage(
+                id=m["id"],
+                sender=((m.get("from") or {}).get("emailAddress") or {}).get(
+                    "address", ""
+                ),
+                subject=m.get("subject") or "",
+                received=m.get("receivedDateTime") or "",
+            )
+        url = page.get("@odata.nextLink")
+
+
+def _graph_attachments(token: str, message_id: str) -> List[Tuple[str, bytes]]:
+    """A message's file attachments; attached emails and links are skipped."""
+    page = _fetch(
+        f"{GRAPH_API_URL}/me/messages/{parse.quote(message_id, safe='')}/attachments",
+        token,
+    )
+    return [
+        (a["name"], base64.b64decode(a["contentBytes"]))
+        for a in page.get("value", [])
+        if a.get("@odata.type") == "#microsoft.graph.fileAttachment"
+    ]
+
+
+def _google_authorize_url(mailbox: Mailbox, redirect_url: str, state: str) -> str:
+    """Google's sign-in page for read-only Gmail access, issuing a refresh token."""
+    query = parse.urlencode(
+        {
+            "client_id": mailbox.client_id,
+            "response_type": "code",
+            "redirect_uri": redirect_url,
+            "scope": GMAIL_SCOPE,
+            "access_type": "offline",
+            "prompt": "consent",
+            "state": state,
+        }
+    )
+    return f"{GOOGLE_AUTH_URL}?{query}"
+
+
+def _google_redeem(
+    mailbox: Mailbox, secret: str, grant: Dict[str, str]
+) -> Dict[str, str]:
+    """Redeems a grant at Google's token endpoint."""
+    return _fetch(
+        GOOGLE_TOKEN_URL,
+        form={"client_id": mailbox.client_id, "client_secret": secret, **grant},
+    )
+
+
+def _gmail_messages(token: str, since: datetime) -> Iterator[MailMessage]:
+    """Messages with attachments received since a time, following pages."""
+    query = {"q": f"has:attachment after:{int(since.timestamp())}"}
+    while True:
+        page = _fetch(f"{GMAIL_API_URL}/messages?{parse.urlencode(query)}", token)
+        for ref in page.get("messages", []):
+            message = _fetch(
+                f"{GMAIL_API_URL}/messages/{ref['id']}?format=metadata"
+                "&metadataHeaders=From&metadataHeaders=Subject",
+                token,
+            )
+            headers = {
+                h["name"].lower(): h["value"]
+                for h in message.get("payload", {}).get("headers", [])
+            }
+            yield MailMessage(
+                id=message["id"],
+                sender=headers.get("from", ""),
+                subject=headers.get("subject", ""),
+                received=datetime.fromtimestamp(
+                    int(message.get("internalDate", 0)) / 1000, timezone.utc
+                ).isoformat(),
+            )
+        if not page.get("nextPageToken"):
+            return
+        query["pageToken"] = page["nextPageToken"]
+
+
+def _gmail_parts(part: dict) -> Iterator[dict]:
+    """A message part and all the parts nested in it."""
+    yield part
+    for child in part.get("parts", []):
+        yield from _gmail_parts(child)
+
+
+def _gmail_attachments(token: str, message_id: str) -> List[Tuple[str, bytes]]:
+    """A message's attachments, downloaded by attachment id."""
+    message = _fetch(f"{GMAIL_API_URL}/messages/{message_id}?format=full", token)
+    attachments = []
+    for part in _gmail_parts(message.get("payload", {})):
+        attachment_id = part.get("body", {}).get("attachmentId")
+        if not part.get("filename") or not attachment_id:
+            continue
+        body = _fetch(
+            f"{GMAIL_API_URL}/messages/{message_id}/attachments/{attachment_id}",
+            token,
+        )
+        data = body["data"] + "=" * (-len(body["data"]) % 4)
+        attachments.append((part["filename"], base64.urlsafe_b64decode(data)))
+    return attachments
+
+
+# Mail APIs by provider name
+PROVIDERS = {
+    provider.name: provider
+    for provider in (
+        MailProvider(
+            "graph",
+            _graph_authorize_url,
+            _graph_redeem,
+            _graph_messages,
+            _graph_attachments,
+        ),
+        MailProvider(
+            "gmail",
+            _google_authorize_url,
+            _google_redeem,
+            _gmail_messages,
+            _gmail_attachments,
+        ),
+    )
+}
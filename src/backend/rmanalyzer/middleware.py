@@ -0,0 +1,389 @@
+"""
+Recovery and logging wrappers applied to every Function entry point.
+"""
+
+import collections
+import contextvars
+import dataclasses
+import functools
+import json
+import logging
+import os
+import time
+import uuid
+from http import HTTPStatus
+from typing import Any, Callable, Iterable, Iterator
+from urllib.parse import parse_qsl, urlencode, urlsplit
+
+import azure.functions as func
+from rmanalyzer import amounts, sandbox
+from rmanalyzer.services import shared_metrics, table_metrics, table_routes_scope
+
+__all__ = [
+    "http_logging",
+    "http_recovery",
+    "http_streaming",
+    "queue_recovery",
+    "scheduled",
+    "api_version",
+    "household",
+    "failure_counts",
+    "REQUESTS_GROUP",
+    "CORRELATION_HEADER",
+]
+
+logger = logging.getLogger(__name__)
+
+CORRELATION_HEADER = "x-correlation-id"
+
+# Content types whose bodies are never logged
+SUPPRESSED_CONTENT_TYPES = (
+    "multipart/",
+    "application/octet-stream",
+    "application/pdf",
+    "image/",
+)
+
+# Query parameters that carry signatures or credentials and are never logged
+REDACTED_PARAMS = frozenset({"sig", "code", "state", "token", "key"})
+
+HttpHandler = Callable[[func.HttpRequest], func.HttpResponse]
+
+# Unhandled failures per handler since the worker started
+_FAILURES: collections.Counter[str] = collections.Counter()
+
+# Shared metric group counting requests, and those answered with a server error
+REQUESTS_GROUP = "http"
+
+
+def failure_counts() -> dict[str, int]:
+    """Returns the number of unhandled failures per handler."""
+    return dict(_FAILURES)
+
+
+def _record_failure(name: str) -> None:
+    """Increments the failure metric and logs it for aggregation in App Insights."""
+    _FAILURES[name] += 1
+    logger.info("metric handler_failures handler=%s count=%d", name, _FAILURES[name])
+
+
+def http_recovery(handler: HttpHandler) -> HttpHandler:
+    """
+    Catches anything a HTTP handler lets escape, logs the traceback with a correlation ID,
+    and returns a clean 500 JSON envelope instead of the host's default error.
+    """
+
+    @functools.wraps(handler)
+    def wrapper(req: func.HttpRequest) -> func.HttpResponse:
+        try:
+            return handler(req)
+        except Exception:  # pylint: disable=broad-exception-caught
+            correlation_id = req.headers.get(CORRELATION_HEADER) or uuid.uuid4().hex
+            logger.exception(
+                "Unhandled error in %s [correlation_id=%s]",
+                handler.__name__,
+                correlation_id,
+            )
+            _record_failure(handler.__name__)
+            return func.HttpResponse(
+                json.dumps(
+                    {"error": "Internal Server Error", "correlationId": correlation_id}
+                ),
+                mimetype="application/json",
+                headers={CORRELATION_HEADER: correlation_id},
+                status_code=HTTPStatus.INTERNAL_SERVER_ERROR,
+            )
+
+    return wrapper
+
+
+def queue_recovery(
+    handler: Callable[[func.QueueMessage], None],
+) -> Callable[[func.QueueMessage], None]:
+    """
+    Logs the traceback of a failed queue handler with the message ID and dequeue count,
+    then re-raises so the host retries and eventually moves the message to the poison queue.
+    Table requests made while handling the message are counted against the handler,
+    and go to the tables switched to when it started.
+    """
+
+    @functools.wraps(handler)
+    def wrapper(msg: func.QueueMessage) -> None:
+        try:
+            with table_routes_scope(), table_metrics.storage_operation(
+                handler.__name__
+            ):
+                handler(msg)
+        except Exception:
+            logger.exception(
+                "Unhandled error in %s [message_id=%s, dequeue_count=%s]",
+                handler.__name__,
+                msg.id,
+                msg.dequeue_count,
+            )
+            _record_failure(handler.__name__)
+            raise
+        finally:
+            shared_metrics.flush()
+
+    return wrapper
+
+
+def scheduled(
+    handler: Callable[[func.TimerRequest], None],
+) -> Callable[[func.TimerRequest], None]:
+    """Runs a timer job against the tables switched to when it started."""
+
+    @functools.wraps(handler)
+    def wrapper(timer: func.TimerRequest) -> None:
+        with table_routes_scope():
+            handler(timer)
+
+    return wrapper
+
+
+def _body_preview(body: bytes | None, content_type: str | None, limit: int) -> str:
+    """Renders a loggable preview of a body, suppressing binary and multipart content."""
+    if not body:
+        return "<empty>"
+    content_type = (content_type or "").lower()
+    if content_type.startswith(SUPPRESSED_CONTENT_TYPES):
+        return f"<{len(body)} bytes suppressed ({content_type})>"
+    try:
+        text = body[:limit].decode("utf-8")
+    except UnicodeDecodeError:
+        return f"<{len(body)} bytes suppressed (binary)>"
+    if len(body) > limit:
+        text += f"... <{len(body) - limit} more bytes>"
+    return text
+
+
+def _loggable_url(url: str) -> str:
+    """The request path and query, with signature and credential values redacted."""
+    parts = urlsplit(url)
+    if not parts.query:
+        return parts.path
+    query = [
+        (name, "REDACTED" if name.lower() in REDACTED_PARAMS else value)
+        for name, value in parse_qsl(parts.query, keep_blank_values=True)
+    ]
+    return f"{parts.path}?{urlencode(query)}"
+
+
+def _log_request(req: func.HttpRequest, status_code: int, start: float) -> None:
+    """Counts a request towards the error rate and logs its summary."""
+    elapsed_ms = (time.perf_counter() - start) * 1000
+    shared_metrics.add(
+        REQUESTS_GROUP,
+        requests=1,
+        serverErrors=int(status_code >= HTTPStatus.INTERNAL_SERVER_ERROR),
+    )
+    shared_metrics.flush()
+
+    logger.info(
+        "%s %s -> %d (%.0f ms)",
+        req.method,
+        _loggable_url(req.url),
+        status_code,
+        elapsed_ms,
+    )
+
+
+def http_logging(log_body: bool = False) -> Callable[[HttpHandler], HttpHandler]:
+    """
+    Logs the method, path, status and duration of every request to a HTTP handler.
+    Signatures and credentials in the query string (REDACTED_PARAMS) are redacted.
+
+    Bodies are only logged (at DEBUG) for routes that opt in, either with log_body=True
+    or by listing the handler name in LOG_BODY_ROUTES. Routes carrying amounts,
+    account numbers or credentials don't opt in. Previews are capped at
+    LOG_BODY_PREVIEW_BYTES (default 1024).
+
+    Table requests made while handling the request are counted against the handler,
+    and the request towards the error rate, in the metrics every worker shares. They
+    go to the tables switched to when it started.
+    """
+
+    def decorator(handler: HttpHandler) -> HttpHandler:
+        @functools.wraps(handler)
+        def wrapper(req: func.HttpRequest) -> func.HttpResponse:
+            opted_in = os.environ.get("LOG_BODY_ROUTES", "").split(",")
+            with_body = log_body or handler.__name__ in [r.strip() for r in opted_in]
+            limit = int(os.environ.get("LOG_BODY_PREVIEW_BYTES", "1024"))
+
+            if with_body:
+                logger.debug(
+                    "%s request body: %s",
+                    handler.__name__,
+                    _body_preview(
+                        req.get_body(), req.headers.get("content-type"), limit
+                    ),
+                )
+
+            start = time.perf_counter()
+            with table_routes_scope(), table_metrics.storage_operation(
+                handler.__name__
+            ):
+                resp = handler(req)
+            _log_request(req, resp.status_code, start)
+            if with_body:
+                logger.debug(
+                    "%s response body: %s",
+                    handler.__name__,
+                    _body_preview(resp.get_body(), resp.mimetype, limit),
+                )
+            return resp
+
+        return wrapper
+
+    return decorator
+
+
+def api_version(
+    amount_fields: Iterable[str] = (),
+) -> Callable[[HttpHandler], HttpHandler]:
+    """
+    Negotiates the API version from the x-api-version header, rejecting unsupported
+    versions with 400, and echoes it on the response. In versions that write
+    amounts as strings, the numbers in the JSON response's amount fields (the
+    common AMOUNT_FIELDS plus the route's own amount_fields) are rewritten as
+    two-place decimal strings.
+    """
+    fields = amounts.AMOUNT_FIELDS | frozenset(amount_fields)
+
+    def decorator(handler: HttpHandler) -> HttpHandler:
+        @functools.wraps(handler)
+        def wrapper(req: func.HttpRequest) -> func.HttpResponse:
+            version = amounts.requested_version(req.headers)
+            if version is None:
+                supported = ", ".join(map(str, amounts.SUPPORTED_API_VERSIONS))
+                return func.HttpResponse(
+                    f"Unsupported API version, expected one of: {supported}",
+                    status_code=HTTPStatus.BAD_REQUEST,
+                )
+
+            resp = handler(req)
+            headers = {**resp.headers, amounts.API_VERSION_HEADER: str(version)}
+            body = resp.get_body()
+            if (
+                amounts.amounts_as_strings(version)
+                and resp.mimetype == "application/json"
+                and body
+            ):
+                body = json.dumps(
+                    amounts.stringify_amounts(json.loads(body), fields)
+                ).encode("utf-8")
+            return func.HttpResponse(
+                body,
+                status_code=resp.status_code,
+                headers=headers,
+                mimetype=resp.mimetype,
+            )
+
+        return wrapper
+
+    return decorator
+
+
+def household(sandboxed: bool = True) -> Callable[[HttpHandler], HttpHandler]:
+    """
+    Handles a request in the household its x-household header names: the live one
+    by default, or the sandbox's demo data when the deployment has one. The
+    household is echoed on the response. Routes that reach storage beyond the
+    tables (blobs, queues, secrets) aren't sandboxed, and turn sandbox requests
+    away rather than touch live data.
+    """
+
+    def decorator(handler: HttpHandler) -> HttpHandler:
+        @functools.wraps(handler)
+        def wrapper(req: func.HttpRequest) -> func.HttpResponse:
+            name = (req.headers.get(sandbox.HOUSEHOLD_HEADER) or sandbox.LIVE).lower()
+            if name not in (sandbox.LIVE, sandbox.SANDBOX):
+                return func.HttpResponse(
+                    f"Unknown household, expected {sandbox.LIVE} or {sandbox.SANDBOX}",
+                    status_code=HTTPStatus.BAD_REQUEST,
+                )
+            if name == sandbox.SANDBOX and not sandbox.enabled():
+                return func.HttpResponse(
+                    "The sandbox household is not enabled",
+                    status_code=HTTPStatus.NOT_FOUND,
+                )
+            if name == sandbox.SANDBOX and not sandboxed:
+                return func.HttpResponse(
+                    "Not available in the sandbox household",
+                    status_code=HTTPStatus.BAD_REQUEST,
+                )
+
+            with sandbox.use(name):
+                resp = handler(req)
+            if isinstance(resp, func.HttpResponse):
+                resp.headers[sandbox.HOUSEHOLD_HEADER] = name
+            return resp
+
+        return wrapper
+
+    return decorator
+
+
+def http_streaming(
+    handler: Callable[[func.HttpRequest], Any],
+) -> Callable[[func.HttpRequest], Any]:
+    """
+    Wraps a HTTP handler that returns either a response or a stream (a dataclass
+    with an iterator of chunks, e.g. an ExportStream) the way http_logging,
+    household and http_recovery wrap the others. The stream is read after the
+    handler returns, so its chunks are read in the household, table routes and
+    storage operation the request started with, and the request is logged and
+    counted once it ends.
+
+    The status has been sent by the time a chunk fails, so the failure is logged
+    with a correlation ID, counted as a server error and re-raised, aborting the
+    response rather than ending it as though the download were complete.
+    """
+
+    # Opens the stream, keeping the context to read it in
+    @functools.wraps(handler)
+    def opened(req: func.HttpRequest) -> Any:
+        with table_routes_scope(), table_metrics.storage_operation(handler.__name__):
+            result = handler(req)
+            return result, contextvars.copy_context()
+
+    def read(
+        req: func.HttpRequest,
+        chunks: Iterator[Any],
+        context: contextvars.Context,
+        start: float,
+    ) -> Iterator[Any]:
+        status_code = HTTPStatus.OK
+        try:
+            while True:
+                try:
+                    chunk = context.run(next, chunks)
+                except StopIteration:
+                    return
+                yield chunk
+        except Exception:
+            status_code = HTTPStatus.INTERNAL_SERVER_ERROR
+            logger.exception(
+                "Unhandled error streaming %s [correlation_id=%s]",
+                handler.__name__,
+                req.headers.get(CORRELATION_HEADER) or uuid.uuid4().hex,
+            )
+            _record_failure(handler.__name__)
+            raise
+        finally:
+            _log_request(req, status_code, start)
+
+    @functools.wraps(handler)
+    def wrapper(req: func.HttpRequest) -> Any:
+        start = time.perf_counter()
+        result = household()(http_recovery(opened))(req)
+        if isinstance(result, func.HttpResponse):
+            _log_request(req, result.status_code, start)
+            return result
+        result, context = result
+        return dataclasses.replace(
+            result, chunks=read(req, iter(result.chunks), context, start)
+        )
+
+    return wrapper
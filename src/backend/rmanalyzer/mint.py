@@ -0,0 +1,136 @@
+"""
+Parsing of Mint's transaction export (transactions.csv) into transactions.
+Mint writes every amount as a positive number with a Transaction Type of debit
+or credit, names accounts rather than numbering them, and uses its own categories.
+"""
+
+import csv
+import re
+from decimal import Decimal, InvalidOperation
+from typing import Dict, List, Optional, Tuple
+
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.utils import parse_date
+
+__all__ = ["MINT_HEADERS", "looks_like_mint", "get_mint_transactions"]
+
+# Columns only Mint's export has, which tell it apart from a plain CSV upload
+MINT_HEADERS = ("Original Description", "Transaction Type", "Account Name")
+
+# Mint categories with a counterpart here. The rest are Other.
+MINT_CATEGORIES = {
+    "Alcohol & Bars": Category.DINING,
+    "Coffee Shops": Category.DINING,
+    "Fast Food": Category.DINING,
+    "Food & Dining": Category.DINING,
+    "Restaurants": Category.DINING,
+    "Groceries": Category.GROCERIES,
+    "Pet Food & Supplies": Category.PETS,
+    "Pet Grooming": Category.PETS,
+    "Pets": Category.PETS,
+    "Veterinary": Category.PETS,
+    "Bills & Utilities": Category.BILLS,
+    "Home Phone": Category.BILLS,
+    "Internet": Category.BILLS,
+    "Mobile Phone": Category.BILLS,
+    "Television": Category.BILLS,
+    "Utilities": Category.BILLS,
+    "Air Travel": Category.TRAVEL,
+    "Hotel": Category.TRAVEL,
+    "Rental Car & Taxi": Category.TRAVEL,
+    "Travel": Category.TRAVEL,
+    "Vacation": Category.TRAVEL,
+}
+
+# Mint categories for money moved between the household's own accounts
+TRANSFER_CATEGORIES = ("Credit Card Payment", "Transfer")
+
+
+def _header(content: str) -> List[str]:
+    """The column names on the first non-blank line."""
+    for line in content.lstrip("\ufeff").splitlines():
+        if line.strip():
+            return [name.strip() for name in next(csv.reader([line]))]
+    return []
+
+
+def looks_like_mint(content: str) -> bool:
+    """Whether CSV content is a Mint export, by its header row."""
+    return set(MINT_HEADERS) <= set(_header(content))
+
+
+def _to_transaction(
+    row: Dict[str, str],
+) -> Tuple[Optional[Transaction], Optional[str]]:
+    """
+    Parses a Mint export row into a Transaction.
+    Returns (Transaction, None) if successful, or (None, error_message) if not.
+    """
+    clean_row = {k.strip(): (v or "").strip() for k, v in row.items() if k}
+
+    try:
+        transaction_date = parse_date(clean_row.get("Date", ""))
+    except ValueError as e:
+        return None, str(e)
+
+    name = clean_row.get("Description") or clean_row.get("Original Description")
+    if not name:
+        return None, "Missing 'Description' field"
+
+    # Mint names accounts; the last four digits of the name are the account number
+    digits = re.sub(r"\D", "", clean_row.get("Account Name", ""))
+    if not digits:
+        return (
+            None,
+            f"No account number in 'Account Name': {clean_row.get('Account Name')}",
+        )
+
+    try:
+        amount = Decimal(clean_row.get("Amount", "").replace(",", ""))
+    except InvalidOperation:
+        return None, f"Invalid or missing 'Amount': {clean_row.get('Amount')}"
+    transaction_type = clean_row.get("Transaction Type", "").lower()
+    if transaction_type not in ("debit", "credit"):
+        return (
+            None,
+            f"Invalid 'Transaction Type': {clean_row.get('Transaction Type')}",
+        )
+
+    category = clean_row.get("Category", "")
+    return (
+        Transaction(
+            transaction_date,
+            name,
+            int(digits[-4:]),
+            # Debits are spending, which is positive here; credits are refunds
+            amount if transaction_type == "debit" else -amount,
+            MINT_CATEGORIES.get(category, Category.OTHER),
+            (
+                IgnoredFrom.EVERYTHING
+                if category in TRANSFER_CATEGORIES
+                else IgnoredFrom.NOTHING
+            ),
+        ),
+        None,
+    )
+
+
+def get_mint_transactions(content: str) -> Tuple[List[Transaction], List[str]]:
+    """
+    Parses a Mint transaction export into a list of Transactions.
+    Transfers and card payments between accounts are ignored from everything.
+    Returns (List[Transaction], List[str]) where the second list contains error messages.
+    """
+    lines = [line for line in content.lstrip("\ufeff").splitlines() if line.strip()]
+    rows = csv.DictReader(lines)
+    transactions = []
+    errors = []
+
+    for i, row in enumerate(rows, start=1):
+        transaction, error = _to_transaction(row)
+        if transaction:
+            transactions.append(transaction)
+        else:
+            errors.append(f"Row {i}: {error}")
+
+    return transactions, errors
@@ -2,23 +2,30 @@
 Data models for transactions, people, and groups.
 """
 
+import dataclasses
 from dataclasses import dataclass, field
 from datetime import date
 from decimal import Decimal
 from enum import Enum
-from typing import List, Optional
+from typing import Dict, Iterable, List, Optional, Sequence, Tuple, Union
 
 __all__ = [
     "Category",
+    "AddedCategory",
+    "AnyCategory",
+    "parse_category",
+    "all_categories",
+    "category_names",
     "IgnoredFrom",
     "Transaction",
     "Person",
     "Group",
+    "split_by_weight",
 ]
 
 
 class Category(Enum):
-    """Spending categories for transactions."""
+    """Built-in spending categories for transactions."""
 
     DINING = "Dining & Drinks"
     GROCERIES = "Groceries"
@@ -29,6 +36,67 @@ class Category(Enum):
     TRAVEL = "Travel & Vacation"
     OTHER = "Other"
 
+    @classmethod
+    def is_valid(cls, value: object, added: Iterable[str] = ()) -> bool:
+        """Whether a name is exactly a built-in category or one of those added."""
+        if not isinstance(value, str):
+            return False
+        return value in cls._value2member_map_ or value in set(added)
+
+    @property
+    def built_in(self) -> bool:
+        """Whether the category is one of the built-in ones (always)."""
+        return True
+
+
+@dataclass(frozen=True)
+class AddedCategory:
+    """
+    A category a household added to the built-in ones. Made only by
+    parse_category, from a name in the household's categories table.
+    """
+
+    value: str
+
+    @property
+    def name(self) -> str:
+        """The category's name, which is also its value."""
+        return self.value
+
+    @property
+    def built_in(self) -> bool:
+        """Whether the category is one of the built-in ones (never)."""
+        return False
+
+
+# A built-in category or one a household added
+AnyCategory = Union[Category, AddedCategory]
+
+
+def parse_category(name: object, added: Iterable[str] = ()) -> AnyCategory:
+    """
+    The category with a name: the built-in one, or the household's added one
+    when the name is among added. Raises ValueError for any other name.
+    """
+    if isinstance(name, str) and name in Category._value2member_map_:
+        return Category(name)
+    if isinstance(name, str) and name in set(added):
+        return AddedCategory(name)
+    raise ValueError(f"{name!r} is not a category")
+
+
+def all_categories(added: Iterable[str] = ()) -> List[AnyCategory]:
+    """The built-in categories, then the added ones, e.g. a household's."""
+    built_in: List[AnyCategory] = list(Category)
+    names = [c.value for c in built_in]
+    extra = [n for n in dict.fromkeys(added) if n not in names]
+    return built_in + [AddedCategory(n) for n in extra]
+
+
+def category_names(added: Iterable[str] = ()) -> List[str]:
+    """The name of every category, built-in or added; see all_categories."""
+    return [c.value for c in all_categories(added)]
+
 
 class IgnoredFrom(Enum):
     """Flags for ignoring transactions from certain calculations."""
@@ -40,29 +108,85 @@ class IgnoredFrom(Enum):
 
 @dataclass(frozen=True)
 class Transaction:
-    """A single financial transaction."""
+    """
+    A single financial transaction.
+    owner is the email of the person it is really for, overriding account ownership.
+    currency is the ISO 4217 code of a transaction made in another currency, or
+    None for the home currency. Once converted, amount is in the home currency
+    and original_amount is what was charged in the other currency; until then,
+    amount is what was charged and original_amount is None.
+    """
 
     date: date
     name: str
     account_number: int
     amount: Decimal
-    category: Category
+    category: AnyCategory
     ignore: IgnoredFrom
+    owner: Optional[str] = None
+    currency: Optional[str] = None
+    original_amount: Optional[Decimal] = None
+
+    def is_ignored(self, scope: IgnoredFrom) -> bool:
+        """
+        Whether the transaction is left out of calculations in a scope. Rows
+        ignored from everything are left out of all of them; rows ignored from
+        the budget only out of budget ones (scope IgnoredFrom.BUDGET).
+        """
+        if self.ignore == IgnoredFrom.EVERYTHING:
+            return True
+        return self.ignore == IgnoredFrom.BUDGET and scope == IgnoredFrom.BUDGET
 
 
 @dataclass
 class Person:
-    """A person with accounts and transactions."""
+    """
+    A person with accounts and transactions.
+    account_weights holds the person's share of joint accounts they hold with
+    others, e.g. 0.6 of a card used 60/40.
+    split_share is the person's share of shared spend, e.g. 0.6 to pay 60/40, and
+    category_shares overrides it for some categories. Unset means an even split.
+    """
 
     name: str
     email: str
     account_numbers: List[int]
     transactions: List[Transaction] = field(default_factory=list)
+    account_weights: Dict[int, Decimal] = field(default_factory=dict)
+    split_share: Optional[Decimal] = None
+    category_shares: Dict[AnyCategory, Decimal] = field(default_factory=dict)
 
     @classmethod
-    def from_config(cls, config: dict) -> "Person":
-        """Create a Person instance from a configuration dictionary."""
-        return cls(config["Name"], config["Email"], config["Accounts"], [])
+    def from_config(cls, config: dict, added: Iterable[str] = ()) -> "Person":
+        """
+        Create a Person instance from a configuration dictionary. added is the
+        household's added categories, which category shares may be for.
+        """
+        weights = {
+            int(account): Decimal(str(weight))
+            for account, weight in config.get("AccountWeights", {}).items()
+        }
+        split_share = config.get("SplitShare")
+        return cls(
+            config["Name"],
+            config["Email"],
+            config["Accounts"],
+            [],
+            weights,
+            Decimal(str(split_share)) if split_share is not None else None,
+            {
+                parse_category(category, added): Decimal(str(share))
+                for category, share in (config.get("CategoryShares") or {}).items()
+            },
+        )
+
+    def share_of(self, category: AnyCategory) -> Optional[Decimal]:
+        """The person's share of a category's shared spend, if one was set."""
+        return self.category_shares.get(category, self.split_share)
+
+    def account_weight(self, account_number: int) -> Decimal:
+        """The person's weight on an account, 1 unless one was set."""
+        return self.account_weights.get(account_number, Decimal("1"))
 
     def add_transaction(self, transaction: Transaction) -> None:
         """Add a transaction to the person's list."""
@@ -80,34 +204,87 @@ class Person:
             return None
         return max(t.date for t in self.transactions)
 
-    def get_expenses(self, category: Optional[Category] = None) -> Decimal:
-        """Calculate total expenses, optionally filtered by category."""
-        if not self.transactions:
-            return Decimal("0.00")
+    def get_expenses(
+        self,
+        category: Optional[AnyCategory] = None,
+        exclude: Sequence[AnyCategory] = (),
+        scope: IgnoredFrom = IgnoredFrom.BUDGET,
+    ) -> Decimal:
+        """
+        Calculate total expenses, optionally filtered by category.
+        Categories in exclude are left out of the total, as are transactions
+        ignored in the scope (see Transaction.is_ignored).
+        """
+        counted = [t for t in self.transactions if not t.is_ignored(scope)]
         if not category:
-            return sum((t.amount for t in self.transactions), start=Decimal("0.00"))
+            return sum(
+                (t.amount for t in counted if t.category not in exclude),
+                start=Decimal("0.00"),
+            )
         return sum(
-            (t.amount for t in self.transactions if t.category == category),
+            (t.amount for t in counted if t.category == category),
             start=Decimal("0.00"),
         )
 
 
+def split_by_weight(
+    amount: Decimal, holders: List[Person], account_number: int
+) -> List[Tuple[Person, Decimal]]:
+    """
+    Splits an amount on an account between its holders in proportion to their
+    weights. Shares are rounded to cents, with the rounding left on the last
+    holder's share so they add up to the amount.
+    """
+    if len(holders) == 1:
+        return [(holders[0], amount)]
+    weights = [p.account_weight(account_number) for p in holders]
+    total = sum(weights, start=Decimal("0"))
+    shares = [(amount * w / total).quantize(Decimal("0.01")) for w in weights[:-1]]
+    shares.append(amount - sum(shares, start=Decimal("0")))
+    return list(zip(holders, shares))
+
+
 @dataclass
 class Group:
-    """A group of people for expense analysis."""
+    """
+    A group of people for expense analysis.
+    Categories in debt_excluded are personal and never count toward shared debt.
+    Transactions on accounts that belong to no member are kept in unassigned.
+    """
 
     members: List[Person]
+    debt_excluded: List[AnyCategory] = field(default_factory=list)
+    unassigned: List[Transaction] = field(default_factory=list)
 
     def add_transactions(self, transactions: List[Transaction]) -> None:
-        """Add a list of transactions to the appropriate members."""
+        """
+        Add a list of transactions to the appropriate members.
+        A transaction's owner, when set to a member's email, takes precedence over
+        account ownership. A transaction on a joint account is split between its
+        holders by their account weights.
+        """
+        by_email = {p.email.lower(): p for p in self.members}
         for t in transactions:
-            for p in self.members:
-                if (
-                    t.account_number in p.account_numbers
-                    and t.ignore == IgnoredFrom.NOTHING
-                    and t.category != Category.OTHER
-                ):
-                    p.add_transaction(t)
+            if t.is_ignored(IgnoredFrom.BUDGET) or t.category == Category.OTHER:
+                continue
+            owner = by_email.get(t.owner.lower()) if t.owner else None
+            if owner:
+                owner.add_transaction(t)
+                continue
+            matched = [p for p in self.members if t.account_number in p.account_numbers]
+            if not matched:
+                self.unassigned.append(t)
+                continue
+            for p, share in split_by_weight(t.amount, matched, t.account_number):
+                p.add_transaction(
+                    t if share == t.amount else dataclasses.replace(t, amount=share)
+                )
+
+    def categories(self) -> List[AnyCategory]:
+        """The built-in categories, then the added ones members spent in."""
+        return all_categories(
+            t.category.value for p in self.members for t in p.transactions
+        )
 
     def get_oldest_transaction(self) -> date:
         """Return the date of the oldest transaction in the group."""
@@ -132,7 +309,7 @@ class Group:
         return max(dates)
 
     def get_expenses_difference(
-        self, p1: Person, p2: Person, category: Optional[Category] = None
+        self, p1: Person, p2: Person, category: Optional[AnyCategory] = None
     ) -> Decimal:
         """Calculate the difference in expenses between two people."""
         missing = [p for p in [p1, p2] if p not in self.members]
@@ -144,14 +321,51 @@ class Group:
         """Calculate the total expenses of the group."""
         return sum((p.get_expenses() for p in self.members), start=Decimal("0.00"))
 
+    @staticmethod
+    def split_share(p1: Person, p2: Person, category: AnyCategory) -> Decimal:
+        """
+        p1's share of a category's shared spend with p2: p1's own share if set,
+        else what p2's leaves, else half. A category share beats an overall one.
+        """
+        if category in p1.category_shares or category not in p2.category_shares:
+            share = p1.share_of(category)
+            if share is not None:
+                return share
+        other = p2.share_of(category)
+        return Decimal("1") - other if other is not None else Decimal("0.5")
+
+    def get_category_debt(
+        self, p1: Person, p2: Person, category: AnyCategory
+    ) -> Decimal:
+        """
+        What a category adds to p1's debt to p2: p1's share of the two's spend
+        in it less what p1 spent. Nothing for a category in debt_excluded.
+        """
+        if category in self.debt_excluded:
+            return Decimal("0.00")
+        spent = p1.get_expenses(category)
+        share = self.split_share(p1, p2, category)
+        return share * (spent + p2.get_expenses(category)) - spent
+
     def get_debt(
-        self, p1: Person, p2: Person, p1_scale_factor: Decimal = Decimal("0.5")
+        self, p1: Person, p2: Person, p1_scale_factor: Optional[Decimal] = None
     ) -> Decimal:
         """
-        Calculate how much p1 owes p2 based on a scale factor.
+        Calculate how much p1 owes p2, splitting each category's shared spend by
+        the two's split shares, or by p1_scale_factor across the board if given.
         Returns a positive value if p1 owes p2, and a negative value if p2 owes p1.
+        Expenses in debt_excluded categories are ignored.
         """
         missing = [p for p in [p1, p2] if p not in self.members]
         if missing:
             raise ValueError("People args missing from group")
-        return p1_scale_factor * self.get_expenses() - p1.get_expenses()
+        if p1_scale_factor is None:
+            return sum(
+                (self.get_category_debt(p1, p2, c) for c in self.categories()),
+                start=Decimal("0.00"),
+            )
+        shared = sum(
+            (p.get_expenses(exclude=self.debt_excluded) for p in self.members),
+            start=Decimal("0.00"),
+        )
+        return p1_scale_factor * shared - p1.get_expenses(exclude=self.debt_excluded)
@@ -0,0 +1,108 @@
+"""
+Parsing of OFX/QFX bank statements into transactions.
+Handles both OFX 1.x (SGML, leaf elements unclosed) and OFX 2.x (XML).
+"""
+
+import re
+from datetime import date
+from decimal import Decimal, InvalidOperation
+from typing import List, Optional, Tuple
+
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+
+__all__ = ["OFX_EXTENSIONS", "is_ofx", "get_ofx_transactions"]
+
+# Upload file extensions parsed as OFX rather than CSV
+OFX_EXTENSIONS = (".ofx", ".qfx")
+
+_STATEMENT = re.compile(r"<(STMTRS|CCSTMTRS)>(.*?)</\1>", re.IGNORECASE | re.DOTALL)
+_TRANSACTION = re.compile(r"<STMTTRN>(.*?)</STMTTRN>", re.IGNORECASE | re.DOTALL)
+
+
+def is_ofx(file_name: str) -> bool:
+    """Whether an uploaded file should be parsed as OFX, by its extension."""
+    return file_name.lower().endswith(OFX_EXTENSIONS)
+
+
+def _field(block: str, tag: str) -> Optional[str]:
+    """Reads a leaf element's value, whether or not the element is closed."""
+    match = re.search(rf"<{tag}>([^<\r\n]*)", block, re.IGNORECASE)
+    if not match or not match.group(1).strip():
+        return None
+    return match.group(1).strip()
+
+
+def _parse_date(value: str) -> date:
+    """OFX dates start with YYYYMMDD, optionally followed by a time and zone."""
+    return date(int(value[:4]), int(value[4:6]), int(value[6:8]))
+
+
+def _to_transaction(
+    block: str, account_number: int
+) -> Tuple[Optional[Transaction], Optional[str]]:
+    """
+    Parses an STMTTRN block into a Transaction.
+    Returns (Transaction, None) if successful, or (None, error_message) if not.
+    """
+    posted = _field(block, "DTPOSTED")
+    if not posted:
+        return None, "Missing 'DTPOSTED' field"
+    try:
+        transaction_date = _parse_date(posted)
+    except ValueError:
+        return None, f"Invalid 'DTPOSTED': {posted}"
+
+    name = _field(block, "NAME") or _field(block, "MEMO")
+    if not name:
+        return None, "Missing 'NAME' field"
+
+    amount = _field(block, "TRNAMT")
+    try:
+        # OFX amounts are negative for debits; spending is positive here
+        transaction_amount = -Decimal(amount or "")
+    except InvalidOperation:
+        return None, f"Invalid or missing 'TRNAMT': {amount}"
+
+    return (
+        Transaction(
+            transaction_date,
+            name,
+            account_number,
+            transaction_amount,
+            # OFX carries no category; rules or manual edits assign one
+            Category.OTHER,
+            IgnoredFrom.NOTHING,
+        ),
+        None,
+    )
+
+
+def get_ofx_transactions(content: str) -> Tuple[List[Transaction], List[str]]:
+    """
+    Parses OFX/QFX content into a list of Transactions.
+    The account number is the last four digits of each statement's ACCTID, matching
+    the masked account numbers in CSV exports.
+    Returns (List[Transaction], List[str]) where the second list contains error messages.
+    """
+    transactions = []
+    errors = []
+
+    statements = _STATEMENT.findall(content)
+    if not statements:
+        return [], ["No bank or credit card statement found"]
+
+    for s, (_, statement) in enumerate(statements, start=1):
+        digits = re.sub(r"\D", "", _field(statement, "ACCTID") or "")
+        if not digits:
+            errors.append(f"Statement {s}: Invalid or missing 'ACCTID'")
+            continue
+        account_number = int(digits[-4:])
+
+        for i, block in enumerate(_TRANSACTION.findall(statement), start=1):
+            transaction, error = _to_transaction(block, account_number)
+            if transaction:
+                transactions.append(transaction)
+            else:
+                errors.append(f"Statement {s}, transaction {i}: {error}")
+
+    return transactions, errors
@@ -0,0 +1,81 @@
+"""
+Operational alerts for deployments without external monitoring: the server
+error rate, the processing queues' backlog and oldest message, and failing
+nightly job runs, each checked against a threshold from the settings.
+"""
+
+from typing import Any, Dict, List, Optional
+
+__all__ = [
+    "OPS_METRICS",
+    "MIN_REQUESTS",
+    "CHECK_MINUTES",
+    "error_rate",
+    "queue_values",
+    "consecutive_failures",
+    "firing",
+]
+
+# Each metric with the setting holding its threshold
+OPS_METRICS = {
+    "errorRate": "ErrorRateAlertThreshold",
+    "queueBacklog": "QueueBacklogAlertThreshold",
+    "queueAgeMinutes": "QueueAgeAlertMinutes",
+    "jobFailures": "JobFailureAlertThreshold",
+}
+
+# Minutes between checks (the check_ops_alerts schedule), the interval each
+# error rate covers
+CHECK_MINUTES = 15
+
+# Fewest requests in a check's interval for its error rate to count
+MIN_REQUESTS = 20
+
+
+def error_rate(counts: Dict[str, int]) -> Optional[float]:
+    """
+    The share of requests answered with a server error in a check's interval,
+    counted across every worker, or None when there were too few to tell.
+    """
+    requests = counts.get("requests", 0)
+    if requests < MIN_REQUESTS:
+        return None
+    return counts.get("serverErrors", 0) / requests
+
+
+def queue_values(queues: List[Dict[str, Any]]) -> Dict[str, Optional[float]]:
+    """
+    The longest backlog and the oldest message's age in minutes across the
+    processing queues. Poison queues are left out: their messages have stopped
+    being retried until someone does.
+    """
+    processing = [q for q in queues if not q["poison"]]
+    ages = [a for a in (q["oldestAgeSeconds"] for q in processing) if a is not None]
+    return {
+        "queueBacklog": max((q["messages"] for q in processing), default=0),
+        "queueAgeMinutes": max(ages) // 60 if ages else None,
+    }
+
+
+def consecutive_failures(runs: List[Dict[str, Any]]) -> int:
+    """How many of a job's runs, newest first, failed in a row."""
+    count = 0
+    for run in runs:
+        if run["status"] != "failed":
+            break
+        count += 1
+    return count
+
+
+def firing(
+    values: Dict[str, Optional[float]], thresholds: Dict[str, float]
+) -> List[Dict[str, Any]]:
+    """
+    The metrics at or over their threshold, with their value and threshold. A
+    threshold of 0 turns a metric's alert off, and a None value is skipped.
+    """
+    return [
+        {"metric": metric, "value": value, "threshold": thresholds[metric]}
+        for metric, value in values.items()
+        if value is not None and thresholds[metric] > 0 and value >= thresholds[metric]
+    ]
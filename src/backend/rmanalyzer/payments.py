@@ -0,0 +1,69 @@
+"""
+Card payment due dates: when the next payment is due and whether one was made.
+"""
+
+import calendar
+from datetime import date, timedelta
+from decimal import Decimal
+from typing import List, Optional
+
+from rmanalyzer.models import Transaction
+
+__all__ = [
+    "PAYMENT_CYCLE_DAYS",
+    "MAX_REMINDER_LEADS",
+    "next_due_date",
+    "payment_made",
+    "leads_due",
+]
+
+# How far before a due date a payment counts towards it
+PAYMENT_CYCLE_DAYS = 25
+
+# Most reminders a card can be sent before each due date
+MAX_REMINDER_LEADS = 5
+
+
+def _due_in_month(due_day: int, year: int, month: int) -> date:
+    """The due date in a month, moved to its last day when the month is short."""
+    return date(year, month, min(due_day, calendar.monthrange(year, month)[1]))
+
+
+def next_due_date(due_day: int, today: date) -> date:
+    """The next due date on or after today."""
+    due = _due_in_month(due_day, today.year, today.month)
+    if due >= today:
+        return due
+    if today.month == 12:
+        return _due_in_month(due_day, today.year + 1, 1)
+    return _due_in_month(due_day, today.year, today.month + 1)
+
+
+def payment_made(
+    transactions: List[Transaction], mask: Optional[str], due: date
+) -> bool:
+    """
+    Whether a credit (a payment) posted to the card whose account number ends in
+    the mask within PAYMENT_CYCLE_DAYS before the due date.
+    """
+    if not mask:
+        return False
+    start = due - timedelta(days=PAYMENT_CYCLE_DAYS)
+    return any(
+        t.account_number == int(mask)
+        and t.amount < Decimal("0")
+        and start <= t.date <= due
+        for t in transactions
+    )
+
+
+def leads_due(leads: List[int], days_left: int, sent: List[int]) -> List[int]:
+    """
+    The lead days (days before the due date a reminder goes out) that have been
+    reached and not sent yet, longest first. If runs were missed several can be
+    due at once, and one reminder covers them all.
+    """
+    return sorted(
+        {lead for lead in leads if days_left <= lead and lead not in sent},
+        reverse=True,
+    )
@@ -0,0 +1,99 @@
+"""
+Rendering of plain-text reports as PDF documents: one standard font, lines laid
+out top to bottom across as many Letter pages as they need. Enough for a printed
+report without a PDF library.
+"""
+
+import textwrap
+from typing import List
+
+__all__ = ["text_pdf"]
+
+# US Letter, in points
+PAGE_WIDTH = 612
+PAGE_HEIGHT = 792
+MARGIN = 54
+
+FONT_SIZE = 10
+TITLE_SIZE = 14
+LINE_HEIGHT = 14
+
+# Characters of Courier at FONT_SIZE that fit between the margins
+LINE_WIDTH = 84
+
+LINES_PER_PAGE = (PAGE_HEIGHT - 2 * MARGIN) // LINE_HEIGHT - 2
+
+
+def _escape(text: str) -> str:
+    """A PDF string literal's contents, in the font's Latin-1 encoding."""
+    text = text.encode("latin-1", "replace").decode("latin-1")
+    return text.replace("\\", "\\\\").replace("(", "\\(").replace(")", "\\)")
+
+
+def _page_stream(title: str, lines: List[str]) -> bytes:
+    """Draws the title and a page's lines."""
+    top = PAGE_HEIGHT - MARGIN
+    commands = [
+        f"BT /F2 {TITLE_SIZE} Tf {MARGIN} {top} Td ({_escape(title)}) Tj ET",
+        f"BT /F1 {FONT_SIZE} Tf {LINE_HEIGHT} TL {MARGIN} {top - 2 * LINE_HEIGHT} Td",
+        *(f"({_escape(line)}) '" for line in lines),
+        "ET",
+    ]
+    return "\n".join(commands).encode("latin-1")
+
+
+def text_pdf(title: str, lines: List[str]) -> bytes:
+    """
+    Renders lines of text as a PDF, each page headed by the title. Long lines are
+    wrapped; blank lines are kept as spacing.
+    """
+    wrapped = [
+        part
+        for line in lines
+        for part in (textwrap.wrap(line, LINE_WIDTH, subsequent_indent="  ") or [""])
+    ]
+    pages = [
+        wrapped[i : i + LINES_PER_PAGE]
+        for i in range(0, max(len(wrapped), 1), LINES_PER_PAGE)
+    ]
+
+    # Objects 1-4 are the catalog, page tree and fonts; each page is then a page
+    # object followed by its content stream
+    page_ids = [5 + 2 * i for i in range(len(pages))]
+    objects = [
+        b"<< /Type /Catalog /Pages 2 0 R >>",
+        (
+            f"<< /Type /Pages /Kids [{' '.join(f'{p} 0 R' for p in page_ids)}] "
+            f"/Count {len(pages)} >>"
+        ).encode("latin-1"),
+        b"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+        b"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold >>",
+    ]
+    for page_id, page in zip(page_ids, pages):
+        stream = _page_stream(title, page)
+        objects.append(
+            (
+                f"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 {PAGE_WIDTH} "
+                f"{PAGE_HEIGHT}] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> "
+                f"/Contents {page_id + 1} 0 R >>"
+            ).encode("latin-1")
+        )
+        objects.append(
+            f"<< /Length {len(stream)} >>\nstream\n".encode("latin-1")
+            + stream
+            + b"\nendstream"
+        )
+
+    out = bytearray(b"%PDF-1.4\n")
+    offsets = []
+    for number, body in enumerate(objects, start=1):
+        offsets.append(len(out))
+        out += f"{number} 0 obj\n".encode("latin-1") + body + b"\nendobj\n"
+    xref = len(out)
+    out += f"xref\n0 {len(objects) + 1}\n0000000000 65535 f \n".encode("latin-1")
+    out += "".join(f"{offset:010d} 00000 n \n" for offset in offsets).encode("latin-1")
+    out += (
+        f"trailer\n<< /Size {len(objects) + 1} /Root 1 0 R >>\n"
+        f"startxref\n{xref}\n%%EOF\n"
+    ).encode("latin-1")
+    return bytes(out)
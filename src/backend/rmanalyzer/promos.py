@@ -0,0 +1,44 @@
+"""
+Promotional APRs: warning before a card's promo rate ends with a balance on it.
+"""
+
+from datetime import date
+from decimal import Decimal
+from typing import Any, Dict, List
+
+__all__ = ["monthly_interest", "expiring_promos"]
+
+
+def monthly_interest(balance: Decimal, apr: Decimal) -> Decimal:
+    """Interest a month at the APR (a percentage) adds to the balance, to the cent."""
+    if balance <= 0:
+        return Decimal("0.00")
+    return (balance * apr / 100 / 12).quantize(Decimal("0.01"))
+
+
+def expiring_promos(
+    accounts: List[Dict[str, Any]], today: date, days: int
+) -> List[Dict[str, Any]]:
+    """
+    Accounts whose promo APR ends within `days` of today while carrying a balance,
+    soonest first. Each gets `daysLeft` and `projectedInterest`: the monthly
+    interest the current balance accrues at the regular APR once the promo ends.
+    """
+    expiring = []
+    for account in accounts:
+        expiry = account.get("promoExpiry")
+        balance = Decimal(str(account.get("balance") or 0))
+        if not expiry or balance <= 0:
+            continue
+        days_left = (date.fromisoformat(expiry) - today).days
+        if not 0 <= days_left <= days:
+            continue
+        apr = Decimal(str(account.get("apr") or 0))
+        expiring.append(
+            {
+                **account,
+                "daysLeft": days_left,
+                "projectedInterest": float(monthly_interest(balance, apr)),
+            }
+        )
+    return sorted(expiring, key=lambda a: a["promoExpiry"])
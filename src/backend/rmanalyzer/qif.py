@@ -0,0 +1,163 @@
+"""
+Parsing of QIF (Quicken Interchange Format) statements into transactions.
+Each line starts with a one-letter field code, records end with '^', and '!'
+lines start a section, e.g. '!Type:Bank' before a list of transactions.
+"""
+
+import re
+from datetime import date, datetime
+from decimal import Decimal, InvalidOperation
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from rmanalyzer.models import (
+    AnyCategory,
+    Category,
+    IgnoredFrom,
+    Transaction,
+    parse_category,
+)
+
+__all__ = ["QIF_EXTENSIONS", "is_qif", "looks_like_qif", "get_qif_transactions"]
+
+# Upload file extensions parsed as QIF
+QIF_EXTENSIONS = (".qif",)
+
+# Sections holding bank, cash and card transactions. Investment sections
+# (!Type:Invst) and lists such as !Type:Cat hold no spending and are skipped.
+TRANSACTION_SECTIONS = ("bank", "cash", "ccard", "oth a", "oth l")
+
+# Quicken writes US dates, with an apostrophe before two-digit years after 1999
+DATE_FORMATS = ["%m/%d/%Y", "%m/%d/%y", "%Y-%m-%d"]
+
+
+def is_qif(file_name: str) -> bool:
+    """Whether an uploaded file should be parsed as QIF, by its extension."""
+    return file_name.lower().endswith(QIF_EXTENSIONS)
+
+
+def looks_like_qif(content: str) -> bool:
+    """Whether content is QIF, which starts with a '!' section header."""
+    return content.lstrip("\ufeff \r\n").lower().startswith(
+        ("!type:", "!account", "!option:", "!clear:")
+    )
+
+
+def _parse_date(value: str) -> date:
+    """Parses a QIF date such as 01/15/2025, 1/15/25 or 1/15'25."""
+    normalized = re.sub(r"\s", "", value.replace("'", "/"))
+    for fmt in DATE_FORMATS:
+        try:
+            return datetime.strptime(normalized, fmt).date()
+        except ValueError:
+            continue
+    raise ValueError(f"Date '{value}' does not match any supported format.")
+
+
+def _account_number(name: str) -> Optional[int]:
+    """The last four digits of an account's name, as in masked CSV exports."""
+    digits = re.sub(r"\D", "", name)
+    return int(digits[-4:]) if digits else None
+
+
+def _records(content: str) -> List[Tuple[str, Dict[str, str]]]:
+    """Splits QIF content into (section, fields) records, in file order."""
+    records = []
+    section = ""
+    fields: Dict[str, str] = {}
+    for raw in content.lstrip("\ufeff").splitlines():
+        line = raw.strip()
+        if not line:
+            continue
+        if line.startswith("!"):
+            section = line[1:].lower()
+            fields = {}
+        elif line.startswith("^"):
+            records.append((section, fields))
+            fields = {}
+        else:
+            # Split lines (S, E, $) repeat; the record's total (T) is what's kept
+            fields.setdefault(line[0], line[1:].strip())
+    return records
+
+
+def _to_transaction(
+    fields: Dict[str, str], account_number: Optional[int], added: Sequence[str]
+) -> Tuple[Optional[Transaction], Optional[str]]:
+    """
+    Parses a transaction record into a Transaction. A category that isn't built
+    in or among those added is read as Other.
+    Returns (Transaction, None) if successful, or (None, error_message) if not.
+    """
+    if account_number is None:
+        return None, "No account number in the !Account name or file name"
+
+    if "D" not in fields:
+        return None, "Missing 'D' (date) field"
+    try:
+        transaction_date = _parse_date(fields["D"])
+    except ValueError as e:
+        return None, str(e)
+
+    name = fields.get("P") or fields.get("M")
+    if not name:
+        return None, "Missing 'P' (payee) field"
+
+    amount = fields.get("T") or fields.get("U") or ""
+    try:
+        # QIF amounts are negative for withdrawals; spending is positive here
+        transaction_amount = -Decimal(amount.replace(",", ""))
+    except InvalidOperation:
+        return None, f"Invalid or missing 'T' (amount): {amount}"
+
+    category: AnyCategory = Category.OTHER
+    if Category.is_valid(fields.get("L"), added):
+        category = parse_category(fields["L"], added)
+
+    return (
+        Transaction(
+            transaction_date,
+            name,
+            account_number,
+            transaction_amount,
+            category,
+            IgnoredFrom.NOTHING,
+        ),
+        None,
+    )
+
+
+def get_qif_transactions(
+    content: str, account_number: Optional[int] = None, added: Sequence[str] = ()
+) -> Tuple[List[Transaction], List[str]]:
+    """
+    Parses QIF content into a list of Transactions. added names the categories
+    the household added, which records may use as well as the built-in ones.
+    Transactions belong to the account named by the last !Account record before
+    them, by the last four digits of its name; files without one (most single
+    account downloads) use the account_number given.
+    Returns (List[Transaction], List[str]) where the second list contains error messages.
+    """
+    transactions = []
+    errors = []
+    current_account = account_number
+    i = 0
+    for section, fields in _records(content):
+        if section == "account":
+            named = _account_number(fields.get("N", ""))
+            current_account = account_number if named is None else named
+            continue
+        if not section.startswith("type:"):
+            continue
+        if section[len("type:") :] not in TRANSACTION_SECTIONS:
+            continue
+
+        i += 1
+        transaction, error = _to_transaction(fields, current_account, added)
+        if transaction:
+            transactions.append(transaction)
+        else:
+            errors.append(f"Transaction {i}: {error}")
+
+    if not transactions and not errors:
+        return [], ["No bank, cash or credit card transactions found"]
+    return transactions, errors
@@ -0,0 +1,56 @@
+"""
+Reconciling a card against its statement: the balance the stored transactions
+add up to since it was last reconciled, compared with the statement's.
+"""
+
+from datetime import date
+from decimal import Decimal
+from typing import Any, Dict, List, Optional
+
+from rmanalyzer.models import Transaction
+
+__all__ = ["card_transactions", "compare_balance"]
+
+
+def card_transactions(
+    transactions: List[Transaction], mask: str, since: date, until: date
+) -> List[Transaction]:
+    """
+    The transactions on the card whose account number ends in the mask after
+    `since` (the last reconcile) and up to `until` (the statement date).
+    """
+    return [
+        t
+        for t in transactions
+        if t.account_number == int(mask) and since < t.date <= until
+    ]
+
+
+def compare_balance(
+    balance: Decimal,
+    prior_balance: Optional[Decimal],
+    transactions: List[Transaction],
+) -> Dict[str, Any]:
+    """
+    Compares a statement balance with the last reconciled balance plus the
+    transactions since (charges add to a card balance, credits take away).
+    Without a prior balance there's nothing to compare, so the statement is the
+    baseline later reconciles are checked against.
+    """
+    if prior_balance is None:
+        return {
+            "status": "baseline",
+            "expectedBalance": None,
+            "discrepancy": None,
+            "transactions": len(transactions),
+        }
+    expected = prior_balance + sum(
+        (t.amount for t in transactions), start=Decimal("0.00")
+    )
+    discrepancy = (balance - expected).quantize(Decimal("0.01"))
+    return {
+        "status": "matched" if discrepancy == 0 else "discrepancy",
+        "expectedBalance": float(expected),
+        "discrepancy": float(discrepancy),
+        "transactions": len(transactions),
+    }
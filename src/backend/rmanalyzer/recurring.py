@@ -0,0 +1,133 @@
+"""
+Recurring charges (rent, insurance, subscriptions) detected from transaction history.
+"""
+
+from dataclasses import dataclass
+from datetime import date, timedelta
+from decimal import Decimal
+from statistics import median
+from typing import Dict, List, Optional
+
+from rmanalyzer.diff import merchant_name
+from rmanalyzer.models import IgnoredFrom, Transaction
+
+__all__ = [
+    "HISTORY_MONTHS",
+    "RecurringCharge",
+    "detect_recurring",
+    "missing_charges",
+    "price_increase",
+]
+
+# Complete months of history searched for recurring charges
+HISTORY_MONTHS = 12
+
+# Fewest charges needed before a merchant is treated as recurring
+MIN_OCCURRENCES = 3
+
+# Shortest cadence considered; more frequent charges are everyday spending
+MIN_CADENCE_DAYS = 7
+
+# How far an interval may stray from the usual cadence, as a fraction of it
+CADENCE_TOLERANCE = 0.25
+
+
+@dataclass(frozen=True)
+class RecurringCharge:
+    """A merchant charged at a regular cadence."""
+
+    merchant: str
+    cadence_days: int
+    last_date: date
+    average_amount: Decimal
+    occurrences: int
+    last_amount: Decimal
+    # Average of the charges before the last one
+    trailing_average: Decimal
+
+    @property
+    def expected_date(self) -> date:
+        """When the next charge is due."""
+        return self.last_date + timedelta(days=self.cadence_days)
+
+    def to_json(self) -> Dict[str, object]:
+        """Serializes the charge for the API and alerts."""
+        return {
+            "merchant": self.merchant,
+            "cadenceDays": self.cadence_days,
+            "lastDate": self.last_date.isoformat(),
+            "expectedDate": self.expected_date.isoformat(),
+            "averageAmount": float(self.average_amount),
+            "lastAmount": float(self.last_amount),
+            "occurrences": self.occurrences,
+        }
+
+
+def _average(amounts: List[Decimal]) -> Decimal:
+    """Mean of the amounts, to the cent."""
+    total = sum(amounts, start=Decimal("0.00"))
+    return (total / len(amounts)).quantize(Decimal("0.01"))
+
+
+def detect_recurring(transactions: List[Transaction]) -> List[RecurringCharge]:
+    """
+    Finds merchants charged at least MIN_OCCURRENCES times where every gap between
+    charges is within CADENCE_TOLERANCE of the median gap.
+    Credits and ignored transactions are left out.
+    """
+    by_merchant: Dict[str, List[Transaction]] = {}
+    for t in transactions:
+        if t.amount <= 0 or t.ignore != IgnoredFrom.NOTHING:
+            continue
+        by_merchant.setdefault(merchant_name(t.name), []).append(t)
+
+    charges = []
+    for merchant, charged in by_merchant.items():
+        # Charges on the same day (e.g. split payments) count as one
+        by_date: Dict[date, Decimal] = {}
+        for t in charged:
+            by_date[t.date] = by_date.get(t.date, Decimal("0.00")) + t.amount
+        dates = sorted(by_date)
+        if len(dates) < MIN_OCCURRENCES:
+            continue
+        gaps = [(b - a).days for a, b in zip(dates, dates[1:])]
+        cadence = median(gaps)
+        if cadence < MIN_CADENCE_DAYS:
+            continue
+        if any(abs(gap - cadence) > cadence * CADENCE_TOLERANCE for gap in gaps):
+            continue
+        amounts = [by_date[d] for d in dates]
+        charges.append(
+            RecurringCharge(
+                merchant,
+                round(cadence),
+                dates[-1],
+                _average(amounts),
+                len(amounts),
+                amounts[-1],
+                _average(amounts[:-1]),
+            )
+        )
+    return sorted(charges, key=lambda c: c.merchant)
+
+
+def missing_charges(
+    charges: List[RecurringCharge], today: date, grace_days: int
+) -> List[RecurringCharge]:
+    """Returns the charges overdue by more than their cadence plus grace_days."""
+    return [
+        c for c in charges if today > c.expected_date + timedelta(days=grace_days)
+    ]
+
+
+def price_increase(charge: RecurringCharge, threshold: Decimal) -> Optional[Decimal]:
+    """
+    Returns the fractional increase of the last charge over the trailing average
+    when it exceeds threshold (e.g. 0.1 for 10%), otherwise None.
+    """
+    if charge.trailing_average <= 0:
+        return None
+    change = charge.last_amount / charge.trailing_average - 1
+    if change <= threshold:
+        return None
+    return change.quantize(Decimal("0.0001"))
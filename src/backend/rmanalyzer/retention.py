@@ -0,0 +1,92 @@
+"""
+Data retention policies per data type.
+"""
+
+import os
+from dataclasses import dataclass
+from datetime import date, datetime, timedelta
+from typing import Dict, Optional
+
+__all__ = ["RetentionPolicy"]
+
+
+def _days_from_env(name: str, default: Optional[int]) -> Optional[int]:
+    """Reads a retention period in days. Empty or 0 means keep forever."""
+    raw = os.environ.get(name)
+    if raw is None:
+        return default
+    raw = raw.strip()
+    if not raw or raw == "0":
+        return None
+    days = int(raw)
+    if days < 0:
+        raise ValueError(f"{name} must not be negative.")
+    return days
+
+
+@dataclass(frozen=True)
+class RetentionPolicy:
+    """
+    Retention periods in days per data type. None means the data is kept forever.
+
+    Configured via RETENTION_UPLOADS_DAYS, RETENTION_TRANSACTIONS_DAYS,
+    RETENTION_SAVINGS_DAYS, RETENTION_ACTIVITY_DAYS, RETENTION_JOBS_DAYS,
+    RETENTION_METRICS_DAYS, RETENTION_BACKUPS_DAYS and RETENTION_REHEARSALS_DAYS.
+    Rehearsals are the shadow tables a restore rehearsal leaves for inspection.
+    """
+
+    uploads_days: Optional[int] = 90
+    transactions_days: Optional[int] = None
+    savings_days: Optional[int] = None
+    activity_days: Optional[int] = 365
+    jobs_days: Optional[int] = 90
+    metrics_days: Optional[int] = 31
+    backups_days: Optional[int] = 30
+    rehearsals_days: Optional[int] = 7
+
+    @classmethod
+    def from_env(cls) -> "RetentionPolicy":
+        """Create a policy from environment settings, falling back to defaults."""
+        return cls(
+            uploads_days=_days_from_env("RETENTION_UPLOADS_DAYS", 90),
+            transactions_days=_days_from_env("RETENTION_TRANSACTIONS_DAYS", None),
+            savings_days=_days_from_env("RETENTION_SAVINGS_DAYS", None),
+            activity_days=_days_from_env("RETENTION_ACTIVITY_DAYS", 365),
+            jobs_days=_days_from_env("RETENTION_JOBS_DAYS", 90),
+            metrics_days=_days_from_env("RETENTION_METRICS_DAYS", 31),
+            backups_days=_days_from_env("RETENTION_BACKUPS_DAYS", 30),
+            rehearsals_days=_days_from_env("RETENTION_REHEARSALS_DAYS", 7),
+        )
+
+    @staticmethod
+    def _cutoff(days: Optional[int], now: datetime) -> Optional[date]:
+        """Data dated strictly before the cutoff is expired."""
+        if days is None:
+            return None
+        return (now - timedelta(days=days)).date()
+
+    def cutoffs(self, now: datetime) -> Dict[str, Optional[date]]:
+        """Returns the cutoff date for each data type, or None if kept forever."""
+        return {
+            "uploads": self._cutoff(self.uploads_days, now),
+            "transactions": self._cutoff(self.transactions_days, now),
+            "savings": self._cutoff(self.savings_days, now),
+            "activity": self._cutoff(self.activity_days, now),
+            "jobs": self._cutoff(self.jobs_days, now),
+            "metrics": self._cutoff(self.metrics_days, now),
+            "backups": self._cutoff(self.backups_days, now),
+            "rehearsals": self._cutoff(self.rehearsals_days, now),
+        }
+
+    def to_dict(self) -> Dict[str, Optional[int]]:
+        """Serialize the policy for a JSON response."""
+        return {
+            "uploadsDays": self.uploads_days,
+            "transactionsDays": self.transactions_days,
+            "savingsDays": self.savings_days,
+            "activityDays": self.activity_days,
+            "jobsDays": self.jobs_days,
+            "metricsDays": self.metrics_days,
+            "backupsDays": self.backups_days,
+            "rehearsalsDays": self.rehearsals_days,
+        }
@@ -0,0 +1,128 @@
+"""
+Monthly review: the transactions worth talking through at a household money
+meeting, i.e. charges far above what a category usually costs and charges that
+look like they were imported twice.
+"""
+
+from collections import defaultdict
+from decimal import Decimal
+from statistics import median
+from typing import Dict, List, Tuple
+
+from rmanalyzer.diff import merchant_name
+from rmanalyzer.models import IgnoredFrom, Transaction
+from rmanalyzer.utils import to_currency
+
+__all__ = ["unusual_transactions", "possible_duplicates", "packet_lines"]
+
+# A charge this many times its category's median is unusual
+UNUSUAL_FACTOR = Decimal("3")
+
+# Fewest earlier charges in a category before its median is trusted
+MIN_CATEGORY_HISTORY = 5
+
+
+def _transaction_json(t: Transaction) -> Dict[str, object]:
+    """Serializes a transaction for the packet."""
+    return {
+        "date": t.date.isoformat(),
+        "name": t.name,
+        "accountNumber": t.account_number,
+        "amount": float(t.amount),
+        "category": t.category.value,
+    }
+
+
+def _charges(transactions: List[Transaction]) -> List[Transaction]:
+    """Charges that count, leaving out credits and ignored transactions."""
+    return [
+        t for t in transactions if t.amount > 0 and t.ignore == IgnoredFrom.NOTHING
+    ]
+
+
+def unusual_transactions(
+    month: List[Transaction], history: List[Transaction]
+) -> List[Dict[str, object]]:
+    """
+    A month's charges at least UNUSUAL_FACTOR times the median charge in their
+    category over the history, largest first. Categories with too little history
+    to judge are skipped.
+    """
+    by_category: Dict[str, List[Decimal]] = defaultdict(list)
+    for t in _charges(history):
+        by_category[t.category.value].append(t.amount)
+
+    unusual = []
+    for t in _charges(month):
+        amounts = by_category.get(t.category.value, [])
+        if len(amounts) < MIN_CATEGORY_HISTORY:
+            continue
+        typical = Decimal(str(median(amounts))).quantize(Decimal("0.01"))
+        if typical > 0 and t.amount >= typical * UNUSUAL_FACTOR:
+            unusual.append({**_transaction_json(t), "typicalAmount": float(typical)})
+    return sorted(unusual, key=lambda u: -float(u["amount"]))  # type: ignore
+
+
+def possible_duplicates(month: List[Transaction]) -> List[Dict[str, object]]:
+    """
+    Charges to the same merchant for the same amount on the same day and account,
+    which may be one charge imported twice, oldest first. Each is listed once
+    with how many times it appears.
+    """
+    seen: Dict[Tuple, List[Transaction]] = defaultdict(list)
+    for t in _charges(month):
+        key = (t.date, t.account_number, t.amount, merchant_name(t.name))
+        seen[key].append(t)
+    return [
+        {**_transaction_json(group[0]), "count": len(group)}
+        for _, group in sorted(seen.items(), key=lambda item: item[0][0])
+        if len(group) > 1
+    ]
+
+
+def _transaction_line(t: Dict[str, object]) -> str:
+    """A transaction as one line of the printed packet."""
+    return (
+        f"  {t['date']}  {str(t['name'])[:32]:<32} {t['accountNumber']:>6}"
+        f"  {to_currency(t['amount']):>11}"
+    )
+
+
+def packet_lines(packet: Dict[str, object]) -> List[str]:
+    """Lays a review packet out as lines of text, section by section."""
+    summary: Dict = packet["summary"]  # type: ignore
+    lines = [
+        f"Total spend: {to_currency(summary['total'])}",
+        "",
+        "SPEND BY PERSON",
+        *(
+            f"  {p['name']:<40} {to_currency(p['total']):>11}"
+            for p in summary["people"]
+        ),
+        "",
+        "SPEND BY CATEGORY",
+        *(
+            f"  {c['category']:<40} {to_currency(c['total']):>11}"
+            for c in summary["categories"]
+            if c["total"]
+        ),
+        "",
+        "BUDGETS",
+    ]
+    budgets: List[Dict] = packet["budgets"]  # type: ignore
+    lines += [
+        f"  {b['category']:<30} {to_currency(b['spent']):>11} of "
+        f"{to_currency(b['limit']):>11}" + ("  OVER" if b["overBudget"] else "")
+        for b in budgets
+    ] or ["  No budgets set."]
+
+    sections = [
+        ("UNUSUAL TRANSACTIONS", "unusual", "None stood out."),
+        ("POSSIBLE DUPLICATES", "duplicates", "None found."),
+        ("UNASSIGNED TRANSACTIONS", "unassigned", "Every transaction has an owner."),
+    ]
+    for heading, key, empty in sections:
+        items: List[Dict] = packet[key]  # type: ignore
+        lines += ["", heading]
+        lines += [_transaction_line(t) for t in items] or [f"  {empty}"]
+    return lines
@@ -0,0 +1,78 @@
+"""
+Card rewards: cashback estimated from each card's reward rates.
+"""
+
+from decimal import Decimal
+from typing import Any, Dict, List, Optional
+
+from rmanalyzer.fees import card_transactions
+from rmanalyzer.models import AnyCategory, Transaction, all_categories
+
+__all__ = ["reward_rate", "card_rewards", "best_cards"]
+
+
+def reward_rate(account: Dict[str, Any], category: AnyCategory) -> Decimal:
+    """
+    The card's reward rate (a percentage) for a category: the category's own rate
+    if set, otherwise the flat rate, otherwise zero.
+    """
+    rates = account.get("categoryRewardRates") or {}
+    rate = rates.get(category.value, account.get("rewardRate"))
+    return Decimal(str(rate or 0))
+
+
+def card_rewards(
+    account: Dict[str, Any], transactions: List[Transaction]
+) -> Dict[str, Any]:
+    """Estimates the rewards a card earned on the transactions, by category."""
+    spend: Dict[AnyCategory, Decimal] = {}
+    for t in card_transactions(transactions, account.get("mask")):
+        spend[t.category] = spend.get(t.category, Decimal("0.00")) + t.amount
+
+    by_category = {
+        category.value: {
+            "spend": float(amount),
+            "rate": float(reward_rate(account, category)),
+            "rewards": float(
+                (amount * reward_rate(account, category) / 100).quantize(
+                    Decimal("0.01")
+                )
+            ),
+        }
+        for category, amount in spend.items()
+    }
+    return {
+        "accountId": account["accountId"],
+        "institution": account.get("institution"),
+        "mask": account.get("mask"),
+        "spend": round(sum(c["spend"] for c in by_category.values()), 2),
+        "rewards": round(sum(c["rewards"] for c in by_category.values()), 2),
+        "byCategory": by_category,
+    }
+
+
+def best_cards(
+    accounts: List[Dict[str, Any]], categories: Optional[List[AnyCategory]] = None
+) -> Dict[str, Optional[Dict[str, Any]]]:
+    """
+    The card with the highest reward rate for each category, None where no card
+    earns anything. Ties go to the card listed first.
+    """
+    best: Dict[str, Optional[Dict[str, Any]]] = {}
+    for category in categories or all_categories():
+        ranked = sorted(
+            accounts, key=lambda a, c=category: reward_rate(a, c), reverse=True
+        )
+        top = ranked[0] if ranked else None
+        rate = reward_rate(top, category) if top else Decimal("0")
+        best[category.value] = (
+            {
+                "accountId": top["accountId"],
+                "institution": top.get("institution"),
+                "mask": top.get("mask"),
+                "rate": float(rate),
+            }
+            if top and rate > 0
+            else None
+        )
+    return best
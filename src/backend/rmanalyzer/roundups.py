@@ -0,0 +1,44 @@
+"""
+Spare-change round-ups of purchases, suggested as savings contributions.
+"""
+
+from decimal import ROUND_CEILING, Decimal
+from typing import Dict, List
+
+from rmanalyzer.models import IgnoredFrom, Person, Transaction
+
+__all__ = ["ROUND_UP_ITEM_NAME", "round_up", "person_round_ups"]
+
+# Name of the savings item a recorded round-up is stored as
+ROUND_UP_ITEM_NAME = "Round-ups"
+
+
+def round_up(amount: Decimal, increment: Decimal) -> Decimal:
+    """Returns the spare change needed to bring a purchase up to the next increment."""
+    if amount <= 0 or increment <= 0:
+        return Decimal("0.00")
+    rounded = (amount / increment).to_integral_value(rounding=ROUND_CEILING) * increment
+    return (rounded - amount).quantize(Decimal("0.01"))
+
+
+def _is_purchase_by(person: Person, t: Transaction) -> bool:
+    """Owner overrides take precedence over account ownership. Credits don't count."""
+    if t.amount <= 0 or t.ignore == IgnoredFrom.EVERYTHING:
+        return False
+    if t.owner:
+        return t.owner.lower() == person.email.lower()
+    return t.account_number in person.account_numbers
+
+
+def person_round_ups(
+    person: Person, transactions: List[Transaction], increment: Decimal
+) -> Dict[str, object]:
+    """
+    Totals the round-ups of a person's purchases. Unlike shared expenses, every
+    purchase counts regardless of category; only fully ignored transactions are skipped.
+    """
+    purchases = [t for t in transactions if _is_purchase_by(person, t)]
+    total = sum(
+        (round_up(t.amount, increment) for t in purchases), start=Decimal("0.00")
+    )
+    return {"increment": increment, "purchases": len(purchases), "total": total}
@@ -0,0 +1,213 @@
+"""
+Category rules: user-defined patterns that categorize transactions on import.
+"""
+
+import dataclasses
+import logging
+import re
+from dataclasses import dataclass
+from decimal import Decimal
+from typing import Dict, Iterable, List, Optional
+
+from rmanalyzer import filters
+from rmanalyzer.models import AnyCategory, Transaction, parse_category
+
+__all__ = ["Rule", "apply_rules", "has_nested_quantifier"]
+
+# A repetition operator in a regex: *, + or a {m,n} range
+_QUANTIFIER = re.compile(r"[*+]|\{\d*,\d*\}")
+
+
+@dataclass(frozen=True)
+class Rule:
+    """
+    Assigns a category to transactions matching every condition it sets: a
+    case-insensitive substring or regex on the description, an amount range
+    (inclusive), an account and a filter expression (see filters). Rules run by
+    ascending priority; the first match wins.
+    """
+
+    rule_id: str
+    category: AnyCategory
+    contains: Optional[str] = None
+    pattern: Optional[str] = None
+    min_amount: Optional[Decimal] = None
+    max_amount: Optional[Decimal] = None
+    account_number: Optional[int] = None
+    priority: int = 0
+    filter_expression: Optional[str] = None
+
+    def matches(self, t: Transaction) -> bool:
+        """True when the transaction meets every condition the rule sets."""
+        if self.contains and self.contains.lower() not in t.name.lower():
+            return False
+        if self.pattern and not re.search(self.pattern, t.name, re.IGNORECASE):
+            return False
+        if self.min_amount is not None and t.amount < self.min_amount:
+            return False
+        if self.max_amount is not None and t.amount > self.max_amount:
+            return False
+        if self.account_number is not None and t.account_number != self.account_number:
+            return False
+        if self.filter_expression and not filters.parse(
+            self.filter_expression
+        ).matches(t):
+            return False
+        return True
+
+    @property
+    def filter_error(self) -> Optional[str]:
+        """
+        Why the rule's filter no longer parses, e.g. after a category it names
+        was deleted, or None if it does or the rule has none.
+        """
+        if not self.filter_expression:
+            return None
+        try:
+            filters.parse(self.filter_expression)
+        except filters.FilterError as e:
+            return str(e)
+        return None
+
+    @classmethod
+    def from_json(
+        cls, rule_id: str, data: Dict[str, object], added: Iterable[str] = ()
+    ) -> "Rule":
+        """
+        Create a rule from a validated API request body. added is the
+        household's added categories, which the rule may set.
+        """
+
+        def amount(field: str) -> Optional[Decimal]:
+            value = data.get(field)
+            return None if value is None else Decimal(str(value))
+
+        account = data.get("accountNumber")
+        return cls(
+            rule_id=rule_id,
+            category=parse_category(data["category"], added),
+            contains=data.get("contains") or None,
+            pattern=data.get("pattern") or None,
+            min_amount=amount("minAmount"),
+            max_amount=amount("maxAmount"),
+            account_number=None if account is None else int(account),
+            priority=int(data.get("priority") or 0),
+            filter_expression=data.get("filter") or None,
+        )
+
+    def to_json(self) -> Dict[str, object]:
+        """Serialize the rule for the API."""
+        return {
+            "id": self.rule_id,
+            "category": self.category.value,
+            "contains": self.contains,
+            "pattern": self.pattern,
+            "minAmount": None if self.min_amount is None else float(self.min_amount),
+            "maxAmount": None if self.max_amount is None else float(self.max_amount),
+            "accountNumber": self.account_number,
+            "priority": self.priority,
+            "filter": self.filter_expression,
+            "filterError": self.filter_error,
+        }
+
+    @classmethod
+    def from_entity(
+        cls, entity: Dict[str, object], added: Iterable[str] = ()
+    ) -> "Rule":
+        """Create a rule from a rules table entity; see from_json for added."""
+
+        def amount(column: str) -> Optional[Decimal]:
+            value = entity.get(column)
+            return None if value is None else Decimal(str(value))
+
+        account = entity.get("AccountNumber")
+        return cls(
+            rule_id=str(entity["RowKey"]),
+            category=parse_category(entity["Category"], added),
+            contains=entity.get("Contains") or None,
+            pattern=entity.get("Pattern") or None,
+            min_amount=amount("MinAmount"),
+            max_amount=amount("MaxAmount"),
+            account_number=None if account is None else int(account),
+            priority=int(entity.get("Priority") or 0),
+            filter_expression=entity.get("Filter") or None,
+        )
+
+    def to_entity(self, partition_key: str) -> Dict[str, object]:
+        """Serialize the rule for the rules table. Unset conditions are omitted."""
+        entity: Dict[str, object] = {
+            "PartitionKey": partition_key,
+            "RowKey": self.rule_id,
+            "Category": self.category.value,
+            "Priority": self.priority,
+        }
+        optional = {
+            "Contains": self.contains,
+            "Pattern": self.pattern,
+            "MinAmount": None if self.min_amount is None else float(self.min_amount),
+            "MaxAmount": None if self.max_amount is None else float(self.max_amount),
+            "AccountNumber": self.account_number,
+            "Filter": self.filter_expression,
+        }
+        entity.update({k: v for k, v in optional.items() if v is not None})
+        return entity
+
+
+def has_nested_quantifier(pattern: str) -> bool:
+    """
+    Whether a regex repeats a group that itself contains a repetition, such as
+    (a+)+ or (\w*,)*. Those backtrack exponentially on names that almost match,
+    so one rule could stall every import.
+    """
+    # Whether each open group, innermost last, contains a repetition
+    groups = [False]
+    in_class = False
+    i = 0
+    while i < len(pattern):
+        char = pattern[i]
+        if char == "\\":
+            i += 2
+            continue
+        if in_class:
+            in_class = char != "]"
+        elif char == "[":
+            in_class = True
+            # A ] straight after [ or [^ is a literal, not the end of the class
+            i += 2 if pattern[i + 1 : i + 2] == "^" else 1
+            if pattern[i : i + 1] == "]":
+                i += 1
+            continue
+        elif char == "(":
+            groups.append(False)
+        elif char == ")" and len(groups) > 1:
+            inner = groups.pop()
+            if inner and _QUANTIFIER.match(pattern, i + 1):
+                return True
+            groups[-1] = groups[-1] or inner
+        elif _QUANTIFIER.match(pattern, i):
+            groups[-1] = True
+        i += 1
+    return False
+
+
+def apply_rules(
+    rules: List[Rule], transactions: List[Transaction]
+) -> List[Transaction]:
+    """
+    Returns the transactions with the category of the first matching rule applied.
+    Transactions no rule matches keep the category they were imported with. A
+    rule whose filter no longer parses is logged and matches nothing, so one
+    broken rule doesn't fail the import.
+    """
+    ordered = sorted(rules, key=lambda r: (r.priority, r.rule_id))
+    broken = [r for r in ordered if r.filter_error]
+    for r in broken:
+        logging.warning("Skipping rule %s: %s", r.rule_id, r.filter_error)
+    ordered = [r for r in ordered if r not in broken]
+    result = []
+    for t in transactions:
+        rule = next((r for r in ordered if r.matches(t)), None)
+        if rule is not None and rule.category != t.category:
+            t = dataclasses.replace(t, category=rule.category)
+        result.append(t)
+    return result
@@ -0,0 +1,176 @@
+"""
+The sandbox household: a second household of demo data kept in its own tables
+alongside the real one. A request opts in with the x-household header, so
+features can be demoed and the frontend developed against realistic data
+without touching production records.
+"""
+
+import contextlib
+import contextvars
+import os
+import random
+from datetime import date
+from decimal import Decimal
+from typing import Any, Dict, Iterator, List
+
+from rmanalyzer.emergency import previous_months
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+
+__all__ = [
+    "HOUSEHOLD_HEADER",
+    "LIVE",
+    "SANDBOX",
+    "TABLE_PREFIX",
+    "enabled",
+    "active",
+    "use",
+    "demo_household",
+]
+
+HOUSEHOLD_HEADER = "x-household"
+LIVE = "live"
+SANDBOX = "sandbox"
+
+# Prefix of the sandbox's copy of every table. Table names can't contain "_"
+TABLE_PREFIX = "sandbox"
+
+# Complete months of demo transactions, before the current one
+DEMO_MONTHS = 6
+
+DEMO_PARTNER = {"Name": "Sam Demo", "Email": "sam.demo@example.com"}
+CHECKING_ACCOUNT = 1001
+MEMBER_CARD = 2002
+PARTNER_CARD = 3003
+
+_HOUSEHOLD: contextvars.ContextVar[str] = contextvars.ContextVar(
+    "household", default=LIVE
+)
+
+
+def enabled() -> bool:
+    """Whether this deployment has a sandbox (SANDBOX_ENABLED is true)."""
+    return os.environ.get("SANDBOX_ENABLED", "false").lower() == "true"
+
+
+def active() -> bool:
+    """Whether the request being handled is in the sandbox household."""
+    return _HOUSEHOLD.get() == SANDBOX
+
+
+@contextlib.contextmanager
+def use(household: str) -> Iterator[None]:
+    """Handles the requests inside the block in a household."""
+    token = _HOUSEHOLD.set(household)
+    try:
+        yield
+    finally:
+        _HOUSEHOLD.reset(token)
+
+
+def _transaction(
+    day: date, name: str, account: int, amount: str, category: Category
+) -> Transaction:
+    return Transaction(
+        day, name, account, Decimal(amount), category, IgnoredFrom.NOTHING
+    )
+
+
+def _demo_month(month: str, rng: random.Random) -> List[Transaction]:
+    """A month of a two-person household's pay, bills and everyday spending."""
+    year, number = (int(part) for part in month.split("-"))
+
+    def day(d: int) -> date:
+        return date(year, number, d)
+
+    def amount(low: int, high: int) -> str:
+        return f"{rng.uniform(low, high):.2f}"
+
+    transactions = [
+        _transaction(day(d), name, account, value, category)
+        for d, name, account, value, category in [
+            (1, "PAYROLL DEPOSIT", CHECKING_ACCOUNT, "-3200.00", Category.OTHER),
+            (15, "PAYROLL DEPOSIT", CHECKING_ACCOUNT, "-3200.00", Category.OTHER),
+            (1, "RENT", CHECKING_ACCOUNT, "1850.00", Category.BILLS),
+            (12, "CITY POWER", MEMBER_CARD, amount(70, 140), Category.BILLS),
+            (18, "FIBER INTERNET", PARTNER_CARD, "65.00", Category.BILLS),
+            (8, "STREAMFLIX", MEMBER_CARD, "15.99", Category.SUBSCRIPTIONS),
+            (20, "PET SUPPLY CO", PARTNER_CARD, amount(40, 70), Category.PETS),
+        ]
+    ]
+    for week in range(4):
+        card = MEMBER_CARD if week % 2 == 0 else PARTNER_CARD
+        transactions.append(
+            _transaction(
+                day(3 + 7 * week),
+                "FRESH MARKET",
+                card,
+                amount(60, 160),
+                Category.GROCERIES,
+            )
+        )
+    for d in sorted(rng.sample(range(1, 29), 6)):
+        transactions.append(
+            _transaction(
+                day(d),
+                rng.choice(["CORNER CAFE", "TACO SPOT", "PIZZERIA", "NOODLE BAR"]),
+                rng.choice([MEMBER_CARD, PARTNER_CARD]),
+                amount(12, 85),
+                Category.DINING,
+            )
+        )
+    return transactions
+
+
+def demo_household(member: Dict[str, str], today: date) -> Dict[str, Any]:
+    """
+    Demo data for the sandbox: the member seeding it and a demo partner, six
+    months of transactions plus this month's so far, budgets, a card, this
+    month's savings and an emergency fund. The same day always gives the same
+    data.
+    """
+    rng = random.Random(today.isoformat())
+    current = today.strftime("%Y-%m")
+    months = {
+        month: [t for t in _demo_month(month, rng) if t.date <= today]
+        for month in [*previous_months(today, DEMO_MONTHS), current]
+    }
+
+    return {
+        "people": [
+            {
+                "Name": member["Name"],
+                "Email": member["Email"],
+                "Accounts": [CHECKING_ACCOUNT, MEMBER_CARD],
+            },
+            {**DEMO_PARTNER, "Accounts": [PARTNER_CARD]},
+        ],
+        "transactions": months,
+        "budgets": {
+            Category.GROCERIES: Decimal("600"),
+            Category.DINING: Decimal("250"),
+            Category.PETS: Decimal("80"),
+        },
+        "accounts": [
+            {
+                "accountId": "demo-card",
+                "name": "Demo Rewards Card",
+                "institution": "Demo Bank",
+                "mask": str(MEMBER_CARD),
+                "balance": 1240.55,
+                "limit": 5000.0,
+                "dueDay": 20,
+                "statementCloseDay": 25,
+                "apr": 22.99,
+                "rewardRate": 1.5,
+            }
+        ],
+        "savings": {
+            "startingBalance": 2500.0,
+            "items": [
+                {"name": "Emergency fund", "cost": 400.0},
+                {"name": "Vacation", "cost": 250.0},
+                {"name": "New tires", "cost": 320.0, "oneOff": True},
+            ],
+        },
+        "settings": {"EmergencyFund": "6000"},
+    }
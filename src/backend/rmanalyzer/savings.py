@@ -0,0 +1,34 @@
+"""
+Savings months as templates: a month's items carried into the next, starting
+from what the month left over.
+"""
+
+from decimal import Decimal
+from typing import Any, Dict
+
+__all__ = ["ending_balance", "copy_forward"]
+
+
+def ending_balance(savings: Dict[str, Any]) -> Decimal:
+    """What is left of a month's starting balance after its items."""
+    balance = Decimal(str(savings.get("startingBalance", 0)))
+    cost = sum(
+        (Decimal(str(i.get("cost", 0))) for i in savings.get("items", [])),
+        start=Decimal("0.00"),
+    )
+    return (balance - cost).quantize(Decimal("0.01"))
+
+
+def copy_forward(savings: Dict[str, Any], exclude_one_off: bool) -> Dict[str, Any]:
+    """
+    The next month's savings from a month's: the same items, optionally without
+    those marked one-off, starting from the month's ending balance.
+    """
+    return {
+        "startingBalance": float(ending_balance(savings)),
+        "items": [
+            dict(i)
+            for i in savings.get("items", [])
+            if not (exclude_one_off and i.get("oneOff"))
+        ],
+    }
@@ -0,0 +1,85 @@
+"""
+Free-text transaction search. Table Storage has no text index, so a search scans
+each month in its window, with the amount and date bounds in the query, and
+matches descriptions in memory. A SearchIndex can keep scanned months warm so
+repeated searches skip the scan.
+"""
+
+import time
+from datetime import date
+from decimal import Decimal
+from typing import Callable, Dict, List, Optional, Tuple
+
+from rmanalyzer.models import Transaction
+
+__all__ = ["SearchIndex", "terms", "matches", "within"]
+
+KeyedTransactions = List[Tuple[str, Transaction]]
+
+
+def terms(query: str) -> List[str]:
+    """The words of a search query, lowercased."""
+    return query.lower().split()
+
+
+def matches(words: List[str], description: str) -> bool:
+    """Whether a description contains every word, ignoring case."""
+    text = description.lower()
+    return all(word in text for word in words)
+
+
+def within(
+    t: Transaction,
+    min_amount: Optional[Decimal] = None,
+    max_amount: Optional[Decimal] = None,
+    start: Optional[date] = None,
+    end: Optional[date] = None,
+) -> bool:
+    """Whether a transaction is inside amount and date bounds, inclusive."""
+    return (
+        (min_amount is None or t.amount >= min_amount)
+        and (max_amount is None or t.amount <= max_amount)
+        and (start is None or t.date >= start)
+        and (end is None or t.date <= end)
+    )
+
+
+class SearchIndex:
+    """
+    Months of transactions kept in memory for ttl seconds after they're scanned,
+    by table and month, so the live and sandbox households don't mix. A ttl of 0
+    keeps nothing.
+    """
+
+    def __init__(self, ttl: float) -> None:
+        self.ttl = ttl
+        self._months: Dict[Tuple[str, str], Tuple[float, KeyedTransactions]] = {}
+
+    @property
+    def enabled(self) -> bool:
+        """Whether months are kept at all."""
+        return self.ttl > 0
+
+    def month(
+        self, table: str, month: str, scan: Callable[[], KeyedTransactions]
+    ) -> KeyedTransactions:
+        """A month's transactions, scanned only if they aren't kept or are stale."""
+        if not self.enabled:
+            return scan()
+        kept = self._months.get((table, month))
+        if kept and time.monotonic() - kept[0] < self.ttl:
+            return kept[1]
+        transactions = scan()
+        self._months[(table, month)] = (time.monotonic(), transactions)
+        return transactions
+
+    def forget(self, table: str, month: Optional[str] = None) -> None:
+        """
+        Drops a month of a table, or with None every month of it, so the next
+        search scans them again. Writes to transactions call it.
+        """
+        if month is not None:
+            self._months.pop((table, month), None)
+            return
+        for key in [k for k in self._months if k[0] == table]:
+            self._months.pop(key, None)
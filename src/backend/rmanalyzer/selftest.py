@@ -0,0 +1,109 @@
+"""
+Startup self-test for deployment smoke checks: exercises each dependency the app
+is configured with and exits non-zero with a diagnosis when one fails.
+
+    python -m rmanalyzer.selftest
+"""
+
+import sys
+import uuid
+from datetime import date
+from decimal import Decimal
+from typing import Callable, Dict, List, Tuple
+
+from rmanalyzer import services
+from rmanalyzer.models import Category, Group, IgnoredFrom, Person, Transaction
+
+__all__ = ["CHECKS", "run", "main"]
+
+
+def _check_table() -> None:
+    services.DatabaseService().probe()
+
+
+def _check_blob() -> None:
+    blob_service = services.BlobService()
+    name = f"selftest-{uuid.uuid4().hex}.txt"
+    content = b"rm-analyzer self-test"
+    blob_service.upload_blob(services.BlobKind.MESSAGES, name, content)
+    try:
+        if blob_service.download_blob(services.BlobKind.MESSAGES, name) != content:
+            raise RuntimeError("Downloaded probe blob doesn't match what was uploaded")
+    finally:
+        blob_service.delete_blob(name, services.BlobKind.MESSAGES)
+
+
+def _check_queue() -> None:
+    services.QueueService().probe()
+
+
+def _check_email() -> None:
+    """Renders a summary email for a made-up household; nothing is sent."""
+    group = Group(
+        [
+            Person("Probe A", "a@example.com", [1]),
+            Person("Probe B", "b@example.com", [2]),
+        ]
+    )
+    group.add_transactions(
+        [
+            Transaction(
+                date.today(),
+                "SELFTEST",
+                1,
+                Decimal("10.00"),
+                Category.GROCERIES,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+    )
+    renderer = services.EmailRenderer()
+    if not renderer.render_subject(group) or not renderer.render_body(group):
+        raise RuntimeError("Rendered an empty email")
+
+
+# Each dependency with its check and the settings to look at when it fails
+CHECKS: List[Tuple[str, Callable[[], None], str]] = [
+    ("table", _check_table, "TABLE_SERVICE_URL and the settings table"),
+    ("blob", _check_blob, "BLOB_SERVICE_URL and MESSAGES_CONTAINER_NAME"),
+    ("queue", _check_queue, "QUEUE_SERVICE_URL and PROBE_QUEUE_NAME"),
+    ("email", _check_email, "the email templates"),
+]
+
+
+def run(
+    checks: List[Tuple[str, Callable[[], None], str]],
+) -> List[Dict[str, object]]:
+    """Runs every check, carrying on past failures, and returns their results."""
+    results: List[Dict[str, object]] = []
+    for name, check, hint in checks:
+        try:
+            check()
+            results.append({"check": name, "ok": True})
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            results.append(
+                {
+                    "check": name,
+                    "ok": False,
+                    "error": f"{type(e).__name__}: {e}",
+                    "hint": f"Check {hint}.",
+                }
+            )
+    return results
+
+
+def main() -> int:
+    """Prints each check's outcome. Returns 1 if any failed, otherwise 0."""
+    results = run(CHECKS)
+    for r in results:
+        if r["ok"]:
+            print(f"ok    {r['check']}")
+        else:
+            print(f"FAIL  {r['check']}: {r['error']}. {r['hint']}")
+    failed = [r for r in results if not r["ok"]]
+    print(f"{len(results) - len(failed)} of {len(results)} checks passed.")
+    return 1 if failed else 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -1,15 +1,37 @@
 """Services package."""
 
-from .blob_service import BlobService
-from .database_service import DatabaseService
+from .blob_service import BlobKind, BlobService
+from .concurrency import ConcurrencyRetrier
+from .database_service import (
+    MANUAL_CATEGORY,
+    UNDO_CHUNK_LENGTH,
+    DatabaseService,
+    table_routes_scope,
+)
 from .email_renderer import EmailRenderer
-from .email_service import EmailService
-from .queue_service import QueueService
+from .email_service import EmailAttachment, EmailService
+from .exchange_rate_service import ExchangeRateService, RateProvider
+from .queue_service import FAILURE_ID_KEY, QueuePriority, QueueService
+from .secret_provider import SECRET_PREFIX, SecretProvider
+from .table_metrics import InstrumentedTableClient
 
 __all__ = [
+    "FAILURE_ID_KEY",
+    "MANUAL_CATEGORY",
+    "SECRET_PREFIX",
+    "UNDO_CHUNK_LENGTH",
+    "BlobKind",
     "BlobService",
+    "ConcurrencyRetrier",
+    "QueuePriority",
     "QueueService",
+    "SecretProvider",
     "DatabaseService",
+    "EmailAttachment",
     "EmailRenderer",
     "EmailService",
+    "ExchangeRateService",
+    "RateProvider",
+    "InstrumentedTableClient",
+    "table_routes_scope",
 ]
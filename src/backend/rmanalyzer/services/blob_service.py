@@ -2,16 +2,49 @@
 
 import logging
 import os
+from datetime import date, datetime, timedelta, timezone
+from enum import Enum
+from typing import Any
 
 from azure.core.exceptions import ResourceExistsError
 from azure.identity import DefaultAzureCredential
-from azure.storage.blob import BlobServiceClient, ContainerClient
+from azure.storage.blob import (
+    BlobSasPermissions,
+    BlobServiceClient,
+    ContainerClient,
+    StandardBlobTier,
+    generate_blob_sas,
+)
 
 from .constants import AZURE_DEV_ACCOUNT_KEY
 
 logger = logging.getLogger(__name__)
 
 
+class BlobKind(Enum):
+    """Classes of stored content, each routed to its own container."""
+
+    UPLOADS = "uploads"
+    RECEIPTS = "receipts"
+    BACKUPS = "backups"
+    REPORTS = "reports"
+    MESSAGES = "messages"
+    DOCUMENTS = "documents"
+
+
+# Default (container env var, container name, access tier) per kind
+_CONTAINER_DEFAULTS: dict[BlobKind, tuple[str, str, StandardBlobTier]] = {
+    BlobKind.UPLOADS: ("BLOB_CONTAINER_NAME", "csv-uploads", StandardBlobTier.HOT),
+    BlobKind.RECEIPTS: ("RECEIPTS_CONTAINER_NAME", "receipts", StandardBlobTier.COOL),
+    BlobKind.BACKUPS: ("BACKUPS_CONTAINER_NAME", "backups", StandardBlobTier.COOL),
+    BlobKind.REPORTS: ("REPORTS_CONTAINER_NAME", "reports", StandardBlobTier.HOT),
+    BlobKind.MESSAGES: ("MESSAGES_CONTAINER_NAME", "messages", StandardBlobTier.HOT),
+    BlobKind.DOCUMENTS: (
+        "DOCUMENTS_CONTAINER_NAME", "documents", StandardBlobTier.COOL
+    ),
+}
+
+
 class BlobService:
     """Service for interacting with Azure Blob Storage."""
 
@@ -21,9 +54,19 @@ class BlobService:
             raise ValueError("BLOB_SERVICE_URL environment variable is not set.")
         self._blob_service_url: str = blob_service_url
 
-        self._container_name = os.environ.get("BLOB_CONTAINER_NAME", "csv-uploads")
+        # Container and access tier per kind, overridable via <KIND>_ACCESS_TIER
+        self._containers: dict[BlobKind, tuple[str, StandardBlobTier]] = {}
+        for kind, (env_var, default_name, default_tier) in _CONTAINER_DEFAULTS.items():
+            tier = os.environ.get(f"{kind.name}_ACCESS_TIER")
+            self._containers[kind] = (
+                os.environ.get(env_var, default_name),
+                StandardBlobTier(tier) if tier else default_tier,
+            )
+        self._container_name = self._containers[BlobKind.UPLOADS][0]
         self._blob_service_client: BlobServiceClient | None = None
         self._container_clients: dict[str, ContainerClient] = {}
+        self._delegation_key: Any = None
+        self._delegation_key_expiry = datetime.min.replace(tzinfo=timezone.utc)
 
     def _get_blob_service_client(self) -> BlobServiceClient:
         """Returns a BlobServiceClient."""
@@ -62,23 +105,130 @@ class BlobService:
         self._container_clients[container_name] = container_client
         return container_client
 
-    def upload_csv(self, file_name: str, content: bytes) -> str:
+    def ensure_containers(self) -> list[str]:
+        """Creates the container for every kind if missing. Returns the container names."""
+        names = [self.container_name(kind) for kind in BlobKind]
+        for name in names:
+            self._get_container_client(name)
+        return names
+
+    def container_name(self, kind: BlobKind) -> str:
+        """Returns the container name a kind of content is routed to."""
+        return self._containers[kind][0]
+
+    def upload_blob(self, kind: BlobKind, file_name: str, content: bytes) -> str:
         """
-        Uploads CSV content to the blob container.
+        Uploads content to the container for its kind, using that kind's access tier.
         Returns the URL of the uploaded blob.
         """
-        container_client = self._get_container_client(self._container_name)
+        container_name, tier = self._containers[kind]
+        container_client = self._get_container_client(container_name)
         blob_client = container_client.get_blob_client(file_name)
-        blob_client.upload_blob(content, overwrite=True)
+        blob_client.upload_blob(content, overwrite=True, standard_blob_tier=tier)
 
         return blob_client.url
 
+    def download_blob(self, kind: BlobKind, file_name: str) -> bytes:
+        """Downloads raw content from the container for its kind."""
+        container_client = self._get_container_client(self.container_name(kind))
+        blob_client = container_client.get_blob_client(file_name)
+
+        download_stream = blob_client.download_blob()
+        return download_stream.readall()
+
+    def _user_delegation_key(self, start: datetime, expiry: datetime) -> Any:
+        """
+        A key to sign links valid until expiry with. Keys are fetched valid for a
+        day and reused, so listing many links costs one request.
+        """
+        if self._delegation_key is None or self._delegation_key_expiry < expiry:
+            key_expiry = max(expiry, start + timedelta(days=1))
+            client = self._get_blob_service_client()
+            self._delegation_key = client.get_user_delegation_key(start, key_expiry)
+            self._delegation_key_expiry = key_expiry
+        return self._delegation_key
+
+    def download_url(
+        self,
+        kind: BlobKind,
+        file_name: str,
+        expires_in: timedelta,
+        download_name: str,
+        content_type: str,
+    ) -> str:
+        """
+        Returns a read-only link to a blob that expires, served as a download with
+        the given name and content type. In production the link is signed with a
+        user delegation key, so no account key is needed.
+        """
+        client = self._get_blob_service_client()
+        container_name = self.container_name(kind)
+        # Started a little early so clock skew doesn't make a new link invalid
+        start = datetime.now(timezone.utc) - timedelta(minutes=5)
+        expiry = datetime.now(timezone.utc) + expires_in
+        signing: dict[str, Any]
+        if self._blob_service_url.startswith("http://"):
+            signing = {"account_key": AZURE_DEV_ACCOUNT_KEY}
+        else:
+            signing = {"user_delegation_key": self._user_delegation_key(start, expiry)}
+        # Kept to what's safe inside the Content-Disposition header
+        download_name = "".join(
+            c for c in download_name if c.isprintable() and c not in '"\\'
+        )
+        sas = generate_blob_sas(
+            account_name=client.account_name,
+            container_name=container_name,
+            blob_name=file_name,
+            permission=BlobSasPermissions(read=True),
+            start=start,
+            expiry=expiry,
+            content_disposition=f'attachment; filename="{download_name}"',
+            content_type=content_type,
+            **signing,
+        )
+        blob_client = client.get_blob_client(container_name, file_name)
+        return f"{blob_client.url}?{sas}"
+
+    def upload_csv(self, file_name: str, content: bytes) -> str:
+        """
+        Uploads CSV content to the blob container.
+        Returns the URL of the uploaded blob.
+        """
+        return self.upload_blob(BlobKind.UPLOADS, file_name, content)
+
     def download_csv(self, file_name: str) -> str:
         """
         Downloads CSV content from the blob container as a string.
         """
-        container_client = self._get_container_client(self._container_name)
-        blob_client = container_client.get_blob_client(file_name)
-
-        download_stream = blob_client.download_blob()
-        return download_stream.readall().decode("utf-8")
+        return self.download_blob(BlobKind.UPLOADS, file_name).decode("utf-8")
+
+    def list_blobs(self, kind: BlobKind = BlobKind.UPLOADS) -> list[str]:
+        """Lists the names of all blobs in the container for a kind."""
+        container_client = self._get_container_client(self.container_name(kind))
+        return [blob.name for blob in container_client.list_blobs()]
+
+    def list_blob_properties(
+        self, prefix: str, kind: BlobKind = BlobKind.UPLOADS
+    ) -> list[dict[str, Any]]:
+        """Lists the blobs under a name prefix with their size and modified time."""
+        container_client = self._get_container_client(self.container_name(kind))
+        return [
+            {"name": blob.name, "size": blob.size, "lastModified": blob.last_modified}
+            for blob in container_client.list_blobs(name_starts_with=prefix)
+        ]
+
+    def list_blobs_before(
+        self, cutoff: date, kind: BlobKind = BlobKind.UPLOADS
+    ) -> list[str]:
+        """Lists the names of blobs last modified before the cutoff date."""
+        container_client = self._get_container_client(self.container_name(kind))
+        return [
+            blob.name
+            for blob in container_client.list_blobs()
+            if blob.last_modified and blob.last_modified.date() < cutoff
+        ]
+
+    def delete_blob(self, file_name: str, kind: BlobKind = BlobKind.UPLOADS) -> None:
+        """Deletes a blob from the container for a kind."""
+        container_client = self._get_container_client(self.container_name(kind))
+        container_client.delete_blob(file_name)
@@ -0,0 +1,43 @@
+"""Optimistic concurrency for read-modify-write table updates."""
+
+import logging
+import time
+from dataclasses import dataclass
+from typing import Callable, TypeVar
+
+from azure.core.exceptions import ResourceModifiedError
+
+logger = logging.getLogger(__name__)
+
+T = TypeVar("T")
+
+
+@dataclass
+class ConcurrencyRetrier:
+    """
+    Runs a read-modify-write operation whose write is conditional on the ETag it
+    read. When another writer got there first the write fails with 412
+    Precondition Failed, and the whole operation is retried with exponential
+    backoff, so each attempt re-reads the entity and reapplies its change.
+
+    retries: attempts after the first before the conflict is raised.
+    """
+
+    retries: int = 5
+    backoff: float = 0.05
+    sleep: Callable[[float], None] = time.sleep
+
+    def run(self, operation: Callable[[], T]) -> T:
+        """Runs the operation, retrying it on ETag conflicts."""
+        attempt = 0
+        while True:
+            try:
+                return operation()
+            except ResourceModifiedError:
+                if attempt >= self.retries:
+                    raise
+
+            delay = self.backoff * (2**attempt)
+            attempt += 1
+            logger.info("ETag conflict, retrying in %.2fs (attempt %d)", delay, attempt)
+            self.sleep(delay)
@@ -1,23 +1,128 @@
 """Service for interacting with Azure Table Storage."""
 
+import base64
 import collections
+import contextlib
+import contextvars
+import copy
+import dataclasses
 import hashlib
 import json
 import logging
 import os
+import time
 import uuid
-from datetime import datetime
-from typing import Any
+from concurrent.futures import ThreadPoolExecutor
+from datetime import date, datetime
+from decimal import Decimal
+from typing import Any, Callable, Iterable, Iterator
 
+from azure.core import MatchConditions
 from azure.core.credentials import AzureNamedKeyCredential
-from azure.data.tables import TableClient, TableTransactionError, UpdateMode
+from azure.core.exceptions import (
+    ResourceExistsError,
+    ResourceModifiedError,
+    ResourceNotFoundError,
+)
+from azure.data.tables import (
+    TableClient,
+    TableServiceClient,
+    TableTransactionError,
+    UpdateMode,
+)
 from azure.identity import DefaultAzureCredential
 
-from ..models import Transaction
+from ..connectors import Connector
+from ..documents import Document
+from ..mailbox import Mailbox
+from ..ledger import LedgerEntry
+from ..models import (
+    AnyCategory,
+    Category,
+    IgnoredFrom,
+    Transaction,
+    parse_category,
+)
+from ..rules import Rule
+from .concurrency import ConcurrencyRetrier
 from .constants import AZURE_DEV_ACCOUNT_KEY
+from .table_metrics import InstrumentedTableClient
 
 logger = logging.getLogger(__name__)
 
+# Partitions save_transactions writes at once, unless SAVE_TRANSACTIONS_WORKERS is set
+DEFAULT_SAVE_WORKERS = 8
+
+# Entities per batch, the most a table transaction accepts
+BATCH_SIZE = 100
+
+# Entities fetched per page when streaming an export, the most a query returns
+EXPORT_PAGE_SIZE = 1000
+
+# Activity and job run row keys count down in milliseconds from this moment
+# (year 2286), so the newest sort first
+ACTIVITY_EPOCH_END_MS = 10**13
+
+# CategorySource of a transaction whose category a user set by hand; rule runs
+# leave it alone unless told to override
+MANUAL_CATEGORY = "manual"
+
+# Setting holding the tables configured table names are switched to
+TABLE_ROUTES_SETTING = "TableRoutes"
+
+# Settings partition recording each finished migration, keyed by its table
+MIGRATIONS_PARTITION = "MIGRATIONS"
+
+# Seconds a worker keeps using the table routes it last read outside an
+# invocation; see table_routes_scope
+TABLE_ROUTES_TTL = 60
+
+# Seconds a worker keeps using the household's categories it last read
+CATEGORIES_TTL = 60
+
+# Characters of an undo inverse per property, within the 32K a string holds
+UNDO_CHUNK_LENGTH = 30000
+
+
+# Table routes read in the current invocation, per settings table
+_ROUTES_SCOPE: contextvars.ContextVar[dict[str, dict[str, str]] | None] = (
+    contextvars.ContextVar("table_routes_scope", default=None)
+)
+
+
+@contextlib.contextmanager
+def table_routes_scope() -> Iterator[None]:
+    """
+    Scopes a function invocation: the table routes are read afresh on its first
+    table request and kept for the rest of it, so every worker follows a switch
+    from its next invocation and an invocation never mixes tables.
+    """
+    token = _ROUTES_SCOPE.set({})
+    try:
+        yield
+    finally:
+        _ROUTES_SCOPE.reset(token)
+
+
+def _quoted(value: object) -> str:
+    """An OData string literal, with single quotes doubled so values can't break out."""
+    return "'" + str(value).replace("'", "''") + "'"
+
+
+def _known_category(name: str | None, added: list[str]) -> AnyCategory:
+    """
+    A stored category, reading those not built in or among the household's
+    added ones (i.e. since deleted) as Other.
+    """
+    if not Category.is_valid(name, added):
+        return Category.OTHER
+    return parse_category(name, added)
+
+
+def _charged_amount(t: Transaction) -> Decimal:
+    """What was charged, in the transaction's own currency."""
+    return t.original_amount if t.original_amount is not None else t.amount
+
 
 class DatabaseService:
     """Service for interacting with Azure Table Storage."""
@@ -28,32 +133,85 @@ class DatabaseService:
         if not url:
             raise ValueError("TABLE_SERVICE_URL environment variable is not set.")
         self._table_service_url = url
+        self._retrier = ConcurrencyRetrier()
+        self._save_workers = max(
+            int(os.environ.get("SAVE_TRANSACTIONS_WORKERS", DEFAULT_SAVE_WORKERS)), 1
+        )
 
         self._transactions_table = os.environ.get("TRANSACTIONS_TABLE", "transactions")
         self._savings_table = os.environ.get("SAVINGS_TABLE", "savings")
         self._people_table = os.environ.get("PEOPLE_TABLE", "people")
+        self._settings_table = os.environ.get("SETTINGS_TABLE", "settings")
+        self._api_keys_table = os.environ.get("API_KEYS_TABLE", "apikeys")
+        self._debts_table = os.environ.get("DEBTS_TABLE", "debts")
+        self._health_table = os.environ.get("HEALTH_SCORES_TABLE", "healthscores")
+        self._accounts_table = os.environ.get("ACCOUNTS_TABLE", "accounts")
+        self._subscriptions_table = os.environ.get(
+            "SUBSCRIPTIONS_TABLE", "subscriptions"
+        )
+        self._rules_table = os.environ.get("RULES_TABLE", "rules")
+        self._budgets_table = os.environ.get("BUDGETS_TABLE", "budgets")
+        self._invites_table = os.environ.get("INVITES_TABLE", "invites")
+        self._activity_table = os.environ.get("ACTIVITY_TABLE", "activity")
+        self._failures_table = os.environ.get("FAILURES_TABLE", "failures")
+        self._jobs_table = os.environ.get("JOBS_TABLE", "jobs")
+        self._uploads_table = os.environ.get("UPLOADS_TABLE", "uploads")
+        self._processing_log_table = os.environ.get(
+            "PROCESSING_LOG_TABLE", "processinglog"
+        )
+        self._exchange_rates_table = os.environ.get(
+            "EXCHANGE_RATES_TABLE", "exchangerates"
+        )
+        self._connectors_table = os.environ.get("CONNECTORS_TABLE", "connectors")
+        self._documents_table = os.environ.get("DOCUMENTS_TABLE", "documents")
+        self._statements_table = os.environ.get("STATEMENTS_TABLE", "statements")
+        self._reconciliations_table = os.environ.get(
+            "RECONCILIATIONS_TABLE", "reconciliations"
+        )
+        self._alerts_table = os.environ.get("ALERTS_TABLE", "alerts")
+        self._shares_table = os.environ.get("SHARES_TABLE", "shares")
+        self._usage_table = os.environ.get("USAGE_TABLE", "usage")
+        self._views_table = os.environ.get("VIEWS_TABLE", "views")
+        self._undo_table = os.environ.get("UNDO_TABLE", "undo")
+        self._categories_table = os.environ.get("CATEGORIES_TABLE", "categories")
+        self._metrics_table = os.environ.get("METRICS_TABLE", "metrics")
+
+        # Tables configured names are switched to; see switch_tables
+        self._table_routes: dict[str, str] = {}
+        self._table_routes_read = float("-inf")
+
+        # Names of the household's categories; see category_names
+        self._category_names: list[str] = []
+        self._category_names_read = float("-inf")
+
+        # Told the table and month of transactions after each write that changes
+        # them, with None for the month when every month may have changed
+        self.on_transactions_changed: Callable[[str, str | None], None] | None = None
+
+    def _credential(self) -> Any:
+        """The credential the table service is reached with."""
+        # Azurite well-known credentials
+        if self._table_service_url.startswith("http://"):
+            return AzureNamedKeyCredential("devstoreaccount1", AZURE_DEV_ACCOUNT_KEY)
+        return DefaultAzureCredential()
 
     def _get_table_client(self, table_name: str) -> TableClient:
-        """Returns a TableClient, ensuring the table exists. Cached per instance."""
+        """
+        Returns a TableClient for the table a configured name is switched to,
+        ensuring it exists. Cached per instance, and instrumented to count its
+        requests towards the storage ops metric.
+        """
+        table_name = self._route(table_name)
         if table_name in self._table_clients:
             return self._table_clients[table_name]
 
-        # Azurite well-known credentials
-        if self._table_service_url.startswith("http://"):
-            client = TableClient(
-                endpoint=self._table_service_url,
-                table_name=table_name,
-                credential=AzureNamedKeyCredential(
-                    "devstoreaccount1",
-                    AZURE_DEV_ACCOUNT_KEY,
-                ),
-            )
-        else:
-            client = TableClient(
+        client = InstrumentedTableClient(
+            TableClient(
                 endpoint=self._table_service_url,
                 table_name=table_name,
-                credential=DefaultAzureCredential(),
+                credential=self._credential(),
             )
+        )
 
         try:
             client.create_table()
@@ -65,76 +223,321 @@ class DatabaseService:
         self._table_clients[table_name] = client
         return client
 
+    @property
+    def transactions_table(self) -> str:
+        """Name of the table transactions are stored in."""
+        return self._transactions_table
+
+    def _transactions_changed(self, partition_keys: Iterable[str] | None) -> None:
+        """
+        Reports the months of the partitions a write changed, or every month for
+        None, to on_transactions_changed. Reporting never fails a write.
+        """
+        if not self.on_transactions_changed:
+            return
+        months = (
+            [None]
+            if partition_keys is None
+            else sorted({pk.rsplit("_", 1)[-1] for pk in partition_keys})
+        )
+        try:
+            for month in months:
+                self.on_transactions_changed(self._transactions_table, month)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logger.error("Error reporting changed transactions: %s", e)
+
+    @property
+    def debts_table(self) -> str:
+        """Name of the table the debt ledger is stored in."""
+        return self._debts_table
+
+    def shadow(self, prefix: str) -> "DatabaseService":
+        """
+        A service over copies of every table named with a prefix, so data can be
+        restored and read back through the usual queries without touching the
+        live tables.
+        """
+        shadow = copy.copy(self)
+        shadow._table_clients = {}
+        shadow._category_names_read = float("-inf")
+        for attr, value in vars(self).items():
+            if attr.endswith("_table"):
+                setattr(shadow, attr, f"{prefix}{value}")
+        return shadow
+
+    def _route(self, table_name: str) -> str:
+        """
+        The table a configured table name is switched to. The routes are read
+        once per invocation (see table_routes_scope), or every TABLE_ROUTES_TTL
+        seconds outside one. The settings table holds the routes, so it's never
+        switched.
+        """
+        if table_name == self._settings_table:
+            return table_name
+        scope = _ROUTES_SCOPE.get()
+        if scope is not None:
+            if self._settings_table not in scope:
+                scope[self._settings_table] = self._load_table_routes()
+            return scope[self._settings_table].get(table_name, table_name)
+        if time.monotonic() - self._table_routes_read >= TABLE_ROUTES_TTL:
+            self._load_table_routes()
+        return self._table_routes.get(table_name, table_name)
+
+    def _load_table_routes(self) -> dict[str, str]:
+        """Reads the table routes, keeping the last read if they can't be."""
+        try:
+            self._table_routes = self._read_table_routes()[0]["routes"]
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logger.warning("Could not read table routes, keeping the last: %s", e)
+        self._table_routes_read = time.monotonic()
+        return self._table_routes
+
+    def _read_table_routes(self) -> tuple[dict[str, Any], str | None]:
+        """The table routes setting and its ETag, or empty routes and None."""
+        client = self._get_table_client(self._settings_table)
+        try:
+            entity = client.get_entity(
+                partition_key="SETTINGS", row_key=TABLE_ROUTES_SETTING
+            )
+        except ResourceNotFoundError:
+            return {"routes": {}, "previous": None, "switchedAt": None}, None
+        return json.loads(entity["Value"]), entity.metadata["etag"]
+
     def _generate_row_key(self, t: Transaction, occurrence_index: int = 0) -> str:
         """
         Generates a deterministic unique key for a transaction to handle deduplication logic.
         Uses an occurrence index to handle identical transactions strictly within
-        the same upload batch.
+        the same upload batch. Keyed on the amount charged, so a foreign currency
+        transaction keeps its key whether or not it has been converted yet.
         """
         # Deterministic part including occurrence index
         unique_string = (
-            f"{t.date.isoformat()}|{t.name}|{t.amount}|"
+            f"{t.date.isoformat()}|{t.name}|{_charged_amount(t)}|"
             f"{t.account_number}|{occurrence_index}"
         )
         return hashlib.sha256(unique_string.encode("utf-8")).hexdigest()
 
+    def _keyed_partitions(
+        self, transactions: list[Transaction], tenant: str = "default"
+    ) -> dict[str, list[tuple[Transaction, str]]]:
+        """
+        Groups transactions by PartitionKey (Tenant_Month) and pairs each with its RowKey.
+        Identical transactions within a partition get increasing occurrence indexes.
+        """
+        partitions = collections.defaultdict(list)
+        occurrences: dict[Any, int] = collections.defaultdict(int)
+        for t in transactions:
+            # Partition Strategy: Tenant_Month
+            pk = f"{tenant}_{t.date.strftime('%Y-%m')}"
+            # Track occurrences of identical transactions within this partition
+            # to ensure unique (but deterministic) RowKeys for duplicates in the same file.
+            txn_signature = (pk, t.date, t.name, _charged_amount(t), t.account_number)
+            occurrences[txn_signature] += 1
+            idx = occurrences[txn_signature] - 1
+            partitions[pk].append((t, self._generate_row_key(t, idx)))
+        return partitions
+
     def save_transactions(self, transactions: list[Transaction]) -> None:
         """
         Saves a list of transactions to Azure Table Storage using batched upserts.
         Groups by PartitionKey (Tenant_Month) first, then chunks into batches of 100.
+        Upserts merge, so owner overrides set on existing rows survive a re-import.
+
+        Partitions are written concurrently by up to SAVE_TRANSACTIONS_WORKERS
+        threads, each submitting its partition's batches in order.
         """
         if not transactions:
             return
 
         client = self._get_table_client(self._transactions_table)
         timestamp = datetime.now().isoformat()
+        partitions = self._keyed_partitions(transactions)
 
-        # Group by PartitionKey (Tenant_Month) to satisfy batch requirements
-        partitions = collections.defaultdict(list)
-        for t in transactions:
-            # Partition Strategy: Tenant_Month
-            pk = f"default_{t.date.strftime('%Y-%m')}"
-            partitions[pk].append(t)
+        workers = min(self._save_workers, len(partitions))
+        with ThreadPoolExecutor(max_workers=workers) as executor:
+            futures = [
+                # Each task runs in a copy of the caller's context, so its requests
+                # count towards the caller's storage operation
+                executor.submit(
+                    contextvars.copy_context().run,
+                    self._save_partition,
+                    client,
+                    pk,
+                    keyed,
+                    timestamp,
+                )
+                for pk, keyed in partitions.items()
+            ]
+            try:
+                for future in futures:
+                    future.result()
+            finally:
+                self._transactions_changed(partitions)
 
-        # Process each partition group
-        for pk, trans_list in partitions.items():
-            # Track occurrences of identical transactions within this partition
-            # to ensure unique (but deterministic) RowKeys for duplicates in the same file.
-            occurrences: dict[Any, int] = collections.defaultdict(int)
+    def _save_partition(
+        self,
+        client: TableClient,
+        pk: str,
+        keyed: list[tuple[Transaction, str]],
+        timestamp: str,
+    ) -> None:
+        """Upserts one partition's transactions in batches of BATCH_SIZE."""
+        for i in range(0, len(keyed), BATCH_SIZE):
+            batch = [
+                (
+                    "upsert",
+                    self._create_transaction_entity(t, pk, row_key, timestamp),
+                    {"mode": UpdateMode.MERGE},
+                )
+                for t, row_key in keyed[i : i + BATCH_SIZE]
+            ]
 
-            # Chunk into batches of 100
-            for i in range(0, len(trans_list), 100):
-                chunk = trans_list[i : i + 100]
-                batch = []
+            try:
+                if batch:
+                    client.submit_transaction(batch)
+            except TableTransactionError as e:
+                logger.error("Failed to submit batch for partition %s: %s", pk, e)
 
-                for t in chunk:
-                    # Calculate occurrence index for this specific transaction signature
-                    txn_signature = (t.date, t.name, t.amount, t.account_number)
-                    occurrences[txn_signature] += 1
-                    idx = occurrences[txn_signature] - 1
+    def apply_owner_overrides(
+        self, transactions: list[Transaction]
+    ) -> list[Transaction]:
+        """Returns the transactions with any stored owner override applied."""
+        client = self._get_table_client(self._transactions_table)
+        result = []
+        for pk, keyed in self._keyed_partitions(transactions).items():
+            overrides = {
+                e["RowKey"]: e["Owner"]
+                for e in client.query_entities(
+                    query_filter=f"PartitionKey eq '{pk}' and Owner ne ''",
+                    select=["RowKey", "Owner"],
+                )
+            }
+            result.extend(
+                dataclasses.replace(t, owner=overrides[row_key])
+                if row_key in overrides
+                else t
+                for t, row_key in keyed
+            )
+        return result
 
-                    # Add to batch as an "upsert" operation (REPLACE mode)
-                    batch.append(
-                        (
-                            "upsert",
-                            self._create_transaction_entity(
-                                t, pk, self._generate_row_key(t, idx), timestamp
-                            ),
-                            {"mode": UpdateMode.REPLACE},
-                        )
-                    )
+    def _find_transaction(
+        self, client: TableClient, transaction_id: str, tenant: str
+    ) -> dict[str, Any] | None:
+        """Returns the entity of the transaction with the given ID, if any."""
+        matches = list(
+            client.query_entities(
+                query_filter=(
+                    f"{self._prefix_filter(f'{tenant}_')} "
+                    f"and RowKey eq {_quoted(transaction_id)}"
+                )
+            )
+        )
+        return matches[0] if matches else None
 
-                try:
-                    if batch:
-                        client.submit_transaction(batch)
-                except TableTransactionError as e:
-                    logger.error("Failed to submit batch for partition %s: %s", pk, e)
+    def set_transaction_owner(
+        self,
+        transaction_id: str,
+        owner_email: str | None,
+        tenant: str = "default",
+        check: Callable[[dict[str, Any]], None] | None = None,
+    ) -> bool:
+        """
+        Sets (or with None, clears) the effective owner of a stored transaction.
+        check is called with the stored entity first and raises to refuse the
+        change. The write is conditional on the entity check saw, and retried if
+        another writer changed it in between.
+        Returns False if no transaction has the given ID (RowKey).
+        """
+        client = self._get_table_client(self._transactions_table)
+
+        def attempt() -> bool:
+            entity = self._find_transaction(client, transaction_id, tenant)
+            if entity is None:
+                return False
+            if check:
+                check(entity)
+            client.update_entity(
+                {
+                    "PartitionKey": entity["PartitionKey"],
+                    "RowKey": transaction_id,
+                    "Owner": owner_email or "",
+                },
+                mode=UpdateMode.MERGE,
+                etag=entity.metadata["etag"],
+                match_condition=MatchConditions.IfNotModified,
+            )
+            self._transactions_changed([entity["PartitionKey"]])
+            return True
+
+        return self._retrier.run(attempt)
+
+    def update_transaction(
+        self, transaction_id: str, changes: dict[str, Any], tenant: str = "default"
+    ) -> dict[str, Any] | None:
+        """
+        Merges the given entity properties onto a stored transaction and returns
+        the entity it was before. Returns None if no transaction has the given ID
+        (RowKey).
+        """
+        client = self._get_table_client(self._transactions_table)
+        entity = self._find_transaction(client, transaction_id, tenant)
+        if entity is None:
+            return None
+
+        client.update_entity(
+            {
+                "PartitionKey": entity["PartitionKey"],
+                "RowKey": transaction_id,
+                **changes,
+            },
+            mode=UpdateMode.MERGE,
+        )
+        self._transactions_changed([entity["PartitionKey"]])
+        return dict(entity)
+
+    def delete_transaction(
+        self, transaction_id: str, tenant: str = "default"
+    ) -> dict[str, Any] | None:
+        """
+        Deletes a stored transaction and returns the entity it was, so it can be
+        restored. Returns None if no transaction has the given ID (RowKey).
+        """
+        client = self._get_table_client(self._transactions_table)
+        found = self._find_transaction(client, transaction_id, tenant)
+        if found is None:
+            return None
+
+        entity = dict(found)
+        client.delete_entity(
+            partition_key=entity["PartitionKey"], row_key=transaction_id
+        )
+        self._transactions_changed([entity["PartitionKey"]])
+        return entity
+
+    def restore_transactions(self, entities: list[dict[str, Any]]) -> int:
+        """
+        Puts deleted transaction entities back as they were, in batches grouped
+        by partition. Returns the number restored.
+        """
+        client = self._get_table_client(self._transactions_table)
+        partitions = collections.defaultdict(list)
+        for entity in entities:
+            partitions[entity["PartitionKey"]].append(entity)
+        try:
+            for partition in partitions.values():
+                for i in range(0, len(partition), BATCH_SIZE):
+                    client.submit_transaction(
+                        [("upsert", e) for e in partition[i : i + BATCH_SIZE]]
+                    )
+        finally:
+            self._transactions_changed(partitions)
+        return len(entities)
 
     def _create_transaction_entity(
         self, t: Transaction, partition_key: str, row_key: str, timestamp: str
     ) -> dict[str, Any]:
         """Helper to create a transaction entity dict."""
-        return {
+        entity = {
             "PartitionKey": partition_key,
             "RowKey": row_key,
             "Date": t.date.isoformat(),
@@ -146,6 +549,239 @@ class DatabaseService:
             "IgnoredFrom": t.ignore.value if t.ignore else None,
             "ImportedAt": timestamp,
         }
+        if t.currency:
+            entity["Currency"] = t.currency
+            # Flagged until a rate is available to convert it
+            entity["FxPending"] = t.original_amount is None
+            if t.original_amount is not None:
+                entity["OriginalAmount"] = float(t.original_amount)
+        return entity
+
+    def _entity_to_transaction(self, e: dict[str, Any]) -> Transaction:
+        """Helper to create a transaction from a transaction entity."""
+        return Transaction(
+            date.fromisoformat(e["Date"]),
+            e.get("Description", ""),
+            int(e["AccountNumber"]),
+            Decimal(str(e["Amount"])).quantize(Decimal("0.01")),
+            _known_category(e.get("Category"), self.category_names()),
+            IgnoredFrom(e.get("IgnoredFrom") or IgnoredFrom.NOTHING.value),
+            e.get("Owner") or None,
+            e.get("Currency") or None,
+            (
+                Decimal(str(e["OriginalAmount"])).quantize(Decimal("0.01"))
+                if e.get("OriginalAmount") is not None
+                else None
+            ),
+        )
+
+    def get_transactions(
+        self, month: str, tenant: str = "default"
+    ) -> list[Transaction]:
+        """Retrieves all stored transactions for a month (YYYY-MM)."""
+        return [t for _, t in self.get_keyed_transactions(month, tenant)]
+
+    def get_keyed_transactions(
+        self, month: str, tenant: str = "default"
+    ) -> list[tuple[str, Transaction]]:
+        """Retrieves a month's (YYYY-MM) stored transactions paired with their IDs."""
+        client = self._get_table_client(self._transactions_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_{month}'"
+        )
+        return [(e["RowKey"], self._entity_to_transaction(e)) for e in entities]
+
+    def get_manual_category_ids(
+        self, month: str, tenant: str = "default"
+    ) -> set[str]:
+        """Retrieves the IDs of a month's (YYYY-MM) transactions categorized by hand."""
+        client = self._get_table_client(self._transactions_table)
+        entities = client.query_entities(
+            query_filter=(
+                f"PartitionKey eq '{tenant}_{month}' "
+                f"and CategorySource eq '{MANUAL_CATEGORY}'"
+            ),
+            select=["RowKey"],
+        )
+        return {e["RowKey"] for e in entities}
+
+    def search_transactions(
+        self,
+        month: str,
+        min_amount: Decimal | None = None,
+        max_amount: Decimal | None = None,
+        start: date | None = None,
+        end: date | None = None,
+        tenant: str = "default",
+    ) -> list[tuple[str, Transaction]]:
+        """
+        Retrieves a month's (YYYY-MM) transactions paired with their IDs, with the
+        amount and date bounds (inclusive) applied by the query.
+        """
+        conditions = [f"PartitionKey eq '{tenant}_{month}'"]
+        if min_amount is not None:
+            conditions.append(f"Amount ge {float(min_amount)}")
+        if max_amount is not None:
+            conditions.append(f"Amount le {float(max_amount)}")
+        if start is not None:
+            conditions.append(f"Date ge '{start.isoformat()}'")
+        if end is not None:
+            conditions.append(f"Date le '{end.isoformat()}'")
+        client = self._get_table_client(self._transactions_table)
+        entities = client.query_entities(query_filter=" and ".join(conditions))
+        return [(e["RowKey"], self._entity_to_transaction(e)) for e in entities]
+
+    def iter_transactions(
+        self, months: list[str], tenant: str = "default"
+    ) -> Iterator[Transaction]:
+        """
+        Yields the stored transactions of each month (YYYY-MM) in turn as the pager
+        fetches them, so a long range is never held in memory at once.
+        """
+        client = self._get_table_client(self._transactions_table)
+        for month in months:
+            entities = client.query_entities(
+                query_filter=f"PartitionKey eq '{tenant}_{month}'",
+                results_per_page=EXPORT_PAGE_SIZE,
+            )
+            for e in entities:
+                yield self._entity_to_transaction(e)
+
+    def update_transactions(
+        self,
+        month: str,
+        changes: dict[str, dict[str, Any]],
+        tenant: str = "default",
+        expected: dict[str, Any] | None = None,
+    ) -> int:
+        """
+        Merges entity properties onto a month's stored transactions, keyed by
+        transaction ID, in batches of 100. With expected, only transactions still
+        holding those property values are updated; missing properties count as
+        empty. Returns the number of transactions updated; a failed batch is
+        logged and skipped.
+        """
+        if not changes:
+            return 0
+
+        client = self._get_table_client(self._transactions_table)
+        pk = f"{tenant}_{month}"
+        if expected is not None:
+            current = {
+                e["RowKey"]: e
+                for e in client.query_entities(
+                    query_filter=f"PartitionKey eq '{pk}'",
+                    select=["RowKey", *expected],
+                )
+            }
+            changes = {
+                row_key: properties
+                for row_key, properties in changes.items()
+                if row_key in current
+                and all(
+                    (current[row_key].get(column) or "") == (value or "")
+                    for column, value in expected.items()
+                )
+            }
+        items = list(changes.items())
+        updated = 0
+        for i in range(0, len(items), 100):
+            batch = [
+                (
+                    "update",
+                    {"PartitionKey": pk, "RowKey": row_key, **properties},
+                    {"mode": UpdateMode.MERGE},
+                )
+                for row_key, properties in items[i : i + 100]
+            ]
+            try:
+                client.submit_transaction(batch)
+                updated += len(batch)
+            except TableTransactionError as e:
+                logger.error("Failed to submit batch for partition %s: %s", pk, e)
+        if updated:
+            self._transactions_changed([pk])
+        return updated
+
+    def recategorize_transactions(
+        self,
+        transactions: list[Transaction],
+        override: bool = False,
+        tenant: str = "default",
+    ) -> dict[str, int]:
+        """
+        Sets stored transactions' categories to those of the given transactions,
+        matched on the RowKeys save_transactions gives them. Nothing else on the
+        stored rows changes, and transactions that aren't stored aren't added.
+        Categories set by hand are kept unless override is set. Returns how many
+        were matched, updated, kept as locked and missing.
+        """
+        client = self._get_table_client(self._transactions_table)
+        result = {"matched": 0, "updated": 0, "locked": 0, "missing": 0}
+        for pk, keyed in self._keyed_partitions(transactions, tenant).items():
+            stored = {
+                e["RowKey"]: e
+                for e in client.query_entities(
+                    query_filter=f"PartitionKey eq '{pk}'",
+                    select=["RowKey", "Category", "CategorySource"],
+                )
+            }
+            changes = {}
+            for t, row_key in keyed:
+                if row_key not in stored:
+                    result["missing"] += 1
+                    continue
+                result["matched"] += 1
+                entity = stored[row_key]
+                if entity.get("Category") == t.category.value:
+                    continue
+                if not override and entity.get("CategorySource") == MANUAL_CATEGORY:
+                    result["locked"] += 1
+                else:
+                    changes[row_key] = {
+                        "Category": t.category.value,
+                        "CategorySource": "",
+                    }
+            month = pk[len(tenant) + 1 :]
+            result["updated"] += self.update_transactions(month, changes, tenant)
+        return result
+
+    def get_spending_totals(
+        self, month: str, tenant: str = "default"
+    ) -> list[dict[str, Any]]:
+        """
+        Aggregates a month's (YYYY-MM) spend by category, account and owner override.
+        Ignored transactions are left out. Each row has Category, AccountNumber,
+        Owner (None when unset), Total and Count.
+        """
+        client = self._get_table_client(self._transactions_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_{month}'",
+            select=["Amount", "AccountNumber", "Category", "IgnoredFrom", "Owner"],
+        )
+        added = self.category_names()
+        totals: dict[tuple, dict[str, Any]] = {}
+        for e in entities:
+            if e.get("IgnoredFrom"):
+                continue
+            key = (
+                _known_category(e.get("Category"), added).value,
+                int(e["AccountNumber"]),
+                e.get("Owner") or None,
+            )
+            row = totals.setdefault(
+                key,
+                {
+                    "Category": key[0],
+                    "AccountNumber": key[1],
+                    "Owner": key[2],
+                    "Total": Decimal("0.00"),
+                    "Count": 0,
+                },
+            )
+            row["Total"] += Decimal(str(e["Amount"])).quantize(Decimal("0.01"))
+            row["Count"] += 1
+        return list(totals.values())
 
     def get_savings(self, month: str, user_id: str) -> dict[str, object] | None:
         """
@@ -155,7 +791,7 @@ class DatabaseService:
         partition_key = f"{user_id}_{month}"
 
         entities = client.query_entities(
-            query_filter=f"PartitionKey eq '{partition_key}'"
+            query_filter=f"PartitionKey eq {_quoted(partition_key)}"
         )
 
         items: list[dict[str, object]] = []
@@ -167,9 +803,10 @@ class DatabaseService:
             if entity["RowKey"] == "SUMMARY":
                 result["startingBalance"] = entity.get("StartingBalance", 0.0)
             elif entity["RowKey"].startswith("ITEM_"):
-                items.append(
-                    {"name": entity.get("Name", ""), "cost": entity.get("Cost", 0.0)}
-                )
+                item = {"name": entity.get("Name", ""), "cost": entity.get("Cost", 0.0)}
+                if entity.get("OneOff"):
+                    item["oneOff"] = True
+                items.append(item)
 
         if not found_any:
             return None
@@ -188,7 +825,7 @@ class DatabaseService:
         # Fetch existing entities to delete
         existing_entities = list(
             client.query_entities(
-                query_filter=f"PartitionKey eq '{partition_key}'",
+                query_filter=f"PartitionKey eq {_quoted(partition_key)}",
                 select=["PartitionKey", "RowKey"],
             )
         )
@@ -268,6 +905,7 @@ class DatabaseService:
                                 "RowKey": row_key,
                                 "Name": item.get("name", ""),
                                 "Cost": float(item.get("cost", 0)),  # type: ignore
+                                "OneOff": bool(item.get("oneOff", False)),
                             },
                         )
                     )
@@ -276,7 +914,10 @@ class DatabaseService:
     def save_person(self, person: dict) -> None:
         """
         Saves a person to the People table.
-        person dict must have: Name, Email, Accounts (list[int]).
+        person dict must have: Name, Email, Accounts (list[int]), and may have
+        AccountWeights (account number as a string to weight) for joint accounts,
+        SplitShare (their share of shared spend) and CategoryShares (category to
+        share) for an uneven split.
         """
         client = self._get_table_client(self._people_table)
 
@@ -287,6 +928,9 @@ class DatabaseService:
             "Email": person["Email"],
             # Azure Tables doesn't support lists, store as JSON string
             "Accounts": json.dumps(person["Accounts"]),
+            "AccountWeights": json.dumps(person.get("AccountWeights", {})),
+            "SplitShare": json.dumps(person.get("SplitShare")),
+            "CategoryShares": json.dumps(person.get("CategoryShares", {})),
         }
 
         try:
@@ -298,7 +942,9 @@ class DatabaseService:
     def get_all_people(self) -> list[dict]:
         """
         Retrieves all people from the database.
-        Returns a list of dicts with keys: Name, Email, Accounts (list[int]).
+        Returns a list of dicts with keys: Name, Email, Accounts (list[int]),
+        AccountWeights (dict of account number as a string to weight), SplitShare
+        (None for an even split) and CategoryShares (dict of category to share).
         """
         client = self._get_table_client(self._people_table)
         people = []
@@ -311,6 +957,13 @@ class DatabaseService:
                         "Name": entity.get("Name"),
                         "Email": entity.get("Email", entity["RowKey"]),
                         "Accounts": json.loads(entity.get("Accounts", "[]")),
+                        "AccountWeights": json.loads(
+                            entity.get("AccountWeights") or "{}"
+                        ),
+                        "SplitShare": json.loads(entity.get("SplitShare") or "null"),
+                        "CategoryShares": json.loads(
+                            entity.get("CategoryShares") or "{}"
+                        ),
                     }
                 )
         except Exception as e:  # pylint: disable=broad-except
@@ -319,3 +972,2321 @@ class DatabaseService:
             return []
 
         return people
+
+    def delete_person(self, email: str) -> bool:
+        """
+        Deletes a person from the People table.
+        Returns False if no person has the given email (RowKey).
+        """
+        client = self._get_table_client(self._people_table)
+        try:
+            client.get_entity(partition_key="PEOPLE", row_key=email)
+        except ResourceNotFoundError:
+            return False
+
+        client.delete_entity(partition_key="PEOPLE", row_key=email)
+        return True
+
+    def add_person_account(
+        self, email: str, account_number: int, weight: float | None = None
+    ) -> list[int] | None:
+        """
+        Associates an account number with a person, with their weight on it when
+        it is a joint account. Without a weight any earlier one is cleared.
+        Returns the person's accounts, or None if no person has the given email.
+        """
+
+        def update(
+            accounts: list[int], weights: dict[str, float]
+        ) -> tuple[list[int], dict[str, float]]:
+            weights = {a: w for a, w in weights.items() if a != str(account_number)}
+            if weight is not None:
+                weights[str(account_number)] = weight
+            return sorted({*accounts, account_number}), weights
+
+        return self._update_person_accounts(email, update)
+
+    def remove_person_account(
+        self, email: str, account_number: int
+    ) -> list[int] | None:
+        """
+        Removes an account number from a person.
+        Returns the person's accounts, or None if no person has the given email.
+        """
+        return self._update_person_accounts(
+            email,
+            lambda accounts, weights: (
+                [a for a in accounts if a != account_number],
+                {a: w for a, w in weights.items() if a != str(account_number)},
+            ),
+        )
+
+    def _update_person_accounts(
+        self,
+        email: str,
+        update: Callable[
+            [list[int], dict[str, float]], tuple[list[int], dict[str, float]]
+        ],
+    ) -> list[int] | None:
+        """
+        Applies update to a person's accounts and account weights and saves them,
+        retrying if another writer changed the person in between.
+        """
+        client = self._get_table_client(self._people_table)
+
+        def attempt() -> list[int] | None:
+            try:
+                entity = client.get_entity(partition_key="PEOPLE", row_key=email)
+            except ResourceNotFoundError:
+                return None
+
+            accounts, weights = update(
+                json.loads(entity.get("Accounts", "[]")),
+                json.loads(entity.get("AccountWeights") or "{}"),
+            )
+            client.update_entity(
+                {
+                    "PartitionKey": "PEOPLE",
+                    "RowKey": email,
+                    "Accounts": json.dumps(accounts),
+                    "AccountWeights": json.dumps(weights),
+                },
+                mode=UpdateMode.MERGE,
+                etag=entity.metadata["etag"],
+                match_condition=MatchConditions.IfNotModified,
+            )
+            return accounts
+
+        return self._retrier.run(attempt)
+
+    def save_ledger_entry(self, entry: LedgerEntry, tenant: str = "default") -> None:
+        """Records a debt ledger entry, replacing any entry with the same ID."""
+        client = self._get_table_client(self._debts_table)
+        client.upsert_entity(
+            entry.to_entity(f"{tenant}_LEDGER"), mode=UpdateMode.REPLACE
+        )
+
+    def get_ledger_entries(self, tenant: str = "default") -> list[LedgerEntry]:
+        """Retrieves every debt ledger entry for the tenant."""
+        client = self._get_table_client(self._debts_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_LEDGER'"
+        )
+        return [LedgerEntry.from_entity(e) for e in entities]
+
+    def save_health_score(
+        self, user_id: str, day: date, month: str, result: dict[str, object]
+    ) -> None:
+        """Records a user's health score for a day, replacing an earlier run that day."""
+        client = self._get_table_client(self._health_table)
+        client.upsert_entity(
+            {
+                "PartitionKey": user_id,
+                "RowKey": day.isoformat(),
+                "Month": month,
+                "Score": result["score"],
+                # Azure Tables doesn't support nested objects, store as JSON string
+                "Factors": json.dumps(result["factors"]),
+            },
+            mode=UpdateMode.REPLACE,
+        )
+
+    def get_health_scores(self, user_id: str) -> list[dict[str, object]]:
+        """Retrieves a user's recorded health scores, oldest first."""
+        client = self._get_table_client(self._health_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq {_quoted(user_id)}"
+        )
+        history = [
+            {"date": e["RowKey"], "month": e.get("Month"), "score": e.get("Score")}
+            for e in entities
+        ]
+        return sorted(history, key=lambda h: str(h["date"]))
+
+    def upsert_accounts(self, user_id: str, accounts: list[dict[str, Any]]) -> int:
+        """
+        Saves externally synced accounts for a user using batched upserts, keyed by
+        the account's external ID. Fields a payload omits are left unchanged.
+        Returns the number of accounts saved.
+        """
+        if not accounts:
+            return 0
+
+        client = self._get_table_client(self._accounts_table)
+        synced_at = datetime.now().isoformat()
+        entities = [
+            {
+                "PartitionKey": user_id,
+                "RowKey": a["accountId"],
+                **{
+                    column: a[field]
+                    for field, column in (
+                        ("name", "Name"),
+                        ("institution", "Institution"),
+                        ("mask", "Mask"),
+                        ("balance", "Balance"),
+                        ("limit", "Limit"),
+                        ("dueDay", "DueDay"),
+                        ("statementCloseDay", "StatementCloseDay"),
+                        ("annualFee", "AnnualFee"),
+                        ("feeMonth", "FeeMonth"),
+                        ("apr", "Apr"),
+                        ("promoApr", "PromoApr"),
+                        ("promoExpiry", "PromoExpiry"),
+                        ("rewardRate", "RewardRate"),
+                        ("minimumPayment", "MinimumPayment"),
+                    )
+                    if a.get(field) is not None
+                },
+                # Azure Tables doesn't support maps, store as JSON string
+                **(
+                    {"CategoryRewardRates": json.dumps(a["categoryRewardRates"])}
+                    if a.get("categoryRewardRates") is not None
+                    else {}
+                ),
+                **(
+                    {"ReminderLeadDays": json.dumps(a["reminderLeadDays"])}
+                    if a.get("reminderLeadDays") is not None
+                    else {}
+                ),
+                "SyncedAt": synced_at,
+            }
+            for a in accounts
+        ]
+
+        saved = 0
+        for i in range(0, len(entities), 100):
+            chunk = entities[i : i + 100]
+            try:
+                client.submit_transaction(
+                    [("upsert", e, {"mode": UpdateMode.MERGE}) for e in chunk]
+                )
+                saved += len(chunk)
+            except TableTransactionError as e:
+                logger.error("Failed to upsert accounts for %s: %s", user_id, e)
+        return saved
+
+    def get_accounts(self, user_id: str) -> list[dict[str, Any]]:
+        """Retrieves a user's synced accounts."""
+        client = self._get_table_client(self._accounts_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq {_quoted(user_id)}"
+        )
+        return [
+            {
+                "accountId": e["RowKey"],
+                "name": e.get("Name"),
+                "institution": e.get("Institution"),
+                "mask": e.get("Mask"),
+                "balance": e.get("Balance"),
+                "limit": e.get("Limit"),
+                "dueDay": e.get("DueDay"),
+                "statementCloseDay": e.get("StatementCloseDay"),
+                "statementBalance": e.get("StatementBalance"),
+                "lastStatementDate": e.get("LastStatementDate"),
+                "annualFee": e.get("AnnualFee"),
+                "feeMonth": e.get("FeeMonth"),
+                "apr": e.get("Apr"),
+                "promoApr": e.get("PromoApr"),
+                "promoExpiry": e.get("PromoExpiry"),
+                "rewardRate": e.get("RewardRate"),
+                "minimumPayment": e.get("MinimumPayment"),
+                "categoryRewardRates": json.loads(e.get("CategoryRewardRates", "{}")),
+                "reminderLeadDays": json.loads(e.get("ReminderLeadDays", "[]")),
+                "syncedAt": e.get("SyncedAt"),
+                "lastImportedDate": e.get("LastImportedDate"),
+                "lastReconciledDate": e.get("LastReconciledDate"),
+                "reconciledBalance": e.get("ReconciledBalance"),
+            }
+            for e in entities
+        ]
+
+    def record_account_import(
+        self, user_id: str, account_id: str, imported_date: date
+    ) -> bool:
+        """
+        Advances a synced account's LastImportedDate, the newest transaction date
+        imported for it. Backfilling older statements never moves it back, even
+        when queue processors race: the write only succeeds if the account is
+        unchanged since it was read, and is retried otherwise. Returns True if the
+        date changed.
+        """
+        client = self._get_table_client(self._accounts_table)
+
+        def attempt() -> bool:
+            try:
+                entity = client.get_entity(partition_key=user_id, row_key=account_id)
+            except ResourceNotFoundError:
+                return False
+            if (entity.get("LastImportedDate") or "") >= imported_date.isoformat():
+                return False
+            client.update_entity(
+                {
+                    "PartitionKey": user_id,
+                    "RowKey": account_id,
+                    "LastImportedDate": imported_date.isoformat(),
+                },
+                mode=UpdateMode.MERGE,
+                etag=entity.metadata["etag"],
+                match_condition=MatchConditions.IfNotModified,
+            )
+            return True
+
+        return self._retrier.run(attempt)
+
+    def adjust_account_balance(
+        self, user_id: str, account_id: str, delta: Decimal
+    ) -> bool:
+        """
+        Adds delta to a synced account's balance, retrying if another writer changed
+        the account in between. Returns False if the user has no such account or
+        its balance isn't known.
+        """
+        client = self._get_table_client(self._accounts_table)
+
+        def attempt() -> bool:
+            try:
+                entity = client.get_entity(partition_key=user_id, row_key=account_id)
+            except ResourceNotFoundError:
+                return False
+            if entity.get("Balance") is None:
+                return False
+            balance = Decimal(str(entity["Balance"])) + delta
+            client.update_entity(
+                {
+                    "PartitionKey": user_id,
+                    "RowKey": account_id,
+                    "Balance": float(balance.quantize(Decimal("0.01"))),
+                },
+                mode=UpdateMode.MERGE,
+                etag=entity.metadata["etag"],
+                match_condition=MatchConditions.IfNotModified,
+            )
+            return True
+
+        return self._retrier.run(attempt)
+
+    def reconcile_account(
+        self,
+        user_id: str,
+        account_id: str,
+        balance: float,
+        reconciled_date: date,
+    ) -> bool:
+        """
+        Records that a synced account's balance was verified as of a date, e.g.
+        against a statement. Returns False if the user has no such account.
+        """
+        client = self._get_table_client(self._accounts_table)
+        try:
+            client.update_entity(
+                {
+                    "PartitionKey": user_id,
+                    "RowKey": account_id,
+                    "ReconciledBalance": balance,
+                    "LastReconciledDate": reconciled_date.isoformat(),
+                },
+                mode=UpdateMode.MERGE,
+            )
+        except ResourceNotFoundError:
+            return False
+        return True
+
+    def record_reconciliation(
+        self, user_id: str, account_id: str, record: dict[str, Any]
+    ) -> None:
+        """
+        Adds a reconcile attempt ({"statementDate", "balance", "expectedBalance",
+        "discrepancy", "status", "transactions", "since", "reconciled",
+        "reconciledBy"}) to a card's reconciliation audit trail.
+        """
+        client = self._get_table_client(self._reconciliations_table)
+        client.create_entity(
+            {
+                "PartitionKey": f"{user_id}_{account_id}",
+                # Sorts by statement date, then attempt
+                "RowKey": f"{record['statementDate']}_{uuid.uuid4().hex[:8]}",
+                "StatementDate": record["statementDate"],
+                "Balance": record["balance"],
+                **(
+                    {
+                        "ExpectedBalance": record["expectedBalance"],
+                        "Discrepancy": record["discrepancy"],
+                        "Since": record["since"],
+                    }
+                    if record.get("expectedBalance") is not None
+                    else {}
+                ),
+                "Status": record["status"],
+                "TransactionCount": record["transactions"],
+                "Reconciled": record["reconciled"],
+                "ReconciledBy": record["reconciledBy"],
+                "RecordedAt": datetime.now().isoformat(),
+            }
+        )
+
+    def get_reconciliations(
+        self, user_id: str, account_id: str
+    ) -> list[dict[str, Any]]:
+        """Retrieves a card's reconciliation audit trail, newest first."""
+        client = self._get_table_client(self._reconciliations_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq {_quoted(f'{user_id}_{account_id}')}"
+        )
+        records = [
+            {
+                "statementDate": e.get("StatementDate"),
+                "balance": e.get("Balance"),
+                "expectedBalance": e.get("ExpectedBalance"),
+                "discrepancy": e.get("Discrepancy"),
+                "since": e.get("Since"),
+                "status": e.get("Status"),
+                "transactions": e.get("TransactionCount"),
+                "reconciled": e.get("Reconciled"),
+                "reconciledBy": e.get("ReconciledBy"),
+                "recordedAt": e.get("RecordedAt"),
+            }
+            for e in entities
+        ]
+        return sorted(
+            records, key=lambda r: (r["statementDate"], r["recordedAt"]), reverse=True
+        )
+
+    def close_statement(
+        self,
+        user_id: str,
+        account_id: str,
+        statement: dict[str, Any],
+    ) -> bool:
+        """
+        Records a card's statement ({"closeDate", "cycleStart", "balance",
+        "limit"}) in its history and rolls the card's StatementBalance over to it.
+        The history row is written first and the card's LastStatementDate, which
+        marks the statement taken, last, so a close that failed in between is
+        finished from the recorded row when retried. Returns False if that
+        statement was already taken.
+        """
+        history = self._get_table_client(self._statements_table)
+        partition_key = f"{user_id}_{account_id}"
+        try:
+            history.create_entity(
+                {
+                    "PartitionKey": partition_key,
+                    "RowKey": statement["closeDate"],
+                    "CycleStart": statement["cycleStart"],
+                    "Balance": statement["balance"],
+                    **(
+                        {"Limit": statement["limit"]}
+                        if statement.get("limit") is not None
+                        else {}
+                    ),
+                    "ClosedAt": datetime.now().isoformat(),
+                }
+            )
+            balance = statement["balance"]
+        except ResourceExistsError:
+            # Recorded by an earlier attempt, whose balance the card rolls over to
+            balance = history.get_entity(
+                partition_key=partition_key, row_key=statement["closeDate"]
+            )["Balance"]
+
+        client = self._get_table_client(self._accounts_table)
+
+        def attempt() -> bool:
+            entity = client.get_entity(partition_key=user_id, row_key=account_id)
+            if (entity.get("LastStatementDate") or "") >= statement["closeDate"]:
+                return False
+            client.update_entity(
+                {
+                    "PartitionKey": user_id,
+                    "RowKey": account_id,
+                    "StatementBalance": balance,
+                    "LastStatementDate": statement["closeDate"],
+                },
+                mode=UpdateMode.MERGE,
+                etag=entity.metadata["etag"],
+                match_condition=MatchConditions.IfNotModified,
+            )
+            return True
+
+        return self._retrier.run(attempt)
+
+    def get_statements(self, user_id: str, account_id: str) -> list[dict[str, Any]]:
+        """Retrieves a card's statement history, newest first."""
+        client = self._get_table_client(self._statements_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq {_quoted(f'{user_id}_{account_id}')}"
+        )
+        statements = [
+            {
+                "closeDate": e["RowKey"],
+                "cycleStart": e.get("CycleStart"),
+                "balance": e.get("Balance"),
+                "limit": e.get("Limit"),
+                "closedAt": e.get("ClosedAt"),
+            }
+            for e in entities
+        ]
+        return sorted(statements, key=lambda s: s["closeDate"], reverse=True)
+
+    def save_subscription(
+        self, subscription: dict[str, Any], tenant: str = "default"
+    ) -> None:
+        """
+        Saves a detected recurring charge with its price change history, replacing
+        the stored record for the merchant.
+        """
+        client = self._get_table_client(self._subscriptions_table)
+        merchant = subscription["merchant"]
+        client.upsert_entity(
+            {
+                "PartitionKey": f"{tenant}_SUBSCRIPTIONS",
+                # Merchant names can contain characters RowKeys don't allow
+                "RowKey": hashlib.sha256(merchant.encode("utf-8")).hexdigest()[:32],
+                "Merchant": merchant,
+                "CadenceDays": subscription["cadenceDays"],
+                "LastDate": subscription["lastDate"],
+                "LastAmount": subscription["lastAmount"],
+                "AverageAmount": subscription["averageAmount"],
+                # Azure Tables doesn't support lists, store as JSON string
+                "PriceHistory": json.dumps(subscription["priceHistory"]),
+            },
+            mode=UpdateMode.REPLACE,
+        )
+
+    def get_subscriptions(self, tenant: str = "default") -> list[dict[str, Any]]:
+        """Retrieves the stored recurring charges, by merchant name."""
+        client = self._get_table_client(self._subscriptions_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_SUBSCRIPTIONS'"
+        )
+        subscriptions = [
+            {
+                "merchant": e["Merchant"],
+                "cadenceDays": e.get("CadenceDays"),
+                "lastDate": e.get("LastDate"),
+                "lastAmount": e.get("LastAmount"),
+                "averageAmount": e.get("AverageAmount"),
+                "priceHistory": json.loads(e.get("PriceHistory", "[]")),
+            }
+            for e in entities
+        ]
+        return sorted(subscriptions, key=lambda s: s["merchant"])
+
+    def record_alert(
+        self,
+        alert_id: str,
+        alert: dict[str, Any],
+        tenant: str = "default",
+    ) -> None:
+        """
+        Records that an alert ({"kind", "key", "subject", "recipients"}) was sent,
+        replacing its last send. A sent alert is new, as any snooze has ended.
+        """
+        client = self._get_table_client(self._alerts_table)
+        client.upsert_entity(
+            {
+                "PartitionKey": f"{tenant}_ALERTS",
+                "RowKey": alert_id,
+                "Kind": alert["kind"],
+                "Key": alert["key"],
+                "Subject": alert["subject"],
+                "Recipients": json.dumps(alert["recipients"]),
+                "Status": "new",
+                "SentAt": datetime.now().isoformat(),
+            },
+            mode=UpdateMode.REPLACE,
+        )
+
+    @staticmethod
+    def _entity_to_alert(entity: dict[str, Any]) -> dict[str, Any]:
+        return {
+            "id": entity["RowKey"],
+            "kind": entity.get("Kind"),
+            "key": entity.get("Key"),
+            "subject": entity.get("Subject"),
+            "recipients": json.loads(entity.get("Recipients", "[]")),
+            "status": entity.get("Status", "new"),
+            "snoozedUntil": entity.get("SnoozedUntil"),
+            "sentAt": entity.get("SentAt"),
+            "updatedBy": entity.get("UpdatedBy"),
+            "updatedAt": entity.get("UpdatedAt"),
+        }
+
+    def get_alerts(self, tenant: str = "default") -> list[dict[str, Any]]:
+        """Retrieves every alert that has been sent, most recently sent first."""
+        client = self._get_table_client(self._alerts_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_ALERTS'"
+        )
+        alerts = [self._entity_to_alert(e) for e in entities]
+        return sorted(alerts, key=lambda a: a["sentAt"] or "", reverse=True)
+
+    def get_alert(
+        self, alert_id: str, tenant: str = "default"
+    ) -> dict[str, Any] | None:
+        """Returns a sent alert, or None if there is none with the ID."""
+        client = self._get_table_client(self._alerts_table)
+        try:
+            entity = client.get_entity(
+                partition_key=f"{tenant}_ALERTS", row_key=alert_id
+            )
+        except ResourceNotFoundError:
+            return None
+        return self._entity_to_alert(entity)
+
+    def set_alert_status(
+        self,
+        alert_id: str,
+        status: str,
+        snoozed_until: str | None,
+        updated_by: str,
+        tenant: str = "default",
+    ) -> None:
+        """
+        Moves an alert to a new status, snoozed until a date or not. Replaced
+        rather than merged, so a reopened alert loses its old snooze date.
+        """
+        client = self._get_table_client(self._alerts_table)
+        entity = client.get_entity(partition_key=f"{tenant}_ALERTS", row_key=alert_id)
+        entity = {
+            k: v for k, v in entity.items() if k not in ("SnoozedUntil", "Status")
+        }
+        entity["Status"] = status
+        if snoozed_until:
+            entity["SnoozedUntil"] = snoozed_until
+        entity["UpdatedBy"] = updated_by
+        entity["UpdatedAt"] = datetime.now().isoformat()
+        client.upsert_entity(entity, mode=UpdateMode.REPLACE)
+
+    def add_usage(
+        self, month: str, counter: str, count: int, tenant: str = "default"
+    ) -> None:
+        """
+        Adds to a month's (YYYY-MM) usage counter, e.g. EmailsSent, retrying if
+        another writer added to the month in between.
+        """
+        client = self._get_table_client(self._usage_table)
+
+        def attempt() -> None:
+            try:
+                entity = client.get_entity(
+                    partition_key=f"{tenant}_USAGE", row_key=month
+                )
+            except ResourceNotFoundError:
+                try:
+                    client.create_entity(
+                        {
+                            "PartitionKey": f"{tenant}_USAGE",
+                            "RowKey": month,
+                            counter: count,
+                        }
+                    )
+                except ResourceExistsError as e:
+                    raise ResourceModifiedError("Usage month was created") from e
+                return
+            client.update_entity(
+                {
+                    "PartitionKey": f"{tenant}_USAGE",
+                    "RowKey": month,
+                    counter: int(entity.get(counter, 0)) + count,
+                },
+                mode=UpdateMode.MERGE,
+                etag=entity.metadata["etag"],
+                match_condition=MatchConditions.IfNotModified,
+            )
+
+        self._retrier.run(attempt)
+
+    def save_metric_counts(
+        self, bucket: str, worker: str, counts: dict[str, dict[str, int]]
+    ) -> None:
+        """
+        Stores a worker's metric counts for a bucket, replacing those it stored for
+        the bucket before. Each worker only writes its own entities.
+        """
+        client = self._get_table_client(self._metrics_table)
+        client.upsert_entity(
+            {"PartitionKey": bucket, "RowKey": worker, "Counts": json.dumps(counts)},
+            mode=UpdateMode.REPLACE,
+        )
+
+    def get_metric_counts(
+        self, start: str, end: str | None = None
+    ) -> list[dict[str, dict[str, int]]]:
+        """
+        Returns every worker's metric counts for the buckets from start up to, but
+        not including, end (or the latest bucket when None).
+        """
+        client = self._get_table_client(self._metrics_table)
+        query_filter = f"PartitionKey ge {_quoted(start)}"
+        if end is not None:
+            query_filter += f" and PartitionKey lt {_quoted(end)}"
+        entities = client.query_entities(query_filter=query_filter, select=["Counts"])
+        return [json.loads(e["Counts"]) for e in entities]
+
+    def get_usage(self, month: str, tenant: str = "default") -> dict[str, int]:
+        """Returns a month's (YYYY-MM) usage counters, empty if nothing was counted."""
+        client = self._get_table_client(self._usage_table)
+        try:
+            entity = client.get_entity(partition_key=f"{tenant}_USAGE", row_key=month)
+        except ResourceNotFoundError:
+            return {}
+        return {
+            k: int(v)
+            for k, v in entity.items()
+            if k not in ("PartitionKey", "RowKey", "Timestamp")
+        }
+
+    def count_transactions(self, tenant: str = "default") -> int:
+        """Counts the tenant's stored transactions, reading only their keys."""
+        return len(
+            self._list_keys(self._transactions_table, self._prefix_filter(f"{tenant}_"))
+        )
+
+    def save_rule(self, rule: Rule, tenant: str = "default") -> None:
+        """Saves a category rule, replacing any rule with the same ID."""
+        client = self._get_table_client(self._rules_table)
+        client.upsert_entity(rule.to_entity(f"{tenant}_RULES"), mode=UpdateMode.REPLACE)
+
+    def get_rules(self, tenant: str = "default") -> list[Rule]:
+        """Retrieves the tenant's category rules in the order they run."""
+        client = self._get_table_client(self._rules_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_RULES'"
+        )
+        added = self.category_names()
+        rules = []
+        for e in entities:
+            if not Category.is_valid(e.get("Category"), added):
+                logger.warning("Skipping rule %s for unknown category", e["RowKey"])
+                continue
+            rules.append(Rule.from_entity(e, added))
+        return sorted(rules, key=lambda r: (r.priority, r.rule_id))
+
+    def delete_rule(self, rule_id: str, tenant: str = "default") -> bool:
+        """Deletes a category rule. Returns False if no rule has the given ID."""
+        client = self._get_table_client(self._rules_table)
+        try:
+            client.get_entity(partition_key=f"{tenant}_RULES", row_key=rule_id)
+        except ResourceNotFoundError:
+            return False
+        client.delete_entity(partition_key=f"{tenant}_RULES", row_key=rule_id)
+        return True
+
+    def save_connector(self, connector: Connector, tenant: str = "default") -> None:
+        """Saves a pull connector, replacing any connector with the same name."""
+        client = self._get_table_client(self._connectors_table)
+        client.upsert_entity(
+            connector.to_entity(f"{tenant}_CONNECTORS"), mode=UpdateMode.REPLACE
+        )
+
+    def get_connectors(self, tenant: str = "default") -> list[Connector]:
+        """Retrieves the tenant's pull connectors, by name."""
+        client = self._get_table_client(self._connectors_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_CONNECTORS'"
+        )
+        return sorted(
+            (Connector.from_entity(e) for e in entities), key=lambda c: c.name
+        )
+
+    def delete_connector(self, name: str, tenant: str = "default") -> bool:
+        """
+        Deletes a pull connector and its status. The files it pulled stay
+        recorded, so re-adding it doesn't pull them again.
+        Returns False if no connector has the given name.
+        """
+        client = self._get_table_client(self._connectors_table)
+        try:
+            client.get_entity(partition_key=f"{tenant}_CONNECTORS", row_key=name)
+        except ResourceNotFoundError:
+            return False
+        client.delete_entity(partition_key=f"{tenant}_CONNECTORS", row_key=name)
+        try:
+            client.delete_entity(
+                partition_key=f"{tenant}_CONNECTOR_STATUS", row_key=name
+            )
+        except ResourceNotFoundError:
+            pass
+        return True
+
+    def set_connector_status(
+        self,
+        name: str,
+        status: str,
+        files_pulled: int,
+        error: str | None = None,
+        tenant: str = "default",
+    ) -> None:
+        """Records the outcome of a connector's latest pull."""
+        client = self._get_table_client(self._connectors_table)
+        now = datetime.now().isoformat()
+        entity: dict[str, Any] = {
+            "PartitionKey": f"{tenant}_CONNECTOR_STATUS",
+            "RowKey": name,
+            "Status": status,
+            "LastRunAt": now,
+            "FilesPulled": files_pulled,
+            "Error": error or "",
+        }
+        if status == "succeeded":
+            entity["LastSuccessAt"] = now
+        client.upsert_entity(entity, mode=UpdateMode.MERGE)
+
+    def get_connector_statuses(
+        self, tenant: str = "default"
+    ) -> dict[str, dict[str, Any]]:
+        """Returns the outcome of each connector's latest pull, by connector name."""
+        client = self._get_table_client(self._connectors_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_CONNECTOR_STATUS'"
+        )
+        return {
+            e["RowKey"]: {
+                "status": e["Status"],
+                "lastRunAt": e["LastRunAt"],
+                "lastSuccessAt": e.get("LastSuccessAt"),
+                "filesPulled": e.get("FilesPulled", 0),
+                "error": e.get("Error") or None,
+            }
+            for e in entities
+        }
+
+    def get_pulled_files(self, name: str, tenant: str = "default") -> set[str]:
+        """Returns the keys of the file deliveries a connector has pulled."""
+        client = self._get_table_client(self._connectors_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq {_quoted(f'{tenant}_PULLED_{name}')}",
+            select=["RowKey"],
+        )
+        return {e["RowKey"] for e in entities}
+
+    def record_pulled_file(
+        self,
+        name: str,
+        key: str,
+        path: str,
+        blob_name: str,
+        tenant: str = "default",
+    ) -> None:
+        """Records that a connector pulled a file delivery, and the upload it became."""
+        client = self._get_table_client(self._connectors_table)
+        client.upsert_entity(
+            {
+                "PartitionKey": f"{tenant}_PULLED_{name}",
+                "RowKey": key,
+                "Path": path,
+                "BlobName": blob_name,
+                "PulledAt": datetime.now().isoformat(),
+            },
+            mode=UpdateMode.REPLACE,
+        )
+
+    def get_collected_file(
+        self, content_hash: str, tenant: str = "default"
+    ) -> dict[str, Any] | None:
+        """
+        Returns the upload a collected file with this content became and whether it
+        was queued, or None if no file with this content has been collected.
+        """
+        client = self._get_table_client(self._connectors_table)
+        try:
+            entity = client.get_entity(
+                partition_key=f"{tenant}_COLLECTED", row_key=content_hash
+            )
+        except ResourceNotFoundError:
+            return None
+        return {
+            "blobName": entity["BlobName"],
+            "source": entity.get("Source"),
+            "status": entity["Status"],
+            "updatedAt": entity["UpdatedAt"],
+        }
+
+    def record_collected_file(
+        self,
+        content_hash: str,
+        blob_name: str,
+        source: str,
+        status: str,
+        tenant: str = "default",
+    ) -> None:
+        """
+        Records a collected file's content hash against the upload it became, as
+        pending before it's queued and queued after.
+        """
+        client = self._get_table_client(self._connectors_table)
+        client.upsert_entity(
+            {
+                "PartitionKey": f"{tenant}_COLLECTED",
+                "RowKey": content_hash,
+                "BlobName": blob_name,
+                "Source": source,
+                "Status": status,
+                "UpdatedAt": datetime.now().isoformat(),
+            },
+            mode=UpdateMode.REPLACE,
+        )
+
+    def save_mailbox(self, mailbox: Mailbox, tenant: str = "default") -> None:
+        """Saves a mailbox connector, replacing any mailbox with the same name."""
+        client = self._get_table_client(self._connectors_table)
+        client.upsert_entity(
+            mailbox.to_entity(f"{tenant}_MAILBOXES"), mode=UpdateMode.REPLACE
+        )
+
+    def get_mailboxes(self, tenant: str = "default") -> list[Mailbox]:
+        """Retrieves the tenant's mailbox connectors, by name."""
+        client = self._get_table_client(self._connectors_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_MAILBOXES'"
+        )
+        return sorted((Mailbox.from_entity(e) for e in entities), key=lambda m: m.name)
+
+    def delete_mailbox(self, name: str, tenant: str = "default") -> bool:
+        """
+        Deletes a mailbox connector with its token and status. The messages it
+        fetched stay recorded, so re-adding it doesn't import them again.
+        Returns False if no mailbox has the given name.
+        """
+        client = self._get_table_client(self._connectors_table)
+        try:
+            client.get_entity(partition_key=f"{tenant}_MAILBOXES", row_key=name)
+        except ResourceNotFoundError:
+            return False
+        client.delete_entity(partition_key=f"{tenant}_MAILBOXES", row_key=name)
+        for partition_key, row_key in (
+            (f"{tenant}_MAILBOX_TOKENS", name),
+            (f"{tenant}_CONNECTOR_STATUS", f"mail:{name}"),
+        ):
+            try:
+                client.delete_entity(partition_key=partition_key, row_key=row_key)
+            except ResourceNotFoundError:
+                pass
+        return True
+
+    def get_mailbox_token(self, name: str, tenant: str = "default") -> dict[str, Any]:
+        """
+        Returns a mailbox's sealed OAuth refresh token and pending sign-in state,
+        as {"refreshToken", "authState", "authorizedAt"}, each None if unset.
+        """
+        client = self._get_table_client(self._connectors_table)
+        try:
+            entity = client.get_entity(
+                partition_key=f"{tenant}_MAILBOX_TOKENS", row_key=name
+            )
+        except ResourceNotFoundError:
+            entity = {}
+        return {
+            "refreshToken": entity.get("RefreshToken") or None,
+            "authState": entity.get("AuthState") or None,
+            "authorizedAt": entity.get("AuthorizedAt") or None,
+        }
+
+    def set_mailbox_auth_state(
+        self, name: str, state: str, tenant: str = "default"
+    ) -> None:
+        """Records the OAuth state of a sign-in started for a mailbox."""
+        client = self._get_table_client(self._connectors_table)
+        client.upsert_entity(
+            {
+                "PartitionKey": f"{tenant}_MAILBOX_TOKENS",
+                "RowKey": name,
+                "AuthState": state,
+            },
+            mode=UpdateMode.MERGE,
+        )
+
+    def save_mailbox_token(
+        self, name: str, refresh_token: str, tenant: str = "default"
+    ) -> None:
+        """
+        Stores a mailbox's OAuth refresh token, sealed by the caller, ending any
+        pending sign-in. It's kept apart from the mailbox, so it's never returned
+        by the API.
+        """
+        client = self._get_table_client(self._connectors_table)
+        client.upsert_entity(
+            {
+                "PartitionKey": f"{tenant}_MAILBOX_TOKENS",
+                "RowKey": name,
+                "RefreshToken": refresh_token,
+                "AuthState": "",
+                "AuthorizedAt": datetime.now().isoformat(),
+            },
+            mode=UpdateMode.MERGE,
+        )
+
+    def save_document(self, document: Document, tenant: str = "default") -> None:
+        """Records a stored document under its month."""
+        client = self._get_table_client(self._documents_table)
+        client.create_entity(document.to_entity(f"{tenant}_{document.month}"))
+
+    def get_documents(
+        self,
+        month: str,
+        account_number: int | None = None,
+        tenant: str = "default",
+    ) -> list[Document]:
+        """Retrieves a month's documents, optionally for one account, oldest first."""
+        client = self._get_table_client(self._documents_table)
+        query_filter = f"PartitionKey eq '{tenant}_{month}'"
+        if account_number is not None:
+            query_filter += f" and AccountNumber eq {int(account_number)}"
+        entities = client.query_entities(query_filter=query_filter)
+        return sorted(
+            (Document.from_entity(e) for e in entities), key=lambda d: d.uploaded_at
+        )
+
+    def get_document(
+        self, document_id: str, tenant: str = "default"
+    ) -> Document | None:
+        """Retrieves a document by id from any month, or None if there isn't one."""
+        client = self._get_table_client(self._documents_table)
+        entities = client.query_entities(
+            query_filter=(
+                f"{self._prefix_filter(f'{tenant}_')} "
+                f"and RowKey eq {_quoted(document_id)}"
+            )
+        )
+        entity = next(iter(entities), None)
+        return None if entity is None else Document.from_entity(entity)
+
+    def delete_document(self, document: Document, tenant: str = "default") -> None:
+        """Deletes a document's record."""
+        client = self._get_table_client(self._documents_table)
+        client.delete_entity(
+            partition_key=f"{tenant}_{document.month}", row_key=document.document_id
+        )
+
+    def save_budget(
+        self, category: AnyCategory, limit: Decimal, tenant: str = "default"
+    ) -> None:
+        """Sets the monthly spending limit for a category."""
+        client = self._get_table_client(self._budgets_table)
+        client.upsert_entity(
+            {
+                "PartitionKey": f"{tenant}_BUDGETS",
+                "RowKey": category.value,
+                "Limit": float(limit),
+            },
+            mode=UpdateMode.REPLACE,
+        )
+
+    def get_budgets(self, tenant: str = "default") -> dict[AnyCategory, Decimal]:
+        """Retrieves the monthly spending limit per budgeted category."""
+        client = self._get_table_client(self._budgets_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_BUDGETS'"
+        )
+        added = self.category_names()
+        return {
+            parse_category(e["RowKey"], added): Decimal(str(e["Limit"])).quantize(
+                Decimal("0.01")
+            )
+            for e in entities
+            if Category.is_valid(e["RowKey"], added)
+        }
+
+    def delete_budget(self, category: AnyCategory, tenant: str = "default") -> bool:
+        """Removes a category's budget. Returns False if it had none."""
+        client = self._get_table_client(self._budgets_table)
+        try:
+            client.get_entity(partition_key=f"{tenant}_BUDGETS", row_key=category.value)
+        except ResourceNotFoundError:
+            return False
+        client.delete_entity(partition_key=f"{tenant}_BUDGETS", row_key=category.value)
+        return True
+
+    def table_names(self) -> list[str]:
+        """Returns the name of every table the application uses."""
+        return [
+            self._transactions_table,
+            self._savings_table,
+            self._people_table,
+            self._settings_table,
+            self._api_keys_table,
+            self._debts_table,
+            self._health_table,
+            self._accounts_table,
+            self._subscriptions_table,
+            self._rules_table,
+            self._budgets_table,
+            self._invites_table,
+            self._activity_table,
+            self._failures_table,
+            self._jobs_table,
+            self._uploads_table,
+            self._processing_log_table,
+            self._exchange_rates_table,
+            self._connectors_table,
+            self._documents_table,
+            self._statements_table,
+            self._reconciliations_table,
+            self._alerts_table,
+            self._shares_table,
+            self._usage_table,
+            self._views_table,
+            self._undo_table,
+            self._categories_table,
+            self._metrics_table,
+        ]
+
+    def ensure_tables(self) -> list[str]:
+        """Creates every table the application uses if missing. Returns the table names."""
+        tables = self.table_names()
+        for table_name in tables:
+            self._get_table_client(table_name)
+        return tables
+
+    def restore_table(self, table_name: str, entities: list[dict[str, Any]]) -> int:
+        """
+        Replaces a table's contents with exported entities, in batched
+        transactions grouped by partition. Returns the number restored.
+        """
+        client = self._get_table_client(table_name)
+        self.delete_entities(
+            table_name,
+            [
+                {"PartitionKey": e["PartitionKey"], "RowKey": e["RowKey"]}
+                for e in client.list_entities(select=["PartitionKey", "RowKey"])
+            ],
+        )
+
+        partitions = collections.defaultdict(list)
+        for entity in entities:
+            partitions[entity["PartitionKey"]].append(entity)
+        try:
+            for partition in partitions.values():
+                for i in range(0, len(partition), BATCH_SIZE):
+                    client.submit_transaction(
+                        [("upsert", e) for e in partition[i : i + BATCH_SIZE]]
+                    )
+        finally:
+            if table_name == self._transactions_table:
+                self._transactions_changed(partitions)
+        return len(entities)
+
+    def list_tables(self, prefix: str) -> list[str]:
+        """Lists the names of the tables in the account that start with a prefix."""
+        upper = prefix[:-1] + chr(ord(prefix[-1]) + 1)
+        service = TableServiceClient(
+            endpoint=self._table_service_url, credential=self._credential()
+        )
+        return [
+            table.name
+            for table in service.query_tables(
+                f"TableName ge {_quoted(prefix)} and TableName lt {_quoted(upper)}"
+            )
+        ]
+
+    def drop_table(self, table_name: str) -> None:
+        """Deletes a table and everything in it, e.g. a restore rehearsal's copy."""
+        service = TableServiceClient(
+            endpoint=self._table_service_url, credential=self._credential()
+        )
+        service.delete_table(table_name)
+        self._table_clients.pop(table_name, None)
+
+    def count_entities(self, table_name: str) -> int:
+        """Counts the entities in a table."""
+        client = self._get_table_client(table_name)
+        keys = client.list_entities(select=["PartitionKey", "RowKey"])
+        return sum(1 for _ in keys)
+
+    def migrate_table(
+        self,
+        table_name: str,
+        target: str,
+        transform: Callable[[dict[str, Any]], dict[str, Any] | None],
+    ) -> int:
+        """
+        Writes a migration's output to a new table, to be switched to with
+        switch_tables once checked. Each entity of the configured table is passed
+        through transform, which returns the entity to write or None to drop it.
+        The live table is only read. The migration is recorded once every entity
+        is written, so a table it didn't finish can't be switched to. Returns the
+        number of entities written.
+        """
+        settings = self._get_table_client(self._settings_table)
+        settings.delete_entity(partition_key=MIGRATIONS_PARTITION, row_key=target)
+        source = self._get_table_client(table_name)
+        migrated = [transform(dict(e)) for e in source.list_entities()]
+        written = self.restore_table(target, [e for e in migrated if e is not None])
+        settings.upsert_entity(
+            {
+                "PartitionKey": MIGRATIONS_PARTITION,
+                "RowKey": target,
+                "Source": table_name,
+                "Entities": written,
+                "CompletedAt": datetime.now().isoformat(),
+            },
+            mode=UpdateMode.REPLACE,
+        )
+        return written
+
+    def switch_target_problem(self, target: str) -> str | None:
+        """
+        What stops a table name being switched to a table, if anything: it must
+        exist and hold every entity the migration that wrote it recorded.
+        """
+        if target not in self.list_tables(target):
+            return "does not exist"
+        settings = self._get_table_client(self._settings_table)
+        try:
+            migration = settings.get_entity(
+                partition_key=MIGRATIONS_PARTITION, row_key=target
+            )
+        except ResourceNotFoundError:
+            return "was not written by a finished migration"
+        count = self.count_entities(target)
+        if count != migration["Entities"]:
+            return f"holds {count} entities, not the {migration['Entities']} migrated"
+        return None
+
+    def table_routes(self) -> dict[str, Any]:
+        """
+        The tables configured table names are switched to, the routes before the
+        last switch (what a rollback restores) and when it happened.
+        """
+        return self._read_table_routes()[0]
+
+    def _save_table_routes(
+        self, change: Callable[[dict[str, Any]], dict[str, str] | None]
+    ) -> dict[str, str] | None:
+        """
+        Replaces the table routes with those change returns given the current
+        setting, keeping the replaced ones to roll back to; None leaves them. The
+        routes are one entity, so every name switches at once, and the write is
+        conditional on the routes read, so concurrent switches can't interleave.
+        """
+        client = self._get_table_client(self._settings_table)
+
+        def attempt() -> dict[str, str] | None:
+            current, etag = self._read_table_routes()
+            routes = change(current)
+            if routes is None:
+                return None
+            # Names switched back to their configured table need no route
+            routes = {name: table for name, table in routes.items() if name != table}
+            entity = {
+                "PartitionKey": "SETTINGS",
+                "RowKey": TABLE_ROUTES_SETTING,
+                "Value": json.dumps(
+                    {
+                        "routes": routes,
+                        "previous": current["routes"],
+                        "switchedAt": datetime.now().isoformat(),
+                    }
+                ),
+            }
+            if etag is None:
+                try:
+                    client.create_entity(entity)
+                except ResourceExistsError as e:
+                    raise ResourceModifiedError("Table routes were created") from e
+            else:
+                client.update_entity(
+                    entity,
+                    mode=UpdateMode.REPLACE,
+                    etag=etag,
+                    match_condition=MatchConditions.IfNotModified,
+                )
+            return routes
+
+        routes = self._retrier.run(attempt)
+        if routes is not None:
+            self._table_routes = routes
+            self._table_routes_read = time.monotonic()
+            scope = _ROUTES_SCOPE.get()
+            if scope is not None:
+                scope[self._settings_table] = routes
+            # Transactions may now be read from another table
+            self._transactions_changed(None)
+        return routes
+
+    def switchable_tables(self) -> list[str]:
+        """The configured table names that can be switched: all but settings."""
+        return [name for name in self.table_names() if name != self._settings_table]
+
+    def switch_tables(self, switches: dict[str, str]) -> dict[str, str]:
+        """
+        Switches configured table names to other tables, e.g. ones a migration
+        wrote to, all together. Names not given keep their current table, and a
+        name switched to itself goes back to its configured table. Every worker
+        follows from its next invocation. Returns the new routes.
+        """
+        routes = self._save_table_routes(
+            lambda current: {**current["routes"], **switches}
+        )
+        assert routes is not None
+        return routes
+
+    def rollback_tables(self) -> dict[str, str] | None:
+        """
+        Restores the table routes from before the last switch, or returns None if
+        there hasn't been one. The routes rolled back from become the ones to roll
+        back to, so a rollback can itself be undone.
+        """
+        return self._save_table_routes(lambda current: current["previous"])
+
+    def export_table(self, table_name: str) -> list[dict[str, Any]]:
+        """
+        Reads every entity in a table as JSON-serializable dicts, for backups.
+        Timestamps are kept as ISO strings and binary properties as base64.
+        """
+        client = self._get_table_client(table_name)
+
+        def serializable(value: Any) -> Any:
+            if isinstance(value, datetime):
+                return value.isoformat()
+            if isinstance(value, bytes):
+                return base64.b64encode(value).decode("ascii")
+            return value
+
+        return [
+            {key: serializable(value) for key, value in entity.items()}
+            for entity in client.list_entities()
+        ]
+
+    def seed_settings(self, defaults: dict[str, str]) -> list[str]:
+        """
+        Inserts default settings that are not already present, leaving existing values alone.
+        Returns the names of the settings that were added.
+        """
+        client = self._get_table_client(self._settings_table)
+        added = []
+        for name, value in defaults.items():
+            try:
+                client.create_entity(
+                    {"PartitionKey": "SETTINGS", "RowKey": name, "Value": value}
+                )
+                added.append(name)
+            except ResourceExistsError:
+                continue
+        return added
+
+    def get_settings(self) -> dict[str, str]:
+        """Retrieves all settings as a name to value mapping."""
+        client = self._get_table_client(self._settings_table)
+        entities = client.query_entities(query_filter="PartitionKey eq 'SETTINGS'")
+        return {e["RowKey"]: e.get("Value", "") for e in entities}
+
+    def save_setting(self, name: str, value: str) -> None:
+        """Creates or replaces a single setting."""
+        client = self._get_table_client(self._settings_table)
+        client.upsert_entity(
+            {"PartitionKey": "SETTINGS", "RowKey": name, "Value": value},
+            mode=UpdateMode.REPLACE,
+        )
+
+    def probe(self) -> None:
+        """
+        Writes, reads back and deletes a throwaway entity in the settings table,
+        raising if any step fails. Used by the startup self-test.
+        """
+        client = self._get_table_client(self._settings_table)
+        row_key = uuid.uuid4().hex
+        client.create_entity({"PartitionKey": "SELFTEST", "RowKey": row_key})
+        client.get_entity("SELFTEST", row_key)
+        client.delete_entity("SELFTEST", row_key)
+
+    def has_api_keys(self) -> bool:
+        """Returns True if any API key has been issued."""
+        client = self._get_table_client(self._api_keys_table)
+        keys = client.query_entities(
+            query_filter="PartitionKey eq 'APIKEY'", select=["RowKey"]
+        )
+        return next(iter(keys), None) is not None
+
+    def save_api_key(self, key_hash: str, owner_email: str, name: str) -> None:
+        """Stores an API key by its hash. The plaintext key is never stored."""
+        client = self._get_table_client(self._api_keys_table)
+        client.create_entity(
+            {
+                "PartitionKey": "APIKEY",
+                "RowKey": key_hash,
+                "Owner": owner_email,
+                "Name": name,
+                "CreatedAt": datetime.now().isoformat(),
+            }
+        )
+
+    def get_api_key_owner(self, key_hash: str) -> str | None:
+        """Returns the owner email for an API key hash, or None if it is unknown."""
+        client = self._get_table_client(self._api_keys_table)
+        try:
+            entity = client.get_entity(partition_key="APIKEY", row_key=key_hash)
+        except ResourceNotFoundError:
+            return None
+        return entity.get("Owner")
+
+    def save_invite(
+        self,
+        token_hash: str,
+        email: str,
+        name: str,
+        invited_by: str,
+        expires_at: str,
+    ) -> None:
+        """Stores a member invite by its token hash; the plaintext is never stored."""
+        client = self._get_table_client(self._invites_table)
+        client.create_entity(
+            {
+                "PartitionKey": "INVITE",
+                "RowKey": token_hash,
+                "Email": email,
+                "Name": name,
+                "InvitedBy": invited_by,
+                "ExpiresAt": expires_at,
+            }
+        )
+
+    def get_invite(self, token_hash: str) -> dict[str, Any] | None:
+        """Returns the invite for a token hash, or None if it is unknown."""
+        client = self._get_table_client(self._invites_table)
+        try:
+            entity = client.get_entity(partition_key="INVITE", row_key=token_hash)
+        except ResourceNotFoundError:
+            return None
+        return {
+            "email": entity["Email"],
+            "name": entity["Name"],
+            "invitedBy": entity.get("InvitedBy"),
+            "expiresAt": entity["ExpiresAt"],
+            "acceptedBy": entity.get("AcceptedBy"),
+        }
+
+    def mark_invite_accepted(self, token_hash: str, accepted_by: str) -> bool:
+        """
+        Records who accepted an invite, so its link can't be used again. The write
+        is conditional on the invite being unchanged since it was read, so of two
+        concurrent accepts only one succeeds. Returns False if the invite is unknown
+        or was already accepted.
+        """
+        client = self._get_table_client(self._invites_table)
+
+        def attempt() -> bool:
+            try:
+                entity = client.get_entity(partition_key="INVITE", row_key=token_hash)
+            except ResourceNotFoundError:
+                return False
+            if entity.get("AcceptedBy"):
+                return False
+            client.update_entity(
+                {
+                    "PartitionKey": "INVITE",
+                    "RowKey": token_hash,
+                    "AcceptedBy": accepted_by,
+                    "AcceptedAt": datetime.now().isoformat(),
+                },
+                mode=UpdateMode.MERGE,
+                etag=entity.metadata["etag"],
+                match_condition=MatchConditions.IfNotModified,
+            )
+            return True
+
+        return self._retrier.run(attempt)
+
+    def save_share(
+        self, token_hash: str, share: dict[str, Any], tenant: str = "default"
+    ) -> None:
+        """
+        Stores a report share link by its token hash; the plaintext is never stored.
+        share has the report, its params, createdBy and expiresAt.
+        """
+        client = self._get_table_client(self._shares_table)
+        client.create_entity(
+            {
+                "PartitionKey": f"{tenant}_SHARES",
+                "RowKey": token_hash,
+                "Report": share["report"],
+                "Params": json.dumps(share["params"]),
+                "CreatedBy": share["createdBy"],
+                "CreatedAt": datetime.now().isoformat(),
+                "ExpiresAt": share["expiresAt"],
+            }
+        )
+
+    @staticmethod
+    def _entity_to_share(entity: dict[str, Any]) -> dict[str, Any]:
+        return {
+            "id": entity["RowKey"],
+            "report": entity["Report"],
+            "params": json.loads(entity.get("Params") or "{}"),
+            "createdBy": entity.get("CreatedBy"),
+            "createdAt": entity.get("CreatedAt"),
+            "expiresAt": entity["ExpiresAt"],
+            "revokedAt": entity.get("RevokedAt"),
+        }
+
+    def get_share(
+        self, token_hash: str, tenant: str = "default"
+    ) -> dict[str, Any] | None:
+        """Returns the share link for a token hash, or None if it is unknown."""
+        client = self._get_table_client(self._shares_table)
+        try:
+            entity = client.get_entity(
+                partition_key=f"{tenant}_SHARES", row_key=token_hash
+            )
+        except ResourceNotFoundError:
+            return None
+        return self._entity_to_share(entity)
+
+    def get_shares(
+        self, created_by: str, tenant: str = "default"
+    ) -> list[dict[str, Any]]:
+        """Retrieves the share links a member created, newest first."""
+        client = self._get_table_client(self._shares_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_SHARES'"
+        )
+        shares = [
+            self._entity_to_share(e)
+            for e in entities
+            if (e.get("CreatedBy") or "").lower() == created_by.lower()
+        ]
+        return sorted(shares, key=lambda s: s["createdAt"] or "", reverse=True)
+
+    def revoke_share(self, token_hash: str, tenant: str = "default") -> None:
+        """Records that a share link was revoked, so it no longer opens."""
+        client = self._get_table_client(self._shares_table)
+        client.update_entity(
+            {
+                "PartitionKey": f"{tenant}_SHARES",
+                "RowKey": token_hash,
+                "RevokedAt": datetime.now().isoformat(),
+            },
+            mode=UpdateMode.MERGE,
+        )
+
+    def save_view(
+        self, view: dict[str, Any], owner: str, tenant: str = "default"
+    ) -> None:
+        """
+        Saves one of a member's transaction views, replacing any with the same id.
+        view has the id, name, filter and sort.
+        """
+        client = self._get_table_client(self._views_table)
+        client.upsert_entity(
+            {
+                "PartitionKey": f"{tenant}_VIEWS",
+                "RowKey": view["id"],
+                "Owner": owner,
+                "Name": view["name"],
+                "Filter": view.get("filter") or "",
+                "Sort": view.get("sort") or "",
+                "UpdatedAt": datetime.now().isoformat(),
+            },
+            mode=UpdateMode.REPLACE,
+        )
+
+    def get_views(
+        self, owner: str | None = None, tenant: str = "default"
+    ) -> list[dict[str, Any]]:
+        """Retrieves the transaction views a member saved, or everyone's, by name."""
+        client = self._get_table_client(self._views_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_VIEWS'"
+        )
+        views = [
+            {
+                "id": e["RowKey"],
+                "name": e["Name"],
+                "filter": e.get("Filter") or None,
+                "sort": e.get("Sort") or None,
+            }
+            for e in entities
+            if owner is None or (e.get("Owner") or "").lower() == owner.lower()
+        ]
+        return sorted(views, key=lambda v: v["name"].lower())
+
+    def delete_view(self, view_id: str, tenant: str = "default") -> None:
+        """Deletes a transaction view."""
+        client = self._get_table_client(self._views_table)
+        client.delete_entity(partition_key=f"{tenant}_VIEWS", row_key=view_id)
+
+    def save_undo_operation(
+        self,
+        operation: dict[str, Any],
+        expired_before: str,
+        tenant: str = "default",
+    ) -> None:
+        """
+        Adds an operation to the undo log: its id, kind, summary, actor and the
+        inverse that reverts it, split across Inverse, Inverse1, ... properties
+        of UNDO_CHUNK_LENGTH characters. Operations created before expired_before
+        (an ISO timestamp) can no longer be undone and are dropped.
+        """
+        client = self._get_table_client(self._undo_table)
+        self.delete_entities(
+            self._undo_table,
+            self._list_keys(
+                self._undo_table,
+                f"PartitionKey eq '{tenant}_UNDO' and CreatedAt lt '{expired_before}'",
+            ),
+        )
+        inverse = json.dumps(operation["inverse"])
+        chunks = [
+            inverse[i : i + UNDO_CHUNK_LENGTH]
+            for i in range(0, len(inverse), UNDO_CHUNK_LENGTH)
+        ]
+        client.create_entity(
+            {
+                "PartitionKey": f"{tenant}_UNDO",
+                "RowKey": operation["id"],
+                "Kind": operation["kind"],
+                "Summary": operation["summary"],
+                "Actor": operation["actor"],
+                "CreatedAt": datetime.now().isoformat(),
+                **{
+                    f"Inverse{n or ''}": chunk
+                    for n, chunk in enumerate(chunks)
+                },
+            }
+        )
+
+    def get_undo_operations(self, tenant: str = "default") -> list[dict[str, Any]]:
+        """Retrieves the undo log, newest first."""
+        client = self._get_table_client(self._undo_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_UNDO'"
+        )
+        operations = [
+            {
+                "id": e["RowKey"],
+                "kind": e["Kind"],
+                "summary": e["Summary"],
+                "actor": e.get("Actor"),
+                "createdAt": e["CreatedAt"],
+                "undoneAt": e.get("UndoneAt"),
+                "inverse": json.loads(self._joined_inverse(e)),
+            }
+            for e in entities
+        ]
+        return sorted(operations, key=lambda o: o["createdAt"], reverse=True)
+
+    @staticmethod
+    def _joined_inverse(entity: dict[str, Any]) -> str:
+        """An undo log entity's inverse, put back together from its chunks."""
+        chunks = [entity["Inverse"]]
+        while f"Inverse{len(chunks)}" in entity:
+            chunks.append(entity[f"Inverse{len(chunks)}"])
+        return "".join(chunks)
+
+    def mark_undone(self, operation_id: str, tenant: str = "default") -> None:
+        """Records that an operation in the undo log was undone."""
+        client = self._get_table_client(self._undo_table)
+        client.update_entity(
+            {
+                "PartitionKey": f"{tenant}_UNDO",
+                "RowKey": operation_id,
+                "UndoneAt": datetime.now().isoformat(),
+            },
+            mode=UpdateMode.MERGE,
+        )
+
+    def save_category(self, category: dict[str, Any], tenant: str = "default") -> None:
+        """
+        Saves a category the household added, replacing any with the same name.
+        category has the name, color and budgetEligible flag.
+        """
+        client = self._get_table_client(self._categories_table)
+        client.upsert_entity(
+            {
+                "PartitionKey": f"{tenant}_CATEGORIES",
+                "RowKey": category["name"],
+                "Color": category.get("color") or "",
+                "BudgetEligible": bool(category.get("budgetEligible", True)),
+                "UpdatedAt": datetime.now().isoformat(),
+            },
+            mode=UpdateMode.REPLACE,
+        )
+        self._category_names_read = float("-inf")
+
+    def get_categories(self, tenant: str = "default") -> list[dict[str, Any]]:
+        """Retrieves the categories the household added, by name."""
+        client = self._get_table_client(self._categories_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_CATEGORIES'"
+        )
+        categories = [
+            {
+                "name": e["RowKey"],
+                "color": e.get("Color") or None,
+                "budgetEligible": bool(e.get("BudgetEligible", True)),
+            }
+            for e in entities
+        ]
+        return sorted(categories, key=lambda c: c["name"].lower())
+
+    def delete_category(self, name: str, tenant: str = "default") -> bool:
+        """Deletes a category the household added. Returns False if there's none."""
+        client = self._get_table_client(self._categories_table)
+        try:
+            client.get_entity(partition_key=f"{tenant}_CATEGORIES", row_key=name)
+        except ResourceNotFoundError:
+            return False
+        client.delete_entity(partition_key=f"{tenant}_CATEGORIES", row_key=name)
+        self._category_names_read = float("-inf")
+        return True
+
+    def category_names(self) -> list[str]:
+        """
+        The names of the categories the household added, re-read every
+        CATEGORIES_TTL seconds so every worker picks up changes.
+        """
+        if time.monotonic() - self._category_names_read >= CATEGORIES_TTL:
+            try:
+                self._category_names = [c["name"] for c in self.get_categories()]
+            except Exception as e:  # pylint: disable=broad-exception-caught
+                logger.warning("Could not read categories, keeping the last: %s", e)
+            self._category_names_read = time.monotonic()
+        return list(self._category_names)
+
+    def record_activity(
+        self,
+        kind: str,
+        summary: str,
+        actor: str | None = None,
+        details: dict[str, Any] | None = None,
+        tenant: str = "default",
+    ) -> None:
+        """Appends an event to the household's activity feed."""
+        client = self._get_table_client(self._activity_table)
+        now = datetime.now()
+        # Row keys sort ascending, so count down from a far-off moment to list
+        # the newest events first
+        ticks = ACTIVITY_EPOCH_END_MS - int(now.timestamp() * 1000)
+        client.create_entity(
+            {
+                "PartitionKey": f"{tenant}_ACTIVITY",
+                "RowKey": f"{ticks:013d}_{uuid.uuid4().hex[:8]}",
+                "Kind": kind,
+                "Summary": summary,
+                "Actor": actor,
+                "Details": json.dumps(details or {}),
+                "OccurredAt": now.isoformat(),
+            }
+        )
+
+    def get_activity(
+        self,
+        limit: int,
+        cursor: str | None = None,
+        kind: str | None = None,
+        tenant: str = "default",
+    ) -> tuple[list[dict[str, Any]], str | None]:
+        """
+        Returns up to `limit` activity events, newest first, starting after the
+        cursor, along with the cursor for the next page (None on the last page).
+        """
+        client = self._get_table_client(self._activity_table)
+        query_filter = f"PartitionKey eq '{tenant}_ACTIVITY'"
+        if cursor:
+            query_filter += f" and RowKey gt {_quoted(cursor)}"
+        if kind:
+            query_filter += f" and Kind eq {_quoted(kind)}"
+
+        events = []
+        for entity in client.query_entities(query_filter=query_filter):
+            if len(events) == limit:
+                return events, events[-1]["id"]
+            events.append(
+                {
+                    "id": entity["RowKey"],
+                    "kind": entity["Kind"],
+                    "summary": entity["Summary"],
+                    "actor": entity.get("Actor"),
+                    "details": json.loads(entity.get("Details") or "{}"),
+                    "occurredAt": entity["OccurredAt"],
+                }
+            )
+        return events, None
+
+    def record_failure(
+        self,
+        failure_id: str,
+        message: dict[str, Any],
+        error: str,
+        tenant: str = "default",
+    ) -> None:
+        """Records a queue message that failed processing, pending a retry."""
+        client = self._get_table_client(self._failures_table)
+        now = datetime.now().isoformat()
+        client.upsert_entity(
+            {
+                "PartitionKey": f"{tenant}_FAILURES",
+                "RowKey": failure_id,
+                "Message": json.dumps(message),
+                "Error": error,
+                "Status": "pending",
+                "FailedAt": now,
+                "UpdatedAt": now,
+            },
+            mode=UpdateMode.REPLACE,
+        )
+
+    @staticmethod
+    def _entity_to_failure(entity: dict[str, Any]) -> dict[str, Any]:
+        """Converts a failures table entity to its API shape."""
+        return {
+            "id": entity["RowKey"],
+            "message": json.loads(entity["Message"]),
+            "error": entity.get("Error"),
+            "status": entity["Status"],
+            "failedAt": entity["FailedAt"],
+            "updatedAt": entity.get("UpdatedAt"),
+        }
+
+    def get_failures(self, tenant: str = "default") -> list[dict[str, Any]]:
+        """Retrieves the recorded processing failures, most recent first."""
+        client = self._get_table_client(self._failures_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_FAILURES'"
+        )
+        failures = [self._entity_to_failure(e) for e in entities]
+        return sorted(failures, key=lambda f: f["failedAt"], reverse=True)
+
+    def get_failure(
+        self, failure_id: str, tenant: str = "default"
+    ) -> dict[str, Any] | None:
+        """Returns a recorded failure, or None if there is none with the ID."""
+        client = self._get_table_client(self._failures_table)
+        try:
+            entity = client.get_entity(
+                partition_key=f"{tenant}_FAILURES", row_key=failure_id
+            )
+        except ResourceNotFoundError:
+            return None
+        return self._entity_to_failure(entity)
+
+    def set_failure_status(
+        self,
+        failure_id: str,
+        status: str,
+        error: str | None = None,
+        tenant: str = "default",
+    ) -> None:
+        """Moves a failure record to a new status, with the latest error if any."""
+        client = self._get_table_client(self._failures_table)
+        entity = {
+            "PartitionKey": f"{tenant}_FAILURES",
+            "RowKey": failure_id,
+            "Status": status,
+            "UpdatedAt": datetime.now().isoformat(),
+        }
+        if error is not None:
+            entity["Error"] = error
+        client.update_entity(entity, mode=UpdateMode.MERGE)
+
+    def record_upload(
+        self,
+        blob_name: str,
+        file_format: str,
+        uploaded_by: str,
+        tenant: str = "default",
+        provenance: dict[str, Any] | None = None,
+    ) -> None:
+        """
+        Starts tracking an upload's processing, as queued. Provenance records where
+        a file that didn't come through the upload endpoint was picked up from.
+        """
+        client = self._get_table_client(self._uploads_table)
+        now = datetime.now().isoformat()
+        entity = {
+            "PartitionKey": f"{tenant}_UPLOADS",
+            "RowKey": blob_name,
+            "Format": file_format,
+            "UploadedBy": uploaded_by,
+            "Status": "queued",
+            "Errors": "[]",
+            "QueuedAt": now,
+            "UpdatedAt": now,
+        }
+        if provenance:
+            entity["Provenance"] = json.dumps(provenance)
+        client.upsert_entity(entity, mode=UpdateMode.REPLACE)
+
+    def set_upload_status(
+        self,
+        blob_name: str,
+        status: str,
+        rows_imported: int | None = None,
+        rows_skipped: int | None = None,
+        errors: list[str] | None = None,
+        tenant: str = "default",
+    ) -> None:
+        """
+        Moves an upload to a new processing status, with its row counts and errors
+        when known. Uploads queued before tracking began get a record here.
+        """
+        client = self._get_table_client(self._uploads_table)
+        entity: dict[str, Any] = {
+            "PartitionKey": f"{tenant}_UPLOADS",
+            "RowKey": blob_name,
+            "Status": status,
+            "UpdatedAt": datetime.now().isoformat(),
+        }
+        if rows_imported is not None:
+            entity["RowsImported"] = rows_imported
+        if rows_skipped is not None:
+            entity["RowsSkipped"] = rows_skipped
+        if errors is not None:
+            entity["Errors"] = json.dumps(errors)
+        client.upsert_entity(entity, mode=UpdateMode.MERGE)
+
+    @staticmethod
+    def _entity_to_upload(entity: dict[str, Any]) -> dict[str, Any]:
+        """Converts an uploads table entity to its API shape."""
+        return {
+            "blobName": entity["RowKey"],
+            "format": entity.get("Format"),
+            "uploadedBy": entity.get("UploadedBy"),
+            "status": entity["Status"],
+            "rowsImported": entity.get("RowsImported"),
+            "rowsSkipped": entity.get("RowsSkipped"),
+            "errors": json.loads(entity.get("Errors") or "[]"),
+            "queuedAt": entity.get("QueuedAt"),
+            "updatedAt": entity["UpdatedAt"],
+            "provenance": json.loads(entity.get("Provenance") or "null"),
+        }
+
+    def get_uploads(self, limit: int, tenant: str = "default") -> list[dict[str, Any]]:
+        """Returns up to `limit` of the most recent uploads, newest first."""
+        client = self._get_table_client(self._uploads_table)
+        entities = client.query_entities(
+            query_filter=f"PartitionKey eq '{tenant}_UPLOADS'"
+        )
+        # Blob names start with their upload time, so they sort chronologically
+        uploads = sorted(
+            (self._entity_to_upload(e) for e in entities),
+            key=lambda u: u["blobName"],
+            reverse=True,
+        )
+        return uploads[:limit]
+
+    def get_upload(
+        self, blob_name: str, tenant: str = "default"
+    ) -> dict[str, Any] | None:
+        """Returns an upload's processing status, or None if it isn't tracked."""
+        client = self._get_table_client(self._uploads_table)
+        try:
+            entity = client.get_entity(
+                partition_key=f"{tenant}_UPLOADS", row_key=blob_name
+            )
+        except ResourceNotFoundError:
+            return None
+        return self._entity_to_upload(entity)
+
+    @staticmethod
+    def _processing_key(blob_name: str, content_hash: str) -> str:
+        """
+        Row key for a blob's processing log entry. Blob names can hold characters
+        row keys can't, so the name is hashed.
+        """
+        name_hash = hashlib.sha256(blob_name.encode("utf-8")).hexdigest()[:16]
+        return f"{name_hash}_{content_hash}"
+
+    def get_processing_log(
+        self, blob_name: str, content_hash: str, tenant: str = "default"
+    ) -> dict[str, Any] | None:
+        """
+        Returns the processing log entry for a blob with this content, or None if
+        it has never been processed.
+        """
+        client = self._get_table_client(self._processing_log_table)
+        try:
+            entity = client.get_entity(
+                partition_key=f"{tenant}_PROCESSED",
+                row_key=self._processing_key(blob_name, content_hash),
+            )
+        except ResourceNotFoundError:
+            return None
+        return {
+            "blobName": entity["BlobName"],
+            "status": entity["Status"],
+            "rowsImported": entity.get("RowsImported"),
+            "errors": json.loads(entity.get("Errors") or "[]"),
+            "ledgerEntryId": entity.get("LedgerEntryId"),
+            "sentEmails": json.loads(entity.get("SentEmails") or "{}"),
+            "updatedAt": entity["UpdatedAt"],
+        }
+
+    def log_processing(
+        self,
+        blob_name: str,
+        content_hash: str,
+        status: str,
+        rows_imported: int | None = None,
+        errors: list[str] | None = None,
+        ledger_entry_id: str | None = None,
+        sent_emails: dict[str, str] | None = None,
+        tenant: str = "default",
+    ) -> None:
+        """
+        Records a blob's processing progress under its name and content hash, with
+        the outcome's details as they become known. sent_emails maps each recipient
+        its summary went out to onto the email's message ID.
+        """
+        client = self._get_table_client(self._processing_log_table)
+        entity: dict[str, Any] = {
+            "PartitionKey": f"{tenant}_PROCESSED",
+            "RowKey": self._processing_key(blob_name, content_hash),
+            "BlobName": blob_name,
+            "ContentHash": content_hash,
+            "Status": status,
+            "UpdatedAt": datetime.now().isoformat(),
+        }
+        if rows_imported is not None:
+            entity["RowsImported"] = rows_imported
+        if errors is not None:
+            entity["Errors"] = json.dumps(errors)
+        if ledger_entry_id is not None:
+            entity["LedgerEntryId"] = ledger_entry_id
+        if sent_emails is not None:
+            entity["SentEmails"] = json.dumps(sent_emails)
+        client.upsert_entity(entity, mode=UpdateMode.MERGE)
+
+    def get_exchange_rates(self, base: str, day: date) -> dict[str, Decimal] | None:
+        """
+        Returns the cached rates for a day, as units of each currency per unit of
+        base, or None if the day's rates haven't been cached.
+        """
+        client = self._get_table_client(self._exchange_rates_table)
+        try:
+            entity = client.get_entity(partition_key=base, row_key=day.isoformat())
+        except ResourceNotFoundError:
+            return None
+        return {
+            currency: Decimal(rate)
+            for currency, rate in json.loads(entity["Rates"]).items()
+        }
+
+    def save_exchange_rates(
+        self, base: str, day: date, rates: dict[str, Decimal]
+    ) -> None:
+        """Caches a day's rates against a base currency."""
+        client = self._get_table_client(self._exchange_rates_table)
+        client.upsert_entity(
+            {
+                "PartitionKey": base,
+                "RowKey": day.isoformat(),
+                # Kept as strings so the rates come back exactly as published
+                "Rates": json.dumps({k: str(v) for k, v in rates.items()}),
+                "FetchedAt": datetime.now().isoformat(),
+            },
+            mode=UpdateMode.REPLACE,
+        )
+
+    def get_unconverted_transactions(
+        self, tenant: str = "default"
+    ) -> list[tuple[str, Transaction]]:
+        """
+        Retrieves the foreign currency transactions saved while their rates
+        couldn't be fetched, paired with their IDs.
+        """
+        client = self._get_table_client(self._transactions_table)
+        entities = client.query_entities(
+            query_filter=f"{self._prefix_filter(f'{tenant}_')} and FxPending eq true"
+        )
+        return [(e["RowKey"], self._entity_to_transaction(e)) for e in entities]
+
+    def save_conversions(
+        self, converted: list[tuple[str, Transaction]], tenant: str = "default"
+    ) -> None:
+        """
+        Stores the home currency amounts of transactions that were saved before
+        they could be converted, and clears their flag.
+        """
+        client = self._get_table_client(self._transactions_table)
+        for transaction_id, t in converted:
+            client.update_entity(
+                {
+                    "PartitionKey": f"{tenant}_{t.date.strftime('%Y-%m')}",
+                    "RowKey": transaction_id,
+                    "Amount": float(t.amount),
+                    "OriginalAmount": float(t.original_amount),
+                    "FxPending": False,
+                },
+                mode=UpdateMode.MERGE,
+            )
+        self._transactions_changed(
+            f"{tenant}_{t.date.strftime('%Y-%m')}" for _, t in converted
+        )
+
+    def record_job_run(
+        self,
+        job: str,
+        started_at: datetime,
+        status: str,
+        details: dict[str, Any],
+        errors: list[str],
+        tenant: str = "default",
+    ) -> None:
+        """Records the outcome of a scheduled job's run in its history."""
+        client = self._get_table_client(self._jobs_table)
+        ticks = ACTIVITY_EPOCH_END_MS - int(started_at.timestamp() * 1000)
+        client.upsert_entity(
+            {
+                "PartitionKey": f"{tenant}_JOB_{job}",
+                "RowKey": f"{ticks:013d}",
+                "Status": status,
+                "StartedAt": started_at.isoformat(),
+                "FinishedAt": datetime.now().isoformat(),
+                "Details": json.dumps(details),
+                "Errors": json.dumps(errors),
+            },
+            mode=UpdateMode.REPLACE,
+        )
+
+    def get_job_runs(
+        self, job: str, limit: int, tenant: str = "default"
+    ) -> list[dict[str, Any]]:
+        """Returns up to `limit` of a job's most recent runs, newest first."""
+        client = self._get_table_client(self._jobs_table)
+        runs = []
+        for entity in client.query_entities(
+            query_filter=f"PartitionKey eq {_quoted(f'{tenant}_JOB_{job}')}"
+        ):
+            if len(runs) == limit:
+                break
+            runs.append(
+                {
+                    "status": entity["Status"],
+                    "startedAt": entity["StartedAt"],
+                    "finishedAt": entity["FinishedAt"],
+                    **json.loads(entity.get("Details") or "{}"),
+                    "errors": json.loads(entity.get("Errors") or "[]"),
+                }
+            )
+        return runs
+
+    @staticmethod
+    def _prefix_filter(prefix: str) -> str:
+        """Builds a PartitionKey range filter matching every key that starts with prefix."""
+        upper = prefix[:-1] + chr(ord(prefix[-1]) + 1)
+        return f"PartitionKey ge {_quoted(prefix)} and PartitionKey lt {_quoted(upper)}"
+
+    def _list_keys(self, table_name: str, query_filter: str) -> list[dict[str, str]]:
+        """Lists the PartitionKey/RowKey pairs of entities matching a filter."""
+        client = self._get_table_client(table_name)
+        return [
+            {"PartitionKey": e["PartitionKey"], "RowKey": e["RowKey"]}
+            for e in client.query_entities(
+                query_filter=query_filter, select=["PartitionKey", "RowKey"]
+            )
+        ]
+
+    def list_household_keys(self, tenant: str = "default") -> dict[str, list[dict]]:
+        """
+        Lists the keys of every entity owned by a household, keyed by table name.
+        Covers the tenant's transactions, each member's savings, and the member records,
+        along with its settings, API keys, invites and connector and mailbox tokens.
+        The table routes setting is kept, as it points at the tables themselves.
+        """
+        people = self.get_all_people()
+
+        savings: list[dict[str, str]] = []
+        health: list[dict[str, str]] = []
+        accounts: list[dict[str, str]] = []
+        statements: list[dict[str, str]] = []
+        reconciliations: list[dict[str, str]] = []
+        for person in people:
+            savings.extend(
+                self._list_keys(
+                    self._savings_table, self._prefix_filter(f"{person['Email']}_")
+                )
+            )
+            health.extend(
+                self._list_keys(
+                    self._health_table, f"PartitionKey eq {_quoted(person['Email'])}"
+                )
+            )
+            accounts.extend(
+                self._list_keys(
+                    self._accounts_table, f"PartitionKey eq {_quoted(person['Email'])}"
+                )
+            )
+            statements.extend(
+                self._list_keys(
+                    self._statements_table, self._prefix_filter(f"{person['Email']}_")
+                )
+            )
+            reconciliations.extend(
+                self._list_keys(
+                    self._reconciliations_table,
+                    self._prefix_filter(f"{person['Email']}_"),
+                )
+            )
+
+        return {
+            self._transactions_table: self._list_keys(
+                self._transactions_table, self._prefix_filter(f"{tenant}_")
+            ),
+            self._savings_table: savings,
+            self._people_table: self._list_keys(
+                self._people_table, "PartitionKey eq 'PEOPLE'"
+            ),
+            self._settings_table: self._list_keys(
+                self._settings_table,
+                f"PartitionKey eq 'SETTINGS' and RowKey ne '{TABLE_ROUTES_SETTING}'",
+            ),
+            self._api_keys_table: self._list_keys(
+                self._api_keys_table, "PartitionKey eq 'APIKEY'"
+            ),
+            self._invites_table: self._list_keys(
+                self._invites_table, "PartitionKey eq 'INVITE'"
+            ),
+            self._debts_table: self._list_keys(
+                self._debts_table, f"PartitionKey eq '{tenant}_LEDGER'"
+            ),
+            self._health_table: health,
+            self._accounts_table: accounts,
+            self._statements_table: statements,
+            self._reconciliations_table: reconciliations,
+            self._subscriptions_table: self._list_keys(
+                self._subscriptions_table, f"PartitionKey eq '{tenant}_SUBSCRIPTIONS'"
+            ),
+            self._alerts_table: self._list_keys(
+                self._alerts_table, f"PartitionKey eq '{tenant}_ALERTS'"
+            ),
+            self._shares_table: self._list_keys(
+                self._shares_table, f"PartitionKey eq '{tenant}_SHARES'"
+            ),
+            self._usage_table: self._list_keys(
+                self._usage_table, f"PartitionKey eq '{tenant}_USAGE'"
+            ),
+            self._views_table: self._list_keys(
+                self._views_table, f"PartitionKey eq '{tenant}_VIEWS'"
+            ),
+            self._undo_table: self._list_keys(
+                self._undo_table, f"PartitionKey eq '{tenant}_UNDO'"
+            ),
+            self._categories_table: self._list_keys(
+                self._categories_table, f"PartitionKey eq '{tenant}_CATEGORIES'"
+            ),
+            self._rules_table: self._list_keys(
+                self._rules_table, f"PartitionKey eq '{tenant}_RULES'"
+            ),
+            self._budgets_table: self._list_keys(
+                self._budgets_table, f"PartitionKey eq '{tenant}_BUDGETS'"
+            ),
+            self._activity_table: self._list_keys(
+                self._activity_table, f"PartitionKey eq '{tenant}_ACTIVITY'"
+            ),
+            self._failures_table: self._list_keys(
+                self._failures_table, f"PartitionKey eq '{tenant}_FAILURES'"
+            ),
+            self._jobs_table: self._list_keys(
+                self._jobs_table, self._prefix_filter(f"{tenant}_JOB_")
+            ),
+            self._uploads_table: self._list_keys(
+                self._uploads_table, f"PartitionKey eq '{tenant}_UPLOADS'"
+            ),
+            self._processing_log_table: self._list_keys(
+                self._processing_log_table, f"PartitionKey eq '{tenant}_PROCESSED'"
+            ),
+            self._connectors_table: self._list_keys(
+                self._connectors_table, self._prefix_filter(f"{tenant}_")
+            ),
+            self._documents_table: self._list_keys(
+                self._documents_table, self._prefix_filter(f"{tenant}_")
+            ),
+        }
+
+    def list_expired_keys(
+        self,
+        transactions_cutoff: date | None,
+        savings_cutoff: date | None,
+        activity_cutoff: date | None = None,
+        jobs_cutoff: date | None = None,
+        metrics_cutoff: date | None = None,
+        tenant: str = "default",
+    ) -> dict[str, list[dict]]:
+        """
+        Lists the keys of entities dated before the given cutoffs, keyed by table name.
+        A cutoff of None keeps that data type forever and its table is omitted.
+        """
+        expired: dict[str, list[dict]] = {}
+
+        if transactions_cutoff:
+            query_filter = (
+                f"{self._prefix_filter(f'{tenant}_')} "
+                f"and Date lt '{transactions_cutoff.isoformat()}'"
+            )
+            expired[self._transactions_table] = self._list_keys(
+                self._transactions_table, query_filter
+            )
+
+        if savings_cutoff:
+            # Savings are stored per month; only whole months before the cutoff expire
+            cutoff_month = savings_cutoff.strftime("%Y-%m")
+            savings: list[dict[str, str]] = []
+            for person in self.get_all_people():
+                prefix = f"{person['Email']}_"
+                savings.extend(
+                    key
+                    for key in self._list_keys(
+                        self._savings_table, self._prefix_filter(prefix)
+                    )
+                    if key["PartitionKey"][len(prefix) :] < cutoff_month
+                )
+            expired[self._savings_table] = savings
+
+        if activity_cutoff:
+            query_filter = (
+                f"PartitionKey eq {_quoted(f'{tenant}_ACTIVITY')} "
+                f"and OccurredAt lt '{activity_cutoff.isoformat()}'"
+            )
+            expired[self._activity_table] = self._list_keys(
+                self._activity_table, query_filter
+            )
+
+        if jobs_cutoff:
+            query_filter = (
+                f"{self._prefix_filter(f'{tenant}_JOB_')} "
+                f"and StartedAt lt '{jobs_cutoff.isoformat()}'"
+            )
+            expired[self._jobs_table] = self._list_keys(self._jobs_table, query_filter)
+
+        if metrics_cutoff:
+            # Metric buckets are shared by every household and named by when they start
+            query_filter = f"PartitionKey lt '{metrics_cutoff.isoformat()}'"
+            expired[self._metrics_table] = self._list_keys(
+                self._metrics_table, query_filter
+            )
+
+        return expired
+
+    def delete_entities(self, table_name: str, keys: list[dict[str, str]]) -> int:
+        """
+        Deletes entities by key using batched transactions grouped by partition.
+        Returns the number of entities deleted.
+        """
+        if not keys:
+            return 0
+
+        client = self._get_table_client(table_name)
+        partitions = collections.defaultdict(list)
+        for key in keys:
+            partitions[key["PartitionKey"]].append(key)
+
+        deleted = 0
+        try:
+            for pk, key_list in partitions.items():
+                for i in range(0, len(key_list), 100):
+                    chunk = key_list[i : i + 100]
+                    try:
+                        client.submit_transaction([("delete", k) for k in chunk])
+                    except TableTransactionError as e:
+                        logger.error(
+                            "Failed to delete batch for partition %s: %s", pk, e
+                        )
+                        raise
+                    deleted += len(chunk)
+        finally:
+            if table_name == self._transactions_table:
+                self._transactions_changed(partitions)
+        return deleted
@@ -1,11 +1,19 @@
 """Service for rendering email content."""
 
-from typing import List, Optional
+import collections
+from decimal import Decimal
+from html import escape
+from typing import Any, Dict, List, Optional
 
-from ..models import Category, Group
+from ..models import AnyCategory, Category, Group, Person
 from ..utils import to_currency
 
 
+def _card_label(card: Dict[str, object]) -> str:
+    """A card's institution and last digits, escaped for HTML."""
+    return escape(f"{card['institution'] or 'Card'} ending {card['mask']}")
+
+
 class EmailRenderer:
     """Service for rendering email content."""
 
@@ -15,7 +23,7 @@ class EmailRenderer:
         if not errors:
             return ""
 
-        error_items = "".join([f"<li>{e}</li>" for e in errors])
+        error_items = "".join([f"<li>{escape(e)}</li>" for e in errors])
         return f"""
         <div style="background-color: #fff4f4; border-left: 5px solid #d13438; padding: 15px; margin-bottom: 20px;">
             <h3 style="color: #d13438; margin-top: 0; font-size: 18px;">⚠️ Warning: Some transactions were skipped</h3>
@@ -36,7 +44,7 @@ class EmailRenderer:
                     <h2 style="margin: 0;">Upload Failed</h2>
                 </div>
                 <div style="padding: 20px;">
-                    <p>The uploaded CSV could not be processed due to the following errors:</p>
+                    <p>The uploaded file could not be processed due to the following errors:</p>
                     {cls._render_error_section(errors)}
                 </div>
             </div>
@@ -45,21 +53,541 @@ class EmailRenderer:
         """
 
     @staticmethod
-    def _render_rows(group: Group, tracked_categories: List[Category]) -> str:
-        """Helper to render table rows."""
+    def render_emergency_fund_alert(months: float, threshold: float) -> str:
+        """Renders the body for a low emergency fund coverage alert."""
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #c19c00; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">Emergency Fund Alert</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>Your emergency fund now covers <strong>{months:g} months</strong> of essential spending, below your target of {threshold:g} months.</p>
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def render_missing_bills_alert(bills: List[Dict[str, object]]) -> str:
+        """Renders the body for an alert about recurring bills that haven't appeared."""
+        rows_html = "".join(
+            f"<tr><td>{escape(str(b['merchant']))}</td><td>{b['expectedDate']}</td>"
+            f"<td>{to_currency(b['averageAmount'])}</td></tr>"
+            for b in bills
+        )
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #c19c00; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">Missing Bill Alert</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>These recurring bills were expected but haven't appeared. An autopay may have failed:</p>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        <tr><th>Merchant</th><th>Expected</th><th>Usual Amount</th></tr>
+                        {rows_html}
+                    </table>
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def render_price_increase_alert(increases: List[Dict[str, object]]) -> str:
+        """Renders the body for an alert about recurring charges that went up."""
+        rows_html = "".join(
+            f"<tr><td>{escape(str(i['merchant']))}</td><td>{i['date']}</td>"
+            f"<td>{to_currency(i['from'])}</td><td>{to_currency(i['to'])}</td>"
+            f"<td>+{i['change']:.0%}</td></tr>"
+            for i in increases
+        )
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #c19c00; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">Price Increase Alert</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>These recurring charges went up compared to their usual amount:</p>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        <tr><th>Merchant</th><th>Charged</th><th>Usual</th><th>Now</th><th>Change</th></tr>
+                        {rows_html}
+                    </table>
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def render_annual_fee_reminder(cards: List[Dict[str, object]]) -> str:
+        """Renders the body for a reminder about upcoming card annual fees."""
+        rows_html = "".join(
+            f"<tr><td>{_card_label(c)}</td>"
+            f"<td>{c['feeDate']}</td><td>{to_currency(c['annualFee'])}</td>"
+            f"<td>{to_currency(c['spend'])}</td>"
+            + (
+                "<td>Unused</td>"
+                if c["feeRate"] is None
+                else f"<td>{c['feeRate']:.1%}</td>"
+            )
+            + "</tr>"
+            for c in cards
+        )
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #0078d4; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">Annual Fee Reminder</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>These cards charge their annual fee soon. The fee rate is the fee as a share of the past year's spend; keep a card if its rewards beat it:</p>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        <tr><th>Card</th><th>Fee Month</th><th>Fee</th><th>Spend (12 mo)</th><th>Fee Rate</th></tr>
+                        {rows_html}
+                    </table>
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def render_payment_reminder(
+        cards: List[Dict[str, object]], escalated: bool = False
+    ) -> str:
+        """
+        Renders the body for a reminder about card payments coming due, or its
+        escalation when the due date is a day away and no payment has been seen.
+        """
+        rows_html = "".join(
+            f"<tr><td>{_card_label(c)}</td>"
+            f"<td>{c['dueDate']}</td><td>{to_currency(c['balance'])}</td>"
+            + (
+                f"<td>{to_currency(c['projectedInterest'])}</td>"
+                if c.get("projectedInterest")
+                else "<td></td>"
+            )
+            + (
+                f"<td><a href=\"{escape(str(c['ackUrl']))}\" style=\"color: #0078d4;\">I've paid this</a></td>"
+                if c.get("ackUrl")
+                else "<td></td>"
+            )
+            + "</tr>"
+            for c in cards
+        )
+        color, title, intro = (
+            (
+                "#d13438",
+                "Payment Due Tomorrow",
+                "We still haven't seen a payment for these cards and they're due tomorrow. Pay now to avoid a late fee and interest:",
+            )
+            if escalated
+            else (
+                "#c19c00",
+                "Payment Reminder",
+                "These card payments are due soon and no payment has been seen yet:",
+            )
+        )
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: {color}; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">{title}</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>{intro}</p>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        <tr><th>Card</th><th>Due</th><th>Balance</th><th>Interest/mo if Carried</th><th></th></tr>
+                        {rows_html}
+                    </table>
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def render_savings_suggestion(
+        month: str, leftover: float, amount: float, approve_url: Optional[str]
+    ) -> str:
+        """
+        Renders the body for a suggested transfer to savings from the month's
+        unspent discretionary budget, with a link that records it if there is one.
+        """
+        action = (
+            f'<p><a href="{escape(approve_url)}" style="color: #0078d4; font-weight: bold;">I\'ve moved it to savings</a> to add it to this month\'s savings.</p>'
+            if approve_url
+            else "<p>Add it to this month's savings once you've moved it.</p>"
+        )
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #107c10; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">Pay Yourself First</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>You're {to_currency(leftover)} under this month's ({escape(month)}) discretionary budgets so far. Consider moving <strong>{to_currency(amount)}</strong> to savings now, before it gets spent.</p>
+                    {action}
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def render_promo_expiry_warning(cards: List[Dict[str, object]]) -> str:
+        """Renders the body for a warning about promo APRs ending on a balance."""
+        rows_html = "".join(
+            f"<tr><td>{_card_label(c)}</td>"
+            f"<td>{c['promoExpiry']}</td><td>{to_currency(c['balance'])}</td>"
+            f"<td>{c['apr'] or 0:g}%</td>"
+            f"<td>{to_currency(c['projectedInterest'])}/mo</td></tr>"
+            for c in cards
+        )
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #c19c00; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">Promo APR Ending</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>The promotional rate on these cards ends soon. Any balance left after the promo ends starts accruing interest at the regular APR:</p>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        <tr><th>Card</th><th>Promo Ends</th><th>Balance</th><th>APR</th><th>Projected Interest</th></tr>
+                        {rows_html}
+                    </table>
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def render_utilization_alert(
+        aggregate: Dict[str, object], threshold: float, cards: List[Dict[str, object]]
+    ) -> str:
+        """Renders the body for an alert about high overall credit utilization."""
+        rows_html = "".join(
+            f"<tr><td>{_card_label(c)}</td>"
+            f"<td>{to_currency(c['balance'] or 0)}</td>"
+            f"<td>{to_currency(c['limit'])}</td>"
+            f"<td>{c['utilization']:.0%}</td></tr>"
+            for c in cards
+        )
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #c19c00; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">Credit Utilization Alert</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>You're using <strong>{aggregate['ratio']:.0%}</strong> of your total credit ({to_currency(aggregate['balance'])} of {to_currency(aggregate['limit'])}), above your {threshold:.0%} threshold. Credit scores weigh overall utilization as well as each card's:</p>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        <tr><th>Card</th><th>Balance</th><th>Limit</th><th>Utilization</th></tr>
+                        {rows_html}
+                    </table>
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def render_over_allocation_alert(
+        totals: Dict[str, object], cards: List[Dict[str, object]]
+    ) -> str:
+        """
+        Renders the body for a warning that a month's savings contributions and
+        required card payments exceed detected income.
+        """
+        rows_html = "".join(
+            f"<tr><td>{_card_label(c)}</td>"
+            f"<td>{to_currency(c['payment'])}</td></tr>"
+            for c in cards
+        )
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #c19c00; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">Over-Allocation Warning</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>For {escape(str(totals['month']))} you've planned {to_currency(totals['contributions'])} of savings contributions and owe {to_currency(totals['cardPayments'])} in required card payments, but your regular deposits come to about {to_currency(totals['income'])} a month. That's <strong>{to_currency(totals['shortfall'])}</strong> more than comes in. Consider trimming this month's contributions before a card payment falls short:</p>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        <tr><th>Card</th><th>Required Payment</th></tr>
+                        {rows_html}
+                    </table>
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def _render_review_transactions(
+        title: str, transactions: List[Dict[str, object]], empty: str
+    ) -> str:
+        """Renders one of a review packet's lists of transactions."""
+        if not transactions:
+            return f"<h3>{title}</h3><p>{empty}</p>"
+        rows_html = "".join(
+            f"<tr><td>{t['date']}</td><td>{escape(str(t['name']))}</td>"
+            f"<td>{escape(str(t['accountNumber']))}</td>"
+            f"<td>{to_currency(t['amount'])}</td></tr>"
+            for t in transactions
+        )
+        return f"""
+                    <h3>{title}</h3>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        <tr><th>Date</th><th>Description</th><th>Account</th><th>Amount</th></tr>
+                        {rows_html}
+                    </table>
+        """
+
+    @classmethod
+    def render_review_packet(cls, packet: Dict[str, Any]) -> str:
+        """
+        Renders the body of a monthly review packet: the month's spend by person
+        and category, budgets, and the transactions to talk through.
+        """
+        summary = packet["summary"]
+        people_html = "".join(
+            f"<tr><td>{escape(str(p['name']))}</td>"
+            f"<td>{to_currency(p['total'])}</td></tr>"
+            for p in summary["people"]
+        )
+        categories_html = "".join(
+            f"<tr><td>{escape(str(c['category']))}</td>"
+            f"<td>{to_currency(c['total'])}</td></tr>"
+            for c in summary["categories"]
+            if c["total"]
+        )
+        budgets_html = "".join(
+            f"<tr><td>{escape(str(b['category']))}</td>"
+            f"<td>{to_currency(b['spent'])}</td>"
+            f"<td>{to_currency(b['limit'])}</td>"
+            + (
+                '<td style="color: #d13438;">Over</td>'
+                if b["overBudget"]
+                else "<td>Within</td>"
+            )
+            + "</tr>"
+            for b in packet["budgets"]
+        )
+        budgets_section = (
+            f"""
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        <tr><th>Category</th><th>Spent</th><th>Limit</th><th></th></tr>
+                        {budgets_html}
+                    </table>
+            """
+            if budgets_html
+            else "<p>No budgets set.</p>"
+        )
+        sections_html = "".join(
+            cls._render_review_transactions(title, packet[key], empty)
+            for title, key, empty in (
+                ("Unusual Transactions", "unusual", "None stood out."),
+                ("Possible Duplicates", "duplicates", "None found."),
+                (
+                    "Unassigned Transactions",
+                    "unassigned",
+                    "Every transaction has an owner.",
+                ),
+            )
+        )
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #0078d4; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">Monthly Review: {escape(str(packet['month']))}</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>Here's what to go over at this month's money meeting. The packet is attached as a PDF for printing. Total spend was <strong>{to_currency(summary['total'])}</strong>.</p>
+                    <h3>Spend by Person</h3>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        {people_html}
+                    </table>
+                    <h3>Spend by Category</h3>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        {categories_html}
+                    </table>
+                    <h3>Budgets</h3>
+                    {budgets_section}
+                    {sections_html}
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def render_monthly_report(report: Dict[str, Any]) -> str:
+        """
+        Renders the body of the monthly report: total spend, spend by person and
+        category, budget against actual, and the debt left to settle.
+        """
+        people_html = "".join(
+            f"<tr><td>{escape(p['name'])}</td><td>{to_currency(p['total'])}</td></tr>"
+            for p in report["people"]
+        )
+        categories_html = "".join(
+            f"<tr><td>{escape(c['category'])}</td>"
+            f"<td>{to_currency(c['total'])}</td></tr>"
+            for c in report["categories"]
+        )
+        budgets_html = "".join(
+            f"<tr><td>{escape(b['category'])}</td><td>{to_currency(b['limit'])}</td>"
+            f"<td>{to_currency(b['spent'])}</td>"
+            + (
+                f'<td style="color: #d13438;">{to_currency(-b["remaining"])} over</td>'
+                if b["overBudget"]
+                else f"<td>{to_currency(b['remaining'])} left</td>"
+            )
+            + "</tr>"
+            for b in report["budgets"]
+        )
+        budgets_section = (
+            f"""
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        <tr><th>Category</th><th>Budget</th><th>Actual</th><th></th></tr>
+                        {budgets_html}
+                    </table>
+            """
+            if budgets_html
+            else "<p>No budgets set.</p>"
+        )
+        debt = report["debt"]
+        if debt is None:
+            debt_html = "<p>Debt is only worked out between exactly two members.</p>"
+        elif debt["amount"]:
+            debt_html = (
+                f"<p>{escape(debt['from'])} owes {escape(debt['to'])}: "
+                f"<strong>{to_currency(debt['amount'])}</strong></p>"
+            )
+        else:
+            debt_html = "<p>Nothing is owed; the month is settled.</p>"
+        if debt and debt["settled"]:
+            debt_html += (
+                f"<p>{to_currency(debt['settled'])} was settled for the month.</p>"
+            )
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #0078d4; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">Monthly Report: {report['month']}</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>Total spend was <strong>{to_currency(report['total'])}</strong>.</p>
+                    <h3>Spend by Person</h3>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        {people_html}
+                    </table>
+                    <h3>Spend by Category</h3>
+                    <table style="width: 100%; border-collapse: collapse; text-align: left;">
+                        {categories_html}
+                    </table>
+                    <h3>Budget vs. Actual</h3>
+                    {budgets_section}
+                    <h3>Debt</h3>
+                    {debt_html}
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def render_job_alert(problem: str, errors: List[str]) -> str:
+        """Renders the body for an admin alert about a scheduled job."""
+        errors_html = (
+            "<ul>" + "".join(f"<li>{escape(e)}</li>" for e in errors) + "</ul>"
+            if errors
+            else ""
+        )
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #d13438; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">Scheduled Job Alert</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>{escape(problem)}</p>
+                    {errors_html}
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def render_invite(name: str, invited_by: str, link: str, expires_at: str) -> str:
+        """Renders the body for a household member invite."""
+        return f"""
+        <html>
+        <body style="font-family: 'Segoe UI', sans-serif; color: #333; line-height: 1.6; background-color: #f4f4f4; margin: 0; padding: 20px;">
+            <div style="max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+                <div style="background-color: #0078d4; padding: 20px; text-align: center; color: white;">
+                    <h2 style="margin: 0;">You're Invited</h2>
+                </div>
+                <div style="padding: 20px;">
+                    <p>Hi {escape(name)}, {escape(invited_by)} invited you to join their household's shared expenses.</p>
+                    <p><a href="{escape(link)}" style="color: #0078d4; font-weight: bold;">Accept the invite</a> and sign in to finish setting up your account.</p>
+                    <p style="color: #666; font-size: 14px;">This link can be used once and expires {escape(expires_at[:10])}.</p>
+                </div>
+            </div>
+        </body>
+        </html>
+        """
+
+    @staticmethod
+    def _ordered_members(group: Group, recipient: Optional[Person]) -> List[Person]:
+        """Returns the group members with the recipient (if any) first."""
+        if recipient is None or recipient not in group.members:
+            return list(group.members)
+        return [recipient] + [p for p in group.members if p is not recipient]
+
+    @classmethod
+    def _render_rows(
+        cls,
+        group: Group,
+        tracked_categories: List[AnyCategory],
+        recipient: Optional[Person] = None,
+    ) -> str:
+        """Helper to render table rows. The recipient's row is listed first and highlighted."""
+        members = cls._ordered_members(group, recipient)
         rows_html = ""
-        for p in group.members:
-            row_cells = f"<td>{p.name}</td>"
+        for p in members:
+            row_cells = f"<td>{escape(p.name)}</td>"
             for c in tracked_categories:
                 row_cells += f"<td>{to_currency(p.get_expenses(c))}</td>"
             row_cells += (
                 f"<td style='font-weight: bold;'>{to_currency(p.get_expenses())}</td>"
             )
-            rows_html += f"<tr>{row_cells}</tr>"
+            if p is recipient:
+                rows_html += f"<tr style='background-color: #f0f6ff;'>{row_cells}</tr>"
+            else:
+                rows_html += f"<tr>{row_cells}</tr>"
 
         # Difference Row (if 2 members)
-        if len(group.members) == 2:
-            p1, p2 = group.members
+        if len(members) == 2:
+            p1, p2 = members
             diff_cells = "<td>Difference</td>"
             for c in tracked_categories:
                 diff_cells += (
@@ -73,31 +601,92 @@ class EmailRenderer:
         return rows_html
 
     @classmethod
-    def render_body(cls, group: Group, errors: Optional[List[str]] = None) -> str:
-        """Generate the HTML body of the email based on the group's expenses."""
-        tracked_categories: List[Category] = [
-            c for c in Category if c != Category.OTHER
+    def _render_debt_message(cls, group: Group, recipient: Optional[Person]) -> str:
+        """Renders the debt line, addressed to the recipient when there is one."""
+        p1, p2 = cls._ordered_members(group, recipient)
+        debt_amount = group.get_debt(p1, p2)
+
+        name1, name2 = escape(p1.name), escape(p2.name)
+
+        if recipient is p1:
+            if debt_amount > 0:
+                return f"You owe {name2}: <strong>{to_currency(debt_amount)}</strong>"
+            return f"{name2} owes you: <strong>{to_currency(abs(debt_amount))}</strong>"
+
+        if debt_amount > 0:
+            return f"{name1} owes {name2}: <strong>{to_currency(debt_amount)}</strong>"
+        return f"{name2} owes {name1}: <strong>{to_currency(abs(debt_amount))}</strong>"
+
+    @staticmethod
+    def _render_unassigned_section(group: Group) -> str:
+        """Renders the transactions on accounts that belong to no member, per account."""
+        if not group.unassigned:
+            return ""
+
+        totals: Dict[int, Decimal] = collections.defaultdict(Decimal)
+        for t in group.unassigned:
+            totals[t.account_number] += t.amount
+        items = "".join(
+            f"<li>Account {account}: {to_currency(total)}</li>"
+            for account, total in sorted(totals.items())
+        )
+        return f"""
+        <div style="background-color: #fffbe6; border-left: 5px solid #c19c00; padding: 15px; margin-top: 20px;">
+            <h3 style="color: #8a6d00; margin-top: 0; font-size: 18px;">Unassigned transactions</h3>
+            <p style="margin: 0 0 10px;">These accounts don't belong to anyone yet, so they are not included above. Assign them to a person to include them in future summaries.</p>
+            <ul style="margin-bottom: 0; padding-left: 20px;">
+                {items}
+            </ul>
+        </div>
+        """
+
+    @staticmethod
+    def _render_outstanding(group: Group, outstanding: Dict[str, object]) -> str:
+        """Renders the ledger balance remaining after settlements."""
+        amount = Decimal(str(outstanding.get("amount", 0)))
+        line = (
+            f"Outstanding balance after settlements: <strong>{to_currency(amount)}</strong>"
+        )
+        if not amount:
+            return line
+        names = {p.email: p.name for p in group.members}
+        debtor = names.get(str(outstanding["debtor"]), outstanding["debtor"])
+        creditor = names.get(str(outstanding["creditor"]), outstanding["creditor"])
+        return f"{line} ({escape(str(debtor))} owes {escape(str(creditor))})"
+
+    @classmethod
+    def render_body(
+        cls,
+        group: Group,
+        errors: Optional[List[str]] = None,
+        recipient: Optional[Person] = None,
+        outstanding: Optional[Dict[str, object]] = None,
+    ) -> str:
+        """
+        Generate the HTML body of the email based on the group's expenses.
+        When a recipient is given, the body is personalized: their row comes first
+        and their debt line is addressed to them. When the ledger balance is given
+        (outstanding), it is shown below the debt line.
+        """
+        tracked_categories: List[AnyCategory] = [
+            c for c in group.categories() if c != Category.OTHER
         ]
 
         # Build Table Headers
         headers_html = "<th></th>"
         for c in tracked_categories:
-            headers_html += f"<th>{c.value}</th>"
+            headers_html += f"<th>{escape(c.value)}</th>"
         headers_html += "<th>Total</th>"
 
         # Build Table Rows
-        rows_html = cls._render_rows(group, tracked_categories)
+        rows_html = cls._render_rows(group, tracked_categories, recipient)
 
         # Debt Message
         debt_html = ""
         if len(group.members) == 2:
-            p1, p2 = group.members
-            debt_amount = group.get_debt(p1, p2)
-
-            if debt_amount > 0:
-                msg = f"{p1.name} owes {p2.name}: <strong>{to_currency(debt_amount)}</strong>"
-            else:
-                msg = f"{p2.name} owes {p1.name}: <strong>{to_currency(abs(debt_amount))}</strong>"
+            msg = cls._render_debt_message(group, recipient)
+            if outstanding is not None:
+                msg += f"<br>{cls._render_outstanding(group, outstanding)}"
 
             debt_html = f"""
             <div style="margin-top: 25px; font-size: 16px; background-color: #f0f6ff; padding: 15px; border-radius: 4px; border: 1px solid #c7e0f4; color: #005a9e; text-align: center;">
@@ -144,6 +733,8 @@ class EmailRenderer:
                     </div>
 
                     {debt_html}
+
+                    {cls._render_unassigned_section(group)}
                 </div>
 
                 <!-- Footer -->
@@ -1,7 +1,10 @@
 """Service for sending emails via Azure Communication Services."""
 
+import base64
 import logging
 import os
+from dataclasses import dataclass
+from typing import Any, Callable
 
 from azure.communication.email import EmailClient
 from azure.identity import DefaultAzureCredential
@@ -11,6 +14,23 @@ from .email_renderer import EmailRenderer
 logger = logging.getLogger(__name__)
 
 
+@dataclass(frozen=True)
+class EmailAttachment:
+    """A file attached to an email."""
+
+    name: str
+    content_type: str
+    content: bytes
+
+    def to_acs(self) -> dict:
+        """Serializes the attachment in the shape ACS expects."""
+        return {
+            "name": self.name,
+            "contentType": self.content_type,
+            "contentInBase64": base64.b64encode(self.content).decode("ascii"),
+        }
+
+
 class EmailService:
     """Service for sending emails via Azure Communication Services."""
 
@@ -26,6 +46,8 @@ class EmailService:
             raise ValueError("SENDER_EMAIL environment variable is not set.")
 
         self._email_client: EmailClient | None = None
+        # Told how many emails went out after each send, to count usage
+        self.on_sent: Callable[[int], None] | None = None
 
     def _get_email_client(self) -> EmailClient:
         """Returns an EmailClient, creating it if necessary."""
@@ -39,47 +61,127 @@ class EmailService:
         self._email_client = EmailClient(endpoint=self._endpoint, credential=credential)
         return self._email_client
 
-    def send_email(self, to: list[str], subject: str, body: str) -> None:
+    def _build_message(
+        self,
+        to: list[str],
+        subject: str,
+        body: str,
+        attachments: list[EmailAttachment] | None = None,
+        high_priority: bool = False,
+    ) -> dict:
+        """Builds an ACS email message."""
+        message: dict[str, Any] = {
+            "senderAddress": self._sender,
+            "recipients": {
+                "to": [{"address": email} for email in to],
+            },
+            "content": {
+                "subject": subject,
+                "plainText": "Please view this email in a client that supports HTML.",
+                "html": body,
+            },
+        }
+        if attachments:
+            message["attachments"] = [a.to_acs() for a in attachments]
+        if high_priority:
+            # Mail clients flag x-priority 1 as high importance
+            message["headers"] = {"x-priority": "1"}
+        return message
+
+    @staticmethod
+    def _log_result(result: Any) -> str | None:
+        """Logs the message ID of a completed send and returns it, if known."""
+        # Extract message ID (result might be dict or object)
+        message_id = None
+        if isinstance(result, dict):
+            message_id = (
+                result.get("messageId") or result.get("message_id") or result.get("id")
+            )
+        else:
+            message_id = getattr(result, "message_id", None) or getattr(
+                result, "id", None
+            )
+
+        if message_id:
+            logger.info("Email sent with message ID: %s", message_id)
+        else:
+            logger.info("Email sent successfully")
+        return message_id
+
+    def _count_sent(self, count: int) -> None:
+        """Reports emails sent to on_sent. Counting never fails a send."""
+        if not self.on_sent or not count:
+            return
+        try:
+            self.on_sent(count)
+        except Exception as ex:  # pylint: disable=broad-exception-caught
+            logger.error("Error counting sent emails: %s", ex)
+
+    def send_email(
+        self,
+        to: list[str],
+        subject: str,
+        body: str,
+        attachments: list[EmailAttachment] | None = None,
+        high_priority: bool = False,
+    ) -> None:
         """Send an email using Azure Communication Services and Managed Identity."""
         try:
             email_client = self._get_email_client()
 
-            message = {
-                "senderAddress": self._sender,
-                "recipients": {
-                    "to": [{"address": email} for email in to],
-                },
-                "content": {
-                    "subject": subject,
-                    "plainText": "Please view this email in a client that supports HTML.",
-                    "html": body,
-                },
-            }
-
-            poller = email_client.begin_send(message)
-            result = poller.result()
-
-            # Extract message ID (result might be dict or object)
-            message_id = None
-            if isinstance(result, dict):
-                message_id = (
-                    result.get("messageId")
-                    or result.get("message_id")
-                    or result.get("id")
-                )
-            else:
-                message_id = getattr(result, "message_id", None) or getattr(
-                    result, "id", None
-                )
-
-            if message_id:
-                logger.info("Email sent with message ID: %s", message_id)
-            else:
-                logger.info("Email sent successfully")
+            poller = email_client.begin_send(
+                self._build_message(to, subject, body, attachments, high_priority)
+            )
+            self._log_result(poller.result())
 
         except Exception as ex:
             logger.error("Error sending email: %s", ex)
             raise
+        self._count_sent(1)
+
+    def send_emails(
+        self,
+        messages: list[tuple[list[str], str, str]],
+        attachments: list[EmailAttachment] | None = None,
+        on_delivered: Callable[[list[str], str | None], None] | None = None,
+    ) -> None:
+        """
+        Sends several (to, subject, body) emails, e.g. personalized copies of a summary.
+        All sends are started before waiting on any of them, so the ACS round trips overlap.
+        Any attachments are added to every message. on_delivered is told the
+        recipients and message ID of each send as it completes, so a caller can
+        record them and skip them when retrying.
+        Raises after attempting every message if any send failed.
+        """
+        email_client = self._get_email_client()
+
+        pollers = []
+        failures = 0
+        for to, subject, body in messages:
+            try:
+                message = self._build_message(to, subject, body, attachments)
+                pollers.append((to, email_client.begin_send(message)))
+            except Exception as ex:  # pylint: disable=broad-exception-caught
+                logger.error("Error sending email to %s: %s", to, ex)
+                failures += 1
+
+        for to, poller in pollers:
+            try:
+                message_id = self._log_result(poller.result())
+            except Exception as ex:  # pylint: disable=broad-exception-caught
+                logger.error("Error sending email to %s: %s", to, ex)
+                failures += 1
+                continue
+            if not on_delivered:
+                continue
+            try:
+                on_delivered(to, message_id)
+            except Exception as ex:  # pylint: disable=broad-exception-caught
+                logger.error("Error recording email sent to %s: %s", to, ex)
+
+        self._count_sent(len(messages) - failures)
+        if failures:
+            raise RuntimeError(f"{failures} of {len(messages)} emails failed to send.")
 
     def send_error_email(self, recipients: list[str], errors: list[str]) -> None:
         """Helper to send an email with validation errors."""
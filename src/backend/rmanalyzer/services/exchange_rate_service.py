@@ -0,0 +1,226 @@
+"""Service for converting foreign currency transactions to the home currency."""
+
+import dataclasses
+import json
+import logging
+import os
+from dataclasses import dataclass
+from datetime import date
+from decimal import Decimal
+from typing import Callable, Iterator
+from urllib import parse, request
+
+from ..models import Transaction
+from .database_service import DatabaseService
+
+logger = logging.getLogger(__name__)
+
+# Currency amounts are stored and reported in, unless HOME_CURRENCY is set
+DEFAULT_HOME_CURRENCY = "USD"
+
+# Rate source, unless EXCHANGE_RATE_PROVIDER is set
+DEFAULT_PROVIDER = "frankfurter"
+
+# Free API serving the European Central Bank's daily reference rates
+FRANKFURTER_URL = "https://api.frankfurter.app"
+
+# Seconds to wait for a rate source to answer
+REQUEST_TIMEOUT = 10
+
+
+@dataclass(frozen=True)
+class RateProvider:
+    """
+    A source of daily exchange rates. fetch returns units of each currency per
+    unit of a base currency on a day. Rates from sources whose past days don't
+    change are cached in the exchange rates table; others are fetched each time.
+    """
+
+    name: str
+    fetch: Callable[[str, date], dict[str, Decimal]]
+    cached: bool = True
+
+
+def _frankfurter_rates(base: str, day: date) -> dict[str, Decimal]:
+    """
+    The ECB reference rates for a day, via FRANKFURTER_URL. Days the ECB doesn't
+    publish (weekends, holidays) get the previous business day's rates.
+    """
+    url = os.environ.get("FRANKFURTER_URL", FRANKFURTER_URL).rstrip("/")
+    query = parse.urlencode({"from": base})
+    with request.urlopen(
+        f"{url}/{day.isoformat()}?{query}", timeout=REQUEST_TIMEOUT
+    ) as resp:
+        body = json.loads(resp.read().decode("utf-8"), parse_float=Decimal)
+    return {currency: Decimal(rate) for currency, rate in body["rates"].items()}
+
+
+def _static_rates(base: str, day: date) -> dict[str, Decimal]:
+    """
+    Fixed rates from EXCHANGE_RATES, a JSON object of units of each currency per
+    unit of the home currency (e.g. {"EUR": "0.92"}), the same every day.
+    """
+    del base, day  # Unused: the configured rates are already against home
+    rates = json.loads(os.environ.get("EXCHANGE_RATES") or "{}")
+    return {currency.upper(): Decimal(str(rate)) for currency, rate in rates.items()}
+
+
+# Rate sources by EXCHANGE_RATE_PROVIDER name
+PROVIDERS = {
+    provider.name: provider
+    for provider in (
+        RateProvider("frankfurter", _frankfurter_rates),
+        # Read fresh, so edits to EXCHANGE_RATES take effect on the next import
+        RateProvider("static", _static_rates, cached=False),
+    )
+}
+
+
+class ExchangeRateService:
+    """
+    Converts transactions made in other currencies to the home currency at the
+    rate on the day they were made, so summaries and card balances add up in one
+    currency.
+    """
+
+    def __init__(
+        self, db_service: DatabaseService, provider: RateProvider | None = None
+    ) -> None:
+        self.home_currency = os.environ.get(
+            "HOME_CURRENCY", DEFAULT_HOME_CURRENCY
+        ).upper()
+        self._provider = provider
+        self._db_service = db_service
+        self._rates: dict[date, dict[str, Decimal]] = {}
+
+    @property
+    def provider(self) -> RateProvider:
+        """
+        The rate source, resolved from EXCHANGE_RATE_PROVIDER when first needed,
+        so a misconfigured one only fails the conversions that use it.
+        """
+        if self._provider is None:
+            name = os.environ.get("EXCHANGE_RATE_PROVIDER", DEFAULT_PROVIDER)
+            if name not in PROVIDERS:
+                raise ValueError(
+                    f"Unknown EXCHANGE_RATE_PROVIDER: {name}, "
+                    f"expected one of {', '.join(sorted(PROVIDERS))}"
+                )
+            self._provider = PROVIDERS[name]
+        return self._provider
+
+    def rates(self, day: date) -> dict[str, Decimal]:
+        """
+        Units of each currency per unit of the home currency on a day. Finished
+        days are cached, in memory and in the exchange rates table; today's rates
+        may not be published yet, so they're fetched each time.
+        """
+        day = min(day, date.today())
+        if day in self._rates:
+            return self._rates[day]
+
+        provider = self.provider
+        cacheable = provider.cached and day < date.today()
+        rates = (
+            self._db_service.get_exchange_rates(self.home_currency, day)
+            if cacheable
+            else None
+        )
+        if rates is None:
+            logger.info("Fetching %s exchange rates for %s", provider.name, day)
+            rates = provider.fetch(self.home_currency, day)
+            if cacheable:
+                self._db_service.save_exchange_rates(self.home_currency, day, rates)
+        if cacheable:
+            self._rates[day] = rates
+        return rates
+
+    def normalize(
+        self, transactions: list[Transaction]
+    ) -> tuple[list[Transaction], list[str]]:
+        """
+        Returns the transactions with foreign currency amounts converted to the home
+        currency, keeping what was charged as the original amount, and errors for
+        those in currencies there's no rate for, which are left out. When a day's
+        rates can't be fetched, its transactions are kept as charged, without an
+        original amount, to be converted once the rates are available.
+        """
+        result = []
+        errors = []
+        for t, error in self._convert(transactions):
+            if error:
+                errors.append(error)
+            else:
+                result.append(t)
+        return result, errors
+
+    def convert_saved(
+        self, keyed: list[tuple[str, Transaction]]
+    ) -> list[tuple[str, Transaction]]:
+        """
+        Converts saved transactions, paired with their IDs, that couldn't be when
+        they were imported. Returns those converted now, paired with their IDs.
+        """
+        converted = zip(
+            (transaction_id for transaction_id, _ in keyed),
+            self._convert([t for _, t in keyed]),
+        )
+        return [
+            (transaction_id, t)
+            for transaction_id, (t, error) in converted
+            if not error and t.original_amount is not None
+        ]
+
+    def _convert(
+        self, transactions: list[Transaction]
+    ) -> Iterator[tuple[Transaction, str | None]]:
+        """
+        Yields each transaction converted, or as it was if its day's rates couldn't
+        be fetched, with an error if there's no rate for its currency.
+        """
+        # Today's rates aren't cached, so they're fetched once per call here.
+        # None marks a day whose rates couldn't be fetched.
+        day_rates: dict[date, dict[str, Decimal] | None] = {}
+        for t in transactions:
+            if not t.currency or t.currency == self.home_currency:
+                yield dataclasses.replace(t, currency=None), None
+                continue
+            if t.original_amount is not None:
+                # Already converted
+                yield t, None
+                continue
+
+            if t.date not in day_rates:
+                day_rates[t.date] = self._fetch_rates(t.date)
+            rates = day_rates[t.date]
+            if rates is None:
+                yield t, None
+                continue
+            rate = rates.get(t.currency)
+            if not rate:
+                error = (
+                    f"{t.name} on {t.date.isoformat()}: "
+                    f"No exchange rate from {t.currency} to {self.home_currency}"
+                )
+                yield t, error
+                continue
+            converted = dataclasses.replace(
+                t,
+                amount=(t.amount / rate).quantize(Decimal("0.01")),
+                original_amount=t.amount,
+            )
+            yield converted, None
+
+    def _fetch_rates(self, day: date) -> dict[str, Decimal] | None:
+        """
+        A day's rates, or None if the rate source couldn't be reached. A
+        misconfigured source is still an error.
+        """
+        provider = self.provider
+        try:
+            return self.rates(day)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logger.warning(
+                "Could not fetch %s exchange rates for %s: %s", provider.name, day, e
+            )
+            return None
@@ -4,29 +4,72 @@ import base64
 import json
 import logging
 import os
+import uuid
+from datetime import datetime, timezone
+from enum import Enum
 from typing import Any
 
 from azure.core.exceptions import ResourceExistsError
 from azure.identity import DefaultAzureCredential
 from azure.storage.queue import QueueClient
 
+from .blob_service import BlobKind, BlobService
 from .constants import AZURE_DEV_ACCOUNT_KEY
 
 logger = logging.getLogger(__name__)
 
+# Azure Queue caps visibility timeouts at 7 days
+MAX_VISIBILITY_DELAY = 7 * 24 * 60 * 60
 
-class QueueService:  # pylint: disable=too-few-public-methods
-    """Service for interacting with Azure Queue Storage."""
+# Azure Queue caps messages at 64KB; Base64 inflates payloads by 4/3
+MAX_MESSAGE_SIZE = 48 * 1024
+
+# Envelope key referencing a payload stored in blob (claim-check pattern)
+CLAIM_CHECK_KEY = "claim_check"
+
+# Message key linking a resubmitted message to its failure record
+FAILURE_ID_KEY = "failure_id"
+
+# Suffix of the queue the Functions host moves a message to once it has failed
+# every attempt, where it waits to be retried
+POISON_SUFFIX = "-poison"
+
+
+class QueuePriority(Enum):
+    """Processing queues, so interactive uploads aren't stuck behind bulk imports."""
+
+    INTERACTIVE = "interactive"
+    BACKFILL = "backfill"
+
+
+class QueueService:
+    """
+    Service for interacting with Azure Queue Storage.
+
+    Payloads over MAX_MESSAGE_SIZE are written to blob and a reference is enqueued instead;
+    pass a BlobService to enable this.
+    """
+
+    def __init__(self, blob_service: BlobService | None = None) -> None:
+        self._blob_service = blob_service
 
-    def __init__(self) -> None:
         queue_service_url = os.environ.get("QUEUE_SERVICE_URL")
         if not queue_service_url:
             raise ValueError("QUEUE_SERVICE_URL environment variable is not set.")
         self._queue_service_url: str = queue_service_url
 
-        self._queue_name = os.environ.get("QUEUE_NAME", "csv-processing")
+        self._queue_names: dict[QueuePriority, str] = {
+            QueuePriority.INTERACTIVE: os.environ.get("QUEUE_NAME", "csv-processing"),
+            QueuePriority.BACKFILL: os.environ.get(
+                "BACKFILL_QUEUE_NAME", "csv-backfill"
+            ),
+        }
         self._queue_clients: dict[str, QueueClient] = {}
 
+        # Probed by the self-test instead of the processing queues, whose
+        # triggers would pick the probe message up
+        self._probe_queue_name = os.environ.get("PROBE_QUEUE_NAME", "selftest")
+
     def _get_queue_client(self, queue_name: str) -> QueueClient:
         """Returns a QueueClient, ensuring the queue exists. Cached per instance."""
         if queue_name in self._queue_clients:
@@ -57,20 +100,139 @@ class QueueService:  # pylint: disable=too-few-public-methods
         self._queue_clients[queue_name] = client
         return client
 
-    def enqueue_message(self, message: dict[str, Any]) -> None:
+    def ensure_queues(self) -> list[str]:
+        """Creates the queue for every priority if missing. Returns the queue names."""
+        names = list(self._queue_names.values())
+        for name in names:
+            self._get_queue_client(name)
+        return names
+
+    def queue_status(self) -> list[dict[str, Any]]:
+        """
+        Each processing queue and its poison queue, with the approximate number of
+        messages waiting and the age in seconds of the oldest visible one (None
+        when there are none). Delayed messages are counted but not aged.
+        """
+        now = datetime.now(timezone.utc)
+        status = []
+        for priority, name in self._queue_names.items():
+            for queue_name, poison in ((name, False), (name + POISON_SUFFIX, True)):
+                client = self._get_queue_client(queue_name)
+                properties = client.get_queue_properties()
+                oldest = next(iter(client.peek_messages(max_messages=1)), None)
+                status.append(
+                    {
+                        "name": queue_name,
+                        "priority": priority.value,
+                        "poison": poison,
+                        "messages": properties.approximate_message_count,
+                        "oldestAgeSeconds": (
+                            int((now - oldest.inserted_on).total_seconds())
+                            if oldest and oldest.inserted_on
+                            else None
+                        ),
+                    }
+                )
+        return status
+
+    def probe(self) -> None:
+        """
+        Sends, receives and deletes a message on the probe queue, raising if any
+        step fails. Used by the startup self-test.
         """
-        Enqueues a message to the processing queue.
+        client = self._get_queue_client(self._probe_queue_name)
+        content = uuid.uuid4().hex
+        client.send_message(content)
+        for message in client.receive_messages(max_messages=32):
+            client.delete_message(message)
+            if message.content == content:
+                return
+        raise RuntimeError(f"Probe message not received from {self._probe_queue_name}")
+
+    def enqueue_message(
+        self,
+        message: dict[str, Any],
+        delay: int | None = None,
+        ttl: int | None = None,
+        priority: QueuePriority = QueuePriority.INTERACTIVE,
+    ) -> None:
+        """
+        Enqueues a message to the processing queue for the given priority.
         Message is JSON encoded and Base64 encoded (standard for Azure Functions Queue Trigger).
+
+        delay: seconds before the message becomes visible to consumers (max 7 days).
+        ttl: seconds the message lives in the queue; -1 never expires. Defaults to 7 days.
         """
-        client = self._get_queue_client(self._queue_name)
+        if delay is not None and not 0 <= delay <= MAX_VISIBILITY_DELAY:
+            raise ValueError(
+                f"delay must be between 0 and {MAX_VISIBILITY_DELAY} seconds."
+            )
+        if ttl is not None and ttl != -1 and ttl <= 0:
+            raise ValueError("ttl must be positive, or -1 to never expire.")
+        if delay and ttl and ttl != -1 and ttl <= delay:
+            raise ValueError("ttl must be longer than delay.")
+
+        client = self._get_queue_client(self._queue_names[priority])
 
         # Azure Functions usually expects base64 encoded string if not using binding native types,
         # but the python SDK handles generic text. Let's send plain JSON string;
         # the QueueTrigger will receive it.
         message_str = json.dumps(message)
+        if len(message_str.encode("utf-8")) > MAX_MESSAGE_SIZE:
+            message_str = json.dumps({CLAIM_CHECK_KEY: self._check_in(message_str)})
 
         # Base64 encoding is standard for Azure Functions Queue Trigger
         message_bytes = message_str.encode("utf-8")
         message_b64 = base64.b64encode(message_bytes).decode("utf-8")
 
-        client.send_message(message_b64)
+        client.send_message(message_b64, visibility_timeout=delay, time_to_live=ttl)
+
+    def resubmit(
+        self,
+        message: dict[str, Any],
+        failure_id: str,
+        priority: QueuePriority = QueuePriority.INTERACTIVE,
+    ) -> None:
+        """
+        Enqueues a failed message again, tagged with its failure record so the
+        outcome of the retry can be recorded against it.
+        """
+        self.enqueue_message({**message, FAILURE_ID_KEY: failure_id}, priority=priority)
+
+    def _check_in(self, message_str: str) -> str:
+        """Stores an oversized payload in blob and returns the claim-check blob name."""
+        if not self._blob_service:
+            raise ValueError(
+                f"Message exceeds {MAX_MESSAGE_SIZE} bytes and no BlobService is set."
+            )
+        blob_name = f"{uuid.uuid4()}.json"
+        self._blob_service.upload_blob(
+            BlobKind.MESSAGES, blob_name, message_str.encode("utf-8")
+        )
+        logger.info("Stored oversized queue payload as claim check: %s", blob_name)
+        return blob_name
+
+    def load_message(self, message_body: str) -> dict[str, Any]:
+        """
+        Decodes a received message body, rehydrating claim-checked payloads from blob.
+        """
+        data = json.loads(message_body)
+        if isinstance(data, dict) and CLAIM_CHECK_KEY in data:
+            if not self._blob_service:
+                raise ValueError(
+                    "Received a claim check but no BlobService is configured."
+                )
+            payload = self._blob_service.download_blob(
+                BlobKind.MESSAGES, data[CLAIM_CHECK_KEY]
+            )
+            return json.loads(payload.decode("utf-8"))
+        return data
+
+    def discard_message(self, message_body: str) -> None:
+        """
+        Deletes the stored payload of a claim-checked message once it has been processed.
+        Plain messages are ignored.
+        """
+        data = json.loads(message_body)
+        if isinstance(data, dict) and CLAIM_CHECK_KEY in data and self._blob_service:
+            self._blob_service.delete_blob(data[CLAIM_CHECK_KEY], BlobKind.MESSAGES)
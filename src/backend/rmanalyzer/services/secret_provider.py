@@ -0,0 +1,28 @@
+"""Provider of credentials for connecting to outside systems."""
+
+import os
+from typing import Callable
+
+# Only app settings named with this prefix are handed out as secrets, so a
+# connector can't be pointed at the app's own configuration
+SECRET_PREFIX = "SECRET_"
+
+
+class SecretProvider:
+    """
+    Looks up secrets by name. By default they're read from app settings, which
+    in Azure can be Key Vault references (@Microsoft.KeyVault(SecretUri=...))
+    the platform resolves, so secrets never sit in tables or API requests.
+    """
+
+    def __init__(self, lookup: Callable[[str], str | None] = os.environ.get) -> None:
+        self._lookup = lookup
+
+    def get(self, name: str) -> str:
+        """Returns a secret. Raises ValueError if it's not a secret name or unset."""
+        if not name.startswith(SECRET_PREFIX):
+            raise ValueError(f"Secret names must start with {SECRET_PREFIX}: {name}")
+        value = self._lookup(name)
+        if not value:
+            raise ValueError(f"Secret {name} is not set")
+        return value
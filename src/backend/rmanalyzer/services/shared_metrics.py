@@ -0,0 +1,129 @@
+"""
+Metric counts shared by every worker instance. Each worker adds to its own counts
+per bucket of BUCKET_MINUTES in memory, and saves them to the metrics table at
+most every FLUSH_SECONDS, one entity per bucket and worker, so workers never
+write the same entity. Readers add up every worker's buckets in a window.
+"""
+
+import collections
+import logging
+import threading
+import time
+import uuid
+from datetime import datetime, timedelta
+from typing import Callable, Iterable, Optional
+
+__all__ = [
+    "BUCKET_MINUTES",
+    "FLUSH_SECONDS",
+    "WORKER_ID",
+    "bucket",
+    "window",
+    "add",
+    "configure",
+    "flush",
+    "total",
+]
+
+logger = logging.getLogger(__name__)
+
+# Minutes of counts each saved bucket holds
+BUCKET_MINUTES = 5
+
+# Longest a worker holds counts before saving them, so stored counts lag by up to this
+FLUSH_SECONDS = 60
+
+# Tells this worker's saved counts from other instances'
+WORKER_ID = uuid.uuid4().hex
+
+# Saves a worker's counts for a bucket: saver(bucket, worker, counts per group)
+Saver = Callable[[str, str, dict[str, dict[str, int]]], None]
+
+# Counts per bucket, then per group, e.g. one endpoint's table requests
+_COUNTS: dict[str, dict[str, collections.Counter[str]]] = {}
+
+# Buckets added to since they were last saved
+_DIRTY: set[str] = set()
+
+_LOCK = threading.Lock()
+_STATE: dict = {"saver": None, "flushedAt": time.monotonic()}
+
+
+def bucket(moment: datetime) -> str:
+    """The bucket a moment falls in, named by when it starts (YYYY-MM-DDTHH:MM)."""
+    start = moment.replace(
+        minute=moment.minute - moment.minute % BUCKET_MINUTES, second=0, microsecond=0
+    )
+    return start.strftime("%Y-%m-%dT%H:%M")
+
+
+def window(minutes: int, now: datetime) -> tuple[str, str]:
+    """
+    The first bucket and the bucket after the last of the whole buckets ending in
+    the given minutes before now. The bucket now falls in is still being counted
+    and is left out.
+    """
+    return bucket(now - timedelta(minutes=minutes)), bucket(now)
+
+
+def add(group: str, **counts: int) -> None:
+    """Adds to a group's counts in the current bucket."""
+    key = bucket(datetime.now())
+    with _LOCK:
+        groups = _COUNTS.setdefault(key, {})
+        groups.setdefault(group, collections.Counter()).update(counts)
+        _DIRTY.add(key)
+
+
+def configure(saver: Optional[Saver]) -> None:
+    """Sets where flush saves this worker's counts. Nothing is saved until it is."""
+    _STATE["saver"] = saver
+
+
+def flush(force: bool = False) -> None:
+    """
+    Saves the buckets added to since they were last saved, once FLUSH_SECONDS have
+    passed or when forced. Ended buckets are dropped from memory once saved. A
+    failed save is logged and tried again on the next flush.
+    """
+    saver: Optional[Saver] = _STATE["saver"]
+    if saver is None:
+        return
+    with _LOCK:
+        if not _DIRTY:
+            return
+        if not force and time.monotonic() - _STATE["flushedAt"] < FLUSH_SECONDS:
+            return
+        _STATE["flushedAt"] = time.monotonic()
+        pending = {
+            key: {group: dict(c) for group, c in _COUNTS[key].items()}
+            for key in _DIRTY
+        }
+        _DIRTY.clear()
+
+    # Saved outside the lock, since saving makes table requests that are counted
+    current = bucket(datetime.now())
+    for key, counts in sorted(pending.items()):
+        try:
+            saver(key, WORKER_ID, counts)
+        except Exception as e:  # pylint: disable=broad-exception-caught
+            logger.warning("Could not save metric counts for %s: %s", key, e)
+            with _LOCK:
+                _DIRTY.add(key)
+            continue
+        with _LOCK:
+            if key < current and key not in _DIRTY:
+                _COUNTS.pop(key, None)
+
+
+def total(
+    saved: Iterable[dict[str, dict[str, int]]],
+) -> dict[str, collections.Counter[str]]:
+    """Adds up saved counts, e.g. every worker's buckets in a window, per group."""
+    totals: dict[str, collections.Counter[str]] = collections.defaultdict(
+        collections.Counter
+    )
+    for counts in saved:
+        for group, values in counts.items():
+            totals[group].update(values)
+    return dict(totals)
@@ -0,0 +1,171 @@
+"""Counts of Table Storage requests per operation, and what they cost."""
+
+import collections
+import contextlib
+import contextvars
+import math
+import os
+from datetime import datetime
+from typing import Any, Iterable, Iterator
+
+from . import shared_metrics
+
+__all__ = [
+    "InstrumentedTableClient",
+    "storage_operation",
+    "current_operation",
+    "storage_ops",
+    "monthly_cost",
+]
+
+# Entities a query returns per page, each page a billed request
+QUERY_PAGE_SIZE = 1000
+
+# Table Storage bills every request, read or write, at the same rate (USD)
+DEFAULT_PRICE_PER_10K_TRANSACTIONS = "0.00036"
+
+# Average days in a month, for projecting a month of usage
+DAYS_PER_MONTH = 30.44
+
+# Requests attributed to no HTTP or queue handler, e.g. timer jobs
+BACKGROUND_OPERATION = "background"
+
+_CURRENT_OPERATION: contextvars.ContextVar[str] = contextvars.ContextVar(
+    "storage_operation", default=BACKGROUND_OPERATION
+)
+
+# Prefix of the shared metric groups holding each operation's counts
+GROUP_PREFIX = "table:"
+
+# Counts storage_ops reports per operation
+COUNTERS = ("requests", "entitiesRead", "entitiesWritten", "batches")
+
+
+@contextlib.contextmanager
+def storage_operation(name: str) -> Iterator[None]:
+    """Attributes the table requests made inside the block to an operation."""
+    token = _CURRENT_OPERATION.set(name)
+    try:
+        yield
+    finally:
+        _CURRENT_OPERATION.reset(token)
+
+
+def current_operation() -> str:
+    """The operation table requests are currently attributed to."""
+    return _CURRENT_OPERATION.get()
+
+
+def _count(**counts: int) -> None:
+    shared_metrics.add(GROUP_PREFIX + current_operation(), **counts)
+
+
+def storage_ops(
+    totals: dict[str, collections.Counter[str]], since: datetime
+) -> dict[str, Any]:
+    """
+    The table requests, entities read and written, and batches per operation in
+    shared metric counts added up since a moment, e.g. every worker's. Groups
+    that aren't table counts are left out.
+    """
+    operations = {
+        group[len(GROUP_PREFIX) :]: {key: c.get(key, 0) for key in COUNTERS}
+        for group, c in sorted(totals.items())
+        if group.startswith(GROUP_PREFIX)
+    }
+    summed = collections.Counter()
+    for counts in operations.values():
+        summed.update(counts)
+    return {
+        "since": since.isoformat(),
+        "operations": operations,
+        "totals": {key: summed[key] for key in COUNTERS},
+    }
+
+
+def monthly_cost(requests: int, since: datetime, now: datetime) -> dict[str, float]:
+    """
+    Projects a month of table requests from the rate seen since a moment, priced
+    at TABLE_PRICE_PER_10K_TRANSACTIONS. Storage capacity isn't included.
+    """
+    price = float(
+        os.environ.get(
+            "TABLE_PRICE_PER_10K_TRANSACTIONS", DEFAULT_PRICE_PER_10K_TRANSACTIONS
+        )
+    )
+    days = max((now - since).total_seconds() / 86400, 1 / 1440)
+    projected = requests / days * DAYS_PER_MONTH
+    return {
+        "projectedRequests": round(projected),
+        "pricePer10k": price,
+        "estimate": round(projected / 10_000 * price, 4),
+    }
+
+
+class InstrumentedTableClient:
+    """
+    Wraps a TableClient, counting each request, entity read or written, and batch
+    against the current operation. Anything not counted passes straight through.
+    """
+
+    def __init__(self, client: Any) -> None:
+        self._client = client
+
+    def __getattr__(self, name: str) -> Any:
+        return getattr(self._client, name)
+
+    def _counted_query(self, entities: Iterable[Any]) -> Iterator[Any]:
+        read = 0
+        try:
+            for entity in entities:
+                read += 1
+                yield entity
+        finally:
+            pages = max(math.ceil(read / QUERY_PAGE_SIZE), 1)
+            _count(requests=pages, entitiesRead=read)
+
+    def query_entities(self, *args: Any, **kwargs: Any) -> Iterator[Any]:
+        """Queries entities, counting a request per page read."""
+        return self._counted_query(self._client.query_entities(*args, **kwargs))
+
+    def list_entities(self, *args: Any, **kwargs: Any) -> Iterator[Any]:
+        """Lists entities, counting a request per page read."""
+        return self._counted_query(self._client.list_entities(*args, **kwargs))
+
+    def get_entity(self, *args: Any, **kwargs: Any) -> Any:
+        """Reads one entity."""
+        _count(requests=1)
+        entity = self._client.get_entity(*args, **kwargs)
+        _count(entitiesRead=1)
+        return entity
+
+    def _write(self, method: str, *args: Any, **kwargs: Any) -> Any:
+        _count(requests=1, entitiesWritten=1)
+        return getattr(self._client, method)(*args, **kwargs)
+
+    def create_entity(self, *args: Any, **kwargs: Any) -> Any:
+        """Inserts an entity."""
+        return self._write("create_entity", *args, **kwargs)
+
+    def upsert_entity(self, *args: Any, **kwargs: Any) -> Any:
+        """Inserts or updates an entity."""
+        return self._write("upsert_entity", *args, **kwargs)
+
+    def update_entity(self, *args: Any, **kwargs: Any) -> Any:
+        """Updates an entity."""
+        return self._write("update_entity", *args, **kwargs)
+
+    def delete_entity(self, *args: Any, **kwargs: Any) -> Any:
+        """Deletes an entity."""
+        return self._write("delete_entity", *args, **kwargs)
+
+    def submit_transaction(self, operations: Iterable[Any], **kwargs: Any) -> Any:
+        """Submits a batch, a single request however many entities it writes."""
+        operations = list(operations)
+        _count(requests=1, batches=1, entitiesWritten=len(operations))
+        return self._client.submit_transaction(operations, **kwargs)
+
+    def create_table(self, *args: Any, **kwargs: Any) -> Any:
+        """Creates the table."""
+        _count(requests=1)
+        return self._client.create_table(*args, **kwargs)
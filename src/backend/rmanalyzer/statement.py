@@ -0,0 +1,164 @@
+"""
+The statement formats uploads can be in, and telling which one a file is.
+Each format is a Parser; the upload's extension picks it when only one format
+uses that extension, and the file's content decides otherwise (e.g. a .csv that
+is a Mint export, or a file with no extension at all).
+"""
+
+import os
+import re
+from dataclasses import dataclass
+from typing import Callable, Dict, List, Optional, Sequence, Tuple
+
+from rmanalyzer.mint import get_mint_transactions, looks_like_mint
+from rmanalyzer.models import Transaction
+from rmanalyzer.ofx import OFX_EXTENSIONS, get_ofx_transactions
+from rmanalyzer.qif import QIF_EXTENSIONS, get_qif_transactions, looks_like_qif
+from rmanalyzer.utils import get_transactions
+from rmanalyzer.xlsx import XLSX_EXTENSIONS, get_xlsx_transactions
+
+__all__ = [
+    "Parser",
+    "PARSERS",
+    "DEFAULT_FORMAT",
+    "STATEMENT_EXTENSIONS",
+    "get_parser",
+    "detect_format",
+    "parse",
+]
+
+# Leading bytes of a file read to recognize its format
+SNIFF_SIZE = 4096
+
+# Format of files nothing else recognizes: the app's own CSV layout
+DEFAULT_FORMAT = "csv"
+
+# Upload names start with the time they were uploaded, e.g. 20250115093000_
+_UPLOAD_TIMESTAMP = re.compile(r"^\d{14}_")
+
+
+@dataclass(frozen=True)
+class Parser:
+    """
+    A statement format. sniff tells from a file's leading bytes whether it is in
+    the format; parse reads the whole file, given its name and the categories
+    the household added, into transactions and the errors for the rows it skipped.
+    """
+
+    name: str
+    extensions: Tuple[str, ...]
+    content_type: str
+    sniff: Callable[[bytes], bool]
+    parse: Callable[[bytes, str, Sequence[str]], Tuple[List[Transaction], List[str]]]
+
+
+def _text(content: bytes) -> str:
+    """A text format's content; statements are UTF-8."""
+    return content.decode("utf-8")
+
+
+def _sniff_text(content: bytes) -> str:
+    """Leading bytes as text; a character cut off at the end is replaced."""
+    return content.decode("utf-8", errors="replace")
+
+
+def _file_account_number(file_name: str) -> Optional[int]:
+    """
+    The last four digits in a file's name, e.g. 1234 in checking-1234.qif, for
+    formats that may not say which account they're for.
+    """
+    stem = os.path.splitext(os.path.basename(file_name))[0]
+    digits = re.sub(r"\D", "", _UPLOAD_TIMESTAMP.sub("", stem))
+    return int(digits[-4:]) if digits else None
+
+
+def _is_ofx(content: bytes) -> bool:
+    """OFX 1.x starts with an OFXHEADER line, OFX 2.x with XML around <OFX>."""
+    head = _sniff_text(content).upper()
+    return "OFXHEADER" in head or "<OFX>" in head
+
+
+# In the order content is checked, most distinctive first. The app's own CSV
+# layout recognizes anything, so it goes last.
+PARSERS: Tuple[Parser, ...] = (
+    Parser(
+        name="xlsx",
+        extensions=XLSX_EXTENSIONS,
+        content_type=(
+            "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+        ),
+        # Workbooks are zip archives
+        sniff=lambda content: content.startswith(b"PK\x03\x04"),
+        parse=lambda content, _, added: get_xlsx_transactions(content, added),
+    ),
+    Parser(
+        name="ofx",
+        extensions=OFX_EXTENSIONS,
+        content_type="application/x-ofx",
+        sniff=_is_ofx,
+        parse=lambda content, *_: get_ofx_transactions(_text(content)),
+    ),
+    Parser(
+        name="qif",
+        extensions=QIF_EXTENSIONS,
+        content_type="application/qif",
+        sniff=lambda content: looks_like_qif(_sniff_text(content)),
+        parse=lambda content, file_name, added: get_qif_transactions(
+            _text(content), _file_account_number(file_name), added
+        ),
+    ),
+    Parser(
+        name="mint",
+        extensions=(".csv",),
+        content_type="text/csv",
+        sniff=lambda content: looks_like_mint(_sniff_text(content)),
+        parse=lambda content, *_: get_mint_transactions(_text(content)),
+    ),
+    Parser(
+        name=DEFAULT_FORMAT,
+        extensions=(".csv",),
+        content_type="text/csv",
+        sniff=lambda _: True,
+        parse=lambda content, _, added: get_transactions(_text(content), added),
+    ),
+)
+
+_PARSERS_BY_NAME: Dict[str, Parser] = {parser.name: parser for parser in PARSERS}
+
+# Extensions of files that are imported as statements
+STATEMENT_EXTENSIONS = tuple(
+    sorted({extension for parser in PARSERS for extension in parser.extensions})
+)
+
+
+def get_parser(file_format: str) -> Parser:
+    """The parser for a format; unknown formats are the app's own CSV layout."""
+    return _PARSERS_BY_NAME.get(file_format, _PARSERS_BY_NAME[DEFAULT_FORMAT])
+
+
+def detect_format(file_name: str, content: bytes) -> str:
+    """
+    The format of an uploaded statement. An extension only one format uses
+    settles it; otherwise the formats using the extension (or every format, for
+    an extension none use) are checked against the file's content.
+    """
+    candidates = [p for p in PARSERS if file_name.lower().endswith(p.extensions)]
+    if len(candidates) == 1:
+        return candidates[0].name
+
+    head = content[:SNIFF_SIZE]
+    for parser in candidates or PARSERS:
+        if parser.sniff(head):
+            return parser.name
+    return DEFAULT_FORMAT
+
+
+def parse(
+    file_format: str, content: bytes, file_name: str, added: Sequence[str] = ()
+) -> Tuple[List[Transaction], List[str]]:
+    """
+    Parses an uploaded statement with the parser for its format. added names the
+    categories the household added, which rows may use.
+    Returns (List[Transaction], List[str]) where the second list contains error messages.
+    """
+    return get_parser(file_format).parse(content, file_name, added)
@@ -0,0 +1,42 @@
+"""
+Household usage: how many transactions are stored, how much blob storage is
+used and how many emails were sent this month, against optional soft limits
+that warn the admins rather than block anything.
+"""
+
+import os
+from typing import Any, Dict, List
+
+__all__ = ["USAGE_METRICS", "soft_limits", "over_limits"]
+
+# Usage metrics with the setting that sets each one's soft limit. Storage is
+# limited in megabytes but measured in bytes.
+USAGE_METRICS = {
+    "transactions": "USAGE_LIMIT_TRANSACTIONS",
+    "storageBytes": "USAGE_LIMIT_STORAGE_MB",
+    "emailsSent": "USAGE_LIMIT_EMAILS",
+}
+
+MEGABYTE = 1024 * 1024
+
+
+def soft_limits() -> Dict[str, int]:
+    """The soft limits that are set, by metric. Unset or invalid ones are skipped."""
+    limits = {}
+    for metric, setting in USAGE_METRICS.items():
+        try:
+            limit = int(os.environ.get(setting, ""))
+        except ValueError:
+            continue
+        if limit > 0:
+            limits[metric] = limit * MEGABYTE if metric == "storageBytes" else limit
+    return limits
+
+
+def over_limits(usage: Dict[str, int], limits: Dict[str, int]) -> List[Dict[str, Any]]:
+    """The metrics at or over their soft limit, with their usage and limit."""
+    return [
+        {"metric": metric, "usage": usage[metric], "limit": limit}
+        for metric, limit in limits.items()
+        if usage.get(metric, 0) >= limit
+    ]
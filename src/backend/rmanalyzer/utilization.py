@@ -0,0 +1,40 @@
+"""
+Credit utilization: the share of available credit in use, per card and overall.
+"""
+
+from decimal import Decimal
+from typing import Any, Dict, List, Optional
+
+__all__ = ["card_utilization", "aggregate_utilization"]
+
+
+def _balance(account: Dict[str, Any]) -> Decimal:
+    return max(Decimal(str(account.get("balance") or 0)), Decimal("0"))
+
+
+def _limit(account: Dict[str, Any]) -> Decimal:
+    return Decimal(str(account.get("limit") or 0))
+
+
+def card_utilization(account: Dict[str, Any]) -> Optional[float]:
+    """A card's balance as a share of its limit, None when it has no limit."""
+    limit = _limit(account)
+    if limit <= 0:
+        return None
+    return round(float(_balance(account) / limit), 4)
+
+
+def aggregate_utilization(accounts: List[Dict[str, Any]]) -> Dict[str, Any]:
+    """
+    Total balances over total limits across the cards that have a limit, the
+    figure credit scoring looks at alongside each card's own. `ratio` is None
+    when no card has a limit.
+    """
+    cards = [a for a in accounts if _limit(a) > 0]
+    balance = sum((_balance(a) for a in cards), start=Decimal("0"))
+    limit = sum((_limit(a) for a in cards), start=Decimal("0"))
+    return {
+        "balance": float(balance),
+        "limit": float(limit),
+        "ratio": round(float(balance / limit), 4) if limit else None,
+    }
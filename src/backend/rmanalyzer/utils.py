@@ -3,18 +3,45 @@ Utility functions for parsing and formatting.
 """
 
 import csv
+import json
+import logging
+import math
+import os
+import re
 from datetime import date, datetime
 from decimal import Decimal, InvalidOperation
-from typing import Dict, List, Optional, Tuple
-
-from .models import Category, IgnoredFrom, Transaction
-
-__all__ = ["parse_date", "to_transaction", "get_transactions", "to_currency"]
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from .models import (
+    AnyCategory,
+    Category,
+    IgnoredFrom,
+    Transaction,
+    all_categories,
+    parse_category,
+)
+
+__all__ = [
+    "parse_date",
+    "category_aliases",
+    "to_category",
+    "to_transaction",
+    "get_transactions",
+    "to_currency",
+    "unquote_formula",
+]
 
 
 # Supported date formats
 DATE_FORMATS = ["%Y-%m-%d", "%m/%d/%Y", "%d/%m/%Y", "%Y/%m/%d"]
 
+# Spreadsheets run a cell starting with one of these as a formula, so exports
+# quote such cells with a leading '
+FORMULA_PREFIXES = ("=", "+", "-", "@", "\t", "\r")
+
+# ISO 4217 currency codes, e.g. EUR
+CURRENCY_CODE = re.compile(r"^[A-Z]{3}$")
+
 
 def parse_date(date_str: str) -> date:
     """Parse a date string using supported formats."""
@@ -26,16 +53,61 @@ def parse_date(date_str: str) -> date:
     raise ValueError(f"Date '{date_str}' does not match any supported format.")
 
 
+def category_aliases(added: Sequence[str] = ()) -> Dict[str, AnyCategory]:
+    """
+    Other names uploads use for categories, from CATEGORY_ALIASES: a JSON object
+    such as {"Restaurants": "Dining & Drinks"}. Keyed by lowercase name. Aliases
+    for a category that isn't built in or among those added are logged and left
+    out.
+    """
+    try:
+        configured = json.loads(os.environ.get("CATEGORY_ALIASES") or "{}")
+    except ValueError:
+        logging.warning("CATEGORY_ALIASES is not valid JSON and was ignored")
+        return {}
+    aliases = {}
+    for alias, name in configured.items():
+        if Category.is_valid(name, added):
+            aliases[alias.strip().lower()] = parse_category(name, added)
+        else:
+            logging.warning("Category alias '%s' is for unknown '%s'", alias, name)
+    return aliases
+
+
+def to_category(
+    value: str, aliases: Dict[str, AnyCategory], added: Sequence[str] = ()
+) -> Optional[AnyCategory]:
+    """
+    The category a name stands for: the category itself, built in or among
+    those added, in any case, or the one it is an alias of. Blank is Other.
+    None if the name isn't known.
+    """
+    if not value:
+        return Category.OTHER
+    if Category.is_valid(value, added):
+        return parse_category(value, added)
+    for category in all_categories(added):
+        if category.value.lower() == value.lower():
+            return category
+    return aliases.get(value.lower())
+
+
 def to_transaction(  # pylint: disable=too-many-return-statements
     row: Dict[str, str],
+    aliases: Optional[Dict[str, AnyCategory]] = None,
+    added: Sequence[str] = (),
 ) -> Tuple[Optional[Transaction], Optional[str]]:
     """
-    Parses a CSV row into a Transaction object.
+    Parses a CSV row into a Transaction object. A category that isn't built in
+    or among those added is an error, unless UNKNOWN_CATEGORIES is "other" to
+    import it as Other.
     Returns (Transaction, None) if successful, or (None, error_message) if not.
     """
     # Normalize keys and values
     try:
-        clean_row = {k.strip(): v.strip() for k, v in row.items() if k}
+        clean_row = {
+            k.strip(): unquote_formula(v.strip()) for k, v in row.items() if k
+        }
     except AttributeError:
         return None, "Unexpected error: row is not a valid dictionary"
 
@@ -67,12 +139,22 @@ def to_transaction(  # pylint: disable=too-many-return-statements
         transaction_amount = Decimal(clean_row.get("Amount", "0"))
     except (ValueError, InvalidOperation):
         return None, f"Invalid or missing 'Amount': {clean_row.get('Amount')}"
+    # Amounts are stored as doubles, which can't hold infinities or anything larger
+    if not transaction_amount.is_finite() or not math.isfinite(
+        float(transaction_amount)
+    ):
+        return None, f"Invalid or missing 'Amount': {clean_row.get('Amount')}"
 
     # Category (Optional)
-    try:
-        transaction_category = Category(clean_row.get("Category"))
-    except ValueError:
-        # Treat unknown categories as OTHER
+    category_name = clean_row.get("Category", "")
+    transaction_category = to_category(
+        category_name,
+        category_aliases(added) if aliases is None else aliases,
+        added,
+    )
+    if transaction_category is None:
+        if os.environ.get("UNKNOWN_CATEGORIES", "reject").lower() != "other":
+            return None, f"Unknown 'Category': {category_name}"
         transaction_category = Category.OTHER
 
     # Ignored From (Optional)
@@ -85,6 +167,11 @@ def to_transaction(  # pylint: disable=too-many-return-statements
             f"Invalid 'Ignored From' value: {clean_row.get('Ignored From')}",
         )
 
+    # Currency (Optional): ISO 4217 code; blank is the home currency
+    transaction_currency = clean_row.get("Currency", "").upper() or None
+    if transaction_currency and not CURRENCY_CODE.match(transaction_currency):
+        return None, f"Invalid 'Currency': {clean_row.get('Currency')}"
+
     return (
         Transaction(
             transaction_date,
@@ -93,27 +180,39 @@ def to_transaction(  # pylint: disable=too-many-return-statements
             transaction_amount,
             transaction_category,
             transaction_ignore,
+            currency=transaction_currency,
         ),
         None,
     )
 
 
-def get_transactions(content: str) -> Tuple[List[Transaction], List[str]]:
+def unquote_formula(value: str) -> str:
+    """Strips the quote an export put before a cell that looks like a formula."""
+    if value.startswith("'") and value[1:].startswith(FORMULA_PREFIXES):
+        return value[1:]
+    return value
+
+
+def get_transactions(
+    content: str, added: Sequence[str] = ()
+) -> Tuple[List[Transaction], List[str]]:
     """
-    Parses CSV content into a list of Transactions.
+    Parses CSV content into a list of Transactions. added names the categories
+    the household added, which rows may use as well as the built-in ones.
     Returns (List[Transaction], List[str]) where the second list contains error messages.
     """
     lines = [line for line in content.splitlines() if line.strip()]
     rows = csv.DictReader(lines)
     transactions = []
     errors = []
+    aliases = category_aliases(added)
 
     # Handle case where fieldnames might have whitespace
     if rows.fieldnames:
         rows.fieldnames = [name.strip() for name in rows.fieldnames]
 
     for i, row in enumerate(rows, start=1):
-        transaction, error = to_transaction(row)
+        transaction, error = to_transaction(row, aliases, added)
         if transaction:
             transactions.append(transaction)
         else:
@@ -0,0 +1,199 @@
+"""
+Declarative validation for HTTP query parameters and JSON bodies.
+"""
+
+import math
+import re
+from collections.abc import Mapping
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, List, Optional
+
+__all__ = ["FieldError", "Schema", "MONTH_PATTERN", "DATE_PATTERN"]
+
+
+# Months are addressed as YYYY-MM throughout the API
+MONTH_PATTERN = r"^\d{4}-(0[1-9]|1[0-2])$"
+DATE_PATTERN = r"^\d{4}-(0[1-9]|1[0-2])-(0[1-9]|[12]\d|3[01])$"
+
+
+@dataclass(frozen=True)
+class FieldError:
+    """A validation failure for a single field."""
+
+    field: str
+    message: str
+
+    def to_dict(self) -> Dict[str, str]:
+        """Serialize the error for a JSON response."""
+        return {"field": self.field, "message": self.message}
+
+
+@dataclass(frozen=True)
+class _Rule:
+    """A single field rule within a schema."""
+
+    name: str
+    kind: str
+    required: bool = False
+    pattern: Optional[str] = None
+    choices: Optional[List[str] | Callable[[], List[str]]] = None
+    minimum: Optional[float] = None
+    maximum: Optional[float] = None
+    check: Optional[Callable[[Any], Optional[str]]] = None
+    max_length: Optional[int] = None
+
+
+@dataclass
+class Schema:
+    """
+    Builder for a set of field rules.
+
+    Example:
+        Schema().string("month", pattern=MONTH_PATTERN).number("amount", required=True)
+    """
+
+    rules: List[_Rule] = field(default_factory=list)
+
+    def string(
+        self,
+        name: str,
+        required: bool = False,
+        pattern: Optional[str] = None,
+        choices: Optional[List[str] | Callable[[], List[str]]] = None,
+        max_length: Optional[int] = None,
+    ) -> "Schema":
+        """
+        Add a string field, optionally matched against a regex or a set. The set
+        can be a function returning it, for sets that change while running.
+        """
+        self.rules.append(
+            _Rule(name, "string", required, pattern, choices, max_length=max_length)
+        )
+        return self
+
+    def number(
+        self,
+        name: str,
+        required: bool = False,
+        minimum: Optional[float] = None,
+        maximum: Optional[float] = None,
+    ) -> "Schema":
+        """
+        Add a numeric field. Numeric strings are accepted (query params, and
+        amounts sent as strings as API v2 writes them).
+        """
+        self.rules.append(
+            _Rule(name, "number", required, minimum=minimum, maximum=maximum)
+        )
+        return self
+
+    def integer(
+        self,
+        name: str,
+        required: bool = False,
+        minimum: Optional[float] = None,
+        maximum: Optional[float] = None,
+    ) -> "Schema":
+        """Add an integer field. Integer strings are accepted (query params)."""
+        self.rules.append(
+            _Rule(name, "integer", required, minimum=minimum, maximum=maximum)
+        )
+        return self
+
+    def boolean(self, name: str, required: bool = False) -> "Schema":
+        """Add a boolean field. 'true'/'false' strings are accepted (query params)."""
+        self.rules.append(_Rule(name, "boolean", required))
+        return self
+
+    def array(
+        self,
+        name: str,
+        required: bool = False,
+        check: Optional[Callable[[Any], Optional[str]]] = None,
+        max_length: Optional[int] = None,
+    ) -> "Schema":
+        """Add a JSON array field. `check` is applied to each item and returns an error or None."""
+        self.rules.append(
+            _Rule(name, "array", required, check=check, max_length=max_length)
+        )
+        return self
+
+    def mapping(self, name: str, required: bool = False) -> "Schema":
+        """Add a JSON object field."""
+        self.rules.append(_Rule(name, "mapping", required))
+        return self
+
+    def validate(self, data: Any) -> List[FieldError]:
+        """Validate a mapping against the schema and return all field errors."""
+        if not isinstance(data, Mapping):
+            return [FieldError("body", "must be a JSON object")]
+
+        errors: List[FieldError] = []
+        for rule in self.rules:
+            value = data.get(rule.name)
+            if value is None or value == "":
+                if rule.required:
+                    errors.append(FieldError(rule.name, "is required"))
+                continue
+
+            message = _check_value(rule, value)
+            if message:
+                errors.append(FieldError(rule.name, message))
+        return errors
+
+
+def _check_value(rule: _Rule, value: Any) -> Optional[str]:
+    """Returns an error message if the value violates the rule, otherwise None."""
+    if rule.kind == "string":
+        if not isinstance(value, str):
+            return "must be a string"
+        if rule.max_length is not None and len(value) > rule.max_length:
+            return f"must be at most {rule.max_length} characters"
+        if rule.pattern and not re.match(rule.pattern, value):
+            return "has an invalid format"
+        choices = rule.choices() if callable(rule.choices) else rule.choices
+        if choices and value not in choices:
+            return f"must be one of: {', '.join(choices)}"
+        return None
+
+    if rule.kind in ("number", "integer"):
+        if isinstance(value, bool):
+            return f"must be a {rule.kind}"
+        try:
+            num = float(value)
+        except (TypeError, ValueError, OverflowError):
+            return f"must be a {rule.kind}"
+        if not math.isfinite(num):
+            return f"must be a {rule.kind}"
+        # Strings must be written as integers, since callers convert them with int
+        if rule.kind == "integer" and (
+            not num.is_integer()
+            or (isinstance(value, str) and not re.fullmatch(r"\s*[+-]?\d+\s*", value))
+        ):
+            return "must be an integer"
+        if rule.minimum is not None and num < rule.minimum:
+            return f"must be at least {rule.minimum:g}"
+        if rule.maximum is not None and num > rule.maximum:
+            return f"must be at most {rule.maximum:g}"
+        return None
+
+    if rule.kind == "boolean":
+        if isinstance(value, bool) or str(value).lower() in ("true", "false"):
+            return None
+        return "must be a boolean"
+
+    if rule.kind == "array":
+        if not isinstance(value, list):
+            return "must be a list"
+        if rule.max_length is not None and len(value) > rule.max_length:
+            return f"must have at most {rule.max_length} items"
+        if rule.check:
+            for i, item in enumerate(value):
+                message = rule.check(item)
+                if message:
+                    return f"item {i}: {message}"
+        return None
+
+    if rule.kind == "mapping" and not isinstance(value, dict):
+        return "must be an object"
+    return None
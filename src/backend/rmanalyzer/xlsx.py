@@ -0,0 +1,313 @@
+"""
+Parsing of Excel (.xlsx) statements into transactions.
+Reads the first worksheet, whose first row holds the same column headers as a
+CSV upload, and validates each row with the same rules. Exports are written back
+out as single-sheet workbooks a row at a time.
+"""
+
+import re
+import zipfile
+from datetime import date, timedelta
+from decimal import Decimal
+from io import BytesIO, RawIOBase
+from typing import Dict, Iterable, Iterator, List, Optional, Sequence, Tuple, Union
+from xml.etree import ElementTree
+from xml.sax.saxutils import escape
+
+from rmanalyzer.models import Transaction
+from rmanalyzer.utils import category_aliases, to_transaction
+
+__all__ = [
+    "XLSX_EXTENSIONS",
+    "XLSX_MEDIA_TYPE",
+    "is_xlsx",
+    "get_xlsx_transactions",
+    "workbook_stream",
+]
+
+# Upload file extensions parsed as Excel workbooks rather than CSV
+XLSX_EXTENSIONS = (".xlsx",)
+XLSX_MEDIA_TYPE = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+# Largest uncompressed part read from a workbook, so a small upload can't
+# inflate into more than the worker's memory
+MAX_PART_SIZE = 50 * 1024 * 1024
+
+# Day zero of Excel's date serial numbers (accounting for its 1900 leap year bug)
+EXCEL_EPOCH = date(1899, 12, 30)
+
+_MAIN = "{http://schemas.openxmlformats.org/spreadsheetml/2006/main}"
+_DOC_RELS = "{http://schemas.openxmlformats.org/officeDocument/2006/relationships}"
+_PKG_RELS = "{http://schemas.openxmlformats.org/package/2006/relationships}"
+_CELL_REF = re.compile(r"^([A-Z]+)\d+$")
+
+# Characters XML 1.0 can't carry, and a literal that would read back as an
+# escape, both written as Excel's _xHHHH_ escapes
+_XML_ILLEGAL = re.compile(
+    r"[\x00-\x08\x0b\x0c\x0e-\x1f\ufffe\uffff]|_(?=x[0-9A-Fa-f]{4}_)"
+)
+_ESCAPED_CHAR = re.compile(r"_x([0-9A-Fa-f]{4})_")
+
+Cell = Union[str, int, Decimal]
+
+# The fixed parts of a workbook with one worksheet, xl/worksheets/sheet1.xml
+_WORKBOOK_PARTS = {
+    "[Content_Types].xml": (
+        '<?xml version="1.0" encoding="UTF-8" standalone="yes"?>'
+        '<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">'
+        '<Default Extension="rels" '
+        'ContentType="application/vnd.openxmlformats-package.relationships+xml"/>'
+        '<Default Extension="xml" ContentType="application/xml"/>'
+        '<Override PartName="/xl/workbook.xml" ContentType="application/'
+        'vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>'
+        '<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/'
+        'vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>'
+        "</Types>"
+    ),
+    "_rels/.rels": (
+        '<?xml version="1.0" encoding="UTF-8" standalone="yes"?>'
+        '<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/'
+        'relationships"><Relationship Id="rId1" Type="http://schemas.'
+        'openxmlformats.org/officeDocument/2006/relationships/officeDocument" '
+        'Target="xl/workbook.xml"/></Relationships>'
+    ),
+    "xl/workbook.xml": (
+        '<?xml version="1.0" encoding="UTF-8" standalone="yes"?>'
+        '<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" '
+        'xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/'
+        'relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/>'
+        "</sheets></workbook>"
+    ),
+    "xl/_rels/workbook.xml.rels": (
+        '<?xml version="1.0" encoding="UTF-8" standalone="yes"?>'
+        '<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/'
+        'relationships"><Relationship Id="rId1" Type="http://schemas.'
+        'openxmlformats.org/officeDocument/2006/relationships/worksheet" '
+        'Target="worksheets/sheet1.xml"/></Relationships>'
+    ),
+}
+
+
+def is_xlsx(file_name: str) -> bool:
+    """Whether an uploaded file should be parsed as an Excel workbook."""
+    return file_name.lower().endswith(XLSX_EXTENSIONS)
+
+
+def _read_part(workbook: zipfile.ZipFile, name: str) -> Optional[ElementTree.Element]:
+    """Parses an XML part of the workbook, None if it isn't there."""
+    try:
+        info = workbook.getinfo(name)
+    except KeyError:
+        return None
+    if info.file_size > MAX_PART_SIZE:
+        raise ValueError(f"{name} is larger than {MAX_PART_SIZE // 1024 // 1024}MB")
+    return ElementTree.fromstring(workbook.read(info))
+
+
+def _first_sheet_path(workbook: zipfile.ZipFile) -> str:
+    """The path of the first worksheet in the workbook's sheet order."""
+    book = _read_part(workbook, "xl/workbook.xml")
+    rels = _read_part(workbook, "xl/_rels/workbook.xml.rels")
+    if book is None or rels is None:
+        raise ValueError("missing workbook.xml")
+
+    sheet = book.find(f"{_MAIN}sheets/{_MAIN}sheet")
+    if sheet is None:
+        raise ValueError("the workbook has no worksheets")
+    rel_id = sheet.get(f"{_DOC_RELS}id")
+    for rel in rels.iter(f"{_PKG_RELS}Relationship"):
+        if rel.get("Id") == rel_id:
+            target = rel.get("Target", "")
+            return target.lstrip("/") if target.startswith("/") else f"xl/{target}"
+    raise ValueError("the first worksheet is missing")
+
+
+def _unescape_text(text: str) -> str:
+    """Decodes the _xHHHH_ escapes Excel writes for characters XML can't hold."""
+    return _ESCAPED_CHAR.sub(lambda m: chr(int(m.group(1), 16)), text)
+
+
+def _shared_strings(workbook: zipfile.ZipFile) -> List[str]:
+    """The workbook's shared string table, which text cells index into."""
+    table = _read_part(workbook, "xl/sharedStrings.xml")
+    if table is None:
+        return []
+    return [
+        _unescape_text("".join(t.text or "" for t in item.iter(f"{_MAIN}t")))
+        for item in table.findall(f"{_MAIN}si")
+    ]
+
+
+def _column_index(ref: str) -> Optional[int]:
+    """The zero-based column of a cell reference such as 'C7'."""
+    match = _CELL_REF.match(ref)
+    if not match:
+        return None
+    index = 0
+    for letter in match.group(1):
+        index = index * 26 + ord(letter) - ord("A") + 1
+    return index - 1
+
+
+def _cell_value(cell: ElementTree.Element, strings: List[str]) -> Tuple[str, bool]:
+    """A cell's value as text, and whether it was stored as a number."""
+    kind = cell.get("t", "n")
+    if kind == "inlineStr":
+        text = "".join(t.text or "" for t in cell.iter(f"{_MAIN}t"))
+        return _unescape_text(text), False
+    value = cell.findtext(f"{_MAIN}v") or ""
+    if kind == "s":
+        return strings[int(value)], False
+    if kind == "n" and value:
+        # Excel keeps 15 significant digits; beyond that is binary float noise
+        return f"{float(value):.15g}", True
+    return value, False
+
+
+def _read_rows(content: bytes) -> List[List[Tuple[str, bool]]]:
+    """Reads the first worksheet's rows, filling skipped cells with blanks."""
+    with zipfile.ZipFile(BytesIO(content)) as workbook:
+        sheet = _read_part(workbook, _first_sheet_path(workbook))
+        if sheet is None:
+            raise ValueError("the first worksheet is missing")
+        strings = _shared_strings(workbook)
+
+    rows = []
+    for row in sheet.iter(f"{_MAIN}row"):
+        values: Dict[int, Tuple[str, bool]] = {}
+        for position, cell in enumerate(row.findall(f"{_MAIN}c")):
+            column = _column_index(cell.get("r", ""))
+            values[position if column is None else column] = _cell_value(
+                cell, strings
+            )
+        if values:
+            rows.append([values.get(i, ("", False)) for i in range(max(values) + 1)])
+    return rows
+
+
+def _to_row(header: List[str], cells: List[Tuple[str, bool]]) -> Dict[str, str]:
+    """
+    Pairs a row's cells with the header, as a CSV row. Dates Excel stores as
+    serial numbers are written out as YYYY-MM-DD. Raises ValueError for a serial
+    that isn't a date, e.g. one too large or not finite.
+    """
+    row = {}
+    for name, (value, numeric) in zip(header, cells):
+        if name == "Date" and numeric:
+            try:
+                value = (EXCEL_EPOCH + timedelta(days=int(float(value)))).isoformat()
+            except (OverflowError, ValueError) as e:
+                raise ValueError(f"Invalid 'Date': {value}") from e
+        row[name] = value
+    return row
+
+
+def get_xlsx_transactions(
+    content: bytes, added: Sequence[str] = ()
+) -> Tuple[List[Transaction], List[str]]:
+    """
+    Parses the first worksheet of an .xlsx workbook into Transactions. Rows may
+    use the categories the household added (added) as well as the built-in ones.
+    Returns (List[Transaction], List[str]) where the second list contains error messages.
+    """
+    try:
+        rows = _read_rows(content)
+    except (zipfile.BadZipFile, ElementTree.ParseError, ValueError, IndexError) as e:
+        return [], [f"Not a valid .xlsx workbook: {e}"]
+
+    rows = [r for r in rows if any(value.strip() for value, _ in r)]
+    if not rows:
+        return [], []
+
+    header = [value.strip() for value, _ in rows[0]]
+    transactions = []
+    errors = []
+    aliases = category_aliases(added)
+    for i, cells in enumerate(rows[1:], start=1):
+        try:
+            row = _to_row(header, cells)
+        except ValueError as e:
+            errors.append(f"Row {i}: {e}")
+            continue
+        transaction, error = to_transaction(row, aliases, added)
+        if transaction:
+            transactions.append(transaction)
+        else:
+            errors.append(f"Row {i}: {error}")
+
+    return transactions, errors
+
+
+def _column_letters(index: int) -> str:
+    """The letters of a 0-based column index, e.g. 0 is A and 26 is AA."""
+    letters = ""
+    index += 1
+    while index:
+        index, remainder = divmod(index - 1, 26)
+        letters = chr(ord("A") + remainder) + letters
+    return letters
+
+
+def _escape_text(value: str) -> str:
+    """Cell text as XML, with characters XML 1.0 forbids written as _xHHHH_."""
+    return escape(_XML_ILLEGAL.sub(lambda m: f"_x{ord(m.group()):04X}_", value))
+
+
+def _row_xml(number: int, values: Sequence[Cell]) -> str:
+    """A worksheet row. Numbers are stored as numbers and text inline."""
+    cells = []
+    for i, value in enumerate(values):
+        ref = f"{_column_letters(i)}{number}"
+        if isinstance(value, (int, Decimal)):
+            cells.append(f'<c r="{ref}"><v>{value}</v></c>')
+        else:
+            cells.append(
+                f'<c r="{ref}" t="inlineStr"><is><t xml:space="preserve">'
+                f"{_escape_text(value)}</t></is></c>"
+            )
+    return f'<row r="{number}">{"".join(cells)}</row>'
+
+
+class _Chunks(RawIOBase):
+    """An unseekable file that hands back what was written since the last take."""
+
+    def __init__(self) -> None:
+        super().__init__()
+        self._buffer = bytearray()
+
+    def writable(self) -> bool:
+        return True
+
+    def write(self, data) -> int:
+        self._buffer.extend(data)
+        return len(data)
+
+    def take(self) -> bytes:
+        data = bytes(self._buffer)
+        self._buffer.clear()
+        return data
+
+
+def workbook_stream(rows: Iterable[Sequence[Cell]]) -> Iterator[bytes]:
+    """
+    Writes rows to a one-sheet workbook, yielding the compressed file as it
+    grows, so an export never holds more than a row and the compressor's window.
+    The first row is the header.
+    """
+    out = _Chunks()
+    with zipfile.ZipFile(out, "w", zipfile.ZIP_DEFLATED) as workbook:
+        for name, part in _WORKBOOK_PARTS.items():
+            workbook.writestr(name, part)
+        with workbook.open("xl/worksheets/sheet1.xml", "w") as sheet:
+            sheet.write(
+                b'<?xml version="1.0" encoding="UTF-8" standalone="yes"?><worksheet '
+                b'xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">'
+                b"<sheetData>"
+            )
+            for number, values in enumerate(rows, start=1):
+                sheet.write(_row_xml(number, values).encode("utf-8"))
+                chunk = out.take()
+                if chunk:
+                    yield chunk
+            sheet.write(b"</sheetData></worksheet>")
+    yield out.take()
@@ -1,4 +1,42 @@
-import { renderNavbar } from './navbar.js';
+import { householdHeaders, renderNavbar } from './navbar.js';
+
+const POLL_INTERVAL_MS = 3000;
+const POLL_ATTEMPTS = 40;
+
+function describeUpload(upload) {
+    switch (upload.status) {
+        case 'queued':
+            return 'Upload queued for processing...';
+        case 'processing':
+            return 'Processing your statement...';
+        case 'completed': {
+            const skipped = upload.rowsSkipped
+                ? ` (${upload.rowsSkipped} rows skipped: ${upload.errors.join('; ')})`
+                : '';
+            return `Imported ${upload.rowsImported} transactions${skipped}. You will receive an email shortly.`;
+        }
+        default:
+            return `Processing failed: ${upload.errors.join('; ') || 'unknown error'}`;
+    }
+}
+
+async function pollUpload(blobName, statusDiv) {
+    for (let attempt = 0; attempt < POLL_ATTEMPTS; attempt++) {
+        await new Promise(resolve => setTimeout(resolve, POLL_INTERVAL_MS));
+        const response = await fetch(`/api/uploads/${encodeURIComponent(blobName)}`, {
+            headers: householdHeaders()
+        });
+        if (!response.ok) {
+            continue;
+        }
+        const upload = await response.json();
+        statusDiv.innerText = describeUpload(upload);
+        if (upload.status === 'completed' || upload.status === 'failed') {
+            return;
+        }
+    }
+    statusDiv.innerText = 'Still processing. You will receive an email once it is done.';
+}
 
 async function uploadFile() {
     const fileInput = document.getElementById('fileInput');
@@ -21,11 +59,14 @@ async function uploadFile() {
         // Upload directly to our Backend API (secured by SWA Auth)
         const response = await fetch('/api/upload', {
             method: 'POST',
+            headers: householdHeaders(),
             body: formData
         });
 
         if (response.ok) {
-            statusDiv.innerText = 'Upload accepted for processing! You will receive an email shortly.';
+            const { blobName } = await response.json();
+            statusDiv.innerText = 'Upload accepted for processing...';
+            await pollUpload(blobName, statusDiv);
         } else {
             const errorText = await response.text();
             throw new Error(errorText || 'Upload failed');
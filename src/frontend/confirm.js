@@ -0,0 +1,92 @@
+// Confirms the action of a signed email link: recording a suggested transfer
+// to savings, or acknowledging a payment reminder. The link opens this page;
+// the action is only taken once the member has signed in and clicks Confirm,
+// so a mail scanner or link preview following the link changes nothing.
+
+// Per action: the page's title and prompt, the button's label and the endpoint
+// the signature is posted to
+const ACTIONS = {
+    savings: {
+        title: 'Pay Yourself First',
+        prompt: "Record the suggested transfer in this month's savings?",
+        button: "I've Moved It to Savings",
+        endpoint: (id) => `/api/savings/suggestions/${encodeURIComponent(id)}/approve`
+    },
+    reminder: {
+        title: 'Card Payment',
+        prompt: 'Mark this card payment as paid, so it is not escalated this cycle?',
+        button: "I've Paid This",
+        endpoint: (id) => `/api/reminders/${encodeURIComponent(id)}/ack`
+    }
+};
+
+function setStatus(message) {
+    document.getElementById('status').innerText = message;
+}
+
+async function signedIn() {
+    try {
+        const response = await fetch('/.auth/me');
+        if (!response.ok) {
+            return false;
+        }
+        const { clientPrincipal } = await response.json();
+        return Boolean(clientPrincipal);
+    } catch (error) {
+        return false;
+    }
+}
+
+async function submit(action, id, sig) {
+    const button = document.getElementById('confirmBtn');
+    button.disabled = true;
+    setStatus('Saving...');
+
+    try {
+        const response = await fetch(action.endpoint(id), {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ sig })
+        });
+        if (response.ok || response.status === 410) {
+            setStatus(await response.text());
+        } else if (response.status === 403) {
+            setStatus('This link is not valid.');
+        } else {
+            setStatus('Error saving. Please try again later.');
+        }
+        button.style.display = 'none';
+        document.getElementById('homeLink').style.display = '';
+    } catch (error) {
+        console.error('Error confirming:', error);
+        setStatus('Error saving. Please try again later.');
+        button.disabled = false;
+    }
+}
+
+async function init() {
+    const params = new URLSearchParams(window.location.search);
+    const action = ACTIONS[params.get('action')];
+    const id = params.get('id');
+    const sig = params.get('sig');
+    if (!action || !id || !sig) {
+        setStatus('This link is incomplete.');
+        return;
+    }
+
+    // The page is open to everyone so sign-in can return here with the link's query
+    if (!(await signedIn())) {
+        const returnTo = window.location.pathname + window.location.search;
+        window.location.href = `/.auth/login/aad?post_login_redirect_uri=${encodeURIComponent(returnTo)}`;
+        return;
+    }
+
+    document.getElementById('title').innerText = action.title;
+    setStatus(action.prompt);
+    const button = document.getElementById('confirmBtn');
+    button.innerText = action.button;
+    button.style.display = '';
+    button.addEventListener('click', () => submit(action, id, sig));
+}
+
+document.addEventListener('DOMContentLoaded', init);
@@ -0,0 +1,76 @@
+// Accepts a member invite. The link in the invite email opens this page; the
+// invite is only used once the invitee has signed in and clicks Accept, so a
+// mail scanner following the link doesn't use it up.
+
+function setStatus(message) {
+    document.getElementById('status').innerText = message;
+}
+
+async function signedIn() {
+    try {
+        const response = await fetch('/.auth/me');
+        if (!response.ok) {
+            return false;
+        }
+        const { clientPrincipal } = await response.json();
+        return Boolean(clientPrincipal);
+    } catch (error) {
+        return false;
+    }
+}
+
+async function accept(token) {
+    const button = document.getElementById('acceptBtn');
+    button.disabled = true;
+    setStatus('Accepting invite...');
+
+    try {
+        const response = await fetch('/api/invites/accept', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ token })
+        });
+        if (response.status === 201) {
+            const person = await response.json();
+            setStatus(`Welcome, ${person.name}! You're now a member of the household.`);
+            button.style.display = 'none';
+            document.getElementById('homeLink').style.display = '';
+            return;
+        }
+
+        if (response.status === 404 || response.status === 410) {
+            setStatus('This invite has expired or was already used.');
+        } else if (response.status === 409) {
+            setStatus(await response.text());
+        } else {
+            setStatus('Error accepting invite.');
+        }
+        button.style.display = 'none';
+    } catch (error) {
+        console.error('Error accepting invite:', error);
+        setStatus('Error accepting invite.');
+        button.disabled = false;
+    }
+}
+
+async function init() {
+    const token = new URLSearchParams(window.location.search).get('token');
+    if (!token) {
+        setStatus('This link is missing its token.');
+        return;
+    }
+
+    // The page is open to everyone so sign-in can return here with the token
+    if (!(await signedIn())) {
+        const returnTo = window.location.pathname + window.location.search;
+        window.location.href = `/.auth/login/aad?post_login_redirect_uri=${encodeURIComponent(returnTo)}`;
+        return;
+    }
+
+    setStatus("You've been invited to join the household's shared expenses.");
+    const button = document.getElementById('acceptBtn');
+    button.style.display = '';
+    button.addEventListener('click', () => accept(token));
+}
+
+document.addEventListener('DOMContentLoaded', init);
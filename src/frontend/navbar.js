@@ -1,3 +1,14 @@
+// Open a page with the navbar with ?household=sandbox to work against the demo
+// household, and ?household=live to go back. The choice is remembered across
+// pages, and every API request from a page with the navbar must send these headers.
+export function householdHeaders() {
+    const requested = new URLSearchParams(window.location.search).get('household');
+    if (requested) {
+        localStorage.setItem('household', requested);
+    }
+    return localStorage.getItem('household') === 'sandbox' ? { 'X-Household': 'sandbox' } : {};
+}
+
 export async function renderNavbar() {
     // Inject font (optional, but good for style consistency)
     if (!document.querySelector('link[href*="segoe-ui"]')) {
@@ -17,6 +28,7 @@ export async function renderNavbar() {
                 <a href="index.html" class="nav-link ${currentPath === 'index.html' || currentPath === '' ? 'active' : ''}">Upload</a>
                 <a href="savings.html" class="nav-link ${currentPath === 'savings.html' ? 'active' : ''}">Savings Calculator</a>
             </div>
+            ${householdHeaders()['X-Household'] ? '<span class="nav-sandbox">Sandbox</span>' : ''}
             <div id="nav-user-info" class="nav-user"></div>
         </div>
     </nav>
@@ -1,4 +1,7 @@
-import { renderNavbar } from './navbar.js';
+import { householdHeaders, renderNavbar } from './navbar.js';
+
+// API v2 sends amounts as decimal strings ("12.30") rather than floats
+const API_HEADERS = { 'X-API-Version': '2', ...householdHeaders() };
 
 const state = {
     month: '',
@@ -32,22 +35,25 @@ async function loadData() {
     updateStatus('Loading data...', true);
 
     try {
-        const response = await fetch(`/api/savings?month=${state.month}`);
+        const response = await fetch(`/api/savings?month=${state.month}`, { headers: API_HEADERS });
         if (response.ok) {
             const data = await response.json();
             // Merge into state
-            state.startingBalance = data.startingBalance !== undefined ? data.startingBalance : 0;
+            state.startingBalance = data.startingBalance !== undefined ? parseFloat(data.startingBalance) : 0;
             state.items = Array.isArray(data.items) ? data.items : [];
             updateStatus('');
         } else if (response.status === 404) {
-            // Try fetching previous month
+            // Start from the previous month's items and ending balance, without one-offs
             const prevMonth = getPreviousMonth(state.month);
             try {
-                const prevResponse = await fetch(`/api/savings?month=${prevMonth}`);
-                if (prevResponse.ok) {
-                    const prevData = await prevResponse.json();
-                    state.startingBalance = prevData.startingBalance !== undefined ? prevData.startingBalance : 0;
-                    state.items = Array.isArray(prevData.items) ? prevData.items : [];
+                const copyResponse = await fetch(
+                    `/api/savings/copy?from=${prevMonth}&to=${state.month}&excludeOneOff=true`,
+                    { method: 'POST', headers: API_HEADERS }
+                );
+                if (copyResponse.ok) {
+                    const copied = await copyResponse.json();
+                    state.startingBalance = copied.startingBalance !== undefined ? parseFloat(copied.startingBalance) : 0;
+                    state.items = Array.isArray(copied.items) ? copied.items : [];
                     updateStatus('Data copied from previous month.');
                 } else {
                     // No previous data either, reset
@@ -122,6 +128,11 @@ function render() {
             }
             costInput.oninput = (e) => handleItemChange(index, 'cost', e.target.value);
 
+            // One-off Checkbox
+            const oneOffInput = tr.querySelector('.item-one-off');
+            oneOffInput.checked = Boolean(item.oneOff);
+            oneOffInput.onchange = (e) => handleItemChange(index, 'oneOff', e.target.checked);
+
             // Remove Button
             const btnRemove = tr.querySelector('.btn-danger');
             btnRemove.onclick = () => handleRemoveItem(index);
@@ -161,6 +172,16 @@ function createRow(index, item) {
     inputCost.oninput = (e) => handleItemChange(index, 'cost', e.target.value);
     tdCost.appendChild(inputCost);
 
+    // One-off Cell: left out when the month is copied forward
+    const tdOneOff = document.createElement('td');
+    const inputOneOff = document.createElement('input');
+    inputOneOff.type = 'checkbox';
+    inputOneOff.className = 'item-one-off';
+    inputOneOff.checked = Boolean(item.oneOff);
+    inputOneOff.title = 'Not copied into next month';
+    inputOneOff.onchange = (e) => handleItemChange(index, 'oneOff', e.target.checked);
+    tdOneOff.appendChild(inputOneOff);
+
     // Action Cell
     const tdAction = document.createElement('td');
     const btnRemove = document.createElement('button');
@@ -171,6 +192,7 @@ function createRow(index, item) {
 
     tr.appendChild(tdName);
     tr.appendChild(tdCost);
+    tr.appendChild(tdOneOff);
     tr.appendChild(tdAction);
 
     return tr;
@@ -225,7 +247,7 @@ async function handleSave() {
     try {
         const response = await fetch('/api/savings', {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
+            headers: { ...API_HEADERS, 'Content-Type': 'application/json' },
             body: JSON.stringify(payload)
         });
 
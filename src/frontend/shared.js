@@ -0,0 +1,88 @@
+// Renders a report shared with a read-only link. The token comes from the link
+// and is sent as a header, so it stays out of the API's request logs.
+
+function formatCurrency(value) {
+    return `$${Number(value).toLocaleString(undefined, { minimumFractionDigits: 2, maximumFractionDigits: 2 })}`;
+}
+
+function setStatus(message) {
+    document.getElementById('status').innerText = message;
+}
+
+function renderTable(headings, rows) {
+    const head = document.getElementById('reportHead');
+    const body = document.getElementById('reportBody');
+    head.innerHTML = '';
+    body.innerHTML = '';
+
+    const headRow = document.createElement('tr');
+    headings.forEach(heading => {
+        const th = document.createElement('th');
+        th.innerText = heading;
+        headRow.appendChild(th);
+    });
+    head.appendChild(headRow);
+
+    rows.forEach(cells => {
+        const tr = document.createElement('tr');
+        cells.forEach(cell => {
+            const td = document.createElement('td');
+            td.innerText = cell;
+            tr.appendChild(td);
+        });
+        body.appendChild(tr);
+    });
+    document.getElementById('reportTable').style.display = '';
+}
+
+function renderSummary(data) {
+    document.getElementById('reportTitle').innerText = `Spending Summary for ${data.month}`;
+    const rows = [
+        ...data.people.map(p => [p.name, formatCurrency(p.total)]),
+        ...(data.unassigned ? [['Unassigned', formatCurrency(data.unassigned)]] : []),
+        ...data.categories.filter(c => c.total).map(c => [c.category, formatCurrency(c.total)]),
+        ['Total', formatCurrency(data.total)]
+    ];
+    renderTable(['', 'Spend'], rows);
+}
+
+function renderTrends(data) {
+    const adjusted = data.real ? ` (in ${data.baseYear} dollars)` : '';
+    document.getElementById('reportTitle').innerText = `Spending from ${data.from} to ${data.to}${adjusted}`;
+    renderTable(['Month', 'Total'], data.months.map(m => [m.month, formatCurrency(m.total)]));
+}
+
+async function init() {
+    const token = new URLSearchParams(window.location.search).get('token');
+    if (!token) {
+        setStatus('This link is missing its token.');
+        return;
+    }
+
+    try {
+        const response = await fetch('/api/shared', { headers: { 'X-Share-Token': token } });
+        if (response.status === 404 || response.status === 410) {
+            setStatus('This link has expired or was revoked.');
+            return;
+        }
+        if (!response.ok) {
+            setStatus('Error loading report.');
+            return;
+        }
+
+        const shared = await response.json();
+        if (shared.report === 'summary') {
+            renderSummary(shared.data);
+        } else {
+            renderTrends(shared.data);
+        }
+        setStatus('');
+        document.getElementById('expires').innerText =
+            `This read-only link expires ${new Date(shared.expiresAt).toLocaleDateString()}.`;
+    } catch (error) {
+        console.error('Error fetching shared report:', error);
+        setStatus('Error loading report.');
+    }
+}
+
+document.addEventListener('DOMContentLoaded', init);
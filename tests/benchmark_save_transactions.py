@@ -0,0 +1,70 @@
+"""
+Benchmarks save_transactions on a large multi-month import, sequentially and with
+partitions written concurrently. Each batch is given the latency of a round trip
+to Table Storage, since that wait is what the workers overlap.
+
+Run from the repository root:
+    PYTHONPATH=src/backend python tests/benchmark_save_transactions.py [rows] [ms]
+"""
+
+import os
+import sys
+import time
+from datetime import date, timedelta
+from decimal import Decimal
+from unittest.mock import MagicMock
+
+os.environ.setdefault("TABLE_SERVICE_URL", "http://127.0.0.1:10002")
+
+# pylint: disable=wrong-import-position
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.services import DatabaseService
+
+
+def _transactions(rows: int) -> list[Transaction]:
+    start = date(2023, 1, 1)
+    return [
+        Transaction(
+            date=start + timedelta(days=i % 365),
+            name=f"Merchant {i}",
+            account_number=1234,
+            amount=Decimal(i % 500) + Decimal("0.99"),
+            category=Category.GROCERIES,
+            ignore=IgnoredFrom.NOTHING,
+        )
+        for i in range(rows)
+    ]
+
+
+def _run(transactions: list[Transaction], workers: int, latency: float) -> float:
+    client = MagicMock()
+    client.submit_transaction.side_effect = lambda batch: time.sleep(latency)
+    db = DatabaseService()
+    db._get_table_client = MagicMock(  # pylint: disable=protected-access
+        return_value=client
+    )
+    db._save_workers = workers  # pylint: disable=protected-access
+
+    start = time.perf_counter()
+    db.save_transactions(transactions)
+    return time.perf_counter() - start
+
+
+def main() -> None:
+    """Prints rows per second for each worker count."""
+    rows = int(sys.argv[1]) if len(sys.argv) > 1 else 10_000
+    latency = (float(sys.argv[2]) if len(sys.argv) > 2 else 20) / 1000
+    transactions = _transactions(rows)
+
+    baseline = None
+    for workers in (1, 2, 4, 8, 12):
+        elapsed = _run(transactions, workers, latency)
+        baseline = baseline or elapsed
+        print(
+            f"workers={workers:>2}  {elapsed:6.2f}s  "
+            f"{rows / elapsed:8.0f} rows/s  x{baseline / elapsed:.1f}"
+        )
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,47 @@
+"""
+Fixtures shared by the controller tests.
+"""
+
+import base64
+import json
+import unittest
+from typing import Any, Iterable, Optional
+from unittest.mock import MagicMock
+
+import azure.functions as func
+
+
+def principal(email: str) -> str:
+    """The client principal header Static Web Apps sends for a signed in user."""
+    payload = {"userDetails": email}
+    return base64.b64encode(json.dumps(payload).encode("utf-8")).decode("utf-8")
+
+
+def http_request(email: Optional[str] = None, **attrs: Any) -> MagicMock:
+    """
+    A request with no params and an empty JSON body, signed in as the given
+    email if any. Other attributes, e.g. params or route_params, are set as given.
+    """
+    req = MagicMock(spec=func.HttpRequest)
+    req.params = {}
+    req.headers = {}
+    req.get_json = MagicMock(return_value={})
+    for name, value in attrs.items():
+        setattr(req, name, value)
+    if email:
+        sign_in(req, email)
+    return req
+
+
+def sign_in(req: MagicMock, email: str) -> None:
+    """Signs a request in as the given email."""
+    req.headers["x-ms-client-principal"] = principal(email)
+
+
+def start_patches(case: unittest.TestCase, patchers: Iterable[Any]) -> list[Any]:
+    """Starts patchers, stopped when the test ends, and returns their mocks."""
+    mocks = []
+    for p in patchers:
+        mocks.append(p.start())
+        case.addCleanup(p.stop)
+    return mocks
@@ -0,0 +1,115 @@
+"""
+Tests for the alert lifecycle.
+"""
+
+import json
+import unittest
+from datetime import date, datetime
+from unittest.mock import patch
+
+from fixtures import http_request, start_patches
+from rmanalyzer.alerts import alert_id, is_suppressed
+from rmanalyzer.controller import controller
+
+
+class TestAlerts(unittest.TestCase):
+    def test_alert_id_is_stable_and_row_key_safe(self):
+        first = alert_id("missing-bill", "AT&T / Wireless")
+        self.assertEqual(first, alert_id("missing-bill", "AT&T / Wireless"))
+        self.assertNotEqual(first, alert_id("price-increase", "AT&T / Wireless"))
+        self.assertRegex(first, r"^[0-9a-f]{32}$")
+
+    def test_suppression(self):
+        today = date(2025, 3, 10)
+        self.assertFalse(is_suppressed(None, today))
+        self.assertFalse(is_suppressed({"status": "new"}, today))
+        self.assertTrue(is_suppressed({"status": "dismissed"}, today))
+        self.assertTrue(
+            is_suppressed({"status": "snoozed", "snoozedUntil": "2025-03-11"}, today)
+        )
+        # Sent again on the day the snooze ends
+        self.assertFalse(
+            is_suppressed({"status": "snoozed", "snoozedUntil": "2025-03-10"}, today)
+        )
+
+
+class TestAlertsController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", route_params={"id": "a1"})
+        self.alert = {
+            "id": "a1",
+            "kind": "utilization",
+            "key": "a@test.com",
+            "recipients": ["A@test.com"],
+            "status": "new",
+            "snoozedUntil": None,
+        }
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_alerts",
+                side_effect=lambda: [
+                    self.alert,
+                    {**self.alert, "id": "b1", "recipients": ["b@test.com"]},
+                ],
+            ),
+            patch.object(
+                controller.db_service, "get_alert", side_effect=lambda _: self.alert
+            ),
+            patch.object(controller.db_service, "set_alert_status"),
+            patch("rmanalyzer.controller.datetime"),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_status, self.mock_datetime = mocks[2], mocks[3]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 10)
+
+    def test_lists_callers_alerts(self):
+        resp = controller.handle_alerts(self.req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            [a["id"] for a in json.loads(resp.get_body())["alerts"]], ["a1"]
+        )
+
+    def test_snooze(self):
+        self.req.get_json.return_value = {
+            "status": "snoozed",
+            "snoozedUntil": "2025-04-01",
+        }
+        resp = controller.handle_alert(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(json.loads(resp.get_body())["snoozedUntil"], "2025-04-01")
+        self.mock_status.assert_called_once_with(
+            "a1", "snoozed", "2025-04-01", "a@test.com"
+        )
+
+    def test_dismiss_and_reopen(self):
+        for status in ("dismissed", "new"):
+            self.req.get_json.return_value = {"status": status}
+            resp = controller.handle_alert(self.req)
+            self.assertEqual(resp.status_code, 200)
+            self.mock_status.assert_called_with("a1", status, None, "a@test.com")
+
+    def test_snooze_needs_future_date(self):
+        for body in (
+            {"status": "snoozed"},
+            {"status": "snoozed", "snoozedUntil": "2025-03-10"},
+            {"status": "dismissed", "snoozedUntil": "2025-04-01"},
+        ):
+            self.req.get_json.return_value = body
+            resp = controller.handle_alert(self.req)
+            self.assertEqual(resp.status_code, 400)
+            self.assertEqual(
+                json.loads(resp.get_body())["fields"][0]["field"], "snoozedUntil"
+            )
+        self.mock_status.assert_not_called()
+
+    def test_other_members_alert_not_found(self):
+        self.alert["recipients"] = ["b@test.com"]
+        self.req.get_json.return_value = {"status": "dismissed"}
+        resp = controller.handle_alert(self.req)
+        self.assertEqual(resp.status_code, 404)
+        self.mock_status.assert_not_called()
+
+if __name__ == "__main__":
+    unittest.main()
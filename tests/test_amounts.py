@@ -0,0 +1,51 @@
+"""
+Tests for amount serialization per API version.
+"""
+
+import unittest
+
+from rmanalyzer.amounts import requested_version, stringify_amounts
+
+
+class TestAmounts(unittest.TestCase):
+    def test_requested_version(self):
+        self.assertEqual(requested_version({}), 1)
+        self.assertEqual(requested_version({"x-api-version": " 2 "}), 2)
+        self.assertIsNone(requested_version({"x-api-version": "3"}))
+        self.assertIsNone(requested_version({"x-api-version": "latest"}))
+
+    def test_stringify_nested_amounts(self):
+        payload = {
+            "entries": [{"amount": 0.1, "kind": "expense", "count": 3}],
+            "balance": {"debtor": "a@test.com", "amount": 1e-05},
+            "ratio": 0.3333,
+            "settled": True,
+        }
+
+        self.assertEqual(
+            stringify_amounts(payload),
+            {
+                "entries": [{"amount": "0.10", "kind": "expense", "count": 3}],
+                "balance": {"debtor": "a@test.com", "amount": "0.00"},
+                "ratio": 0.3333,
+                "settled": True,
+            },
+        )
+
+    def test_extra_fields_convert_every_number_beneath(self):
+        payload = {"categories": {"Groceries": 100.5, "Dining": 0}, "month": "2025-01"}
+
+        self.assertEqual(
+            stringify_amounts(payload, ["categories"]),
+            {
+                "categories": {"Groceries": "100.50", "Dining": "0.00"},
+                "month": "2025-01",
+            },
+        )
+
+    def test_none_stays_null(self):
+        self.assertEqual(stringify_amounts({"limit": None}), {"limit": None})
+
+
+if __name__ == "__main__":
+    unittest.main()
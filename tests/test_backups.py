@@ -0,0 +1,372 @@
+"""
+Tests for encrypted backups and their verification.
+"""
+
+import base64
+import hashlib
+import hmac
+import json
+import os
+import unittest
+from datetime import datetime
+from decimal import Decimal
+from unittest.mock import MagicMock, patch
+
+from fixtures import http_request, sign_in
+from rmanalyzer import backups
+from rmanalyzer.controller import controller
+from rmanalyzer.ledger import LedgerEntry
+
+KEY = bytes(range(32))
+ENCODED_KEY = base64.b64encode(KEY).decode()
+
+
+class _FakeAESGCM:
+    """Stands in for AES-GCM: a keyed tag over the nonce, name and data."""
+
+    def __init__(self, key):
+        self.key = key
+
+    def _tag(self, nonce, data, aad):
+        return hmac.new(self.key, nonce + aad + data, hashlib.sha256).digest()[:16]
+
+    def encrypt(self, nonce, data, aad):
+        return self._tag(nonce, data, aad) + data[::-1]
+
+    def decrypt(self, nonce, ciphertext, aad):
+        data = ciphertext[16:][::-1]
+        if not hmac.compare_digest(ciphertext[:16], self._tag(nonce, data, aad)):
+            raise ValueError("InvalidTag")
+        return data
+
+
+class TestBackups(unittest.TestCase):
+    """Test suite for backup encryption and manifests."""
+
+    def setUp(self):
+        patcher = patch("rmanalyzer.backups._aead", side_effect=_FakeAESGCM)
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def test_parse_key(self):
+        """Test that only base64 256-bit keys are accepted."""
+        self.assertEqual(backups.parse_key(ENCODED_KEY), KEY)
+        with self.assertRaises(ValueError):
+            backups.parse_key(base64.b64encode(KEY[:16]).decode())
+        with self.assertRaises(ValueError):
+            backups.parse_key("not base64!")
+
+    def test_blob_is_bound_to_its_name(self):
+        """Test that a blob decrypts under its own name only."""
+        blob = backups.encrypt(KEY, "s/people.json.enc", b"[]")
+
+        self.assertEqual(backups.decrypt(KEY, "s/people.json.enc", blob), b"[]")
+        with self.assertRaises(ValueError):
+            backups.decrypt(KEY, "s/savings.json.enc", blob)
+
+    def test_sealed_secret_is_bound_to_its_purpose(self):
+        """Test that a sealed secret is hidden and opens for its purpose only."""
+        sealed = backups.seal(KEY, "mailbox:bank", "refresh-token")
+
+        self.assertNotIn("refresh-token", sealed)
+        self.assertEqual(backups.unseal(KEY, "mailbox:bank", sealed), "refresh-token")
+        with self.assertRaises(ValueError):
+            backups.unseal(KEY, "mailbox:other", sealed)
+        with self.assertRaises(ValueError):
+            backups.unseal(bytes(32), "mailbox:bank", sealed)
+
+    def test_manifest_signature(self):
+        """Test that a changed manifest or another key fails the signature."""
+        manifest = backups.sign_manifest(KEY, {"snapshot": "s", "tables": []})
+
+        self.assertTrue(backups.manifest_valid(KEY, manifest))
+        self.assertFalse(backups.manifest_valid(KEY, {**manifest, "snapshot": "t"}))
+        self.assertFalse(backups.manifest_valid(bytes(32), manifest))
+        self.assertFalse(backups.manifest_valid(KEY, {"snapshot": "s", "tables": []}))
+
+
+class BackupTestCase(unittest.TestCase):
+    """Backs up tables into an in-memory container."""
+
+    def setUp(self):
+        self.req = http_request("admin@test.com", method="POST")
+        self.blobs = {}
+        self.tables = {
+            name: [{"PartitionKey": name, "RowKey": str(i)} for i in range(2)]
+            for name in ("people", "jobs")
+        }
+
+        patches = [
+            patch("rmanalyzer.backups._aead", side_effect=_FakeAESGCM),
+            patch("rmanalyzer.controller.datetime"),
+            patch.dict(
+                os.environ,
+                {"ADMIN_EMAILS": "admin@test.com", "SECRET_BACKUP_KEY": ENCODED_KEY},
+            ),
+            patch.object(
+                controller.db_service,
+                "table_names",
+                side_effect=lambda: list(self.tables),
+            ),
+            patch.object(
+                controller.db_service,
+                "export_table",
+                side_effect=lambda name: self.tables[name],
+            ),
+            patch.object(
+                controller.blob_service,
+                "upload_blob",
+                side_effect=lambda _, name, content: self.blobs.__setitem__(
+                    name, content
+                ),
+            ),
+            patch.object(
+                controller.blob_service,
+                "download_blob",
+                side_effect=lambda _, name: self.blobs[name],
+            ),
+            patch.object(
+                controller.blob_service,
+                "list_blobs",
+                side_effect=lambda _: list(self.blobs),
+            ),
+            patch.object(controller.db_service, "record_job_run"),
+        ]
+        mocks = [p.start() for p in patches]
+        self.addCleanup(patch.stopall)
+        mocks[1].now.return_value = datetime(2025, 6, 1, 2, 15)
+        mocks[1].fromisoformat.side_effect = datetime.fromisoformat
+        self.mock_record_run = mocks[-1]
+
+
+class TestBackupJob(BackupTestCase):
+    """Test suite for the nightly backup and verifying it."""
+
+    def _verify(self):
+        resp = controller.handle_backup_verify(self.req)
+        self.assertEqual(resp.status_code, 200)
+        return json.loads(resp.get_body())
+
+    def _manifest(self):
+        return json.loads(self.blobs["20250601T021500/manifest.json"])
+
+    def test_backup_then_verify(self):
+        """Test that every table is encrypted and listed in a valid manifest."""
+        controller.run_backup_job()
+
+        self.assertEqual(
+            sorted(self.blobs),
+            [
+                "20250601T021500/jobs.json.enc",
+                "20250601T021500/manifest.json",
+                "20250601T021500/people.json.enc",
+            ],
+        )
+        self.assertNotIn(b"RowKey", self.blobs["20250601T021500/people.json.enc"])
+        people = self._manifest()["tables"][0]
+        self.assertEqual(people["entities"], 2)
+        self.assertEqual(
+            people["sha256"],
+            hashlib.sha256(self.blobs[people["blob"]]).hexdigest(),
+        )
+        self.assertEqual(
+            self.mock_record_run.call_args[0][3],
+            {"snapshot": "20250601T021500", "tables": 2, "entities": 4},
+        )
+
+        result = self._verify()
+        self.assertTrue(result["valid"])
+        self.assertTrue(result["manifestValid"])
+        self.assertEqual([t["table"] for t in result["tables"]], ["people", "jobs"])
+
+    def test_verify_reports_corrupt_table(self):
+        """Test that a blob that doesn't match its checksum is reported."""
+        controller.run_backup_job()
+        self.blobs["20250601T021500/jobs.json.enc"] += b"x"
+
+        result = self._verify()
+
+        self.assertFalse(result["valid"])
+        self.assertTrue(result["tables"][0]["valid"])
+        self.assertEqual(result["tables"][1]["error"], "Does not match its checksum")
+
+    def test_verify_rejects_tampered_manifest(self):
+        """Test that tables aren't checked against a manifest that was changed."""
+        controller.run_backup_job()
+        manifest = self._manifest()
+        manifest["tables"][0]["entities"] = 5
+        self.blobs["20250601T021500/manifest.json"] = json.dumps(manifest).encode()
+
+        result = self._verify()
+
+        self.assertFalse(result["valid"])
+        self.assertFalse(result["manifestValid"])
+        self.assertEqual(result["tables"], [])
+
+    def test_verify_with_rotated_key(self):
+        """Test that a snapshot taken with another key says so."""
+        controller.run_backup_job()
+
+        with patch.dict(
+            os.environ, {"SECRET_BACKUP_KEY": base64.b64encode(bytes(32)).decode()}
+        ):
+            result = self._verify()
+
+        self.assertFalse(result["valid"])
+        self.assertEqual(result["error"], "Taken with a different backup key")
+
+    def test_verify_needs_backup_and_admin(self):
+        """Test that there must be a backup, and only admins may verify."""
+        self.assertEqual(controller.handle_backup_verify(self.req).status_code, 404)
+
+        sign_in(self.req, "member@test.com")
+        self.assertEqual(controller.handle_backup_verify(self.req).status_code, 403)
+
+    def test_failed_backup_has_no_manifest(self):
+        """Test that a backup failing partway leaves no manifest and fails."""
+        controller.db_service.export_table.side_effect = Exception("table down")
+
+        with patch.object(controller, "_alert_admins") as mock_alert:
+            with self.assertRaises(RuntimeError):
+                controller.run_backup_job()
+
+        self.assertEqual(self.blobs, {})
+        mock_alert.assert_called_once()
+        self.assertEqual(self.mock_record_run.call_args[0][2], "failed")
+
+
+class TestBackupRehearsal(BackupTestCase):
+    """Test suite for restoring the latest backup into shadow tables."""
+
+    def setUp(self):
+        super().setUp()
+        transactions = controller.db_service.transactions_table
+        debts = controller.db_service.debts_table
+        self.tables = {
+            transactions: [
+                {
+                    "PartitionKey": "default_2025-05",
+                    "RowKey": f"r{i}",
+                    "Date": f"2025-05-0{i + 1}",
+                    "Description": "Store",
+                    "AccountNumber": 1234,
+                    "Amount": amount,
+                    "Category": "Groceries",
+                }
+                for i, amount in enumerate([10.5, -2.25])
+            ],
+            debts: [
+                LedgerEntry(
+                    "e1",
+                    "split",
+                    "a@test.com",
+                    "b@test.com",
+                    Decimal("12.00"),
+                    "2025-05",
+                    "2025-05-31T00:00:00",
+                ).to_entity("default_LEDGER")
+            ],
+        }
+        controller.run_backup_job()
+
+        self.restored = MagicMock()
+        self.restored.get_ledger_entries.side_effect = lambda: [
+            LedgerEntry.from_entity(e) for e in self.tables[debts]
+        ]
+        self.restored.get_transactions.side_effect = lambda month: [
+            controller.db_service._entity_to_transaction(e)
+            for e in self.tables[transactions]
+            if e["PartitionKey"] == f"default_{month}"
+        ]
+        patches = {
+            "shadow": patch.object(
+                controller.db_service, "shadow", return_value=self.restored
+            ),
+            "restore": patch.object(controller.db_service, "restore_table"),
+            "count": patch.object(
+                controller.db_service,
+                "count_entities",
+                side_effect=lambda name: len(self.tables[name.removeprefix("drtest")]),
+            ),
+            "enqueue": patch.object(controller.queue_service, "enqueue_message"),
+        }
+        self.mocks = {name: p.start() for name, p in patches.items()}
+
+    def _rehearse(self):
+        """Queues a rehearsal, runs it as the queue would, and returns its run."""
+        resp = controller.handle_backup_rehearsal(self.req)
+        self.assertEqual(resp.status_code, 202)
+        self.assertEqual(json.loads(resp.get_body())["status"], "queued")
+        (message,), _ = self.mocks["enqueue"].call_args
+        self.mocks["restore"].assert_not_called()
+
+        msg = MagicMock()
+        msg.get_body.return_value = json.dumps(message).encode("utf-8")
+        controller.process_queue_item(msg)
+        return self.mock_record_run.call_args[0][3]
+
+    def test_restores_into_shadow_tables(self):
+        """Test that a sound backup restores and its balances match."""
+        result = self._rehearse()
+
+        statuses = [c[0][2] for c in self.mock_record_run.call_args_list[1:]]
+        self.assertEqual(statuses, ["queued", "running", "succeeded"])
+
+        self.assertTrue(result["passed"])
+        self.mocks["shadow"].assert_called_once_with("drtest")
+        transactions = controller.db_service.transactions_table
+        self.mocks["restore"].assert_any_call(
+            f"drtest{transactions}", self.tables[transactions]
+        )
+        self.assertEqual([t["restored"] for t in result["tables"]], [2, 1])
+        totals = result["checks"][1]
+        self.assertEqual(totals["actual"], {"2025-05": 8.25})
+        self.assertEqual(result["checks"][0]["actual"]["amount"], 12.0)
+        run = self.mock_record_run.call_args[0]
+        self.assertEqual(run[:2], ("dr-rehearsal", datetime(2025, 6, 1, 2, 15)))
+        self.assertEqual(result["requestedBy"], "admin@test.com")
+
+    def test_reports_mismatched_balances(self):
+        """Test that a restore whose totals differ from the backup fails."""
+        self.restored.get_transactions.side_effect = lambda month: []
+
+        result = self._rehearse()
+
+        self.assertFalse(result["passed"])
+        self.assertFalse(result["checks"][1]["passed"])
+        run = self.mock_record_run.call_args[0]
+        self.assertEqual(run[2], "failed")
+        self.assertEqual(run[4], ["monthly transaction totals differs"])
+
+    def test_failed_restore_is_recorded_and_retried(self):
+        """Test that a rehearsal that errors is recorded as failed and raised."""
+        self.mocks["restore"].side_effect = Exception("table down")
+
+        with self.assertRaises(Exception):
+            self._rehearse()
+
+        run = self.mock_record_run.call_args[0]
+        self.assertEqual((run[2], run[4]), ("failed", ["table down"]))
+
+    @patch.object(controller.db_service, "get_job_runs", return_value=[])
+    def test_lists_rehearsals(self, mock_runs):
+        """Test that admins can follow queued rehearsals."""
+        self.req.method = "GET"
+
+        resp = controller.handle_backup_rehearsals(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        mock_runs.assert_called_once_with("dr-rehearsal", 30)
+        sign_in(self.req, "member@test.com")
+        self.assertEqual(controller.handle_backup_rehearsals(self.req).status_code, 403)
+
+    def test_only_admins_rehearse(self):
+        """Test that members can't restore into shadow tables."""
+        sign_in(self.req, "member@test.com")
+
+        self.assertEqual(controller.handle_backup_rehearsal(self.req).status_code, 403)
+        self.mocks["enqueue"].assert_not_called()
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,85 @@
+"""
+Tests for category budget status and savings transfer suggestions.
+"""
+
+import unittest
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.budgets import (
+    budget_status,
+    discretionary_leftover,
+    month_elapsed,
+    suggested_transfer,
+)
+from rmanalyzer.models import Category
+
+
+class TestBudgets(unittest.TestCase):
+    def test_budget_status(self):
+        limits = {
+            Category.GROCERIES: Decimal("400.00"),
+            Category.DINING: Decimal("100.00"),
+            Category.PETS: Decimal("0.00"),
+        }
+        spend = {Category.DINING: Decimal("150.00"), Category.TRAVEL: Decimal("90")}
+
+        status = budget_status(limits, spend)
+
+        self.assertEqual(
+            [s["category"] for s in status], ["Dining & Drinks", "Groceries", "Pets"]
+        )
+        dining, groceries, pets = status
+        self.assertEqual(dining["remaining"], -50.0)
+        self.assertEqual(dining["percentUsed"], 150.0)
+        self.assertTrue(dining["overBudget"])
+        self.assertEqual(groceries["spent"], 0.0)
+        self.assertFalse(groceries["overBudget"])
+        self.assertIsNone(pets["percentUsed"])
+
+    def test_discretionary_leftover(self):
+        limits = {
+            Category.GROCERIES: Decimal("400.00"),
+            Category.DINING: Decimal("200.00"),
+            Category.PETS: Decimal("150.00"),
+        }
+        spend = {
+            Category.GROCERIES: Decimal("100.00"),
+            Category.DINING: Decimal("230.00"),
+            Category.PETS: Decimal("40.00"),
+        }
+
+        # Groceries is essential; dining's overspend comes out of pets'
+        leftover = discretionary_leftover(limits, spend, [Category.GROCERIES])
+        self.assertEqual(leftover, Decimal("80.00"))
+
+        spend[Category.PETS] = Decimal("200.00")
+        self.assertEqual(
+            discretionary_leftover(limits, spend, [Category.GROCERIES]), Decimal("0")
+        )
+
+    def test_leftover_is_pro_rated(self):
+        limits = {Category.DINING: Decimal("300.00")}
+        spend = {Category.DINING: Decimal("40.00")}
+        elapsed = month_elapsed("2025-04", date(2025, 4, 10))
+        self.assertEqual(elapsed, Decimal("1") / 3)
+        self.assertEqual(
+            discretionary_leftover(limits, spend, [], elapsed).quantize(Decimal("1")),
+            Decimal("60"),
+        )
+
+        self.assertEqual(month_elapsed("2025-03", date(2025, 4, 10)), 1)
+        self.assertEqual(month_elapsed("2025-05", date(2025, 4, 10)), 0)
+
+    def test_suggested_transfer(self):
+        self.assertEqual(
+            suggested_transfer(Decimal("80.00"), Decimal("0.5")), Decimal("40")
+        )
+        self.assertEqual(
+            suggested_transfer(Decimal("12.99"), Decimal("0.5")), Decimal("6")
+        )
+        self.assertEqual(suggested_transfer(Decimal("1.50"), Decimal("0.5")), 0)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,70 @@
+"""
+Tests for income detection and over-allocation.
+"""
+
+import unittest
+from datetime import date, timedelta
+from decimal import Decimal
+
+from rmanalyzer.cashflow import (
+    detect_income,
+    monthly_income,
+    over_allocation,
+    required_payment,
+)
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+
+
+def _deposit(day, account=1, amount="-1000.00"):
+    return Transaction(
+        day, "PAYROLL", account, Decimal(amount), Category.OTHER, IgnoredFrom.NOTHING
+    )
+
+
+class TestCashflow(unittest.TestCase):
+    def test_biweekly_pay_is_income(self):
+        start = date(2025, 1, 3)
+        deposits = [_deposit(start + timedelta(days=14 * i)) for i in range(4)]
+
+        (stream,) = detect_income(deposits, card_accounts=[])
+
+        self.assertEqual(stream.cadence_days, 14)
+        self.assertEqual(stream.average_amount, Decimal("1000.00"))
+        self.assertEqual(monthly_income([stream]), Decimal("2174.11"))
+
+    def test_card_credits_and_charges_are_not_income(self):
+        start = date(2025, 1, 3)
+        payments = [_deposit(start + timedelta(days=30 * i), 9) for i in range(4)]
+        charges = [
+            _deposit(start + timedelta(days=30 * i), amount="50.00") for i in range(4)
+        ]
+
+        self.assertEqual(detect_income(payments + charges, card_accounts=[9]), [])
+        self.assertEqual(monthly_income([]), Decimal("0.00"))
+
+    def test_required_payment(self):
+        self.assertEqual(
+            required_payment({"balance": 3000.0, "minimumPayment": 75.0}),
+            Decimal("75.0"),
+        )
+        self.assertEqual(
+            required_payment({"balance": 20.0, "minimumPayment": 35.0}),
+            Decimal("20.0"),
+        )
+        self.assertEqual(
+            required_payment({"balance": 5000.0, "apr": 24.0}), Decimal("150.00")
+        )
+        self.assertEqual(required_payment({"balance": None}), Decimal("0.00"))
+
+    def test_over_allocation(self):
+        self.assertEqual(
+            over_allocation(Decimal("2000"), Decimal("1800"), Decimal("300")),
+            Decimal("100"),
+        )
+        self.assertIsNone(
+            over_allocation(Decimal("2000"), Decimal("1700"), Decimal("300"))
+        )
+
+
+if __name__ == "__main__":
+    unittest.main()
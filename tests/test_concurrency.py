@@ -0,0 +1,62 @@
+"""
+Tests for optimistic concurrency retries.
+"""
+
+import unittest
+from unittest.mock import MagicMock
+
+from azure.core.exceptions import ResourceModifiedError
+
+from rmanalyzer.services import ConcurrencyRetrier
+
+
+class TestConcurrencyRetrier(unittest.TestCase):
+    """Test suite for ConcurrencyRetrier."""
+
+    def setUp(self):
+        self.sleeps = []
+        self.retrier = ConcurrencyRetrier(
+            retries=2, backoff=0.1, sleep=self.sleeps.append
+        )
+
+    def test_returns_first_success(self):
+        """Test that an uncontended operation runs once."""
+        operation = MagicMock(return_value="saved")
+
+        self.assertEqual(self.retrier.run(operation), "saved")
+
+        operation.assert_called_once()
+        self.assertEqual(self.sleeps, [])
+
+    def test_retries_conflicts_with_backoff(self):
+        """Test that 412 conflicts rerun the whole operation with growing delays."""
+        operation = MagicMock(
+            side_effect=[ResourceModifiedError(), ResourceModifiedError(), "saved"]
+        )
+
+        self.assertEqual(self.retrier.run(operation), "saved")
+
+        self.assertEqual(operation.call_count, 3)
+        self.assertEqual(self.sleeps, [0.1, 0.2])
+
+    def test_gives_up_after_retries(self):
+        """Test that a conflict persisting past the retries is raised."""
+        operation = MagicMock(side_effect=ResourceModifiedError())
+
+        with self.assertRaises(ResourceModifiedError):
+            self.retrier.run(operation)
+
+        self.assertEqual(operation.call_count, 3)
+
+    def test_other_errors_are_not_retried(self):
+        """Test that only ETag conflicts are retried."""
+        operation = MagicMock(side_effect=ValueError("bad"))
+
+        with self.assertRaises(ValueError):
+            self.retrier.run(operation)
+
+        operation.assert_called_once()
+
+
+if __name__ == "__main__":
+    unittest.main()
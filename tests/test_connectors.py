@@ -0,0 +1,127 @@
+"""
+Tests for SFTP/FTPS pull connectors and their secrets.
+"""
+
+import ssl
+import unittest
+from unittest.mock import MagicMock, patch
+
+from rmanalyzer.connectors import Connector, RemoteFile, file_key, open_session
+from rmanalyzer.services import SecretProvider
+
+CONNECTOR_JSON = {
+    "protocol": "sftp",
+    "host": "sftp.bank.test",
+    "username": "household",
+    "secretName": "SECRET_BANK",
+    "path": "/outbound",
+    "hostKey": "ssh-ed25519 AAAAC3Nza",
+    "intervalMinutes": 30,
+}
+
+
+class TestConnector(unittest.TestCase):
+    """Test suite for the connector model."""
+
+    def test_json_round_trip(self):
+        """Test that the API shape round trips, with the protocol's default port."""
+        connector = Connector.from_json("bank", CONNECTOR_JSON)
+
+        self.assertEqual(connector.port, None)
+        self.assertEqual(
+            connector.to_json(), {"name": "bank", "port": 22, **CONNECTOR_JSON}
+        )
+
+    def test_entity_round_trip(self):
+        """Test that unset optional fields are left off the entity."""
+        connector = Connector("card", "ftps", "ftp.card.test", "me", "SECRET_CARD")
+
+        entity = connector.to_entity("default_CONNECTORS")
+
+        self.assertNotIn("Port", entity)
+        self.assertNotIn("HostKey", entity)
+        self.assertEqual(Connector.from_entity(entity), connector)
+        self.assertEqual(connector.effective_port, 21)
+
+    def test_file_key(self):
+        """Test that a replaced file counts as a new delivery."""
+        original = RemoteFile("/out/may.csv", 120, "2025-06-01T09:30:00")
+
+        self.assertEqual(
+            file_key(original),
+            file_key(RemoteFile("/out/may.csv", 120, "2025-06-01T09:30:00")),
+        )
+        self.assertNotEqual(
+            file_key(original), file_key(RemoteFile("/out/may.csv", 121, None))
+        )
+
+
+class TestFtpsSession(unittest.TestCase):
+    """Test suite for pulling files over FTPS."""
+
+    @patch("rmanalyzer.connectors.ftplib.FTP_TLS")
+    def test_lists_and_reads_files(self, mock_ftp_class):
+        """Test that only regular files are listed, over an encrypted data channel."""
+        ftp = mock_ftp_class.return_value
+        ftp.mlsd.return_value = [
+            ("may.csv", {"type": "file", "size": "120", "modify": "20250601093000"}),
+            ("archive", {"type": "dir"}),
+        ]
+        ftp.retrbinary.side_effect = lambda cmd, callback: callback(b"csv")
+        connector = Connector(
+            "card", "ftps", "ftp.card.test", "me", "SECRET_CARD", "/out"
+        )
+
+        with open_session(connector, "hunter2") as session:
+            files = session.list_files()
+            content = session.read(files[0].path)
+
+        ftp.connect.assert_called_once_with("ftp.card.test", 21)
+        ftp.login.assert_called_once_with("me", "hunter2")
+        ftp.prot_p.assert_called_once()
+        self.assertEqual(
+            files, [RemoteFile("/out/may.csv", 120, "2025-06-01T09:30:00")]
+        )
+        self.assertEqual(content, b"csv")
+        ftp.close.assert_called_once()
+
+    @patch("rmanalyzer.connectors.ftplib.FTP_TLS")
+    def test_verifies_server_certificate(self, mock_ftp_class):
+        """Test that FTPS only signs in to a server with a trusted certificate."""
+        connector = Connector("card", "ftps", "ftp.card.test", "me", "SECRET_CARD")
+
+        with open_session(connector, "hunter2"):
+            pass
+
+        context = mock_ftp_class.call_args.kwargs["context"]
+        self.assertEqual(context.verify_mode, ssl.CERT_REQUIRED)
+        self.assertTrue(context.check_hostname)
+        mock_ftp_class.return_value.prot_p.assert_called_once()
+
+    def test_sftp_requires_host_key(self):
+        """Test that SFTP never connects to a server it can't verify."""
+        connector = Connector("bank", "sftp", "sftp.bank.test", "me", "SECRET_BANK")
+
+        with patch.dict("sys.modules", {"paramiko": MagicMock()}):
+            with self.assertRaises(ValueError):
+                with open_session(connector, "hunter2"):
+                    pass
+
+
+class TestSecretProvider(unittest.TestCase):
+    """Test suite for looking up connector secrets."""
+
+    def test_get(self):
+        """Test that only set secrets with the secret prefix can be read."""
+        settings = {"SECRET_BANK": "hunter2", "AzureWebJobsStorage": "conn"}
+        provider = SecretProvider(settings.get)
+
+        self.assertEqual(provider.get("SECRET_BANK"), "hunter2")
+        with self.assertRaises(ValueError):
+            provider.get("AzureWebJobsStorage")
+        with self.assertRaises(ValueError):
+            provider.get("SECRET_MISSING")
+
+
+if __name__ == "__main__":
+    unittest.main()
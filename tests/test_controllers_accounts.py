@@ -0,0 +1,628 @@
+"""
+Tests for account controller logic.
+"""
+
+import json
+import os
+import unittest
+from datetime import date, datetime
+from decimal import Decimal
+from unittest.mock import MagicMock, patch
+
+from fixtures import http_request
+from rmanalyzer.controller import controller
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+
+
+class TestAccountAssignmentController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", params={"month": "2025-01"})
+        self.people = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+            {"Name": "B", "Email": "b@test.com", "Accounts": [2]},
+        ]
+        patcher = patch.object(
+            controller.db_service, "get_all_people", return_value=self.people
+        )
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    @patch.object(controller.db_service, "get_transactions")
+    def test_list_unassigned(self, mock_transactions):
+        mock_transactions.return_value = [
+            Transaction(
+                date(2025, 1, d),
+                "X",
+                account,
+                Decimal("10.00"),
+                Category.DINING,
+                IgnoredFrom.NOTHING,
+            )
+            for d, account in [(1, 1), (2, 9), (3, 9)]
+        ]
+
+        resp = controller.handle_unassigned_accounts(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            json.loads(resp.get_body())["accounts"],
+            [{"accountNumber": 9, "count": 2, "total": 20.0}],
+        )
+
+    @patch.dict(os.environ, {"ADMIN_EMAILS": "a@test.com"})
+    @patch.object(controller.db_service, "save_person")
+    def test_assign_account(self, mock_save):
+        self.req.get_json = MagicMock(
+            return_value={"accountNumber": 9, "email": "B@test.com"}
+        )
+
+        resp = controller.handle_assign_account(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        saved = mock_save.call_args[0][0]
+        self.assertEqual(saved["Email"], "b@test.com")
+        self.assertEqual(saved["Accounts"], [2, 9])
+
+    @patch.dict(os.environ, {"ADMIN_EMAILS": "a@test.com"})
+    @patch.object(controller.db_service, "save_person")
+    def test_assign_account_owned_by_someone_else(self, mock_save):
+        self.req.get_json = MagicMock(
+            return_value={"accountNumber": 1, "email": "b@test.com"}
+        )
+        resp = controller.handle_assign_account(self.req)
+        self.assertEqual(resp.status_code, 409)
+        mock_save.assert_not_called()
+
+    @patch.dict(os.environ, {"ADMIN_EMAILS": "a@test.com"})
+    def test_assign_account_requires_fields(self):
+        self.req.get_json = MagicMock(return_value={"email": "b@test.com"})
+        resp = controller.handle_assign_account(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    @patch.object(controller.db_service, "save_person")
+    def test_assign_account_requires_admin(self, mock_save):
+        self.req.get_json = MagicMock(
+            return_value={"accountNumber": 9, "email": "b@test.com"}
+        )
+        resp = controller.handle_assign_account(self.req)
+        self.assertEqual(resp.status_code, 403)
+        mock_save.assert_not_called()
+
+
+class TestAccountSyncController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com")
+        self.accounts = [
+            {"accountId": "acc-1", "institution": "Chase", "mask": "1234"}
+        ]
+        patcher = patch.object(
+            controller.db_service,
+            "get_accounts",
+            side_effect=lambda _: self.accounts,
+        )
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    @patch.object(controller.db_service, "upsert_accounts")
+    def test_sync_rejects_mask_used_by_another_account(self, mock_upsert):
+        self.req.get_json = MagicMock(
+            return_value={"accounts": [{"accountId": "acc-2", "mask": "1234"}]}
+        )
+
+        resp = controller.handle_account_sync(self.req)
+
+        self.assertEqual(resp.status_code, 409)
+        conflict = json.loads(resp.get_body())["conflicts"][0]
+        self.assertEqual(conflict["accountId"], "acc-2")
+        self.assertEqual(conflict["conflictsWith"]["institution"], "Chase")
+        mock_upsert.assert_not_called()
+
+    @patch.object(controller.db_service, "upsert_accounts", return_value=1)
+    def test_sync_override_allows_shared_mask(self, mock_upsert):
+        self.req.get_json = MagicMock(
+            return_value={
+                "accounts": [{"accountId": "acc-2", "mask": "1234"}],
+                "override": True,
+            }
+        )
+        resp = controller.handle_account_sync(self.req)
+        self.assertEqual(resp.status_code, 200)
+        mock_upsert.assert_called_once()
+
+    @patch.object(controller.db_service, "upsert_accounts")
+    def test_sync_override_false_still_checks_masks(self, mock_upsert):
+        self.req.get_json = MagicMock(
+            return_value={
+                "accounts": [{"accountId": "acc-2", "mask": "1234"}],
+                "override": "false",
+            }
+        )
+        resp = controller.handle_account_sync(self.req)
+        self.assertEqual(resp.status_code, 409)
+        mock_upsert.assert_not_called()
+
+    @patch.object(controller.db_service, "upsert_accounts", return_value=2)
+    def test_sync_detects_duplicates_within_payload(self, _):
+        self.accounts = []
+        self.req.get_json = MagicMock(
+            return_value={
+                "accounts": [
+                    {"accountId": "acc-2", "mask": "9876"},
+                    {"accountId": "acc-3", "mask": "9876"},
+                ]
+            }
+        )
+        resp = controller.handle_account_sync(self.req)
+        self.assertEqual(resp.status_code, 409)
+
+    @patch.object(controller.db_service, "upsert_accounts", return_value=1)
+    def test_sync(self, mock_upsert):
+        self.req.get_json = MagicMock(
+            return_value={
+                "accounts": [
+                    {
+                        "accountId": "acc-1",
+                        "institution": "Chase",
+                        "mask": "1234",
+                        "balance": "250.10",
+                        "limit": 5000,
+                    }
+                ]
+            }
+        )
+
+        resp = controller.handle_account_sync(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(json.loads(resp.get_body())["synced"], 1)
+        email, accounts = mock_upsert.call_args[0]
+        self.assertEqual(email, "a@test.com")
+        self.assertEqual(accounts[0]["balance"], 250.1)
+        self.assertEqual(accounts[0]["limit"], 5000.0)
+
+    def test_sync_rejects_invalid_account(self):
+        self.req.get_json = MagicMock(
+            return_value={"accounts": [{"accountId": "a/b", "limit": -1}]}
+        )
+        resp = controller.handle_account_sync(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    def test_sync_reports_field_level_errors(self):
+        self.req.get_json = MagicMock(
+            return_value={
+                "accounts": [
+                    {"accountId": "acc-1"},
+                    {
+                        "accountId": "acc-2",
+                        "name": "x" * 101,
+                        "balance": -5,
+                        "dueDay": 32,
+                        "mask": "12a4",
+                    },
+                ]
+            }
+        )
+
+        resp = controller.handle_account_sync(self.req)
+
+        self.assertEqual(resp.status_code, 400)
+        fields = [f["field"] for f in json.loads(resp.get_body())["fields"]]
+        self.assertEqual(
+            fields,
+            [
+                "accounts[1].name",
+                "accounts[1].mask",
+                "accounts[1].balance",
+                "accounts[1].dueDay",
+            ],
+        )
+
+    @patch.object(controller.db_service, "upsert_accounts", return_value=1)
+    def test_sync_reward_rates(self, mock_upsert):
+        self.req.get_json = MagicMock(
+            return_value={
+                "accounts": [
+                    {
+                        "accountId": "acc-1",
+                        "rewardRate": "1.5",
+                        "categoryRewardRates": {"Groceries": 3},
+                    }
+                ]
+            }
+        )
+
+        resp = controller.handle_account_sync(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        account = mock_upsert.call_args[0][1][0]
+        self.assertEqual(account["rewardRate"], 1.5)
+        self.assertEqual(account["categoryRewardRates"], {"Groceries": 3.0})
+
+    @patch.object(controller.db_service, "upsert_accounts", return_value=1)
+    def test_sync_reminder_lead_days(self, mock_upsert):
+        self.req.get_json = MagicMock(
+            return_value={
+                "accounts": [{"accountId": "acc-1", "reminderLeadDays": [1, "7", 3]}]
+            }
+        )
+
+        resp = controller.handle_account_sync(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        account = mock_upsert.call_args[0][1][0]
+        self.assertEqual(account["reminderLeadDays"], [7, 3, 1])
+
+    def test_sync_rejects_invalid_lead_days(self):
+        self.req.get_json = MagicMock(
+            return_value={
+                "accounts": [
+                    {"accountId": "acc-1", "reminderLeadDays": [40]},
+                    {"accountId": "acc-2", "reminderLeadDays": [1, 2, 3, 4, 5, 6]},
+                ]
+            }
+        )
+        resp = controller.handle_account_sync(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(
+            [f["field"] for f in json.loads(resp.get_body())["fields"]],
+            ["accounts[0].reminderLeadDays", "accounts[1].reminderLeadDays"],
+        )
+
+    def test_sync_rejects_unknown_reward_category(self):
+        self.req.get_json = MagicMock(
+            return_value={
+                "accounts": [
+                    {"accountId": "acc-1", "categoryRewardRates": {"Loans": 2}}
+                ]
+            }
+        )
+        resp = controller.handle_account_sync(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    @patch.object(controller.db_service, "get_transactions")
+    @patch.object(controller.db_service, "get_accounts")
+    def test_card_rewards(self, mock_accounts, mock_transactions):
+        self.req.params = {"month": "2025-01"}
+        mock_accounts.return_value = [
+            {
+                "accountId": "acc-1",
+                "mask": "1234",
+                "rewardRate": 2.0,
+                "categoryRewardRates": {},
+            },
+            {
+                "accountId": "acc-2",
+                "mask": "5678",
+                "rewardRate": None,
+                "categoryRewardRates": {},
+            },
+        ]
+        mock_transactions.return_value = [
+            Transaction(
+                date(2025, 1, 5),
+                "Store",
+                1234,
+                Decimal("250.00"),
+                Category.PURCHASES,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+
+        resp = controller.handle_card_rewards(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        body = json.loads(resp.get_body())
+        self.assertEqual(len(body["cards"]), 1)
+        self.assertEqual(body["totalRewards"], 5.0)
+        mock_transactions.assert_called_once_with("2025-01")
+
+    @patch.object(controller.db_service, "get_accounts")
+    def test_best_card(self, mock_accounts):
+        self.req.params = {"category": "Groceries"}
+        mock_accounts.return_value = [
+            {
+                "accountId": "acc-1",
+                "mask": "1234",
+                "rewardRate": 1.0,
+                "categoryRewardRates": {"Groceries": 3.0},
+            }
+        ]
+
+        resp = controller.handle_best_card(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        best = json.loads(resp.get_body())["best"]
+        self.assertEqual(list(best), ["Groceries"])
+        self.assertEqual(best["Groceries"]["rate"], 3.0)
+
+    def test_cards_with_utilization(self):
+        self.accounts = [
+            {"accountId": "acc-1", "balance": 450.0, "limit": 1000.0},
+            {"accountId": "acc-2", "balance": 50.0, "limit": 1000.0},
+        ]
+
+        resp = controller.handle_cards(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        body = json.loads(resp.get_body())
+        self.assertEqual([c["utilization"] for c in body["cards"]], [0.45, 0.05])
+        self.assertEqual(body["utilization"]["ratio"], 0.25)
+        self.assertEqual(body["utilization"]["alertAbove"], 0.3)
+        self.assertFalse(body["utilization"]["overThreshold"])
+
+    @patch.object(controller.db_service, "get_statements")
+    def test_card_statements(self, mock_statements):
+        self.accounts = [
+            {"accountId": "acc-1", "statementCloseDay": 20, "statementBalance": 42.0}
+        ]
+        mock_statements.return_value = [{"closeDate": "2025-03-20", "balance": 42.0}]
+        self.req.route_params = {"id": "acc-1"}
+
+        resp = controller.handle_card_statements(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        body = json.loads(resp.get_body())
+        self.assertEqual(body["statementBalance"], 42.0)
+        self.assertEqual(body["statements"], mock_statements.return_value)
+        mock_statements.assert_called_once_with("a@test.com", "acc-1")
+
+        self.req.route_params = {"id": "someone-elses"}
+        self.assertEqual(controller.handle_card_statements(self.req).status_code, 404)
+
+    @patch("rmanalyzer.controller.datetime")
+    def test_interest_projection(self, mock_datetime):
+        mock_datetime.now.return_value = datetime(2025, 3, 10)
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "balance": 1200.0,
+                "apr": 24.0,
+                "minimumPayment": 40.0,
+            }
+        ]
+        self.req.route_params = {"id": "acc-1"}
+        self.req.params = {}
+
+        resp = controller.handle_interest_projection(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        body = json.loads(resp.get_body())
+        self.assertEqual((body["payment"], body["paymentSource"]), (40.0, "minimum"))
+        self.assertEqual(len(body["schedule"]), 12)
+        self.assertEqual(
+            body["schedule"][0],
+            {"month": 1, "interest": 24.0, "payment": 40.0, "balance": 1184.0},
+        )
+        self.assertTrue(body["paidOff"])
+        self.assertGreater(body["totalInterest"], 0)
+
+        self.req.params = {"payment": "1224", "months": "3"}
+        body = json.loads(controller.handle_interest_projection(self.req).get_body())
+        self.assertEqual(body["paymentSource"], "target")
+        self.assertEqual((body["monthsToPayOff"], body["totalInterest"]), (1, 24.0))
+
+    @patch("rmanalyzer.controller.datetime")
+    def test_interest_projection_never_paid_off(self, mock_datetime):
+        mock_datetime.now.return_value = datetime(2025, 3, 10)
+        self.accounts = [{"accountId": "acc-1", "balance": 1200.0, "apr": 24.0}]
+        self.req.route_params = {"id": "acc-1"}
+        self.req.params = {"payment": "20"}
+
+        body = json.loads(controller.handle_interest_projection(self.req).get_body())
+
+        self.assertFalse(body["paidOff"])
+        self.assertIsNone(body["monthsToPayOff"])
+        self.assertEqual(len(body["schedule"]), 12)
+
+        self.req.params = {"payment": "0"}
+        resp = controller.handle_interest_projection(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.req.params = {}
+        self.req.route_params = {"id": "someone-elses"}
+        resp = controller.handle_interest_projection(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+    def test_sync_rejects_invalid_promo_expiry(self):
+        self.req.get_json = MagicMock(
+            return_value={
+                "accounts": [{"accountId": "acc-1", "promoExpiry": "2025-02-30"}]
+            }
+        )
+        resp = controller.handle_account_sync(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    def test_sync_rejects_invalid_fee_month(self):
+        self.req.get_json = MagicMock(
+            return_value={"accounts": [{"accountId": "acc-1", "feeMonth": 13}]}
+        )
+        resp = controller.handle_account_sync(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    @patch("rmanalyzer.controller.datetime")
+    @patch.object(controller.db_service, "get_transactions")
+    @patch.object(controller.db_service, "get_accounts")
+    def test_card_fees(self, mock_accounts, mock_transactions, mock_datetime):
+        mock_datetime.now.return_value = datetime(2025, 3, 10)
+        mock_accounts.return_value = [
+            {"accountId": "acc-1", "mask": "1234", "annualFee": 95.0, "feeMonth": 6},
+            {"accountId": "acc-2", "mask": "5678", "annualFee": None},
+        ]
+        mock_transactions.side_effect = lambda month: (
+            [
+                Transaction(
+                    date(2025, 1, 5),
+                    "Store",
+                    1234,
+                    Decimal("950.00"),
+                    Category.PURCHASES,
+                    IgnoredFrom.NOTHING,
+                )
+            ]
+            if month == "2025-01"
+            else []
+        )
+
+        resp = controller.handle_card_fees(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        body = json.loads(resp.get_body())
+        self.assertEqual(body["totalFees"], 95.0)
+        self.assertEqual(len(body["cards"]), 1)
+        self.assertEqual(body["cards"][0]["spend"], 950.0)
+        self.assertEqual(body["cards"][0]["feeRate"], 0.1)
+
+    @patch("rmanalyzer.controller.datetime")
+    @patch.object(controller.db_service, "record_reconciliation")
+    @patch.object(controller.db_service, "reconcile_account", return_value=True)
+    def test_reconcile_defaults_to_today(
+        self, mock_reconcile, mock_audit, mock_datetime
+    ):
+        mock_datetime.now.return_value = datetime(2025, 3, 10)
+        self.req.route_params = {"accountId": "acc-1"}
+        self.req.get_json = MagicMock(return_value={"balance": 250})
+
+        resp = controller.handle_account_reconcile(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        body = json.loads(resp.get_body())
+        self.assertEqual(body["lastReconciledDate"], "2025-03-10")
+        self.assertEqual(body["status"], "baseline")
+        mock_reconcile.assert_called_once_with(
+            "a@test.com", "acc-1", 250.0, date(2025, 3, 10)
+        )
+        mock_audit.assert_called_once()
+
+    def _reconciled_card(self):
+        self.accounts[0].update(
+            {"lastReconciledDate": "2025-02-10", "reconciledBalance": 100.0}
+        )
+        self.req.route_params = {"accountId": "acc-1"}
+
+        def transactions(month):
+            return [
+                Transaction(
+                    day,
+                    "Shop",
+                    account,
+                    Decimal(amount),
+                    Category.OTHER,
+                    IgnoredFrom.NOTHING,
+                )
+                for day, account, amount in (
+                    (date(2025, 2, 10), 1234, "999.00"),
+                    (date(2025, 2, 20), 1234, "60.00"),
+                    (date(2025, 3, 5), 1234, "-40.00"),
+                    (date(2025, 3, 5), 9999, "500.00"),
+                )
+                if day.strftime("%Y-%m") == month
+            ]
+
+        patcher = patch.object(
+            controller.db_service, "get_transactions", side_effect=transactions
+        )
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    @patch.object(controller.db_service, "record_reconciliation")
+    @patch.object(controller.db_service, "reconcile_account", return_value=True)
+    def test_reconcile_matches_transactions_since_last(
+        self, mock_reconcile, mock_audit
+    ):
+        self._reconciled_card()
+        self.req.get_json = MagicMock(
+            return_value={"balance": 120, "date": "2025-03-09"}
+        )
+
+        body = json.loads(controller.handle_account_reconcile(self.req).get_body())
+
+        self.assertEqual(body["status"], "matched")
+        self.assertEqual(body["expectedBalance"], 120.0)
+        self.assertEqual(body["transactions"], 2)
+        self.assertTrue(body["reconciled"])
+        mock_reconcile.assert_called_once()
+        record = mock_audit.call_args[0][2]
+        self.assertEqual(
+            (record["since"], record["reconciledBy"]), ("2025-02-10", "a@test.com")
+        )
+
+    @patch.object(controller.db_service, "record_reconciliation")
+    @patch.object(controller.db_service, "reconcile_account", return_value=True)
+    def test_reconcile_flags_discrepancy(self, mock_reconcile, mock_audit):
+        self._reconciled_card()
+        self.req.get_json = MagicMock(
+            return_value={"balance": 130.5, "date": "2025-03-09"}
+        )
+
+        body = json.loads(controller.handle_account_reconcile(self.req).get_body())
+
+        self.assertEqual(body["status"], "discrepancy")
+        self.assertEqual(body["discrepancy"], 10.5)
+        self.assertFalse(body["reconciled"])
+        self.assertEqual(body["lastReconciledDate"], "2025-02-10")
+        mock_reconcile.assert_not_called()
+        self.assertFalse(mock_audit.call_args[0][2]["reconciled"])
+
+        # Accepting the difference reconciles the card anyway
+        self.req.get_json = MagicMock(
+            return_value={"balance": 130.5, "date": "2025-03-09", "accept": True}
+        )
+        body = json.loads(controller.handle_account_reconcile(self.req).get_body())
+        self.assertTrue(body["reconciled"])
+        mock_reconcile.assert_called_once()
+
+    @patch.object(controller.db_service, "reconcile_account")
+    def test_reconcile_rejects_date_before_last(self, mock_reconcile):
+        self._reconciled_card()
+        self.req.get_json = MagicMock(
+            return_value={"balance": 120, "date": "2025-02-10"}
+        )
+
+        resp = controller.handle_account_reconcile(self.req)
+
+        self.assertEqual(resp.status_code, 400)
+        mock_reconcile.assert_not_called()
+
+    @patch.object(controller.db_service, "get_reconciliations", return_value=[])
+    def test_reconciliations(self, mock_history):
+        self.req.route_params = {"accountId": "acc-1"}
+        self.assertEqual(controller.handle_reconciliations(self.req).status_code, 200)
+        mock_history.assert_called_once_with("a@test.com", "acc-1")
+
+        self.req.route_params = {"accountId": "acc-9"}
+        self.assertEqual(controller.handle_reconciliations(self.req).status_code, 404)
+
+    @patch.object(controller.db_service, "reconcile_account")
+    def test_reconcile_rejects_future_date(self, mock_reconcile):
+        self.req.route_params = {"accountId": "acc-1"}
+        self.req.get_json = MagicMock(
+            return_value={"balance": 250, "date": "2999-01-01"}
+        )
+
+        resp = controller.handle_account_reconcile(self.req)
+
+        self.assertEqual(resp.status_code, 400)
+        mock_reconcile.assert_not_called()
+
+    @patch.object(controller.db_service, "reconcile_account", return_value=False)
+    def test_reconcile_unknown_account(self, _):
+        self.req.route_params = {"accountId": "acc-9"}
+        self.req.get_json = MagicMock(return_value={"balance": 250})
+        resp = controller.handle_account_reconcile(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+    @patch.object(controller.db_service, "record_account_import")
+    def test_import_advances_last_imported_date_per_mask(self, mock_record):
+        transactions = [
+            Transaction(
+                day, "Shop", 1234, Decimal("5.00"), Category.OTHER, IgnoredFrom.NOTHING
+            )
+            for day in (date(2025, 1, 3), date(2025, 1, 20))
+        ]
+
+        controller._record_account_imports([{"Email": "a@test.com"}], transactions)
+
+        mock_record.assert_called_once_with("a@test.com", "acc-1", date(2025, 1, 20))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,1944 @@
+"""
+Tests for admin controller logic.
+"""
+
+import json
+import os
+import unittest
+from datetime import datetime
+from decimal import Decimal
+from unittest.mock import MagicMock, patch
+
+from fixtures import http_request, sign_in, start_patches
+from rmanalyzer import sandbox
+from rmanalyzer.connectors import Connector
+from rmanalyzer.controller import controller
+from rmanalyzer.mailbox import Mailbox, MailRule
+from rmanalyzer.models import AddedCategory, Category
+from rmanalyzer.rules import Rule
+from rmanalyzer.services import BlobKind
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com, other@test.com"})
+class TestPurgeController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request()
+
+        self.keys = {
+            "transactions": [{"PartitionKey": "default_2025-01", "RowKey": "r1"}],
+            "people": [{"PartitionKey": "PEOPLE", "RowKey": "a@test.com"}],
+        }
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "list_household_keys",
+                return_value=self.keys,
+            ),
+            patch.object(
+                controller.blob_service,
+                "list_blobs",
+                side_effect=lambda kind: ["x.csv"] if kind.value == "uploads" else [],
+            ),
+            patch.object(controller.db_service, "delete_entities"),
+            patch.object(controller.blob_service, "delete_blob"),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_delete_entities, self.mock_delete_blob = mocks[2], mocks[3]
+
+    def test_purge_unauthorized(self):
+        resp = controller.handle_admin_purge(self.req)
+        self.assertEqual(resp.status_code, 401)
+
+    def test_purge_forbidden_for_non_admin(self):
+        sign_in(self.req, "user@test.com")
+        resp = controller.handle_admin_purge(self.req)
+        self.assertEqual(resp.status_code, 403)
+
+    def test_purge_dry_run_by_default(self):
+        sign_in(self.req, "Admin@Test.com")
+        resp = controller.handle_admin_purge(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertTrue(payload["dryRun"])
+        self.assertEqual(payload["tables"]["transactions"]["count"], 1)
+        self.assertEqual(payload["blobs"]["uploads"], ["x.csv"])
+        self.assertEqual(payload["blobs"]["reports"], [])
+        self.assertTrue(payload["confirmationToken"])
+        self.mock_delete_entities.assert_not_called()
+        self.mock_delete_blob.assert_not_called()
+
+    def test_purge_requires_matching_token(self):
+        sign_in(self.req, "admin@test.com")
+        self.req.get_json.return_value = {
+            "dryRun": False,
+            "confirmationToken": "stale",
+        }
+        resp = controller.handle_admin_purge(self.req)
+
+        self.assertEqual(resp.status_code, 409)
+        self.mock_delete_entities.assert_not_called()
+
+    def test_purge_deletes_with_token(self):
+        sign_in(self.req, "admin@test.com")
+        dry = json.loads(controller.handle_admin_purge(self.req).get_body())
+
+        self.req.get_json.return_value = {
+            "dryRun": False,
+            "confirmationToken": dry["confirmationToken"],
+        }
+        resp = controller.handle_admin_purge(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(self.mock_delete_entities.call_count, 2)
+        self.mock_delete_blob.assert_called_once_with("x.csv", BlobKind.UPLOADS)
+
+
+@patch.dict(
+    os.environ,
+    {"ADMIN_EMAILS": "admin@test.com", "RETENTION_UPLOADS_DAYS": "30"},
+)
+class TestRetentionController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com")
+
+    @patch.object(controller.db_service, "list_tables", return_value=[])
+    @patch.object(controller.db_service, "get_job_runs", return_value=[])
+    @patch.object(controller.blob_service, "list_blobs", return_value=[])
+    @patch.object(controller.blob_service, "list_blobs_before")
+    @patch.object(controller.db_service, "list_expired_keys")
+    def test_retention_preview(self, mock_expired, mock_blobs, *_):
+        mock_expired.return_value = {}
+        mock_blobs.return_value = ["old.csv"]
+
+        resp = controller.handle_retention_preview(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["policy"]["uploadsDays"], 30)
+        self.assertIsNone(payload["cutoffs"]["transactions"])
+        self.assertEqual(payload["blobs"], ["old.csv"])
+        self.assertIsNotNone(payload["cutoffs"]["activity"])
+        self.assertEqual(payload["policy"]["jobsDays"], 90)
+        self.assertEqual(payload["policy"]["metricsDays"], 31)
+        args = mock_expired.call_args[0]
+        self.assertEqual(args[:2], (None, None))
+        self.assertEqual(len(args), 5)
+
+    @patch.object(controller.db_service, "list_tables", return_value=[])
+    @patch.object(controller.db_service, "get_job_runs", return_value=[])
+    @patch.object(controller.blob_service, "list_blobs", return_value=[])
+    @patch.object(controller.blob_service, "delete_blob")
+    @patch.object(controller.db_service, "delete_entities")
+    @patch.object(controller.blob_service, "list_blobs_before")
+    @patch.object(controller.db_service, "list_expired_keys")
+    def test_run_retention_job(
+        self, mock_expired, mock_blobs, mock_delete_entities, mock_delete_blob, *_
+    ):
+        keys = [{"PartitionKey": "default_2020-01", "RowKey": "r1"}]
+        mock_expired.return_value = {"transactions": keys}
+        mock_blobs.return_value = ["old.csv"]
+
+        controller.run_retention_job()
+
+        mock_delete_entities.assert_called_once_with("transactions", keys)
+        mock_delete_blob.assert_called_once_with("old.csv")
+
+    @patch.object(controller.db_service, "drop_table")
+    @patch.object(controller.db_service, "list_tables")
+    @patch.object(controller.db_service, "get_job_runs")
+    @patch.object(controller.blob_service, "list_blobs")
+    @patch.object(controller.blob_service, "delete_blob")
+    @patch.object(controller.blob_service, "list_blobs_before", return_value=[])
+    @patch.object(controller.db_service, "list_expired_keys", return_value={})
+    def test_retention_removes_old_backups_and_shadow_tables(
+        self, _, __, mock_delete_blob, mock_list, mock_runs, mock_tables, mock_drop
+    ):
+        mock_list.return_value = [
+            "20200101T021500/people.json.enc",
+            "20200101T021500/manifest.json",
+            "20200102T021500/people.json.enc",
+            f"{datetime.now():%Y%m%d}T021500/people.json.enc",
+        ]
+        mock_runs.return_value = [
+            {"status": "succeeded", "startedAt": "2020-01-02T09:00:00"}
+        ]
+        mock_tables.return_value = ["drtestpeople"]
+
+        controller.run_retention_job()
+
+        # The latest complete snapshot is kept however old, and recent ones too
+        mock_delete_blob.assert_called_once_with(
+            "20200102T021500/people.json.enc", BlobKind.BACKUPS
+        )
+        mock_tables.assert_called_once_with("drtest")
+        mock_drop.assert_called_once_with("drtestpeople")
+
+        mock_drop.reset_mock()
+        mock_runs.return_value = [
+            {"status": "running", "startedAt": "2020-01-02T09:00:00"}
+        ]
+        controller.run_retention_job()
+        mock_drop.assert_not_called()
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestJobHistoryController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com")
+
+    @patch.object(controller.db_service, "get_job_runs")
+    def test_nightly_history(self, mock_runs):
+        mock_runs.return_value = [{"status": "succeeded", "errors": []}]
+        self.req.params = {"limit": "1000"}
+
+        resp = controller.handle_nightly_job_history(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(json.loads(resp.get_body())["runs"][0]["status"], "succeeded")
+        mock_runs.assert_called_once_with("nightly", 365)
+
+    def test_nightly_history_requires_admin(self):
+        sign_in(self.req, "user@test.com")
+        resp = controller.handle_nightly_job_history(self.req)
+        self.assertEqual(resp.status_code, 403)
+
+    def test_nightly_history_rejects_invalid_limit(self):
+        self.req.params = {"limit": "0"}
+        resp = controller.handle_nightly_job_history(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestStorageOpsController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com")
+
+    @patch("rmanalyzer.controller.shared_metrics.flush")
+    @patch.object(controller.live_db_service, "get_metric_counts")
+    def test_storage_ops(self, mock_counts, mock_flush):
+        # Two workers' counts for the same endpoint
+        mock_counts.return_value = [
+            {"table:get_transactions": {"requests": 6}, "http": {"requests": 2}},
+            {"table:get_transactions": {"requests": 4}},
+        ]
+        self.req.params = {"days": "7"}
+
+        resp = controller.handle_storage_ops(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        body = json.loads(resp.get_body())
+        self.assertEqual(body["operations"]["get_transactions"]["requests"], 10)
+        self.assertEqual(body["totals"]["requests"], 10)
+        self.assertIn("estimate", body["monthlyCost"])
+        mock_flush.assert_called_once_with(force=True)
+        since = datetime.fromisoformat(mock_counts.call_args[0][0])
+        self.assertEqual((datetime.now() - since).days, 7)
+
+    def test_storage_ops_rejects_invalid_days(self):
+        self.req.params = {"days": "0"}
+        resp = controller.handle_storage_ops(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    def test_storage_ops_requires_admin(self):
+        self.req.headers = {}
+        resp = controller.handle_storage_ops(self.req)
+        self.assertEqual(resp.status_code, 401)
+
+
+
+@patch.dict(
+    os.environ, {"ADMIN_EMAILS": "admin@test.com", "USAGE_LIMIT_TRANSACTIONS": "100"}
+)
+class TestUsageController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com")
+        self.settings = {}
+        patchers = [
+            patch.object(
+                controller.blob_service,
+                "list_blob_properties",
+                side_effect=lambda prefix, kind: (
+                    [{"name": "a.csv", "size": 300}, {"name": "b.csv", "size": 200}]
+                    if kind == BlobKind.UPLOADS
+                    else []
+                ),
+            ),
+            patch.object(controller.db_service, "count_transactions", return_value=120),
+            patch.object(
+                controller.db_service, "get_usage", return_value={"EmailsSent": 7}
+            ),
+            patch.object(
+                controller.db_service,
+                "get_settings",
+                side_effect=lambda: dict(self.settings),
+            ),
+            patch.object(
+                controller.db_service,
+                "save_setting",
+                side_effect=self.settings.__setitem__,
+            ),
+            patch.object(controller.email_service, "send_email"),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_send = mocks[5]
+
+    def test_usage(self):
+        resp = controller.handle_usage(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        body = json.loads(resp.get_body())
+        self.assertEqual(body["transactions"], 120)
+        self.assertEqual(body["storageBytes"], 500)
+        self.assertEqual(body["storage"]["uploads"], {"count": 2, "bytes": 500})
+        self.assertEqual(body["emailsSent"], 7)
+        self.assertEqual(body["limits"], {"transactions": 100})
+        self.assertEqual(
+            body["overLimit"], [{"metric": "transactions", "usage": 120, "limit": 100}]
+        )
+
+    def test_usage_requires_admin(self):
+        self.req.headers = {}
+        self.assertEqual(controller.handle_usage(self.req).status_code, 401)
+
+    def test_warns_admins_once_a_month(self):
+        controller.run_usage_check()
+
+        self.mock_send.assert_called_once()
+        self.assertEqual(self.mock_send.call_args[0][0], ["admin@test.com"])
+        self.assertIn("transactions: 120 of 100", self.mock_send.call_args[0][2])
+        self.assertIn("transactions", json.loads(self.settings["UsageWarnings"]))
+
+        self.mock_send.reset_mock()
+        controller.run_usage_check()
+        self.mock_send.assert_not_called()
+
+    @patch.dict(os.environ, {"USAGE_LIMIT_TRANSACTIONS": ""})
+    def test_no_limits_no_check(self):
+        controller.run_usage_check()
+        self.mock_send.assert_not_called()
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestOpsAlertController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com")
+        self.settings = {}
+        self.counts = {"requests": 0, "serverErrors": 0}
+        self.queues = [
+            {"name": "csv-processing", "poison": False, "messages": 3},
+            {"name": "csv-backfill", "poison": False, "messages": 150},
+            {"name": "csv-backfill-poison", "poison": True, "messages": 400},
+        ]
+        for q in self.queues:
+            q["oldestAgeSeconds"] = 120
+        patchers = [
+            patch.object(
+                controller.live_db_service,
+                "get_metric_counts",
+                side_effect=lambda start, end: [{"http": dict(self.counts)}],
+            ),
+            patch.object(
+                controller.queue_service,
+                "queue_status",
+                side_effect=lambda: self.queues,
+            ),
+            patch.object(
+                controller.db_service,
+                "get_job_runs",
+                return_value=[{"status": "failed"}, {"status": "succeeded"}],
+            ),
+            patch.object(
+                controller.db_service,
+                "get_settings",
+                side_effect=lambda: dict(self.settings),
+            ),
+            patch.object(
+                controller.db_service,
+                "save_setting",
+                side_effect=self.settings.__setitem__,
+            ),
+            patch.object(controller.email_service, "send_email"),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_counts, self.mock_send = mocks[0], mocks[5]
+
+    @patch("rmanalyzer.controller.shared_metrics.flush")
+    def test_alerts_once_while_firing(self, mock_flush):
+        self.counts = {"requests": 40, "serverErrors": 4}
+        controller.run_ops_alert_check()
+
+        # The rate covers every worker's requests since the last check
+        mock_flush.assert_called_once_with(force=True)
+        start, end = self.mock_counts.call_args[0]
+        elapsed = datetime.fromisoformat(end) - datetime.fromisoformat(start)
+        self.assertEqual(elapsed.total_seconds(), 15 * 60)
+
+        self.mock_send.assert_called_once()
+        self.assertEqual(self.mock_send.call_args[0][0], ["admin@test.com"])
+        body = self.mock_send.call_args[0][2]
+        self.assertIn("errorRate: 0.1 of 0.05", body)
+        self.assertIn("queueBacklog: 150 of 100", body)
+        self.assertNotIn("jobFailures", body)
+        self.assertEqual(
+            json.loads(self.settings["OpsAlertsFiring"]), ["errorRate", "queueBacklog"]
+        )
+
+        self.mock_send.reset_mock()
+        self.counts = {"requests": 80, "serverErrors": 8}
+        controller.run_ops_alert_check()
+        self.mock_send.assert_not_called()
+
+    def test_error_rate_adds_up_workers(self):
+        # Neither worker alone served enough requests for a rate
+        self.mock_counts.side_effect = lambda start, end: [
+            {"http": {"requests": 15, "serverErrors": 3}},
+            {"http": {"requests": 15, "serverErrors": 0}},
+        ]
+        self.settings["QueueBacklogAlertThreshold"] = "0"
+
+        controller.run_ops_alert_check()
+
+        self.assertIn("errorRate: 0.1 of 0.05", self.mock_send.call_args[0][2])
+
+    def test_alerts_again_after_recovering(self):
+        self.settings["OpsAlertsFiring"] = json.dumps(["queueBacklog"])
+        self.settings["QueueBacklogAlertThreshold"] = "200"
+        controller.run_ops_alert_check()
+        self.mock_send.assert_not_called()
+        self.assertEqual(json.loads(self.settings["OpsAlertsFiring"]), [])
+
+        self.settings["QueueBacklogAlertThreshold"] = "100"
+        controller.run_ops_alert_check()
+        self.mock_send.assert_called_once()
+
+    def test_alerts_on_stuck_queue(self):
+        self.queues[0]["oldestAgeSeconds"] = 45 * 60
+        self.settings["QueueBacklogAlertThreshold"] = "0"
+        controller.run_ops_alert_check()
+
+        body = self.mock_send.call_args[0][2]
+        self.assertIn("queueAgeMinutes: 45 of 30", body)
+
+    def test_queues(self):
+        resp = controller.handle_queues(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        body = json.loads(resp.get_body())
+        self.assertEqual(len(body["queues"]), 3)
+        self.assertEqual(body["queues"][2]["messages"], 400)
+        self.assertEqual(
+            body["alerts"],
+            [{"metric": "queueBacklog", "value": 150, "threshold": 100.0}],
+        )
+
+    def test_queues_requires_admin(self):
+        self.req.headers = {}
+        self.assertEqual(controller.handle_queues(self.req).status_code, 401)
+
+    def test_zero_threshold_turns_alert_off(self):
+        self.settings["QueueBacklogAlertThreshold"] = "0"
+        self.settings["JobFailureAlertThreshold"] = "1"
+        controller.run_ops_alert_check()
+
+        body = self.mock_send.call_args[0][2]
+        self.assertIn("jobFailures: 1 of 1", body)
+        self.assertNotIn("queueBacklog", body)
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestTableRoutesController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com")
+        self.setting = {
+            "routes": {"transactions": "transactionsv2"},
+            "previous": {},
+            "switchedAt": "2025-06-01T00:00:00",
+        }
+        patchers = {
+            "switchable": patch.object(
+                controller.db_service,
+                "switchable_tables",
+                return_value=["transactions", "people"],
+            ),
+            "routes": patch.object(
+                controller.db_service,
+                "table_routes",
+                side_effect=lambda: self.setting,
+            ),
+            "switch": patch.object(controller.db_service, "switch_tables"),
+            "rollback": patch.object(controller.db_service, "rollback_tables"),
+            "problem": patch.object(
+                controller.db_service, "switch_target_problem", return_value=None
+            ),
+        }
+        self.mocks = {name: p.start() for name, p in patchers.items()}
+        self.addCleanup(patch.stopall)
+
+    def test_lists_every_table(self):
+        resp = controller.handle_table_routes(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        body = json.loads(resp.get_body())
+        self.assertEqual(
+            body["tables"], {"transactions": "transactionsv2", "people": "people"}
+        )
+        self.assertEqual(body["previous"], {})
+
+    def test_switch(self):
+        self.req.get_json = MagicMock(
+            return_value={"tables": {"transactions": "transactionsv2"}}
+        )
+
+        resp = controller.handle_table_switch(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.mocks["switch"].assert_called_once_with({"transactions": "transactionsv2"})
+
+    def test_switch_checks_new_targets(self):
+        self.req.get_json = MagicMock(
+            return_value={
+                "tables": {"transactions": "transactionsv2", "people": "peoplev2"}
+            }
+        )
+        self.mocks["problem"].return_value = "does not exist"
+
+        resp = controller.handle_table_switch(self.req)
+
+        self.assertEqual(resp.status_code, 400)
+        (error,) = json.loads(resp.get_body())["fields"]
+        self.assertEqual(error["field"], "tables.people")
+        self.assertIn("does not exist", json.dumps(error))
+        # The table already switched to isn't checked again
+        self.mocks["problem"].assert_called_once_with("peoplev2")
+        self.mocks["switch"].assert_not_called()
+
+    def test_switch_rejects_unknown_and_invalid_names(self):
+        self.req.get_json = MagicMock(
+            return_value={"tables": {"settings": "settingsv2", "people": "people_v2"}}
+        )
+
+        resp = controller.handle_table_switch(self.req)
+
+        self.assertEqual(resp.status_code, 400)
+        fields = [e["field"] for e in json.loads(resp.get_body())["fields"]]
+        self.assertEqual(fields, ["tables.settings", "tables.people"])
+        self.mocks["switch"].assert_not_called()
+
+    def test_rollback_needs_a_switch(self):
+        self.mocks["rollback"].return_value = None
+
+        resp = controller.handle_table_rollback(self.req)
+
+        self.assertEqual(resp.status_code, 409)
+
+    def test_requires_admin(self):
+        self.req.headers = {}
+        self.assertEqual(controller.handle_table_switch(self.req).status_code, 401)
+        self.mocks["switch"].assert_not_called()
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestBootstrapController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com")
+
+        patchers = [
+            patch.object(
+                controller.db_service, "ensure_tables", return_value=["people"]
+            ),
+            patch.object(
+                controller.blob_service, "ensure_containers", return_value=["c"]
+            ),
+            patch.object(
+                controller.queue_service, "ensure_queues", return_value=["q"]
+            ),
+            patch.object(controller.db_service, "seed_settings", return_value=[]),
+            patch.object(controller.db_service, "has_api_keys"),
+            patch.object(controller.db_service, "save_api_key"),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_has_keys, self.mock_save_key = mocks[4], mocks[5]
+
+    def test_bootstrap_forbidden_for_non_admin(self):
+        self.req.headers = {}
+        resp = controller.handle_admin_bootstrap(self.req)
+        self.assertEqual(resp.status_code, 401)
+
+    def test_bootstrap_issues_first_key(self):
+        self.mock_has_keys.return_value = False
+
+        resp = controller.handle_admin_bootstrap(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertTrue(payload["apiKey"].startswith("rmk_"))
+        self.assertEqual(payload["queues"], ["q"])
+        key_hash, owner, _ = self.mock_save_key.call_args[0]
+        self.assertNotIn(payload["apiKey"], key_hash)
+        self.assertEqual(owner, "admin@test.com")
+
+    def test_bootstrap_is_idempotent(self):
+        self.mock_has_keys.return_value = True
+
+        resp = controller.handle_admin_bootstrap(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertIsNone(json.loads(resp.get_body())["apiKey"])
+        self.mock_save_key.assert_not_called()
+
+    @patch.object(controller.db_service, "get_api_key_owner")
+    def test_api_key_authenticates(self, mock_owner):
+        mock_owner.return_value = "admin@test.com"
+        self.mock_has_keys.return_value = True
+        self.req.headers = {"x-api-key": "rmk_secret"}
+
+        resp = controller.handle_admin_bootstrap(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertNotEqual(mock_owner.call_args[0][0], "rmk_secret")
+
+
+@patch.dict(
+    os.environ, {"ADMIN_EMAILS": "admin@test.com", "SANDBOX_ENABLED": "true"}
+)
+class TestSandboxController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com")
+        db = controller.sandbox_db_service
+        self.mocks = {
+            name: patch.object(db, name).start()
+            for name in (
+                "ensure_tables",
+                "list_household_keys",
+                "delete_entities",
+                "save_person",
+                "save_transactions",
+                "save_budget",
+                "upsert_accounts",
+                "save_savings",
+                "seed_settings",
+                "save_setting",
+            )
+        }
+        self.mocks["list_household_keys"].return_value = {
+            "sandboxpeople": [{"PartitionKey": "PEOPLE", "RowKey": "old@test.com"}]
+        }
+        self.addCleanup(patch.stopall)
+        patch.object(
+            controller.live_db_service, "get_all_people", return_value=[]
+        ).start()
+
+    def test_sandbox_tables_are_prefixed(self):
+        self.assertEqual(
+            controller.sandbox_db_service.transactions_table,
+            f"sandbox{controller.live_db_service.transactions_table}",
+        )
+        self.assertIs(controller.db_service, controller.live_db_service)
+        with sandbox.use(sandbox.SANDBOX):
+            self.assertIs(controller.db_service, controller.sandbox_db_service)
+
+    def test_seed_replaces_sandbox_data(self):
+        resp = controller.handle_sandbox_seed(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["people"][0], "admin@test.com")
+        self.assertEqual(len(payload["months"]), 7)
+        self.mocks["delete_entities"].assert_called_once_with(
+            "sandboxpeople", [{"PartitionKey": "PEOPLE", "RowKey": "old@test.com"}]
+        )
+        self.assertEqual(self.mocks["save_person"].call_count, 2)
+        self.mocks["upsert_accounts"].assert_called_once()
+
+    def test_seed_needs_sandbox_enabled(self):
+        with patch.dict(os.environ, {"SANDBOX_ENABLED": "false"}):
+            resp = controller.handle_sandbox_seed(self.req)
+        self.assertEqual(resp.status_code, 404)
+        self.mocks["save_transactions"].assert_not_called()
+
+    def test_seed_replaces_rather_than_refuses_data(self):
+        patch.object(
+            controller.sandbox_db_service, "get_all_people", return_value=[{}]
+        ).start()
+
+        self.assertEqual(controller.handle_sandbox_seed(self.req).status_code, 200)
+        self.mocks["save_transactions"].assert_called_once()
+
+    def test_seed_requires_admin(self):
+        with patch.dict(os.environ, {"ADMIN_EMAILS": "other@test.com"}):
+            resp = controller.handle_sandbox_seed(self.req)
+        self.assertEqual(resp.status_code, 403)
+        self.mocks["delete_entities"].assert_not_called()
+        self.mocks["save_person"].assert_not_called()
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestDemoSeedController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com")
+        db = controller.live_db_service
+        self.mocks = {
+            name: patch.object(db, name).start()
+            for name in (
+                "ensure_tables",
+                "get_all_people",
+                "get_transactions",
+                "save_person",
+                "save_transactions",
+                "save_budget",
+                "upsert_accounts",
+                "save_savings",
+                "seed_settings",
+                "save_setting",
+            )
+        }
+        self.mocks["get_all_people"].return_value = []
+        self.mocks["get_transactions"].return_value = []
+        self.addCleanup(patch.stopall)
+
+    def test_seeds_empty_household(self):
+        resp = controller.handle_demo_seed(self.req)
+
+        self.assertEqual(resp.status_code, 201)
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["people"][0], "admin@test.com")
+        self.assertGreater(payload["transactions"], 0)
+        self.mocks["save_transactions"].assert_called_once()
+        self.mocks["upsert_accounts"].assert_called_once()
+        self.assertEqual(self.mocks["save_savings"].call_args[0][2], "admin@test.com")
+
+    def test_refuses_household_with_data(self):
+        self.mocks["get_transactions"].return_value = [MagicMock()]
+
+        resp = controller.handle_demo_seed(self.req)
+
+        self.assertEqual(resp.status_code, 409)
+        self.mocks["save_transactions"].assert_not_called()
+
+        self.mocks["get_all_people"].return_value = [{"Email": "a@test.com"}]
+        self.mocks["get_transactions"].return_value = []
+        self.assertEqual(controller.handle_demo_seed(self.req).status_code, 409)
+
+    def test_requires_admin(self):
+        with patch.dict(os.environ, {"ADMIN_EMAILS": "other@test.com"}):
+            resp = controller.handle_demo_seed(self.req)
+        self.assertEqual(resp.status_code, 403)
+        self.mocks["save_person"].assert_not_called()
+
+    def test_seeds_live_household_whatever_the_request_household(self):
+        with sandbox.use(sandbox.SANDBOX):
+            resp = controller.handle_demo_seed(self.req)
+
+        self.assertEqual(resp.status_code, 201)
+        self.mocks["save_transactions"].assert_called_once()
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestSettingsController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com", method="GET")
+
+        self.settings = {"DebtExcludedCategories": "[]"}
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_settings",
+                side_effect=lambda: dict(self.settings),
+            ),
+            patch.object(
+                controller.db_service,
+                "save_setting",
+                side_effect=self.settings.__setitem__,
+            ),
+        ]
+        start_patches(self, patchers)
+
+    def test_get_settings_for_any_user(self):
+        sign_in(self.req, "user@test.com")
+        resp = controller.handle_settings(self.req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            json.loads(resp.get_body()),
+            {
+                "debtExcludedCategories": [],
+                "roundUpIncrements": {},
+                "cpiIndex": {},
+                "emergencyFund": 0.0,
+                "emergencyFundAlertMonths": 3.0,
+                "missingBillGraceDays": 3.0,
+                "priceIncreaseThreshold": 0.1,
+                "promoExpiryWarningDays": 30.0,
+                "paymentReminderDays": 5.0,
+                "utilizationAlertThreshold": 0.3,
+                "savingsSuggestionShare": 0.5,
+                "errorRateAlertThreshold": 0.05,
+                "queueBacklogAlertThreshold": 100.0,
+                "queueAgeAlertMinutes": 30.0,
+                "jobFailureAlertThreshold": 2.0,
+                "paymentReminderLeadDays": [5],
+                "summaryAttachments": False,
+            },
+        )
+
+    def test_update_requires_admin(self):
+        sign_in(self.req, "user@test.com")
+        self.req.method = "PUT"
+        resp = controller.handle_settings(self.req)
+        self.assertEqual(resp.status_code, 403)
+
+    def test_update_debt_exclusions(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(
+            return_value={"debtExcludedCategories": ["Pets", "Pets"]}
+        )
+
+        resp = controller.handle_settings(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["debtExcludedCategories"], ["Pets"])
+
+    def test_update_round_up_increments(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(
+            return_value={"roundUpIncrements": {"A@test.com": 1, "b@test.com": "5"}}
+        )
+
+        resp = controller.handle_settings(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            json.loads(resp.get_body())["roundUpIncrements"],
+            {"a@test.com": 1.0, "b@test.com": 5.0},
+        )
+
+    def test_update_rejects_negative_increment(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(
+            return_value={"roundUpIncrements": {"a@test.com": -1}}
+        )
+        resp = controller.handle_settings(self.req)
+        self.assertEqual(resp.status_code, 400)
+        field = json.loads(resp.get_body())["fields"][0]["field"]
+        self.assertEqual(field, "roundUpIncrements.a@test.com")
+
+    def test_update_cpi_index(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(return_value={"cpiIndex": {"2025": 320.5}})
+
+        resp = controller.handle_settings(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(json.loads(resp.get_body())["cpiIndex"], {"2025": 320.5})
+
+    def test_update_rejects_invalid_cpi_year(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(return_value={"cpiIndex": {"latest": 320.5}})
+        resp = controller.handle_settings(self.req)
+        self.assertEqual(resp.status_code, 400)
+        field = json.loads(resp.get_body())["fields"][0]["field"]
+        self.assertEqual(field, "cpiIndex.latest")
+
+    def test_update_reminder_lead_days(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(
+            return_value={"paymentReminderLeadDays": [1, 7, 3, 7]}
+        )
+
+        resp = controller.handle_settings(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            json.loads(resp.get_body())["paymentReminderLeadDays"], [7, 3, 1]
+        )
+
+    def test_update_rejects_too_many_lead_days(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(
+            return_value={"paymentReminderLeadDays": [1, 2, 3, 4, 5, 6]}
+        )
+        resp = controller.handle_settings(self.req)
+        self.assertEqual(resp.status_code, 400)
+        field = json.loads(resp.get_body())["fields"][0]["field"]
+        self.assertEqual(field, "paymentReminderLeadDays")
+
+    def test_update_rejects_unknown_category(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(return_value={"debtExcludedCategories": ["Loans"]})
+        resp = controller.handle_settings(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestCategoriesController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com", method="GET")
+
+        self.settings = {}
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_settings",
+                side_effect=lambda: dict(self.settings),
+            ),
+            patch.object(
+                controller.db_service,
+                "save_setting",
+                side_effect=self.settings.__setitem__,
+            ),
+        ]
+
+        self.categories = {}
+        self.rules = []
+        self.views = []
+        patchers += [
+            patch.object(
+                controller.db_service,
+                "get_categories",
+                side_effect=lambda: list(self.categories.values()),
+            ),
+            patch.object(
+                controller.db_service,
+                "save_category",
+                side_effect=lambda c: self.categories.__setitem__(c["name"], c),
+            ),
+            patch.object(
+                controller.db_service,
+                "delete_category",
+                side_effect=lambda n: self.categories.pop(n, None) is not None,
+            ),
+            patch.object(
+                controller.db_service,
+                "category_names",
+                side_effect=lambda: list(self.categories),
+            ),
+            patch.object(
+                controller.db_service, "get_rules", side_effect=lambda: self.rules
+            ),
+            patch.object(
+                controller.db_service, "get_views", side_effect=lambda: self.views
+            ),
+            patch.object(controller.db_service, "get_budgets", return_value={}),
+            patch.object(controller.db_service, "get_all_people", return_value=[]),
+        ]
+        start_patches(self, patchers)
+
+    def _essential(self, resp):
+        categories = json.loads(resp.get_body())["categories"]
+        return [c["name"] for c in categories if c["essential"]]
+
+    def test_default_essential_categories(self):
+        resp = controller.handle_categories(self.req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(self._essential(resp), ["Groceries", "Bills & Utilities"])
+
+    def test_set_essential(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(
+            return_value={"category": "Pets", "essential": True}
+        )
+        resp = controller.handle_categories(self.req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            self._essential(resp), ["Groceries", "Pets", "Bills & Utilities"]
+        )
+
+        self.req.get_json = MagicMock(
+            return_value={"category": "Groceries", "essential": "false"}
+        )
+        resp = controller.handle_categories(self.req)
+        self.assertEqual(self._essential(resp), ["Pets", "Bills & Utilities"])
+
+    def test_set_rejects_unknown_category(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(
+            return_value={"category": "Loans", "essential": True}
+        )
+        resp = controller.handle_categories(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    def _add(self, body):
+        self.req.method = "POST"
+        self.req.get_json = MagicMock(return_value=body)
+        return controller.handle_categories(self.req)
+
+    def test_add_category(self):
+        resp = self._add({"name": "Kids", "color": "#3366ff", "essential": True})
+        self.assertEqual(resp.status_code, 201)
+        categories = json.loads(resp.get_body())["categories"]
+        self.assertEqual(
+            categories[-1],
+            {
+                "name": "Kids",
+                "builtIn": False,
+                "essential": True,
+                "color": "#3366ff",
+                "budgetEligible": True,
+            },
+        )
+        self.assertTrue(categories[0]["builtIn"])
+        self.assertTrue(
+            Category.is_valid("Kids", controller.db_service.category_names())
+        )
+
+        # Names are unique, ignoring case
+        self.assertEqual(self._add({"name": "Kids"}).status_code, 409)
+        self.assertEqual(self._add({"name": "Groceries"}).status_code, 409)
+        resp = self._add({"name": "kids"})
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(json.loads(resp.get_body())["fields"][0]["field"], "name")
+
+    def test_add_rejects_bad_names(self):
+        for name in ["a/b", "what?", " Kids", "x" * 51]:
+            with self.subTest(name=name):
+                self.assertEqual(self._add({"name": name}).status_code, 400)
+        self.assertEqual(
+            self._add({"name": "Kids", "color": "blue"}).status_code, 400
+        )
+
+    def test_add_requires_admin(self):
+        sign_in(self.req, "member@test.com")
+        self.assertEqual(self._add({"name": "Kids"}).status_code, 403)
+        self.assertEqual(self.categories, {})
+
+    def test_update_keeps_unset_fields(self):
+        self._add({"name": "Kids", "color": "#3366ff"})
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(
+            return_value={"category": "Kids", "budgetEligible": False}
+        )
+        resp = controller.handle_categories(self.req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            self.categories["Kids"],
+            {"name": "Kids", "color": "#3366ff", "budgetEligible": False},
+        )
+
+        # Built-in categories can have a color too
+        self.req.get_json = MagicMock(
+            return_value={"category": "Pets", "color": "#00aa00"}
+        )
+        resp = controller.handle_categories(self.req)
+        pets = json.loads(resp.get_body())["categories"]
+        self.assertEqual(
+            [c["color"] for c in pets if c["name"] == "Pets"], ["#00aa00"]
+        )
+
+    def test_delete_category(self):
+        self._add({"name": "Kids"})
+        self.req.method = "DELETE"
+        self.req.params = {"name": "Kids"}
+        resp = controller.handle_categories(self.req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(self.categories, {})
+        self.assertFalse(
+            Category.is_valid("Kids", controller.db_service.category_names())
+        )
+
+        # Deleted, so it's no longer a known name
+        resp = controller.handle_categories(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    def test_delete_rejects_built_in_and_used(self):
+        self.req.method = "DELETE"
+        self.req.params = {"name": "Groceries"}
+        self.assertEqual(controller.handle_categories(self.req).status_code, 400)
+
+        self._add({"name": "Kids"})
+        self.rules = [MagicMock(category=AddedCategory("Kids"))]
+        self.req.method = "DELETE"
+        self.req.params = {"name": "Kids"}
+        resp = controller.handle_categories(self.req)
+        self.assertEqual(resp.status_code, 409)
+        self.assertIn("rules", resp.get_body().decode())
+        self.assertIn("Kids", self.categories)
+
+    def test_delete_rejects_filters_and_aliases_that_use_it(self):
+        self._add({"name": "Kids"})
+        self.req.method = "DELETE"
+        self.req.params = {"name": "Kids"}
+        rule = Rule("r1", Category.OTHER, filter_expression="category:kids")
+        cases = [
+            ("rules", "rules", [rule]),
+            ("views", "saved views", [{"id": "v1", "filter": "category!=Kids"}]),
+        ]
+        for attribute, used_by, value in cases:
+            with self.subTest(used_by), patch.object(self, attribute, value):
+                resp = controller.handle_categories(self.req)
+                self.assertEqual(resp.status_code, 409)
+                self.assertIn(used_by, resp.get_body().decode())
+
+        with patch.dict(os.environ, {"CATEGORY_ALIASES": '{"Toys": "Kids"}'}):
+            resp = controller.handle_categories(self.req)
+        self.assertEqual(resp.status_code, 409)
+        self.assertIn("category aliases", resp.get_body().decode())
+
+        # A filter that only searches for the name doesn't hold it
+        self.views = [{"id": "v1", "filter": "category~kids"}]
+        self.assertEqual(controller.handle_categories(self.req).status_code, 200)
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestBudgetsController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com", method="GET")
+
+        self.budgets = {}
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_budgets",
+                side_effect=lambda: dict(self.budgets),
+            ),
+            patch.object(
+                controller.db_service,
+                "save_budget",
+                side_effect=self.budgets.__setitem__,
+            ),
+            patch.object(
+                controller.db_service,
+                "delete_budget",
+                side_effect=lambda c: self.budgets.pop(c, None) is not None,
+            ),
+        ]
+        start_patches(self, patchers)
+
+    def test_set_and_delete_budget(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(
+            return_value={"category": "Groceries", "limit": "600"}
+        )
+        resp = controller.handle_budgets(self.req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            json.loads(resp.get_body())["budgets"],
+            [{"category": "Groceries", "limit": 600.0}],
+        )
+
+        self.req.method = "DELETE"
+        self.req.params = {"category": "Groceries"}
+        resp = controller.handle_budgets(self.req)
+        self.assertEqual(json.loads(resp.get_body())["budgets"], [])
+
+        resp = controller.handle_budgets(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+    def test_update_requires_admin(self):
+        sign_in(self.req, "user@test.com")
+        self.req.method = "PUT"
+        resp = controller.handle_budgets(self.req)
+        self.assertEqual(resp.status_code, 403)
+
+    def test_set_rejects_negative_limit(self):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(
+            return_value={"category": "Groceries", "limit": -1}
+        )
+        resp = controller.handle_budgets(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    @patch.object(controller.db_service, "get_categories")
+    def test_set_rejects_ineligible_category(self, mock_categories):
+        mock_categories.return_value = [
+            {"name": "Pets", "color": None, "budgetEligible": False}
+        ]
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(return_value={"category": "Pets", "limit": 80})
+        resp = controller.handle_budgets(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(self.budgets, {})
+
+    @patch.object(controller.db_service, "get_spending_totals")
+    def test_status(self, mock_totals):
+        sign_in(self.req, "user@test.com")
+        self.req.params = {"month": "2025-01"}
+        self.budgets = {
+            Category.GROCERIES: Decimal("500.00"),
+            Category.DINING: Decimal("100.00"),
+        }
+        mock_totals.return_value = [
+            {"Category": "Groceries", "Total": Decimal("300.00")},
+            {"Category": "Groceries", "Total": Decimal("100.00")},
+            {"Category": "Dining & Drinks", "Total": Decimal("125.00")},
+            {"Category": "Pets", "Total": Decimal("40.00")},
+        ]
+
+        resp = controller.handle_budget_status(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        body = json.loads(resp.get_body())
+        mock_totals.assert_called_once_with("2025-01")
+        dining, groceries = body["budgets"]
+        self.assertEqual(groceries["remaining"], 100.0)
+        self.assertEqual(groceries["percentUsed"], 80.0)
+        self.assertTrue(dining["overBudget"])
+        self.assertEqual(
+            body["total"], {"limit": 600.0, "spent": 525.0, "remaining": 75.0}
+        )
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestPeopleController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com", route_params={}, method="GET")
+
+        self.people = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+            {"Name": "B", "Email": "b@test.com", "Accounts": [2]},
+        ]
+        patcher = patch.object(
+            controller.db_service, "get_all_people", return_value=self.people
+        )
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def test_list_people(self):
+        sign_in(self.req, "user@test.com")
+        resp = controller.handle_people(self.req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            json.loads(resp.get_body())["people"][0],
+            {
+                "name": "A",
+                "email": "a@test.com",
+                "accounts": [1],
+                "weights": {},
+                "splitShare": None,
+                "categoryShares": {},
+            },
+        )
+
+    @patch.object(controller.db_service, "save_person")
+    def test_add_person(self, mock_save):
+        self.req.method = "POST"
+        self.req.get_json = MagicMock(
+            return_value={"name": "C", "email": "c@test.com", "accounts": [3]}
+        )
+
+        resp = controller.handle_people(self.req)
+
+        self.assertEqual(resp.status_code, 201)
+        mock_save.assert_called_once_with(
+            {
+                "Name": "C",
+                "Email": "c@test.com",
+                "Accounts": [3],
+                "AccountWeights": {},
+                "SplitShare": None,
+                "CategoryShares": {},
+            }
+        )
+
+    @patch.object(controller.db_service, "save_person")
+    def test_update_person_keeps_accounts(self, mock_save):
+        self.req.method = "POST"
+        self.req.get_json = MagicMock(
+            return_value={"name": "Al", "email": "A@test.com"}
+        )
+
+        resp = controller.handle_people(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        mock_save.assert_called_once_with(
+            {
+                "Name": "Al",
+                "Email": "a@test.com",
+                "Accounts": [1],
+                "AccountWeights": {},
+                "SplitShare": None,
+                "CategoryShares": {},
+            }
+        )
+
+    @patch.object(controller.db_service, "save_person")
+    def test_set_split_shares(self, mock_save):
+        self.people[0]["CategoryShares"] = {"Groceries": 0.5}
+        self.req.method = "POST"
+        self.req.get_json = MagicMock(
+            return_value={
+                "name": "A",
+                "email": "a@test.com",
+                "splitShare": 0.6,
+                "categoryShares": {"Bills & Utilities": 0.7},
+            }
+        )
+
+        resp = controller.handle_people(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        saved = mock_save.call_args[0][0]
+        self.assertEqual(saved["SplitShare"], 0.6)
+        self.assertEqual(saved["CategoryShares"], {"Bills & Utilities": 0.7})
+        self.assertEqual(json.loads(resp.get_body())["splitShare"], 0.6)
+
+    @patch.object(controller.db_service, "save_person")
+    def test_split_shares_carry_over_and_clear(self, mock_save):
+        self.people[0].update(SplitShare=0.6, CategoryShares={"Groceries": 0.5})
+        self.req.method = "POST"
+        self.req.get_json = MagicMock(
+            return_value={"name": "A", "email": "a@test.com", "splitShare": None}
+        )
+
+        controller.handle_people(self.req)
+
+        saved = mock_save.call_args[0][0]
+        self.assertIsNone(saved["SplitShare"])
+        self.assertEqual(saved["CategoryShares"], {"Groceries": 0.5})
+
+    @patch.object(controller.db_service, "save_person")
+    def test_split_shares_must_complement(self, mock_save):
+        self.people[1].update(SplitShare=0.5, CategoryShares={"Groceries": 0.3})
+        self.req.method = "POST"
+        self.req.get_json = MagicMock(
+            return_value={
+                "name": "A",
+                "email": "a@test.com",
+                "splitShare": 0.6,
+                "categoryShares": {"Groceries": 0.7, "Pets": 0.2},
+            }
+        )
+
+        resp = controller.handle_people(self.req)
+
+        self.assertEqual(resp.status_code, 400)
+        errors = json.loads(resp.get_body())["fields"]
+        self.assertEqual([e["field"] for e in errors], ["splitShare"])
+        mock_save.assert_not_called()
+
+    def test_invalid_category_share(self):
+        self.req.method = "POST"
+        self.req.get_json = MagicMock(
+            return_value={
+                "name": "A",
+                "email": "a@test.com",
+                "categoryShares": {"Rent": 0.6, "Groceries": 2},
+            }
+        )
+        resp = controller.handle_people(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(len(json.loads(resp.get_body())["fields"]), 2)
+
+    @patch.object(controller.db_service, "save_person")
+    def test_add_person_with_owned_account(self, mock_save):
+        self.req.method = "POST"
+        self.req.get_json = MagicMock(
+            return_value={"name": "C", "email": "c@test.com", "accounts": [2]}
+        )
+        resp = controller.handle_people(self.req)
+        self.assertEqual(resp.status_code, 409)
+        mock_save.assert_not_called()
+
+    def test_add_person_requires_admin(self):
+        sign_in(self.req, "user@test.com")
+        self.req.method = "POST"
+        resp = controller.handle_people(self.req)
+        self.assertEqual(resp.status_code, 403)
+
+    @patch.object(controller.db_service, "delete_person", return_value=True)
+    def test_delete_person(self, mock_delete):
+        self.req.method = "DELETE"
+        self.req.route_params = {"id": "B@test.com"}
+        resp = controller.handle_people(self.req)
+        self.assertEqual(resp.status_code, 204)
+        mock_delete.assert_called_once_with("b@test.com")
+
+    def test_delete_unknown_person(self):
+        self.req.method = "DELETE"
+        self.req.route_params = {"id": "x@test.com"}
+        resp = controller.handle_people(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+    @patch.object(controller.db_service, "add_person_account", return_value=[1, 9])
+    def test_add_account(self, mock_add):
+        self.req.method = "POST"
+        self.req.route_params = {"id": "a@test.com"}
+        self.req.get_json = MagicMock(return_value={"accountNumber": 9})
+
+        resp = controller.handle_person_accounts(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        mock_add.assert_called_once_with("a@test.com", 9, None)
+        self.assertEqual(json.loads(resp.get_body())["accounts"], [1, 9])
+
+    @patch.object(controller.db_service, "add_person_account", return_value=[1, 2])
+    def test_share_joint_account(self, mock_add):
+        self.people[1]["AccountWeights"] = {"2": 0.4}
+        self.req.method = "POST"
+        self.req.route_params = {"id": "a@test.com"}
+        self.req.get_json = MagicMock(return_value={"accountNumber": 2, "weight": 0.6})
+
+        resp = controller.handle_person_accounts(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        mock_add.assert_called_once_with("a@test.com", 2, 0.6)
+        self.assertEqual(json.loads(resp.get_body())["weights"], {"2": 0.6})
+
+    def test_share_requires_weight_on_every_holder(self):
+        self.req.method = "POST"
+        self.req.route_params = {"id": "a@test.com"}
+        self.req.get_json = MagicMock(return_value={"accountNumber": 2, "weight": 0.6})
+        resp = controller.handle_person_accounts(self.req)
+        self.assertEqual(resp.status_code, 409)
+
+    def test_weight_out_of_range(self):
+        self.req.method = "POST"
+        self.req.route_params = {"id": "a@test.com"}
+        self.req.get_json = MagicMock(return_value={"accountNumber": 9, "weight": 1.5})
+        resp = controller.handle_person_accounts(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    def test_add_account_owned_by_someone_else(self):
+        self.req.method = "POST"
+        self.req.route_params = {"id": "a@test.com"}
+        self.req.get_json = MagicMock(return_value={"accountNumber": 2})
+        resp = controller.handle_person_accounts(self.req)
+        self.assertEqual(resp.status_code, 409)
+
+    @patch.object(controller.db_service, "remove_person_account", return_value=[])
+    def test_remove_account(self, mock_remove):
+        self.req.method = "DELETE"
+        self.req.route_params = {"id": "a@test.com", "account": "1"}
+
+        resp = controller.handle_person_accounts(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        mock_remove.assert_called_once_with("a@test.com", 1)
+
+    def test_remove_unknown_account(self):
+        self.req.method = "DELETE"
+        self.req.route_params = {"id": "a@test.com", "account": "2"}
+        resp = controller.handle_person_accounts(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+    @patch.object(controller.db_service, "remove_person_account")
+    @patch.object(controller.db_service, "add_person_account")
+    def test_change_accounts_requires_admin(self, mock_add, mock_remove):
+        sign_in(self.req, "a@test.com")
+        self.req.route_params = {"id": "b@test.com", "account": "2"}
+        self.req.get_json = MagicMock(return_value={"accountNumber": 9})
+        for method in ("POST", "DELETE"):
+            self.req.method = method
+            resp = controller.handle_person_accounts(self.req)
+            self.assertEqual(resp.status_code, 403, method)
+        mock_add.assert_not_called()
+        mock_remove.assert_not_called()
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestInvitesController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("admin@test.com", method="POST")
+
+        self.invites = {}
+        self.people = [{"Name": "A", "Email": "a@test.com", "Accounts": [1]}]
+        patchers = [
+            patch.object(
+                controller.db_service, "get_all_people", return_value=self.people
+            ),
+            patch.object(
+                controller.db_service,
+                "save_invite",
+                side_effect=lambda h, email, name, by, expires: self.invites.update(
+                    {
+                        h: {
+                            "email": email,
+                            "name": name,
+                            "invitedBy": by,
+                            "expiresAt": expires,
+                            "acceptedBy": None,
+                        }
+                    }
+                ),
+            ),
+            patch.object(
+                controller.db_service, "get_invite", side_effect=self.invites.get
+            ),
+            patch.object(
+                controller.db_service,
+                "mark_invite_accepted",
+                side_effect=lambda h, by: not self.invites[h].update(acceptedBy=by),
+            ),
+            patch.object(controller.db_service, "save_person"),
+            patch.object(controller.email_service, "send_email"),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_accepted = mocks[3]
+        self.mock_save_person, self.mock_send = mocks[4], mocks[5]
+
+    def _invite(self):
+        self.req.get_json = MagicMock(
+            return_value={"name": "C", "email": "c@example.com"}
+        )
+        resp = controller.handle_invites(self.req)
+        body = self.mock_send.call_args[0][2]
+        token = body.split("token=")[1].split('"')[0]
+        return resp, token
+
+    def test_invite_and_accept(self):
+        resp, token = self._invite()
+
+        self.assertEqual(resp.status_code, 201)
+        self.assertEqual(self.mock_send.call_args[0][0], ["c@example.com"])
+        # Only the hash is stored
+        self.assertNotIn(token, self.invites)
+        self.assertIn("/invite.html?token=", self.mock_send.call_args[0][2])
+
+        sign_in(self.req, "c.signin@example.com")
+        self.req.get_json = MagicMock(return_value={"token": token})
+        resp = controller.handle_invite_accept(self.req)
+
+        self.assertEqual(resp.status_code, 201)
+        self.mock_save_person.assert_called_once_with(
+            {"Name": "C", "Email": "c.signin@example.com", "Accounts": []}
+        )
+
+        resp = controller.handle_invite_accept(self.req)
+        self.assertEqual(resp.status_code, 410)
+
+    def test_invite_requires_admin(self):
+        sign_in(self.req, "a@test.com")
+        resp = controller.handle_invites(self.req)
+        self.assertEqual(resp.status_code, 403)
+
+    def test_invite_existing_member(self):
+        self.req.get_json = MagicMock(return_value={"name": "A", "email": "A@test.com"})
+        resp = controller.handle_invites(self.req)
+        self.assertEqual(resp.status_code, 409)
+        self.mock_send.assert_not_called()
+
+    def test_accept_unknown_token(self):
+        sign_in(self.req, "c@example.com")
+        self.req.get_json = MagicMock(return_value={"token": "rmi_nope"})
+        resp = controller.handle_invite_accept(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+    def test_accept_expired_invite(self):
+        _, token = self._invite()
+        for invite in self.invites.values():
+            invite["expiresAt"] = "2000-01-01T00:00:00"
+
+        sign_in(self.req, "c@example.com")
+        self.req.get_json = MagicMock(return_value={"token": token})
+        resp = controller.handle_invite_accept(self.req)
+
+        self.assertEqual(resp.status_code, 410)
+        self.mock_save_person.assert_not_called()
+
+    def test_accept_loses_race_to_another_accept(self):
+        _, token = self._invite()
+        # Another accept used the invite after this one read it
+        self.mock_accepted.side_effect = None
+        self.mock_accepted.return_value = False
+
+        sign_in(self.req, "c@example.com")
+        self.req.get_json = MagicMock(return_value={"token": token})
+        resp = controller.handle_invite_accept(self.req)
+
+        self.assertEqual(resp.status_code, 410)
+        self.mock_save_person.assert_not_called()
+
+
+class TestSharesController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", route_params={}, method="POST")
+
+        self.shares = {}
+        people = [{"Name": "A", "Email": "a@test.com", "Accounts": [4111222233334444]}]
+        totals = [
+            {
+                "Category": "Groceries",
+                "AccountNumber": 4111222233334444,
+                "Owner": "",
+                "Total": Decimal("42.50"),
+                "Count": 2,
+            }
+        ]
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "save_share",
+                side_effect=lambda h, share: self.shares.update(
+                    {
+                        h: {
+                            **share,
+                            "id": h,
+                            "createdAt": "2025-03-01T00:00:00",
+                            "revokedAt": None,
+                        }
+                    }
+                ),
+            ),
+            patch.object(
+                controller.db_service, "get_share", side_effect=self.shares.get
+            ),
+            patch.object(
+                controller.db_service,
+                "revoke_share",
+                side_effect=lambda h: self.shares[h].update(
+                    revokedAt="2025-03-02T00:00:00"
+                ),
+            ),
+            patch.object(controller.db_service, "get_all_people", return_value=people),
+            patch.object(
+                controller.db_service, "get_spending_totals", return_value=totals
+            ),
+        ]
+        start_patches(self, patchers)
+
+    def _share(self, body):
+        self.req.get_json = MagicMock(return_value=body)
+        return controller.handle_shares(self.req)
+
+    def _open(self, token):
+        self.req.headers = {"x-share-token": token}
+        return controller.handle_shared_report(self.req)
+
+    def test_share_and_open_without_signing_in(self):
+        resp = self._share({"report": "summary", "month": "2025-03"})
+
+        self.assertEqual(resp.status_code, 201)
+        created = json.loads(resp.get_body())
+        token = created["url"].split("token=")[1]
+        self.assertTrue(token.startswith("rms_"))
+        # Only the hash is stored
+        self.assertNotIn(token, self.shares)
+        self.assertEqual(created["params"], {"month": "2025-03"})
+
+        resp = self._open(token)
+
+        self.assertEqual(resp.status_code, 200)
+        shared = json.loads(resp.get_body())
+        self.assertEqual(shared["report"], "summary")
+        self.assertEqual(shared["data"]["month"], "2025-03")
+        self.assertEqual(shared["data"]["total"], 42.5)
+        # Members' emails aren't shared
+        self.assertEqual(shared["data"]["people"], [{"name": "A", "total": 42.5}])
+        # Nor are full account numbers
+        self.assertEqual(
+            shared["data"]["accounts"], [{"mask": "4444", "total": 42.5, "count": 2}]
+        )
+
+    def test_share_trends(self):
+        resp = self._share({"report": "trends", "from": "2025-01", "to": "2025-03"})
+        token = json.loads(resp.get_body())["url"].split("token=")[1]
+
+        shared = json.loads(self._open(token).get_body())
+
+        self.assertEqual(len(shared["data"]["months"]), 3)
+        self.assertFalse(shared["data"]["real"])
+
+    def test_trends_need_a_valid_range(self):
+        resp = self._share({"report": "trends", "from": "2025-01"})
+        self.assertEqual(resp.status_code, 400)
+        self.assertIn("to", resp.get_body().decode())
+
+        resp = self._share({"report": "trends", "from": "2025-03", "to": "2025-01"})
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(self.shares, {})
+
+    def test_unsupported_report_and_expiry(self):
+        self.assertEqual(self._share({"report": "ledger"}).status_code, 400)
+        resp = self._share({"report": "summary", "expiresInDays": 365})
+        self.assertEqual(resp.status_code, 400)
+
+    def test_revoked_and_expired_links_are_gone(self):
+        resp = self._share({"report": "summary", "month": "2025-03"})
+        created = json.loads(resp.get_body())
+        token = created["url"].split("token=")[1]
+
+        sign_in(self.req, "b@test.com")
+        self.req.route_params = {"id": created["id"]}
+        self.assertEqual(controller.handle_share(self.req).status_code, 404)
+
+        sign_in(self.req, "a@test.com")
+        self.assertEqual(controller.handle_share(self.req).status_code, 204)
+        self.assertEqual(self._open(token).status_code, 410)
+
+        self.shares[created["id"]].update(
+            revokedAt=None, expiresAt="2000-01-01T00:00:00"
+        )
+        self.assertEqual(self._open(token).status_code, 410)
+
+    def test_unknown_token(self):
+        self.assertEqual(self._open("rms_nope").status_code, 404)
+        self.assertEqual(self._open("").status_code, 404)
+
+    @patch.object(controller.db_service, "get_shares", return_value=[])
+    def test_list_own_shares(self, mock_get):
+        self.req.method = "GET"
+        resp = controller.handle_shares(self.req)
+        self.assertEqual(json.loads(resp.get_body()), {"shares": []})
+        mock_get.assert_called_once_with("a@test.com")
+
+    def test_share_requires_sign_in(self):
+        self.req.headers = {}
+        self.assertEqual(controller.handle_shares(self.req).status_code, 401)
+
+
+class TestViewsController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", route_params={}, method="POST")
+
+        self.views = {}
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "save_view",
+                side_effect=lambda view, owner: self.views.update(
+                    {view["id"]: (owner, view)}
+                ),
+            ),
+            patch.object(
+                controller.db_service,
+                "get_views",
+                side_effect=lambda owner: [
+                    v for o, v in self.views.values() if o == owner
+                ],
+            ),
+            patch.object(
+                controller.db_service,
+                "delete_view",
+                side_effect=self.views.pop,
+            ),
+        ]
+        start_patches(self, patchers)
+
+    def _save(self, body):
+        self.req.get_json = MagicMock(return_value=body)
+        return controller.handle_views(self.req)
+
+    def test_save_and_list(self):
+        resp = self._save(
+            {"name": "Large purchases", "filter": "amount>100", "sort": "largest"}
+        )
+
+        self.assertEqual(resp.status_code, 201)
+        view = json.loads(resp.get_body())
+        self.assertEqual(view["name"], "Large purchases")
+        self.assertEqual(view["filter"], "amount>100")
+
+        self.req.method = "GET"
+        resp = controller.handle_views(self.req)
+        self.assertEqual(json.loads(resp.get_body())["views"], [view])
+
+        # Views are per member
+        sign_in(self.req, "b@test.com")
+        resp = controller.handle_views(self.req)
+        self.assertEqual(json.loads(resp.get_body())["views"], [])
+
+    def test_invalid_view(self):
+        for body, field in [
+            ({"filter": "amount>100"}, "name"),
+            ({"name": "  "}, "name"),
+            ({"name": "Big", "filter": "amount>lots"}, "filter"),
+            ({"name": "Big", "sort": "random"}, "sort"),
+        ]:
+            resp = self._save(body)
+            self.assertEqual(resp.status_code, 400)
+            self.assertEqual(json.loads(resp.get_body())["fields"][0]["field"], field)
+        self.assertEqual(self.views, {})
+
+    def test_replace_and_delete(self):
+        view = json.loads(self._save({"name": "Big"}).get_body())
+        self.req.route_params = {"id": view["id"]}
+
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(return_value={"name": "Bigger", "sort": "oldest"})
+        resp = controller.handle_view(self.req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(self.views[view["id"]][1]["name"], "Bigger")
+
+        # Another member can't touch it
+        sign_in(self.req, "b@test.com")
+        self.req.method = "DELETE"
+        self.assertEqual(controller.handle_view(self.req).status_code, 404)
+
+        sign_in(self.req, "a@test.com")
+        self.assertEqual(controller.handle_view(self.req).status_code, 204)
+        self.assertEqual(self.views, {})
+        self.assertEqual(controller.handle_view(self.req).status_code, 404)
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestConnectorsController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request(
+            "admin@test.com",
+            route_params={"name": "bank"},
+            method="PUT",
+        )
+        self.body = {
+            "protocol": "sftp",
+            "host": "sftp.bank.test",
+            "username": "household",
+            "secretName": "SECRET_BANK",
+            "hostKey": "ssh-ed25519 AAAAC3Nza",
+        }
+        self.req.get_json = MagicMock(return_value=self.body)
+
+    @patch.object(controller.db_service, "get_connector_statuses")
+    @patch.object(controller.db_service, "get_connectors")
+    def test_list_with_last_pull(self, mock_connectors, mock_statuses):
+        mock_connectors.return_value = [Connector.from_json("bank", self.body)]
+        mock_statuses.return_value = {"bank": {"status": "failed", "error": "refused"}}
+
+        resp = controller.handle_connectors(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        (listed,) = json.loads(resp.get_body())["connectors"]
+        self.assertEqual(listed["secretName"], "SECRET_BANK")
+        self.assertEqual(listed["lastPull"]["error"], "refused")
+
+    @patch.object(controller.db_service, "save_connector")
+    def test_put_saves_connector(self, mock_save):
+        resp = controller.handle_connector(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        connector = mock_save.call_args[0][0]
+        self.assertEqual(connector.name, "bank")
+        self.assertEqual(connector.effective_port, 22)
+        self.assertEqual(connector.interval_minutes, 60)
+
+    @patch.object(controller.db_service, "save_connector")
+    def test_put_rejects_unsafe_connectors(self, mock_save):
+        for field, value in (
+            ("secretName", "AzureWebJobsStorage"),
+            ("hostKey", None),
+            ("protocol", "ftp"),
+        ):
+            with self.subTest(field=field):
+                self.req.get_json.return_value = {**self.body, field: value}
+                resp = controller.handle_connector(self.req)
+                self.assertEqual(resp.status_code, 400)
+                self.assertIn(field, resp.get_body().decode())
+
+        self.req.route_params = {"name": "Bad Name"}
+        self.assertEqual(controller.handle_connector(self.req).status_code, 400)
+        mock_save.assert_not_called()
+
+    @patch.object(controller.db_service, "delete_connector")
+    def test_delete(self, mock_delete):
+        self.req.method = "DELETE"
+        mock_delete.return_value = True
+        self.assertEqual(controller.handle_connector(self.req).status_code, 204)
+
+        mock_delete.return_value = False
+        self.assertEqual(controller.handle_connector(self.req).status_code, 404)
+
+    def test_requires_admin(self):
+        sign_in(self.req, "user@test.com")
+        self.assertEqual(controller.handle_connectors(self.req).status_code, 403)
+
+
+@patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+class TestMailboxesController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request(
+            "admin@test.com",
+            route_params={"name": "bank"},
+            method="PUT",
+            url="https://app.test/api/manage/mailboxes/bank/authorize",
+        )
+        self.body = {
+            "provider": "gmail",
+            "clientId": "app-id",
+            "secretName": "SECRET_GMAIL",
+            "rules": [{"from": "statements@bank.test"}],
+        }
+        self.req.get_json = MagicMock(return_value=self.body)
+        self.mailbox = Mailbox(
+            "bank", "gmail", "app-id", "SECRET_GMAIL", (MailRule("a@bank.test"),)
+        )
+
+        patchers = {
+            "mailboxes": patch.object(
+                controller.db_service, "get_mailboxes", return_value=[self.mailbox]
+            ),
+            "token": patch.object(
+                controller.db_service,
+                "get_mailbox_token",
+                return_value={
+                    "refreshToken": None,
+                    "authState": "bank.secret-state",
+                    "authorizedAt": None,
+                },
+            ),
+            "set_state": patch.object(controller.db_service, "set_mailbox_auth_state"),
+            "save_token": patch.object(controller.db_service, "save_mailbox_token"),
+            "secret": patch.object(
+                controller.secret_provider, "get", return_value="client-secret"
+            ),
+            "key": patch.object(controller, "_backup_key", return_value=b"key"),
+            "seal": patch(
+                "rmanalyzer.backups.seal",
+                side_effect=lambda key, purpose, secret: f"{purpose}|{secret}",
+            ),
+        }
+        self.mocks = {name: p.start() for name, p in patchers.items()}
+        self.addCleanup(patch.stopall)
+
+    @patch.object(controller.db_service, "get_connector_statuses", return_value={})
+    def test_list_shows_authorization(self, _):
+        resp = controller.handle_mailboxes(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        (listed,) = json.loads(resp.get_body())["mailboxes"]
+        self.assertEqual(listed["name"], "bank")
+        self.assertFalse(listed["authorized"])
+        self.assertNotIn("refreshToken", listed)
+
+    @patch.object(controller.db_service, "save_mailbox")
+    def test_put_validates_rules(self, mock_save):
+        self.assertEqual(controller.handle_mailbox(self.req).status_code, 200)
+        saved = mock_save.call_args[0][0]
+        self.assertEqual(saved.rules[0].sender, "statements@bank.test")
+
+        for rules in ([], [{}], [{"subject": 5}]):
+            with self.subTest(rules=rules):
+                self.req.get_json.return_value = {**self.body, "rules": rules}
+                resp = controller.handle_mailbox(self.req)
+                self.assertEqual(resp.status_code, 400)
+                self.assertIn("rules", resp.get_body().decode())
+        self.assertEqual(mock_save.call_count, 1)
+
+    def test_authorize_redirects_with_state(self):
+        resp = controller.handle_mailbox_authorize(self.req)
+
+        self.assertEqual(resp.status_code, 302)
+        state = self.mocks["set_state"].call_args[0][1]
+        self.assertTrue(state.startswith("bank."))
+        location = resp.headers["Location"]
+        self.assertTrue(location.startswith("https://accounts.google.com/"))
+        self.assertIn("app.test%2Fapi%2Fmanage%2Fmailbox-callback", location)
+
+    @patch("rmanalyzer.controller.MAIL_PROVIDERS")
+    def test_callback_stores_refresh_token(self, mock_providers):
+        mock_providers.__getitem__.return_value.redeem.return_value = {
+            "access_token": "a",
+            "refresh_token": "r",
+        }
+        self.req.params = {"code": "c", "state": "bank.secret-state"}
+
+        resp = controller.handle_mailbox_callback(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        grant = mock_providers.__getitem__.return_value.redeem.call_args[0][2]
+        self.assertEqual(grant["code"], "c")
+        self.assertEqual(
+            grant["redirect_uri"], "https://app.test/api/manage/mailbox-callback"
+        )
+        # Stored encrypted with the backup key, never as issued
+        self.mocks["seal"].assert_called_once_with(b"key", "mailbox:bank", "r")
+        self.mocks["save_token"].assert_called_once_with("bank", "mailbox:bank|r")
+
+    def test_callback_rejects_wrong_state(self):
+        self.req.params = {"code": "c", "state": "bank.forged"}
+
+        resp = controller.handle_mailbox_callback(self.req)
+
+        self.assertEqual(resp.status_code, 400)
+        self.mocks["save_token"].assert_not_called()
+
+
+if __name__ == "__main__":
+    unittest.main()
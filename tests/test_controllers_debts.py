@@ -0,0 +1,511 @@
+"""
+Tests for debt ledger controller logic.
+"""
+
+import json
+import unittest
+from datetime import date, datetime
+from decimal import Decimal
+from unittest.mock import MagicMock, patch
+
+from fixtures import http_request, start_patches
+from rmanalyzer.controller import controller
+from rmanalyzer.ledger import LedgerEntry
+from rmanalyzer.models import Category, Group, IgnoredFrom, Person, Transaction
+
+
+class TestDebtController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com")
+
+    def test_history_unauthorized(self):
+        self.req.headers = {}
+        resp = controller.handle_debt_history(self.req)
+        self.assertEqual(resp.status_code, 401)
+
+    @patch.object(controller.db_service, "get_ledger_entries")
+    def test_history(self, mock_entries):
+        mock_entries.return_value = [
+            LedgerEntry(
+                "e1",
+                "import",
+                "a@test.com",
+                "b@test.com",
+                Decimal("15.00"),
+                "2025-01",
+                "2025-01-31",
+            )
+        ]
+
+        resp = controller.handle_debt_history(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertEqual(len(payload["entries"]), 1)
+        self.assertEqual(payload["balance"]["amount"], 15.0)
+
+    @patch.object(controller.db_service, "save_ledger_entry")
+    def test_record_import_debt(self, mock_save):
+        p1 = Person("A", "a@test.com", [1])
+        p2 = Person("B", "b@test.com", [2])
+        group = Group([p1, p2])
+        group.add_transactions(
+            [
+                Transaction(
+                    date(2025, 3, 2),
+                    "Market",
+                    2,
+                    Decimal("40.00"),
+                    Category.GROCERIES,
+                    IgnoredFrom.NOTHING,
+                )
+            ]
+        )
+
+        # pylint: disable=protected-access
+        controller._record_import_debt(group, "20250302_statement.csv")
+        controller._record_import_debt(group, "20250302_statement.csv")
+
+        first, second = [c[0][0] for c in mock_save.call_args_list]
+        self.assertEqual(first.entry_id, second.entry_id)
+        self.assertEqual(first.debtor, "a@test.com")
+        self.assertEqual(first.amount, Decimal("20.00"))
+        self.assertEqual(first.month, "2025-03")
+
+
+class TestMonthlyReportJob(unittest.TestCase):
+    def setUp(self):
+        self.people = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+            {"Name": "B", "Email": "b@test.com", "Accounts": [2]},
+        ]
+        patches = [
+            patch.object(
+                controller.db_service,
+                "get_all_people",
+                side_effect=lambda: self.people,
+            ),
+            patch.object(
+                controller.db_service,
+                "get_transactions",
+                return_value=[
+                    Transaction(
+                        date(2025, 5, 3),
+                        "Market",
+                        1,
+                        Decimal("100.00"),
+                        Category.GROCERIES,
+                        IgnoredFrom.NOTHING,
+                    )
+                ],
+            ),
+            patch.object(
+                controller.db_service,
+                "apply_owner_overrides",
+                side_effect=lambda transactions: transactions,
+            ),
+            patch.object(
+                controller.db_service,
+                "get_spending_totals",
+                return_value=[
+                    {
+                        "Category": "Groceries",
+                        "AccountNumber": 1,
+                        "Owner": "",
+                        "Total": Decimal("100.00"),
+                        "Count": 1,
+                    }
+                ],
+            ),
+            patch.object(
+                controller.db_service,
+                "get_budgets",
+                return_value={Category.GROCERIES: Decimal("80.00")},
+            ),
+            patch.object(
+                controller.db_service,
+                "get_ledger_entries",
+                return_value=[
+                    # B paid A 20 towards May, and 7 towards the running balance
+                    LedgerEntry(
+                        "s1",
+                        "settlement",
+                        "a@test.com",
+                        "b@test.com",
+                        Decimal("20.00"),
+                        "2025-05",
+                        "2025-05-20",
+                        "2025-05",
+                    ),
+                    LedgerEntry(
+                        "s2",
+                        "settlement",
+                        "a@test.com",
+                        "b@test.com",
+                        Decimal("7.00"),
+                        "2025-05",
+                        "2025-05-21",
+                    ),
+                ],
+            ),
+            patch.object(controller.db_service, "get_settings", return_value={}),
+            patch.object(controller.db_service, "record_job_run"),
+            patch.object(controller.db_service, "record_activity"),
+            patch.object(controller.email_service, "send_emails"),
+            patch("rmanalyzer.controller.datetime", wraps=datetime),
+        ]
+        mocks = [p.start() for p in patches]
+        self.addCleanup(patch.stopall)
+        self.mock_record_run, self.mock_send = mocks[7], mocks[9]
+        self.mock_record_activity = mocks[8]
+        mocks[10].now.return_value = datetime(2025, 6, 1, 7)
+
+    def test_report(self):
+        # pylint: disable=protected-access
+        report = controller._monthly_report("2025-05")
+
+        self.assertEqual(report["total"], 100.0)
+        self.assertEqual(
+            report["categories"], [{"category": "Groceries", "total": 100.0}]
+        )
+        self.assertTrue(report["budgets"][0]["overBudget"])
+        self.assertEqual(
+            report["debt"], {"from": "B", "to": "A", "amount": 30.0, "settled": 20.0}
+        )
+
+    def test_job_emails_every_member_last_month(self):
+        controller.run_monthly_report_job()
+
+        (messages,) = self.mock_send.call_args[0]
+        self.assertEqual(
+            [m[:2] for m in messages],
+            [
+                (["a@test.com"], "Monthly report: 2025-05"),
+                (["b@test.com"], "Monthly report: 2025-05"),
+            ],
+        )
+        self.assertIn("B owes A: <strong>30.00</strong>", messages[0][2])
+        self.assertIn("20.00 over", messages[0][2])
+        run = self.mock_record_run.call_args[0]
+        self.assertEqual((run[0], run[2]), ("monthly-report", "succeeded"))
+        kind, subject = self.mock_record_activity.call_args[0][:2]
+        self.assertEqual((kind, subject), ("report", "Monthly report: 2025-05"))
+
+    def test_no_debt_without_two_members(self):
+        self.people = self.people[:1]
+        # pylint: disable=protected-access
+        self.assertIsNone(controller._monthly_report("2025-05")["debt"])
+
+
+class TestSettleController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com")
+        self.entries = [
+            LedgerEntry(
+                "IMPORT_1",
+                "import",
+                "a@test.com",
+                "b@test.com",
+                Decimal("30.00"),
+                "2025-01",
+                "2025-01-31T00:00:00",
+            )
+        ]
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_ledger_entries",
+                side_effect=lambda: list(self.entries),
+            ),
+            patch.object(
+                controller.db_service,
+                "save_ledger_entry",
+                side_effect=self.entries.append,
+            ),
+        ]
+        start_patches(self, patchers)
+
+    def test_partial_settlement_reduces_balance(self):
+        self.req.get_json = MagicMock(return_value={"amount": 10})
+
+        resp = controller.handle_debt_settle(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            json.loads(resp.get_body()),
+            {"debtor": "a@test.com", "creditor": "b@test.com", "amount": 20.0},
+        )
+        settlement = self.entries[-1]
+        self.assertEqual(settlement.kind, "settlement")
+        self.assertEqual(settlement.debtor, "b@test.com")
+
+    def test_settlement_cannot_exceed_balance(self):
+        self.req.get_json = MagicMock(return_value={"amount": 30.01})
+        resp = controller.handle_debt_settle(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(len(self.entries), 1)
+
+    def test_settlement_requires_positive_amount(self):
+        self.req.get_json = MagicMock(return_value={"amount": 0})
+        resp = controller.handle_debt_settle(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    def test_nothing_to_settle(self):
+        self.entries.clear()
+        self.req.get_json = MagicMock(return_value={"amount": 5})
+        resp = controller.handle_debt_settle(self.req)
+        self.assertEqual(resp.status_code, 409)
+
+    @patch.object(controller.db_service, "get_settings", return_value={})
+    @patch.object(
+        controller.db_service, "apply_owner_overrides", side_effect=lambda ts: ts
+    )
+    @patch.object(controller.db_service, "get_transactions")
+    @patch.object(controller.db_service, "get_all_people")
+    def test_settles_a_months_debt(self, mock_people, mock_transactions, *_):
+        mock_people.return_value = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+            {"Name": "B", "Email": "b@test.com", "Accounts": [2]},
+        ]
+        # B paid 40 of shared groceries in March, so A owes B 20 for it
+        mock_transactions.return_value = [
+            Transaction(
+                date(2025, 3, 3),
+                "Market",
+                2,
+                Decimal("40.00"),
+                Category.GROCERIES,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+        self.req.get_json = MagicMock(return_value={"amount": 15, "month": "2025-03"})
+
+        resp = controller.handle_debt_settle(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        mock_transactions.assert_called_once_with("2025-03")
+        settlement = self.entries[-1]
+        self.assertEqual(
+            (settlement.debtor, settlement.creditor), ("b@test.com", "a@test.com")
+        )
+        self.assertEqual(settlement.period, "2025-03")
+
+        # Only 5 is still owed for March, whatever the running balance
+        self.req.get_json = MagicMock(return_value={"amount": 6, "month": "2025-03"})
+        self.assertEqual(controller.handle_debt_settle(self.req).status_code, 400)
+        self.req.get_json = MagicMock(return_value={"amount": 5, "month": "2025-03"})
+        self.assertEqual(controller.handle_debt_settle(self.req).status_code, 200)
+        self.req.get_json = MagicMock(return_value={"amount": 1, "month": "2025-03"})
+        self.assertEqual(controller.handle_debt_settle(self.req).status_code, 409)
+
+    def test_balance_settlements_have_no_period(self):
+        self.req.get_json = MagicMock(return_value={"amount": 10})
+        controller.handle_debt_settle(self.req)
+        self.assertEqual(self.entries[-1].period, "")
+
+
+
+class TestSummaryAttachments(unittest.TestCase):
+    def setUp(self):
+        self.settings = {"SummaryAttachments": "true"}
+        patcher = patch.object(
+            controller.db_service,
+            "get_settings",
+            side_effect=lambda: dict(self.settings),
+        )
+        patcher.start()
+        self.addCleanup(patcher.stop)
+        self.transactions = [
+            Transaction(
+                date(2025, 3, 2),
+                "Market",
+                2,
+                Decimal("40.00"),
+                Category.GROCERIES,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+
+    def test_attaches_statement_and_report(self):
+        # pylint: disable=protected-access
+        attachments = controller._summary_attachments(
+            "uploads/20250302_statement.csv", b"raw", "csv", self.transactions
+        )
+
+        self.assertEqual(
+            [a.name for a in attachments],
+            ["20250302_statement.csv", "20250302_statement-processed.csv"],
+        )
+        self.assertEqual(attachments[0].content, b"raw")
+        self.assertIn(b"Market,2,40.00,Groceries", attachments[1].content)
+
+    def test_off_by_default(self):
+        self.settings = {}
+        # pylint: disable=protected-access
+        attachments = controller._summary_attachments(
+            "statement.csv", b"raw", "csv", self.transactions
+        )
+        self.assertIsNone(attachments)
+
+    @patch("rmanalyzer.controller.MAX_ATTACHMENT_SIZE", 10)
+    def test_skipped_when_too_large(self):
+        # pylint: disable=protected-access
+        attachments = controller._summary_attachments(
+            "statement.csv", b"x" * 20, "csv", self.transactions
+        )
+        self.assertIsNone(attachments)
+
+
+class TestDebtReportController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", params={"month": "2025-01"})
+
+        self.people = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+            {"Name": "B", "Email": "b@test.com", "Accounts": [2]},
+        ]
+        transactions = [
+            Transaction(
+                date(2025, 1, 3),
+                "Cafe",
+                1,
+                Decimal("30.00"),
+                Category.DINING,
+                IgnoredFrom.NOTHING,
+            ),
+            Transaction(
+                date(2025, 1, 4),
+                "Market",
+                2,
+                Decimal("70.00"),
+                Category.GROCERIES,
+                IgnoredFrom.NOTHING,
+            ),
+            Transaction(
+                date(2025, 1, 5),
+                "Hardware",
+                9,
+                Decimal("12.50"),
+                Category.PURCHASES,
+                IgnoredFrom.NOTHING,
+            ),
+        ]
+        patchers = [
+            patch.object(
+                controller.db_service, "get_all_people", return_value=self.people
+            ),
+            patch.object(
+                controller.db_service, "get_transactions", return_value=transactions
+            ),
+            patch.object(
+                controller.db_service,
+                "apply_owner_overrides",
+                side_effect=lambda ts: ts,
+            ),
+            patch.object(controller.db_service, "get_settings", return_value={}),
+            patch.object(controller.db_service, "get_ledger_entries", return_value=[]),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_get_transactions, self.mock_get_settings = mocks[1], mocks[3]
+        self.mock_get_ledger_entries = mocks[4]
+
+    def test_debt(self):
+        resp = controller.handle_debt(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        # A spent 30 of 100 shared, so owes B 20
+        self.assertEqual(
+            payload["owes"], {"from": "a@test.com", "to": "b@test.com", "amount": 20.0}
+        )
+        self.assertEqual(payload["debt"], 20.0)
+        self.assertEqual(payload["p2"]["shared"], 70.0)
+        self.assertEqual(payload["unassigned"], 12.5)
+        by_category = {c["category"]: c for c in payload["categories"]}
+        self.assertEqual(by_category["Dining & Drinks"]["debt"], -15.0)
+        self.assertEqual(by_category["Groceries"]["debt"], 35.0)
+        self.assertEqual(sum(c["debt"] for c in payload["categories"]), 20.0)
+        self.mock_get_transactions.assert_called_once_with("2025-01")
+
+    def test_debt_respects_exclusions(self):
+        self.mock_get_settings.return_value = {
+            "DebtExcludedCategories": '["Groceries"]'
+        }
+
+        payload = json.loads(controller.handle_debt(self.req).get_body())
+
+        # Only A's 30 of dining is shared, so B owes A 15
+        self.assertEqual(
+            payload["owes"], {"from": "b@test.com", "to": "a@test.com", "amount": 15.0}
+        )
+        groceries = next(
+            c for c in payload["categories"] if c["category"] == "Groceries"
+        )
+        self.assertEqual(groceries, {**groceries, "excluded": True, "debt": 0.0})
+
+    def test_debt_uses_split_shares(self):
+        self.people[0]["SplitShare"] = 0.6
+        self.people[1]["CategoryShares"] = {"Dining & Drinks": 0.5}
+
+        payload = json.loads(controller.handle_debt(self.req).get_body())
+
+        by_category = {c["category"]: c for c in payload["categories"]}
+        self.assertEqual(by_category["Dining & Drinks"]["split"], 0.5)
+        self.assertEqual(by_category["Groceries"]["split"], 0.6)
+        # Groceries: 0.6 * 70 = 42 of B's spend is A's
+        self.assertEqual(by_category["Groceries"]["debt"], 42.0)
+        self.assertEqual(payload["debt"], 27.0)
+
+    def test_debt_subtracts_settlements(self):
+        self.mock_get_ledger_entries.return_value = [
+            # A paid B 12 for January. Payments in January for December or
+            # towards the running balance don't count.
+            LedgerEntry(
+                "s1",
+                "settlement",
+                "b@test.com",
+                "A@test.com",
+                Decimal("12.00"),
+                "2025-02",
+                "2025-02-03T10:00:00",
+                "2025-01",
+            ),
+            LedgerEntry(
+                "s2",
+                "settlement",
+                "b@test.com",
+                "a@test.com",
+                Decimal("5.00"),
+                "2025-01",
+                "2025-01-02T10:00:00",
+                "2024-12",
+            ),
+            LedgerEntry(
+                "s3",
+                "settlement",
+                "b@test.com",
+                "a@test.com",
+                Decimal("4.00"),
+                "2025-01",
+                "2025-01-20T10:00:00",
+            ),
+        ]
+
+        payload = json.loads(controller.handle_debt(self.req).get_body())
+
+        self.assertEqual(payload["settled"], 12.0)
+        self.assertEqual(payload["debt"], 8.0)
+        self.assertEqual(
+            payload["owes"], {"from": "a@test.com", "to": "b@test.com", "amount": 8.0}
+        )
+
+    def test_debt_needs_two_members(self):
+        self.people.append({"Name": "C", "Email": "c@test.com", "Accounts": [3]})
+        self.assertEqual(controller.handle_debt(self.req).status_code, 409)
+
+    def test_debt_invalid_month(self):
+        self.req.params = {"month": "2025-13"}
+        self.assertEqual(controller.handle_debt(self.req).status_code, 400)
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,217 @@
+"""
+Tests for report controller logic.
+"""
+
+import json
+import unittest
+from datetime import date
+from decimal import Decimal
+from unittest.mock import patch
+
+from fixtures import http_request, start_patches
+from rmanalyzer.controller import controller
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+
+
+class TestCompareController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request(
+            "a@test.com",
+            params={"p1": "A@test.com", "p2": "b@test.com", "month": "2025-01"},
+        )
+
+        people = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+            {"Name": "B", "Email": "b@test.com", "Accounts": [2]},
+        ]
+        transactions = [
+            Transaction(
+                date(2025, 1, 3),
+                "Cafe",
+                1,
+                Decimal("30.00"),
+                Category.DINING,
+                IgnoredFrom.NOTHING,
+            ),
+            Transaction(
+                date(2025, 1, 4),
+                "Market",
+                2,
+                Decimal("70.00"),
+                Category.GROCERIES,
+                IgnoredFrom.NOTHING,
+            ),
+        ]
+        patchers = [
+            patch.object(controller.db_service, "get_all_people", return_value=people),
+            patch.object(
+                controller.db_service, "get_transactions", return_value=transactions
+            ),
+            patch.object(controller.db_service, "get_settings", return_value={}),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_get_transactions = mocks[1]
+        self.mock_get_settings = mocks[2]
+
+    def test_compare(self):
+        resp = controller.handle_compare_report(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        dining = payload["categories"][0]
+        self.assertEqual(
+            dining,
+            {"category": "Dining & Drinks", "p1": 30.0, "p2": 0.0, "difference": 30.0},
+        )
+        self.assertEqual(payload["totals"]["difference"], -40.0)
+        # A spent 30 of 100 shared, so owes B 20
+        self.assertEqual(payload["debt"], 20.0)
+        self.mock_get_transactions.assert_called_once_with("2025-01")
+
+    def test_compare_respects_debt_exclusions(self):
+        self.mock_get_settings.return_value = {
+            "DebtExcludedCategories": '["Dining & Drinks"]'
+        }
+        resp = controller.handle_compare_report(self.req)
+        # Only B's 70 of groceries is shared, so A owes B 35
+        self.assertEqual(json.loads(resp.get_body())["debt"], 35.0)
+
+    def test_compare_unknown_person(self):
+        self.req.params = {"p1": "a@test.com", "p2": "c@test.com"}
+        resp = controller.handle_compare_report(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(json.loads(resp.get_body())["fields"][0]["field"], "p2")
+
+    def test_compare_requires_both_people(self):
+        self.req.params = {"p1": "a@test.com"}
+        resp = controller.handle_compare_report(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+
+class TestSummaryController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", params={"month": "2025-01"})
+        people = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+            {"Name": "B", "Email": "b@test.com", "Accounts": [2]},
+        ]
+        rows = [
+            {
+                "Category": "Groceries",
+                "AccountNumber": 1,
+                "Owner": None,
+                "Total": Decimal("100.00"),
+                "Count": 2,
+            },
+            # Reassigned from A's account to B
+            {
+                "Category": "Dining & Drinks",
+                "AccountNumber": 1,
+                "Owner": "B@test.com",
+                "Total": Decimal("30.00"),
+                "Count": 1,
+            },
+            {
+                "Category": "Groceries",
+                "AccountNumber": 9,
+                "Owner": None,
+                "Total": Decimal("5.50"),
+                "Count": 1,
+            },
+        ]
+        patchers = [
+            patch.object(controller.db_service, "get_all_people", return_value=people),
+            patch.object(
+                controller.db_service, "get_spending_totals", return_value=rows
+            ),
+        ]
+        self.views = [{"id": "v1", "name": "Big", "filter": "amount>100", "sort": None}]
+        patchers.append(
+            patch.object(controller.db_service, "get_views", return_value=self.views)
+        )
+        mocks = start_patches(self, patchers)
+        self.mock_get_totals = mocks[1]
+        self.mock_get_views = mocks[2]
+
+    def test_summary(self):
+        resp = controller.handle_summary(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.mock_get_totals.assert_called_once_with("2025-01")
+        self.assertEqual(payload["total"], 135.5)
+        categories = {c["category"]: c["total"] for c in payload["categories"]}
+        self.assertEqual(categories["Groceries"], 105.5)
+        self.assertEqual(categories["Pets"], 0.0)
+        self.assertEqual(
+            payload["accounts"],
+            [
+                {"accountNumber": 1, "total": 130.0, "count": 3},
+                {"accountNumber": 9, "total": 5.5, "count": 1},
+            ],
+        )
+        people = {p["email"]: p["total"] for p in payload["people"]}
+        self.assertEqual(people, {"a@test.com": 100.0, "b@test.com": 30.0})
+        self.assertEqual(payload["unassigned"], 5.5)
+        # The caller's saved views come with the dashboard
+        self.assertEqual(payload["views"], self.views)
+        self.mock_get_views.assert_called_once_with("a@test.com")
+
+    def test_invalid_month(self):
+        self.req.params = {"month": "2025-13"}
+        resp = controller.handle_summary(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+
+class TestTrendReportController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request(
+            "a@test.com",
+            params={"from": "2023-12", "to": "2024-01"},
+        )
+        rows = [
+            {
+                "Category": "Groceries",
+                "AccountNumber": 1,
+                "Owner": None,
+                "Total": Decimal("100.00"),
+                "Count": 1,
+            }
+        ]
+        settings = {"CpiIndex": json.dumps({"2023": "100", "2024": "110"})}
+        patchers = [
+            patch.object(
+                controller.db_service, "get_spending_totals", return_value=rows
+            ),
+            patch.object(controller.db_service, "get_settings", return_value=settings),
+        ]
+        start_patches(self, patchers)
+
+    def test_nominal(self):
+        resp = controller.handle_trend_report(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertFalse(payload["real"])
+        self.assertEqual([m["total"] for m in payload["months"]], [100.0, 100.0])
+
+    def test_real(self):
+        self.req.params["real"] = "true"
+
+        resp = controller.handle_trend_report(self.req)
+
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["baseYear"], 2024)
+        # 2023 spend in 2024 dollars
+        self.assertEqual(payload["months"][0]["total"], 110.0)
+        self.assertEqual(payload["months"][0]["categories"]["Groceries"], 110.0)
+        self.assertEqual(payload["months"][1]["total"], 100.0)
+
+    def test_rejects_reversed_range(self):
+        self.req.params = {"from": "2024-02", "to": "2024-01"}
+        resp = controller.handle_trend_report(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -2,31 +2,26 @@
 Tests for savings controller logic.
 """
 
-import base64
 import json
+import os
+import re
 import unittest
+from datetime import date, datetime
+from decimal import Decimal
 from unittest.mock import MagicMock, patch
 
-import azure.functions as func
-
+from fixtures import http_request, sign_in, start_patches
 from rmanalyzer.controller import controller
+from rmanalyzer.models import Category, IgnoredFrom, Person, Transaction
 
 
 class TestSavingsController(unittest.TestCase):
     def setUp(self):
-        self.req = MagicMock(spec=func.HttpRequest)
-        self.req.params = {}
-        self.req.headers = {}
-        self.req.get_json = MagicMock(return_value={})
-
-    def _set_auth_header(self, email="test@example.com"):
-        payload = {"userDetails": email}
-        encoded = base64.b64encode(json.dumps(payload).encode("utf-8")).decode("utf-8")
-        self.req.headers["x-ms-client-principal"] = encoded
+        self.req = http_request()
 
     # pylint: disable=protected-access
     def test_get_user_email_valid(self):
-        self._set_auth_header("user@test.com")
+        sign_in(self.req, "user@test.com")
         email = controller._get_user_email(self.req)
         self.assertEqual(email, "user@test.com")
 
@@ -47,7 +42,7 @@ class TestSavingsController(unittest.TestCase):
 
     @patch("rmanalyzer.controller.controller.db_service.get_savings")
     def test_handle_savings_get_success(self, mock_get):
-        self._set_auth_header("user@test.com")
+        sign_in(self.req, "user@test.com")
         self.req.method = "GET"
         self.req.params = {"month": "2023-10"}
 
@@ -61,7 +56,7 @@ class TestSavingsController(unittest.TestCase):
 
     @patch("rmanalyzer.controller.controller.db_service.save_savings")
     def test_handle_savings_post_success(self, mock_save):
-        self._set_auth_header("user@test.com")
+        sign_in(self.req, "user@test.com")
         self.req.method = "POST"
         body = {"month": "2023-10", "startingBalance": 500}
         self.req.get_json.return_value = body
@@ -71,6 +66,235 @@ class TestSavingsController(unittest.TestCase):
         self.assertEqual(resp.status_code, 200)
         mock_save.assert_called_with("2023-10", body, "user@test.com")
 
+    @patch("rmanalyzer.controller.controller.db_service.save_savings")
+    def test_handle_savings_post_validation_errors(self, mock_save):
+        sign_in(self.req, "user@test.com")
+        self.req.method = "POST"
+        self.req.get_json.return_value = {"items": [{"name": "Rent", "cost": "x"}]}
+
+        resp = controller.handle_savings_dbrequest(self.req)
+
+        self.assertEqual(resp.status_code, 400)
+        payload = json.loads(resp.get_body())
+        fields = [f["field"] for f in payload["fields"]]
+        self.assertEqual(fields, ["startingBalance", "items"])
+        mock_save.assert_not_called()
+
+    def test_handle_savings_invalid_month_param(self):
+        sign_in(self.req, "user@test.com")
+        self.req.method = "GET"
+        self.req.params = {"month": "October"}
+
+        resp = controller.handle_savings_dbrequest(self.req)
+
+        self.assertEqual(resp.status_code, 400)
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["fields"][0]["field"], "month")
+
+    @patch("rmanalyzer.controller.controller.db_service.save_savings")
+    @patch("rmanalyzer.controller.controller.db_service.get_savings")
+    def test_savings_copy(self, mock_get, mock_save):
+        sign_in(self.req, "user@test.com")
+        self.req.params = {"from": "2024-05", "to": "2024-06", "excludeOneOff": "true"}
+        may = {
+            "startingBalance": 1000.0,
+            "items": [
+                {"name": "Rent", "cost": 600.0},
+                {"name": "Couch", "cost": 250.5, "oneOff": True},
+            ],
+        }
+        mock_get.side_effect = lambda month, _: may if month == "2024-05" else None
+
+        resp = controller.handle_savings_copy(self.req)
+
+        self.assertEqual(resp.status_code, 201)
+        mock_save.assert_called_once_with(
+            "2024-06",
+            {"startingBalance": 149.5, "items": [{"name": "Rent", "cost": 600.0}]},
+            "user@test.com",
+        )
+
+    @patch("rmanalyzer.controller.controller.db_service.save_savings")
+    @patch("rmanalyzer.controller.controller.db_service.get_savings")
+    def test_savings_copy_leaves_saved_month(self, mock_get, mock_save):
+        sign_in(self.req, "user@test.com")
+        self.req.params = {"from": "2024-05", "to": "2024-06"}
+        mock_get.return_value = {"startingBalance": 0.0, "items": []}
+
+        resp = controller.handle_savings_copy(self.req)
+
+        self.assertEqual(resp.status_code, 409)
+        mock_save.assert_not_called()
+
+    def test_savings_copy_must_go_forward(self):
+        sign_in(self.req, "user@test.com")
+        self.req.params = {"from": "2024-06", "to": "2024-05"}
+        resp = controller.handle_savings_copy(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(json.loads(resp.get_body())["fields"][0]["field"], "to")
+
+
+@patch.dict(os.environ, {"REMINDER_SIGNING_KEY": "k", "APP_URL": "https://app"})
+class TestSavingsSuggestion(unittest.TestCase):
+    def setUp(self):
+        self.members = [Person("A", "a@test.com", [1]), Person("B", "b@test.com", [2])]
+        self.transactions = [
+            Transaction(
+                date(2025, 3, 9),
+                "Cafe",
+                1,
+                Decimal("60.00"),
+                Category.DINING,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+        self.settings = {}
+        self.savings = {}
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_settings",
+                side_effect=lambda: dict(self.settings),
+            ),
+            patch.object(
+                controller.db_service,
+                "save_setting",
+                side_effect=self.settings.__setitem__,
+            ),
+            patch.object(
+                controller.db_service,
+                "get_budgets",
+                return_value={
+                    Category.DINING: Decimal("200.00"),
+                    Category.GROCERIES: Decimal("500.00"),
+                },
+            ),
+            patch.object(
+                controller.db_service,
+                "get_spending_totals",
+                return_value=[{"Category": "Dining & Drinks", "Total": Decimal("60")}],
+            ),
+            patch.object(
+                controller.db_service,
+                "get_savings",
+                side_effect=lambda month, email: self.savings.get((month, email)),
+            ),
+            patch.object(
+                controller.db_service,
+                "save_savings",
+                side_effect=lambda month, data, email: self.savings.__setitem__(
+                    (month, email), data
+                ),
+            ),
+            patch.object(controller.db_service, "record_activity"),
+            patch.object(controller.email_service, "send_email"),
+        ]
+        start_patches(self, patchers)
+        self.mock_send = controller.email_service.send_email
+
+    def _approve_request(self, link):
+        return http_request(
+            "a@test.com",
+            route_params={"id": link.group(1)},
+            get_json=MagicMock(return_value={"sig": link.group(2)}),
+        )
+
+    def test_suggests_share_of_discretionary_leftover(self):
+        controller._suggest_savings_transfer(self.transactions, self.members)
+
+        # Groceries is essential, so only dining's 140 left over counts
+        recipients, subject, body = self.mock_send.call_args[0]
+        self.assertEqual(recipients, ["a@test.com", "b@test.com"])
+        self.assertEqual(subject, "Move $70 to savings?")
+        self.assertIn("140.00", body)
+        self.assertIn("/confirm.html?action=savings&amp;id=2025-03_70&amp;sig=", body)
+
+        # Nothing more is suggested that month, whatever the next import leaves
+        self.mock_send.reset_mock()
+        with patch.object(
+            controller.db_service,
+            "get_spending_totals",
+            return_value=[{"Category": "Dining & Drinks", "Total": Decimal("20")}],
+        ):
+            controller._suggest_savings_transfer(self.transactions, self.members)
+        self.mock_send.assert_not_called()
+
+    def test_suggestion_is_pro_rated_mid_month(self):
+        # Ten days into March, dining's budget so far is 64.52, so 34.52 is left
+        with patch("rmanalyzer.controller.datetime") as mock_datetime:
+            mock_datetime.now.return_value = datetime(2025, 3, 10, 9, 0)
+            with patch.object(
+                controller.db_service,
+                "get_spending_totals",
+                return_value=[{"Category": "Dining & Drinks", "Total": Decimal("30")}],
+            ):
+                controller._suggest_savings_transfer(self.transactions, self.members)
+
+        _, subject, body = self.mock_send.call_args[0]
+        self.assertEqual(subject, "Move $17 to savings?")
+        self.assertIn("34.52", body)
+
+    def test_no_suggestion_when_turned_off(self):
+        self.settings["SavingsSuggestionShare"] = "0"
+        controller._suggest_savings_transfer(self.transactions, self.members)
+        self.mock_send.assert_not_called()
+
+    def test_approve_records_savings_item(self):
+        self.savings[("2025-03", "a@test.com")] = {
+            "startingBalance": 500.0,
+            "items": [{"name": "Vacation", "cost": 100.0}],
+        }
+        controller._suggest_savings_transfer(self.transactions, self.members)
+        link = re.search(
+            r"/confirm.html\?action=savings&amp;id=([^&]+)&amp;sig=(\w+)",
+            self.mock_send.call_args[0][2],
+        )
+        req = self._approve_request(link)
+
+        req.get_json.return_value = {"sig": "forged"}
+        resp = controller.handle_savings_suggestion_approve(req)
+        self.assertEqual(resp.status_code, 403)
+
+        req.get_json.return_value = {"sig": link.group(2)}
+        resp = controller.handle_savings_suggestion_approve(req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            self.savings[("2025-03", "a@test.com")]["items"],
+            [
+                {"name": "Vacation", "cost": 100.0},
+                {"name": "Pay yourself first", "cost": 70.0, "oneOff": True},
+            ],
+        )
+
+        # Approving again changes nothing, and no more suggestions are sent
+        resp = controller.handle_savings_suggestion_approve(req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(len(self.savings[("2025-03", "a@test.com")]["items"]), 2)
+        self.mock_send.reset_mock()
+        with patch.object(
+            controller.db_service, "get_spending_totals", return_value=[]
+        ):
+            controller._suggest_savings_transfer(self.transactions, self.members)
+        self.mock_send.assert_not_called()
+
+    def test_approve_expired_suggestion(self):
+        controller._suggest_savings_transfer(self.transactions, self.members)
+        link = re.search(
+            r"/confirm.html\?action=savings&amp;id=([^&]+)&amp;sig=(\w+)",
+            self.mock_send.call_args[0][2],
+        )
+
+        # Later months' suggestions push it out of those kept
+        suggestions = json.loads(self.settings["SavingsSuggestions"])
+        for month in ("2025-04", "2025-05", "2025-06"):
+            suggestions[month] = {"amount": "10"}
+        del suggestions["2025-03"]
+        self.settings["SavingsSuggestions"] = json.dumps(suggestions)
+
+        resp = controller.handle_savings_suggestion_approve(self._approve_request(link))
+        self.assertEqual(resp.status_code, 410)
+        self.assertEqual(self.savings, {})
+
 
 if __name__ == "__main__":
     unittest.main()
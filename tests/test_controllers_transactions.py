@@ -0,0 +1,627 @@
+"""
+Tests for transaction controller logic.
+"""
+
+import json
+import os
+import unittest
+from datetime import date, datetime, timedelta
+from decimal import Decimal
+from unittest.mock import MagicMock, patch
+
+from fixtures import http_request, sign_in, start_patches
+from rmanalyzer.controller import controller
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+
+
+class TestTransactionController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", method="PUT", route_params={"id": "abc"})
+        self.stored = {
+            "PartitionKey": "default_2025-01",
+            "RowKey": "abc",
+            "AccountNumber": 1234,
+            "Amount": 10.0,
+        }
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_all_people",
+                return_value=[{"Email": "a@test.com", "Accounts": [1234, 5678]}],
+            ),
+            patch.object(
+                controller.db_service,
+                "get_accounts",
+                return_value=[
+                    {"accountId": "visa", "mask": "1234"},
+                    {"accountId": "amex", "mask": "5678"},
+                ],
+            ),
+            patch.object(controller.db_service, "adjust_account_balance"),
+        ]
+        start_patches(self, patchers)
+        self.mock_adjust = controller.db_service.adjust_account_balance
+
+    @patch.object(controller.db_service, "record_activity")
+    @patch.object(controller.db_service, "update_transaction")
+    def test_update(self, mock_update, mock_activity):
+        mock_update.return_value = self.stored
+        self.req.get_json = MagicMock(
+            return_value={"category": "Groceries", "amount": "12.50"}
+        )
+
+        resp = controller.handle_transaction(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        # A category set by hand is kept when the rules run again
+        mock_update.assert_called_once_with(
+            "abc",
+            {"Amount": 12.5, "Category": "Groceries", "CategorySource": "manual"},
+        )
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["category"], "Groceries")
+        kind, _, actor, details = mock_activity.call_args[0]
+        self.assertEqual((kind, actor), ("edit", "a@test.com"))
+        self.assertEqual(details["changes"], {"amount": 12.5, "category": "Groceries"})
+        # The card is charged the difference
+        self.mock_adjust.assert_called_once_with("a@test.com", "visa", Decimal("2.5"))
+
+    @patch.object(controller.db_service, "record_activity")
+    @patch.object(controller.db_service, "update_transaction")
+    def test_update_moves_charge_between_cards(self, mock_update, _):
+        mock_update.return_value = self.stored
+        self.req.get_json = MagicMock(
+            return_value={"accountNumber": 5678, "amount": "15.00"}
+        )
+
+        resp = controller.handle_transaction(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            {c.args for c in self.mock_adjust.call_args_list},
+            {
+                ("a@test.com", "visa", Decimal("-10.0")),
+                ("a@test.com", "amex", Decimal("15.0")),
+            },
+        )
+
+    @patch.object(controller.db_service, "record_activity")
+    @patch.object(controller.db_service, "update_transaction")
+    def test_update_without_amount_leaves_balance(self, mock_update, _):
+        mock_update.return_value = self.stored
+        self.req.get_json = MagicMock(return_value={"category": "Groceries"})
+
+        controller.handle_transaction(self.req)
+
+        self.mock_adjust.assert_not_called()
+
+    @patch.object(
+        controller.db_service, "record_activity", side_effect=Exception("down")
+    )
+    @patch.object(controller.db_service, "update_transaction")
+    def test_update_survives_activity_failure(self, mock_update, __):
+        mock_update.return_value = self.stored
+        self.req.get_json = MagicMock(return_value={"category": "Groceries"})
+        resp = controller.handle_transaction(self.req)
+        self.assertEqual(resp.status_code, 200)
+
+    def test_update_rejects_unknown_category(self):
+        self.req.get_json = MagicMock(return_value={"category": "Loans"})
+        resp = controller.handle_transaction(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    def test_update_requires_fields(self):
+        self.req.get_json = MagicMock(return_value={})
+        resp = controller.handle_transaction(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    @patch.object(controller.db_service, "save_undo_operation")
+    @patch.object(controller.db_service, "record_activity")
+    @patch.object(controller.db_service, "delete_transaction")
+    def test_delete(self, mock_delete, _, mock_undo):
+        mock_delete.return_value = self.stored
+        self.req.method = "DELETE"
+        resp = controller.handle_transaction(self.req)
+        self.assertEqual(resp.status_code, 204)
+        mock_delete.assert_called_once_with("abc")
+        # The deleted row is kept so the delete can be undone
+        operation = mock_undo.call_args[0][0]
+        self.assertEqual(resp.headers["x-undo-id"], operation["id"])
+        self.assertEqual(operation["inverse"], {"restore": [self.stored]})
+        # and its charge comes off the card
+        self.mock_adjust.assert_called_once_with("a@test.com", "visa", Decimal("-10.0"))
+
+    @patch.object(controller.db_service, "delete_transaction", return_value=None)
+    def test_delete_unknown_transaction(self, _):
+        self.req.method = "DELETE"
+        resp = controller.handle_transaction(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+
+class TestTransactionsBulkEdit(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", method="POST")
+        keyed = [
+            (
+                row_key,
+                Transaction(
+                    date(2025, 1, 5),
+                    name,
+                    1,
+                    Decimal(amount),
+                    category,
+                    IgnoredFrom.NOTHING,
+                ),
+            )
+            for row_key, name, amount, category in [
+                ("r1", "COSTCO", "120.00", Category.OTHER),
+                ("r2", "COSTCO", "40.00", Category.GROCERIES),
+                ("r3", "CAFE", "8.00", Category.DINING),
+            ]
+        ]
+        people = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+            {"Name": "B", "Email": "b@test.com", "Accounts": [2]},
+        ]
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_keyed_transactions",
+                side_effect=lambda month: keyed if month == "2025-01" else [],
+            ),
+            patch.object(
+                controller.db_service,
+                "update_transactions",
+                side_effect=lambda month, changes: len(changes),
+            ),
+            patch.object(controller.db_service, "get_all_people", return_value=people),
+            patch.object(controller.db_service, "record_activity"),
+            patch.object(controller.db_service, "save_undo_operation"),
+            patch.object(
+                controller.db_service, "get_manual_category_ids", return_value=set()
+            ),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_update = mocks[1]
+        self.mock_activity = mocks[3]
+        self.mock_undo = mocks[4]
+        self.mock_manual = mocks[5]
+
+    def _edit(self, body):
+        self.req.get_json = MagicMock(return_value=body)
+        resp = controller.handle_transactions_bulk_edit(self.req)
+        return resp, json.loads(resp.get_body()) if resp.status_code == 200 else None
+
+    def test_dry_run_previews_counts(self):
+        resp, payload = self._edit(
+            {
+                "months": ["2025-01", "2025-02"],
+                "filter": "merchant:costco",
+                "changes": {"category": "Groceries"},
+                "dryRun": True,
+            }
+        )
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertTrue(payload["dryRun"])
+        # Both Costco rows match; only the one not yet in Groceries changes
+        self.assertEqual((payload["matched"], payload["changed"]), (2, 1))
+        self.assertEqual(payload["updated"], 0)
+        self.assertIsNone(payload["undoId"])
+        self.assertTrue(payload["undoable"])
+        self.mock_update.assert_not_called()
+        self.mock_activity.assert_not_called()
+        self.mock_undo.assert_not_called()
+
+    def test_edit_by_filter(self):
+        resp, payload = self._edit(
+            {
+                "months": ["2025-01"],
+                "filter": "merchant:costco",
+                "changes": {"category": "Groceries", "ignore": "budget"},
+            }
+        )
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual((payload["matched"], payload["updated"]), (2, 2))
+        self.mock_update.assert_called_once_with(
+            "2025-01",
+            {
+                "r1": {
+                    "Category": "Groceries",
+                    "IgnoredFrom": "budget",
+                    "CategorySource": "manual",
+                },
+                "r2": {"IgnoredFrom": "budget"},
+            },
+        )
+        kind, _, actor, details = self.mock_activity.call_args[0]
+        self.assertEqual((kind, actor), ("edit", "a@test.com"))
+        self.assertEqual(details["months"], ["2025-01"])
+        # What each row had is kept so the edit can be undone
+        operation, _ = self.mock_undo.call_args[0]
+        self.assertEqual(payload["undoId"], operation["id"])
+        self.assertEqual(
+            operation["inverse"],
+            {
+                "updates": [
+                    {
+                        "month": "2025-01",
+                        "properties": {
+                            "Category": "Other",
+                            "CategorySource": "",
+                            "IgnoredFrom": "",
+                        },
+                        # What undo expects the rows to still hold
+                        "after": {
+                            "Category": "Groceries",
+                            "IgnoredFrom": "budget",
+                            "CategorySource": "manual",
+                        },
+                        "ids": ["r1"],
+                    },
+                    {
+                        "month": "2025-01",
+                        "properties": {"IgnoredFrom": ""},
+                        "after": {"IgnoredFrom": "budget"},
+                        "ids": ["r2"],
+                    },
+                ]
+            },
+        )
+
+    @patch("rmanalyzer.controller.MAX_UNDO_INVERSE_LENGTH", 50)
+    def test_dry_run_warns_when_too_large_to_undo(self):
+        resp, payload = self._edit(
+            {
+                "months": ["2025-01"],
+                "filter": "merchant:costco",
+                "changes": {"category": "Groceries"},
+                "dryRun": True,
+            }
+        )
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertFalse(payload["undoable"])
+
+    def test_undo_keeps_a_previous_manual_category(self):
+        self.mock_manual.return_value = {"r1"}
+
+        self._edit(
+            {
+                "months": ["2025-01"],
+                "ids": ["r1"],
+                "changes": {"category": "Groceries"},
+            }
+        )
+
+        operation, _ = self.mock_undo.call_args[0]
+        (update,) = operation["inverse"]["updates"]
+        self.assertEqual(
+            update["properties"], {"Category": "Other", "CategorySource": "manual"}
+        )
+
+    @patch.dict(os.environ, {"ADMIN_EMAILS": "a@test.com"})
+    def test_edit_by_ids(self):
+        resp, payload = self._edit(
+            {
+                "months": ["2025-01"],
+                "ids": ["r3", "gone"],
+                "changes": {"owner": "B@test.com"},
+            }
+        )
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(payload["matched"], 1)
+        self.assertEqual(payload["missing"], ["gone"])
+        self.mock_update.assert_called_once_with(
+            "2025-01", {"r3": {"Owner": "B@test.com"}}
+        )
+
+    @patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+    def test_only_admins_change_owners(self):
+        resp, _ = self._edit(
+            {"months": ["2025-01"], "ids": ["r3"], "changes": {"owner": "b@test.com"}}
+        )
+
+        self.assertEqual(resp.status_code, 403)
+        self.mock_update.assert_not_called()
+
+    def test_months_are_capped(self):
+        resp, _ = self._edit(
+            {
+                "months": ["2025-01"] * 25,
+                "ids": ["r1"],
+                "changes": {"category": "Groceries"},
+            }
+        )
+
+        self.assertEqual(resp.status_code, 400)
+        (error,) = json.loads(resp.get_body())["fields"]
+        self.assertEqual(error["field"], "months")
+        self.assertIn("at most 24 items", error["message"])
+
+    @patch.dict(os.environ, {"ADMIN_EMAILS": "a@test.com"})
+    def test_invalid_bulk_edit(self):
+        for body, field in [
+            ({"months": ["2025-01"], "changes": {"category": "Groceries"}}, "body"),
+            (
+                {
+                    "months": ["2025-01"],
+                    "ids": ["r1"],
+                    "filter": "amount>1",
+                    "changes": {"category": "Groceries"},
+                },
+                "body",
+            ),
+            ({"months": ["2025-01"], "ids": ["r1"], "changes": {}}, "changes"),
+            (
+                {"months": ["2025-01"], "ids": ["r1"], "changes": {"tags": ["x"]}},
+                "changes",
+            ),
+            (
+                {"months": ["2025-01"], "ids": ["r1"], "changes": {"ignore": "some"}},
+                "changes.ignore",
+            ),
+            (
+                {"months": ["2025-01"], "ids": ["r1"], "changes": {"owner": "z@x"}},
+                "changes.owner",
+            ),
+            (
+                {"months": ["2025-01"], "filter": "amt>1", "changes": {"owner": ""}},
+                "filter",
+            ),
+        ]:
+            resp, _ = self._edit(body)
+            self.assertEqual(resp.status_code, 400, body)
+            self.assertEqual(json.loads(resp.get_body())["fields"][0]["field"], field)
+        self.mock_update.assert_not_called()
+
+
+class TestUndoController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request(
+            "a@test.com",
+            method="POST",
+            route_params={"operationId": "op1"},
+        )
+
+        now = datetime.now()
+        self.restored = {
+            "PartitionKey": "p",
+            "RowKey": "r9",
+            "AccountNumber": 1234,
+            "Amount": 20.0,
+        }
+        self.operations = [
+            {
+                "id": "op2",
+                "kind": "delete",
+                "summary": "Deleted a transaction",
+                "actor": "a@test.com",
+                "createdAt": (now - timedelta(minutes=1)).isoformat(),
+                "undoneAt": None,
+                "inverse": {"restore": [self.restored]},
+            },
+            {
+                "id": "op1",
+                "kind": "bulk-edit",
+                "summary": "Bulk edited 2 transactions' category",
+                "actor": "a@test.com",
+                "createdAt": (now - timedelta(minutes=5)).isoformat(),
+                "undoneAt": None,
+                "inverse": {
+                    "updates": [
+                        {
+                            "month": "2025-01",
+                            "properties": {"Category": "Other"},
+                            "after": {"Category": "Groceries"},
+                            "ids": ["r1", "r2"],
+                        }
+                    ]
+                },
+            },
+        ]
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_undo_operations",
+                side_effect=lambda: self.operations,
+            ),
+            patch.object(
+                controller.db_service,
+                "update_transactions",
+                side_effect=lambda month, changes, expected=None: len(changes),
+            ),
+            patch.object(
+                controller.db_service,
+                "restore_transactions",
+                side_effect=len,
+            ),
+            patch.object(
+                controller.db_service,
+                "mark_undone",
+                side_effect=lambda i: next(
+                    o for o in self.operations if o["id"] == i
+                ).update(undoneAt=datetime.now().isoformat()),
+            ),
+            patch.object(controller.db_service, "record_activity"),
+            patch.object(
+                controller.db_service,
+                "get_all_people",
+                return_value=[{"Email": "a@test.com", "Accounts": [1234]}],
+            ),
+            patch.object(
+                controller.db_service,
+                "get_accounts",
+                return_value=[{"accountId": "card", "mask": "1234"}],
+            ),
+            patch.object(controller.db_service, "adjust_account_balance"),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_update = mocks[1]
+        self.mock_restore = mocks[2]
+
+    def _undo(self, operation_id):
+        self.req.route_params = {"operationId": operation_id}
+        return controller.handle_undo(self.req)
+
+    def test_undo_newest_first(self):
+        # op1 was followed by op2, which is still in effect
+        self.assertEqual(self._undo("op1").status_code, 409)
+        self.mock_update.assert_not_called()
+
+        resp = self._undo("op2")
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(json.loads(resp.get_body())["restored"], 1)
+        self.mock_restore.assert_called_with([self.restored])
+        # The restored charge goes back on its card
+        controller.db_service.adjust_account_balance.assert_called_once_with(
+            "a@test.com", "card", Decimal("20.0")
+        )
+
+        resp = self._undo("op1")
+        self.assertEqual(resp.status_code, 200)
+        self.mock_update.assert_called_once_with(
+            "2025-01",
+            {"r1": {"Category": "Other"}, "r2": {"Category": "Other"}},
+            expected={"Category": "Groceries"},
+        )
+
+        # Nothing is undone twice
+        self.assertEqual(self._undo("op1").status_code, 409)
+
+    def test_undo_window(self):
+        self.operations[0]["createdAt"] = (
+            datetime.now() - timedelta(minutes=45)
+        ).isoformat()
+        self.assertEqual(self._undo("op2").status_code, 410)
+
+        with patch.dict(os.environ, {"UNDO_WINDOW_MINUTES": "60"}):
+            self.assertEqual(self._undo("op2").status_code, 200)
+
+    def test_other_members_changes_dont_block_undo(self):
+        self.operations[0]["actor"] = "b@test.com"
+
+        resp = self._undo("op1")
+
+        self.assertEqual(resp.status_code, 200)
+        self.mock_restore.assert_called_once_with([])
+
+    def test_rows_changed_since_are_skipped(self):
+        self.operations[0]["undoneAt"] = datetime.now().isoformat()
+        # r2 was recategorized since, so it no longer holds what op1 set
+        self.mock_update.side_effect = lambda month, changes, expected=None: 1
+
+        payload = json.loads(self._undo("op1").get_body())
+
+        self.assertEqual((payload["restored"], payload["skipped"]), (1, 1))
+
+    def test_undo_is_for_the_member_who_made_the_change(self):
+        sign_in(self.req, "b@test.com")
+        self.assertEqual(self._undo("op2").status_code, 404)
+        self.assertEqual(self._undo("missing").status_code, 404)
+
+
+class TestActivityController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com")
+
+    @patch.object(controller.db_service, "get_activity")
+    def test_activity(self, mock_get):
+        mock_get.return_value = ([{"id": "1", "kind": "import"}], "1")
+        self.req.params = {"limit": "500", "kind": "import"}
+
+        resp = controller.handle_activity(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        mock_get.assert_called_once_with(200, None, "import")
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["cursor"], "1")
+        self.assertEqual(payload["events"][0]["kind"], "import")
+
+    def test_rejects_malformed_cursor(self):
+        self.req.params = {"cursor": "' or true"}
+        resp = controller.handle_activity(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    def test_rejects_unknown_kind(self):
+        self.req.params = {"kind": "login"}
+        resp = controller.handle_activity(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+
+class TestTransactionOwnerController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", route_params={"id": "abc"})
+        people = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+            {"Name": "B", "Email": "b@test.com", "Accounts": [2]},
+        ]
+        patcher = patch.object(
+            controller.db_service, "get_all_people", return_value=people
+        )
+        patcher.start()
+        self.addCleanup(patcher.stop)
+        self.stored = {"AccountNumber": 1, "Owner": ""}
+        patcher = patch.object(
+            controller.db_service,
+            "set_transaction_owner",
+            side_effect=self._set_owner,
+        )
+        self.mock_set = patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def _set_owner(self, transaction_id, owner, check=None):
+        if self.stored is None:
+            return False
+        check(self.stored)
+        return True
+
+    def test_set_owner(self):
+        self.req.get_json = MagicMock(return_value={"owner": "b@test.com"})
+        resp = controller.handle_transaction_owner(self.req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(self.mock_set.call_args.args, ("abc", "b@test.com"))
+
+    def test_clear_owner(self):
+        self.stored["Owner"] = "a@test.com"
+        self.req.get_json = MagicMock(return_value={"owner": None})
+        resp = controller.handle_transaction_owner(self.req)
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(self.mock_set.call_args.args, ("abc", None))
+
+    def test_owner_must_be_member(self):
+        self.req.get_json = MagicMock(return_value={"owner": "x@test.com"})
+        resp = controller.handle_transaction_owner(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.mock_set.assert_not_called()
+
+    def test_only_current_owner_can_reassign(self):
+        self.stored = {"AccountNumber": 2, "Owner": ""}
+        self.req.get_json = MagicMock(return_value={"owner": "a@test.com"})
+        resp = controller.handle_transaction_owner(self.req)
+        self.assertEqual(resp.status_code, 403)
+
+    def test_reassigned_owner_replaces_account_holder(self):
+        self.stored["Owner"] = "b@test.com"
+        self.req.get_json = MagicMock(return_value={"owner": "a@test.com"})
+        resp = controller.handle_transaction_owner(self.req)
+        self.assertEqual(resp.status_code, 403)
+
+    @patch.dict(os.environ, {"ADMIN_EMAILS": "a@test.com"})
+    def test_admin_can_reassign_any(self):
+        self.stored = {"AccountNumber": 2, "Owner": ""}
+        self.req.get_json = MagicMock(return_value={"owner": "a@test.com"})
+        resp = controller.handle_transaction_owner(self.req)
+        self.assertEqual(resp.status_code, 200)
+
+    def test_unknown_transaction(self):
+        self.stored = None
+        self.req.get_json = MagicMock(return_value={"owner": "b@test.com"})
+        resp = controller.handle_transaction_owner(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+
+if __name__ == "__main__":
+    unittest.main()
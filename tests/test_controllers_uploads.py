@@ -0,0 +1,370 @@
+"""
+Tests for upload controller logic.
+"""
+
+import json
+import unittest
+from datetime import date
+from decimal import Decimal
+from unittest.mock import MagicMock, patch
+
+from fixtures import http_request, start_patches
+from rmanalyzer.controller import controller
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.rules import Rule
+
+
+class TestFailuresController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", route_params={"id": "f1"})
+        self.failure = {
+            "id": "f1",
+            "message": {"blob_name": "a.csv", "format": "csv", "failure_id": "f0"},
+            "status": "pending",
+        }
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_failure",
+                side_effect=lambda _: self.failure,
+            ),
+            patch.object(controller.db_service, "set_failure_status"),
+            patch.object(controller.db_service, "record_failure"),
+            patch.object(controller.queue_service, "resubmit"),
+            patch.object(controller.db_service, "set_upload_status"),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_status, self.mock_record, self.mock_resubmit = mocks[1:4]
+        self.mock_upload_status = mocks[4]
+
+    def test_retry(self):
+        resp = controller.handle_failure_retry(self.req)
+
+        self.assertEqual(resp.status_code, 202)
+        self.mock_resubmit.assert_called_once_with(
+            {"blob_name": "a.csv", "format": "csv"}, "f1"
+        )
+        self.mock_status.assert_called_once_with("f1", "retried")
+        self.mock_upload_status.assert_called_once_with("a.csv", "queued", errors=[])
+
+    def test_retry_conflicts_once_retried(self):
+        self.failure["status"] = "retried"
+        resp = controller.handle_failure_retry(self.req)
+        self.assertEqual(resp.status_code, 409)
+        self.mock_resubmit.assert_not_called()
+
+    def test_retry_unknown_failure(self):
+        self.failure = None
+        resp = controller.handle_failure_retry(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+    def _message(self, body, dequeue_count):
+        msg = MagicMock()
+        msg.id = "m1"
+        msg.dequeue_count = dequeue_count
+        msg.get_body.return_value = json.dumps(body).encode("utf-8")
+        return msg
+
+    @patch.object(controller, "_process_upload", side_effect=RuntimeError("boom"))
+    def test_last_failed_attempt_is_recorded(self, _):
+        msg = self._message({"blob_name": "a.csv"}, 4)
+        with self.assertRaises(RuntimeError):
+            controller.process_queue_item(msg)
+        self.mock_record.assert_not_called()
+
+        msg = self._message({"blob_name": "a.csv"}, 5)
+        with self.assertRaises(RuntimeError):
+            controller.process_queue_item(msg)
+        self.mock_record.assert_called_once_with("m1", {"blob_name": "a.csv"}, "boom")
+
+    @patch.object(controller, "_process_upload", side_effect=RuntimeError("boom"))
+    def test_upload_status_follows_attempts(self, _):
+        with self.assertRaises(RuntimeError):
+            controller.process_queue_item(self._message({"blob_name": "a.csv"}, 1))
+        self.mock_upload_status.assert_any_call("a.csv", "processing")
+        self.mock_upload_status.assert_called_with("a.csv", "queued", errors=["boom"])
+
+        with self.assertRaises(RuntimeError):
+            controller.process_queue_item(self._message({"blob_name": "a.csv"}, 5))
+        self.mock_upload_status.assert_called_with("a.csv", "failed", errors=["boom"])
+
+    @patch.object(controller.queue_service, "discard_message")
+    @patch.object(controller, "_process_upload", return_value=(0, ["Row 1: bad"]))
+    def test_upload_with_no_valid_rows_fails(self, _, __):
+        controller.process_queue_item(self._message({"blob_name": "a.csv"}, 1))
+        self.mock_upload_status.assert_called_with(
+            "a.csv", "failed", rows_imported=0, rows_skipped=1, errors=["Row 1: bad"]
+        )
+
+    @patch.object(controller.db_service, "get_upload")
+    def test_upload_status(self, mock_get):
+        mock_get.return_value = {"blobName": "a.csv", "status": "completed"}
+        self.req.route_params = {"blobName": "a.csv"}
+
+        resp = controller.handle_upload_status(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(json.loads(resp.get_body())["status"], "completed")
+        mock_get.assert_called_once_with("a.csv")
+
+        mock_get.return_value = None
+        resp = controller.handle_upload_status(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+    @patch.object(controller.db_service, "get_uploads", return_value=[])
+    def test_list_uploads(self, mock_get):
+        self.req.params = {"limit": "500"}
+        resp = controller.handle_uploads(self.req)
+        self.assertEqual(resp.status_code, 200)
+        mock_get.assert_called_once_with(200)
+
+    @patch.object(controller.queue_service, "discard_message")
+    @patch.object(controller, "_process_upload", return_value=(3, []))
+    def test_successful_retry_is_recorded(self, _, __):
+        controller.process_queue_item(
+            self._message({"blob_name": "a.csv", "failure_id": "f1"}, 1)
+        )
+        self.mock_status.assert_called_once_with("f1", "succeeded")
+        self.mock_upload_status.assert_called_with(
+            "a.csv", "completed", rows_imported=3, rows_skipped=0, errors=[]
+        )
+
+    @patch.object(controller, "_process_upload", side_effect=RuntimeError("boom"))
+    def test_failed_retry_is_recorded(self, _):
+        msg = self._message({"blob_name": "a.csv", "failure_id": "f1"}, 5)
+        with self.assertRaises(RuntimeError):
+            controller.process_queue_item(msg)
+        self.mock_status.assert_called_once_with("f1", "failed", "boom")
+        self.mock_record.assert_not_called()
+
+
+class TestUploadDedupe(unittest.TestCase):
+    def setUp(self):
+        patchers = [
+            patch.object(
+                controller.blob_service, "download_blob", return_value=b"Date,Name\n"
+            ),
+            patch.object(controller.db_service, "get_processing_log"),
+            patch.object(controller.db_service, "log_processing"),
+            patch.object(controller, "_import_upload", return_value=(2, [])),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_download, self.mock_get_log, self.mock_log = mocks[:3]
+        self.mock_import = mocks[3]
+
+    def test_redelivered_blob_is_skipped(self):
+        self.mock_get_log.return_value = {
+            "status": "completed",
+            "rowsImported": 2,
+            "errors": ["Row 3: bad"],
+        }
+
+        self.assertEqual(controller._process_upload("a.csv"), (2, ["Row 3: bad"]))
+        self.mock_import.assert_not_called()
+        self.mock_log.assert_not_called()
+
+    def test_new_or_interrupted_blob_is_processed_and_logged(self):
+        for log in (None, {"status": "processing", "rowsImported": None}):
+            self.mock_get_log.return_value = log
+            self.mock_log.reset_mock()
+
+            self.assertEqual(controller._process_upload("a.csv"), (2, []))
+
+            content_hash = self.mock_get_log.call_args[0][1]
+            self.assertEqual(len(content_hash), 64)
+            self.assertEqual(
+                [c[0][2] for c in self.mock_log.call_args_list],
+                ["processing", "completed"],
+            )
+
+    def test_xlsx_is_imported_as_bytes(self):
+        self.mock_download.return_value = b"PK\x03"
+        self.mock_get_log.return_value = None
+
+        controller._process_upload("a.xlsx", "xlsx")
+
+        self.mock_download.assert_called_once()
+        self.assertEqual(self.mock_import.call_args[0][1], b"PK\x03")
+
+    def test_format_detected_when_not_recorded(self):
+        self.mock_download.return_value = b"!Type:Bank\nD1/15/2025\n^\n"
+        self.mock_get_log.return_value = None
+
+        controller._process_upload("20250115093000_export.txt")
+
+        self.assertEqual(self.mock_import.call_args[0][3], "qif")
+
+
+
+    def test_summaries_already_sent_are_passed_on(self):
+        self.mock_get_log.return_value = {
+            "status": "processing",
+            "sentEmails": {"a@test.com": "m1"},
+        }
+
+        controller._process_upload("a.csv")
+
+        self.assertEqual(
+            self.mock_import.call_args.kwargs["sent_emails"], {"a@test.com": "m1"}
+        )
+
+
+class TestUploadSummaryRetry(unittest.TestCase):
+    def setUp(self):
+        transactions = [
+            Transaction(
+                date(2025, 3, 2),
+                "CITY POWER",
+                1,
+                Decimal("80.00"),
+                Category.BILLS,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+        people = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+            {"Name": "B", "Email": "b@test.com", "Accounts": [2]},
+        ]
+        patchers = [
+            patch(
+                "rmanalyzer.controller.statement.parse",
+                return_value=(transactions, []),
+            ),
+            patch.object(
+                controller.exchange_rates,
+                "normalize",
+                side_effect=lambda rows: (rows, []),
+            ),
+            patch.object(controller.db_service, "get_rules", return_value=[]),
+            patch.object(controller.db_service, "get_all_people", return_value=people),
+            patch.object(controller.db_service, "save_transactions"),
+            patch.object(
+                controller.db_service,
+                "apply_owner_overrides",
+                side_effect=lambda rows: rows,
+            ),
+            patch.object(controller.db_service, "log_processing"),
+            patch.object(controller, "_record_activity"),
+            patch.object(controller, "_record_account_imports"),
+            patch.object(controller, "_record_import_debt", return_value=None),
+            patch.object(controller, "_outstanding_balance", return_value=None),
+            patch.object(controller, "_summary_attachments", return_value=[]),
+            patch.object(controller, "_suggest_savings_transfer"),
+            patch.object(controller.email_service, "send_emails"),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_log, self.mock_send = mocks[6], mocks[13]
+
+    def test_members_already_emailed_are_skipped_and_sends_logged(self):
+        controller._import_upload(
+            "a.csv", b"", "hash", "csv", sent_emails={"a@test.com": "m1"}
+        )
+
+        messages = self.mock_send.call_args[0][0]
+        self.assertEqual([to for to, _, _ in messages], [["b@test.com"]])
+
+        self.mock_send.call_args.kwargs["on_delivered"](["b@test.com"], "m2")
+        self.assertEqual(
+            self.mock_log.call_args.kwargs["sent_emails"],
+            {"a@test.com": "m1", "b@test.com": "m2"},
+        )
+
+
+class TestUploadReprocess(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", route_params={"blobName": "a.csv"})
+        self.parsed = [
+            Transaction(
+                date(2025, 3, 2),
+                "CITY POWER",
+                1,
+                Decimal("80.00"),
+                Category.OTHER,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_upload",
+                return_value={"status": "completed", "format": "csv"},
+            ),
+            patch.object(controller.blob_service, "download_blob", return_value=b""),
+            patch(
+                "rmanalyzer.controller.statement.parse",
+                return_value=(self.parsed, ["Row 3: bad"]),
+            ),
+            patch.object(
+                controller.exchange_rates, "normalize", side_effect=lambda ts: (ts, [])
+            ),
+            patch.object(
+                controller.db_service,
+                "get_rules",
+                return_value=[Rule("r1", Category.BILLS, contains="power")],
+            ),
+            patch.object(
+                controller.db_service,
+                "recategorize_transactions",
+                return_value={"matched": 1, "updated": 1, "locked": 0, "missing": 0},
+            ),
+            patch.object(controller, "_record_activity"),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_get_upload, self.mock_recategorize = mocks[0], mocks[5]
+
+    @patch.object(controller.db_service, "save_transactions")
+    @patch.object(controller.email_service, "send_emails")
+    def test_reprocess_only_updates_categories(self, mock_send, mock_save):
+        resp = controller.handle_upload_reprocess(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            json.loads(resp.get_body()),
+            {
+                "blobName": "a.csv",
+                "parsed": 1,
+                "matched": 1,
+                "updated": 1,
+                "locked": 0,
+                "missing": 0,
+                "errors": ["Row 3: bad"],
+            },
+        )
+        (recategorized,), override = self.mock_recategorize.call_args[0]
+        self.assertEqual(recategorized.category, Category.BILLS)
+        self.assertFalse(override)
+        mock_save.assert_not_called()
+        mock_send.assert_not_called()
+
+    def test_override_param(self):
+        for value, override in (("false", False), ("true", True)):
+            self.req.params = {"override": value}
+            resp = controller.handle_upload_reprocess(self.req)
+            self.assertEqual(resp.status_code, 200)
+            self.assertEqual(self.mock_recategorize.call_args[0][1], override)
+
+        self.mock_recategorize.reset_mock()
+        self.req.params = {"override": "yes"}
+        resp = controller.handle_upload_reprocess(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.mock_recategorize.assert_not_called()
+
+    def test_unknown_upload(self):
+        self.mock_get_upload.return_value = None
+        resp = controller.handle_upload_reprocess(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+    def test_upload_not_processed_yet(self):
+        self.mock_get_upload.return_value = {"status": "queued", "format": "csv"}
+        resp = controller.handle_upload_reprocess(self.req)
+        self.assertEqual(resp.status_code, 409)
+        self.mock_recategorize.assert_not_called()
+
+    def test_unauthorized(self):
+        self.req.headers = {}
+        resp = controller.handle_upload_reprocess(self.req)
+        self.assertEqual(resp.status_code, 401)
+
+
+if __name__ == "__main__":
+    unittest.main()
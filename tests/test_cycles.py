@@ -0,0 +1,39 @@
+"""
+Tests for card statement cycles.
+"""
+
+import unittest
+from datetime import date
+
+from rmanalyzer.cycles import cycle_start, last_close_date, statement_due
+
+
+class TestCycles(unittest.TestCase):
+    def test_last_close_date(self):
+        self.assertEqual(last_close_date(20, date(2025, 3, 25)), date(2025, 3, 20))
+        self.assertEqual(last_close_date(20, date(2025, 3, 20)), date(2025, 3, 20))
+        self.assertEqual(last_close_date(20, date(2025, 1, 5)), date(2024, 12, 20))
+
+    def test_close_day_clamped_to_short_months(self):
+        self.assertEqual(last_close_date(31, date(2025, 3, 5)), date(2025, 2, 28))
+        self.assertEqual(last_close_date(31, date(2025, 2, 28)), date(2025, 2, 28))
+
+    def test_cycle_start(self):
+        self.assertEqual(cycle_start(20, date(2025, 3, 20)), date(2025, 2, 21))
+        self.assertEqual(cycle_start(31, date(2025, 3, 31)), date(2025, 3, 1))
+        self.assertEqual(cycle_start(5, date(2025, 1, 5)), date(2024, 12, 6))
+
+    def test_first_statement_only_on_close_day(self):
+        self.assertEqual(statement_due(20, None, date(2025, 3, 20)), date(2025, 3, 20))
+        self.assertIsNone(statement_due(20, None, date(2025, 3, 21)))
+
+    def test_catches_up_on_missed_close(self):
+        self.assertEqual(
+            statement_due(20, date(2025, 2, 20), date(2025, 3, 22)),
+            date(2025, 3, 20),
+        )
+        self.assertIsNone(statement_due(20, date(2025, 3, 20), date(2025, 3, 22)))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -79,8 +79,11 @@ class TestDBConfig(unittest.TestCase):
         client2 = service._get_table_client("test_table")
 
         self.assertIs(client1, client2)
-        # Should only be called once (created once)
-        mock_table_client.assert_called_once()
+        # Should only be created once, alongside the settings table its route is in
+        tables = [c.kwargs["table_name"] for c in mock_table_client.call_args_list]
+        self.assertEqual(
+            sorted(tables), sorted(["test_table", service._settings_table])
+        )
 
 
 if __name__ == "__main__":
@@ -1,11 +1,37 @@
+import dataclasses
+import json
 import os
 import unittest
-from datetime import date
+from datetime import date, datetime
 from decimal import Decimal
-from unittest.mock import MagicMock, patch
+from unittest.mock import MagicMock, call, patch
 
-from rmanalyzer.services import DatabaseService
-from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from azure.core.exceptions import (
+    ResourceExistsError,
+    ResourceModifiedError,
+    ResourceNotFoundError,
+)
+from azure.data.tables import TableTransactionError, UpdateMode
+
+from rmanalyzer.services import (
+    ConcurrencyRetrier,
+    DatabaseService,
+    table_routes_scope,
+)
+from rmanalyzer.models import (
+    AddedCategory,
+    Category,
+    IgnoredFrom,
+    Transaction,
+)
+
+
+class _Entity(dict):
+    """A table entity as the SDK returns it, with its ETag."""
+
+    def __init__(self, fields, etag='W/"1"'):
+        super().__init__(fields)
+        self.metadata = {"etag": etag}
 
 
 class TestDB(unittest.TestCase):
@@ -82,6 +108,1271 @@ class TestDB(unittest.TestCase):
         self.assertEqual(entity["Description"], "Grocery Store")
         self.assertEqual(entity["Amount"], 50.0)
 
+    def test_save_transactions_writes_partitions_concurrently(self):
+        """Test that every partition's batches are submitted, in order per partition."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        transactions = [
+            Transaction(
+                date=date(2023, month, 1),
+                name=f"Store {i}",
+                account_number=5678,
+                amount=Decimal("1.0"),
+                category=Category.GROCERIES,
+                ignore=IgnoredFrom.NOTHING,
+            )
+            for month in range(1, 4)
+            for i in range(150)
+        ]
+
+        self.db_service.save_transactions(transactions)
+
+        batches = [c[0][0] for c in mock_client.submit_transaction.call_args_list]
+        self.assertEqual(len(batches), 6)
+        self.assertEqual(sum(len(b) for b in batches), 450)
+        for month in ("2023-01", "2023-02", "2023-03"):
+            sizes = [
+                len(b) for b in batches if b[0][1]["PartitionKey"] == f"default_{month}"
+            ]
+            self.assertEqual(sizes, [100, 50])
+
+    def test_save_transactions_failed_batch_spares_other_partitions(self):
+        """Test that a rejected batch doesn't stop other partitions saving."""
+        mock_client = MagicMock()
+        mock_client.submit_transaction.side_effect = [TableTransactionError(), None]
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        self.db_service._save_workers = 1
+        transactions = [
+            Transaction(
+                date=date(2023, month, 1),
+                name="Store",
+                account_number=5678,
+                amount=Decimal("1.0"),
+                category=Category.GROCERIES,
+                ignore=IgnoredFrom.NOTHING,
+            )
+            for month in (1, 2)
+        ]
+
+        self.db_service.save_transactions(transactions)
+
+        self.assertEqual(mock_client.submit_transaction.call_count, 2)
+
+    def test_iter_transactions_queries_months_lazily(self):
+        """Test that each month is only queried once the previous one is read."""
+        mock_client = MagicMock()
+        mock_client.query_entities.return_value = iter([])
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        transactions = self.db_service.iter_transactions(["2023-10", "2023-11"])
+        mock_client.query_entities.assert_not_called()
+
+        self.assertEqual(list(transactions), [])
+        filters = [
+            c.kwargs["query_filter"] for c in mock_client.query_entities.call_args_list
+        ]
+        self.assertEqual(
+            filters,
+            ["PartitionKey eq 'default_2023-10'", "PartitionKey eq 'default_2023-11'"],
+        )
+
+    def test_prefix_filter(self):
+        """Test that prefix filters bound the PartitionKey range."""
+        self.assertEqual(
+            self.db_service._prefix_filter("default_"),
+            "PartitionKey ge 'default_' and PartitionKey lt 'default`'",
+        )
+
+    def test_delete_entities_batches_by_partition(self):
+        """Test that deletes are grouped per partition."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        keys = [
+            {"PartitionKey": "default_2023-10", "RowKey": "a"},
+            {"PartitionKey": "default_2023-10", "RowKey": "b"},
+            {"PartitionKey": "default_2023-11", "RowKey": "c"},
+        ]
+
+        deleted = self.db_service.delete_entities("transactions", keys)
+
+        self.assertEqual(deleted, 3)
+        self.assertEqual(mock_client.submit_transaction.call_count, 2)
+        first_batch = mock_client.submit_transaction.call_args_list[0][0][0]
+        self.assertEqual([op[0] for op in first_batch], ["delete", "delete"])
+
+    def test_get_transactions_round_trip(self):
+        """Test that stored entities are converted back into transactions."""
+        t = Transaction(
+            date=date(2023, 10, 5),
+            name="Cafe",
+            account_number=1234,
+            amount=Decimal("12.30"),
+            category=Category.DINING,
+            ignore=IgnoredFrom.NOTHING,
+        )
+        self.db_service.category_names = MagicMock(return_value=[])
+        entity = self.db_service._create_transaction_entity(
+            t, "default_2023-10", "r1", "now"
+        )
+        mock_client = MagicMock()
+        mock_client.query_entities.return_value = [entity]
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        result = self.db_service.get_transactions("2023-10")
+
+        self.assertEqual(result, [t])
+        mock_client.query_entities.assert_called_once_with(
+            query_filter="PartitionKey eq 'default_2023-10'"
+        )
+
+    def test_foreign_currency_round_trip(self):
+        """Test that a converted transaction keeps its original amount and currency."""
+        t = Transaction(
+            date=date(2023, 10, 5),
+            name="Hotel",
+            account_number=1234,
+            amount=Decimal("108.70"),
+            category=Category.TRAVEL,
+            ignore=IgnoredFrom.NOTHING,
+            currency="EUR",
+            original_amount=Decimal("100.00"),
+        )
+        entity = self.db_service._create_transaction_entity(
+            t, "default_2023-10", "r1", "now"
+        )
+        self.assertEqual(entity["Currency"], "EUR")
+        self.assertEqual(entity["OriginalAmount"], 100.0)
+
+        self.assertEqual(self.db_service._entity_to_transaction(entity), t)
+        self.assertFalse(entity["FxPending"])
+
+    def test_unconverted_transactions(self):
+        """Test that a transaction saved unconverted is flagged, then converted."""
+        t = Transaction(
+            date=date(2023, 10, 5),
+            name="Hotel",
+            account_number=1234,
+            amount=Decimal("100.00"),
+            category=Category.TRAVEL,
+            ignore=IgnoredFrom.NOTHING,
+            currency="EUR",
+        )
+        self.db_service.category_names = MagicMock(return_value=[])
+        entity = self.db_service._create_transaction_entity(
+            t, "default_2023-10", "r1", "now"
+        )
+        self.assertTrue(entity["FxPending"])
+        self.assertNotIn("OriginalAmount", entity)
+        converted = dataclasses.replace(
+            t, amount=Decimal("108.70"), original_amount=Decimal("100.00")
+        )
+        # Keyed on what was charged, so converting doesn't change the key
+        self.assertEqual(
+            self.db_service._generate_row_key(t),
+            self.db_service._generate_row_key(converted),
+        )
+
+        mock_client = MagicMock()
+        mock_client.query_entities.return_value = [entity]
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        self.assertEqual(
+            self.db_service.get_unconverted_transactions(), [("r1", t)]
+        )
+        self.assertIn(
+            "FxPending eq true",
+            mock_client.query_entities.call_args[1]["query_filter"],
+        )
+
+        self.db_service.save_conversions([("r1", converted)])
+        mock_client.update_entity.assert_called_once_with(
+            {
+                "PartitionKey": "default_2023-10",
+                "RowKey": "r1",
+                "Amount": 108.7,
+                "OriginalAmount": 100.0,
+                "FxPending": False,
+            },
+            mode=UpdateMode.MERGE,
+        )
+
+    def test_exchange_rates_cache(self):
+        """Test that a day's rates are stored as exact strings and read back."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.db_service.save_exchange_rates(
+            "USD", date(2025, 1, 15), {"EUR": Decimal("0.9712")}
+        )
+
+        entity = mock_client.upsert_entity.call_args[0][0]
+        self.assertEqual(entity["PartitionKey"], "USD")
+        self.assertEqual(entity["RowKey"], "2025-01-15")
+        mock_client.get_entity.return_value = entity
+        self.assertEqual(
+            self.db_service.get_exchange_rates("USD", date(2025, 1, 15)),
+            {"EUR": Decimal("0.9712")},
+        )
+
+        mock_client.get_entity.side_effect = ResourceNotFoundError("missing")
+        self.assertIsNone(
+            self.db_service.get_exchange_rates("USD", date(2025, 1, 16))
+        )
+
+    def test_connector_status(self):
+        """Test that a failed pull keeps the time of the last successful one."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.db_service.set_connector_status("bank", "failed", 0, "refused")
+
+        (entity,) = mock_client.upsert_entity.call_args[0]
+        self.assertEqual(entity["PartitionKey"], "default_CONNECTOR_STATUS")
+        self.assertNotIn("LastSuccessAt", entity)
+        mock_client.query_entities.return_value = [entity]
+        status = self.db_service.get_connector_statuses()["bank"]
+        self.assertEqual(status["status"], "failed")
+        self.assertEqual(status["error"], "refused")
+        self.assertIsNone(status["lastSuccessAt"])
+
+    def test_export_table_is_serializable(self):
+        """Test that exported entities keep timestamps and binary as strings."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        mock_client.list_entities.return_value = [
+            {
+                "PartitionKey": "p",
+                "RowKey": "r",
+                "At": datetime(2025, 6, 1),
+                "B": b"\x01",
+            }
+        ]
+
+        (entity,) = self.db_service.export_table("jobs")
+
+        self.assertEqual(entity["At"], "2025-06-01T00:00:00")
+        self.assertEqual(entity["B"], "AQ==")
+        self.db_service._get_table_client.assert_called_once_with("jobs")
+
+    def test_shadow_prefixes_every_table(self):
+        """Test that a shadow service reads and writes prefixed copies only."""
+        shadow = self.db_service.shadow("drtest")
+
+        self.assertEqual(
+            shadow.table_names(),
+            [f"drtest{name}" for name in self.db_service.table_names()],
+        )
+        self.assertIsNot(shadow._table_clients, self.db_service._table_clients)
+
+    def test_restore_table_replaces_contents(self):
+        """Test that a restore clears the table, then upserts by partition."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        mock_client.list_entities.return_value = [
+            {"PartitionKey": "old", "RowKey": "r0"}
+        ]
+        entities = [{"PartitionKey": "p", "RowKey": f"r{i}"} for i in range(150)]
+
+        restored = self.db_service.restore_table("drtestjobs", entities)
+
+        self.assertEqual(restored, 150)
+        batches = [c[0][0] for c in mock_client.submit_transaction.call_args_list]
+        self.assertEqual(
+            batches[0], [("delete", {"PartitionKey": "old", "RowKey": "r0"})]
+        )
+        self.assertEqual([len(b) for b in batches[1:]], [100, 50])
+        self.assertEqual(batches[1][0], ("upsert", entities[0]))
+
+    def test_switch_tables_routes_names(self):
+        """Test that a switch is saved in one entity and routes at once."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        mock_client.get_entity.side_effect = ResourceNotFoundError("missing")
+        transactions = self.db_service.transactions_table
+
+        routes = self.db_service.switch_tables({transactions: "transactionsv2"})
+
+        self.assertEqual(routes, {transactions: "transactionsv2"})
+        (entity,) = mock_client.create_entity.call_args[0]
+        self.assertEqual(entity["RowKey"], "TableRoutes")
+        self.assertEqual(json.loads(entity["Value"])["previous"], {})
+        self.assertEqual(self.db_service._route(transactions), "transactionsv2")
+        self.assertEqual(self.db_service._route("people"), "people")
+
+    def test_rollback_tables(self):
+        """Test that a rollback restores the routes before the last switch."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        mock_client.get_entity.return_value = _Entity(
+            {
+                "Value": json.dumps(
+                    {
+                        "routes": {"transactions": "transactionsv2"},
+                        "previous": {},
+                        "switchedAt": "2025-06-01T00:00:00",
+                    }
+                )
+            }
+        )
+
+        self.assertEqual(self.db_service.rollback_tables(), {})
+        entity = mock_client.update_entity.call_args[0][0]
+        self.assertEqual(
+            json.loads(entity["Value"])["previous"], {"transactions": "transactionsv2"}
+        )
+        self.assertEqual(mock_client.update_entity.call_args[1]["etag"], 'W/"1"')
+
+        mock_client.reset_mock()
+        mock_client.get_entity.side_effect = ResourceNotFoundError("missing")
+        self.assertIsNone(self.db_service.rollback_tables())
+        mock_client.create_entity.assert_not_called()
+
+    def test_migrate_table_writes_new_table(self):
+        """Test that migrated entities go to the target, dropping filtered ones."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        mock_client.list_entities.side_effect = [
+            [
+                {"PartitionKey": "p", "RowKey": "a"},
+                {"PartitionKey": "p", "RowKey": "b"},
+            ],
+            [],
+        ]
+
+        written = self.db_service.migrate_table(
+            "jobs",
+            "jobsv2",
+            lambda e: {**e, "Migrated": True} if e["RowKey"] == "a" else None,
+        )
+
+        self.assertEqual(written, 1)
+        (batch,) = mock_client.submit_transaction.call_args[0]
+        self.assertEqual(
+            batch, [("upsert", {"PartitionKey": "p", "RowKey": "a", "Migrated": True})]
+        )
+        # Recorded only once written, after clearing any earlier attempt's record
+        mock_client.delete_entity.assert_called_once_with(
+            partition_key="MIGRATIONS", row_key="jobsv2"
+        )
+        (record,) = mock_client.upsert_entity.call_args[0]
+        self.assertEqual((record["Source"], record["Entities"]), ("jobs", 1))
+
+    def test_switch_target_problem(self):
+        """Test that only a finished migration's complete table can be switched to."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        self.db_service.list_tables = MagicMock(return_value=["jobsv2"])
+        mock_client.get_entity.return_value = {"Entities": 2}
+        mock_client.list_entities.return_value = [{}, {}]
+
+        self.assertIsNone(self.db_service.switch_target_problem("jobsv2"))
+
+        mock_client.list_entities.return_value = [{}]
+        self.assertEqual(
+            self.db_service.switch_target_problem("jobsv2"),
+            "holds 1 entities, not the 2 migrated",
+        )
+        mock_client.get_entity.side_effect = ResourceNotFoundError("missing")
+        self.assertEqual(
+            self.db_service.switch_target_problem("jobsv2"),
+            "was not written by a finished migration",
+        )
+        self.db_service.list_tables.return_value = []
+        self.assertEqual(
+            self.db_service.switch_target_problem("jobsv2"), "does not exist"
+        )
+
+    def test_routes_are_read_once_per_invocation(self):
+        """Test that each invocation reads the routes afresh, and once."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        transactions = self.db_service.transactions_table
+
+        def routes(table):
+            return _Entity(
+                {"Value": json.dumps({"routes": {transactions: table}})}
+            )
+
+        mock_client.get_entity.return_value = routes("transactionsv2")
+        with table_routes_scope():
+            self.assertEqual(self.db_service._route(transactions), "transactionsv2")
+            # Switched by another worker mid-invocation
+            mock_client.get_entity.return_value = routes("transactionsv3")
+            self.assertEqual(self.db_service._route(transactions), "transactionsv2")
+        with table_routes_scope():
+            self.assertEqual(self.db_service._route(transactions), "transactionsv3")
+        self.assertEqual(mock_client.get_entity.call_count, 2)
+
+    @patch("rmanalyzer.services.database_service.TableServiceClient")
+    def test_list_and_drop_tables(self, mock_service):
+        """Test that tables are listed by prefix and dropped from the cache."""
+        service = mock_service.return_value
+        table = MagicMock()
+        table.name = "drtestpeople"
+        service.query_tables.return_value = [table]
+        self.db_service._table_clients["drtestpeople"] = MagicMock()
+
+        self.assertEqual(self.db_service.list_tables("drtest"), ["drtestpeople"])
+        service.query_tables.assert_called_once_with(
+            "TableName ge 'drtest' and TableName lt 'drtesu'"
+        )
+
+        self.db_service.drop_table("drtestpeople")
+        service.delete_table.assert_called_once_with("drtestpeople")
+        self.assertNotIn("drtestpeople", self.db_service._table_clients)
+
+    def test_close_statement_once(self):
+        """Test that a statement is taken once and becomes the card's balance."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        mock_client.get_entity.return_value = _Entity({"RowKey": "acc-1"})
+        statement = {
+            "closeDate": "2025-03-20",
+            "cycleStart": "2025-02-21",
+            "balance": 420.5,
+            "limit": None,
+        }
+
+        self.assertTrue(
+            self.db_service.close_statement("a@test.com", "acc-1", statement)
+        )
+        (entity,) = mock_client.create_entity.call_args[0]
+        self.assertEqual(entity["PartitionKey"], "a@test.com_acc-1")
+        self.assertNotIn("Limit", entity)
+        merged = mock_client.update_entity.call_args[0][0]
+        self.assertEqual(merged["StatementBalance"], 420.5)
+        self.assertEqual(merged["LastStatementDate"], "2025-03-20")
+        self.assertEqual(mock_client.update_entity.call_args[1]["etag"], 'W/"1"')
+
+        mock_client.reset_mock()
+        mock_client.create_entity.side_effect = ResourceExistsError("exists")
+        mock_client.get_entity.side_effect = [
+            _Entity({"Balance": 420.5}),
+            _Entity({"LastStatementDate": "2025-03-20"}),
+        ]
+        self.assertFalse(
+            self.db_service.close_statement("a@test.com", "acc-1", statement)
+        )
+        mock_client.update_entity.assert_not_called()
+
+    def test_close_statement_finishes_a_failed_close(self):
+        """Test that a close that failed after its history row is finished."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        mock_client.create_entity.side_effect = ResourceExistsError("exists")
+        mock_client.get_entity.side_effect = [
+            _Entity({"Balance": 400.0}),
+            _Entity({"LastStatementDate": "2025-02-20"}),
+        ]
+        statement = {
+            "closeDate": "2025-03-20",
+            "cycleStart": "2025-02-21",
+            "balance": 420.5,
+            "limit": None,
+        }
+
+        self.assertTrue(
+            self.db_service.close_statement("a@test.com", "acc-1", statement)
+        )
+        # The recorded row's balance, not the card's balance now
+        merged = mock_client.update_entity.call_args[0][0]
+        self.assertEqual(merged["StatementBalance"], 400.0)
+        self.assertEqual(merged["LastStatementDate"], "2025-03-20")
+
+    def test_reconciliation_audit_trail(self):
+        """Test that reconcile attempts are stored and listed newest first."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.db_service.record_reconciliation(
+            "a@test.com",
+            "acc-1",
+            {
+                "statementDate": "2025-03-09",
+                "balance": 120.0,
+                "expectedBalance": None,
+                "status": "baseline",
+                "transactions": 0,
+                "reconciled": True,
+                "reconciledBy": "a@test.com",
+            },
+        )
+        (entity,) = mock_client.create_entity.call_args[0]
+        self.assertEqual(entity["PartitionKey"], "a@test.com_acc-1")
+        self.assertTrue(entity["RowKey"].startswith("2025-03-09_"))
+        self.assertNotIn("Discrepancy", entity)
+
+        mock_client.query_entities.return_value = [
+            {"StatementDate": "2025-02-09", "RecordedAt": "2025-02-09T10:00:00"},
+            {"StatementDate": "2025-03-09", "RecordedAt": "2025-03-09T10:00:00"},
+        ]
+        history = self.db_service.get_reconciliations("a@test.com", "acc-1")
+        self.assertEqual(
+            [r["statementDate"] for r in history], ["2025-03-09", "2025-02-09"]
+        )
+
+    def test_alert_lifecycle(self):
+        """Test that a snooze replaces the alert's status and a reopen clears it."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.db_service.record_alert(
+            "a1",
+            {
+                "kind": "utilization",
+                "key": "a@test.com",
+                "subject": "Credit utilization is high",
+                "recipients": ["a@test.com"],
+            },
+        )
+        (entity,) = mock_client.upsert_entity.call_args[0]
+        self.assertEqual(entity["PartitionKey"], "default_ALERTS")
+        self.assertEqual(entity["Status"], "new")
+
+        mock_client.get_entity.return_value = {**entity, "SnoozedUntil": "2025-04-01"}
+        self.db_service.set_alert_status("a1", "new", None, "a@test.com")
+        (entity,) = mock_client.upsert_entity.call_args[0]
+        self.assertEqual(entity["Status"], "new")
+        self.assertNotIn("SnoozedUntil", entity)
+        self.assertEqual(entity["UpdatedBy"], "a@test.com")
+
+        alert = self.db_service.get_alert("a1")
+        self.assertEqual(alert["recipients"], ["a@test.com"])
+        self.assertEqual(alert["snoozedUntil"], "2025-04-01")
+
+    def test_share_lifecycle(self):
+        """Test that a share is stored by hash and listed only for its creator."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.db_service.save_share(
+            "h1",
+            {
+                "report": "summary",
+                "params": {"month": "2025-03"},
+                "createdBy": "a@test.com",
+                "expiresAt": "2025-03-08T00:00:00",
+            },
+        )
+        (entity,) = mock_client.create_entity.call_args[0]
+        self.assertEqual(entity["PartitionKey"], "default_SHARES")
+        self.assertEqual(entity["RowKey"], "h1")
+        self.assertEqual(entity["Params"], '{"month": "2025-03"}')
+
+        mock_client.query_entities.return_value = [
+            entity,
+            {**entity, "RowKey": "h2", "CreatedBy": "b@test.com"},
+        ]
+        shares = self.db_service.get_shares("A@test.com")
+        self.assertEqual([s["id"] for s in shares], ["h1"])
+        self.assertEqual(shares[0]["params"], {"month": "2025-03"})
+        self.assertIsNone(shares[0]["revokedAt"])
+
+        self.db_service.revoke_share("h1")
+        (update,) = mock_client.update_entity.call_args[0]
+        self.assertEqual(update["RowKey"], "h1")
+        self.assertIn("RevokedAt", update)
+
+    def test_view_lifecycle(self):
+        """Test that views are listed only for their owner, by name."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.db_service.save_view(
+            {"id": "v1", "name": "Large", "filter": "amount>100", "sort": None},
+            "a@test.com",
+        )
+        (entity,) = mock_client.upsert_entity.call_args[0]
+        self.assertEqual(entity["PartitionKey"], "default_VIEWS")
+        self.assertEqual(entity["Owner"], "a@test.com")
+        self.assertEqual(entity["Sort"], "")
+
+        mock_client.query_entities.return_value = [
+            entity,
+            {**entity, "RowKey": "v2", "Name": "groceries"},
+            {**entity, "RowKey": "v3", "Owner": "b@test.com"},
+        ]
+        views = self.db_service.get_views("A@test.com")
+        self.assertEqual([v["id"] for v in views], ["v2", "v1"])
+        self.assertEqual(views[1]["filter"], "amount>100")
+        self.assertIsNone(views[1]["sort"])
+
+        self.db_service.delete_view("v1")
+        mock_client.delete_entity.assert_called_once_with(
+            partition_key="default_VIEWS", row_key="v1"
+        )
+
+    def test_search_transactions(self):
+        """Test that amount and date bounds are part of the query."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        mock_client.query_entities.return_value = []
+
+        self.db_service.search_transactions("2025-03")
+        mock_client.query_entities.assert_called_with(
+            query_filter="PartitionKey eq 'default_2025-03'"
+        )
+
+        self.db_service.search_transactions(
+            "2025-03", Decimal("10"), Decimal("99.5"), date(2025, 3, 2), None
+        )
+        mock_client.query_entities.assert_called_with(
+            query_filter=(
+                "PartitionKey eq 'default_2025-03' and Amount ge 10.0"
+                " and Amount le 99.5 and Date ge '2025-03-02'"
+            )
+        )
+
+    def test_category_lifecycle(self):
+        """Test that added categories are stored, cached and read back by name."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.db_service.save_category(
+            {"name": "Kids", "color": "#3366ff", "budgetEligible": False}
+        )
+        (entity,) = mock_client.upsert_entity.call_args[0]
+        self.assertEqual(entity["PartitionKey"], "default_CATEGORIES")
+        self.assertEqual(entity["RowKey"], "Kids")
+        self.assertFalse(entity["BudgetEligible"])
+
+        mock_client.query_entities.return_value = [
+            entity,
+            {**entity, "RowKey": "gym", "Color": "", "BudgetEligible": True},
+        ]
+        categories = self.db_service.get_categories()
+        self.assertEqual([c["name"] for c in categories], ["gym", "Kids"])
+        self.assertIsNone(categories[0]["color"])
+
+        # Read once, then kept, including when the table can't be read
+        self.assertEqual(self.db_service.category_names(), ["gym", "Kids"])
+        mock_client.query_entities.side_effect = RuntimeError("down")
+        self.assertEqual(self.db_service.category_names(), ["gym", "Kids"])
+        self.db_service._category_names_read = float("-inf")
+        self.assertEqual(self.db_service.category_names(), ["gym", "Kids"])
+
+        mock_client.get_entity.side_effect = ResourceNotFoundError("missing")
+        self.assertFalse(self.db_service.delete_category("Nope"))
+        mock_client.get_entity.side_effect = None
+        self.assertTrue(self.db_service.delete_category("Kids"))
+        mock_client.delete_entity.assert_called_once_with(
+            partition_key="default_CATEGORIES", row_key="Kids"
+        )
+
+    def test_deleted_category_reads_as_other(self):
+        """Test that transactions in a category since deleted read as Other."""
+        entity = {
+            "Date": "2025-01-05",
+            "Description": "Toy store",
+            "AccountNumber": 1,
+            "Amount": 20,
+            "Category": "Kids",
+        }
+        self.db_service.category_names = MagicMock(return_value=[])
+        transaction = self.db_service._entity_to_transaction(entity)
+        self.assertEqual(transaction.category, Category.OTHER)
+
+        self.db_service.category_names.return_value = ["Kids"]
+        transaction = self.db_service._entity_to_transaction(entity)
+        self.assertEqual(transaction.category.value, "Kids")
+
+    def test_rules_and_budgets_for_unknown_categories_are_skipped(self):
+        """Test that rules and budgets for a category not added are left out."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        self.db_service.category_names = MagicMock(return_value=["Kids"])
+        mock_client.query_entities.return_value = [
+            {"RowKey": "r1", "Category": "Kids", "Contains": "TOYS"},
+            {"RowKey": "r2", "Category": "Gone", "Contains": "OLD"},
+        ]
+        rules = self.db_service.get_rules()
+        self.assertEqual([r.category for r in rules], [AddedCategory("Kids")])
+
+        mock_client.query_entities.return_value = [
+            {"RowKey": "Groceries", "Limit": 300},
+            {"RowKey": "Gone", "Limit": 50},
+        ]
+        self.assertEqual(
+            self.db_service.get_budgets(), {Category.GROCERIES: Decimal("300.00")}
+        )
+
+    def test_add_usage(self):
+        """Test that a month's counter is created, then added to conditionally."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        mock_client.get_entity.side_effect = ResourceNotFoundError("missing")
+
+        self.db_service.add_usage("2025-03", "EmailsSent", 2)
+        (entity,) = mock_client.create_entity.call_args[0]
+        self.assertEqual(
+            entity,
+            {"PartitionKey": "default_USAGE", "RowKey": "2025-03", "EmailsSent": 2},
+        )
+
+        existing = MagicMock()
+        existing.get.return_value = 2
+        existing.metadata = {"etag": "e1"}
+        mock_client.get_entity.side_effect = None
+        mock_client.get_entity.return_value = existing
+        self.db_service.add_usage("2025-03", "EmailsSent", 3)
+        (update,) = mock_client.update_entity.call_args[0]
+        self.assertEqual(update["EmailsSent"], 5)
+        self.assertEqual(mock_client.update_entity.call_args[1]["etag"], "e1")
+
+    def test_update_transactions_batches(self):
+        """Test that changes are merged onto a month's rows in batches of 100."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        changes = {f"r{i}": {"Category": "Groceries"} for i in range(150)}
+
+        updated = self.db_service.update_transactions("2023-10", changes)
+
+        self.assertEqual(updated, 150)
+        self.assertEqual(mock_client.submit_transaction.call_count, 2)
+        op, entity, _ = mock_client.submit_transaction.call_args_list[0][0][0][0]
+        self.assertEqual(op, "update")
+        self.assertEqual(entity["PartitionKey"], "default_2023-10")
+        self.assertEqual(entity["RowKey"], "r0")
+        self.assertEqual(entity["Category"], "Groceries")
+
+    def test_writes_report_changed_months(self):
+        """Test that writes to transactions report the months they changed."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        changed = MagicMock()
+        self.db_service.on_transactions_changed = changed
+        transactions = self.db_service.transactions_table
+
+        self.db_service.update_transactions("2023-10", {"r1": {"Category": "Pets"}})
+        changed.assert_called_once_with(transactions, "2023-10")
+
+        changed.reset_mock()
+        mock_client.query_entities.return_value = [
+            _Entity({"PartitionKey": "default_2023-11", "RowKey": "r2"}, "e1")
+        ]
+        self.db_service.delete_transaction("r2")
+        changed.assert_called_once_with(transactions, "2023-11")
+
+        changed.reset_mock()
+        self.db_service.delete_entities(
+            transactions,
+            [
+                {"PartitionKey": "default_2023-09", "RowKey": "r3"},
+                {"PartitionKey": "default_2023-10", "RowKey": "r4"},
+            ],
+        )
+        self.db_service.delete_entities(
+            "people", [{"PartitionKey": "PEOPLE", "RowKey": "a@test.com"}]
+        )
+        self.assertEqual(
+            changed.call_args_list,
+            [call(transactions, "2023-09"), call(transactions, "2023-10")],
+        )
+
+        # A failing listener doesn't fail the write
+        changed.side_effect = RuntimeError("boom")
+        with self.assertLogs(level="ERROR"):
+            updated = self.db_service.update_transactions(
+                "2023-10", {"r1": {"Category": "Pets"}}
+            )
+        self.assertEqual(updated, 1)
+
+    def test_update_transactions_expected(self):
+        """Test that rows no longer holding the expected values are left alone."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        mock_client.query_entities.return_value = [
+            {"RowKey": "r1", "Category": "Groceries", "Owner": ""},
+            {"RowKey": "r2", "Category": "Dining"},
+        ]
+        changes = {
+            r: {"Category": "Other", "Owner": "a@test.com"} for r in ("r1", "r2", "r3")
+        }
+
+        updated = self.db_service.update_transactions(
+            "2023-10", changes, expected={"Category": "Groceries", "Owner": ""}
+        )
+
+        self.assertEqual(updated, 1)
+        self.assertEqual(
+            mock_client.query_entities.call_args[1]["select"],
+            ["RowKey", "Category", "Owner"],
+        )
+        (batch,) = mock_client.submit_transaction.call_args[0]
+        self.assertEqual([entity["RowKey"] for _, entity, _ in batch], ["r1"])
+
+    def test_recategorize_transactions(self):
+        """Test that only stored rows whose category changed are updated."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        stored, changed, new = [
+            Transaction(
+                date(2023, 10, d),
+                "Shop",
+                1,
+                Decimal("10.00"),
+                Category.GROCERIES,
+                IgnoredFrom.NOTHING,
+            )
+            for d in (1, 2, 3)
+        ]
+        key = self.db_service._generate_row_key
+        mock_client.query_entities.return_value = [
+            {"RowKey": key(stored), "Category": "Groceries"},
+            {"RowKey": key(changed), "Category": "Other"},
+        ]
+
+        result = self.db_service.recategorize_transactions([stored, changed, new])
+
+        self.assertEqual(
+            result, {"matched": 2, "updated": 1, "locked": 0, "missing": 1}
+        )
+        (batch,) = mock_client.submit_transaction.call_args[0]
+        self.assertEqual(
+            [entity for _, entity, _ in batch],
+            [
+                {
+                    "PartitionKey": "default_2023-10",
+                    "RowKey": key(changed),
+                    "Category": "Groceries",
+                    "CategorySource": "",
+                }
+            ],
+        )
+
+    def test_recategorize_keeps_manual_categories(self):
+        """Test that categories set by hand only change with override."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        t = Transaction(
+            date(2023, 10, 1),
+            "Shop",
+            1,
+            Decimal("10.00"),
+            Category.GROCERIES,
+            IgnoredFrom.NOTHING,
+        )
+        mock_client.query_entities.return_value = [
+            {
+                "RowKey": self.db_service._generate_row_key(t),
+                "Category": "Other",
+                "CategorySource": "manual",
+            }
+        ]
+
+        result = self.db_service.recategorize_transactions([t])
+
+        self.assertEqual((result["updated"], result["locked"]), (0, 1))
+        mock_client.submit_transaction.assert_not_called()
+
+        result = self.db_service.recategorize_transactions([t], override=True)
+
+        self.assertEqual((result["updated"], result["locked"]), (1, 0))
+
+    def test_activity_keys_list_newest_first(self):
+        """Test that later events get smaller row keys, so they sort first."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.db_service.record_activity("import", "first")
+        self.db_service.record_activity("edit", "second", "a@test.com")
+
+        first, second = [
+            c[0][0]["RowKey"] for c in mock_client.create_entity.call_args_list
+        ]
+        self.assertLessEqual(second[:13], first[:13])
+        self.assertEqual(
+            mock_client.create_entity.call_args[0][0]["PartitionKey"],
+            "default_ACTIVITY",
+        )
+
+    def test_get_activity_pages_with_cursor(self):
+        """Test that a full page returns the cursor of its last event."""
+        mock_client = MagicMock()
+        mock_client.query_entities.return_value = iter(
+            {
+                "RowKey": f"{i:013d}_0000000{i}",
+                "Kind": "edit",
+                "Summary": "Edited",
+                "Details": "{}",
+                "OccurredAt": "2025-01-01T00:00:00",
+            }
+            for i in range(3)
+        )
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        events, cursor = self.db_service.get_activity(2, "0000000000000_00000000")
+
+        self.assertEqual(len(events), 2)
+        self.assertEqual(cursor, events[-1]["id"])
+        self.assertIn(
+            "RowKey gt '0000000000000_00000000'",
+            mock_client.query_entities.call_args[1]["query_filter"],
+        )
+
+    def test_job_runs_list_newest_first(self):
+        """Test that later runs get smaller row keys, under the job's partition."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.db_service.record_job_run(
+            "nightly", datetime(2025, 3, 9), "succeeded", {}, []
+        )
+        self.db_service.record_job_run(
+            "nightly", datetime(2025, 3, 10), "failed", {}, ["boom"]
+        )
+
+        first, second = [c[0][0] for c in mock_client.upsert_entity.call_args_list]
+        self.assertLess(second["RowKey"], first["RowKey"])
+        self.assertEqual(second["PartitionKey"], "default_JOB_nightly")
+        self.assertEqual(second["Errors"], '["boom"]')
+
+    def test_account_import_date_never_moves_back(self):
+        """Test that backfilling an older statement leaves LastImportedDate alone."""
+        mock_client = MagicMock()
+        mock_client.get_entity.return_value = _Entity(
+            {"LastImportedDate": "2025-03-01"}
+        )
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.assertFalse(
+            self.db_service.record_account_import(
+                "a@test.com", "acc-1", date(2024, 12, 31)
+            )
+        )
+        mock_client.update_entity.assert_not_called()
+
+        self.assertTrue(
+            self.db_service.record_account_import(
+                "a@test.com", "acc-1", date(2025, 3, 31)
+            )
+        )
+        entity = mock_client.update_entity.call_args[0][0]
+        self.assertEqual(entity["LastImportedDate"], "2025-03-31")
+        self.assertNotIn("LastReconciledDate", entity)
+
+    def test_apply_owner_overrides(self):
+        """Test that stored owners are matched to transactions by RowKey."""
+        t1 = Transaction(
+            date(2023, 10, 5),
+            "Cafe",
+            1234,
+            Decimal("12.30"),
+            Category.DINING,
+            IgnoredFrom.NOTHING,
+        )
+        t2 = Transaction(
+            date(2023, 10, 6),
+            "Cafe",
+            1234,
+            Decimal("8.00"),
+            Category.DINING,
+            IgnoredFrom.NOTHING,
+        )
+        mock_client = MagicMock()
+        mock_client.query_entities.return_value = [
+            {"RowKey": self.db_service._generate_row_key(t2), "Owner": "b@test.com"}
+        ]
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        result = self.db_service.apply_owner_overrides([t1, t2])
+
+        self.assertIsNone(result[0].owner)
+        self.assertEqual(result[1].owner, "b@test.com")
+
+    def test_set_transaction_owner(self):
+        """Test that owners are merged onto the matching entity only."""
+        mock_client = MagicMock()
+        mock_client.query_entities.return_value = [
+            _Entity({"PartitionKey": "default_2023-10", "RowKey": "abc"})
+        ]
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.assertTrue(self.db_service.set_transaction_owner("abc", "b@test.com"))
+        entity = mock_client.update_entity.call_args[0][0]
+        self.assertEqual(entity["Owner"], "b@test.com")
+        self.assertEqual(entity["PartitionKey"], "default_2023-10")
+        kwargs = mock_client.update_entity.call_args.kwargs
+        self.assertEqual(kwargs["etag"], 'W/"1"')
+
+        mock_client.query_entities.return_value = []
+        self.assertFalse(self.db_service.set_transaction_owner("missing", None))
+
+    def test_set_transaction_owner_refused_by_check(self):
+        """Test that a check that raises leaves the transaction unchanged."""
+        mock_client = MagicMock()
+        mock_client.query_entities.return_value = [
+            _Entity({"PartitionKey": "default_2023-10", "RowKey": "abc"})
+        ]
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        def refuse(entity):
+            raise PermissionError(entity["RowKey"])
+
+        with self.assertRaises(PermissionError):
+            self.db_service.set_transaction_owner("abc", "b@test.com", check=refuse)
+        mock_client.update_entity.assert_not_called()
+
+    def test_update_transaction(self):
+        """Test that changes are merged onto the matching entity."""
+        mock_client = MagicMock()
+        mock_client.query_entities.return_value = [
+            {"PartitionKey": "default_2023-10", "RowKey": "abc"}
+        ]
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.assertTrue(
+            self.db_service.update_transaction("abc", {"Category": "Groceries"})
+        )
+        entity = mock_client.update_entity.call_args[0][0]
+        self.assertEqual(entity["Category"], "Groceries")
+        self.assertEqual(entity["PartitionKey"], "default_2023-10")
+
+    def test_adjust_account_balance(self):
+        """Test that a balance moves by the delta, only while the account is known."""
+        mock_client = MagicMock()
+        mock_client.get_entity.return_value = _Entity(
+            {"PartitionKey": "a@test.com", "RowKey": "visa", "Balance": 100.0}
+        )
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.assertTrue(
+            self.db_service.adjust_account_balance("a@test.com", "visa", Decimal("-2.5"))
+        )
+        entity = mock_client.update_entity.call_args[0][0]
+        self.assertEqual(entity["Balance"], 97.5)
+        self.assertEqual(mock_client.update_entity.call_args.kwargs["etag"], 'W/"1"')
+
+        mock_client.get_entity.return_value = _Entity({"RowKey": "visa"})
+        self.assertFalse(
+            self.db_service.adjust_account_balance("a@test.com", "visa", Decimal("1"))
+        )
+
+    def test_invite_accepted_once(self):
+        """Test that an invite another accept got to first is not accepted again."""
+        mock_client = MagicMock()
+        mock_client.get_entity.side_effect = [
+            _Entity({"RowKey": "h"}),
+            _Entity({"RowKey": "h", "AcceptedBy": "b@test.com"}, etag='W/"2"'),
+        ]
+        mock_client.update_entity.side_effect = ResourceModifiedError()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        self.db_service._retrier = ConcurrencyRetrier(sleep=lambda _: None)
+
+        self.assertFalse(self.db_service.mark_invite_accepted("h", "a@test.com"))
+        # The losing write was conditional on the invite as first read
+        self.assertEqual(mock_client.update_entity.call_args.kwargs["etag"], 'W/"1"')
+
+        mock_client.get_entity.side_effect = None
+        mock_client.get_entity.return_value = _Entity({"RowKey": "h"})
+        mock_client.update_entity.side_effect = None
+        self.assertTrue(self.db_service.mark_invite_accepted("h", "a@test.com"))
+        self.assertEqual(
+            mock_client.update_entity.call_args[0][0]["AcceptedBy"], "a@test.com"
+        )
+
+    def test_delete_transaction(self):
+        """Test that only a found transaction is deleted, returning what it was."""
+        mock_client = MagicMock()
+        stored = {"PartitionKey": "default_2023-10", "RowKey": "abc", "Amount": 5.0}
+        mock_client.query_entities.return_value = [stored]
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.assertEqual(self.db_service.delete_transaction("abc"), stored)
+        mock_client.delete_entity.assert_called_once_with(
+            partition_key="default_2023-10", row_key="abc"
+        )
+
+        mock_client.query_entities.return_value = []
+        self.assertIsNone(self.db_service.delete_transaction("missing"))
+
+    def test_restore_transactions(self):
+        """Test that deleted entities are put back in batches per partition."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        entities = [
+            {"PartitionKey": "default_2023-10", "RowKey": "a"},
+            {"PartitionKey": "default_2023-11", "RowKey": "b"},
+            {"PartitionKey": "default_2023-10", "RowKey": "c"},
+        ]
+
+        self.assertEqual(self.db_service.restore_transactions(entities), 3)
+
+        batches = [c[0][0] for c in mock_client.submit_transaction.call_args_list]
+        self.assertEqual(
+            [[e["RowKey"] for _, e in batch] for batch in batches], [["a", "c"], ["b"]]
+        )
+        self.assertEqual(batches[0][0][0], "upsert")
+
+    def test_undo_log(self):
+        """Test that operations are logged with their inverse, expiring old ones."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        mock_client.query_entities.return_value = [
+            {"PartitionKey": "default_UNDO", "RowKey": "old"}
+        ]
+
+        self.db_service.save_undo_operation(
+            {
+                "id": "op1",
+                "kind": "delete",
+                "summary": "Deleted a transaction",
+                "actor": "a@test.com",
+                "inverse": {"restore": []},
+            },
+            "2025-03-01T11:30:00",
+        )
+        self.assertIn(
+            "CreatedAt lt '2025-03-01T11:30:00'",
+            mock_client.query_entities.call_args[1]["query_filter"],
+        )
+        (batch,) = mock_client.submit_transaction.call_args[0]
+        self.assertEqual(batch[0][1]["RowKey"], "old")
+        (entity,) = mock_client.create_entity.call_args[0]
+        self.assertEqual(entity["PartitionKey"], "default_UNDO")
+        self.assertEqual(entity["Inverse"], '{"restore": []}')
+
+        mock_client.query_entities.return_value = [
+            {**entity, "CreatedAt": "2025-03-01T12:00:00"},
+            {**entity, "RowKey": "op2", "CreatedAt": "2025-03-01T12:05:00"},
+        ]
+        operations = self.db_service.get_undo_operations()
+        self.assertEqual([o["id"] for o in operations], ["op2", "op1"])
+        self.assertEqual(operations[0]["inverse"], {"restore": []})
+        self.assertIsNone(operations[0]["undoneAt"])
+
+        # A large inverse is split across properties and put back together
+        inverse = {"restore": [{"RowKey": "x" * 40}]}
+        with patch("rmanalyzer.services.database_service.UNDO_CHUNK_LENGTH", 20):
+            self.db_service.save_undo_operation(
+                {
+                    "id": "op3",
+                    "kind": "delete",
+                    "summary": "Deleted a transaction",
+                    "actor": "a@test.com",
+                    "inverse": inverse,
+                },
+                "2025-03-01T11:30:00",
+            )
+        (entity,) = mock_client.create_entity.call_args[0]
+        self.assertEqual(len(entity["Inverse"]), 20)
+        self.assertIn("Inverse3", entity)
+        self.assertNotIn("Inverse4", entity)
+        mock_client.query_entities.return_value = [
+            {**entity, "CreatedAt": "2025-03-01T12:10:00"}
+        ]
+        self.assertEqual(self.db_service.get_undo_operations()[0]["inverse"], inverse)
+
+        self.db_service.mark_undone("op1")
+        (update,) = mock_client.update_entity.call_args[0]
+        self.assertEqual(update["RowKey"], "op1")
+        self.assertIn("UndoneAt", update)
+
+    def test_get_spending_totals(self):
+        """Test that spend is grouped and ignored transactions are skipped."""
+        mock_client = MagicMock()
+        mock_client.query_entities.return_value = [
+            {"Amount": 10.5, "AccountNumber": 1, "Category": "Groceries"},
+            {"Amount": 4.25, "AccountNumber": 1, "Category": "Groceries", "Owner": ""},
+            {"Amount": 7.0, "AccountNumber": 1, "Category": "Groceries", "Owner": "b"},
+            {"Amount": 99.0, "AccountNumber": 1, "IgnoredFrom": "everything"},
+            {"Amount": 50.0, "AccountNumber": 1, "IgnoredFrom": "budget"},
+        ]
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        rows = self.db_service.get_spending_totals("2023-10")
+
+        self.assertEqual(len(rows), 2)
+        self.assertEqual(rows[0]["Total"], Decimal("14.75"))
+        self.assertEqual(rows[0]["Count"], 2)
+        self.assertIsNone(rows[0]["Owner"])
+        self.assertEqual(rows[1]["Owner"], "b")
+
+    def test_add_person_account(self):
+        """Test that accounts are merged onto the person without duplicates."""
+        mock_client = MagicMock()
+        mock_client.get_entity.return_value = _Entity({"Accounts": "[2, 1]"})
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.assertEqual(self.db_service.add_person_account("a@test.com", 1), [1, 2])
+        entity = mock_client.update_entity.call_args[0][0]
+        self.assertEqual(entity["RowKey"], "a@test.com")
+        self.assertEqual(entity["Accounts"], "[1, 2]")
+
+    def test_add_person_account_retries_concurrent_change(self):
+        """Test that a conflicting write rereads, keeping the other writer's change."""
+        mock_client = MagicMock()
+        mock_client.get_entity.side_effect = [
+            _Entity({"Accounts": "[2]"}, 'W/"1"'),
+            _Entity({"Accounts": "[2, 3]"}, 'W/"2"'),
+        ]
+        mock_client.update_entity.side_effect = [ResourceModifiedError(), None]
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        self.db_service._retrier = ConcurrencyRetrier(sleep=lambda _: None)
+
+        self.assertEqual(self.db_service.add_person_account("a@test.com", 1), [1, 2, 3])
+        _, kwargs = mock_client.update_entity.call_args
+        self.assertEqual(kwargs["etag"], 'W/"2"')
+
+    def test_add_person_account_weight(self):
+        """Test that a joint account's weight is stored, replacing any earlier one."""
+        mock_client = MagicMock()
+        mock_client.get_entity.return_value = _Entity(
+            {"Accounts": "[1]", "AccountWeights": '{"1": 0.5}'}
+        )
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.db_service.add_person_account("a@test.com", 1, 0.6)
+
+        entity = mock_client.update_entity.call_args[0][0]
+        self.assertEqual(entity["AccountWeights"], '{"1": 0.6}')
+
+    def test_remove_person_account(self):
+        """Test that only the given account is removed."""
+        mock_client = MagicMock()
+        mock_client.get_entity.return_value = _Entity({"Accounts": "[1, 2]"})
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+
+        self.assertEqual(self.db_service.remove_person_account("a@test.com", 1), [2])
+
+    def test_upsert_accounts(self):
+        """Test that accounts are merged in batches keyed by external ID."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        accounts = [
+            {"accountId": f"acc-{i}", "mask": "1234", "balance": None}
+            for i in range(150)
+        ]
+
+        self.assertEqual(self.db_service.upsert_accounts("a@test.com", accounts), 150)
+
+        self.assertEqual(mock_client.submit_transaction.call_count, 2)
+        _, entity, _ = mock_client.submit_transaction.call_args_list[0][0][0][0]
+        self.assertEqual(entity["PartitionKey"], "a@test.com")
+        self.assertEqual(entity["RowKey"], "acc-0")
+        self.assertEqual(entity["Mask"], "1234")
+        # Omitted fields are left unchanged by the merge
+        self.assertNotIn("Balance", entity)
+
+    def test_subscription_round_trip(self):
+        """Test that price history is stored as JSON under a hashed merchant key."""
+        mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=mock_client)
+        subscription = {
+            "merchant": "HULU/LIVE",
+            "cadenceDays": 30,
+            "lastDate": "2025-03-02",
+            "lastAmount": 12.5,
+            "averageAmount": 11.5,
+            "priceHistory": [{"date": "2025-03-02", "from": 11.0, "to": 12.5}],
+        }
+
+        self.db_service.save_subscription(subscription)
+
+        entity = mock_client.upsert_entity.call_args[0][0]
+        self.assertNotIn("/", entity["RowKey"])
+        mock_client.query_entities.return_value = [entity]
+        self.assertEqual(self.db_service.get_subscriptions(), [subscription])
+
 
 if __name__ == "__main__":
     unittest.main()
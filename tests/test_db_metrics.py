@@ -0,0 +1,66 @@
+"""
+Tests for the metric counts saved to the metrics table.
+"""
+
+import json
+import os
+import unittest
+from datetime import date
+from unittest.mock import MagicMock, patch
+
+from rmanalyzer.services import DatabaseService
+
+
+class TestMetricsDB(unittest.TestCase):
+    """Test suite for the metrics table in DatabaseService."""
+
+    def setUp(self):
+        patcher = patch.dict(
+            os.environ, {"TABLE_SERVICE_URL": "http://localhost:10002"}
+        )
+        patcher.start()
+        self.addCleanup(patcher.stop)
+        self.db_service = DatabaseService()
+        self.mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=self.mock_client)
+
+    def test_metric_counts_per_worker_and_bucket(self):
+        """Test that a worker's counts replace its own entity and are read back."""
+        self.db_service.save_metric_counts(
+            "2025-03-02T09:00", "w1", {"http": {"requests": 3}}
+        )
+
+        entity = self.mock_client.upsert_entity.call_args[0][0]
+        self.assertEqual(
+            (entity["PartitionKey"], entity["RowKey"]), ("2025-03-02T09:00", "w1")
+        )
+        self.assertEqual(json.loads(entity["Counts"]), {"http": {"requests": 3}})
+
+        self.mock_client.query_entities.return_value = [{"Counts": entity["Counts"]}]
+        counts = self.db_service.get_metric_counts(
+            "2025-03-02T09:00", "2025-03-02T09:15"
+        )
+
+        self.assertEqual(counts, [{"http": {"requests": 3}}])
+        self.assertEqual(
+            self.mock_client.query_entities.call_args.kwargs["query_filter"],
+            "PartitionKey ge '2025-03-02T09:00' and PartitionKey lt '2025-03-02T09:15'",
+        )
+
+    def test_expired_keys_cover_metric_buckets(self):
+        """Test that metric buckets before the cutoff are listed."""
+        self.mock_client.query_entities.side_effect = lambda **kwargs: []
+
+        tables = self.db_service.list_expired_keys(
+            None, None, metrics_cutoff=date(2025, 1, 1)
+        )
+
+        self.assertEqual(set(tables), {"metrics"})
+        self.assertEqual(
+            self.mock_client.query_entities.call_args.kwargs["query_filter"],
+            "PartitionKey lt '2025-01-01'",
+        )
+
+
+if __name__ == "__main__":
+    unittest.main()
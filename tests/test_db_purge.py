@@ -0,0 +1,47 @@
+"""
+Tests for listing a household's table keys to purge.
+"""
+
+import os
+import unittest
+from unittest.mock import MagicMock, patch
+
+from rmanalyzer.services import DatabaseService
+
+
+class TestPurgeDB(unittest.TestCase):
+    """Test suite for DatabaseService.list_household_keys."""
+
+    def setUp(self):
+        patcher = patch.dict(
+            os.environ, {"TABLE_SERVICE_URL": "http://localhost:10002"}
+        )
+        patcher.start()
+        self.addCleanup(patcher.stop)
+        self.db_service = DatabaseService()
+        self.mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=self.mock_client)
+
+    def test_household_keys_cover_settings_keys_and_invites(self):
+        """Test that every household table is listed, with filter values quoted."""
+        self.mock_client.list_entities.return_value = []
+        self.mock_client.query_entities.side_effect = lambda **kwargs: []
+        self.db_service.get_all_people = MagicMock(
+            return_value=[{"Email": "o'brien@example.com"}]
+        )
+
+        tables = self.db_service.list_household_keys()
+
+        self.assertTrue({"settings", "apikeys", "invites"} <= set(tables))
+        filters = [
+            c.kwargs["query_filter"]
+            for c in self.mock_client.query_entities.call_args_list
+        ]
+        self.assertIn("PartitionKey eq 'o''brien@example.com'", filters)
+        self.assertIn(
+            "PartitionKey eq 'SETTINGS' and RowKey ne 'TableRoutes'", filters
+        )
+
+
+if __name__ == "__main__":
+    unittest.main()
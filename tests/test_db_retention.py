@@ -0,0 +1,51 @@
+"""
+Tests for listing table keys past their retention period.
+"""
+
+import os
+import unittest
+from datetime import date
+from unittest.mock import MagicMock, patch
+
+from rmanalyzer.services import DatabaseService
+
+
+class TestRetentionDB(unittest.TestCase):
+    """Test suite for DatabaseService.list_expired_keys."""
+
+    def setUp(self):
+        patcher = patch.dict(
+            os.environ, {"TABLE_SERVICE_URL": "http://localhost:10002"}
+        )
+        patcher.start()
+        self.addCleanup(patcher.stop)
+        self.db_service = DatabaseService()
+        self.mock_client = MagicMock()
+        self.db_service._get_table_client = MagicMock(return_value=self.mock_client)
+
+    def test_expired_keys_cover_activity_and_job_runs(self):
+        """Test that activity and job runs before their cutoffs are listed."""
+        self.mock_client.query_entities.side_effect = lambda **kwargs: []
+
+        tables = self.db_service.list_expired_keys(
+            None, None, date(2025, 1, 1), date(2025, 6, 1)
+        )
+
+        self.assertEqual(set(tables), {"activity", "jobs"})
+        filters = [
+            c.kwargs["query_filter"]
+            for c in self.mock_client.query_entities.call_args_list
+        ]
+        self.assertIn(
+            "PartitionKey eq 'default_ACTIVITY' and OccurredAt lt '2025-01-01'",
+            filters,
+        )
+        self.assertIn(
+            "PartitionKey ge 'default_JOB_' and PartitionKey lt 'default_JOB`' "
+            "and StartedAt lt '2025-06-01'",
+            filters,
+        )
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -27,7 +27,10 @@ class TestSavingsDB(unittest.TestCase):
         pk = f"{user}_{month}"
         data = {
             "startingBalance": 1000.50,
-            "items": [{"name": "Rent", "cost": 1500}, {"name": "Food", "cost": 500}],
+            "items": [
+                {"name": "Rent", "cost": 1500},
+                {"name": "Food", "cost": 500, "oneOff": True},
+            ],
         }
 
         self.db_service.save_savings(month, data, user)
@@ -53,6 +56,8 @@ class TestSavingsDB(unittest.TestCase):
         self.assertEqual(len(items), 2)
         rent = next(i for i in items if i["Name"] == "Rent")
         self.assertEqual(rent["Cost"], 1500.0)
+        self.assertFalse(rent["OneOff"])
+        self.assertTrue(next(i for i in items if i["Name"] == "Food")["OneOff"])
 
     def test_get_savings_reassembles_json(self):
         month = "2023-11"
@@ -72,6 +77,7 @@ class TestSavingsDB(unittest.TestCase):
                 "RowKey": "ITEM_2",
                 "Name": "Internet",
                 "Cost": 80.0,
+                "OneOff": True,
             },
         ]
 
@@ -83,6 +89,8 @@ class TestSavingsDB(unittest.TestCase):
         self.assertEqual(len(result["items"]), 2)
         self.assertEqual(result["items"][0]["name"], "Utilities")
         self.assertEqual(result["items"][1]["cost"], 80.0)
+        self.assertNotIn("oneOff", result["items"][0])
+        self.assertTrue(result["items"][1]["oneOff"])
 
     def test_get_savings_returns_none_if_missing(self):
         # Mock empty query result
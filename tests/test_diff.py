@@ -0,0 +1,119 @@
+"""
+Tests for the what-changed diff between two months.
+"""
+
+import json
+import unittest
+from datetime import date
+from decimal import Decimal
+from unittest.mock import patch
+
+from fixtures import http_request
+from rmanalyzer.controller import controller
+from rmanalyzer.diff import largest_deltas, merchant_name
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+
+
+class TestDiff(unittest.TestCase):
+    def _transaction(self, name, amount, category, ignore=IgnoredFrom.NOTHING):
+        return Transaction(date(2025, 1, 1), name, 1, Decimal(amount), category, ignore)
+
+    def test_merchant_name(self):
+        self.assertEqual(merchant_name("Safeway  #1234"), "SAFEWAY")
+        self.assertEqual(merchant_name("TRADER JOE S 552"), "TRADER JOE S")
+        self.assertEqual(merchant_name("Netflix.com"), "NETFLIX.COM")
+        self.assertEqual(merchant_name("7-Eleven"), "7-ELEVEN")
+
+    def test_largest_deltas(self):
+        a = [
+            self._transaction("Safeway #1", "100.00", Category.GROCERIES),
+            self._transaction("Rent", "1000.00", Category.BILLS),
+        ]
+        b = [
+            self._transaction("Safeway #2", "80.00", Category.GROCERIES),
+            self._transaction("Safeway #3", "60.00", Category.GROCERIES),
+            self._transaction("Rent", "1000.00", Category.BILLS),
+            self._transaction("Vet", "25.00", Category.PETS),
+            self._transaction("Vet", "500.00", Category.PETS, IgnoredFrom.EVERYTHING),
+        ]
+
+        deltas = largest_deltas(a, b, lambda t: t.category.value, 5)
+
+        # Unchanged bills are left out; ignored vet visits don't count
+        self.assertEqual([d["key"] for d in deltas], ["Groceries", "Pets"])
+        self.assertEqual(deltas[0]["delta"], 40.0)
+        self.assertEqual(
+            [(t["period"], t["amount"]) for t in deltas[0]["transactions"]],
+            [("a", 100.0), ("b", 80.0), ("b", 60.0)],
+        )
+
+    def test_largest_deltas_limit(self):
+        b = [self._transaction(n, "10.00", Category.OTHER) for n in ["A", "B", "C"]]
+        deltas = largest_deltas([], b, lambda t: merchant_name(t.name), 2)
+        self.assertEqual(len(deltas), 2)
+
+
+class TestDiffReportController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", params={"a": "2025-01", "b": "2025-02"})
+        months = {
+            "2025-01": [
+                Transaction(
+                    date(2025, 1, 5),
+                    "Safeway #12",
+                    1,
+                    Decimal("100.00"),
+                    Category.GROCERIES,
+                    IgnoredFrom.NOTHING,
+                )
+            ],
+            "2025-02": [
+                Transaction(
+                    date(2025, 2, 5),
+                    "Safeway #12",
+                    1,
+                    Decimal("150.00"),
+                    Category.GROCERIES,
+                    IgnoredFrom.NOTHING,
+                ),
+                Transaction(
+                    date(2025, 2, 9),
+                    "Chewy",
+                    1,
+                    Decimal("20.00"),
+                    Category.PETS,
+                    IgnoredFrom.NOTHING,
+                ),
+            ],
+        }
+        patcher = patch.object(
+            controller.db_service, "get_transactions", side_effect=months.get
+        )
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def test_diff(self):
+        resp = controller.handle_diff_report(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["totals"], {"a": 100.0, "b": 170.0, "delta": 70.0})
+        self.assertEqual(
+            [(c["category"], c["delta"]) for c in payload["categories"]],
+            [("Groceries", 50.0), ("Pets", 20.0)],
+        )
+        self.assertEqual(payload["merchants"][0]["merchant"], "SAFEWAY")
+        self.assertEqual(len(payload["merchants"][0]["transactions"]), 2)
+
+    def test_limit(self):
+        self.req.params["limit"] = "1"
+        resp = controller.handle_diff_report(self.req)
+        self.assertEqual(len(json.loads(resp.get_body())["categories"]), 1)
+
+    def test_requires_both_months(self):
+        self.req.params = {"a": "2025-01"}
+        resp = controller.handle_diff_report(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,170 @@
+"""
+Tests for the documents archive.
+"""
+
+import io
+import json
+import os
+import unittest
+from unittest.mock import MagicMock, patch
+
+from fixtures import http_request, sign_in
+from rmanalyzer.controller import controller
+from rmanalyzer.documents import Document, blob_name, content_type
+from rmanalyzer.services import BlobKind
+
+DOCUMENT = Document(
+    document_id="a" * 32,
+    month="2025-05",
+    file_name="May statement.pdf",
+    content_type="application/pdf",
+    size=3,
+    blob_name=f"2025-05/{'a' * 32}_May_statement.pdf",
+    uploaded_by="owner@test.com",
+    uploaded_at="2025-06-01T09:30:00",
+    account_number=1234,
+)
+
+
+class TestDocument(unittest.TestCase):
+    """Test suite for the document model."""
+
+    def test_content_type_by_extension(self):
+        """Test that only archivable file types have a content type."""
+        self.assertEqual(content_type("scan.PDF"), "application/pdf")
+        self.assertEqual(content_type("receipt.jpeg"), "image/jpeg")
+        self.assertIsNone(content_type("page.html"))
+        self.assertIsNone(content_type("noextension"))
+
+    def test_blob_name_is_safe(self):
+        """Test that stored names keep to safe characters under the month."""
+        self.assertEqual(
+            blob_name("2025-05", "abc", "../My bill (1).pdf"),
+            "2025-05/abc_My_bill__1_.pdf",
+        )
+
+    def test_entity_round_trip(self):
+        """Test that unset optional fields are left off the entity."""
+        entity = DOCUMENT.to_entity("default_2025-05")
+
+        self.assertNotIn("Description", entity)
+        self.assertEqual(Document.from_entity(entity), DOCUMENT)
+
+
+class TestDocumentsController(unittest.TestCase):
+    """Test suite for filing, listing and deleting documents."""
+
+    def setUp(self):
+        self.req = http_request(
+            "owner@test.com",
+            method="GET",
+            params={"month": "2025-05"},
+            route_params={"id": DOCUMENT.document_id},
+        )
+
+        patches = {
+            "list": patch.object(
+                controller.db_service, "get_documents", return_value=[DOCUMENT]
+            ),
+            "get": patch.object(
+                controller.db_service, "get_document", return_value=DOCUMENT
+            ),
+            "save": patch.object(controller.db_service, "save_document"),
+            "delete": patch.object(controller.db_service, "delete_document"),
+            "activity": patch.object(controller.db_service, "record_activity"),
+            "url": patch.object(
+                controller.blob_service,
+                "download_url",
+                return_value="https://blob.test/doc?sig=x",
+            ),
+            "upload": patch.object(controller.blob_service, "upload_blob"),
+            "delete_blob": patch.object(controller.blob_service, "delete_blob"),
+        }
+        self.mocks = {name: p.start() for name, p in patches.items()}
+        self.addCleanup(patch.stopall)
+
+    def _attach(self, file_name, content=b"%PDF"):
+        uploaded = MagicMock()
+        uploaded.filename = file_name
+        uploaded.stream = io.BytesIO(content)
+        self.req.files = {"file": uploaded}
+
+    def test_list_with_links(self):
+        """Test that a month's documents come with expiring download links."""
+        self.req.params = {"month": "2025-05", "accountNumber": "1234"}
+
+        resp = controller.handle_documents(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.mocks["list"].assert_called_once_with("2025-05", 1234)
+        (listed,) = json.loads(resp.get_body())["documents"]
+        self.assertEqual(listed["fileName"], "May statement.pdf")
+        self.assertEqual(listed["downloadUrl"], "https://blob.test/doc?sig=x")
+        self.assertIn("expiresAt", listed)
+        self.mocks["url"].assert_called_once()
+        args = self.mocks["url"].call_args[0]
+        self.assertEqual(args[:2], (BlobKind.DOCUMENTS, DOCUMENT.blob_name))
+        self.assertEqual(args[3:], ("May statement.pdf", "application/pdf"))
+
+    def test_list_needs_month(self):
+        """Test that listing requires a month."""
+        self.req.params = {}
+
+        self.assertEqual(controller.handle_documents(self.req).status_code, 400)
+
+    def test_file_document(self):
+        """Test that an upload is stored under its month and recorded."""
+        self.req.method = "POST"
+        self.req.params = {"month": "2025-05", "description": "Card statement"}
+        self._attach("May.pdf")
+
+        resp = controller.handle_documents(self.req)
+
+        self.assertEqual(resp.status_code, 201)
+        kind, name, content = self.mocks["upload"].call_args[0]
+        self.assertEqual(kind, BlobKind.DOCUMENTS)
+        self.assertTrue(name.startswith("2025-05/"))
+        self.assertEqual(content, b"%PDF")
+        saved = self.mocks["save"].call_args[0][0]
+        self.assertEqual(saved.uploaded_by, "owner@test.com")
+        self.assertEqual(saved.description, "Card statement")
+        self.assertEqual(json.loads(resp.get_body())["id"], saved.document_id)
+
+    def test_rejects_unsafe_types(self):
+        """Test that files that could render in a browser aren't accepted."""
+        self.req.method = "POST"
+        self._attach("page.html", b"<script>")
+
+        resp = controller.handle_documents(self.req)
+
+        self.assertEqual(resp.status_code, 400)
+        self.mocks["upload"].assert_not_called()
+
+    @patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+    def test_delete_by_owner_or_admin(self):
+        """Test that only whoever filed a document or an admin can delete it."""
+        self.req.method = "DELETE"
+        sign_in(self.req, "other@test.com")
+        self.assertEqual(controller.handle_document(self.req).status_code, 403)
+        self.mocks["delete_blob"].assert_not_called()
+
+        sign_in(self.req, "admin@test.com")
+        self.assertEqual(controller.handle_document(self.req).status_code, 204)
+        self.mocks["delete_blob"].assert_called_once_with(
+            DOCUMENT.blob_name, BlobKind.DOCUMENTS
+        )
+        self.mocks["delete"].assert_called_once_with(DOCUMENT)
+
+    def test_unknown_document(self):
+        """Test that malformed and unknown ids are not found."""
+        self.req.route_params = {"id": "../x"}
+        self.assertEqual(controller.handle_document(self.req).status_code, 404)
+        self.mocks["get"].assert_not_called()
+
+        self.req.route_params = {"id": "b" * 32}
+        self.mocks["get"].return_value = None
+        self.assertEqual(controller.handle_document(self.req).status_code, 404)
+
+
+if __name__ == "__main__":
+    unittest.main()
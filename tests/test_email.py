@@ -4,7 +4,7 @@ from datetime import date
 from decimal import Decimal
 import os
 
-from rmanalyzer.services import EmailService, EmailRenderer
+from rmanalyzer.services import EmailAttachment, EmailService, EmailRenderer
 from rmanalyzer.models import Category, Group, IgnoredFrom, Person, Transaction
 
 
@@ -47,6 +47,100 @@ class TestEmailer(unittest.TestCase):
         self.assertIn("Upload Failed", body)
         self.assertIn("Critical Error", body)
 
+    def test_render_body_personalized(self):
+        """Test that the recipient's row comes first and the debt line addresses them."""
+        body = EmailRenderer.render_body(self.group, recipient=self.p2)
+        self.assertLess(body.index("Bob"), body.index("Alice"))
+        # Alice spent 10, Bob 0: Bob owes Alice 5
+        self.assertIn("You owe Alice: <strong>5.00</strong>", body)
+
+        body = EmailRenderer.render_body(self.group, recipient=self.p1)
+        self.assertLess(body.index("Alice"), body.index("Bob"))
+        self.assertIn("Bob owes you: <strong>5.00</strong>", body)
+
+    def test_render_body_outstanding_balance(self):
+        """Test that the ledger balance after settlements is shown with the debt."""
+        outstanding = {"debtor": "bob@example.com", "creditor": "alice@example.com"}
+        body = EmailRenderer.render_body(
+            self.group, outstanding={**outstanding, "amount": 12.5}
+        )
+        self.assertIn(
+            "Outstanding balance after settlements: <strong>12.50</strong>", body
+        )
+        self.assertIn("(Bob owes Alice)", body)
+
+        body = EmailRenderer.render_body(
+            self.group, outstanding={"debtor": None, "creditor": None, "amount": 0.0}
+        )
+        self.assertIn("settlements: <strong>0.00</strong>", body)
+        self.assertNotIn(" owes Alice)", body)
+
+    def test_render_body_unassigned(self):
+        """Test that unassigned transactions are summarized per account."""
+        self.group.unassigned = [
+            Transaction(
+                date(2025, 8, 1),
+                "X",
+                77,
+                Decimal("4.5"),
+                Category.DINING,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+        body = EmailRenderer.render_body(self.group)
+        self.assertIn("Unassigned transactions", body)
+        self.assertIn("Account 77: 4.50", body)
+
+        self.group.unassigned = []
+        body = EmailRenderer.render_body(self.group)
+        self.assertNotIn("Unassigned transactions", body)
+
+    def test_user_values_are_escaped(self):
+        """Test that names, errors and transaction details can't inject HTML."""
+        self.p1.name = "<b>Alice</b>"
+        body = EmailRenderer.render_body(self.group, errors=["Row 1: <script>"])
+        self.assertIn("&lt;b&gt;Alice&lt;/b&gt;", body)
+        self.assertIn("Row 1: &lt;script&gt;", body)
+        self.assertNotIn("<b>Alice", body)
+
+        body = EmailRenderer.render_review_packet(
+            {
+                "month": "2025-05",
+                "summary": {
+                    "total": 10.0,
+                    "people": [{"name": "<i>Bob</i>", "total": 10.0}],
+                    "categories": [],
+                },
+                "budgets": [],
+                "unusual": [
+                    {
+                        "date": "2025-05-01",
+                        "name": '<img src="x">',
+                        "accountNumber": 1,
+                        "amount": 10.0,
+                    }
+                ],
+                "duplicates": [],
+                "unassigned": [],
+            }
+        )
+        self.assertIn("&lt;i&gt;Bob&lt;/i&gt;", body)
+        self.assertIn("&lt;img src=&quot;x&quot;&gt;", body)
+
+        body = EmailRenderer.render_payment_reminder(
+            [
+                {
+                    "institution": "<u>Bank</u>",
+                    "mask": "1234",
+                    "dueDate": "2025-05-10",
+                    "balance": 5.0,
+                    "ackUrl": 'https://x.test/ack?a=1&b="2"',
+                }
+            ]
+        )
+        self.assertIn("&lt;u&gt;Bank&lt;/u&gt; ending 1234", body)
+        self.assertIn('href="https://x.test/ack?a=1&amp;b=&quot;2&quot;"', body)
+
     def test_render_subject(self):
         """Test generating the email subject."""
         subject = EmailRenderer.render_subject(self.group)
@@ -79,6 +173,147 @@ class TestEmailer(unittest.TestCase):
         self.assertEqual(message["recipients"]["to"][0]["address"], "alice@example.com")
         self.assertEqual(message["content"]["subject"], "Test Subject")
 
+    @patch("rmanalyzer.services.email_service.EmailClient")
+    @patch("rmanalyzer.services.email_service.DefaultAzureCredential")
+    def test_send_emails_batches(self, _, mock_email_client):
+        """Test that all sends start before any result is awaited."""
+        os.environ["COMMUNICATION_SERVICES_ENDPOINT"] = (
+            "https://test.communication.azure.com"
+        )
+        calls = []
+        mock_client_instance = mock_email_client.return_value
+
+        def begin_send(message):
+            address = message["recipients"]["to"][0]["address"]
+            calls.append(f"begin:{address}")
+            poller = unittest.mock.Mock()
+            poller.result.side_effect = lambda: calls.append(f"result:{address}")
+            return poller
+
+        mock_client_instance.begin_send.side_effect = begin_send
+
+        service = EmailService()
+        service.send_emails(
+            [(["a@example.com"], "S", "A"), (["b@example.com"], "S", "B")]
+        )
+
+        self.assertEqual(
+            calls,
+            [
+                "begin:a@example.com",
+                "begin:b@example.com",
+                "result:a@example.com",
+                "result:b@example.com",
+            ],
+        )
+
+    @patch("rmanalyzer.services.email_service.EmailClient")
+    @patch("rmanalyzer.services.email_service.DefaultAzureCredential")
+    def test_send_emails_reports_failures(self, _, mock_email_client):
+        """Test that a failed send doesn't stop the others but is reported."""
+        os.environ["COMMUNICATION_SERVICES_ENDPOINT"] = (
+            "https://test.communication.azure.com"
+        )
+        mock_client_instance = mock_email_client.return_value
+        mock_client_instance.begin_send.side_effect = [
+            RuntimeError("throttled"),
+            unittest.mock.Mock(),
+        ]
+
+        service = EmailService()
+        with self.assertRaises(RuntimeError):
+            service.send_emails(
+                [(["a@example.com"], "S", "A"), (["b@example.com"], "S", "B")]
+            )
+        self.assertEqual(mock_client_instance.begin_send.call_count, 2)
+
+    @patch("rmanalyzer.services.email_service.EmailClient")
+    @patch("rmanalyzer.services.email_service.DefaultAzureCredential")
+    def test_send_emails_reports_deliveries(self, _, mock_email_client):
+        """Test that on_delivered hears each completed send with its message ID."""
+        os.environ["COMMUNICATION_SERVICES_ENDPOINT"] = (
+            "https://test.communication.azure.com"
+        )
+        delivered = unittest.mock.Mock()
+        delivered.result.return_value = {"id": "m1"}
+        mock_email_client.return_value.begin_send.side_effect = [
+            delivered,
+            RuntimeError("throttled"),
+        ]
+        on_delivered = unittest.mock.Mock()
+
+        with self.assertRaises(RuntimeError):
+            EmailService().send_emails(
+                [(["a@example.com"], "S", "A"), (["b@example.com"], "S", "B")],
+                on_delivered=on_delivered,
+            )
+        on_delivered.assert_called_once_with(["a@example.com"], "m1")
+
+    @patch("rmanalyzer.services.email_service.EmailClient")
+    @patch("rmanalyzer.services.email_service.DefaultAzureCredential")
+    def test_sent_emails_are_counted(self, _, mock_email_client):
+        """Test that on_sent hears how many emails went out, failures excluded."""
+        os.environ["COMMUNICATION_SERVICES_ENDPOINT"] = (
+            "https://test.communication.azure.com"
+        )
+        mock_client_instance = mock_email_client.return_value
+        service = EmailService()
+        service.on_sent = unittest.mock.Mock()
+
+        service.send_email(["a@example.com"], "S", "B")
+        service.on_sent.assert_called_once_with(1)
+
+        mock_client_instance.begin_send.side_effect = [
+            RuntimeError("throttled"),
+            unittest.mock.Mock(),
+        ]
+        with self.assertRaises(RuntimeError):
+            service.send_emails(
+                [(["a@example.com"], "S", "A"), (["b@example.com"], "S", "B")]
+            )
+        service.on_sent.assert_called_with(1)
+
+        # A failure to count doesn't fail the send
+        mock_client_instance.begin_send.side_effect = None
+        service.on_sent.side_effect = RuntimeError("table down")
+        service.send_email(["a@example.com"], "S", "B")
+
+    @patch("rmanalyzer.services.email_service.EmailClient")
+    @patch("rmanalyzer.services.email_service.DefaultAzureCredential")
+    def test_send_emails_with_attachments(self, _, mock_email_client):
+        """Test that attachments are base64-encoded onto every message."""
+        os.environ["COMMUNICATION_SERVICES_ENDPOINT"] = (
+            "https://test.communication.azure.com"
+        )
+        mock_client_instance = mock_email_client.return_value
+
+        service = EmailService()
+        service.send_emails(
+            [(["a@example.com"], "S", "A"), (["b@example.com"], "S", "B")],
+            [EmailAttachment("statement.csv", "text/csv", b"Date,Name\n")],
+        )
+
+        for call in mock_client_instance.begin_send.call_args_list:
+            attachment = call[0][0]["attachments"][0]
+            self.assertEqual(attachment["name"], "statement.csv")
+            self.assertEqual(attachment["contentType"], "text/csv")
+            self.assertEqual(attachment["contentInBase64"], "RGF0ZSxOYW1lCg==")
+
+    @patch("rmanalyzer.services.email_service.EmailClient")
+    @patch("rmanalyzer.services.email_service.DefaultAzureCredential")
+    def test_send_email_high_priority(self, _, mock_email_client):
+        """Test that high-priority emails carry the x-priority header."""
+        os.environ["COMMUNICATION_SERVICES_ENDPOINT"] = (
+            "https://test.communication.azure.com"
+        )
+        mock_client_instance = mock_email_client.return_value
+
+        service = EmailService()
+        service.send_email(["a@example.com"], "S", "B", high_priority=True)
+
+        message = mock_client_instance.begin_send.call_args[0][0]
+        self.assertEqual(message["headers"], {"x-priority": "1"})
+
     def test_init_missing_config(self):
         """Test that EmailService raises ValueError if config is missing."""
         if "COMMUNICATION_SERVICES_ENDPOINT" in os.environ:
@@ -122,3 +357,53 @@ class TestEmailer(unittest.TestCase):
 
         body2 = EmailRenderer.render_body(group2)
         self.assertIn("Bob owes Alice: <strong>5.00</strong>", body2)
+
+    def test_render_monthly_report(self):
+        report = {
+            "month": "2025-05",
+            "total": 150.0,
+            "people": [{"name": "Alice", "email": "a@test.com", "total": 150.0}],
+            "categories": [{"category": "Groceries", "total": 150.0}],
+            "budgets": [
+                {
+                    "category": "Groceries",
+                    "limit": 200.0,
+                    "spent": 150.0,
+                    "remaining": 50.0,
+                    "overBudget": False,
+                }
+            ],
+            "debt": None,
+        }
+
+        body = EmailRenderer.render_monthly_report(report)
+
+        self.assertIn("Monthly Report: 2025-05", body)
+        self.assertIn("<strong>150.00</strong>", body)
+        self.assertIn("50.00 left", body)
+        self.assertIn("exactly two members", body)
+
+    def test_render_monthly_report_escapes_names(self):
+        report = {
+            "month": "2025-05",
+            "total": 10.0,
+            "people": [{"name": "<b>Al</b>", "total": 10.0}],
+            "categories": [{"category": "Kids & <i>Toys</i>", "total": 10.0}],
+            "budgets": [
+                {
+                    "category": "Kids & <i>Toys</i>",
+                    "limit": 5.0,
+                    "spent": 10.0,
+                    "remaining": -5.0,
+                    "overBudget": True,
+                }
+            ],
+            "debt": {"from": "<b>Al</b>", "to": "Bo", "amount": 5.0, "settled": 0},
+        }
+
+        body = EmailRenderer.render_monthly_report(report)
+
+        self.assertNotIn("<b>", body)
+        self.assertNotIn("<i>", body)
+        self.assertIn("Kids &amp; &lt;i&gt;Toys&lt;/i&gt;", body)
+        self.assertIn("&lt;b&gt;Al&lt;/b&gt; owes Bo", body)
@@ -0,0 +1,54 @@
+"""
+Tests for emergency fund coverage.
+"""
+
+import unittest
+from datetime import date, datetime
+from decimal import Decimal
+
+from rmanalyzer.emergency import (
+    average_monthly_spend,
+    coverage_months,
+    previous_months,
+)
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+
+
+class TestEmergencyFund(unittest.TestCase):
+    def _transaction(self, amount, category, ignore=IgnoredFrom.NOTHING):
+        return Transaction(date(2025, 1, 1), "X", 1, Decimal(amount), category, ignore)
+
+    def test_previous_months(self):
+        self.assertEqual(
+            previous_months(datetime(2025, 2, 15), 3),
+            ["2024-11", "2024-12", "2025-01"],
+        )
+
+    def test_average_monthly_spend(self):
+        monthly = {
+            "2024-12": [
+                self._transaction("100.00", Category.GROCERIES),
+                self._transaction("50.00", Category.BILLS),
+                # Discretionary and ignored spending doesn't count
+                self._transaction("80.00", Category.DINING),
+                self._transaction("30.00", Category.GROCERIES, IgnoredFrom.EVERYTHING),
+            ],
+            "2025-01": [self._transaction("250.00", Category.BILLS)],
+            # Months without data are left out of the average
+            "2025-02": [],
+        }
+
+        spend = average_monthly_spend(monthly, [Category.GROCERIES, Category.BILLS])
+
+        self.assertEqual(spend, Decimal("200.00"))
+
+    def test_average_monthly_spend_no_data(self):
+        self.assertEqual(average_monthly_spend({}, [Category.BILLS]), Decimal("0.00"))
+
+    def test_coverage_months(self):
+        self.assertEqual(coverage_months(Decimal("1000"), Decimal("300")), 3.3)
+        self.assertIsNone(coverage_months(Decimal("1000"), Decimal("0")))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,153 @@
+"""
+Tests for converting foreign currency transactions.
+"""
+
+import unittest
+from datetime import date, timedelta
+from decimal import Decimal
+from unittest.mock import MagicMock, patch
+
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.services import ExchangeRateService, RateProvider
+
+DAY = date(2025, 1, 15)
+
+
+def _transaction(amount, currency=None, day=DAY):
+    return Transaction(
+        day,
+        "Hotel",
+        1234,
+        Decimal(amount),
+        Category.TRAVEL,
+        IgnoredFrom.NOTHING,
+        currency=currency,
+    )
+
+
+class TestExchangeRateService(unittest.TestCase):
+    """Test suite for ExchangeRateService."""
+
+    def setUp(self):
+        self.fetch = MagicMock(return_value={"EUR": Decimal("0.92")})
+        self.db = MagicMock()
+        self.db.get_exchange_rates.return_value = None
+        with patch.dict("os.environ", {"HOME_CURRENCY": "usd"}):
+            self.service = ExchangeRateService(
+                self.db, RateProvider("test", self.fetch)
+            )
+
+    def test_converts_to_home_currency(self):
+        """Test that foreign amounts are converted and the original kept."""
+        transactions, errors = self.service.normalize(
+            [_transaction("100.00", "EUR"), _transaction("5.00", "USD")]
+        )
+
+        self.assertEqual(errors, [])
+        hotel, local = transactions
+        self.assertEqual(hotel.amount, Decimal("108.70"))
+        self.assertEqual(hotel.original_amount, Decimal("100.00"))
+        self.assertEqual(hotel.currency, "EUR")
+        self.assertEqual(local.amount, Decimal("5.00"))
+        self.assertIsNone(local.currency)
+        self.fetch.assert_called_once_with("USD", DAY)
+
+    def test_unknown_currency_is_an_error(self):
+        """Test that transactions without a rate are left out with an error."""
+        transactions, errors = self.service.normalize([_transaction("1", "XYZ")])
+
+        self.assertEqual(transactions, [])
+        self.assertEqual(
+            errors, ["Hotel on 2025-01-15: No exchange rate from XYZ to USD"]
+        )
+
+    def test_past_days_are_cached(self):
+        """Test that a finished day's rates are fetched once and stored."""
+        self.service.rates(DAY)
+        self.service.rates(DAY)
+
+        self.fetch.assert_called_once()
+        self.db.save_exchange_rates.assert_called_once_with(
+            "USD", DAY, {"EUR": Decimal("0.92")}
+        )
+
+    def test_stored_rates_are_used(self):
+        """Test that rates cached by another worker aren't fetched again."""
+        self.db.get_exchange_rates.return_value = {"EUR": Decimal("0.5")}
+
+        self.assertEqual(self.service.rates(DAY), {"EUR": Decimal("0.5")})
+        self.fetch.assert_not_called()
+
+    def test_today_is_not_cached(self):
+        """Test that today's (and future) rates, maybe unpublished, aren't stored."""
+        tomorrow = date.today() + timedelta(days=1)
+        transactions, _ = self.service.normalize(
+            [_transaction("1", "EUR", tomorrow), _transaction("2", "EUR", tomorrow)]
+        )
+
+        self.assertEqual(len(transactions), 2)
+        self.fetch.assert_called_once_with("USD", date.today())
+        self.db.save_exchange_rates.assert_not_called()
+
+    @patch.dict(
+        "os.environ",
+        {"EXCHANGE_RATE_PROVIDER": "static", "EXCHANGE_RATES": '{"eur": "0.8"}'},
+    )
+    def test_static_provider(self):
+        """Test that configured rates are used as they are, without caching."""
+        service = ExchangeRateService(self.db)
+
+        transactions, _ = service.normalize([_transaction("10.00", "EUR")])
+
+        self.assertEqual(transactions[0].amount, Decimal("12.50"))
+        self.db.save_exchange_rates.assert_not_called()
+
+    @patch.dict("os.environ", {"EXCHANGE_RATE_PROVIDER": "oanda"})
+    def test_unknown_provider(self):
+        """Test that an unknown provider name is an error only once rates are needed."""
+        service = ExchangeRateService(self.db)
+
+        transactions, _ = service.normalize([_transaction("5.00")])
+        self.assertEqual(len(transactions), 1)
+        with self.assertRaisesRegex(ValueError, "oanda, expected one of"):
+            service.normalize([_transaction("1", "EUR")])
+
+    def test_unavailable_rates_keep_the_charged_amount(self):
+        """Test that rates that can't be fetched leave transactions to convert later."""
+        self.fetch.side_effect = OSError("timed out")
+
+        transactions, errors = self.service.normalize(
+            [_transaction("100.00", "EUR"), _transaction("50.00", "EUR")]
+        )
+
+        self.assertEqual(errors, [])
+        self.assertEqual(
+            [t.amount for t in transactions], [Decimal("100.00"), Decimal("50.00")]
+        )
+        self.assertTrue(all(t.currency == "EUR" for t in transactions))
+        self.assertTrue(all(t.original_amount is None for t in transactions))
+        self.fetch.assert_called_once()
+
+    def test_convert_saved(self):
+        """Test that saved transactions are converted and paired with their IDs."""
+        converted = self.service.convert_saved(
+            [("a", _transaction("1", "XYZ")), ("b", _transaction("100.00", "EUR"))]
+        )
+
+        self.assertEqual(len(converted), 1)
+        transaction_id, hotel = converted[0]
+        self.assertEqual(transaction_id, "b")
+        self.assertEqual(hotel.amount, Decimal("108.70"))
+        self.assertEqual(hotel.original_amount, Decimal("100.00"))
+
+    def test_convert_saved_leaves_unavailable_rates(self):
+        """Test that saved transactions stay flagged while rates are unavailable."""
+        self.fetch.side_effect = OSError("timed out")
+
+        self.assertEqual(
+            self.service.convert_saved([("b", _transaction("100.00", "EUR"))]), []
+        )
+
+
+if __name__ == "__main__":
+    unittest.main()
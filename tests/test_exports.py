@@ -0,0 +1,387 @@
+"""
+Tests for CSV and Excel exports.
+"""
+
+import json
+import unittest
+from datetime import date
+from decimal import Decimal
+from unittest.mock import patch
+
+from fixtures import http_request
+from rmanalyzer.controller import controller
+from rmanalyzer.exports import (
+    TRANSACTION_COLUMNS,
+    budget_status_csv,
+    savings_csv,
+    transactions_csv,
+    transactions_csv_stream,
+    transactions_ndjson_stream,
+    transactions_xlsx_stream,
+)
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.utils import get_transactions
+from rmanalyzer.xlsx import XLSX_MEDIA_TYPE, get_xlsx_transactions
+
+
+class TestExports(unittest.TestCase):
+    def test_savings_csv_running_totals(self):
+        data = {
+            "startingBalance": 1000.0,
+            "items": [{"name": "Rent", "cost": 600}, {"name": "Gym", "cost": 50.5}],
+        }
+
+        lines = savings_csv(data).splitlines()
+
+        self.assertEqual(lines[0], "Item,Cost,Cumulative Cost,Remaining,Percent Used")
+        self.assertEqual(lines[1], "Rent,600.00,600.00,400.00,60.0")
+        self.assertEqual(lines[2], "Gym,50.50,650.50,349.50,65.0")
+
+    def test_savings_csv_zero_balance(self):
+        data = {"startingBalance": 0, "items": [{"name": "A", "cost": 10}]}
+        lines = savings_csv(data).splitlines()
+        self.assertEqual(lines[1], "A,10.00,10.00,-10.00,")
+
+    def test_budget_status_csv_sorted_by_month(self):
+        months = {
+            "2025-02": {"startingBalance": 200, "items": [{"name": "x", "cost": 250}]},
+            "2025-01": {"startingBalance": 100, "items": []},
+        }
+
+        lines = budget_status_csv(months).splitlines()
+
+        self.assertEqual(len(lines), 3)
+        self.assertEqual(lines[1], "2025-01,100.00,0.00,100.00,0.0")
+        self.assertEqual(lines[2], "2025-02,200.00,250.00,-50.00,125.0")
+
+    def test_transactions_csv_uses_upload_columns(self):
+        transactions = [
+            Transaction(
+                date(2025, 1, 9),
+                "Chewy",
+                2,
+                Decimal("20"),
+                Category.PETS,
+                IgnoredFrom.BUDGET,
+                "a@test.com",
+            ),
+            Transaction(
+                date(2025, 1, 5),
+                "Safeway",
+                1,
+                Decimal("100.5"),
+                Category.GROCERIES,
+                IgnoredFrom.NOTHING,
+            ),
+        ]
+
+        lines = transactions_csv(transactions).splitlines()
+
+        self.assertEqual(
+            lines[0], "Date,Name,Account Number,Amount,Category,Ignored From,Owner"
+        )
+        self.assertEqual(lines[1], "2025-01-05,Safeway,1,100.50,Groceries,,")
+        self.assertEqual(lines[2], "2025-01-09,Chewy,2,20.00,Pets,budget,a@test.com")
+
+    def test_transactions_csv_stream_yields_a_line_per_row(self):
+        transactions = iter(
+            [
+                Transaction(
+                    date(2025, 1, 9),
+                    "Chewy, Inc",
+                    2,
+                    Decimal("20"),
+                    Category.PETS,
+                    IgnoredFrom.BUDGET,
+                ),
+            ]
+        )
+
+        chunks = list(transactions_csv_stream(transactions))
+
+        self.assertEqual(len(chunks), 2)
+        self.assertEqual(chunks[1], '2025-01-09,"Chewy, Inc",2,20.00,Pets,budget,\n')
+
+    def test_formulas_neutralized_in_every_csv(self):
+        transaction = Transaction(
+            date(2025, 1, 9),
+            '=HYPERLINK("x")',
+            2,
+            Decimal("-20"),
+            Category.PETS,
+            IgnoredFrom.NOTHING,
+        )
+
+        row = list(transactions_csv_stream([transaction]))[1]
+        self.assertEqual(row, '2025-01-09,"\'=HYPERLINK(""x"")",2,-20.00,Pets,,\n')
+        self.assertIn("'=HYPERLINK", transactions_csv([transaction]))
+
+        lines = savings_csv(
+            {"startingBalance": 10, "items": [{"name": "@SUM(A1)", "cost": 20}]}
+        ).splitlines()
+        self.assertEqual(lines[1], "'@SUM(A1),20.00,20.00,-10.00,200.0")
+
+    def test_neutralized_csv_round_trips(self):
+        transaction = Transaction(
+            date(2025, 1, 9),
+            '=HYPERLINK("x")',
+            2,
+            Decimal("-20"),
+            Category.PETS,
+            IgnoredFrom.NOTHING,
+        )
+
+        content = "".join(transactions_csv_stream([transaction]))
+        (parsed,), errors = get_transactions(content)
+
+        self.assertEqual(errors, [])
+        self.assertEqual(parsed.name, '=HYPERLINK("x")')
+        self.assertEqual(parsed.amount, Decimal("-20.00"))
+
+    def test_transactions_xlsx_stream_round_trips(self):
+        transactions = [
+            Transaction(
+                date(2025, 1, 9),
+                "Chewy, Inc",
+                2,
+                Decimal("20.00"),
+                Category.PETS,
+                IgnoredFrom.BUDGET,
+            )
+        ]
+
+        content = b"".join(transactions_xlsx_stream(transactions))
+
+        self.assertEqual(get_xlsx_transactions(content), (transactions, []))
+
+    def test_xlsx_control_characters_round_trip(self):
+        transactions = [
+            Transaction(
+                date(2025, 1, 9),
+                "Bell\x01 _x0041_ Co",
+                2,
+                Decimal("20.00"),
+                Category.PETS,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+
+        content = b"".join(transactions_xlsx_stream(transactions))
+
+        self.assertEqual(get_xlsx_transactions(content), (transactions, []))
+
+    def test_transactions_ndjson_stream(self):
+        transactions = [
+            Transaction(
+                date(2025, 1, 5),
+                "Safeway",
+                1,
+                Decimal("100.5"),
+                Category.GROCERIES,
+                IgnoredFrom.NOTHING,
+                "a@test.com",
+            )
+        ]
+
+        (line,) = transactions_ndjson_stream(transactions)
+
+        self.assertTrue(line.endswith("\n"))
+        self.assertEqual(
+            json.loads(line),
+            {
+                "date": "2025-01-05",
+                "name": "Safeway",
+                "accountNumber": 1,
+                "amount": 100.5,
+                "category": "Groceries",
+                "ignore": "",
+                "owner": "a@test.com",
+                "currency": None,
+                "originalAmount": None,
+            },
+        )
+
+
+class TestExportControllers(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("user@test.com")
+
+    @patch("rmanalyzer.controller.controller.db_service.get_savings")
+    def test_savings_export(self, mock_get):
+        mock_get.return_value = {"startingBalance": 100, "items": []}
+        self.req.params = {"month": "2025-01"}
+
+        resp = controller.handle_savings_export(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(resp.mimetype, "text/csv")
+        self.assertIn("savings-2025-01.csv", resp.headers["Content-Disposition"])
+        mock_get.assert_called_once_with("2025-01", "user@test.com")
+
+    @patch("rmanalyzer.controller.controller.db_service.get_savings")
+    def test_savings_export_not_found(self, mock_get):
+        mock_get.return_value = None
+        resp = controller.handle_savings_export(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+    @patch("rmanalyzer.controller.controller.db_service.get_savings")
+    def test_budget_export_skips_missing_months(self, mock_get):
+        mock_get.side_effect = lambda month, _: (
+            {"startingBalance": 100, "items": []} if month == "2025-03" else None
+        )
+        self.req.params = {"year": "2025"}
+
+        resp = controller.handle_budget_export(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        lines = resp.get_body().decode("utf-8").splitlines()
+        self.assertEqual(len(lines), 2)
+        self.assertTrue(lines[1].startswith("2025-03,"))
+        self.assertEqual(mock_get.call_count, 12)
+
+    def test_budget_export_requires_year(self):
+        resp = controller.handle_budget_export(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    @patch("rmanalyzer.controller.controller.db_service.iter_transactions")
+    def test_transactions_export_streams_rows(self, mock_iter):
+        mock_iter.return_value = iter(
+            [
+                Transaction(
+                    date(2025, 1, 5),
+                    "Safeway",
+                    1,
+                    Decimal("10"),
+                    Category.GROCERIES,
+                    IgnoredFrom.NOTHING,
+                )
+            ]
+        )
+        self.req.params = {"from": "2024-12", "to": "2025-01", "format": "ndjson"}
+
+        stream = controller.handle_transactions_export(self.req)
+
+        self.assertEqual(stream.media_type, "application/x-ndjson")
+        self.assertEqual(stream.file_name, "transactions-2024-12-to-2025-01.ndjson")
+        mock_iter.assert_called_once_with(["2024-12", "2025-01"])
+        lines = list(stream.chunks)
+        self.assertEqual(len(lines), 1)
+        self.assertEqual(json.loads(lines[0])["name"], "Safeway")
+
+    @patch("rmanalyzer.controller.controller.db_service.iter_transactions")
+    def test_transactions_export_defaults_to_csv(self, mock_iter):
+        mock_iter.return_value = iter([])
+        self.req.params = {"from": "2025-01", "to": "2025-01"}
+
+        stream = controller.handle_transactions_export(self.req)
+
+        self.assertEqual(stream.media_type, "text/csv")
+        self.assertEqual(list(stream.chunks), [",".join(TRANSACTION_COLUMNS) + "\n"])
+
+    @patch("rmanalyzer.controller.controller.db_service.iter_transactions")
+    def test_export_month_as_xlsx(self, mock_iter):
+        mock_iter.return_value = iter([])
+        self.req.params = {"month": "2025-01", "format": "xlsx"}
+
+        stream = controller.handle_export(self.req)
+
+        mock_iter.assert_called_once_with(["2025-01"])
+        self.assertEqual(stream.file_name, "transactions-2025-01.xlsx")
+        self.assertEqual(stream.media_type, XLSX_MEDIA_TYPE)
+        self.assertTrue(b"".join(stream.chunks).startswith(b"PK"))
+
+    def test_export_requires_month(self):
+        self.req.params = {"format": "xlsx"}
+        resp = controller.handle_export(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    def test_export_rejects_ndjson(self):
+        self.req.params = {"month": "2025-01", "format": "ndjson"}
+        resp = controller.handle_export(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    def test_transactions_export_rejects_long_range(self):
+        self.req.params = {"from": "2000-01", "to": "2025-01"}
+        resp = controller.handle_transactions_export(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    @patch("rmanalyzer.controller.controller.db_service.iter_transactions")
+    def test_transactions_export_filter(self, mock_iter):
+        mock_iter.return_value = iter(
+            [
+                Transaction(
+                    date(2025, 1, d),
+                    name,
+                    1,
+                    Decimal(amount),
+                    Category.GROCERIES,
+                    IgnoredFrom.NOTHING,
+                )
+                for d, name, amount in [(5, "Safeway", "10"), (6, "Costco", "120")]
+            ]
+        )
+        self.req.params = {
+            "from": "2025-01",
+            "to": "2025-01",
+            "format": "ndjson",
+            "filter": "amount>100 and merchant~costco",
+        }
+
+        stream = controller.handle_transactions_export(self.req)
+
+        self.assertEqual(
+            [json.loads(line)["name"] for line in stream.chunks], ["Costco"]
+        )
+
+    def test_transactions_export_invalid_filter(self):
+        self.req.params = {"from": "2025-01", "to": "2025-01", "filter": "amount>"}
+        resp = controller.handle_transactions_export(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(json.loads(resp.get_body())["fields"][0]["field"], "filter")
+
+    @patch("rmanalyzer.controller.controller.db_service.get_keyed_transactions")
+    def test_list_transactions(self, mock_get):
+        mock_get.return_value = [
+            (
+                f"r{d}",
+                Transaction(
+                    date(2025, 1, d),
+                    "Market",
+                    1,
+                    Decimal(amount),
+                    Category.GROCERIES,
+                    IgnoredFrom.NOTHING,
+                ),
+            )
+            for d, amount in [(3, "20"), (9, "150"), (12, "80")]
+        ]
+        self.req.params = {
+            "month": "2025-01",
+            "filter": 'category:"Groceries" and amount>50',
+        }
+
+        resp = controller.handle_transactions(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertEqual([t["id"] for t in payload["transactions"]], ["r12", "r9"])
+        self.assertEqual(payload["transactions"][1]["amount"], 150.0)
+        mock_get.assert_called_once_with("2025-01")
+
+        self.req.params = {"month": "2025-01", "sort": "largest"}
+        payload = json.loads(controller.handle_transactions(self.req).get_body())
+        self.assertEqual(payload["sort"], "largest")
+        self.assertEqual(
+            [t["id"] for t in payload["transactions"]], ["r9", "r12", "r3"]
+        )
+
+        self.req.params = {"sort": "random"}
+        self.assertEqual(controller.handle_transactions(self.req).status_code, 400)
+
+    def test_list_transactions_invalid_filter(self):
+        self.req.params = {"filter": "owner~"}
+        resp = controller.handle_transactions(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,70 @@
+"""
+Tests for card annual fee tracking.
+"""
+
+import unittest
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.fees import card_spend, fee_summary, next_fee_date, upcoming_fees
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+
+
+class TestFees(unittest.TestCase):
+    def _transaction(self, account, amount, ignore=IgnoredFrom.NOTHING):
+        return Transaction(
+            date(2025, 1, 5), "Store", account, Decimal(amount), Category.OTHER, ignore
+        )
+
+    def test_next_fee_date(self):
+        today = date(2025, 6, 15)
+        self.assertEqual(next_fee_date(6, today), date(2025, 6, 1))
+        self.assertEqual(next_fee_date(9, today), date(2025, 9, 1))
+        self.assertEqual(next_fee_date(2, today), date(2026, 2, 1))
+
+    def test_upcoming_fees(self):
+        accounts = [
+            {"accountId": "later", "annualFee": 95.0, "feeMonth": 9},
+            {"accountId": "soon", "annualFee": 550.0, "feeMonth": 7},
+            {"accountId": "no-fee", "annualFee": 0, "feeMonth": 7},
+            {"accountId": "no-month", "annualFee": 95.0},
+        ]
+
+        upcoming = upcoming_fees(accounts, date(2025, 6, 15))
+
+        self.assertEqual([a["accountId"] for a in upcoming], ["soon"])
+        self.assertEqual(upcoming[0]["feeDate"], "2025-07-01")
+
+    def test_card_spend_skips_credits_and_ignored(self):
+        transactions = [
+            self._transaction(1234, "100.00"),
+            self._transaction(1234, "-40.00"),
+            self._transaction(1234, "25.00", IgnoredFrom.EVERYTHING),
+            self._transaction(5678, "300.00"),
+        ]
+        self.assertEqual(card_spend(transactions, "1234"), Decimal("100.00"))
+        self.assertEqual(card_spend(transactions, None), Decimal("0.00"))
+
+    def test_fee_summary(self):
+        account = {
+            "accountId": "acc-1",
+            "institution": "Chase",
+            "mask": "1234",
+            "annualFee": 95.0,
+            "feeMonth": 3,
+        }
+
+        summary = fee_summary(
+            account, [self._transaction(1234, "3800.00")], date(2025, 6, 1)
+        )
+
+        self.assertEqual(summary["feeDate"], "2026-03-01")
+        self.assertEqual(summary["spend"], 3800.0)
+        self.assertEqual(summary["feeRate"], 0.025)
+
+        unused = fee_summary(account, [], date(2025, 6, 1))
+        self.assertIsNone(unused["feeRate"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,100 @@
+"""
+Tests for transaction filter expressions.
+"""
+
+import unittest
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.filters import FilterError, parse
+from rmanalyzer.models import Category, IgnoredFrom, Transaction, category_names
+
+
+class TestFilters(unittest.TestCase):
+    def setUp(self):
+        self.costco = Transaction(
+            date(2025, 3, 2),
+            "COSTCO WHSE #123",
+            1,
+            Decimal("150.00"),
+            Category.GROCERIES,
+            IgnoredFrom.NOTHING,
+        )
+        self.cafe = Transaction(
+            date(2025, 3, 20),
+            "Corner Cafe",
+            2,
+            Decimal("12.50"),
+            Category.DINING,
+            IgnoredFrom.NOTHING,
+            owner="b@test.com",
+        )
+
+    def _matching(self, expression):
+        selected = parse(expression)
+        return [t.name for t in (self.costco, self.cafe) if selected.matches(t)]
+
+    def test_terms(self):
+        cases = {
+            'amount>100 and category:"Groceries" and merchant~costco': [
+                "COSTCO WHSE #123"
+            ],
+            'category:"dining & drinks"': ["Corner Cafe"],
+            'category!="Dining & Drinks"': ["COSTCO WHSE #123"],
+            "category~dining": ["Corner Cafe"],
+            'merchant:"Costco Whse #9"': ["COSTCO WHSE #123"],
+            'name~"cafe"': ["Corner Cafe"],
+            "amount=12.50": ["Corner Cafe"],
+            "account:2": ["Corner Cafe"],
+            "date>=2025-03-10": ["Corner Cafe"],
+            "owner:B@test.com": ["Corner Cafe"],
+        }
+        for expression, expected in cases.items():
+            with self.subTest(expression):
+                self.assertEqual(self._matching(expression), expected)
+
+    def test_precedence_and_grouping(self):
+        # not binds tighter than and, which binds tighter than or
+        self.assertEqual(
+            self._matching("account:2 or amount>100 and not category:groceries"),
+            ["Corner Cafe"],
+        )
+        self.assertEqual(
+            self._matching("(account:2 or amount>100) and not category:groceries"),
+            ["Corner Cafe"],
+        )
+        self.assertEqual(
+            self._matching("AMOUNT>1 AND (merchant~cafe OR merchant~costco)"),
+            ["COSTCO WHSE #123", "Corner Cafe"],
+        )
+
+    def test_errors(self):
+        cases = {
+            "": "empty",
+            "amount>": "Expected a value",
+            "(amount>1": "Unclosed",
+            "amount>abc": "must be compared with a number",
+            "date<March": "a date",
+            "category:Rent": "not a known category",
+            "merchant>a": "can't be compared",
+            "amount~5": "can't be compared",
+            "colour:red": "Unknown field 'colour' at column 1",
+            "amount>1 amount<2": "Unexpected 'amount' at column 10",
+            'name:"open': "Unexpected character at column 6",
+            "(" * 249 + "a>1": "more than 32 levels deep at column 33",
+            "not " * 300 + "amount>1": "more than 32 levels deep",
+        }
+        for expression, message in cases.items():
+            with self.subTest(expression):
+                with self.assertRaises(FilterError) as raised:
+                    parse(expression, tuple(category_names()))
+                self.assertIn(message, str(raised.exception))
+
+    def test_categories(self):
+        expression = 'category:"Dining & Drinks" or category~gro or category!=Kids'
+        self.assertEqual(parse(expression).categories, {"dining & drinks", "kids"})
+
+        # Category names are only checked against the household's when given
+        with self.assertRaises(FilterError):
+            parse(expression, tuple(category_names()))
+        parse(expression, tuple(category_names(["Kids"])))
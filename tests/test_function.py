@@ -2,12 +2,31 @@
 Tests for the Azure Function App.
 """
 
+import hashlib
+import json
+import os
 import unittest
+from datetime import datetime
 from unittest.mock import MagicMock, patch
 
 import azure.functions as func
 
-from function_app import upload
+from function_app import (
+    demo_seed,
+    fetch_mailboxes,
+    invite_accept,
+    invites,
+    pull_connectors,
+    scan_inbox,
+    share,
+    shared_report,
+    shares,
+    upload,
+)
+from rmanalyzer.connectors import Connector, RemoteFile, file_key
+from rmanalyzer.mailbox import MailMessage, Mailbox, MailRule, message_key
+from rmanalyzer.controller import controller
+from rmanalyzer.services import QueuePriority
 
 
 class TestFunctionApp(unittest.TestCase):
@@ -16,10 +35,12 @@ class TestFunctionApp(unittest.TestCase):
     def setUp(self):
         """Set up test fixtures."""
         self.req = MagicMock(spec=func.HttpRequest)
+        self.req.url = "http://localhost/api/upload"
         # Base64 encoded: {"userDetails": "user@example.com"}
         self.req.headers = {
             "x-ms-client-principal": "eyJ1c2VyRGV0YWlscyI6ICJ1c2VyQGV4YW1wbGUuY29tIn0="
         }
+        self.req.params = {}
         self.req.files = {"file": MagicMock()}
         self.req.files["file"].filename = "test.csv"
         # csv content
@@ -40,21 +61,514 @@ class TestFunctionApp(unittest.TestCase):
         resp = upload(self.req)
         self.assertEqual(resp.status_code, 400)
 
+    @patch("rmanalyzer.controller.controller.db_service.record_upload")
     @patch("rmanalyzer.controller.controller.blob_service.upload_csv")
     @patch("rmanalyzer.controller.controller.queue_service.enqueue_message")
     def test_success_async(
         self,
         mock_enqueue,
         mock_upload,
+        mock_record,
     ):
-        """Test successful async upload (202 Accepted)."""
+        """Test successful async upload (202 Accepted) returns the blob to poll."""
         mock_upload.return_value = "https://example.com/blob.csv"
 
         resp = upload(self.req)
 
         self.assertEqual(resp.status_code, 202)
+        body = json.loads(resp.get_body())
+        self.assertEqual(body["status"], "queued")
+        mock_record.assert_called_once_with(body["blobName"], "csv", "user@example.com")
         mock_upload.assert_called_once()
         mock_enqueue.assert_called_once()
+        _, kwargs = mock_enqueue.call_args
+        self.assertEqual(kwargs["priority"], QueuePriority.INTERACTIVE)
+
+    @patch("rmanalyzer.controller.controller.blob_service.upload_csv")
+    @patch("rmanalyzer.controller.controller.queue_service.enqueue_message")
+    def test_backfill_priority(self, mock_enqueue, _):
+        """Test that priority=backfill routes to the backfill queue."""
+        self.req.params = {"priority": "backfill"}
+
+        resp = upload(self.req)
+
+        self.assertEqual(resp.status_code, 202)
+        _, kwargs = mock_enqueue.call_args
+        self.assertEqual(kwargs["priority"], QueuePriority.BACKFILL)
+
+    @patch("rmanalyzer.controller.controller.blob_service.upload_csv")
+    @patch("rmanalyzer.controller.controller.queue_service.enqueue_message")
+    def test_ofx_format_recorded(self, mock_enqueue, _):
+        """Test that the file format is recorded in the queue message by extension."""
+        self.req.files["file"].filename = "statement.QFX"
+
+        resp = upload(self.req)
+
+        self.assertEqual(resp.status_code, 202)
+        message, _ = mock_enqueue.call_args
+        self.assertEqual(message[0]["format"], "ofx")
+
+    @patch("rmanalyzer.controller.controller.blob_service.upload_csv")
+    @patch("rmanalyzer.controller.controller.queue_service.enqueue_message")
+    def test_xlsx_format_recorded(self, mock_enqueue, _):
+        """Test that Excel workbooks are queued to be parsed as XLSX."""
+        self.req.files["file"].filename = "statement.xlsx"
+
+        upload(self.req)
+
+        message, _ = mock_enqueue.call_args
+        self.assertEqual(message[0]["format"], "xlsx")
+
+    def test_invalid_priority(self):
+        """Test that unknown priorities are rejected."""
+        self.req.params = {"priority": "urgent"}
+        resp = upload(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+
+@patch.dict(os.environ, {"SANDBOX_ENABLED": "true"})
+class TestSandboxRefused(unittest.TestCase):
+    """Test suite for the routes that reach live data the sandbox has no copy of."""
+
+    @patch.object(controller, "handle_shared_report")
+    @patch.object(controller, "handle_invites")
+    def test_invites_and_shares(self, mock_invites, mock_shared):
+        """Test that invites and share links turn sandbox requests away."""
+        req = MagicMock(spec=func.HttpRequest)
+        req.method = "POST"
+        req.url = "http://localhost/api/invites"
+        req.headers = {"x-household": "sandbox"}
+
+        for route in (invites, invite_accept, shares, share, shared_report):
+            self.assertEqual(route(req).status_code, 400)
+
+        mock_invites.assert_not_called()
+        mock_shared.assert_not_called()
+
+    @patch.object(controller, "handle_demo_seed")
+    def test_demo_seed(self, mock_seed):
+        """Test that the live demo seed isn't pointed at the sandbox by the header."""
+        req = MagicMock(spec=func.HttpRequest)
+        req.method = "POST"
+        req.url = "http://localhost/api/manage/seed-demo"
+        req.headers = {"x-household": "sandbox"}
+
+        self.assertEqual(demo_seed(req).status_code, 400)
+        mock_seed.assert_not_called()
+
+
+class TestInboxScan(unittest.TestCase):
+    """Test suite for importing statements dropped in the inbox blob folder."""
+
+    def setUp(self):
+        self.timer = MagicMock(past_due=False)
+        self.blobs = [
+            {
+                "name": "inbox/bank/may.csv",
+                "size": 120,
+                "lastModified": datetime(2025, 6, 1, 9, 30),
+            }
+        ]
+        patches = {
+            "list": patch.object(
+                controller.blob_service, "list_blob_properties", return_value=self.blobs
+            ),
+            "download": patch.object(
+                controller.blob_service, "download_blob", return_value=b"csv"
+            ),
+            "upload": patch.object(controller.blob_service, "upload_csv"),
+            "delete": patch.object(controller.blob_service, "delete_blob"),
+            "record": patch.object(controller.db_service, "record_upload"),
+            "enqueue": patch.object(controller.queue_service, "enqueue_message"),
+            "collected": patch.object(
+                controller.db_service, "get_collected_file", return_value=None
+            ),
+            "record_collected": patch.object(
+                controller.db_service, "record_collected_file"
+            ),
+        }
+        self.mocks = {name: p.start() for name, p in patches.items()}
+        self.addCleanup(patch.stopall)
+
+    def test_queues_and_moves_new_files(self):
+        """Test that a dropped statement is copied to an upload, queued and removed."""
+        scan_inbox(self.timer)
+
+        self.mocks["list"].assert_called_once_with("inbox/")
+        blob_name, content = self.mocks["upload"].call_args[0]
+        self.assertTrue(blob_name.endswith("_bank_may.csv"))
+        self.assertEqual(content, b"csv")
+        self.mocks["enqueue"].assert_called_once_with(
+            {"blob_name": blob_name, "format": "csv"},
+            priority=QueuePriority.BACKFILL,
+        )
+        self.mocks["delete"].assert_called_once_with("inbox/bank/may.csv")
+
+    def test_records_provenance(self):
+        """Test that the upload record notes where in the inbox the file came from."""
+        scan_inbox(self.timer)
+
+        args, kwargs = self.mocks["record"].call_args
+        self.assertEqual(args[1:], ("csv", "inbox"))
+        provenance = kwargs["provenance"]
+        self.assertEqual(provenance["source"], "inbox")
+        self.assertEqual(provenance["path"], "inbox/bank/may.csv")
+        self.assertEqual(provenance["size"], 120)
+        self.assertEqual(provenance["lastModified"], "2025-06-01T09:30:00")
+
+    @patch.dict("os.environ", {"INBOX_PREFIX": "drop/"})
+    def test_prefix_is_configurable(self):
+        """Test that INBOX_PREFIX picks the folder scanned."""
+        scan_inbox(self.timer)
+
+        self.mocks["list"].assert_called_once_with("drop/")
+
+    def test_skips_files_that_are_not_statements(self):
+        """Test that other files are left in the inbox untouched."""
+        self.blobs[:] = [{"name": "inbox/readme.txt"}, {"name": "inbox/bank/"}]
+
+        scan_inbox(self.timer)
+
+        self.mocks["upload"].assert_not_called()
+        self.mocks["enqueue"].assert_not_called()
+        self.mocks["delete"].assert_not_called()
+
+    def test_failed_file_stays_for_next_scan(self):
+        """Test that a file that fails to queue isn't removed, and others still go."""
+        self.blobs.append({"name": "inbox/card.ofx"})
+        self.mocks["enqueue"].side_effect = [RuntimeError("queue down"), None]
+
+        scan_inbox(self.timer)
+
+        self.mocks["delete"].assert_called_once_with("inbox/card.ofx")
+
+    def test_records_content_hash_once_queued(self):
+        """Test that a file's content hash is recorded pending, then queued."""
+        scan_inbox(self.timer)
+
+        blob_name = self.mocks["upload"].call_args[0][0]
+        content_hash = hashlib.sha256(b"csv").hexdigest()
+        self.assertEqual(
+            [c.args for c in self.mocks["record_collected"].call_args_list],
+            [
+                (content_hash, blob_name, "inbox/bank/may.csv", "pending"),
+                (content_hash, blob_name, "inbox/bank/may.csv", "queued"),
+            ],
+        )
+
+    def test_repeated_file_is_removed_without_importing(self):
+        """Test that a file with the same content as a queued one isn't queued."""
+        self.mocks["collected"].return_value = {
+            "blobName": "20250601093000_bank_may.csv",
+            "status": "queued",
+        }
+
+        scan_inbox(self.timer)
+
+        self.mocks["upload"].assert_not_called()
+        self.mocks["enqueue"].assert_not_called()
+        self.mocks["delete"].assert_called_once_with("inbox/bank/may.csv")
+
+    def test_interrupted_file_is_queued_under_its_first_name(self):
+        """Test that a file that never got queued is queued as its first upload."""
+        self.mocks["collected"].return_value = {
+            "blobName": "20250601093000_bank_may.csv",
+            "status": "pending",
+        }
+
+        scan_inbox(self.timer)
+
+        self.mocks["enqueue"].assert_called_once_with(
+            {"blob_name": "20250601093000_bank_may.csv", "format": "csv"},
+            priority=QueuePriority.BACKFILL,
+        )
+        self.mocks["delete"].assert_called_once_with("inbox/bank/may.csv")
+
+    def test_skips_files_over_the_upload_limit(self):
+        """Test that a file larger than /api/upload accepts is left unread."""
+        self.blobs[0]["size"] = 11 * 1024 * 1024
+
+        scan_inbox(self.timer)
+
+        self.mocks["download"].assert_not_called()
+        self.mocks["enqueue"].assert_not_called()
+        self.mocks["delete"].assert_not_called()
+
+
+class TestConnectorPulls(unittest.TestCase):
+    """Test suite for pulling statements from SFTP/FTPS connectors."""
+
+    def setUp(self):
+        self.timer = MagicMock(past_due=False)
+        self.connector = Connector(
+            name="bank",
+            protocol="ftps",
+            host="ftp.bank.test",
+            username="household",
+            secret_name="SECRET_BANK",
+            path="/out",
+        )
+        self.files = [
+            RemoteFile("/out/may.csv", 120, "2025-06-01T09:30:00"),
+            RemoteFile("/out/readme.txt", 10),
+        ]
+        self.session = MagicMock()
+        self.session.list_files.return_value = self.files
+        self.session.read.return_value = b"csv"
+        session_cm = MagicMock()
+        session_cm.__enter__.return_value = self.session
+
+        patches = {
+            "connectors": patch.object(
+                controller.db_service, "get_connectors", return_value=[self.connector]
+            ),
+            "statuses": patch.object(
+                controller.db_service, "get_connector_statuses", return_value={}
+            ),
+            "pulled": patch.object(
+                controller.db_service, "get_pulled_files", return_value=set()
+            ),
+            "record_pulled": patch.object(controller.db_service, "record_pulled_file"),
+            "status": patch.object(controller.db_service, "set_connector_status"),
+            "secret": patch.object(
+                controller.secret_provider, "get", return_value="hunter2"
+            ),
+            "open": patch(
+                "rmanalyzer.controller.connectors.open_session",
+                return_value=session_cm,
+            ),
+            "upload": patch.object(controller.blob_service, "upload_csv"),
+            "record": patch.object(controller.db_service, "record_upload"),
+            "enqueue": patch.object(controller.queue_service, "enqueue_message"),
+            "collected": patch.object(
+                controller.db_service, "get_collected_file", return_value=None
+            ),
+            "record_collected": patch.object(
+                controller.db_service, "record_collected_file"
+            ),
+        }
+        self.mocks = {name: p.start() for name, p in patches.items()}
+        self.addCleanup(patch.stopall)
+
+    def test_pulls_new_statements(self):
+        """Test that new statements are queued, recorded as pulled and reported."""
+        pull_connectors(self.timer)
+
+        self.mocks["secret"].assert_called_once_with("SECRET_BANK")
+        self.mocks["open"].assert_called_once_with(self.connector, "hunter2")
+        self.session.read.assert_called_once_with("/out/may.csv")
+        blob_name, content = self.mocks["upload"].call_args[0]
+        self.assertTrue(blob_name.endswith("_may.csv"))
+        self.assertEqual(content, b"csv")
+        self.mocks["enqueue"].assert_called_once_with(
+            {"blob_name": blob_name, "format": "csv"},
+            priority=QueuePriority.BACKFILL,
+        )
+
+        args, kwargs = self.mocks["record"].call_args
+        self.assertEqual(args[1:], ("csv", "connector:bank"))
+        self.assertEqual(kwargs["provenance"]["source"], "connector")
+        self.assertEqual(kwargs["provenance"]["path"], "/out/may.csv")
+        self.mocks["record_pulled"].assert_called_once_with(
+            "bank", file_key(self.files[0]), "/out/may.csv", blob_name
+        )
+        self.mocks["status"].assert_called_once_with("bank", "succeeded", 1)
+
+    def test_skips_files_already_pulled(self):
+        """Test that a file is pulled once, unless it's replaced."""
+        self.mocks["pulled"].return_value = {file_key(self.files[0])}
+
+        pull_connectors(self.timer)
+
+        self.session.read.assert_not_called()
+        self.mocks["status"].assert_called_once_with("bank", "succeeded", 0)
+
+    def test_waits_for_interval(self):
+        """Test that a connector isn't pulled again until its interval passes."""
+        self.mocks["statuses"].return_value = {
+            "bank": {"lastRunAt": datetime.now().isoformat()}
+        }
+
+        pull_connectors(self.timer)
+
+        self.mocks["open"].assert_not_called()
+        self.mocks["status"].assert_not_called()
+
+    def test_failure_is_reported(self):
+        """Test that a connector that can't connect is marked failed with why."""
+        other = Connector("card", "ftps", "ftp.card.test", "me", "SECRET_CARD")
+        self.mocks["connectors"].return_value = [self.connector, other]
+        self.mocks["open"].side_effect = [OSError("Connection refused"), MagicMock()]
+
+        pull_connectors(self.timer)
+
+        self.assertEqual(
+            self.mocks["status"].call_args_list[0].args,
+            ("bank", "failed", 0, "Connection refused"),
+        )
+        self.assertEqual(
+            self.mocks["status"].call_args_list[1].args, ("card", "succeeded", 0)
+        )
+
+
+class TestMailboxFetches(unittest.TestCase):
+    """Test suite for fetching statement attachments from mailboxes."""
+
+    def setUp(self):
+        self.timer = MagicMock(past_due=False)
+        self.mailbox = Mailbox(
+            name="bank",
+            provider="graph",
+            client_id="app-id",
+            secret_name="SECRET_GRAPH",
+            rules=(MailRule(sender="statements@bank.test"),),
+        )
+        self.messages = [
+            MailMessage("m1", "statements@bank.test", "May", "2025-06-01T09:30:00Z"),
+            MailMessage("m2", "friend@home.test", "Photos", "2025-06-01T10:00:00Z"),
+        ]
+        self.provider = MagicMock()
+        self.provider.redeem.return_value = {"access_token": "access"}
+        self.provider.messages.return_value = self.messages
+        self.provider.attachments.return_value = [
+            ("../May 2025.csv", b"csv"),
+            ("logo.png", b"png"),
+        ]
+
+        patches = {
+            "mailboxes": patch.object(
+                controller.db_service, "get_mailboxes", return_value=[self.mailbox]
+            ),
+            "statuses": patch.object(
+                controller.db_service, "get_connector_statuses", return_value={}
+            ),
+            "token": patch.object(
+                controller.db_service,
+                "get_mailbox_token",
+                return_value={"refreshToken": "mailbox:bank|refresh"},
+            ),
+            "save_token": patch.object(controller.db_service, "save_mailbox_token"),
+            "key": patch.object(controller, "_backup_key", return_value=b"key"),
+            "seal": patch(
+                "rmanalyzer.backups.seal",
+                side_effect=lambda key, purpose, secret: f"{purpose}|{secret}",
+            ),
+            "unseal": patch(
+                "rmanalyzer.backups.unseal",
+                side_effect=lambda key, purpose, sealed: sealed.removeprefix(
+                    f"{purpose}|"
+                ),
+            ),
+            "fetched": patch.object(
+                controller.db_service, "get_pulled_files", return_value=set()
+            ),
+            "record_fetched": patch.object(
+                controller.db_service, "record_pulled_file"
+            ),
+            "status": patch.object(controller.db_service, "set_connector_status"),
+            "secret": patch.object(
+                controller.secret_provider, "get", return_value="client-secret"
+            ),
+            "providers": patch.dict(
+                "rmanalyzer.controller.MAIL_PROVIDERS", {"graph": self.provider}
+            ),
+            "upload": patch.object(controller.blob_service, "upload_csv"),
+            "record": patch.object(controller.db_service, "record_upload"),
+            "enqueue": patch.object(controller.queue_service, "enqueue_message"),
+            "collected": patch.object(
+                controller.db_service, "get_collected_file", return_value=None
+            ),
+            "record_collected": patch.object(
+                controller.db_service, "record_collected_file"
+            ),
+        }
+        self.mocks = {name: p.start() for name, p in patches.items()}
+        self.addCleanup(patch.stopall)
+
+    def test_fetches_matching_statements(self):
+        """Test that statement attachments of matching emails are queued once."""
+        fetch_mailboxes(self.timer)
+
+        self.provider.redeem.assert_called_once_with(
+            self.mailbox,
+            "client-secret",
+            {"grant_type": "refresh_token", "refresh_token": "refresh"},
+        )
+        self.provider.attachments.assert_called_once_with("access", "m1")
+        blob_name, content = self.mocks["upload"].call_args[0]
+        self.assertTrue(blob_name.endswith("_May_2025.csv"))
+        self.assertEqual(content, b"csv")
+        self.mocks["enqueue"].assert_called_once_with(
+            {"blob_name": blob_name, "format": "csv"},
+            priority=QueuePriority.BACKFILL,
+        )
+
+        args, kwargs = self.mocks["record"].call_args
+        self.assertEqual(args[1:], ("csv", "mailbox:bank"))
+        self.assertEqual(kwargs["provenance"]["messageId"], "m1")
+        self.mocks["record_fetched"].assert_called_once_with(
+            "mail:bank", message_key("m1"), "May", blob_name
+        )
+        self.mocks["status"].assert_called_once_with("mail:bank", "succeeded", 1)
+        self.mocks["save_token"].assert_not_called()
+
+    def test_skips_messages_already_fetched(self):
+        """Test that a message's attachments are only fetched once."""
+        self.mocks["fetched"].return_value = {message_key("m1")}
+
+        fetch_mailboxes(self.timer)
+
+        self.provider.attachments.assert_not_called()
+        self.mocks["status"].assert_called_once_with("mail:bank", "succeeded", 0)
+
+    def test_skips_statements_already_collected(self):
+        """Test that an attachment with the content of a queued file isn't queued."""
+        self.mocks["collected"].return_value = {
+            "blobName": "20250601093000_may.csv",
+            "status": "queued",
+        }
+
+        fetch_mailboxes(self.timer)
+
+        self.mocks["enqueue"].assert_not_called()
+        self.mocks["record_fetched"].assert_called_once_with(
+            "mail:bank", message_key("m1"), "May", ""
+        )
+        self.mocks["status"].assert_called_once_with("mail:bank", "succeeded", 0)
+
+    def test_rotated_refresh_token_is_stored(self):
+        """Test that a new refresh token from the provider replaces the old one."""
+        self.provider.redeem.return_value = {
+            "access_token": "access",
+            "refresh_token": "rotated",
+        }
+
+        fetch_mailboxes(self.timer)
+
+        self.mocks["save_token"].assert_called_once_with(
+            "bank", "mailbox:bank|rotated"
+        )
+
+    def test_undecryptable_refresh_token_fails(self):
+        """Test that a token sealed under a rotated key asks for a new sign-in."""
+        self.mocks["unseal"].side_effect = ValueError("InvalidTag")
+
+        fetch_mailboxes(self.timer)
+
+        self.provider.redeem.assert_not_called()
+        status_args = self.mocks["status"].call_args.args
+        self.assertEqual(status_args[:3], ("mail:bank", "failed", 0))
+        self.assertIn("sign in to it again", status_args[3])
+
+    def test_unauthorized_mailbox_fails(self):
+        """Test that a mailbox nobody has signed in to is reported as failed."""
+        self.mocks["token"].return_value = {"refreshToken": None}
+
+        fetch_mailboxes(self.timer)
+
+        self.provider.redeem.assert_not_called()
+        status_args = self.mocks["status"].call_args.args
+        self.assertEqual(status_args[:3], ("mail:bank", "failed", 0))
 
 
 if __name__ == "__main__":
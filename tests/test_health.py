@@ -0,0 +1,204 @@
+"""
+Tests for the financial health score.
+"""
+
+import json
+import unittest
+from datetime import date
+from decimal import Decimal
+from unittest.mock import patch
+
+from fixtures import http_request, start_patches
+from rmanalyzer.controller import controller
+from rmanalyzer.health import health_score
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+
+
+class TestHealthScore(unittest.TestCase):
+    def test_within_budget(self):
+        savings = {"startingBalance": 1000, "items": [{"name": "Rent", "cost": 900}]}
+
+        result = health_score(savings)
+
+        factors = result["factors"]
+        self.assertEqual(factors["budgetAdherence"]["score"], 100.0)
+        # Saved 10% against a 20% target
+        self.assertEqual(factors["savingsRate"]["score"], 50.0)
+        self.assertIsNone(factors["utilization"]["score"])
+        self.assertIsNotNone(factors["utilization"]["reason"])
+        # Weighted over the available factors only: (100 * 0.3 + 50 * 0.25) / 0.55
+        self.assertEqual(result["score"], 77.3)
+
+    def test_over_budget(self):
+        savings = {"startingBalance": 100, "items": [{"name": "A", "cost": 150}]}
+
+        result = health_score(savings)
+
+        self.assertEqual(result["factors"]["budgetAdherence"]["score"], 50.0)
+        self.assertEqual(result["factors"]["savingsRate"]["score"], 0.0)
+
+    def test_no_balance(self):
+        result = health_score({"startingBalance": 0, "items": []})
+        self.assertIsNone(result["factors"]["savingsRate"]["score"])
+        self.assertEqual(result["score"], 100.0)
+
+    def test_emergency_fund(self):
+        savings = {"startingBalance": 1000, "items": [{"name": "Rent", "cost": 900}]}
+
+        result = health_score(savings, emergency_months=3.0)
+
+        # 3 months against a 6 month target
+        self.assertEqual(result["factors"]["emergencyFundMonths"]["score"], 50.0)
+        # (100 * 0.3 + 50 * 0.25 + 50 * 0.2) / 0.75
+        self.assertEqual(result["score"], 70.0)
+
+    def test_utilization(self):
+        savings = {"startingBalance": 1000, "items": [{"name": "Rent", "cost": 900}]}
+
+        low = health_score(savings, utilization=0.05)
+        high = health_score(savings, utilization=0.55)
+
+        self.assertEqual(low["factors"]["utilization"]["score"], 100.0)
+        self.assertIsNone(low["factors"]["utilization"]["reason"])
+        # Half way from the 10% target to full utilization
+        self.assertEqual(high["factors"]["utilization"]["score"], 50.0)
+        # (100 * 0.3 + 50 * 0.25 + 50 * 0.25) / 0.8
+        self.assertEqual(high["score"], 68.8)
+        self.assertEqual(health_score(None, utilization=1.2)["score"], 0.0)
+
+    def test_no_savings_data(self):
+        result = health_score(None)
+        self.assertIsNone(result["score"])
+        self.assertEqual(
+            result["factors"]["budgetAdherence"]["reason"], "No savings data."
+        )
+
+
+class TestHealthScoreController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", params={"month": "2025-01"})
+        patchers = [
+            patch.object(controller.db_service, "get_transactions", return_value=[]),
+            patch.object(controller.db_service, "get_settings", return_value={}),
+            patch.object(controller.db_service, "get_accounts", return_value=[]),
+            patch.object(controller.db_service, "get_alerts", return_value=[]),
+            patch.object(controller.db_service, "record_alert"),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_get_transactions = mocks[0]
+        self.mock_get_settings = mocks[1]
+        self.mock_get_accounts = mocks[2]
+
+    @patch("rmanalyzer.controller.controller.db_service.get_health_scores")
+    @patch("rmanalyzer.controller.controller.db_service.get_savings")
+    def test_health_score(self, mock_get, mock_history):
+        mock_get.return_value = {"startingBalance": 100, "items": []}
+        mock_history.return_value = [{"date": "2025-01-01", "score": 80.0}]
+
+        resp = controller.handle_health_score(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["score"], 100.0)
+        self.assertEqual(len(payload["history"]), 1)
+        mock_get.assert_called_once_with("2025-01", "a@test.com")
+
+    @patch("rmanalyzer.controller.controller.db_service.get_health_scores")
+    @patch("rmanalyzer.controller.controller.db_service.get_savings")
+    def test_health_score_includes_utilization(self, mock_get, mock_history):
+        mock_get.return_value = None
+        mock_history.return_value = []
+        self.mock_get_accounts.return_value = [
+            {"balance": 550, "limit": 1000},
+            {"balance": 0, "limit": 0},
+        ]
+
+        resp = controller.handle_health_score(self.req)
+
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["factors"]["utilization"]["score"], 50.0)
+        self.assertEqual(payload["score"], 50.0)
+        self.mock_get_accounts.assert_called_once_with("a@test.com")
+
+    @patch("rmanalyzer.controller.controller.db_service.save_health_score")
+    @patch("rmanalyzer.controller.controller.db_service.get_savings")
+    @patch("rmanalyzer.controller.controller.db_service.get_all_people")
+    def test_health_score_job(self, mock_people, mock_get, mock_save):
+        mock_people.return_value = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+            {"Name": "B", "Email": "b@test.com", "Accounts": [2]},
+        ]
+        mock_get.return_value = None
+
+        controller.run_health_score_job()
+
+        self.assertEqual(mock_save.call_count, 2)
+        email, _, _, result = mock_save.call_args[0]
+        self.assertEqual(email, "b@test.com")
+        self.assertIsNone(result["score"])
+
+    @patch("rmanalyzer.controller.controller.email_service.send_email")
+    @patch("rmanalyzer.controller.controller.db_service.save_setting")
+    @patch("rmanalyzer.controller.controller.db_service.save_health_score")
+    @patch("rmanalyzer.controller.controller.db_service.get_savings")
+    @patch("rmanalyzer.controller.controller.db_service.get_all_people")
+    def test_health_score_job_alerts_low_emergency_fund(
+        self, mock_people, mock_get, _, mock_save_setting, mock_send
+    ):
+        mock_people.return_value = [
+            {"Name": "A", "Email": "a@test.com", "Accounts": [1]},
+        ]
+        mock_get.return_value = None
+        self.mock_get_settings.return_value = {"EmergencyFund": "500"}
+        self.mock_get_transactions.return_value = [
+            Transaction(
+                date(2025, 1, 1),
+                "Rent",
+                1,
+                Decimal("1000"),
+                Category.BILLS,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+
+        controller.run_health_score_job()
+
+        mock_send.assert_called_once()
+        self.assertEqual(mock_send.call_args[0][0], ["a@test.com"])
+        mock_save_setting.assert_called_once_with("EmergencyFundAlerted", "true")
+
+        # Not sent again while coverage stays low
+        mock_send.reset_mock()
+        self.mock_get_settings.return_value = {
+            "EmergencyFund": "500",
+            "EmergencyFundAlerted": "true",
+        }
+        controller.run_health_score_job()
+        mock_send.assert_not_called()
+
+    def test_emergency_fund(self):
+        self.mock_get_settings.return_value = {
+            "EmergencyFund": "3000",
+            "EmergencyFundAlertMonths": "2",
+        }
+        self.mock_get_transactions.return_value = [
+            Transaction(
+                date(2025, 1, 1),
+                "Rent",
+                1,
+                Decimal("1000"),
+                Category.BILLS,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+
+        resp = controller.handle_emergency_fund(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["monthlyEssentialSpend"], 1000.0)
+        self.assertEqual(payload["months"], 3.0)
+        self.assertEqual(payload["alertBelowMonths"], 2.0)
+
+if __name__ == "__main__":
+    unittest.main()
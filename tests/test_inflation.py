@@ -0,0 +1,30 @@
+"""
+Tests for inflation adjustment.
+"""
+
+import unittest
+from decimal import Decimal
+
+from rmanalyzer.inflation import CPI_INDEX, months_between, price_index, to_real
+
+
+class TestInflation(unittest.TestCase):
+    def test_months_between(self):
+        self.assertEqual(
+            months_between("2024-11", "2025-02"),
+            ["2024-11", "2024-12", "2025-01", "2025-02"],
+        )
+        self.assertEqual(months_between("2025-02", "2025-01"), [])
+
+    def test_price_index_clamps_to_known_years(self):
+        self.assertEqual(price_index(2030, CPI_INDEX), CPI_INDEX[2024])
+        self.assertEqual(price_index(2000, CPI_INDEX), CPI_INDEX[2015])
+
+    def test_to_real(self):
+        index = {2020: Decimal("100"), 2024: Decimal("120")}
+        self.assertEqual(to_real(Decimal("50.00"), 2020, 2024, index), Decimal("60.00"))
+        self.assertEqual(to_real(Decimal("50.00"), 2024, 2024, index), Decimal("50.00"))
+
+
+if __name__ == "__main__":
+    unittest.main()
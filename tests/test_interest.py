@@ -0,0 +1,52 @@
+"""
+Tests for card interest projections.
+"""
+
+import unittest
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.interest import minimum_payment, project_interest, promo_months_left
+
+
+class TestInterest(unittest.TestCase):
+    def test_minimum_payment(self):
+        self.assertEqual(
+            minimum_payment(Decimal("5000"), Decimal("24")), Decimal("150.00")
+        )
+        self.assertEqual(minimum_payment(Decimal("500"), Decimal("24")), Decimal("25"))
+        self.assertEqual(minimum_payment(Decimal("10"), Decimal("24")), Decimal("10"))
+
+    def test_promo_months_left(self):
+        self.assertEqual(promo_months_left("2025-06-01", date(2025, 3, 10)), 3)
+        self.assertEqual(promo_months_left("2025-03-01", date(2025, 3, 10)), 0)
+        self.assertEqual(promo_months_left(None, date(2025, 3, 10)), 0)
+
+    def test_pays_off_with_last_payment_capped(self):
+        projection = project_interest(Decimal("100"), Decimal("12"), Decimal("60"), 12)
+
+        self.assertTrue(projection["paidOff"])
+        self.assertEqual(projection["monthsToPayOff"], 2)
+        self.assertEqual(projection["schedule"][-1]["payment"], 41.41)
+        self.assertEqual(projection["totalInterest"], 1.41)
+
+    def test_promo_apr_then_regular(self):
+        projection = project_interest(
+            Decimal("1200"), Decimal("24"), Decimal("100"), 3, Decimal("0"), 2
+        )
+
+        self.assertEqual(
+            [m["interest"] for m in projection["schedule"]], [0.0, 0.0, 20.0]
+        )
+
+    def test_payment_below_interest_never_pays_off(self):
+        projection = project_interest(Decimal("1200"), Decimal("24"), Decimal("10"), 6)
+
+        self.assertFalse(projection["paidOff"])
+        self.assertIsNone(projection["totalInterest"])
+        self.assertEqual(len(projection["schedule"]), 6)
+        self.assertEqual(projection["schedule"][-1]["balance"], 1288.32)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,82 @@
+"""
+Tests for the debt ledger.
+"""
+
+import unittest
+from decimal import Decimal
+
+from rmanalyzer.ledger import LedgerEntry, running_balance, settled_in_month
+
+
+class TestLedger(unittest.TestCase):
+    def _entry(self, entry_id, debt, recorded_at, kind="import"):
+        return LedgerEntry.from_debt(
+            entry_id,
+            kind,
+            "a@test.com",
+            "b@test.com",
+            Decimal(debt),
+            "2025-01",
+            recorded_at,
+        )
+
+    def test_from_debt_orients_by_sign(self):
+        entry = self._entry("e1", "-12.50", "2025-01-01")
+        self.assertEqual(entry.debtor, "b@test.com")
+        self.assertEqual(entry.creditor, "a@test.com")
+        self.assertEqual(entry.amount, Decimal("12.50"))
+
+    def test_entity_round_trip(self):
+        entry = self._entry("e1", "20.00", "2025-01-01")
+        entity = entry.to_entity("default_LEDGER")
+        self.assertEqual(LedgerEntry.from_entity(entity), entry)
+
+    def test_running_balance_in_recorded_order(self):
+        entries = [
+            self._entry("e2", "-50.00", "2025-02-01"),
+            self._entry("e1", "20.00", "2025-01-01"),
+        ]
+
+        result = running_balance(entries)
+
+        self.assertEqual([e["id"] for e in result["entries"]], ["e1", "e2"])
+        self.assertEqual(result["entries"][0]["balance"]["debtor"], "a@test.com")
+        self.assertEqual(
+            result["balance"],
+            {"debtor": "b@test.com", "creditor": "a@test.com", "amount": 30.0},
+        )
+
+    def test_running_balance_empty(self):
+        result = running_balance([])
+        self.assertEqual(result["entries"], [])
+        self.assertEqual(result["balance"]["amount"], 0.0)
+
+
+    def test_settled_in_month(self):
+        def paid(payer, payee, amount, month, period):
+            return LedgerEntry(
+                f"{payer}{month}{period}",
+                "settlement",
+                payee,
+                payer,
+                Decimal(amount),
+                month,
+                "t",
+                period,
+            )
+
+        entries = [
+            paid("a@test.com", "b@test.com", "30", "2025-02", "2025-01"),
+            paid("b@test.com", "a@test.com", "5", "2025-01", "2025-01"),
+            paid("a@test.com", "b@test.com", "9", "2025-02", "2025-02"),
+            # Paid in January towards the running balance, not January's debt
+            paid("a@test.com", "b@test.com", "7", "2025-01", ""),
+            self._entry("e1", "50.00", "2025-01-31"),
+        ]
+
+        settled = settled_in_month(entries, "a@test.com", "b@test.com", "2025-01")
+        self.assertEqual(settled, Decimal("25"))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,88 @@
+"""
+Tests for logging configuration.
+"""
+
+import json
+import logging
+import os
+import unittest
+from unittest.mock import patch
+
+from rmanalyzer.logging_config import (
+    DebugSamplingFilter,
+    JsonFormatter,
+    configure_logging,
+)
+
+
+def _record(level=logging.DEBUG, msg="Body: %s", args=("x",)):
+    return logging.LogRecord("test", level, __file__, 1, msg, args, None)
+
+
+class TestLoggingConfig(unittest.TestCase):
+    """Test suite for logging configuration."""
+
+    def setUp(self):
+        root = logging.getLogger()
+        self.original_level = root.level
+        self.original_handlers = list(root.handlers)
+        self.handler = logging.StreamHandler()
+        root.handlers = [self.handler]
+
+    def tearDown(self):
+        root = logging.getLogger()
+        root.handlers = self.original_handlers
+        root.setLevel(self.original_level)
+
+    def test_json_formatter(self):
+        """Test that records are rendered as JSON."""
+        line = JsonFormatter().format(_record(logging.INFO))
+        entry = json.loads(line)
+        self.assertEqual(entry["level"], "INFO")
+        self.assertEqual(entry["message"], "Body: x")
+
+    def test_sampling_filter(self):
+        """Test that only every Nth debug record per call site passes."""
+        sampler = DebugSamplingFilter(3)
+        passed = [sampler.filter(_record()) for _ in range(6)]
+        self.assertEqual(passed, [True, False, False, True, False, False])
+        self.assertTrue(sampler.filter(_record(logging.INFO)))
+
+    @patch.dict(
+        os.environ,
+        {"LOG_LEVEL": "debug", "LOG_FORMAT": "json", "LOG_DEBUG_SAMPLE_EVERY": "5"},
+    )
+    def test_configure_logging(self):
+        """Test that level, format and sampling are applied to root handlers."""
+        configure_logging()
+        configure_logging()
+
+        self.assertEqual(logging.getLogger().level, logging.DEBUG)
+        self.assertIsInstance(self.handler.formatter, JsonFormatter)
+        samplers = [
+            f for f in self.handler.filters if isinstance(f, DebugSamplingFilter)
+        ]
+        self.assertEqual(len(samplers), 1)
+        self.assertEqual(
+            logging.getLogger(
+                "azure.core.pipeline.policies.http_logging_policy"
+            ).level,
+            logging.WARNING,
+        )
+
+    @patch.dict(
+        os.environ,
+        {"LOG_LEVEL": "loud", "LOG_FORMAT": "xml", "LOG_DEBUG_SAMPLE_EVERY": "x"},
+    )
+    def test_invalid_settings_fall_back(self):
+        """Test that invalid settings are warned about and the defaults used."""
+        with self.assertLogs("rmanalyzer.logging_config", "WARNING") as logs:
+            configure_logging()
+
+        self.assertEqual(len(logs.records), 3)
+        self.assertEqual(logging.getLogger().level, logging.INFO)
+        self.assertNotIsInstance(self.handler.formatter, JsonFormatter)
+
+
+if __name__ == "__main__":
+    unittest.main()
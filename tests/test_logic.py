@@ -2,18 +2,24 @@
 Tests for the business logic (models and transactions).
 """
 
+import os
 import unittest
 from datetime import date
 from decimal import Decimal
+from unittest.mock import patch
 
 from rmanalyzer.models import (
+    AddedCategory,
     Category,
     Group,
     IgnoredFrom,
     Person,
     Transaction,
+    category_names,
+    parse_category,
 )
 from rmanalyzer.utils import (
+    category_aliases,
     get_transactions,
     to_currency,
     to_transaction,
@@ -75,6 +81,98 @@ class TestTransactionHelpers(unittest.TestCase):
         self.assertIsNotNone(err)
         self.assertTrue("bad-date" in err or "Date" in err)
 
+    def test_to_transaction_currency(self):
+        """Test that an optional Currency column is read as an ISO 4217 code."""
+        row = {
+            "Date": "2025-08-17",
+            "Name": "Hotel",
+            "Account Number": "123",
+            "Amount": "90",
+            "Currency": " eur ",
+        }
+        t, err = to_transaction(row)
+        self.assertIsNone(err)
+        self.assertEqual(t.currency, "EUR")
+
+        t, err = to_transaction({**row, "Currency": ""})
+        self.assertIsNone(t.currency)
+
+        t, err = to_transaction({**row, "Currency": "euro"})
+        self.assertIsNone(t)
+        self.assertEqual(err, "Invalid 'Currency': euro")
+
+    def test_to_transaction_category(self):
+        """Test that categories must be known, in any case, or a configured alias."""
+        row = {
+            "Date": "2025-08-17",
+            "Name": "Cafe",
+            "Account Number": "123",
+            "Amount": "9",
+        }
+        self.assertTrue(Category.is_valid("Dining & Drinks"))
+        self.assertFalse(Category.is_valid("dining & drinks"))
+
+        t, _ = to_transaction(row)
+        self.assertEqual(t.category, Category.OTHER)
+        t, _ = to_transaction({**row, "Category": "dining & drinks"})
+        self.assertEqual(t.category, Category.DINING)
+
+        t, err = to_transaction({**row, "Category": "Dinning & Drinks"})
+        self.assertIsNone(t)
+        self.assertEqual(err, "Unknown 'Category': Dinning & Drinks")
+
+        aliases = {"restaurants": Category.DINING}
+        t, err = to_transaction({**row, "Category": "Restaurants"}, aliases)
+        self.assertEqual(t.category, Category.DINING)
+
+        with patch.dict(os.environ, {"UNKNOWN_CATEGORIES": "other"}):
+            t, err = to_transaction({**row, "Category": "Dinning & Drinks"})
+        self.assertIsNone(err)
+        self.assertEqual(t.category, Category.OTHER)
+
+    @patch.dict(
+        os.environ,
+        {"CATEGORY_ALIASES": '{"Restaurants": "Dining & Drinks", "Gas": "Fuel"}'},
+    )
+    def test_category_aliases(self):
+        """Test that configured aliases are read, leaving out unknown targets."""
+        self.assertEqual(category_aliases(), {"restaurants": Category.DINING})
+
+        csv_content = (
+            "Date,Name,Account Number,Amount,Category\n"
+            "2025-08-17,Cafe,123,9,Restaurants\n"
+            "2025-08-18,Pump,123,40,Gas\n"
+        )
+        transactions, errors = get_transactions(csv_content)
+        self.assertEqual(transactions[0].category, Category.DINING)
+        self.assertEqual(errors, ["Row 2: Unknown 'Category': Gas"])
+
+    def test_added_categories(self):
+        """Test that added categories are known only where they're added."""
+        kids = parse_category("Kids", ["Kids"])
+        self.assertEqual(kids, AddedCategory("Kids"))
+        self.assertFalse(kids.built_in)
+        self.assertIs(parse_category("Groceries", ["Kids"]), Category.GROCERIES)
+        with self.assertRaises(ValueError):
+            parse_category("Kids")
+        with self.assertRaises(ValueError):
+            Category("Kids")
+        self.assertTrue(Category.GROCERIES.built_in)
+        self.assertFalse(Category.is_valid("Kids"))
+        self.assertTrue(Category.is_valid("Kids", ["Kids"]))
+        names = category_names(["Kids", "Groceries"])
+        self.assertEqual((names[-1], len(names)), ("Kids", len(Category) + 1))
+
+        csv_content = (
+            "Date,Name,Account Number,Amount,Category\n2025-08-17,Toys,1,9,Kids\n"
+        )
+        transactions, errors = get_transactions(csv_content, ["Kids"])
+        self.assertEqual((transactions[0].category, errors), (kids, []))
+
+        # Another household, without the category
+        _, errors = get_transactions(csv_content)
+        self.assertEqual(errors, ["Row 1: Unknown 'Category': Kids"])
+
     def test_to_currency(self):
         """Test currency formatting."""
         self.assertEqual(to_currency(42), "42.00")
@@ -168,6 +266,135 @@ class TestPersonGroup(unittest.TestCase):
         debt = self.group.get_debt(self.p1, self.p2, Decimal("0.5"))
         self.assertEqual(debt, Decimal("0.0"))
 
+    def test_group_debt_excluded_categories(self):
+        """Test that excluded categories don't count toward shared debt."""
+        group = Group([self.p1, self.p2], debt_excluded=[Category.DINING])
+        # Only Alice's 20.0 of groceries is shared: Bob owes her 10.0
+        self.assertEqual(group.get_debt(self.p1, self.p2), Decimal("-10.0"))
+        # Totals and reports are unaffected
+        self.assertEqual(group.get_expenses(), Decimal("60.0"))
+        self.assertEqual(
+            self.p1.get_expenses(exclude=[Category.DINING]), Decimal("20.0")
+        )
+
+    def test_group_debt_split_shares(self):
+        """Test that split shares divide shared spend unevenly, per category."""
+        # Alice pays 60% overall, Bob 80% of dining
+        self.p1.split_share = Decimal("0.6")
+        self.p2.category_shares = {Category.DINING: Decimal("0.8")}
+
+        self.assertEqual(
+            self.group.split_share(self.p1, self.p2, Category.DINING), Decimal("0.2")
+        )
+        self.assertEqual(
+            self.group.split_share(self.p2, self.p1, Category.GROCERIES),
+            Decimal("0.4"),
+        )
+        # Dining: 0.2 * 40 - 10 = -2; groceries: 0.6 * 20 - 20 = -8
+        self.assertEqual(
+            self.group.get_category_debt(self.p1, self.p2, Category.DINING),
+            Decimal("-2.0"),
+        )
+        self.assertEqual(self.group.get_debt(self.p1, self.p2), Decimal("-10.0"))
+        self.assertEqual(self.group.get_debt(self.p2, self.p1), Decimal("10.0"))
+
+    def test_person_split_shares_from_config(self):
+        """Test that split shares are read from a People table record."""
+        person = Person.from_config(
+            {
+                "Name": "A",
+                "Email": "a@test.com",
+                "Accounts": [1],
+                "SplitShare": 0.6,
+                "CategoryShares": {"Groceries": 0.5},
+            }
+        )
+        self.assertEqual(person.share_of(Category.GROCERIES), Decimal("0.5"))
+        self.assertEqual(person.share_of(Category.DINING), Decimal("0.6"))
+
+        config = {"Name": "A", "Email": "a@test.com", "Accounts": [1]}
+        config["CategoryShares"] = {"Kids": 0.7}
+        person = Person.from_config(config, ["Kids"])
+        self.assertEqual(person.share_of(AddedCategory("Kids")), Decimal("0.7"))
+        with self.assertRaises(ValueError):
+            Person.from_config(config)
+
+    def test_group_add_transactions_owner_override(self):
+        """Test that an owner override wins over account ownership."""
+        group = Group([Person("A", "a@test.com", [1]), Person("B", "b@test.com", [2])])
+        t = Transaction(
+            date(2025, 8, 4),
+            "Gift",
+            1,
+            Decimal("15.0"),
+            Category.PURCHASES,
+            IgnoredFrom.NOTHING,
+            owner="B@test.com",
+        )
+        group.add_transactions([t])
+        self.assertEqual(group.members[0].transactions, [])
+        self.assertEqual(group.members[1].transactions, [t])
+
+    def test_group_add_transactions_unassigned(self):
+        """Test that transactions on unknown accounts are kept as unassigned."""
+        t = Transaction(
+            date(2025, 8, 4),
+            "Unknown",
+            99,
+            Decimal("5.0"),
+            Category.DINING,
+            IgnoredFrom.NOTHING,
+        )
+        self.group.add_transactions([t])
+        self.assertEqual(self.group.unassigned, [t])
+        self.assertEqual(self.group.get_expenses(), Decimal("60.0"))
+
+    def _ignored(self, account, amount, ignore):
+        return Transaction(
+            date(2025, 8, 5), "X", account, Decimal(amount), Category.DINING, ignore
+        )
+
+    def test_person_expenses_ignore_scopes(self):
+        """Test that ignored transactions are left out by scope."""
+        self.p1.add_transaction(self._ignored(1, "100.0", IgnoredFrom.EVERYTHING))
+        self.p1.add_transaction(self._ignored(1, "7.0", IgnoredFrom.BUDGET))
+
+        self.assertTrue(self.p1.transactions[2].is_ignored(IgnoredFrom.EVERYTHING))
+        self.assertFalse(self.p1.transactions[3].is_ignored(IgnoredFrom.EVERYTHING))
+        self.assertTrue(self.p1.transactions[3].is_ignored(IgnoredFrom.BUDGET))
+        self.assertFalse(self.t1.is_ignored(IgnoredFrom.BUDGET))
+
+        self.assertEqual(self.p1.get_expenses(), Decimal("30.0"))
+        self.assertEqual(self.p1.get_expenses(Category.DINING), Decimal("10.0"))
+        self.assertEqual(
+            self.p1.get_expenses(scope=IgnoredFrom.EVERYTHING), Decimal("37.0")
+        )
+        self.assertEqual(
+            self.p1.get_expenses(Category.DINING, scope=IgnoredFrom.EVERYTHING),
+            Decimal("17.0"),
+        )
+
+    def test_group_debt_leaves_out_ignored(self):
+        """Test that ignored transactions never count toward debt or totals."""
+        self.p1.add_transaction(self._ignored(1, "100.0", IgnoredFrom.EVERYTHING))
+        self.p2.add_transaction(self._ignored(2, "40.0", IgnoredFrom.BUDGET))
+
+        self.assertEqual(self.group.get_expenses(), Decimal("60.0"))
+        self.assertEqual(self.group.get_debt(self.p1, self.p2), Decimal("0.0"))
+        self.assertEqual(
+            self.group.get_debt(self.p1, self.p2, Decimal("0.5")), Decimal("0.0")
+        )
+
+    def test_group_add_transactions_skips_ignored(self):
+        """Test that ignored transactions aren't assigned to anyone."""
+        ignored = [
+            self._ignored(1, "100.0", IgnoredFrom.EVERYTHING),
+            self._ignored(9, "40.0", IgnoredFrom.BUDGET),
+        ]
+        self.group.add_transactions(ignored)
+        self.assertEqual(len(self.p1.transactions), 2)
+        self.assertEqual(self.group.unassigned, [])
+
     def test_group_add_transactions(self):
         """Test adding transactions to a group."""
         t4 = Transaction(
@@ -181,6 +408,39 @@ class TestPersonGroup(unittest.TestCase):
         self.group.add_transactions([t4])
         self.assertIn(t4, self.p1.transactions)
 
+    def test_group_add_transactions_joint_account(self):
+        """Test that a joint account's transactions are split by weight."""
+        a = Person.from_config(
+            {
+                "Name": "A",
+                "Email": "a@test.com",
+                "Accounts": [3],
+                "AccountWeights": {"3": 0.6},
+            }
+        )
+        b = Person.from_config(
+            {
+                "Name": "B",
+                "Email": "b@test.com",
+                "Accounts": [3],
+                "AccountWeights": {"3": 0.4},
+            }
+        )
+        t = Transaction(
+            date(2025, 8, 4),
+            "Dinner",
+            3,
+            Decimal("10.01"),
+            Category.DINING,
+            IgnoredFrom.NOTHING,
+        )
+
+        Group([a, b]).add_transactions([t])
+
+        self.assertEqual(a.transactions[0].amount, Decimal("6.01"))
+        self.assertEqual(b.transactions[0].amount, Decimal("4.00"))
+        self.assertEqual(a.transactions[0].name, "Dinner")
+
 
 if __name__ == "__main__":
     unittest.main()
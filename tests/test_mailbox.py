@@ -0,0 +1,163 @@
+"""
+Tests for mailbox connectors and their mail providers.
+"""
+
+import base64
+import unittest
+from datetime import datetime, timezone
+from unittest.mock import patch
+
+from rmanalyzer.mailbox import PROVIDERS, MailMessage, Mailbox, MailRule
+
+MAILBOX_JSON = {
+    "provider": "graph",
+    "clientId": "app-id",
+    "secretName": "SECRET_GRAPH",
+    "rules": [
+        {"from": "statements@bank.test", "subject": None},
+        {"from": None, "subject": "Your statement"},
+    ],
+    "tenantId": "common",
+    "intervalMinutes": 60,
+}
+
+
+def _message(sender="alerts@bank.test", subject="Hello"):
+    return MailMessage("m1", sender, subject, "2025-06-01T09:30:00Z")
+
+
+class TestMailbox(unittest.TestCase):
+    """Test suite for the mailbox model and its rules."""
+
+    def test_json_and_entity_round_trip(self):
+        """Test that the API shape and the table entity round trip."""
+        mailbox = Mailbox.from_json("bank", MAILBOX_JSON)
+
+        self.assertEqual(mailbox.to_json(), {"name": "bank", **MAILBOX_JSON})
+        self.assertEqual(
+            Mailbox.from_entity(mailbox.to_entity("default_MAILBOXES")), mailbox
+        )
+        self.assertEqual(mailbox.status_key, "mail:bank")
+
+    def test_rules_match_any(self):
+        """Test that a message matching any rule, case-insensitively, matches."""
+        mailbox = Mailbox.from_json("bank", MAILBOX_JSON)
+
+        self.assertTrue(mailbox.matches(_message(sender="Bank <STATEMENTS@bank.test>")))
+        self.assertTrue(mailbox.matches(_message(subject="Your Statement is ready")))
+        self.assertFalse(mailbox.matches(_message()))
+
+    def test_rule_needs_both_parts(self):
+        """Test that a rule setting sender and subject needs both to match."""
+        rule = MailRule(sender="bank.test", subject="statement")
+
+        self.assertTrue(rule.matches(_message(subject="May statement")))
+        self.assertFalse(
+            rule.matches(_message(sender="a@other.test", subject="statement"))
+        )
+
+
+class TestGraphProvider(unittest.TestCase):
+    """Test suite for Microsoft Graph mail."""
+
+    @patch("rmanalyzer.mailbox._fetch")
+    def test_messages_follow_pages(self, mock_fetch):
+        """Test that every page of messages is listed."""
+        mock_fetch.side_effect = [
+            {
+                "value": [
+                    {
+                        "id": "m1",
+                        "subject": "Statement",
+                        "from": {"emailAddress": {"address": "a@bank.test"}},
+                        "receivedDateTime": "2025-06-01T09:30:00Z",
+                    }
+                ],
+                "@odata.nextLink": "https://graph.test/next",
+            },
+            {"value": [{"id": "m2"}]},
+        ]
+
+        messages = list(
+            PROVIDERS["graph"].messages(
+                "token", datetime(2025, 6, 1, tzinfo=timezone.utc)
+            )
+        )
+
+        self.assertEqual(
+            messages,
+            [
+                MailMessage("m1", "a@bank.test", "Statement", "2025-06-01T09:30:00Z"),
+                MailMessage("m2", "", "", ""),
+            ],
+        )
+        self.assertIn("2025-06-01T00%3A00%3A00Z", mock_fetch.call_args_list[0].args[0])
+        self.assertEqual(
+            mock_fetch.call_args_list[1].args, ("https://graph.test/next", "token")
+        )
+
+    @patch("rmanalyzer.mailbox._fetch")
+    def test_attachments_are_files_only(self, mock_fetch):
+        """Test that attached items that aren't files are skipped."""
+        mock_fetch.return_value = {
+            "value": [
+                {
+                    "@odata.type": "#microsoft.graph.fileAttachment",
+                    "name": "may.csv",
+                    "contentBytes": base64.b64encode(b"csv").decode(),
+                },
+                {"@odata.type": "#microsoft.graph.itemAttachment", "name": "fwd"},
+            ]
+        }
+
+        self.assertEqual(
+            PROVIDERS["graph"].attachments("token", "m1"), [("may.csv", b"csv")]
+        )
+
+    def test_authorize_url(self):
+        """Test that sign-in asks for offline mail access with the state."""
+        mailbox = Mailbox.from_json("bank", MAILBOX_JSON)
+
+        url = PROVIDERS["graph"].authorize_url(
+            mailbox, "https://app.test/cb", "bank.xyz"
+        )
+
+        self.assertTrue(url.startswith("https://login.microsoftonline.com/common/"))
+        self.assertIn("offline_access", url)
+        self.assertIn("state=bank.xyz", url)
+
+
+class TestGmailProvider(unittest.TestCase):
+    """Test suite for Gmail."""
+
+    @patch("rmanalyzer.mailbox._fetch")
+    def test_nested_attachments(self, mock_fetch):
+        """Test that attachments nested in multipart bodies are downloaded."""
+        mock_fetch.side_effect = [
+            {
+                "payload": {
+                    "parts": [
+                        {"filename": "", "body": {}},
+                        {
+                            "parts": [
+                                {
+                                    "filename": "may.qif",
+                                    "body": {"attachmentId": "a1"},
+                                }
+                            ]
+                        },
+                    ]
+                }
+            },
+            # Unpadded base64url, as Gmail may send it
+            {"data": base64.urlsafe_b64encode(b"qif!").decode().rstrip("=")},
+        ]
+
+        self.assertEqual(
+            PROVIDERS["gmail"].attachments("token", "m1"), [("may.qif", b"qif!")]
+        )
+        self.assertTrue(mock_fetch.call_args.args[0].endswith("/m1/attachments/a1"))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,362 @@
+"""
+Tests for the handler recovery wrappers.
+"""
+
+import dataclasses
+import json
+import os
+import unittest
+from typing import Iterator
+from unittest.mock import MagicMock, patch
+
+import azure.functions as func
+
+from rmanalyzer import sandbox
+from rmanalyzer.middleware import (
+    api_version,
+    failure_counts,
+    household,
+    http_logging,
+    http_recovery,
+    http_streaming,
+    queue_recovery,
+)
+from rmanalyzer.services import table_metrics
+
+
+class TestRecovery(unittest.TestCase):
+    """Test suite for http_recovery and queue_recovery."""
+
+    def test_http_passthrough(self):
+        """Test that successful responses are returned unchanged."""
+        ok = func.HttpResponse("ok", status_code=200)
+        wrapped = http_recovery(lambda req: ok)
+        self.assertIs(wrapped(MagicMock()), ok)
+
+    def test_http_failure_returns_envelope(self):
+        """Test that unhandled errors become a 500 with the correlation ID."""
+
+        def failing_handler(req):
+            raise RuntimeError("boom")
+
+        req = MagicMock(spec=func.HttpRequest)
+        req.headers = {"x-correlation-id": "abc123"}
+        before = failure_counts().get("failing_handler", 0)
+
+        with self.assertLogs("rmanalyzer.middleware", level="ERROR") as logs:
+            resp = http_recovery(failing_handler)(req)
+
+        self.assertEqual(resp.status_code, 500)
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["correlationId"], "abc123")
+        self.assertNotIn("boom", payload["error"])
+        self.assertIn("correlation_id=abc123", logs.output[0])
+        self.assertIn("Traceback", logs.output[0])
+        self.assertEqual(failure_counts()["failing_handler"], before + 1)
+
+    def test_http_failure_generates_correlation_id(self):
+        """Test that a correlation ID is generated when none is supplied."""
+
+        def failing_handler(req):
+            raise RuntimeError("boom")
+
+        req = MagicMock(spec=func.HttpRequest)
+        req.headers = {}
+        with self.assertLogs("rmanalyzer.middleware", level="ERROR"):
+            resp = http_recovery(failing_handler)(req)
+
+        payload = json.loads(resp.get_body())
+        self.assertTrue(payload["correlationId"])
+        self.assertEqual(resp.headers["x-correlation-id"], payload["correlationId"])
+
+    def test_queue_failure_reraises(self):
+        """Test that queue failures are logged with message details and re-raised."""
+
+        def failing_queue_handler(msg):
+            raise RuntimeError("boom")
+
+        msg = MagicMock(spec=func.QueueMessage)
+        msg.id = "msg-1"
+        msg.dequeue_count = 3
+
+        with self.assertLogs("rmanalyzer.middleware", level="ERROR") as logs:
+            with self.assertRaises(RuntimeError):
+                queue_recovery(failing_queue_handler)(msg)
+
+        self.assertIn("message_id=msg-1", logs.output[0])
+        self.assertIn("dequeue_count=3", logs.output[0])
+
+
+class TestHttpLogging(unittest.TestCase):
+    """Test suite for http_logging."""
+
+    def setUp(self):
+        self.req = MagicMock(spec=func.HttpRequest)
+        self.req.method = "POST"
+        self.req.url = "http://localhost/api/savings"
+        self.req.headers = {"content-type": "application/json"}
+        self.req.get_body.return_value = b'{"startingBalance": 100}'
+
+    @staticmethod
+    def savings(req):
+        return func.HttpResponse("Saved successfully", status_code=200)
+
+    def test_summary_without_body(self):
+        """Test that bodies are not logged unless the route opts in."""
+        with self.assertLogs("rmanalyzer.middleware", level="DEBUG") as logs:
+            http_logging()(self.savings)(self.req)
+
+        self.assertEqual(len(logs.output), 1)
+        self.assertIn("POST /api/savings -> 200", logs.output[0])
+
+    def test_signatures_redacted(self):
+        """Test that signatures and credentials in the query are never logged."""
+        self.req.url = "http://localhost/api/oauth/callback?code=abc&state=xyz&month=1"
+
+        with self.assertLogs("rmanalyzer.middleware", level="DEBUG") as logs:
+            http_logging()(self.savings)(self.req)
+
+        self.assertNotIn("abc", logs.output[0])
+        self.assertNotIn("xyz", logs.output[0])
+        self.assertIn("code=REDACTED&state=REDACTED&month=1", logs.output[0])
+
+    def test_body_opt_in(self):
+        """Test that opted-in routes log request and response bodies."""
+        with self.assertLogs("rmanalyzer.middleware", level="DEBUG") as logs:
+            http_logging(log_body=True)(self.savings)(self.req)
+
+        output = "\n".join(logs.output)
+        self.assertIn('request body: {"startingBalance": 100}', output)
+        self.assertIn("response body: Saved successfully", output)
+
+    @patch.dict(
+        os.environ, {"LOG_BODY_ROUTES": "savings", "LOG_BODY_PREVIEW_BYTES": "5"}
+    )
+    def test_body_opt_in_from_env_truncates(self):
+        """Test that routes can opt in via settings and previews are capped."""
+        with self.assertLogs("rmanalyzer.middleware", level="DEBUG") as logs:
+            http_logging()(self.savings)(self.req)
+
+        output = "\n".join(logs.output)
+        self.assertIn('request body: {"sta... <19 more bytes>', output)
+
+    @patch("rmanalyzer.middleware.shared_metrics")
+    def test_counts_requests_and_server_errors(self, mock_metrics):
+        """Test that requests and 5xx responses are counted for the error rate."""
+        failing = lambda req: func.HttpResponse("boom", status_code=503)
+        with self.assertLogs("rmanalyzer.middleware", level="DEBUG"):
+            http_logging()(self.savings)(self.req)
+            http_logging()(failing)(self.req)
+
+        self.assertEqual(
+            [c.kwargs for c in mock_metrics.add.call_args_list],
+            [
+                {"requests": 1, "serverErrors": 0},
+                {"requests": 1, "serverErrors": 1},
+            ],
+        )
+        self.assertEqual(mock_metrics.add.call_args[0], ("http",))
+        # Counts reach the shared store as requests are handled
+        self.assertEqual(mock_metrics.flush.call_count, 2)
+
+    def test_multipart_suppressed(self):
+        """Test that multipart bodies are never logged."""
+        self.req.headers = {"content-type": "multipart/form-data; boundary=x"}
+        self.req.get_body.return_value = b"--x\r\nsecret csv"
+
+        with self.assertLogs("rmanalyzer.middleware", level="DEBUG") as logs:
+            http_logging(log_body=True)(self.savings)(self.req)
+
+        output = "\n".join(logs.output)
+        self.assertNotIn("secret", output)
+        self.assertIn("bytes suppressed (multipart/form-data", output)
+
+    def test_binary_suppressed(self):
+        """Test that non-UTF-8 bodies are suppressed."""
+        self.req.headers = {}
+        self.req.get_body.return_value = b"\xff\xfe\x00"
+
+        with self.assertLogs("rmanalyzer.middleware", level="DEBUG") as logs:
+            http_logging(log_body=True)(self.savings)(self.req)
+
+        self.assertIn("3 bytes suppressed (binary)", "\n".join(logs.output))
+
+
+class TestApiVersion(unittest.TestCase):
+    """Test suite for api_version."""
+
+    def setUp(self):
+        self.req = MagicMock(spec=func.HttpRequest)
+        self.req.headers = {}
+        self.handler = api_version(amount_fields=("p1",))(
+            lambda req: func.HttpResponse(
+                json.dumps({"p1": 12.3, "total": 5, "count": 2}),
+                mimetype="application/json",
+            )
+        )
+
+    def test_v1_keeps_numbers(self):
+        """Test that amounts stay numbers when no version is asked for."""
+        resp = self.handler(self.req)
+
+        self.assertEqual(
+            json.loads(resp.get_body()), {"p1": 12.3, "total": 5, "count": 2}
+        )
+        self.assertEqual(resp.headers["x-api-version"], "1")
+
+    def test_v2_writes_amounts_as_strings(self):
+        """Test that v2 writes the route's and the common amount fields as strings."""
+        self.req.headers = {"x-api-version": "2"}
+
+        resp = self.handler(self.req)
+
+        self.assertEqual(
+            json.loads(resp.get_body()), {"p1": "12.30", "total": "5.00", "count": 2}
+        )
+        self.assertEqual(resp.headers["x-api-version"], "2")
+
+    def test_unsupported_version(self):
+        """Test that an unknown version is rejected before the handler runs."""
+        self.req.headers = {"x-api-version": "9"}
+        self.assertEqual(self.handler(self.req).status_code, 400)
+
+
+@patch.dict(os.environ, {"SANDBOX_ENABLED": "true"})
+class TestHousehold(unittest.TestCase):
+    """Test suite for household."""
+
+    def setUp(self):
+        self.req = MagicMock(spec=func.HttpRequest)
+        self.req.headers = {}
+        self.seen = []
+
+        def handler(req):
+            self.seen.append(sandbox.active())
+            return func.HttpResponse("ok")
+
+        self.handler = handler
+
+    def test_live_by_default(self):
+        """Test that requests without the header use the live household."""
+        resp = household()(self.handler)(self.req)
+
+        self.assertEqual(self.seen, [False])
+        self.assertEqual(resp.headers["x-household"], "live")
+
+    def test_sandbox_header(self):
+        """Test that the header runs the handler in the sandbox."""
+        self.req.headers = {"x-household": "Sandbox"}
+
+        resp = household()(self.handler)(self.req)
+
+        self.assertEqual(self.seen, [True])
+        self.assertEqual(resp.headers["x-household"], "sandbox")
+        self.assertFalse(sandbox.active())
+
+    def test_unknown_household(self):
+        """Test that an unknown household is rejected."""
+        self.req.headers = {"x-household": "staging"}
+        self.assertEqual(household()(self.handler)(self.req).status_code, 400)
+        self.assertEqual(self.seen, [])
+
+    @patch.dict(os.environ, {"SANDBOX_ENABLED": "false"})
+    def test_sandbox_disabled(self):
+        """Test that the sandbox isn't found when the deployment has none."""
+        self.req.headers = {"x-household": "sandbox"}
+        self.assertEqual(household()(self.handler)(self.req).status_code, 404)
+
+    def test_route_without_sandbox(self):
+        """Test that routes touching live-only storage refuse the sandbox."""
+        self.req.headers = {"x-household": "sandbox"}
+        wrapped = household(sandboxed=False)(self.handler)
+        self.assertEqual(wrapped(self.req).status_code, 400)
+        self.assertEqual(self.seen, [])
+
+
+@dataclasses.dataclass
+class Stream:
+    """A download read a chunk at a time."""
+
+    chunks: Iterator[str]
+
+
+@patch.dict(os.environ, {"SANDBOX_ENABLED": "true"})
+class TestHttpStreaming(unittest.TestCase):
+    """Test suite for http_streaming."""
+
+    def setUp(self):
+        self.req = MagicMock(spec=func.HttpRequest)
+        self.req.method = "GET"
+        self.req.url = "http://localhost/api/export?month=2024-01"
+        self.req.headers = {"x-household": "sandbox", "x-correlation-id": "abc123"}
+        self.seen = []
+
+    def rows(self, fail: bool = False) -> Iterator[str]:
+        self.seen.append((sandbox.active(), table_metrics.current_operation()))
+        yield "a\n"
+        if fail:
+            raise RuntimeError("boom")
+        yield "b\n"
+
+    @patch("rmanalyzer.middleware.shared_metrics")
+    def test_stream_read_in_request_scope(self, mock_metrics):
+        """Test that chunks are read in the request's household and operation."""
+
+        def export(req):
+            return Stream(self.rows())
+
+        with self.assertLogs("rmanalyzer.middleware", level="INFO") as logs:
+            stream = http_streaming(export)(self.req)
+            self.assertEqual(logs.output, [])
+            self.assertEqual(list(stream.chunks), ["a\n", "b\n"])
+
+        self.assertEqual(self.seen, [(True, "export")])
+        self.assertFalse(sandbox.active())
+        self.assertIn("GET /api/export?month=2024-01 -> 200", logs.output[0])
+        mock_metrics.add.assert_called_once_with("http", requests=1, serverErrors=0)
+
+    @patch("rmanalyzer.middleware.shared_metrics")
+    def test_failure_part_way_aborts(self, mock_metrics):
+        """Test that a failing chunk is logged, counted and aborts the stream."""
+
+        def export(req):
+            return Stream(self.rows(fail=True))
+
+        before = failure_counts().get("export", 0)
+        stream = http_streaming(export)(self.req)
+        with self.assertLogs("rmanalyzer.middleware", level="INFO") as logs:
+            self.assertEqual(next(stream.chunks), "a\n")
+            with self.assertRaises(RuntimeError):
+                next(stream.chunks)
+
+        self.assertIn("correlation_id=abc123", logs.output[0])
+        self.assertIn("Traceback", logs.output[0])
+        self.assertIn("-> 500", logs.output[-1])
+        self.assertEqual(failure_counts()["export"], before + 1)
+        mock_metrics.add.assert_called_once_with("http", requests=1, serverErrors=1)
+
+    @patch("rmanalyzer.middleware.shared_metrics")
+    def test_responses_pass_through(self, mock_metrics):
+        """Test that requests turned away or failing before streaming get a response."""
+
+        def export(req):
+            raise RuntimeError("boom")
+
+        with self.assertLogs("rmanalyzer.middleware", level="INFO"):
+            resp = http_streaming(export)(self.req)
+            self.req.headers = {"x-household": "staging"}
+            turned_away = http_streaming(export)(self.req)
+
+        self.assertEqual(resp.status_code, 500)
+        self.assertEqual(turned_away.status_code, 400)
+        self.assertEqual(
+            [c.kwargs for c in mock_metrics.add.call_args_list],
+            [
+                {"requests": 1, "serverErrors": 1},
+                {"requests": 1, "serverErrors": 0},
+            ],
+        )
+
+
+if __name__ == "__main__":
+    unittest.main()
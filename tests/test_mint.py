@@ -0,0 +1,67 @@
+"""
+Tests for Mint transaction export parsing.
+"""
+
+import unittest
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.mint import get_mint_transactions, looks_like_mint
+from rmanalyzer.models import Category, IgnoredFrom
+
+HEADER = (
+    '"Date","Description","Original Description","Amount","Transaction Type",'
+    '"Category","Account Name","Labels","Notes"\n'
+)
+
+EXPORT = HEADER + (
+    '"1/15/2025","Safeway","SAFEWAY #1234","42.50","debit","Groceries",'
+    '"Checking ...1234","",""\n'
+    '"1/16/2025","Amazon","AMZN MKTP","1,200.00","credit","Shopping",'
+    '"Sapphire 5678","",""\n'
+    '"1/17/2025","Chase","PAYMENT THANK YOU","500.00","credit",'
+    '"Credit Card Payment","Sapphire 5678","",""\n'
+    '"1/18/2025","Cafe","CAFE","4.00","debit","Coffee Shops","Wallet","",""\n'
+    '"1/19/2025","Cafe","CAFE","4.00","refund","Coffee Shops","Card 1","",""\n'
+)
+
+
+class TestMint(unittest.TestCase):
+    """Test suite for Mint export parsing."""
+
+    def test_looks_like_mint(self):
+        """Test that the Mint header tells an export apart from a plain CSV."""
+        self.assertTrue(looks_like_mint("\ufeff" + HEADER))
+        self.assertFalse(looks_like_mint("Date,Name,Account Number,Amount\n"))
+        self.assertFalse(looks_like_mint(""))
+
+    def test_export(self):
+        """Test signs, account numbers, categories and transfers."""
+        transactions, errors = get_mint_transactions(EXPORT)
+
+        self.assertEqual(len(transactions), 3)
+        grocery, refund, payment = transactions
+        self.assertEqual(grocery.date, date(2025, 1, 15))
+        self.assertEqual(grocery.name, "Safeway")
+        self.assertEqual(grocery.account_number, 1234)
+        self.assertEqual(grocery.amount, Decimal("42.50"))
+        self.assertEqual(grocery.category, Category.GROCERIES)
+        self.assertEqual(grocery.ignore, IgnoredFrom.NOTHING)
+
+        self.assertEqual(refund.account_number, 5678)
+        self.assertEqual(refund.amount, Decimal("-1200.00"))
+        self.assertEqual(refund.category, Category.OTHER)
+
+        self.assertEqual(payment.ignore, IgnoredFrom.EVERYTHING)
+
+        self.assertEqual(
+            errors,
+            [
+                "Row 4: No account number in 'Account Name': Wallet",
+                "Row 5: Invalid 'Transaction Type': refund",
+            ],
+        )
+
+
+if __name__ == "__main__":
+    unittest.main()
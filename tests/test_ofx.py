@@ -0,0 +1,99 @@
+"""
+Tests for OFX/QFX statement parsing.
+"""
+
+import unittest
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.models import Category
+from rmanalyzer.ofx import get_ofx_transactions, is_ofx
+
+# OFX 1.x: SGML header, leaf elements left unclosed
+SGML_STATEMENT = """OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>USD
+<BANKACCTFROM><BANKID>123<ACCTID>000098761234<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20250115120000.000[-5:EST]
+<TRNAMT>-42.50
+<FITID>1
+<NAME>SAFEWAY #1234
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20250116
+<TRNAMT>100.00
+<FITID>2
+<MEMO>Refund
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20250117
+<FITID>3
+<NAME>No amount
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+"""
+
+# OFX 2.x: XML, credit card statement
+XML_STATEMENT = """<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX><CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+<CCACCTFROM><ACCTID>4111-XXXX-XXXX-5678</ACCTID></CCACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<DTPOSTED>20250201</DTPOSTED><TRNAMT>-9.99</TRNAMT><NAME>NETFLIX</NAME>
+</STMTTRN>
+</BANKTRANLIST>
+</CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1></OFX>
+"""
+
+
+class TestOfx(unittest.TestCase):
+    def test_is_ofx(self):
+        self.assertTrue(is_ofx("20250101_statement.QFX"))
+        self.assertTrue(is_ofx("statement.ofx"))
+        self.assertFalse(is_ofx("statement.csv"))
+
+    def test_sgml_statement(self):
+        transactions, errors = get_ofx_transactions(SGML_STATEMENT)
+
+        self.assertEqual(len(transactions), 2)
+        purchase, refund = transactions
+        self.assertEqual(purchase.date, date(2025, 1, 15))
+        self.assertEqual(purchase.name, "SAFEWAY #1234")
+        self.assertEqual(purchase.account_number, 1234)
+        self.assertEqual(purchase.amount, Decimal("42.50"))
+        self.assertEqual(purchase.category, Category.OTHER)
+        # Credits are negative, names fall back to the memo
+        self.assertEqual(refund.amount, Decimal("-100.00"))
+        self.assertEqual(refund.name, "Refund")
+        self.assertEqual(
+            errors, ["Statement 1, transaction 3: Invalid or missing 'TRNAMT': None"]
+        )
+
+    def test_xml_credit_card_statement(self):
+        transactions, errors = get_ofx_transactions(XML_STATEMENT)
+
+        self.assertEqual(errors, [])
+        self.assertEqual(len(transactions), 1)
+        self.assertEqual(transactions[0].account_number, 5678)
+        self.assertEqual(transactions[0].amount, Decimal("9.99"))
+
+    def test_no_statement(self):
+        transactions, errors = get_ofx_transactions("Date,Name\n")
+        self.assertEqual(transactions, [])
+        self.assertEqual(len(errors), 1)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,58 @@
+"""
+Tests for operational alert thresholds.
+"""
+
+import unittest
+
+from rmanalyzer.ops_alerts import (
+    MIN_REQUESTS,
+    consecutive_failures,
+    error_rate,
+    firing,
+    queue_values,
+)
+
+
+class TestOpsAlerts(unittest.TestCase):
+    def test_error_rate(self):
+        self.assertEqual(error_rate({"requests": 50, "serverErrors": 5}), 0.1)
+        self.assertEqual(error_rate({"requests": 50}), 0)
+
+    def test_error_rate_needs_enough_requests(self):
+        self.assertIsNone(error_rate({"requests": MIN_REQUESTS - 1, "serverErrors": 5}))
+        self.assertIsNone(error_rate({}))
+
+    def test_queue_values_skip_poison_queues(self):
+        queues = [
+            {"poison": False, "messages": 3, "oldestAgeSeconds": 150},
+            {"poison": False, "messages": 0, "oldestAgeSeconds": None},
+            {"poison": True, "messages": 40, "oldestAgeSeconds": 90000},
+        ]
+        self.assertEqual(
+            queue_values(queues), {"queueBacklog": 3, "queueAgeMinutes": 2}
+        )
+
+    def test_queue_values_without_messages(self):
+        queues = [{"poison": False, "messages": 0, "oldestAgeSeconds": None}]
+        self.assertEqual(
+            queue_values(queues), {"queueBacklog": 0, "queueAgeMinutes": None}
+        )
+
+    def test_consecutive_failures(self):
+        runs = [{"status": "failed"}, {"status": "failed"}, {"status": "succeeded"}]
+        self.assertEqual(consecutive_failures(runs), 2)
+        self.assertEqual(consecutive_failures(runs[2:]), 0)
+        self.assertEqual(consecutive_failures([]), 0)
+
+    def test_firing(self):
+        self.assertEqual(
+            firing(
+                {"errorRate": None, "queueBacklog": 100, "jobFailures": 5},
+                {"errorRate": 0.05, "queueBacklog": 100, "jobFailures": 0},
+            ),
+            [{"metric": "queueBacklog", "value": 100, "threshold": 100}],
+        )
+
+
+if __name__ == "__main__":
+    unittest.main()
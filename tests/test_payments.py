@@ -0,0 +1,49 @@
+"""
+Tests for card payment due dates.
+"""
+
+import unittest
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.payments import leads_due, next_due_date, payment_made
+
+
+class TestPayments(unittest.TestCase):
+    def test_next_due_date(self):
+        self.assertEqual(next_due_date(15, date(2025, 3, 10)), date(2025, 3, 15))
+        self.assertEqual(next_due_date(15, date(2025, 3, 15)), date(2025, 3, 15))
+        self.assertEqual(next_due_date(15, date(2025, 12, 20)), date(2026, 1, 15))
+
+    def test_due_day_clamped_to_short_months(self):
+        self.assertEqual(next_due_date(31, date(2025, 2, 10)), date(2025, 2, 28))
+        self.assertEqual(next_due_date(31, date(2025, 3, 1)), date(2025, 3, 31))
+
+    def test_payment_made_within_cycle(self):
+        def credit(day):
+            return Transaction(
+                day,
+                "PAYMENT THANK YOU",
+                1234,
+                Decimal("-300.00"),
+                Category.OTHER,
+                IgnoredFrom.NOTHING,
+            )
+
+        due = date(2025, 3, 15)
+        self.assertTrue(payment_made([credit(date(2025, 3, 1))], "1234", due))
+        self.assertFalse(payment_made([credit(date(2025, 2, 1))], "1234", due))
+        self.assertFalse(payment_made([credit(date(2025, 3, 1))], "9999", due))
+        self.assertFalse(payment_made([credit(date(2025, 3, 1))], None, due))
+
+    def test_leads_due(self):
+        self.assertEqual(leads_due([7, 3, 1], 8, []), [])
+        self.assertEqual(leads_due([7, 3, 1], 5, []), [7])
+        self.assertEqual(leads_due([7, 3, 1], 5, [7]), [])
+        self.assertEqual(leads_due([7, 3, 1], 2, [7]), [3])
+        self.assertEqual(leads_due([7, 3, 1], 0, []), [7, 3, 1])
+
+
+if __name__ == "__main__":
+    unittest.main()
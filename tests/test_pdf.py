@@ -0,0 +1,42 @@
+"""
+Tests for rendering text reports as PDF.
+"""
+
+import re
+import unittest
+
+from rmanalyzer.pdf import LINES_PER_PAGE, text_pdf
+
+
+class TestPdf(unittest.TestCase):
+    def test_document_structure(self):
+        document = text_pdf("Review", ["Total (May): 12.30", "back\\slash"])
+
+        self.assertTrue(document.startswith(b"%PDF-1.4"))
+        self.assertTrue(document.endswith(b"%%EOF\n"))
+        self.assertIn(b"(Total \\(May\\): 12.30) '", document)
+        self.assertIn(b"(back\\\\slash) '", document)
+
+        # The cross-reference table points at each object
+        offsets = re.search(
+            rb"xref\n0 \d+\n0000000000 65535 f \n((?:\d{10} 00000 n \n)+)", document
+        )
+        for number, line in enumerate(offsets.group(1).splitlines(), start=1):
+            start = int(line[:10])
+            self.assertTrue(document[start:].startswith(f"{number} 0 obj".encode()))
+
+    def test_long_reports_span_pages(self):
+        document = text_pdf("Review", [f"line {i}" for i in range(LINES_PER_PAGE + 1)])
+
+        self.assertIn(b"/Count 2", document)
+        self.assertEqual(document.count(b"(Review) Tj"), 2)
+
+    def test_wraps_long_lines(self):
+        document = text_pdf("Review", ["word " * 40])
+
+        self.assertEqual(document.count(b"word"), 40)
+        self.assertGreater(document.count(b") '"), 1)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,48 @@
+"""
+Tests for promo APR expiry tracking.
+"""
+
+import unittest
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.promos import expiring_promos, monthly_interest
+
+
+class TestPromos(unittest.TestCase):
+    def test_monthly_interest(self):
+        self.assertEqual(
+            monthly_interest(Decimal("1200"), Decimal("24")), Decimal("24.00")
+        )
+        self.assertEqual(
+            monthly_interest(Decimal("-50"), Decimal("24")), Decimal("0.00")
+        )
+
+    def test_expiring_promos(self):
+        accounts = [
+            {
+                "accountId": "later",
+                "balance": 500.0,
+                "apr": 20.0,
+                "promoExpiry": "2025-05-01",
+            },
+            {
+                "accountId": "soon",
+                "balance": 1200.0,
+                "apr": 24.0,
+                "promoExpiry": "2025-03-31",
+            },
+            {"accountId": "paid-off", "balance": 0, "promoExpiry": "2025-03-20"},
+            {"accountId": "expired", "balance": 100.0, "promoExpiry": "2025-03-01"},
+            {"accountId": "no-promo", "balance": 100.0},
+        ]
+
+        expiring = expiring_promos(accounts, date(2025, 3, 10), 30)
+
+        self.assertEqual([a["accountId"] for a in expiring], ["soon"])
+        self.assertEqual(expiring[0]["daysLeft"], 21)
+        self.assertEqual(expiring[0]["projectedInterest"], 24.0)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,112 @@
+"""
+Tests for QIF statement parsing.
+"""
+
+import unittest
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.models import Category
+from rmanalyzer.qif import get_qif_transactions, is_qif, looks_like_qif
+
+# Single account download, no !Account record
+BANK_STATEMENT = """!Type:Bank
+D01/15/2025
+T-42.50
+PSAFEWAY #1234
+LGroceries
+^
+D1/16'25
+T1,000.00
+MPaycheck
+^
+D1/17/25
+PNo amount
+^
+"""
+
+# Quicken export of several accounts, with a category list and a split
+MULTI_ACCOUNT = """!Option:AutoSwitch
+!Account
+NChecking ...1234
+TBank
+^
+!Clear:AutoSwitch
+!Type:Cat
+NGroceries
+^
+!Account
+NSapphire 5678
+TCCard
+^
+!Type:CCard
+D02/01/2025
+T-30.00
+PCOSTCO
+SGroceries
+$-20.00
+SHousehold
+$-10.00
+^
+!Type:Invst
+D02/02/2025
+NBuy
+YVTI
+^
+"""
+
+
+class TestQif(unittest.TestCase):
+    """Test suite for QIF parsing."""
+
+    def test_is_qif(self):
+        """Test that QIF files are recognized by extension and content."""
+        self.assertTrue(is_qif("20250101_export.QIF"))
+        self.assertFalse(is_qif("export.csv"))
+        self.assertTrue(looks_like_qif("\ufeff\n!Type:Bank\n"))
+        self.assertFalse(looks_like_qif("Date,Name\n"))
+
+    def test_bank_statement(self):
+        """Test dates, signs, payees and categories of a bank download."""
+        transactions, errors = get_qif_transactions(BANK_STATEMENT, 1234)
+
+        self.assertEqual(len(transactions), 2)
+        spend, income = transactions
+        self.assertEqual(spend.date, date(2025, 1, 15))
+        self.assertEqual(spend.name, "SAFEWAY #1234")
+        self.assertEqual(spend.account_number, 1234)
+        self.assertEqual(spend.amount, Decimal("42.50"))
+        self.assertEqual(spend.category, Category.GROCERIES)
+        self.assertEqual(income.date, date(2025, 1, 16))
+        self.assertEqual(income.name, "Paycheck")
+        self.assertEqual(income.amount, Decimal("-1000.00"))
+        self.assertEqual(income.category, Category.OTHER)
+        self.assertEqual(errors, ["Transaction 3: Invalid or missing 'T' (amount): "])
+
+    def test_missing_account_number(self):
+        """Test that transactions need an account number from somewhere."""
+        transactions, errors = get_qif_transactions(BANK_STATEMENT)
+
+        self.assertEqual(transactions, [])
+        self.assertEqual(len(errors), 3)
+        self.assertIn("No account number", errors[0])
+
+    def test_accounts_and_splits(self):
+        """Test that !Account names number accounts and splits keep their total."""
+        transactions, errors = get_qif_transactions(MULTI_ACCOUNT, 9999)
+
+        self.assertEqual(errors, [])
+        self.assertEqual(len(transactions), 1)
+        self.assertEqual(transactions[0].account_number, 5678)
+        self.assertEqual(transactions[0].amount, Decimal("30.00"))
+
+    def test_no_transactions(self):
+        """Test that a file without bank or card transactions is an error."""
+        transactions, errors = get_qif_transactions("!Type:Cat\nNGroceries\n^\n")
+
+        self.assertEqual(transactions, [])
+        self.assertEqual(errors, ["No bank, cash or credit card transactions found"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,181 @@
+"""
+Tests for queue message sending.
+"""
+
+import base64
+import json
+import unittest
+from datetime import datetime, timedelta, timezone
+from unittest.mock import MagicMock
+
+from rmanalyzer.services import BlobKind, QueuePriority, QueueService
+from rmanalyzer.services.queue_service import MAX_MESSAGE_SIZE
+
+
+class TestQueueService(unittest.TestCase):
+    """Test suite for QueueService.enqueue_message."""
+
+    def setUp(self):
+        self.service = QueueService()
+        self.mock_client = MagicMock()
+        # pylint: disable=protected-access
+        self.service._get_queue_client = MagicMock(return_value=self.mock_client)
+
+    def test_enqueue_encodes_message(self):
+        """Test that messages are JSON then Base64 encoded."""
+        self.service.enqueue_message({"blob_name": "a.csv"})
+
+        args, kwargs = self.mock_client.send_message.call_args
+        decoded = json.loads(base64.b64decode(args[0]))
+        self.assertEqual(decoded, {"blob_name": "a.csv"})
+        self.assertIsNone(kwargs["visibility_timeout"])
+        self.assertIsNone(kwargs["time_to_live"])
+
+    def test_enqueue_with_delay_and_ttl(self):
+        """Test that delay and ttl are passed through to the queue."""
+        self.service.enqueue_message({"blob_name": "a.csv"}, delay=60, ttl=3600)
+
+        _, kwargs = self.mock_client.send_message.call_args
+        self.assertEqual(kwargs["visibility_timeout"], 60)
+        self.assertEqual(kwargs["time_to_live"], 3600)
+
+    def test_enqueue_never_expires(self):
+        """Test that ttl=-1 is allowed."""
+        self.service.enqueue_message({}, delay=60, ttl=-1)
+        _, kwargs = self.mock_client.send_message.call_args
+        self.assertEqual(kwargs["time_to_live"], -1)
+
+    def test_enqueue_backfill_queue(self):
+        """Test that backfill messages go to the backfill queue."""
+        self.service.enqueue_message({}, priority=QueuePriority.BACKFILL)
+        # pylint: disable=protected-access
+        self.service._get_queue_client.assert_called_with("csv-backfill")
+
+    def test_queue_status(self):
+        """Test that each queue and its poison queue report length and oldest age."""
+        self.mock_client.get_queue_properties.return_value.approximate_message_count = 4
+        self.mock_client.peek_messages.return_value = [
+            MagicMock(inserted_on=datetime.now(timezone.utc) - timedelta(minutes=5))
+        ]
+
+        status = self.service.queue_status()
+
+        self.assertEqual(len(status), 4)
+        backfill = [q for q in status if q["priority"] == "backfill"]
+        self.assertEqual(
+            [(q["name"], q["poison"]) for q in backfill],
+            [("csv-backfill", False), ("csv-backfill-poison", True)],
+        )
+        self.assertEqual(backfill[0]["messages"], 4)
+        self.assertIn(backfill[0]["oldestAgeSeconds"], (300, 301))
+
+    def test_queue_status_empty_queue_has_no_age(self):
+        """Test that a queue with nothing to peek has no oldest message age."""
+        self.mock_client.peek_messages.return_value = []
+        self.assertIsNone(self.service.queue_status()[0]["oldestAgeSeconds"])
+
+    def test_probe_round_trip(self):
+        """Test that the probe sends, receives and deletes on the probe queue."""
+        self.mock_client.receive_messages.side_effect = lambda **_: [
+            MagicMock(content=self.mock_client.send_message.call_args[0][0])
+        ]
+
+        self.service.probe()
+
+        # pylint: disable=protected-access
+        self.service._get_queue_client.assert_called_with("selftest")
+        self.mock_client.delete_message.assert_called_once()
+
+    def test_probe_message_missing(self):
+        """Test that the probe fails when its message never arrives."""
+        self.mock_client.receive_messages.return_value = []
+        with self.assertRaises(RuntimeError):
+            self.service.probe()
+
+    def test_resubmit_tags_failure(self):
+        """Test that resubmitted messages carry their failure record ID."""
+        self.service.resubmit({"blob_name": "a.csv", "format": "csv"}, "f1")
+
+        args, _ = self.mock_client.send_message.call_args
+        decoded = json.loads(base64.b64decode(args[0]))
+        self.assertEqual(
+            decoded, {"blob_name": "a.csv", "format": "csv", "failure_id": "f1"}
+        )
+
+    def test_enqueue_invalid_schedule(self):
+        """Test that out-of-range delays and ttls are rejected."""
+        with self.assertRaises(ValueError):
+            self.service.enqueue_message({}, delay=8 * 24 * 60 * 60)
+        with self.assertRaises(ValueError):
+            self.service.enqueue_message({}, ttl=0)
+        with self.assertRaises(ValueError):
+            self.service.enqueue_message({}, delay=120, ttl=60)
+        self.mock_client.send_message.assert_not_called()
+
+
+class TestClaimCheck(unittest.TestCase):
+    """Test suite for claim-check handling of oversized messages."""
+
+    def setUp(self):
+        self.blob_service = MagicMock()
+        self.service = QueueService(self.blob_service)
+        self.mock_client = MagicMock()
+        # pylint: disable=protected-access
+        self.service._get_queue_client = MagicMock(return_value=self.mock_client)
+
+    def test_small_message_sent_inline(self):
+        """Test that small payloads are not stored in blob."""
+        self.service.enqueue_message({"blob_name": "a.csv"})
+        self.blob_service.upload_blob.assert_not_called()
+
+    def test_large_message_checked_in(self):
+        """Test that oversized payloads are replaced by a blob reference."""
+        message = {"blob_name": "a.csv", "options": "x" * MAX_MESSAGE_SIZE}
+        self.service.enqueue_message(message)
+
+        kind, blob_name, content = self.blob_service.upload_blob.call_args[0]
+        self.assertEqual(kind, BlobKind.MESSAGES)
+        self.assertEqual(json.loads(content), message)
+
+        args, _ = self.mock_client.send_message.call_args
+        envelope = json.loads(base64.b64decode(args[0]))
+        self.assertEqual(envelope, {"claim_check": blob_name})
+
+    def test_large_message_without_blob_service(self):
+        """Test that oversized payloads fail without a BlobService."""
+        service = QueueService()
+        # pylint: disable=protected-access
+        service._get_queue_client = MagicMock()
+        with self.assertRaises(ValueError):
+            service.enqueue_message({"options": "x" * MAX_MESSAGE_SIZE})
+
+    def test_load_message_rehydrates(self):
+        """Test that claim checks are transparently rehydrated."""
+        self.blob_service.download_blob.return_value = b'{"blob_name": "a.csv"}'
+
+        data = self.service.load_message('{"claim_check": "abc.json"}')
+
+        self.assertEqual(data, {"blob_name": "a.csv"})
+        self.blob_service.download_blob.assert_called_once_with(
+            BlobKind.MESSAGES, "abc.json"
+        )
+
+    def test_load_plain_message(self):
+        """Test that plain messages are returned as-is."""
+        data = self.service.load_message('{"blob_name": "a.csv"}')
+        self.assertEqual(data, {"blob_name": "a.csv"})
+        self.blob_service.download_blob.assert_not_called()
+
+    def test_discard_message(self):
+        """Test that processed claim checks are deleted and plain messages ignored."""
+        self.service.discard_message('{"blob_name": "a.csv"}')
+        self.blob_service.delete_blob.assert_not_called()
+
+        self.service.discard_message('{"claim_check": "abc.json"}')
+        self.blob_service.delete_blob.assert_called_once_with(
+            "abc.json", BlobKind.MESSAGES
+        )
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,57 @@
+"""
+Tests for reconciling cards against statements.
+"""
+
+import unittest
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.reconcile import card_transactions, compare_balance
+
+
+def _transaction(day, account, amount):
+    return Transaction(
+        day, "Shop", account, Decimal(amount), Category.OTHER, IgnoredFrom.NOTHING
+    )
+
+
+class TestReconcile(unittest.TestCase):
+    def test_card_transactions_since_last_reconcile(self):
+        transactions = [
+            _transaction(date(2025, 2, 10), 1234, "5.00"),
+            _transaction(date(2025, 2, 11), 1234, "6.00"),
+            _transaction(date(2025, 3, 9), 1234, "7.00"),
+            _transaction(date(2025, 3, 10), 1234, "8.00"),
+            _transaction(date(2025, 2, 20), 4321, "9.00"),
+        ]
+
+        selected = card_transactions(
+            transactions, "1234", date(2025, 2, 10), date(2025, 3, 9)
+        )
+
+        self.assertEqual(
+            [t.amount for t in selected], [Decimal("6.00"), Decimal("7.00")]
+        )
+
+    def test_compare_balance(self):
+        transactions = [
+            _transaction(date(2025, 2, 11), 1234, "60.00"),
+            _transaction(date(2025, 3, 1), 1234, "-40.00"),
+        ]
+
+        matched = compare_balance(Decimal("120"), Decimal("100"), transactions)
+        self.assertEqual((matched["status"], matched["discrepancy"]), ("matched", 0.0))
+
+        off = compare_balance(Decimal("119.99"), Decimal("100"), transactions)
+        self.assertEqual((off["status"], off["discrepancy"]), ("discrepancy", -0.01))
+
+    def test_first_reconcile_is_baseline(self):
+        result = compare_balance(Decimal("120"), None, [])
+
+        self.assertEqual(result["status"], "baseline")
+        self.assertIsNone(result["discrepancy"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,773 @@
+"""
+Tests for recurring charge detection.
+"""
+
+import json
+import os
+import re
+import unittest
+from datetime import date, datetime, timedelta
+from decimal import Decimal
+from unittest.mock import call, patch
+
+from fixtures import http_request, start_patches
+from rmanalyzer import alerts
+from rmanalyzer.controller import controller
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.recurring import detect_recurring, missing_charges, price_increase
+
+
+class TestRecurring(unittest.TestCase):
+    def _transaction(self, name, day, amount="100.00", ignore=IgnoredFrom.NOTHING):
+        return Transaction(day, name, 1, Decimal(amount), Category.BILLS, ignore)
+
+    def test_detect_monthly(self):
+        transactions = [
+            self._transaction("Insurance Co 123", date(2025, 1, 15)),
+            self._transaction("INSURANCE CO 456", date(2025, 2, 14)),
+            self._transaction("Insurance Co", date(2025, 3, 16), "110.00"),
+        ]
+
+        charges = detect_recurring(transactions)
+
+        self.assertEqual(len(charges), 1)
+        charge = charges[0]
+        self.assertEqual(charge.merchant, "INSURANCE CO")
+        self.assertEqual(charge.cadence_days, 30)
+        self.assertEqual(charge.expected_date, date(2025, 4, 15))
+        self.assertEqual(charge.average_amount, Decimal("103.33"))
+
+    def test_irregular_and_frequent_charges_are_not_recurring(self):
+        start = date(2025, 1, 1)
+        transactions = [
+            # Irregular gaps
+            self._transaction("Hardware", start),
+            self._transaction("Hardware", start + timedelta(days=5)),
+            self._transaction("Hardware", start + timedelta(days=60)),
+            # Too frequent
+            self._transaction("Coffee", start),
+            self._transaction("Coffee", start + timedelta(days=2)),
+            self._transaction("Coffee", start + timedelta(days=4)),
+            # Too few
+            self._transaction("Gym", start),
+            self._transaction("Gym", start + timedelta(days=30)),
+            # Ignored
+            self._transaction("Rent", start, ignore=IgnoredFrom.EVERYTHING),
+            self._transaction("Rent", start + timedelta(days=30)),
+            self._transaction("Rent", start + timedelta(days=60)),
+        ]
+        self.assertEqual(detect_recurring(transactions), [])
+
+    def test_missing_charges(self):
+        charges = detect_recurring(
+            [
+                self._transaction("Rent", date(2025, 1, 1)),
+                self._transaction("Rent", date(2025, 1, 31)),
+                self._transaction("Rent", date(2025, 3, 2)),
+            ]
+        )
+        # Due 2025-04-01; missing once the grace days have passed
+        self.assertEqual(missing_charges(charges, date(2025, 4, 4), 3), [])
+        self.assertEqual(missing_charges(charges, date(2025, 4, 5), 3), charges)
+
+
+    def test_price_increase(self):
+        charges = detect_recurring(
+            [
+                self._transaction("Streaming", date(2025, 1, 1), "10.00"),
+                self._transaction("Streaming", date(2025, 1, 31), "12.00"),
+                self._transaction("Streaming", date(2025, 3, 2), "12.50"),
+            ]
+        )
+        charge = charges[0]
+        self.assertEqual(charge.last_amount, Decimal("12.50"))
+        self.assertEqual(charge.trailing_average, Decimal("11.00"))
+        self.assertEqual(price_increase(charge, Decimal("0.1")), Decimal("0.1364"))
+        self.assertIsNone(price_increase(charge, Decimal("0.2")))
+
+
+class TestRecurringChargeJob(unittest.TestCase):
+    def setUp(self):
+        months = {
+            "2025-01": [self._rent(date(2025, 1, 1))],
+            "2025-02": [self._rent(date(2025, 1, 31))],
+            "2025-03": [self._rent(date(2025, 3, 2))],
+        }
+        self.settings = {}
+        people = [{"Name": "A", "Email": "a@test.com", "Accounts": [1]}]
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_transactions",
+                side_effect=lambda month: months.get(month, []),
+            ),
+            patch.object(
+                controller.db_service,
+                "get_settings",
+                side_effect=lambda: dict(self.settings),
+            ),
+            patch.object(
+                controller.db_service,
+                "save_setting",
+                side_effect=self.settings.__setitem__,
+            ),
+            patch.object(controller.db_service, "get_all_people", return_value=people),
+            patch.object(controller.email_service, "send_email"),
+            patch("rmanalyzer.controller.datetime"),
+            patch.object(
+                controller.db_service,
+                "get_subscriptions",
+                side_effect=lambda: list(self.subscriptions.values()),
+            ),
+            patch.object(
+                controller.db_service,
+                "save_subscription",
+                side_effect=lambda s: self.subscriptions.__setitem__(s["merchant"], s),
+            ),
+            patch.object(
+                controller.db_service,
+                "get_accounts",
+                side_effect=lambda email: list(self.accounts),
+            ),
+            patch.object(controller.db_service, "record_job_run"),
+            patch.object(
+                controller.db_service,
+                "get_alerts",
+                side_effect=lambda: list(self.alerts.values()),
+            ),
+            patch.object(
+                controller.db_service,
+                "record_alert",
+                side_effect=lambda alert_id, a: self.alerts.__setitem__(
+                    alert_id, {**a, "id": alert_id, "status": "new"}
+                ),
+            ),
+            patch.object(
+                controller.db_service, "get_unconverted_transactions", return_value=[]
+            ),
+            patch.object(controller.db_service, "get_savings", return_value=None),
+        ]
+        self.subscriptions = {}
+        self.accounts = []
+        self.alerts = {}
+        mocks = start_patches(self, patchers)
+        self.mock_send, self.mock_datetime = mocks[4], mocks[5]
+        self.mock_record_run = mocks[9]
+        self.months = months
+
+    @staticmethod
+    def _rent(day, amount="1000.00"):
+        return Transaction(
+            day, "Rent", 1, Decimal(amount), Category.BILLS, IgnoredFrom.NOTHING
+        )
+
+    def test_alerts_once_per_missed_bill(self):
+        # Rent is due 2025-04-01; 3 grace days have passed
+        self.mock_datetime.now.return_value = datetime(2025, 4, 5)
+
+        controller.run_recurring_charge_job()
+
+        self.mock_send.assert_called_once()
+        self.assertEqual(self.mock_send.call_args[0][0], ["a@test.com"])
+        self.assertEqual(
+            json.loads(self.settings["MissingBillAlerts"]), {"RENT": "2025-04-01"}
+        )
+
+        self.mock_send.reset_mock()
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+
+    def test_no_alert_within_grace_days(self):
+        self.mock_datetime.now.return_value = datetime(2025, 4, 4)
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+        self.assertEqual(self.subscriptions["RENT"]["priceHistory"], [])
+
+    def test_alerts_once_per_price_increase(self):
+        self.months["2025-03"] = [self._rent(date(2025, 3, 2), "1200.00")]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 10)
+
+        controller.run_recurring_charge_job()
+
+        self.mock_send.assert_called_once()
+        self.assertEqual(self.mock_send.call_args[0][1], "Subscription prices went up")
+        history = self.subscriptions["RENT"]["priceHistory"]
+        self.assertEqual(
+            history,
+            [{"date": "2025-03-02", "from": 1000.0, "to": 1200.0, "change": 0.2}],
+        )
+
+        self.mock_send.reset_mock()
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+        self.assertEqual(len(self.subscriptions["RENT"]["priceHistory"]), 1)
+
+    def test_reminds_once_per_annual_fee(self):
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "institution": "Chase",
+                "mask": "0001",
+                "annualFee": 95.0,
+                "feeMonth": 4,
+            }
+        ]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 10)
+
+        controller.run_recurring_charge_job()
+
+        self.mock_send.assert_called_once()
+        self.assertEqual(
+            self.mock_send.call_args[0][1], "Card annual fees are coming up"
+        )
+        self.assertEqual(
+            json.loads(self.settings["AnnualFeeReminders"]),
+            {"a@test.com/acc-1": "2025-04-01"},
+        )
+
+        self.mock_send.reset_mock()
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+
+    def test_escalates_unpaid_payment_reminder(self):
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "institution": "Chase",
+                "mask": "0001",
+                "balance": 300.0,
+                "dueDay": 15,
+            }
+        ]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 11)
+
+        controller.run_recurring_charge_job()
+
+        self.mock_send.assert_called_once()
+        self.assertEqual(self.mock_send.call_args[0][1], "Card payments are due soon")
+        self.assertFalse(self.mock_send.call_args[1]["high_priority"])
+
+        self.mock_send.reset_mock()
+        self.mock_datetime.now.return_value = datetime(2025, 3, 12)
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+
+        self.mock_datetime.now.return_value = datetime(2025, 3, 14)
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_called_once()
+        self.assertTrue(self.mock_send.call_args[1]["high_priority"])
+        self.assertEqual(
+            json.loads(self.settings["PaymentReminders"]),
+            {
+                "a@test.com/acc-1": {
+                    "due": "2025-03-15",
+                    "stage": "escalated",
+                    "leads": [5],
+                }
+            },
+        )
+
+        self.mock_send.reset_mock()
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+
+    def test_reminds_at_each_lead_day(self):
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "institution": "Chase",
+                "mask": "0001",
+                "balance": 300.0,
+                "dueDay": 31,
+                "reminderLeadDays": [7, 3, 1],
+            }
+        ]
+        # Due on the last day of February
+        sends = {}
+        for day in range(20, 29):
+            self.mock_send.reset_mock()
+            self.mock_datetime.now.return_value = datetime(2025, 2, day)
+            controller.run_recurring_charge_job()
+            if self.mock_send.called:
+                sends[day] = self.mock_send.call_args[1]["high_priority"]
+
+        self.assertEqual(sends, {21: False, 25: False, 27: True})
+        self.assertEqual(
+            json.loads(self.settings["PaymentReminders"])["a@test.com/acc-1"],
+            {"due": "2025-02-28", "stage": "escalated", "leads": [7, 3, 1]},
+        )
+
+    def test_missed_lead_days_send_one_reminder(self):
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "institution": "Chase",
+                "mask": "0001",
+                "balance": 300.0,
+                "dueDay": 15,
+            }
+        ]
+        self.settings["PaymentReminderLeadDays"] = "[7, 3]"
+        self.mock_datetime.now.return_value = datetime(2025, 3, 13)
+
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_called_once()
+        self.assertEqual(
+            json.loads(self.settings["PaymentReminders"])["a@test.com/acc-1"]["leads"],
+            [7, 3],
+        )
+
+        self.mock_send.reset_mock()
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+
+    @patch.dict(os.environ, {"REMINDER_SIGNING_KEY": "k", "APP_URL": "https://app"})
+    def test_acknowledged_reminder_is_not_escalated(self):
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "institution": "Chase",
+                "mask": "0001",
+                "balance": 300.0,
+                "dueDay": 15,
+            }
+        ]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 11)
+        controller.run_recurring_charge_job()
+        body = self.mock_send.call_args[0][2]
+        link = re.search(
+            r"/confirm.html\?action=reminder&amp;id=([^&]+)&amp;sig=(\w+)", body
+        )
+
+        req = http_request("a@test.com", route_params={"id": link.group(1)})
+        req.get_json.return_value = {"sig": "forged"}
+        self.assertEqual(controller.handle_reminder_ack(req).status_code, 403)
+
+        req.get_json.return_value = {"sig": link.group(2)}
+        with patch.object(controller.db_service, "record_activity") as mock_activity:
+            resp = controller.handle_reminder_ack(req)
+        self.assertEqual(resp.status_code, 200)
+        mock_activity.assert_called_once()
+        self.assertEqual(
+            json.loads(self.settings["PaymentReminders"])["a@test.com/acc-1"]["stage"],
+            "acknowledged",
+        )
+
+        self.mock_send.reset_mock()
+        self.mock_datetime.now.return_value = datetime(2025, 3, 14)
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+
+        # Once the cycle has passed the link no longer applies
+        self.mock_datetime.now.return_value = datetime(2025, 3, 16)
+        controller.run_recurring_charge_job()
+        self.assertEqual(controller.handle_reminder_ack(req).status_code, 410)
+
+    def test_no_payment_reminder_once_paid(self):
+        self.accounts = [
+            {"accountId": "acc-1", "mask": "0001", "balance": 300.0, "dueDay": 15}
+        ]
+        self.months["2025-03"] = [
+            Transaction(
+                date(2025, 3, 5),
+                "PAYMENT",
+                1,
+                Decimal("-300.00"),
+                Category.OTHER,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 14)
+
+        controller.run_recurring_charge_job()
+
+        self.mock_send.assert_not_called()
+
+    def _paydays(self, account):
+        for month, day in (("2025-01", 15), ("2025-02", 14), ("2025-03", 14)):
+            self.months[month].append(
+                Transaction(
+                    date.fromisoformat(f"{month}-{day}"),
+                    "PAYROLL",
+                    account,
+                    Decimal("-2000.00"),
+                    Category.OTHER,
+                    IgnoredFrom.NOTHING,
+                )
+            )
+
+    @patch.object(controller.db_service, "get_savings")
+    def test_warns_once_when_plans_exceed_income(self, mock_savings):
+        self._paydays(1)
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "institution": "Chase",
+                "mask": "0009",
+                "balance": 3000.0,
+                "minimumPayment": 150.0,
+            }
+        ]
+        mock_savings.return_value = {
+            "startingBalance": 0.0,
+            "items": [
+                {"name": "Vacation", "cost": 1500.0},
+                {"name": "IRA", "cost": 500},
+            ],
+        }
+        self.mock_datetime.now.return_value = datetime(2025, 3, 20)
+
+        controller.run_recurring_charge_job()
+
+        self.mock_send.assert_called_once()
+        self.assertEqual(
+            self.mock_send.call_args[0][1], "Your plans this month exceed your income"
+        )
+        # Once by the savings rollover, which finds the month saved, once by the check
+        self.assertEqual(
+            mock_savings.call_args_list, [call("2025-03", "a@test.com")] * 2
+        )
+        self.assertEqual(
+            json.loads(self.settings["OverAllocationAlerts"]),
+            {"a@test.com": "2025-03"},
+        )
+
+        self.mock_send.reset_mock()
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+
+        # Back within income, the warning re-arms
+        mock_savings.return_value = {"startingBalance": 0.0, "items": []}
+        controller.run_recurring_charge_job()
+        self.assertEqual(json.loads(self.settings["OverAllocationAlerts"]), {})
+
+    @patch.object(controller.db_service, "get_savings")
+    def test_card_credits_are_not_income(self, mock_savings):
+        self._paydays(9)
+        self.accounts = [
+            {"accountId": "acc-1", "mask": "0009", "balance": 3000.0, "apr": 24.0}
+        ]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 20)
+
+        controller.run_recurring_charge_job()
+
+        # Only by the savings rollover, which finds the month saved
+        mock_savings.assert_called_once_with("2025-03", "a@test.com")
+        self.mock_send.assert_not_called()
+
+    def test_warns_once_per_promo_expiry(self):
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "institution": "Citi",
+                "mask": "4321",
+                "balance": 1200.0,
+                "apr": 24.0,
+                "promoApr": 0.0,
+                "promoExpiry": "2025-04-01",
+            }
+        ]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 10)
+
+        controller.run_recurring_charge_job()
+
+        self.mock_send.assert_called_once()
+        self.assertEqual(self.mock_send.call_args[0][1], "Promo APRs are ending")
+        self.assertEqual(
+            json.loads(self.settings["PromoExpiryWarnings"]),
+            {"a@test.com/acc-1": "2025-04-01"},
+        )
+
+        self.mock_send.reset_mock()
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+
+    def test_alerts_once_on_high_utilization(self):
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "institution": "Chase",
+                "mask": "0001",
+                "balance": 800.0,
+                "limit": 1000.0,
+            },
+            {
+                "accountId": "acc-2",
+                "institution": None,
+                "mask": "0002",
+                "balance": 0.0,
+                "limit": 1000.0,
+            },
+        ]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 10)
+
+        controller.run_recurring_charge_job()
+
+        self.mock_send.assert_called_once()
+        self.assertEqual(self.mock_send.call_args[0][1], "Credit utilization is high")
+        self.assertEqual(json.loads(self.settings["UtilizationAlerts"]), ["a@test.com"])
+
+        self.mock_send.reset_mock()
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+
+        # Paying down re-arms the alert
+        self.accounts[0]["balance"] = 100.0
+        controller.run_recurring_charge_job()
+        self.assertEqual(json.loads(self.settings["UtilizationAlerts"]), [])
+
+    def test_dismissed_alert_is_not_sent(self):
+        self.mock_datetime.now.return_value = datetime(2025, 4, 5)
+        controller.run_recurring_charge_job()
+
+        alert = self.alerts[alerts.alert_id("missing-bill", "RENT")]
+        self.assertEqual(alert["recipients"], ["a@test.com"])
+        self.assertEqual(alert["subject"], "Recurring bills are missing")
+
+        # Missed again next month, but the household dismissed it
+        alert["status"] = "dismissed"
+        self.mock_send.reset_mock()
+        self.mock_datetime.now.return_value = datetime(2025, 5, 5)
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+
+    def test_snoozed_alert_is_sent_once_snooze_ends(self):
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "institution": "Chase",
+                "mask": "0001",
+                "balance": 800.0,
+                "limit": 1000.0,
+            }
+        ]
+        self.alerts["u"] = {
+            "kind": "utilization",
+            "key": "a@test.com",
+            "status": "snoozed",
+            "snoozedUntil": "2025-03-12",
+        }
+        self.mock_datetime.now.return_value = datetime(2025, 3, 10)
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_not_called()
+        self.assertNotIn("UtilizationAlerts", self.settings)
+
+        self.mock_datetime.now.return_value = datetime(2025, 3, 12)
+        controller.run_recurring_charge_job()
+        self.mock_send.assert_called_once()
+        self.assertEqual(self.mock_send.call_args[0][1], "Credit utilization is high")
+
+    def test_records_nightly_run(self):
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "institution": "Chase",
+                "mask": "0001",
+                "annualFee": 95.0,
+                "feeMonth": 4,
+            }
+        ]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 10)
+
+        controller.run_recurring_charge_job()
+
+        self.mock_record_run.assert_called_once_with(
+            "nightly",
+            datetime(2025, 3, 10),
+            "succeeded",
+            {
+                "cardsEvaluated": 1,
+                "statementsClosed": 0,
+                "savingsRolledOver": 0,
+                "currenciesConverted": 0,
+                "remindersSent": 1,
+            },
+            [],
+        )
+
+    @patch.object(controller.db_service, "save_conversions")
+    def test_converts_pending_currencies(self, mock_save):
+        hotel = Transaction(
+            date(2025, 3, 2),
+            "Hotel",
+            1,
+            Decimal("100.00"),
+            Category.TRAVEL,
+            IgnoredFrom.NOTHING,
+            currency="EUR",
+        )
+        controller.db_service.get_unconverted_transactions.return_value = [
+            ("r1", hotel)
+        ]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 10)
+
+        with patch.object(
+            controller.exchange_rates,
+            "rates",
+            return_value={"EUR": Decimal("0.5")},
+        ):
+            controller.run_recurring_charge_job()
+
+        [(transaction_id, converted)] = mock_save.call_args[0][0]
+        self.assertEqual(transaction_id, "r1")
+        self.assertEqual(converted.amount, Decimal("200.00"))
+        self.assertEqual(converted.original_amount, Decimal("100.00"))
+        self.assertEqual(
+            self.mock_record_run.call_args[0][3]["currenciesConverted"], 1
+        )
+
+    @patch.object(controller.db_service, "save_savings")
+    @patch.object(controller.db_service, "get_savings")
+    def test_rolls_over_savings_for_the_new_month(self, mock_get, mock_save):
+        saved = {
+            "2025-03": {
+                "startingBalance": 1000.0,
+                "items": [
+                    {"name": "IRA", "cost": 500.0},
+                    {"name": "Couch", "cost": 300.0, "oneOff": True},
+                ],
+            }
+        }
+        mock_get.side_effect = lambda month, email: saved.get(month)
+        self.mock_datetime.now.return_value = datetime(2025, 4, 1, 2, 45)
+
+        controller.run_recurring_charge_job()
+
+        mock_save.assert_called_once_with(
+            "2025-04",
+            {"startingBalance": 200.0, "items": [{"name": "IRA", "cost": 500.0}]},
+            "a@test.com",
+        )
+        details = self.mock_record_run.call_args[0][3]
+        self.assertEqual(details["savingsRolledOver"], 1)
+
+        # A month that's already saved is left alone
+        mock_save.reset_mock()
+        saved["2025-04"] = {"startingBalance": 50.0, "items": []}
+        controller.run_recurring_charge_job()
+        mock_save.assert_not_called()
+
+    @patch.object(controller.db_service, "save_savings")
+    @patch.object(controller.db_service, "get_savings")
+    def test_rolls_over_months_missed_since_the_last_saved(self, mock_get, mock_save):
+        saved = {
+            "2025-02": {
+                "startingBalance": 1000.0,
+                "items": [{"name": "IRA", "cost": 100.0}],
+            }
+        }
+        mock_get.side_effect = lambda month, email: saved.get(month)
+        mock_save.side_effect = lambda month, s, email: saved.__setitem__(month, s)
+        # Not the first, since the run on the first was missed
+        self.mock_datetime.now.return_value = datetime(2025, 4, 3)
+
+        controller.run_recurring_charge_job()
+
+        self.assertEqual(
+            [c[0][0] for c in mock_save.call_args_list], ["2025-03", "2025-04"]
+        )
+        self.assertEqual(saved["2025-04"]["startingBalance"], 800.0)
+        details = self.mock_record_run.call_args[0][3]
+        self.assertEqual(details["savingsRolledOver"], 2)
+
+        mock_save.reset_mock()
+        controller.run_recurring_charge_job()
+        mock_save.assert_not_called()
+
+    @patch.object(controller.db_service, "save_savings")
+    @patch.object(controller.db_service, "get_savings", return_value=None)
+    def test_no_rollover_without_recent_savings(self, mock_get, mock_save):
+        self.mock_datetime.now.return_value = datetime(2025, 4, 1)
+
+        controller.run_recurring_charge_job()
+
+        mock_get.assert_any_call("2024-04", "a@test.com")
+        self.assertNotIn(call("2024-03", "a@test.com"), mock_get.call_args_list)
+        mock_save.assert_not_called()
+
+    @patch.object(controller.db_service, "close_statement", return_value=True)
+    def test_closes_statements_since_last(self, mock_close):
+        self.accounts = [
+            {
+                "accountId": "acc-1",
+                "statementCloseDay": 20,
+                "balance": 420.5,
+                "limit": 5000.0,
+                "lastStatementDate": "2025-02-20",
+            },
+            {"accountId": "acc-2", "statementCloseDay": 9, "balance": 10.0},
+            {"accountId": "acc-3", "balance": 10.0},
+        ]
+        self.mock_datetime.now.return_value = datetime(2025, 3, 22)
+
+        controller.run_recurring_charge_job()
+
+        mock_close.assert_called_once_with(
+            "a@test.com",
+            "acc-1",
+            {
+                "closeDate": "2025-03-20",
+                "cycleStart": "2025-02-21",
+                "balance": 420.5,
+                "limit": 5000.0,
+            },
+        )
+        details = self.mock_record_run.call_args[0][3]
+        self.assertEqual(details["statementsClosed"], 1)
+
+    @patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+    @patch.object(controller.db_service, "get_subscriptions")
+    def test_failed_check_alerts_admins_after_the_rest(self, mock_get):
+        mock_get.side_effect = Exception("table down")
+        self.mock_datetime.now.return_value = datetime(2025, 4, 5)
+
+        with self.assertRaises(RuntimeError):
+            controller.run_recurring_charge_job()
+
+        # The missing bill alert still went out before the admin alert
+        subjects = [c[0][1] for c in self.mock_send.call_args_list]
+        self.assertEqual(
+            subjects, ["Recurring bills are missing", "The nightly job failed"]
+        )
+        self.assertEqual(self.mock_send.call_args[0][0], ["admin@test.com"])
+        self.assertTrue(self.mock_send.call_args[1]["high_priority"])
+        _, _, status, details, errors = self.mock_record_run.call_args[0]
+        self.assertEqual(status, "failed")
+        self.assertEqual(details["remindersSent"], 1)
+        self.assertEqual(errors, ["price changes: table down"])
+
+    @patch.dict(os.environ, {"ADMIN_EMAILS": "admin@test.com"})
+    @patch.object(controller.db_service, "get_job_runs")
+    def test_alerts_admins_when_nightly_run_is_stale(self, mock_runs):
+        self.mock_datetime.fromisoformat = datetime.fromisoformat
+        mock_runs.return_value = [{"startedAt": "2025-03-09T02:45:00"}]
+
+        self.mock_datetime.now.return_value = datetime(2025, 3, 10, 6)
+        controller.run_nightly_job_check()
+        self.mock_send.assert_called_once()
+        self.assertEqual(self.mock_send.call_args[0][1], "The nightly job hasn't run")
+
+        self.mock_send.reset_mock()
+        mock_runs.return_value = [{"startedAt": "2025-03-10T02:45:00"}]
+        controller.run_nightly_job_check()
+        self.mock_send.assert_not_called()
+
+    @patch.object(controller.db_service, "get_job_runs", return_value=[])
+    def test_no_stale_alert_before_first_run(self, _):
+        controller.run_nightly_job_check()
+        self.mock_send.assert_not_called()
+
+    @patch.object(controller.db_service, "get_subscriptions")
+    def test_list_subscriptions(self, mock_get):
+        mock_get.return_value = [{"merchant": "RENT", "priceHistory": []}]
+        req = http_request("a@test.com")
+
+        resp = controller.handle_subscriptions(req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            json.loads(resp.get_body())["subscriptions"][0]["merchant"], "RENT"
+        )
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,64 @@
+"""
+Tests for data retention policies.
+"""
+
+import os
+import unittest
+from datetime import date, datetime
+from unittest.mock import patch
+
+from rmanalyzer.retention import RetentionPolicy
+
+
+class TestRetentionPolicy(unittest.TestCase):
+    """Test suite for RetentionPolicy."""
+
+    @patch.dict(os.environ, {}, clear=True)
+    def test_defaults(self):
+        """Test the default periods: finance data is kept, logs and uploads expire."""
+        policy = RetentionPolicy.from_env()
+        self.assertEqual(policy.uploads_days, 90)
+        self.assertIsNone(policy.transactions_days)
+        self.assertIsNone(policy.savings_days)
+        self.assertEqual(policy.activity_days, 365)
+        self.assertEqual(policy.jobs_days, 90)
+        self.assertEqual(policy.metrics_days, 31)
+        self.assertEqual(policy.backups_days, 30)
+        self.assertEqual(policy.rehearsals_days, 7)
+
+    @patch.dict(
+        os.environ,
+        {
+            "RETENTION_UPLOADS_DAYS": "0",
+            "RETENTION_TRANSACTIONS_DAYS": "365",
+            "RETENTION_SAVINGS_DAYS": "",
+        },
+    )
+    def test_from_env(self):
+        """Test that 0 or empty means keep forever."""
+        policy = RetentionPolicy.from_env()
+        self.assertIsNone(policy.uploads_days)
+        self.assertEqual(policy.transactions_days, 365)
+        self.assertIsNone(policy.savings_days)
+
+    @patch.dict(os.environ, {"RETENTION_UPLOADS_DAYS": "-1"})
+    def test_negative_rejected(self):
+        """Test that negative periods are rejected."""
+        with self.assertRaises(ValueError):
+            RetentionPolicy.from_env()
+
+    def test_cutoffs(self):
+        """Test cutoff dates relative to now."""
+        policy = RetentionPolicy(uploads_days=10, transactions_days=None)
+        cutoffs = policy.cutoffs(datetime(2025, 3, 15, 12, 0))
+        self.assertEqual(cutoffs["uploads"], date(2025, 3, 5))
+        self.assertIsNone(cutoffs["transactions"])
+        self.assertIsNone(cutoffs["savings"])
+        self.assertEqual(cutoffs["jobs"], date(2024, 12, 15))
+        self.assertEqual(cutoffs["metrics"], date(2025, 2, 12))
+        self.assertEqual(cutoffs["backups"], date(2025, 2, 13))
+        self.assertEqual(cutoffs["rehearsals"], date(2025, 3, 8))
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,190 @@
+"""
+Tests for the monthly review packet.
+"""
+
+import json
+import unittest
+from datetime import date, datetime
+from decimal import Decimal
+from unittest.mock import patch
+
+from fixtures import http_request
+from rmanalyzer.controller import controller
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.review import packet_lines, possible_duplicates, unusual_transactions
+
+
+def _charge(day, amount, name="STORE", account=1, category=Category.GROCERIES):
+    return Transaction(
+        day, name, account, Decimal(amount), category, IgnoredFrom.NOTHING
+    )
+
+
+class TestReview(unittest.TestCase):
+    """Test suite for finding transactions to review."""
+
+    def test_unusual_against_category_median(self):
+        history = [_charge(date(2025, 4, d), "50.00") for d in range(1, 6)]
+        month = [
+            _charge(date(2025, 5, 2), "60.00"),
+            _charge(date(2025, 5, 3), "150.00"),
+            _charge(date(2025, 5, 4), "400.00", category=Category.DINING),
+        ]
+
+        unusual = unusual_transactions(month, history)
+
+        self.assertEqual([u["amount"] for u in unusual], [150.0])
+        self.assertEqual(unusual[0]["typicalAmount"], 50.0)
+
+    def test_duplicates_same_merchant_day_and_amount(self):
+        month = [
+            _charge(date(2025, 5, 2), "12.00", "NETFLIX #123"),
+            _charge(date(2025, 5, 2), "12.00", "NETFLIX #456"),
+            _charge(date(2025, 5, 2), "12.00", "NETFLIX #123", account=2),
+            _charge(date(2025, 5, 9), "12.00", "NETFLIX #123"),
+        ]
+
+        (duplicate,) = possible_duplicates(month)
+
+        self.assertEqual(duplicate["count"], 2)
+        self.assertEqual(
+            (duplicate["date"], duplicate["accountNumber"]), ("2025-05-02", 1)
+        )
+
+    def test_packet_lines(self):
+        packet = {
+            "month": "2025-05",
+            "summary": {
+                "total": 100.0,
+                "people": [{"name": "A", "total": 100.0}],
+                "categories": [
+                    {"category": "Groceries", "total": 100.0},
+                    {"category": "Dining & Drinks", "total": 0.0},
+                ],
+            },
+            "budgets": [
+                {
+                    "category": "Groceries",
+                    "spent": 100.0,
+                    "limit": 80.0,
+                    "overBudget": True,
+                }
+            ],
+            "unusual": [],
+            "duplicates": [],
+            "unassigned": [
+                {
+                    "date": "2025-05-02",
+                    "name": "ATM",
+                    "accountNumber": 9,
+                    "amount": 20.0,
+                }
+            ],
+        }
+
+        text = "\n".join(packet_lines(packet))
+
+        self.assertIn("Total spend: 100.00", text)
+        self.assertNotIn("Dining", text)
+        self.assertIn("OVER", text)
+        self.assertIn("None stood out.", text)
+        self.assertIn("2025-05-02  ATM", text)
+
+
+class TestReviewPacketController(unittest.TestCase):
+    """Test suite for the review packet endpoint and monthly job."""
+
+    def setUp(self):
+        self.req = http_request("a@test.com", params={"month": "2025-05"})
+        months = {
+            "2025-05": [
+                _charge(date(2025, 5, 2), "30.00"),
+                _charge(date(2025, 5, 2), "30.00"),
+                _charge(date(2025, 5, 3), "8.00", "ATM", account=9),
+            ]
+        }
+        patches = [
+            patch.object(
+                controller.db_service,
+                "get_all_people",
+                return_value=[{"Name": "A", "Email": "a@test.com", "Accounts": [1]}],
+            ),
+            patch.object(
+                controller.db_service,
+                "get_transactions",
+                side_effect=lambda month: months.get(month, []),
+            ),
+            patch.object(
+                controller.db_service,
+                "apply_owner_overrides",
+                side_effect=lambda transactions: transactions,
+            ),
+            patch.object(
+                controller.db_service,
+                "get_spending_totals",
+                return_value=[
+                    {
+                        "Category": "Groceries",
+                        "AccountNumber": 1,
+                        "Owner": "",
+                        "Total": Decimal("60.00"),
+                        "Count": 2,
+                    }
+                ],
+            ),
+            patch.object(
+                controller.db_service,
+                "get_budgets",
+                return_value={Category.GROCERIES: Decimal("50.00")},
+            ),
+            patch.object(controller.db_service, "get_settings", return_value={}),
+            patch.object(controller.db_service, "record_job_run"),
+            patch.object(controller.db_service, "record_activity"),
+            patch.object(controller.email_service, "send_emails"),
+            patch("rmanalyzer.controller.datetime", wraps=datetime),
+        ]
+        mocks = [p.start() for p in patches]
+        self.addCleanup(patch.stopall)
+        self.mock_record_run, self.mock_send = mocks[6], mocks[8]
+        mocks[9].now.return_value = datetime(2025, 6, 2, 7)
+
+    def test_packet_as_json(self):
+        resp = controller.handle_review_packet(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        packet = json.loads(resp.get_body())
+        self.assertEqual(packet["summary"]["total"], 60.0)
+        self.assertTrue(packet["budgets"][0]["overBudget"])
+        self.assertEqual(packet["duplicates"][0]["count"], 2)
+        self.assertEqual([t["name"] for t in packet["unassigned"]], ["ATM"])
+
+    def test_packet_as_pdf(self):
+        self.req.params = {"format": "pdf"}
+
+        resp = controller.handle_review_packet(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(resp.mimetype, "application/pdf")
+        self.assertTrue(resp.get_body().startswith(b"%PDF"))
+        self.assertIn("review-2025-05.pdf", resp.headers["Content-Disposition"])
+
+    def test_rejects_bad_month(self):
+        self.req.params = {"month": "May"}
+        self.assertEqual(controller.handle_review_packet(self.req).status_code, 400)
+
+    def test_job_emails_last_month_with_pdf(self):
+        controller.run_review_packet_job()
+
+        messages, attachments = self.mock_send.call_args[0]
+        self.assertEqual(
+            messages[0][:2], (["a@test.com"], "Monthly review packet: 2025-05")
+        )
+        self.assertIn("Possible Duplicates", messages[0][2])
+        self.assertEqual(attachments[0].name, "review-2025-05.pdf")
+        run = self.mock_record_run.call_args[0]
+        self.assertEqual((run[0], run[2]), ("review-packet", "succeeded"))
+        self.assertEqual(run[3]["duplicates"], 1)
+
+
+if __name__ == "__main__":
+    unittest.main()
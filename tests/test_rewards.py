@@ -0,0 +1,64 @@
+"""
+Tests for card rewards estimation.
+"""
+
+import unittest
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.rewards import best_cards, card_rewards, reward_rate
+
+
+class TestRewards(unittest.TestCase):
+    def setUp(self):
+        self.flat = {"accountId": "flat", "mask": "1111", "rewardRate": 1.5}
+        self.dining = {
+            "accountId": "dining",
+            "mask": "2222",
+            "rewardRate": 1.0,
+            "categoryRewardRates": {"Dining & Drinks": 4.0},
+        }
+
+    @staticmethod
+    def _transaction(account, amount, category):
+        return Transaction(
+            date(2025, 1, 5),
+            "Store",
+            account,
+            Decimal(amount),
+            category,
+            IgnoredFrom.NOTHING,
+        )
+
+    def test_reward_rate_prefers_category_rate(self):
+        self.assertEqual(reward_rate(self.dining, Category.DINING), Decimal("4.0"))
+        self.assertEqual(reward_rate(self.dining, Category.TRAVEL), Decimal("1.0"))
+        self.assertEqual(reward_rate({"accountId": "x"}, Category.TRAVEL), 0)
+
+    def test_card_rewards(self):
+        transactions = [
+            self._transaction(2222, "100.00", Category.DINING),
+            self._transaction(2222, "50.00", Category.GROCERIES),
+            self._transaction(2222, "-30.00", Category.DINING),
+            self._transaction(1111, "500.00", Category.DINING),
+        ]
+
+        rewards = card_rewards(self.dining, transactions)
+
+        self.assertEqual(rewards["spend"], 150.0)
+        self.assertEqual(rewards["rewards"], 4.5)
+        self.assertEqual(rewards["byCategory"]["Dining & Drinks"]["rewards"], 4.0)
+
+    def test_best_cards(self):
+        best = best_cards(
+            [self.flat, self.dining], [Category.DINING, Category.GROCERIES]
+        )
+
+        self.assertEqual(best["Dining & Drinks"]["accountId"], "dining")
+        self.assertEqual(best["Groceries"]["accountId"], "flat")
+        self.assertEqual(best_cards([], [Category.PETS]), {"Pets": None})
+
+
+if __name__ == "__main__":
+    unittest.main()
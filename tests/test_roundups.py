@@ -0,0 +1,118 @@
+"""
+Tests for purchase round-ups.
+"""
+
+import json
+import unittest
+from datetime import date
+from decimal import Decimal
+from unittest.mock import patch
+
+from fixtures import http_request, start_patches
+from rmanalyzer.controller import controller
+from rmanalyzer.models import Category, IgnoredFrom, Person, Transaction
+from rmanalyzer.roundups import person_round_ups, round_up
+
+
+class TestRoundUps(unittest.TestCase):
+    def _transaction(self, amount, account=1, ignore=IgnoredFrom.NOTHING, owner=None):
+        return Transaction(
+            date(2025, 1, 1),
+            "X",
+            account,
+            Decimal(amount),
+            Category.OTHER,
+            ignore,
+            owner,
+        )
+
+    def test_round_up(self):
+        self.assertEqual(round_up(Decimal("4.25"), Decimal("1")), Decimal("0.75"))
+        self.assertEqual(round_up(Decimal("4.00"), Decimal("1")), Decimal("0.00"))
+        self.assertEqual(round_up(Decimal("12.10"), Decimal("5")), Decimal("2.90"))
+        self.assertEqual(round_up(Decimal("-3.50"), Decimal("1")), Decimal("0.00"))
+
+    def test_person_round_ups(self):
+        person = Person("A", "a@test.com", [1])
+        transactions = [
+            self._transaction("4.25"),
+            self._transaction("1.90"),
+            # Credits, other people's accounts and ignored transactions don't count
+            self._transaction("-2.50"),
+            self._transaction("3.50", account=2),
+            self._transaction("3.50", ignore=IgnoredFrom.EVERYTHING),
+            # Reassigned to A from another account
+            self._transaction("0.60", account=2, owner="A@test.com"),
+        ]
+
+        result = person_round_ups(person, transactions, Decimal("1"))
+
+        self.assertEqual(result["purchases"], 3)
+        self.assertEqual(result["total"], Decimal("1.25"))
+
+
+class TestRoundUpsController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", method="GET", params={"month": "2025-01"})
+        people = [{"Name": "A", "Email": "a@test.com", "Accounts": [1]}]
+        transactions = [
+            Transaction(
+                date(2025, 1, 2),
+                "Cafe",
+                1,
+                Decimal("3.40"),
+                Category.DINING,
+                IgnoredFrom.NOTHING,
+            )
+        ]
+        self.settings = {"RoundUpIncrements": '{"a@test.com": "1"}'}
+        patchers = [
+            patch.object(controller.db_service, "get_all_people", return_value=people),
+            patch.object(
+                controller.db_service, "get_transactions", return_value=transactions
+            ),
+            patch.object(
+                controller.db_service,
+                "get_settings",
+                side_effect=lambda: self.settings,
+            ),
+        ]
+        start_patches(self, patchers)
+
+    def test_suggest(self):
+        resp = controller.handle_round_ups(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        payload = json.loads(resp.get_body())
+        self.assertEqual(payload["total"], 0.6)
+        self.assertFalse(payload["recorded"])
+
+    @patch("rmanalyzer.controller.controller.db_service.save_savings")
+    @patch("rmanalyzer.controller.controller.db_service.get_savings")
+    def test_record_replaces_item(self, mock_get, mock_save):
+        self.req.method = "POST"
+        mock_get.return_value = {
+            "startingBalance": 100.0,
+            "items": [
+                {"name": "Rent", "cost": 50.0},
+                {"name": "Round-ups", "cost": 9.0},
+            ],
+        }
+
+        resp = controller.handle_round_ups(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        month, data, email = mock_save.call_args[0]
+        self.assertEqual((month, email), ("2025-01", "a@test.com"))
+        self.assertEqual(
+            data["items"],
+            [{"name": "Rent", "cost": 50.0}, {"name": "Round-ups", "cost": 0.6}],
+        )
+
+    def test_disabled(self):
+        self.settings = {}
+        resp = controller.handle_round_ups(self.req)
+        self.assertEqual(resp.status_code, 409)
+
+if __name__ == "__main__":
+    unittest.main()
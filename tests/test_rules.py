@@ -0,0 +1,353 @@
+"""
+Tests for category rules.
+"""
+
+import json
+import unittest
+from datetime import date
+from decimal import Decimal
+from unittest.mock import MagicMock, patch
+
+from fixtures import http_request, start_patches
+from rmanalyzer.controller import controller
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.rules import Rule, apply_rules, has_nested_quantifier
+
+
+class TestRules(unittest.TestCase):
+    def _transaction(self, name, amount="50.00", account=1):
+        return Transaction(
+            date(2025, 1, 5),
+            name,
+            account,
+            Decimal(amount),
+            Category.OTHER,
+            IgnoredFrom.NOTHING,
+        )
+
+    def test_conditions_must_all_match(self):
+        rule = Rule(
+            "r1",
+            Category.GROCERIES,
+            contains="costco",
+            min_amount=Decimal("20"),
+            max_amount=Decimal("100"),
+            account_number=1,
+        )
+
+        self.assertTrue(rule.matches(self._transaction("COSTCO WHSE #123")))
+        self.assertFalse(rule.matches(self._transaction("COSTCO GAS", "10.00")))
+        self.assertFalse(rule.matches(self._transaction("COSTCO", "150.00")))
+        self.assertFalse(rule.matches(self._transaction("COSTCO", account=2)))
+        self.assertFalse(rule.matches(self._transaction("SAFEWAY")))
+
+    def test_filter_expression(self):
+        rule = Rule(
+            "r1",
+            Category.GROCERIES,
+            filter_expression="merchant~costco and not amount<20",
+        )
+
+        self.assertTrue(rule.matches(self._transaction("COSTCO WHSE #123")))
+        self.assertFalse(rule.matches(self._transaction("COSTCO WHSE", "10.00")))
+        self.assertFalse(rule.matches(self._transaction("SAFEWAY")))
+        entity = rule.to_entity("default_RULES")
+        self.assertEqual(Rule.from_entity(entity), rule)
+
+    def test_broken_filter_matches_nothing(self):
+        # Saved before filters were capped in depth, so it no longer parses
+        nested = "(" * 40 + "amount>1" + ")" * 40
+        broken = Rule("r1", Category.BILLS, filter_expression=nested)
+        working = Rule("r2", Category.GROCERIES, contains="costco", priority=1)
+
+        with self.assertLogs(level="WARNING") as logs:
+            (t,) = apply_rules([broken, working], [self._transaction("COSTCO")])
+
+        self.assertEqual(t.category, Category.GROCERIES)
+        self.assertIn("Skipping rule r1", logs.output[0])
+        self.assertIn("levels deep", broken.to_json()["filterError"])
+        self.assertIsNone(working.to_json()["filterError"])
+
+    def test_pattern_is_case_insensitive(self):
+        rule = Rule("r1", Category.SUBSCRIPTIONS, pattern=r"^netflix\b")
+        self.assertTrue(rule.matches(self._transaction("NETFLIX.COM")))
+        self.assertFalse(rule.matches(self._transaction("PAY NETFLIX")))
+
+    def test_nested_quantifiers(self):
+        for pattern in (r"(a+)+$", r"^(\w*,)*x", r"((ab)*c)+", r"(x|y+){2,}"):
+            self.assertTrue(has_nested_quantifier(pattern), pattern)
+        for pattern in (
+            r"^netflix\b",
+            r"(amzn|amazon)\s+mktp",
+            r"(\d+)-\d+",
+            r"[(+]+",
+            r"\(a+\)+",
+            r"(ab){2}",
+        ):
+            self.assertFalse(has_nested_quantifier(pattern), pattern)
+
+    def test_first_rule_by_priority_wins(self):
+        rules = [
+            Rule("b", Category.DINING, contains="market", priority=1),
+            Rule("a", Category.GROCERIES, contains="market", priority=0),
+        ]
+        transactions = [self._transaction("Corner Market"), self._transaction("Gym")]
+
+        result = apply_rules(rules, transactions)
+
+        self.assertEqual(result[0].category, Category.GROCERIES)
+        self.assertEqual(result[1].category, Category.OTHER)
+        self.assertEqual(result[0].name, "Corner Market")
+
+    def test_entity_round_trip(self):
+        rule = Rule(
+            "r1",
+            Category.BILLS,
+            contains="power",
+            max_amount=Decimal("200.00"),
+            priority=2,
+        )
+
+        entity = rule.to_entity("default_RULES")
+
+        self.assertNotIn("Pattern", entity)
+        self.assertEqual(Rule.from_entity(entity), rule)
+
+    def test_from_json(self):
+        rule = Rule.from_json(
+            "r1",
+            {"category": "Groceries", "minAmount": "5.5", "accountNumber": "12"},
+        )
+        self.assertEqual(rule.min_amount, Decimal("5.5"))
+        self.assertEqual(rule.account_number, 12)
+        self.assertEqual(rule.to_json()["minAmount"], 5.5)
+
+
+class TestRulesController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", method="POST", route_params={"id": "r1"})
+
+    @patch.object(controller.db_service, "save_rule")
+    def test_create(self, mock_save):
+        self.req.get_json = MagicMock(
+            return_value={"category": "Groceries", "contains": "costco"}
+        )
+
+        resp = controller.handle_rules(self.req)
+
+        self.assertEqual(resp.status_code, 201)
+        rule = mock_save.call_args[0][0]
+        self.assertEqual(rule.category, Category.GROCERIES)
+        self.assertEqual(rule.contains, "costco")
+        self.assertEqual(json.loads(resp.get_body())["id"], rule.rule_id)
+
+    def test_create_requires_a_condition(self):
+        self.req.get_json = MagicMock(return_value={"category": "Groceries"})
+        resp = controller.handle_rules(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    @patch.object(controller.db_service, "save_rule")
+    def test_create_with_filter(self, mock_save):
+        self.req.get_json = MagicMock(
+            return_value={"category": "Groceries", "filter": "merchant~costco"}
+        )
+
+        resp = controller.handle_rules(self.req)
+
+        self.assertEqual(resp.status_code, 201)
+        self.assertEqual(mock_save.call_args[0][0].filter_expression, "merchant~costco")
+        self.assertEqual(json.loads(resp.get_body())["filter"], "merchant~costco")
+
+    def test_create_rejects_invalid_filter(self):
+        self.req.get_json = MagicMock(
+            return_value={"category": "Groceries", "filter": "merchant>costco"}
+        )
+        resp = controller.handle_rules(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(json.loads(resp.get_body())["fields"][0]["field"], "filter")
+
+    def test_create_rejects_invalid_pattern(self):
+        self.req.get_json = MagicMock(
+            return_value={"category": "Groceries", "pattern": "("}
+        )
+        resp = controller.handle_rules(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(
+            json.loads(resp.get_body())["fields"][0]["field"], "pattern"
+        )
+
+    def test_create_rejects_nested_quantifiers(self):
+        self.req.get_json = MagicMock(
+            return_value={"category": "Groceries", "pattern": "^(a+)+$"}
+        )
+        resp = controller.handle_rules(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.assertEqual(
+            json.loads(resp.get_body())["fields"][0]["message"],
+            "must not repeat a group that repeats",
+        )
+
+    @patch.object(controller.db_service, "get_rules")
+    def test_list(self, mock_get):
+        self.req.method = "GET"
+        mock_get.return_value = [Rule("r1", Category.BILLS, contains="power")]
+
+        resp = controller.handle_rules(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        rules = json.loads(resp.get_body())["rules"]
+        self.assertEqual(rules[0]["category"], "Bills & Utilities")
+
+    @patch.object(controller.db_service, "save_rule")
+    @patch.object(controller.db_service, "get_rules")
+    def test_update(self, mock_get, mock_save):
+        self.req.method = "PUT"
+        mock_get.return_value = [Rule("r1", Category.BILLS, contains="power")]
+        self.req.get_json = MagicMock(
+            return_value={"category": "Bills & Utilities", "maxAmount": 200}
+        )
+
+        resp = controller.handle_rule(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        rule = mock_save.call_args[0][0]
+        self.assertEqual(rule.rule_id, "r1")
+        self.assertIsNone(rule.contains)
+
+    @patch.object(controller.db_service, "get_rules", return_value=[])
+    def test_update_unknown_rule(self, _):
+        self.req.method = "PUT"
+        self.req.get_json = MagicMock(
+            return_value={"category": "Groceries", "contains": "costco"}
+        )
+        resp = controller.handle_rule(self.req)
+        self.assertEqual(resp.status_code, 404)
+
+    @patch.object(controller.db_service, "delete_rule", return_value=True)
+    def test_delete(self, mock_delete):
+        self.req.method = "DELETE"
+        resp = controller.handle_rule(self.req)
+        self.assertEqual(resp.status_code, 204)
+        mock_delete.assert_called_once_with("r1")
+
+
+class TestRulesApplyController(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", method="POST")
+        keyed = [
+            ("r1", self._transaction("COSTCO WHSE", Category.OTHER)),
+            ("r2", self._transaction("COSTCO GAS", Category.GROCERIES)),
+            ("r3", self._transaction("Cafe", Category.DINING)),
+        ]
+        patchers = [
+            patch.object(
+                controller.db_service,
+                "get_rules",
+                return_value=[Rule("r", Category.GROCERIES, contains="costco")],
+            ),
+            patch.object(
+                controller.db_service,
+                "get_keyed_transactions",
+                side_effect=lambda month: keyed if month == "2025-01" else [],
+            ),
+            patch.object(
+                controller.db_service,
+                "update_transactions",
+                side_effect=lambda month, changes: len(changes),
+            ),
+            patch.object(
+                controller.db_service, "get_manual_category_ids", return_value=set()
+            ),
+        ]
+        mocks = start_patches(self, patchers)
+        self.mock_update, self.mock_manual = mocks[2], mocks[3]
+
+    @staticmethod
+    def _transaction(name, category):
+        return Transaction(
+            date(2025, 1, 5), name, 1, Decimal("50.00"), category, IgnoredFrom.NOTHING
+        )
+
+    def test_apply_updates_changed_rows(self):
+        self.req.get_json = MagicMock(return_value={"months": ["2025-02", "2025-01"]})
+
+        resp = controller.handle_rules_apply(self.req)
+
+        self.assertEqual(resp.status_code, 200)
+        self.assertEqual(
+            json.loads(resp.get_body()),
+            {
+                "months": ["2025-01", "2025-02"],
+                "scanned": 3,
+                "updated": 1,
+                "locked": 0,
+                "failed": 0,
+            },
+        )
+        self.mock_update.assert_any_call(
+            "2025-01", {"r1": {"Category": "Groceries", "CategorySource": ""}}
+        )
+
+    def test_apply_keeps_manual_categories_unless_override(self):
+        self.mock_manual.return_value = {"r1"}
+        self.req.get_json = MagicMock(return_value={"months": ["2025-01"]})
+
+        resp = controller.handle_rules_apply(self.req)
+
+        body = json.loads(resp.get_body())
+        self.assertEqual((body["updated"], body["locked"]), (0, 1))
+        self.mock_update.assert_called_once_with("2025-01", {})
+
+        self.mock_update.reset_mock()
+        self.req.get_json = MagicMock(
+            return_value={"months": ["2025-01"], "override": "true"}
+        )
+
+        resp = controller.handle_rules_apply(self.req)
+
+        body = json.loads(resp.get_body())
+        self.assertEqual((body["updated"], body["locked"]), (1, 0))
+
+    def test_apply_rejects_invalid_override(self):
+        self.req.get_json = MagicMock(
+            return_value={"months": ["2025-01"], "override": "yes"}
+        )
+        resp = controller.handle_rules_apply(self.req)
+        self.assertEqual(resp.status_code, 400)
+        self.mock_update.assert_not_called()
+
+    @patch.object(controller.queue_service, "enqueue_message")
+    def test_apply_async_queues_backfill(self, mock_enqueue):
+        self.req.get_json = MagicMock(
+            return_value={"months": ["2025-01"], "async": True, "override": False}
+        )
+
+        resp = controller.handle_rules_apply(self.req)
+
+        self.assertEqual(resp.status_code, 202)
+        self.mock_update.assert_not_called()
+        message = mock_enqueue.call_args[0][0]
+        self.assertEqual(
+            message, {"task": "apply_rules", "months": ["2025-01"], "override": False}
+        )
+
+    def test_apply_rejects_invalid_month(self):
+        self.req.get_json = MagicMock(return_value={"months": ["2025-13"]})
+        resp = controller.handle_rules_apply(self.req)
+        self.assertEqual(resp.status_code, 400)
+
+    @patch.object(controller.queue_service, "discard_message")
+    @patch.object(controller.queue_service, "load_message")
+    def test_queued_task_is_processed(self, mock_load, _):
+        mock_load.return_value = {"task": "apply_rules", "months": ["2025-01"]}
+        msg = MagicMock()
+        msg.get_body.return_value = b"{}"
+
+        controller.process_queue_item(msg)
+
+        self.mock_update.assert_called_once_with(
+            "2025-01", {"r1": {"Category": "Groceries", "CategorySource": ""}}
+        )
+
+if __name__ == "__main__":
+    unittest.main()
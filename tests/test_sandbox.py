@@ -0,0 +1,47 @@
+"""
+Tests for the sandbox household's demo data and request context.
+"""
+
+import unittest
+from datetime import date
+
+from rmanalyzer import sandbox
+
+
+class TestSandbox(unittest.TestCase):
+    def setUp(self):
+        self.member = {"Name": "Alex", "Email": "alex@test.com"}
+        self.today = date(2024, 5, 10)
+
+    def test_demo_household_is_deterministic(self):
+        first = sandbox.demo_household(self.member, self.today)
+        second = sandbox.demo_household(self.member, self.today)
+        self.assertEqual(first["transactions"], second["transactions"])
+
+    def test_demo_household_months(self):
+        months = sandbox.demo_household(self.member, self.today)["transactions"]
+
+        self.assertEqual(len(months), sandbox.DEMO_MONTHS + 1)
+        self.assertIn("2023-11", months)
+        self.assertTrue(
+            all(t.date <= self.today for t in months["2024-05"]),
+        )
+        self.assertTrue(months["2024-04"])
+
+    def test_demo_household_people(self):
+        people = sandbox.demo_household(self.member, self.today)["people"]
+
+        self.assertEqual(people[0]["Email"], "alex@test.com")
+        self.assertEqual(people[1]["Name"], sandbox.DEMO_PARTNER["Name"])
+        accounts = [a for p in people for a in p["Accounts"]]
+        self.assertEqual(len(accounts), len(set(accounts)))
+
+    def test_use_sets_household_for_the_block(self):
+        self.assertFalse(sandbox.active())
+        with sandbox.use(sandbox.SANDBOX):
+            self.assertTrue(sandbox.active())
+        self.assertFalse(sandbox.active())
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,37 @@
+"""
+Tests for copying savings months forward.
+"""
+
+import unittest
+from decimal import Decimal
+
+from rmanalyzer.savings import copy_forward, ending_balance
+
+
+class TestSavings(unittest.TestCase):
+    def setUp(self):
+        self.month = {
+            "startingBalance": 1000.0,
+            "items": [
+                {"name": "Rent", "cost": 600.0},
+                {"name": "Couch", "cost": 250.5, "oneOff": True},
+            ],
+        }
+
+    def test_ending_balance(self):
+        self.assertEqual(ending_balance(self.month), Decimal("149.50"))
+        self.assertEqual(ending_balance({}), Decimal("0.00"))
+
+    def test_copy_forward_keeps_one_offs_unless_excluded(self):
+        copied = copy_forward(self.month, exclude_one_off=False)
+        self.assertEqual(copied["startingBalance"], 149.5)
+        self.assertEqual(len(copied["items"]), 2)
+
+        copied = copy_forward(self.month, exclude_one_off=True)
+        self.assertEqual(copied["items"], [{"name": "Rent", "cost": 600.0}])
+        # The source month is untouched
+        self.assertEqual(len(self.month["items"]), 2)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,178 @@
+"""
+Tests for free-text transaction search.
+"""
+
+import json
+import unittest
+from datetime import date, datetime
+from decimal import Decimal
+from unittest.mock import MagicMock, patch
+
+from fixtures import http_request, start_patches
+from rmanalyzer.controller import controller
+from rmanalyzer.models import Category, IgnoredFrom, Transaction
+from rmanalyzer.search import SearchIndex, matches, terms, within
+
+
+class TestSearch(unittest.TestCase):
+    def setUp(self):
+        self.transaction = Transaction(
+            date(2025, 3, 2),
+            "COSTCO WHSE #123",
+            1,
+            Decimal("150.00"),
+            Category.GROCERIES,
+            IgnoredFrom.NOTHING,
+        )
+
+    def test_matches_every_word(self):
+        self.assertTrue(matches(terms("costco  whse"), "COSTCO WHSE #123"))
+        self.assertTrue(matches(terms("Cost"), "COSTCO WHSE #123"))
+        self.assertFalse(matches(terms("costco gas"), "COSTCO WHSE #123"))
+
+    def test_within(self):
+        t = self.transaction
+        self.assertTrue(within(t))
+        self.assertTrue(within(t, Decimal("150"), Decimal("150")))
+        self.assertFalse(within(t, min_amount=Decimal("150.01")))
+        self.assertTrue(within(t, start=date(2025, 3, 2), end=date(2025, 3, 2)))
+        self.assertFalse(within(t, end=date(2025, 3, 1)))
+
+    @patch("rmanalyzer.search.time")
+    def test_index_keeps_months_for_ttl(self, mock_time):
+        mock_time.monotonic.return_value = 100.0
+        scan = MagicMock(return_value=[("r1", self.transaction)])
+        index = SearchIndex(60)
+
+        index.month("transactions", "2025-03", scan)
+        index.month("transactions", "2025-03", scan)
+        index.month("sandboxtransactions", "2025-03", scan)
+        self.assertEqual(scan.call_count, 2)
+
+        mock_time.monotonic.return_value = 160.0
+        self.assertEqual(
+            index.month("transactions", "2025-03", scan), [("r1", self.transaction)]
+        )
+        self.assertEqual(scan.call_count, 3)
+
+        index.forget("transactions", "2025-03")
+        index.month("transactions", "2025-03", scan)
+        self.assertEqual(scan.call_count, 4)
+
+        # Forgetting every month of a table leaves other tables' months
+        index.month("transactions", "2025-04", scan)
+        index.month("sandboxtransactions", "2025-03", scan)
+        index.forget("transactions")
+        index.month("transactions", "2025-03", scan)
+        index.month("transactions", "2025-04", scan)
+        index.month("sandboxtransactions", "2025-03", scan)
+        self.assertEqual(scan.call_count, 8)
+
+    def test_index_off_always_scans(self):
+        scan = MagicMock(return_value=[])
+        index = SearchIndex(0)
+        index.month("transactions", "2025-03", scan)
+        index.month("transactions", "2025-03", scan)
+        self.assertFalse(index.enabled)
+        self.assertEqual(scan.call_count, 2)
+
+
+class TestTransactionsSearch(unittest.TestCase):
+    def setUp(self):
+        self.req = http_request("a@test.com", params={"q": "costco", "months": "3"})
+        self.months = {
+            month: [
+                (
+                    f"{month}-{name}",
+                    Transaction(
+                        date.fromisoformat(f"{month}-{day:02d}"),
+                        name,
+                        1,
+                        Decimal(amount),
+                        Category.GROCERIES,
+                        IgnoredFrom.NOTHING,
+                    ),
+                )
+                for day, name, amount in [
+                    (3, "COSTCO WHSE", "120.00"),
+                    (9, "Costco Gas", "40.00"),
+                    (12, "CAFE", "8.00"),
+                ]
+            ]
+            for month in ("2025-01", "2025-02", "2025-03")
+        }
+        patchers = [
+            patch("rmanalyzer.controller.datetime"),
+            patch.object(
+                controller.db_service,
+                "search_transactions",
+                side_effect=lambda month, *bounds: self.months.get(month, []),
+            ),
+            patch.object(
+                controller.db_service,
+                "get_keyed_transactions",
+                side_effect=lambda month: self.months.get(month, []),
+            ),
+        ]
+        mocks = start_patches(self, patchers)
+        mocks[0].now.return_value = datetime(2025, 3, 20)
+        self.mock_search, self.mock_keyed = mocks[1], mocks[2]
+
+    def _search(self, **params):
+        self.req.params = {**self.req.params, **params}
+        resp = controller.handle_transactions_search(self.req)
+        return resp.status_code, json.loads(resp.get_body())
+
+    def test_search_window(self):
+        status, payload = self._search(q="costco whse")
+
+        self.assertEqual(status, 200)
+        self.assertEqual(payload["months"], ["2025-01", "2025-02", "2025-03"])
+        self.assertEqual(
+            [t["id"] for t in payload["transactions"]],
+            ["2025-03-COSTCO WHSE", "2025-02-COSTCO WHSE", "2025-01-COSTCO WHSE"],
+        )
+        self.assertFalse(payload["truncated"])
+
+    def test_bounds_narrow_the_scan(self):
+        status, payload = self._search(
+            minAmount="50", **{"from": "2025-02-05", "to": "2025-03-31"}
+        )
+
+        self.assertEqual(status, 200)
+        # January is outside the dates, so it isn't scanned
+        self.assertEqual(payload["months"], ["2025-02", "2025-03"])
+        self.assertEqual(
+            self.mock_search.call_args[0],
+            ("2025-03", Decimal("50"), None, date(2025, 2, 5), date(2025, 3, 31)),
+        )
+
+    def test_limit(self):
+        status, payload = self._search(limit="2")
+        self.assertEqual(status, 200)
+        self.assertEqual(payload["count"], 6)
+        self.assertEqual(len(payload["transactions"]), 2)
+        self.assertTrue(payload["truncated"])
+
+    def test_rejects_bad_params(self):
+        for params in [
+            {"q": "  "},
+            {"months": "0"},
+            {"from": "2025-03-02", "to": "2025-03-01"},
+            {"limit": "1000"},
+        ]:
+            with self.subTest(params=params):
+                self.assertEqual(self._search(**params)[0], 400)
+
+    def test_index_keeps_months_warm(self):
+        with patch.object(controller, "search_index", SearchIndex(300)):
+            self._search()
+            status, payload = self._search(minAmount="100")
+
+        self.assertEqual(status, 200)
+        self.assertEqual(payload["count"], 3)
+        self.mock_search.assert_not_called()
+        self.assertEqual(self.mock_keyed.call_count, 3)
+
+if __name__ == "__main__":
+    unittest.main()
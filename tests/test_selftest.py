@@ -0,0 +1,54 @@
+"""
+Tests for the startup self-test.
+"""
+
+import io
+import unittest
+from contextlib import redirect_stdout
+from unittest.mock import patch
+
+from rmanalyzer import selftest
+
+
+def _fail():
+    raise ConnectionError("connection refused")
+
+
+class TestSelfTest(unittest.TestCase):
+    def test_run_carries_on_past_failures(self):
+        results = selftest.run(
+            [
+                ("table", _fail, "TABLE_SERVICE_URL"),
+                ("email", lambda: None, "the email templates"),
+            ]
+        )
+
+        self.assertEqual(
+            results,
+            [
+                {
+                    "check": "table",
+                    "ok": False,
+                    "error": "ConnectionError: connection refused",
+                    "hint": "Check TABLE_SERVICE_URL.",
+                },
+                {"check": "email", "ok": True},
+            ],
+        )
+
+    def test_email_renders_without_sending(self):
+        with patch("rmanalyzer.services.EmailService") as mock_email:
+            # pylint: disable=protected-access
+            selftest._check_email()
+        mock_email.assert_not_called()
+
+    def test_main_exit_code(self):
+        out = io.StringIO()
+        with patch.object(selftest, "CHECKS", [("queue", _fail, "QUEUE_SERVICE_URL")]):
+            with redirect_stdout(out):
+                self.assertEqual(selftest.main(), 1)
+        self.assertIn("FAIL  queue: ConnectionError", out.getvalue())
+
+        with patch.object(selftest, "CHECKS", [("email", lambda: None, "")]):
+            with redirect_stdout(io.StringIO()):
+                self.assertEqual(selftest.main(), 0)
@@ -0,0 +1,92 @@
+"""
+Tests for metric counts shared by every worker instance.
+"""
+
+import unittest
+from datetime import datetime
+from unittest.mock import MagicMock, patch
+
+from rmanalyzer.services import shared_metrics
+
+
+class TestSharedMetrics(unittest.TestCase):
+    """Test suite for shared_metrics."""
+
+    def setUp(self):
+        # pylint: disable=protected-access
+        shared_metrics._COUNTS.clear()
+        shared_metrics._DIRTY.clear()
+        self.saver = MagicMock()
+        shared_metrics.configure(self.saver)
+        self.addCleanup(shared_metrics.configure, None)
+
+    def test_bucket(self):
+        """Test that moments fall in the bucket starting at the last 5 minutes."""
+        self.assertEqual(
+            shared_metrics.bucket(datetime(2025, 3, 2, 9, 14, 59)), "2025-03-02T09:10"
+        )
+        self.assertEqual(
+            shared_metrics.window(15, datetime(2025, 3, 2, 9, 15, 30)),
+            ("2025-03-02T09:00", "2025-03-02T09:15"),
+        )
+
+    def test_flush_saves_this_workers_counts(self):
+        """Test that a forced flush saves the buckets counted in since the last."""
+        shared_metrics.add("http", requests=2)
+        shared_metrics.add("http", requests=1, serverErrors=1)
+
+        shared_metrics.flush(force=True)
+
+        bucket, worker, counts = self.saver.call_args[0]
+        self.assertEqual(bucket, shared_metrics.bucket(datetime.now()))
+        self.assertEqual(worker, shared_metrics.WORKER_ID)
+        self.assertEqual(counts, {"http": {"requests": 3, "serverErrors": 1}})
+
+        self.saver.reset_mock()
+        shared_metrics.flush(force=True)
+        self.saver.assert_not_called()
+
+    def test_flush_waits_between_saves(self):
+        """Test that an unforced flush saves at most every FLUSH_SECONDS."""
+        shared_metrics.add("http", requests=1)
+        shared_metrics.flush(force=True)
+        shared_metrics.add("http", requests=1)
+
+        shared_metrics.flush()
+
+        self.assertEqual(self.saver.call_count, 1)
+
+    def test_failed_save_is_tried_again(self):
+        """Test that counts a save failed for are kept for the next flush."""
+        self.saver.side_effect = [OSError("down"), None]
+        shared_metrics.add("http", requests=1)
+
+        shared_metrics.flush(force=True)
+        shared_metrics.flush(force=True)
+
+        self.assertEqual(self.saver.call_count, 2)
+        self.assertEqual(self.saver.call_args[0][2], {"http": {"requests": 1}})
+
+    def test_ended_buckets_dropped_once_saved(self):
+        """Test that a bucket that has ended is no longer held once saved."""
+        with patch("rmanalyzer.services.shared_metrics.datetime") as mock_datetime:
+            mock_datetime.now.return_value = datetime(2025, 3, 2, 9, 0)
+            shared_metrics.add("http", requests=1)
+
+        shared_metrics.flush(force=True)
+
+        self.assertEqual(self.saver.call_args[0][0], "2025-03-02T09:00")
+        counted = shared_metrics._COUNTS  # pylint: disable=protected-access
+        self.assertNotIn("2025-03-02T09:00", counted)
+
+    def test_total_adds_up_workers(self):
+        """Test that saved counts are summed per group."""
+        totals = shared_metrics.total(
+            [{"http": {"requests": 2}}, {"http": {"requests": 3, "serverErrors": 1}}]
+        )
+        self.assertEqual(totals["http"]["requests"], 5)
+        self.assertEqual(totals["http"]["serverErrors"], 1)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,72 @@
+"""
+Tests for statement format detection and parsing.
+"""
+
+import unittest
+from decimal import Decimal
+
+from rmanalyzer.statement import STATEMENT_EXTENSIONS, detect_format, get_parser, parse
+
+CSV = b"Date,Name,Account Number,Amount,Category,Ignored From\n2025-01-15,A,1,5,,\n"
+MINT = (
+    b"Date,Description,Original Description,Amount,Transaction Type,Category,"
+    b"Account Name,Labels,Notes\n1/15/2025,A,A,5.00,debit,,Card 1234,,\n"
+)
+QIF = b"!Type:CCard\nD01/15/2025\nT-5.00\nPA\n^\n"
+OFX = b"OFXHEADER:100\nDATA:OFXSGML\n\n<OFX>\n</OFX>\n"
+
+
+class TestStatement(unittest.TestCase):
+    """Test suite for statement formats."""
+
+    def test_unambiguous_extension_decides(self):
+        """Test that an extension only one format uses settles the format."""
+        self.assertEqual(detect_format("a.QFX", CSV), "ofx")
+        self.assertEqual(detect_format("a.qif", CSV), "qif")
+        self.assertEqual(detect_format("a.xlsx", CSV), "xlsx")
+
+    def test_csv_content_decides(self):
+        """Test that a .csv is a Mint export or a plain CSV by its header."""
+        self.assertEqual(detect_format("transactions.csv", MINT), "mint")
+        self.assertEqual(detect_format("statement.csv", CSV), "csv")
+
+    def test_unknown_extension_is_sniffed(self):
+        """Test that files without a known extension are recognized by content."""
+        self.assertEqual(detect_format("export.txt", QIF), "qif")
+        self.assertEqual(detect_format("download", OFX), "ofx")
+        self.assertEqual(detect_format("book", b"PK\x03\x04rest"), "xlsx")
+        self.assertEqual(detect_format("transactions", MINT), "mint")
+        self.assertEqual(detect_format("statement", CSV), "csv")
+
+    def test_parse_by_format(self):
+        """Test that content is parsed with its format's parser."""
+        transactions, errors = parse("mint", MINT, "transactions.csv")
+        self.assertEqual(errors, [])
+        self.assertEqual(transactions[0].account_number, 1234)
+
+        transactions, errors = parse("csv", CSV, "statement.csv")
+        self.assertEqual(errors, [])
+        self.assertEqual(transactions[0].amount, Decimal("5"))
+
+    def test_qif_account_from_file_name(self):
+        """Test that a QIF without accounts takes its number from the file name."""
+        transactions, _ = parse("qif", QIF, "20250115093000_card-5678.qif")
+        self.assertEqual(transactions[0].account_number, 5678)
+
+        _, errors = parse("qif", QIF, "20250115093000_card.qif")
+        self.assertIn("No account number", errors[0])
+
+    def test_unknown_format_is_csv(self):
+        """Test that messages naming an unknown format are parsed as CSV."""
+        self.assertEqual(get_parser("lotus").name, "csv")
+        self.assertEqual(get_parser("qif").content_type, "application/qif")
+
+    def test_statement_extensions(self):
+        """Test that every format's extensions are importable."""
+        self.assertEqual(
+            STATEMENT_EXTENSIONS, (".csv", ".ofx", ".qfx", ".qif", ".xlsx")
+        )
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -5,8 +5,12 @@ Tests for storage configuration and connection logic.
 import unittest
 from unittest.mock import patch, MagicMock
 import os
+from datetime import timedelta
+
+from azure.storage.blob import StandardBlobTier
 
 from rmanalyzer.services import (
+    BlobKind,
     BlobService,
     QueueService,
 )
@@ -20,6 +24,7 @@ class TestStorageConfig(unittest.TestCase):
         self.original_env = dict(os.environ)
         # Clear relevant env vars to ensure clean state
         for key in [
+            "BACKUPS_ACCESS_TIER",
             "BLOB_SERVICE_URL",
             "QUEUE_SERVICE_URL",
             "BLOB_CONTAINER_NAME",
@@ -96,6 +101,57 @@ class TestStorageConfig(unittest.TestCase):
         self.assertIs(client1, client2)
         mock_blob_client.assert_called_once()
 
+    @patch("rmanalyzer.services.blob_service.BlobServiceClient")
+    def test_container_routing_per_kind(self, _):
+        """Test that each kind is routed to its own container and access tier."""
+        os.environ["BLOB_SERVICE_URL"] = "http://127.0.0.1:10000/devstoreaccount1"
+        os.environ["BLOB_CONTAINER_NAME"] = "my-uploads"
+        os.environ["BACKUPS_ACCESS_TIER"] = "Cold"
+
+        service = BlobService()
+        mock_container = MagicMock()
+        # pylint: disable=protected-access
+        service._get_container_client = MagicMock(return_value=mock_container)
+
+        self.assertEqual(service.container_name(BlobKind.UPLOADS), "my-uploads")
+        self.assertEqual(service.container_name(BlobKind.REPORTS), "reports")
+
+        service.upload_blob(BlobKind.BACKUPS, "backup.json", b"{}")
+
+        service._get_container_client.assert_called_with("backups")
+        blob_client = mock_container.get_blob_client.return_value
+        _, kwargs = blob_client.upload_blob.call_args
+        self.assertEqual(kwargs["standard_blob_tier"], StandardBlobTier.COLD)
+
+    @patch("rmanalyzer.services.blob_service.generate_blob_sas", return_value="sig")
+    @patch("rmanalyzer.services.blob_service.BlobServiceClient")
+    def test_download_url_reuses_delegation_key(self, mock_blob_client, mock_sas):
+        """Test that production links are signed with one cached delegation key."""
+        os.environ["BLOB_SERVICE_URL"] = "https://account.blob.core.windows.net"
+        client = mock_blob_client.return_value
+        client.get_blob_client.return_value.url = "https://account.blob/doc.pdf"
+
+        service = BlobService()
+        for _ in range(2):
+            url = service.download_url(
+                BlobKind.DOCUMENTS,
+                "2025-05/doc.pdf",
+                timedelta(minutes=15),
+                'my "doc".pdf',
+                "application/pdf",
+            )
+
+        self.assertEqual(url, "https://account.blob/doc.pdf?sig")
+        client.get_user_delegation_key.assert_called_once()
+        kwargs = mock_sas.call_args.kwargs
+        self.assertEqual(kwargs["container_name"], "documents")
+        self.assertEqual(
+            kwargs["content_disposition"], 'attachment; filename="my doc.pdf"'
+        )
+        self.assertIs(
+            kwargs["user_delegation_key"], client.get_user_delegation_key.return_value
+        )
+
     @patch("rmanalyzer.services.queue_service.QueueClient")
     def test_init_queue_service_missing_url(self, _):
         """Test that ValueError is raised when QUEUE_SERVICE_URL is missing."""
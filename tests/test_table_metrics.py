@@ -0,0 +1,111 @@
+"""
+Tests for Table Storage request counting.
+"""
+
+import os
+import unittest
+from datetime import datetime, timedelta
+from unittest.mock import MagicMock, patch
+
+from rmanalyzer.services import shared_metrics
+from rmanalyzer.services.table_metrics import (
+    InstrumentedTableClient,
+    monthly_cost,
+    storage_operation,
+    storage_ops,
+)
+
+
+class TestInstrumentedTableClient(unittest.TestCase):
+    """Test suite for InstrumentedTableClient."""
+
+    def setUp(self):
+        shared_metrics._COUNTS.clear()  # pylint: disable=protected-access
+        self.inner = MagicMock()
+        self.client = InstrumentedTableClient(self.inner)
+
+    @staticmethod
+    def _ops():
+        counted = shared_metrics._COUNTS.values()  # pylint: disable=protected-access
+        return storage_ops(shared_metrics.total(counted), datetime(2024, 1, 1))
+
+    def _counts(self, operation):
+        return self._ops()["operations"][operation]
+
+    def test_counts_query_pages_and_entities(self):
+        """Test that a query counts a request per page of entities it reads."""
+        self.inner.query_entities.return_value = iter([{}] * 1500)
+
+        with storage_operation("get_transactions"):
+            entities = list(self.client.query_entities("PartitionKey eq 'x'"))
+
+        self.assertEqual(len(entities), 1500)
+        counts = self._counts("get_transactions")
+        self.assertEqual(counts["requests"], 2)
+        self.assertEqual(counts["entitiesRead"], 1500)
+
+    def test_empty_query_is_one_request(self):
+        """Test that a query returning nothing still costs a request."""
+        self.inner.list_entities.return_value = iter([])
+
+        list(self.client.list_entities())
+
+        self.assertEqual(self._counts("background")["requests"], 1)
+
+    def test_counts_writes_and_batches(self):
+        """Test that single writes and batches count the entities they write."""
+        with storage_operation("upload"):
+            self.client.upsert_entity({"RowKey": "1"})
+            self.client.submit_transaction(("upsert", {}) for _ in range(3))
+
+        counts = self._counts("upload")
+        self.assertEqual(counts["requests"], 2)
+        self.assertEqual(counts["entitiesWritten"], 4)
+        self.assertEqual(counts["batches"], 1)
+        self.assertEqual(len(self.inner.submit_transaction.call_args[0][0]), 3)
+        self.assertEqual(self._ops()["totals"]["entitiesWritten"], 4)
+
+    def test_failed_read_counts_request(self):
+        """Test that a read that fails still counts its request."""
+        self.inner.get_entity.side_effect = ValueError("missing")
+
+        with self.assertRaises(ValueError):
+            self.client.get_entity("p", "r")
+
+        counts = self._counts("background")
+        self.assertEqual(counts["requests"], 1)
+        self.assertEqual(counts["entitiesRead"], 0)
+
+    def test_other_metric_groups_left_out(self):
+        """Test that shared counts other than table requests aren't reported."""
+        ops = storage_ops(
+            {"http": {"requests": 5}, "table:upload": {"requests": 2}},
+            datetime(2024, 1, 1),
+        )
+
+        self.assertEqual(list(ops["operations"]), ["upload"])
+        self.assertEqual(ops["totals"]["requests"], 2)
+        self.assertEqual(ops["since"], "2024-01-01T00:00:00")
+
+    def test_other_attributes_pass_through(self):
+        """Test that uncounted attributes reach the wrapped client."""
+        self.assertIs(self.client.table_name, self.inner.table_name)
+
+
+class TestMonthlyCost(unittest.TestCase):
+    """Test suite for monthly_cost."""
+
+    @patch.dict(os.environ, {"TABLE_PRICE_PER_10K_TRANSACTIONS": "0.5"})
+    def test_projects_rate_over_a_month(self):
+        """Test that the request rate so far is projected over a month."""
+        now = datetime(2024, 1, 2)
+
+        cost = monthly_cost(1000, now - timedelta(days=1), now)
+
+        self.assertEqual(cost["projectedRequests"], 30440)
+        self.assertEqual(cost["pricePer10k"], 0.5)
+        self.assertEqual(cost["estimate"], 1.522)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,44 @@
+"""
+Tests for household usage soft limits.
+"""
+
+import os
+import unittest
+from unittest.mock import patch
+
+from rmanalyzer.usage import over_limits, soft_limits
+
+
+class TestUsage(unittest.TestCase):
+    @patch.dict(
+        os.environ,
+        {
+            "USAGE_LIMIT_TRANSACTIONS": "50000",
+            "USAGE_LIMIT_STORAGE_MB": "2",
+            "USAGE_LIMIT_EMAILS": "lots",
+        },
+    )
+    def test_soft_limits(self):
+        self.assertEqual(
+            soft_limits(), {"transactions": 50000, "storageBytes": 2 * 1024 * 1024}
+        )
+
+    @patch.dict(os.environ, {"USAGE_LIMIT_EMAILS": "0"})
+    def test_zero_is_no_limit(self):
+        self.assertNotIn("emailsSent", soft_limits())
+
+    def test_over_limits(self):
+        usage = {"transactions": 120, "storageBytes": 10, "emailsSent": 3}
+        limits = {"transactions": 100, "emailsSent": 3, "storageBytes": 11}
+
+        self.assertEqual(
+            over_limits(usage, limits),
+            [
+                {"metric": "transactions", "usage": 120, "limit": 100},
+                {"metric": "emailsSent", "usage": 3, "limit": 3},
+            ],
+        )
+
+
+if __name__ == "__main__":
+    unittest.main()
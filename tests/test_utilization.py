@@ -0,0 +1,32 @@
+"""
+Tests for credit utilization.
+"""
+
+import unittest
+
+from rmanalyzer.utilization import aggregate_utilization, card_utilization
+
+
+class TestUtilization(unittest.TestCase):
+    def test_card_utilization(self):
+        self.assertEqual(card_utilization({"balance": 250, "limit": 1000}), 0.25)
+        self.assertIsNone(card_utilization({"balance": 250, "limit": None}))
+        self.assertEqual(card_utilization({"balance": None, "limit": 1000}), 0.0)
+
+    def test_aggregate_skips_cards_without_a_limit(self):
+        accounts = [
+            {"balance": 900, "limit": 1000},
+            {"balance": 100, "limit": 4000},
+            {"balance": 500, "limit": None},
+        ]
+
+        aggregate = aggregate_utilization(accounts)
+
+        self.assertEqual(aggregate, {"balance": 1000.0, "limit": 5000.0, "ratio": 0.2})
+
+    def test_aggregate_without_limits(self):
+        self.assertIsNone(aggregate_utilization([{"balance": 10}])["ratio"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,97 @@
+"""
+Tests for the request validation layer.
+"""
+
+import unittest
+
+from rmanalyzer.validation import MONTH_PATTERN, Schema
+
+
+class TestSchema(unittest.TestCase):
+    """Test suite for Schema validation."""
+
+    def test_required_field_missing(self):
+        """Test that a missing required field is reported."""
+        errors = Schema().number("amount", required=True).validate({})
+        self.assertEqual(len(errors), 1)
+        self.assertEqual(errors[0].field, "amount")
+        self.assertEqual(errors[0].message, "is required")
+
+    def test_optional_field_missing(self):
+        """Test that optional fields may be omitted."""
+        errors = Schema().string("month", pattern=MONTH_PATTERN).validate({})
+        self.assertEqual(errors, [])
+
+    def test_string_pattern(self):
+        """Test regex validation of string fields."""
+        schema = Schema().string("month", pattern=MONTH_PATTERN)
+        self.assertEqual(schema.validate({"month": "2025-08"}), [])
+        errors = schema.validate({"month": "2025-13"})
+        self.assertEqual(errors[0].field, "month")
+
+    def test_string_max_length(self):
+        """Test that strings longer than max_length are rejected."""
+        schema = Schema().string("name", max_length=3)
+        self.assertEqual(schema.validate({"name": "abc"}), [])
+        errors = schema.validate({"name": "abcd"})
+        self.assertEqual(errors[0].message, "must be at most 3 characters")
+
+    def test_number_accepts_numeric_strings(self):
+        """Test that numbers may be passed as strings (query params)."""
+        schema = Schema().number("amount", minimum=0)
+        self.assertEqual(schema.validate({"amount": "12.5"}), [])
+        self.assertEqual(len(schema.validate({"amount": "abc"})), 1)
+        self.assertEqual(len(schema.validate({"amount": -1})), 1)
+        self.assertEqual(len(schema.validate({"amount": True})), 1)
+        self.assertEqual(len(schema.validate({"amount": "NaN"})), 1)
+
+    def test_integer_range(self):
+        """Test that integers outside the minimum and maximum are rejected."""
+        schema = Schema().integer("month", minimum=1, maximum=12)
+        self.assertEqual(schema.validate({"month": 12}), [])
+        errors = schema.validate({"month": 13})
+        self.assertEqual(errors[0].message, "must be at most 12")
+        self.assertEqual(len(schema.validate({"month": 0})), 1)
+
+    def test_integer_rejects_fractions_and_overflow(self):
+        """Test that integers must be whole and finite, and may be strings."""
+        schema = Schema().integer("count")
+        self.assertEqual(schema.validate({"count": "7"}), [])
+        self.assertEqual(schema.validate({"count": 7.0}), [])
+        errors = schema.validate({"count": 1.5})
+        self.assertEqual(errors[0].message, "must be an integer")
+        self.assertEqual(len(schema.validate({"count": "1.5"})), 1)
+        self.assertEqual(len(schema.validate({"count": float("inf")})), 1)
+        self.assertEqual(len(schema.validate({"count": "1e400"})), 1)
+        self.assertEqual(len(schema.validate({"count": 10**400})), 1)
+
+    def test_array_item_check(self):
+        """Test that array items are checked individually."""
+        schema = Schema().array(
+            "items", check=lambda i: None if isinstance(i, int) else "bad"
+        )
+        self.assertEqual(schema.validate({"items": [1, 2]}), [])
+        errors = schema.validate({"items": [1, "x"]})
+        self.assertEqual(errors[0].message, "item 1: bad")
+
+    def test_array_max_length(self):
+        """Test that an array can be capped in length."""
+        schema = Schema().array("items", max_length=2)
+        self.assertEqual(schema.validate({"items": [1, 2]}), [])
+        errors = schema.validate({"items": [1, 2, 3]})
+        self.assertEqual(errors[0].message, "must have at most 2 items")
+
+    def test_collects_all_errors(self):
+        """Test that every failing field is reported, not just the first."""
+        schema = Schema().string("name", required=True).integer("count")
+        errors = schema.validate({"count": "x"})
+        self.assertEqual([e.field for e in errors], ["name", "count"])
+
+    def test_non_object_body(self):
+        """Test that a non-object body is rejected."""
+        errors = Schema().validate(["not", "an", "object"])
+        self.assertEqual(errors[0].field, "body")
+
+
+if __name__ == "__main__":
+    unittest.main()
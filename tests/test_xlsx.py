@@ -0,0 +1,165 @@
+"""
+Tests for Excel (.xlsx) statement parsing and writing.
+"""
+
+import io
+import unittest
+import zipfile
+from datetime import date
+from decimal import Decimal
+
+from rmanalyzer.models import Category
+from rmanalyzer.xlsx import get_xlsx_transactions, is_xlsx, workbook_stream
+
+MAIN = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+DOC_RELS = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+PKG_RELS = "http://schemas.openxmlformats.org/package/2006/relationships"
+
+WORKBOOK = f"""<workbook xmlns="{MAIN}" xmlns:r="{DOC_RELS}">
+<sheets><sheet name="Statement" sheetId="1" r:id="rId1"/></sheets>
+</workbook>"""
+
+WORKBOOK_RELS = f"""<Relationships xmlns="{PKG_RELS}">
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"
+ Type="{DOC_RELS}/worksheet"/>
+</Relationships>"""
+
+SHARED_STRINGS = f"""<sst xmlns="{MAIN}">
+<si><t>Date</t></si><si><t>Name</t></si><si><t>Account Number</t></si>
+<si><t>Amount</t></si><si><t>Category</t></si><si><t>Safeway</t></si>
+<si><t>Groceries</t></si>
+</sst>"""
+
+# Row 2 has a date serial and a float-noise amount, row 3 inline text and a
+# skipped Category cell, row 4 is blank, and row 5 is missing its account
+SHEET = f"""<worksheet xmlns="{MAIN}"><sheetData>
+<row r="1">
+<c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c>
+<c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c>
+<c r="E1" t="s"><v>4</v></c>
+</row>
+<row r="2">
+<c r="A2"><v>45672</v></c><c r="B2" t="s"><v>5</v></c>
+<c r="C2"><v>1234</v></c><c r="D2"><v>42.500000000000007</v></c>
+<c r="E2" t="s"><v>6</v></c>
+</row>
+<row r="3">
+<c r="A3" t="inlineStr"><is><t>2025-01-20</t></is></c>
+<c r="B3" t="inlineStr"><is><t>Paycheck</t></is></c>
+<c r="C3"><v>1234</v></c><c r="D3"><v>-1000</v></c>
+</row>
+<row r="4"/>
+<row r="5">
+<c r="A5" t="inlineStr"><is><t>2025-01-21</t></is></c>
+<c r="B5" t="inlineStr"><is><t>Nowhere</t></is></c>
+<c r="D5"><v>5</v></c>
+</row>
+</sheetData></worksheet>"""
+
+
+def _workbook(**parts):
+    defaults = {
+        "xl/workbook.xml": WORKBOOK,
+        "xl/_rels/workbook.xml.rels": WORKBOOK_RELS,
+        "xl/sharedStrings.xml": SHARED_STRINGS,
+        "xl/worksheets/sheet1.xml": SHEET,
+    }
+    out = io.BytesIO()
+    with zipfile.ZipFile(out, "w") as workbook:
+        for name, content in {**defaults, **parts}.items():
+            workbook.writestr(name, content)
+    return out.getvalue()
+
+
+class TestXlsx(unittest.TestCase):
+    def test_is_xlsx(self):
+        self.assertTrue(is_xlsx("Statement.XLSX"))
+        self.assertFalse(is_xlsx("statement.xls"))
+
+    def test_reads_first_worksheet(self):
+        transactions, errors = get_xlsx_transactions(_workbook())
+
+        self.assertEqual(len(transactions), 2)
+        grocery, paycheck = transactions
+        self.assertEqual(grocery.date, date(2025, 1, 15))
+        self.assertEqual(grocery.name, "Safeway")
+        self.assertEqual(grocery.account_number, 1234)
+        self.assertEqual(grocery.amount, Decimal("42.5"))
+        self.assertEqual(grocery.category, Category.GROCERIES)
+        self.assertEqual(paycheck.date, date(2025, 1, 20))
+        self.assertEqual(paycheck.amount, Decimal("-1000"))
+        self.assertEqual(paycheck.category, Category.OTHER)
+        self.assertEqual(len(errors), 1)
+        self.assertTrue(errors[0].startswith("Row 3: Invalid or missing 'Account"))
+
+    def test_not_a_workbook(self):
+        transactions, errors = get_xlsx_transactions(b"Date,Name\n")
+
+        self.assertEqual(transactions, [])
+        self.assertEqual(len(errors), 1)
+        self.assertTrue(errors[0].startswith("Not a valid .xlsx workbook"))
+
+    def test_empty_sheet(self):
+        empty = f'<worksheet xmlns="{MAIN}"><sheetData/></worksheet>'
+        workbook = _workbook(**{"xl/worksheets/sheet1.xml": empty})
+        self.assertEqual(get_xlsx_transactions(workbook), ([], []))
+
+    def test_out_of_range_numbers_are_row_errors(self):
+        sheet = f"""<worksheet xmlns="{MAIN}"><sheetData>
+<row r="1">
+<c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c>
+<c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c>
+</row>
+<row r="2">
+<c r="A2"><v>1e400</v></c><c r="B2" t="s"><v>5</v></c>
+<c r="C2"><v>1234</v></c><c r="D2"><v>5</v></c>
+</row>
+<row r="3">
+<c r="A3"><v>inf</v></c><c r="B3" t="s"><v>5</v></c>
+<c r="C3"><v>1234</v></c><c r="D3"><v>5</v></c>
+</row>
+<row r="4">
+<c r="A4"><v>45672</v></c><c r="B4" t="s"><v>5</v></c>
+<c r="C4"><v>1234</v></c><c r="D4"><v>1e400</v></c>
+</row>
+<row r="5">
+<c r="A5"><v>45672</v></c><c r="B5" t="s"><v>5</v></c>
+<c r="C5"><v>1234</v></c><c r="D5"><v>5</v></c>
+</row>
+</sheetData></worksheet>"""
+
+        transactions, errors = get_xlsx_transactions(
+            _workbook(**{"xl/worksheets/sheet1.xml": sheet})
+        )
+
+        self.assertEqual(len(transactions), 1)
+        self.assertEqual(
+            errors,
+            [
+                "Row 1: Invalid 'Date': inf",
+                "Row 2: Invalid 'Date': inf",
+                "Row 3: Invalid or missing 'Amount': inf",
+            ],
+        )
+
+    def test_written_workbook_reads_back(self):
+        rows = [
+            ["Date", "Name", "Account Number", "Amount", "Category"],
+            ["2025-01-05", "Fish & Chips <to go>", 1234, Decimal("12.50"), "Pets"],
+        ]
+
+        content = b"".join(workbook_stream(iter(rows)))
+
+        self.assertIsNone(zipfile.ZipFile(io.BytesIO(content)).testzip())
+        transactions, errors = get_xlsx_transactions(content)
+        self.assertEqual(errors, [])
+        (t,) = transactions
+        self.assertEqual(t.date, date(2025, 1, 5))
+        self.assertEqual(t.name, "Fish & Chips <to go>")
+        self.assertEqual(t.account_number, 1234)
+        self.assertEqual(t.amount, Decimal("12.5"))
+        self.assertEqual(t.category, Category.PETS)
+
+
+if __name__ == "__main__":
+    unittest.main()